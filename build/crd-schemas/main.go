@@ -0,0 +1,179 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// crd-schemas generates the OpenAPI v3 validation schemas of the Agones CRDs in the Helm chart from
+// the Go types, so that every field of their spec is described, and merges into them the hand written
+// constraints, such as titles, enums and minimums, that are already in the chart.
+// It fails if a hand written constraint is for a field that does not exist.
+//
+// Run it from the root of the repository with `make gen-crd-schemas`, then `make gen-install`.
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"agones.dev/agones/pkg/util/crd"
+	"agones.dev/agones/pkg/util/runtime"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	apiv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+)
+
+const (
+	// includeSentinel stands in for the include of the GameServer validation
+	// while a schema is parsed and generated
+	includeSentinel = "AGONES_GAMESERVER_VALIDATION_INCLUDE"
+	gsDefine        = `{{- define "gameserver.validation" }}`
+	gsEnd           = `{{- end }}`
+)
+
+var includeRegex = regexp.MustCompile(`^(\s*)\{\{- include "gameserver.validation" \. \| indent \d+ \}\}$`)
+
+// schemas are the CRD templates, or partials, in the chart whose schemas are generated
+var schemas = []struct {
+	file string
+	obj  interface{}
+	// includes are the paths of the fields that include the GameServer validation, if any
+	includes [][]string
+}{
+	// the GameServer validation is the schema of the GameServer CRD, and of the GameServer templates
+	{file: "_gameserverspecvalidation.yaml", obj: v1alpha1.GameServerTemplateSpec{}},
+	{file: "fleet.yaml", obj: v1alpha1.Fleet{}, includes: [][]string{{"spec", "template"}, {"spec", "canary", "template"}}},
+	{file: "gameserverset.yaml", obj: v1alpha1.GameServerSet{}, includes: [][]string{{"spec", "template"}}},
+	{file: "fleetautoscaler.yaml", obj: autoscalingv1.FleetAutoscaler{}},
+}
+
+func main() {
+	dir := flag.String("dir", "install/helm/agones/templates/crds", "directory of the CRD templates of the Helm chart")
+	flag.Parse()
+
+	logger := runtime.NewLoggerWithSource("crd-schemas")
+	for _, s := range schemas {
+		path := filepath.Join(*dir, s.file)
+		if err := generate(path, reflect.TypeOf(s.obj), s.includes); err != nil {
+			logger.WithError(err).WithField("file", path).Fatal("Could not generate CRD schema")
+		}
+		logger.WithField("file", path).Info("Generated CRD schema")
+	}
+}
+
+// generate replaces the schema in the template at path with the generated schema of t,
+// merged with the constraints of the schema it replaces
+func generate(path string, t reflect.Type, includes [][]string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "error reading template")
+	}
+	lines := strings.Split(string(b), "\n")
+	start, end, indent, err := schemaBlock(lines)
+	if err != nil {
+		return err
+	}
+
+	constraints := apiv1beta1.JSONSchemaProps{}
+	if err := yaml.Unmarshal([]byte(strings.Join(dedent(lines[start:end], indent), "\n")), &constraints); err != nil {
+		return errors.Wrap(err, "error unmarshalling schema")
+	}
+
+	// only the spec is described, and the root can't have a type when the
+	// status subresource is enabled
+	generated := crd.Schema(t)
+	generated = apiv1beta1.JSONSchemaProps{Properties: map[string]apiv1beta1.JSONSchemaProps{"spec": generated.Properties["spec"]}}
+	for _, include := range includes {
+		setProperty(&generated, include, apiv1beta1.JSONSchemaProps{})
+	}
+	if err := crd.MergeSchema(&generated, constraints); err != nil {
+		return errors.Wrap(err, "error merging schema")
+	}
+
+	out, err := yaml.Marshal(generated)
+	if err != nil {
+		return errors.Wrap(err, "error marshalling schema")
+	}
+	schema := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	for i, l := range schema {
+		if strings.HasSuffix(l, "description: "+includeSentinel) {
+			prefix := strings.TrimSuffix(l, "description: "+includeSentinel)
+			schema[i] = prefix + `{{- include "gameserver.validation" . | indent ` + strconv.Itoa(len(prefix)+indent) + ` }}`
+		}
+		schema[i] = strings.Repeat(" ", indent) + schema[i]
+	}
+
+	result := append(append(append([]string{}, lines[:start]...), schema...), lines[end:]...)
+	return errors.Wrap(ioutil.WriteFile(path, []byte(strings.Join(result, "\n")), 0644), "error writing template")
+}
+
+// schemaBlock returns the range of lines of the schema in the template,
+// and how far it is indented
+func schemaBlock(lines []string) (start, end, indent int, err error) {
+	for i, l := range lines {
+		if l == gsDefine {
+			for j := len(lines) - 1; j > i; j-- {
+				if lines[j] == gsEnd {
+					return i + 1, j, 0, nil
+				}
+			}
+			return 0, 0, 0, errors.New("could not find the end of the gameserver.validation definition")
+		}
+
+		if strings.TrimSpace(l) == "openAPIV3Schema:" {
+			indent = len(l) - len(strings.TrimLeft(l, " ")) + 2
+			end = i + 1
+			for end < len(lines) && (strings.TrimSpace(lines[end]) == "" || strings.HasPrefix(lines[end], strings.Repeat(" ", indent))) {
+				end++
+			}
+			// keep any blank lines after the schema where they are
+			for end > i+1 && strings.TrimSpace(lines[end-1]) == "" {
+				end--
+			}
+			return i + 1, end, indent, nil
+		}
+	}
+	return 0, 0, 0, errors.New("could not find openAPIV3Schema")
+}
+
+// dedent removes indent spaces from the start of each line, and replaces the
+// include of the GameServer validation with includeSentinel, so it can be parsed
+func dedent(lines []string, indent int) []string {
+	result := make([]string, len(lines))
+	for i, l := range lines {
+		if m := includeRegex.FindStringSubmatch(l); m != nil {
+			l = m[1] + "description: " + includeSentinel
+		}
+		if len(l) >= indent {
+			l = l[indent:]
+		}
+		result[i] = l
+	}
+	return result
+}
+
+// setProperty sets the property of s at path to v
+func setProperty(s *apiv1beta1.JSONSchemaProps, path []string, v apiv1beta1.JSONSchemaProps) {
+	if len(path) == 1 {
+		s.Properties[path[0]] = v
+		return
+	}
+	p := s.Properties[path[0]]
+	setProperty(&p, path[1:], v)
+	s.Properties[path[0]] = p
+}
@@ -23,10 +23,14 @@ import (
 	"net/http"
 	"path/filepath"
 	"strings"
+	"time"
 
 	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
 	"agones.dev/agones/pkg/client/clientset/versioned"
+	allocationtesting "agones.dev/agones/pkg/gameserverallocations/testing"
 	"agones.dev/agones/pkg/util/runtime"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/rest"
 )
@@ -39,12 +43,24 @@ const (
 	certDir = "/home/allocator/client-ca/"
 	tlsDir  = "/home/allocator/tls/"
 	port    = "8443"
+
+	fakeBackendFlag      = "fake-backend"
+	fakeFleetNameFlag    = "fake-fleet-name"
+	fakeFleetSizeFlag    = "fake-fleet-size"
+	fakeReleaseAfterFlag = "fake-release-after"
 )
 
 // A handler for the web server
 type handler func(w http.ResponseWriter, r *http.Request)
 
 func main() {
+	conf := parseEnvFlags()
+
+	if conf.FakeBackend {
+		runFakeBackend(conf)
+		return
+	}
+
 	agonesClient, err := getAgonesClient()
 	if err != nil {
 		logger.WithError(err).Fatal("could not create agones client")
@@ -75,6 +91,57 @@ func main() {
 	logger.WithError(err).Fatal("allocation service crashed")
 }
 
+// runFakeBackend serves the allocation API over plain http, without client certificates, against an
+// in-memory synthetic Fleet rather than a cluster, so matchmakers can be load tested against it locally
+func runFakeBackend(conf config) {
+	backend := allocationtesting.NewFakeBackend(conf.FakeFleetName, "default", conf.FakeFleetSize, conf.FakeReleaseAfter)
+	h := httpHandler{
+		agonesClient: backend.Clientset(),
+	}
+	http.HandleFunc("/v1/gameserverallocation", h.postOnly(h.allocateHandler))
+
+	logger.WithField("conf", conf).Warn("serving allocations from a fake backend, for testing only")
+	err := http.ListenAndServe(":"+port, nil)
+	logger.WithError(err).Fatal("allocation service crashed")
+}
+
+// config is the configuration of the allocator
+type config struct {
+	FakeBackend      bool
+	FakeFleetName    string
+	FakeFleetSize    int
+	FakeReleaseAfter time.Duration
+}
+
+// parseEnvFlags parses all the flags and environment variables and returns
+// a configuration structure
+func parseEnvFlags() config {
+	viper.SetDefault(fakeBackendFlag, false)
+	viper.SetDefault(fakeFleetNameFlag, "fake-fleet")
+	viper.SetDefault(fakeFleetSizeFlag, 1000)
+	viper.SetDefault(fakeReleaseAfterFlag, 0)
+
+	pflag.Bool(fakeBackendFlag, viper.GetBool(fakeBackendFlag), "For load testing matchmakers, serve allocations over http from an in-memory synthetic Fleet rather than the cluster. Can also use FAKE_BACKEND env variable")
+	pflag.String(fakeFleetNameFlag, viper.GetString(fakeFleetNameFlag), "The name of the synthetic Fleet of the fake backend, which its GameServers are labelled with. Can also use FAKE_FLEET_NAME env variable")
+	pflag.Int(fakeFleetSizeFlag, viper.GetInt(fakeFleetSizeFlag), "The number of Ready GameServers in the synthetic Fleet of the fake backend. Can also use FAKE_FLEET_SIZE env variable")
+	pflag.Duration(fakeReleaseAfterFlag, viper.GetDuration(fakeReleaseAfterFlag), "How long after being allocated GameServers of the fake backend go back to Ready, as if their game session ended. 0 keeps them Allocated. Can also use FAKE_RELEASE_AFTER env variable")
+	pflag.Parse()
+
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	runtime.Must(viper.BindEnv(fakeBackendFlag))
+	runtime.Must(viper.BindEnv(fakeFleetNameFlag))
+	runtime.Must(viper.BindEnv(fakeFleetSizeFlag))
+	runtime.Must(viper.BindEnv(fakeReleaseAfterFlag))
+	runtime.Must(viper.BindPFlags(pflag.CommandLine))
+
+	return config{
+		FakeBackend:      viper.GetBool(fakeBackendFlag),
+		FakeFleetName:    viper.GetString(fakeFleetNameFlag),
+		FakeFleetSize:    viper.GetInt(fakeFleetSizeFlag),
+		FakeReleaseAfter: viper.GetDuration(fakeReleaseAfterFlag),
+	}
+}
+
 // Set up our client which we will use to call the API
 func getAgonesClient() (*versioned.Clientset, error) {
 	// Create the in-cluster config
@@ -0,0 +1,109 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"time"
+
+	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
+	multiclusterv1alpha1 "agones.dev/agones/pkg/apis/multicluster/v1alpha1"
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"agones.dev/agones/pkg/client/clientset/versioned"
+	"agones.dev/agones/pkg/client/informers/externalversions"
+	autoscalinginformers "agones.dev/agones/pkg/client/informers/externalversions/autoscaling/v1"
+	agonesinterfaces "agones.dev/agones/pkg/client/informers/externalversions/internalinterfaces"
+	multiclusterinformers "agones.dev/agones/pkg/client/informers/externalversions/multicluster/v1alpha1"
+	stableinformers "agones.dev/agones/pkg/client/informers/externalversions/stable/v1alpha1"
+	"agones.dev/agones/pkg/util/namespaces"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	kubeinterfaces "k8s.io/client-go/informers/internalinterfaces"
+	policyinformers "k8s.io/client-go/informers/policy/v1beta1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// registerInformers registers informers with the informer factories for the resources that the
+// controllers don't watch all of: every namespaced resource, if they only watch the given namespaces,
+// and Pods and GameServers, if they are filtered by label selectors. This has to happen before the
+// controllers are created, as the factories return the first informer registered for a type.
+// Nodes are cluster scoped, so are always watched cluster-wide.
+func registerInformers(nsList []string, podSelector, gameServerSelector string,
+	kubeInformerFactory informers.SharedInformerFactory, agonesInformerFactory externalversions.SharedInformerFactory) {
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+
+	// informer returns an informer of the objects in the watched namespaces,
+	// from the informers that newInformer creates for a namespace
+	informer := func(newInformer func(ns string) cache.SharedIndexInformer) cache.SharedIndexInformer {
+		if len(nsList) == 0 {
+			return newInformer(metav1.NamespaceAll)
+		}
+		return namespaces.NewInformer(nsList, newInformer)
+	}
+	// agonesInformer and kubeInformer return the func that creates an informer of the objects
+	// that match the label selector in the watched namespaces, for the InformerFor of a factory
+	agonesInformer := func(selector string, newInformer func(versioned.Interface, string, time.Duration, cache.Indexers,
+		agonesinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer) func(versioned.Interface, time.Duration) cache.SharedIndexInformer {
+		return func(client versioned.Interface, resync time.Duration) cache.SharedIndexInformer {
+			return informer(func(ns string) cache.SharedIndexInformer {
+				return newInformer(client, ns, resync, indexers, func(options *metav1.ListOptions) {
+					options.LabelSelector = selector
+				})
+			})
+		}
+	}
+	kubeInformer := func(selector string, newInformer func(kubernetes.Interface, string, time.Duration, cache.Indexers,
+		kubeinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer) func(kubernetes.Interface, time.Duration) cache.SharedIndexInformer {
+		return func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+			return informer(func(ns string) cache.SharedIndexInformer {
+				return newInformer(client, ns, resync, indexers, func(options *metav1.ListOptions) {
+					options.LabelSelector = selector
+				})
+			})
+		}
+	}
+
+	if len(nsList) > 0 || gameServerSelector != "" {
+		agonesInformerFactory.InformerFor(&v1alpha1.GameServer{}, agonesInformer(gameServerSelector, stableinformers.NewFilteredGameServerInformer))
+	}
+	if len(nsList) > 0 || podSelector != "" {
+		kubeInformerFactory.InformerFor(&corev1.Pod{}, kubeInformer(podSelector, coreinformers.NewFilteredPodInformer))
+	}
+	if len(nsList) == 0 {
+		return
+	}
+	agonesInformerFactory.InformerFor(&v1alpha1.GameServerSet{}, agonesInformer("", stableinformers.NewFilteredGameServerSetInformer))
+	agonesInformerFactory.InformerFor(&v1alpha1.Fleet{}, agonesInformer("", stableinformers.NewFilteredFleetInformer))
+	agonesInformerFactory.InformerFor(&autoscalingv1.FleetAutoscaler{}, agonesInformer("", autoscalinginformers.NewFilteredFleetAutoscalerInformer))
+	agonesInformerFactory.InformerFor(&multiclusterv1alpha1.GameServerAllocationPolicy{},
+		agonesInformer("", multiclusterinformers.NewFilteredGameServerAllocationPolicyInformer))
+	kubeInformerFactory.InformerFor(&corev1.Secret{}, kubeInformer("", coreinformers.NewFilteredSecretInformer))
+	kubeInformerFactory.InformerFor(&policyv1beta1.PodDisruptionBudget{}, kubeInformer("", policyinformers.NewFilteredPodDisruptionBudgetInformer))
+}
+
+// parseNamespaces parses a comma separated list of namespaces
+func parseNamespaces(s string) []string {
+	var result []string
+	for _, ns := range strings.Split(s, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			result = append(result, ns)
+		}
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// leaderElectionLockName is the name of the ConfigMap that the controller
+	// replicas hold the leader election lock through
+	leaderElectionLockName = "agones-controller-lock"
+
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// runLeaderElection elects a leader between the controller replicas, through a ConfigMap lock
+// in namespace, and calls lead when this replica becomes the leader. It blocks until this
+// replica stops leading, at which point the controller exits, as its controllers can't be
+// restarted once they have been stopped, and is restarted as a candidate.
+func runLeaderElection(kubeClient kubernetes.Interface, namespace string, lead func(stop <-chan struct{})) error {
+	id, err := os.Hostname()
+	if err != nil {
+		return errors.Wrap(err, "error getting the hostname as the leader election identity")
+	}
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(logger.Infof)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "agones-controller"})
+
+	lock, err := resourcelock.New(resourcelock.ConfigMapsResourceLock, namespace, leaderElectionLockName, kubeClient.CoreV1(),
+		resourcelock.ResourceLockConfig{Identity: id, EventRecorder: recorder})
+	if err != nil {
+		return errors.Wrap(err, "error creating the leader election lock")
+	}
+
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: leaseDuration,
+		RenewDeadline: renewDeadline,
+		RetryPeriod:   retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(stop <-chan struct{}) {
+				logger.WithField("identity", id).Info("Started leading, starting controllers")
+				lead(stop)
+			},
+			OnStoppedLeading: func() {
+				logger.WithField("identity", id).Fatal("Stopped leading, exiting")
+			},
+			OnNewLeader: func(identity string) {
+				logger.WithField("identity", id).WithField("leader", identity).Info("Leader elected")
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "error creating the leader elector")
+	}
+
+	logger.WithField("identity", id).WithField("namespace", namespace).Info("Running leader election")
+	le.Run()
+	return nil
+}
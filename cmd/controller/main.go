@@ -16,6 +16,7 @@
 package main
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"os"
@@ -24,6 +25,10 @@ import (
 	"time"
 
 	"agones.dev/agones/pkg"
+	"agones.dev/agones/pkg/apis/autoscaling"
+	"agones.dev/agones/pkg/apis/stable"
+	stablev1 "agones.dev/agones/pkg/apis/stable/v1"
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
 	"agones.dev/agones/pkg/client/clientset/versioned"
 	"agones.dev/agones/pkg/client/informers/externalversions"
 	"agones.dev/agones/pkg/fleetautoscalers"
@@ -31,8 +36,10 @@ import (
 	"agones.dev/agones/pkg/gameserverallocations"
 	"agones.dev/agones/pkg/gameservers"
 	"agones.dev/agones/pkg/gameserversets"
+	"agones.dev/agones/pkg/lint"
 	"agones.dev/agones/pkg/metrics"
 	"agones.dev/agones/pkg/util/apiserver"
+	"agones.dev/agones/pkg/util/crd"
 	"agones.dev/agones/pkg/util/https"
 	"agones.dev/agones/pkg/util/runtime"
 	"agones.dev/agones/pkg/util/signals"
@@ -44,8 +51,12 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"gopkg.in/natefinch/lumberjack.v2"
+	corev1 "k8s.io/api/core/v1"
 	extclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	extv1beta1 "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
@@ -60,8 +71,25 @@ const (
 	sidecarCPULimitFlag          = "sidecar-cpu-limit"
 	sdkServerAccountFlag         = "sdk-service-account"
 	pullSidecarFlag              = "always-pull-sidecar"
+	sidecarTokenExpirationFlag   = "sidecar-token-expiration"
+	sidecarTokenAudienceFlag     = "sidecar-token-audience"
+	sidecarPingReportPeriodFlag  = "sidecar-ping-report-period"
 	minPortFlag                  = "min-port"
 	maxPortFlag                  = "max-port"
+	stickyPortsFlag              = "sticky-ports"
+	maxPortsPerGameServerFlag    = "max-ports-per-gameserver"
+	additionalPortRangesFlag     = "additional-port-ranges"
+	errorRetentionFlag           = "error-gameserver-retention"
+	unhealthyRetentionFlag       = "unhealthy-gameserver-retention"
+	creationBurstFlag            = "gameserver-creation-burst"
+	creationRateFlag             = "gameserver-creation-rate"
+	maxPendingCreationsFlag      = "max-pending-gameservers"
+	nodeAddressPriorityFlag      = "node-address-priority"
+	preferIPv6AddressFlag        = "prefer-ipv6-address"
+	nodeAddressLabelFlag         = "node-address-label"
+	podPriorityClassNameFlag     = "pod-priority-class-name"
+	podTolerationsFlag           = "pod-tolerations"
+	podNodeSelectorFlag          = "pod-node-selector"
 	certFileFlag                 = "cert-file"
 	keyFileFlag                  = "key-file"
 	numWorkersFlag               = "num-workers"
@@ -70,10 +98,37 @@ const (
 	logDirFlag                   = "log-dir"
 	logSizeLimitMBFlag           = "log-size-limit-mb"
 	kubeconfigFlag               = "kubeconfig"
+	crdWaitTimeoutFlag           = "crd-wait-timeout"
+	partialStartFlag             = "partial-start"
+	versionSkewPolicyFlag        = "version-skew-policy"
+	fleetResourceEstimatesFlag   = "fleet-resource-estimates"
+	sidecarRolloutFleetsFlag     = "sidecar-rollout-fleets"
+	leaderElectionFlag           = "leader-election"
+	leaderElectionNamespaceFlag  = "leader-election-namespace"
+	allocationLogSampleRateFlag  = "allocation-log-sample-rate"
+	allocationEventsFlag         = "allocation-events"
+	allocationRateLimitFlag      = "allocation-rate-limit"
+	namespacesFlag               = "namespaces"
+	podLabelSelectorFlag         = "pod-label-selector"
+	gameServerLabelSelectorFlag  = "gameserver-label-selector"
 	defaultResync                = 30 * time.Second
 	// topNGSForAllocation is used by the GameServerAllocation controller
 	// to reduce the contention while allocating gameservers.
 	topNGSForAllocation = 100
+
+	gameServersCRD      = "gameservers." + stable.GroupName
+	gameServerSetsCRD   = "gameserversets." + stable.GroupName
+	fleetsCRD           = "fleets." + stable.GroupName
+	fleetAutoscalersCRD = "fleetautoscalers." + autoscaling.GroupName
+
+	// versionSkewRefuse fails the controller's start if the schema version of any Agones CRD
+	// is not the one this controller was built for.
+	versionSkewRefuse = "Refuse"
+	// versionSkewCompatible starts the controller if the Agones CRDs have an older, but compatible,
+	// schema version (e.g. part way through a helm upgrade), but still fails it if any have an incompatible
+	// or a newer schema version, as this controller would drop the fields it doesn't know about when it
+	// updates resources.
+	versionSkewCompatible = "Compatible"
 )
 
 var (
@@ -140,13 +195,36 @@ func main() {
 	// https server and the items that share the Mux for routing
 	httpsServer := https.NewServer(ctlConf.CertFile, ctlConf.KeyFile)
 	wh := webhooks.NewWebHook(httpsServer.Mux)
+	if len(ctlConf.Namespaces) > 0 {
+		wh.SetNamespaces(ctlConf.Namespaces)
+	}
+	if ctlConf.GameServerLabelSelector != "" {
+		// the selector has already been checked by validate()
+		selector, _ := labels.Parse(ctlConf.GameServerLabelSelector)
+		wh.SetLabelSelector(v1alpha1.Kind("GameServer"), selector)
+	}
 	api := apiserver.NewAPIServer(httpsServer.Mux)
+	headerAuth, err := apiserver.LoadRequestHeaderAuth(kubeClient.CoreV1())
+	if err != nil {
+		logger.WithError(err).Warn("Could not load the requestheader client CA, so the users of api requests are unverified")
+	}
+	api.Use(apiserver.Trace("agones.dev/apiserver"), apiserver.RemoteUser(headerAuth), apiserver.LogRequests(logger))
+
+	// GameServers, Fleets and GameServerSets are served as both v1alpha1 and v1
+	for _, kind := range []string{"GameServer", "Fleet", "GameServerSet"} {
+		wh.AddConversionHandler("/convert", stablev1.Kind(kind), stablev1.Convert)
+	}
+	// GameServers, Fleets and GameServerSets can be linted through the same webhooks, without creating them
+	lint.NewLinter(wh, api)
 
 	agonesInformerFactory := externalversions.NewSharedInformerFactory(agonesClient, defaultResync)
 	kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, defaultResync)
+	registerInformers(ctlConf.Namespaces, ctlConf.PodLabelSelector, ctlConf.GameServerLabelSelector,
+		kubeInformerFactory, agonesInformerFactory)
 
 	server := &httpServer{}
-	var rs []runner
+	// rs run on every replica, and leaderRs only on the leader, when there is leader election
+	var rs, leaderRs []runner
 	var health healthcheck.Handler
 
 	// Stackdriver metrics
@@ -177,47 +255,158 @@ func main() {
 	// which is a requirements of Stackdriver, otherwise most of time series would be invalid for Stackdriver
 	metrics.SetReportingPeriod(ctlConf.PrometheusMetrics, ctlConf.Stackdriver)
 
+	// the custom resource definitions each runner needs to be established before it can start
+	runnerCRDs := map[runner][]string{}
+
 	// Add metrics controller only if we configure one of metrics exporters
 	if ctlConf.PrometheusMetrics || ctlConf.Stackdriver {
-		rs = append(rs, metrics.NewController(kubeClient, agonesClient, kubeInformerFactory, agonesInformerFactory))
+		metricsController := metrics.NewController(kubeClient, agonesClient, kubeInformerFactory, agonesInformerFactory)
+		runnerCRDs[metricsController] = []string{gameServersCRD, fleetsCRD, fleetAutoscalersCRD}
+		leaderRs = append(leaderRs, metricsController)
 	}
 
 	server.Handle("/", health)
 
 	gsCounter := gameservers.NewPerNodeCounter(kubeInformerFactory, agonesInformerFactory)
+	// allocation failures are counted in memory by the replica that serves the allocation, so they can only be
+	// handed to the FleetAutoscaler controller when there is a single replica, which runs both.
+	// The recorder is only set along with the counter, so that it stays nil otherwise.
+	var allocationFailures *fleetautoscalers.FailureCounter
+	var allocationFailureRecorder gameserverallocations.FailureRecorder
+	if !ctlConf.LeaderElection {
+		allocationFailures = fleetautoscalers.NewFailureCounter()
+		allocationFailureRecorder = allocationFailures
+	}
 
-	gsController := gameservers.NewController(wh, health,
-		ctlConf.MinPort, ctlConf.MaxPort, ctlConf.SidecarImage, ctlConf.AlwaysPullSidecar,
-		ctlConf.SidecarCPURequest, ctlConf.SidecarCPULimit, ctlConf.SdkServiceAccount,
+	gsController := gameservers.NewController(wh, health, ctlConf.CRDWaitTimeout,
+		ctlConf.MinPort, ctlConf.MaxPort, ctlConf.AdditionalPortRanges, ctlConf.StickyPorts, ctlConf.MaxPortsPerGameServer, ctlConf.SidecarImage, ctlConf.AlwaysPullSidecar,
+		ctlConf.SidecarCPURequest, ctlConf.SidecarCPULimit, ctlConf.SdkServiceAccount, ctlConf.SidecarToken, ctlConf.SidecarPingReportPeriod, ctlConf.ErrorRetention,
+		ctlConf.NodeAddressPriority, ctlConf.PreferIPv6Address, ctlConf.NodeAddressLabel, ctlConf.PodDefaults,
 		kubeClient, kubeInformerFactory, extClient, agonesClient, agonesInformerFactory)
-	gsSetController := gameserversets.NewController(wh, health, gsCounter,
+	gsSetController := gameserversets.NewController(wh, health, ctlConf.CRDWaitTimeout, gsCounter, ctlConf.UnhealthyRetention, ctlConf.CreationLimits,
 		kubeClient, extClient, agonesClient, agonesInformerFactory)
-	fleetController := fleets.NewController(wh, health, kubeClient, extClient, agonesClient, agonesInformerFactory)
-	gasController := gameserverallocations.NewController(api, health, gsCounter, topNGSForAllocation,
+	fleetController := fleets.NewController(wh, api, health, ctlConf.CRDWaitTimeout, ctlConf.ResourceEstimates, ctlConf.sidecarResources(), ctlConf.SidecarImage, ctlConf.SidecarRolloutFleets,
+		kubeClient, extClient, agonesClient, kubeInformerFactory, agonesInformerFactory)
+	gasController := gameserverallocations.NewController(api, health, gsCounter, topNGSForAllocation, ctlConf.AllocationLogSampleRate, ctlConf.AllocationEvents, ctlConf.AllocationRateLimit, allocationFailureRecorder,
 		kubeClient, kubeInformerFactory, agonesClient, agonesInformerFactory)
-	fasController := fleetautoscalers.NewController(wh, health,
-		kubeClient, extClient, agonesClient, agonesInformerFactory)
+	fasController := fleetautoscalers.NewController(wh, health, ctlConf.CRDWaitTimeout, allocationFailures,
+		kubeClient, kubeInformerFactory, extClient, agonesClient, agonesInformerFactory)
+
+	rs = append(rs, httpsServer, gsCounter, gasController, server)
+	leaderRs = append(leaderRs, gsController, gsSetController, fleetController, fasController)
 
-	rs = append(rs,
-		httpsServer, gsCounter, gsController, gsSetController, fleetController, fasController, gasController, server)
+	runnerCRDs[gsCounter] = []string{gameServersCRD}
+	runnerCRDs[gsController] = []string{gameServersCRD}
+	runnerCRDs[gsSetController] = []string{gameServersCRD, gameServerSetsCRD}
+	runnerCRDs[fleetController] = []string{gameServerSetsCRD, fleetsCRD}
+	runnerCRDs[fasController] = []string{fleetsCRD, fleetAutoscalersCRD}
+	runnerCRDs[gasController] = []string{gameServersCRD}
 
 	stop := signals.NewStopChannel()
 
 	kubeInformerFactory.Start(stop)
 	agonesInformerFactory.Start(stop)
 
-	for _, r := range rs {
-		go func(rr runner) {
-			if runErr := rr.Run(ctlConf.NumWorkers, stop); runErr != nil {
-				logger.WithError(runErr).Fatalf("could not start runner: %T", rr)
+	// Wait for the custom resource definitions in the background, so the http servers
+	// (and therefore health checks) are available in the meantime.
+	crdGetter := extClient.ApiextensionsV1beta1().CustomResourceDefinitions()
+	crdsEstablished := make(chan struct{})
+	missingCRDs := map[string]bool{}
+	go func() {
+		defer close(crdsEstablished)
+		names := []string{gameServersCRD, gameServerSetsCRD, fleetsCRD, fleetAutoscalersCRD}
+		err := crd.WaitForEstablishedCRDs(crdGetter, names, ctlConf.CRDWaitTimeout, logger)
+		checkSchemaVersions(crdGetter, names, ctlConf.VersionSkewPolicy)
+		if err == nil {
+			return
+		}
+		nee, ok := err.(*crd.NotEstablishedError)
+		if !ok || !ctlConf.PartialStart {
+			logger.WithError(err).Fatal("Could not start controllers, as not all custom resource definitions are established")
+		}
+		logger.WithError(err).Warn("Starting controllers whose custom resource definitions are established, retrying the rest in the background")
+		for _, name := range nee.Names {
+			missingCRDs[name] = true
+		}
+	}()
+
+	start := func(runners []runner, stop <-chan struct{}) {
+		for _, r := range runners {
+			go func(rr runner) {
+				if names, ok := runnerCRDs[rr]; ok {
+					<-crdsEstablished
+					for _, name := range names {
+						if missingCRDs[name] {
+							if !waitForCRDs(crdGetter, names, ctlConf.CRDWaitTimeout, stop) {
+								return
+							}
+							break
+						}
+					}
+				}
+
+				if runErr := rr.Run(ctlConf.NumWorkers, stop); runErr != nil {
+					logger.WithError(runErr).Fatalf("could not start runner: %T", rr)
+				}
+			}(r)
+		}
+	}
+
+	start(rs, stop)
+	if ctlConf.LeaderElection {
+		go func() {
+			if err := runLeaderElection(kubeClient, ctlConf.LeaderElectionNS, func(leaderStop <-chan struct{}) {
+				start(leaderRs, leaderStop)
+			}); err != nil {
+				logger.WithError(err).Fatal("Could not run leader election")
 			}
-		}(r)
+		}()
+	} else {
+		start(leaderRs, stop)
 	}
 
 	<-stop
 	logger.Info("Shut down agones controllers")
 }
 
+// checkSchemaVersions checks the schema versions of the named custom resource definitions
+// against the one this controller was built for, and fails the controller if they are
+// not compatible under the given version skew policy.
+func checkSchemaVersions(crdGetter extv1beta1.CustomResourceDefinitionInterface, names []string, policy string) {
+	err := crd.CheckSchemaVersions(crdGetter, names, crd.SchemaVersion, crd.MinCompatibleSchemaVersion)
+	if err == nil {
+		return
+	}
+	skew, ok := err.(*crd.SchemaVersionSkewError)
+	if !ok {
+		logger.WithError(err).Fatal("Could not check the schema versions of the custom resource definitions")
+	}
+	if policy == versionSkewCompatible && skew.Compatible() {
+		logger.WithError(err).Warn("Running in compatibility mode, as custom resource definitions have an older schema version than this controller")
+		return
+	}
+	logger.WithError(err).WithField(versionSkewPolicyFlag, policy).
+		Fatal("Could not start controllers, as the custom resource definitions have a different schema version than this controller")
+}
+
+// waitForCRDs retries waiting for the named custom resource definitions to be established,
+// until they are, or stop is closed. Returns false if stop was closed first.
+func waitForCRDs(crdGetter extv1beta1.CustomResourceDefinitionInterface, names []string, timeout time.Duration, stop <-chan struct{}) bool {
+	for {
+		err := crd.WaitForEstablishedCRDs(crdGetter, names, timeout, logger)
+		if err == nil {
+			return true
+		}
+		logger.WithError(err).WithField("crds", names).Warn("Custom resource definitions not established, retrying")
+
+		select {
+		case <-stop:
+			return false
+		case <-time.After(time.Second):
+		}
+	}
+}
+
 func parseEnvFlags() config {
 	exec, err := os.Executable()
 	if err != nil {
@@ -229,6 +418,23 @@ func parseEnvFlags() config {
 	viper.SetDefault(sidecarCPURequestFlag, "0")
 	viper.SetDefault(sidecarCPULimitFlag, "0")
 	viper.SetDefault(pullSidecarFlag, false)
+	viper.SetDefault(sidecarTokenExpirationFlag, 0)
+	viper.SetDefault(sidecarTokenAudienceFlag, "")
+	viper.SetDefault(sidecarPingReportPeriodFlag, 0)
+	viper.SetDefault(stickyPortsFlag, false)
+	viper.SetDefault(maxPortsPerGameServerFlag, 16)
+	viper.SetDefault(additionalPortRangesFlag, "{}")
+	viper.SetDefault(errorRetentionFlag, 0)
+	viper.SetDefault(unhealthyRetentionFlag, 0)
+	viper.SetDefault(creationBurstFlag, 64)
+	viper.SetDefault(creationRateFlag, 0)
+	viper.SetDefault(maxPendingCreationsFlag, 5000)
+	viper.SetDefault(nodeAddressPriorityFlag, "ExternalIP,InternalIP")
+	viper.SetDefault(preferIPv6AddressFlag, false)
+	viper.SetDefault(nodeAddressLabelFlag, "")
+	viper.SetDefault(podPriorityClassNameFlag, "")
+	viper.SetDefault(podTolerationsFlag, "[]")
+	viper.SetDefault(podNodeSelectorFlag, "{}")
 	viper.SetDefault(sdkServerAccountFlag, "agones-sdk")
 	viper.SetDefault(certFileFlag, filepath.Join(base, "certs/server.crt"))
 	viper.SetDefault(keyFileFlag, filepath.Join(base, "certs/server.key"))
@@ -240,14 +446,44 @@ func parseEnvFlags() config {
 	viper.SetDefault(apiServerBurstQPSFlag, 200)
 	viper.SetDefault(logDirFlag, "")
 	viper.SetDefault(logSizeLimitMBFlag, 10000) // 10 GB, will be split into 100 MB chunks
+	viper.SetDefault(crdWaitTimeoutFlag, crd.DefaultEstablishedTimeout)
+	viper.SetDefault(partialStartFlag, false)
+	viper.SetDefault(versionSkewPolicyFlag, versionSkewRefuse)
+	viper.SetDefault(fleetResourceEstimatesFlag, false)
+	viper.SetDefault(sidecarRolloutFleetsFlag, 0)
+	viper.SetDefault(leaderElectionFlag, false)
+	viper.SetDefault(leaderElectionNamespaceFlag, "agones-system")
+	viper.SetDefault(allocationLogSampleRateFlag, 0)
+	viper.SetDefault(allocationEventsFlag, false)
+	viper.SetDefault(allocationRateLimitFlag, 0)
+	viper.SetDefault(namespacesFlag, "")
+	viper.SetDefault(podLabelSelectorFlag, "")
+	viper.SetDefault(gameServerLabelSelectorFlag, "")
 
 	pflag.String(sidecarImageFlag, viper.GetString(sidecarImageFlag), "Flag to overwrite the GameServer sidecar image that is used. Can also use SIDECAR env variable")
 	pflag.String(sidecarCPULimitFlag, viper.GetString(sidecarCPULimitFlag), "Flag to overwrite the GameServer sidecar container's cpu limit. Can also use SIDECAR_CPU_LIMIT env variable")
 	pflag.String(sidecarCPURequestFlag, viper.GetString(sidecarCPURequestFlag), "Flag to overwrite the GameServer sidecar container's cpu request. Can also use SIDECAR_CPU_REQUEST env variable")
 	pflag.Bool(pullSidecarFlag, viper.GetBool(pullSidecarFlag), "For development purposes, set the sidecar image to have a ImagePullPolicy of Always. Can also use ALWAYS_PULL_SIDECAR env variable")
+	pflag.Duration(sidecarTokenExpirationFlag, viper.GetDuration(sidecarTokenExpirationFlag), "Authenticate the GameServer sidecar with a projected service account token that is valid for this long, at least 10m, which is mounted in place of the token of its service account Secret. 0 disables this. Can also use SIDECAR_TOKEN_EXPIRATION env variable")
+	pflag.String(sidecarTokenAudienceFlag, viper.GetString(sidecarTokenAudienceFlag), "The audience of the projected token of the GameServer sidecar, which the API server must accept. Defaults to the API server's own. Can also use SIDECAR_TOKEN_AUDIENCE env variable")
+	pflag.Duration(sidecarPingReportPeriodFlag, viper.GetDuration(sidecarPingReportPeriodFlag), "How often the GameServer sidecar reports the last health ping in the GameServer's status, which writes the GameServer each time. 0 disables this. Can also use SIDECAR_PING_REPORT_PERIOD env variable")
 	pflag.String(sdkServerAccountFlag, viper.GetString(sdkServerAccountFlag), "Overwrite what service account default for GameServer Pods. Defaults to Can also use SDK_SERVICE_ACCOUNT")
 	pflag.Int32(minPortFlag, 0, "Required. The minimum port that that a GameServer can be allocated to. Can also use MIN_PORT env variable.")
 	pflag.Int32(maxPortFlag, 0, "Required. The maximum port that that a GameServer can be allocated to. Can also use MAX_PORT env variable")
+	pflag.Bool(stickyPortsFlag, viper.GetBool(stickyPortsFlag), "Prefer reusing the ports previously held by a Fleet's GameServers when allocating new ones. Can also use STICKY_PORTS env variable")
+	pflag.Int(maxPortsPerGameServerFlag, viper.GetInt(maxPortsPerGameServerFlag), "The most Dynamic and Passthrough ports that a single GameServer can request. Can also use MAX_PORTS_PER_GAMESERVER env variable")
+	pflag.String(additionalPortRangesFlag, viper.GetString(additionalPortRangesFlag), `Named port ranges that GameServer ports can be allocated from, besides the default one, as JSON, e.g. {"query":[9000,9100]}. Can also use ADDITIONAL_PORT_RANGES env variable`)
+	pflag.Duration(errorRetentionFlag, viper.GetDuration(errorRetentionFlag), "How long GameServers that are not owned by a GameServerSet are kept in the Error state before they are deleted. 0 keeps them until they are deleted manually. Can also use ERROR_GAMESERVER_RETENTION env variable")
+	pflag.Duration(unhealthyRetentionFlag, viper.GetDuration(unhealthyRetentionFlag), "How long Unhealthy GameServers that are owned by a GameServerSet, and their Pods, are kept for debugging before they are deleted. They are still replaced straight away. 0 deletes them straight away. Can also use UNHEALTHY_GAMESERVER_RETENTION env variable")
+	pflag.Int(creationBurstFlag, viper.GetInt(creationBurstFlag), "The most GameServers that a GameServerSet creates in a single batch. Can also use GAMESERVER_CREATION_BURST env variable")
+	pflag.Float64(creationRateFlag, viper.GetFloat64(creationRateFlag), "How many GameServers are created per second across all GameServerSets, once a burst of them has been created. 0 is no limit. Can also use GAMESERVER_CREATION_RATE env variable")
+	pflag.Int(maxPendingCreationsFlag, viper.GetInt(maxPendingCreationsFlag), "The most GameServers of a GameServerSet that can be waiting for their Pods to be scheduled and start, before it creates any more. Can also use MAX_PENDING_GAMESERVERS env variable")
+	pflag.String(nodeAddressPriorityFlag, viper.GetString(nodeAddressPriorityFlag), "Comma separated Node address types, in the order they are picked for a GameServer's address, e.g. ExternalDNS,ExternalIP,InternalIP. Can also use NODE_ADDRESS_PRIORITY env variable")
+	pflag.Bool(preferIPv6AddressFlag, viper.GetBool(preferIPv6AddressFlag), "Pick a Node's IPv6 address over its IPv4 address of the same type for a GameServer's address. Can also use PREFER_IPV6_ADDRESS env variable")
+	pflag.String(nodeAddressLabelFlag, viper.GetString(nodeAddressLabelFlag), "Optional. Node label whose value, when set on a Node, is used as the address of its GameServers instead of the Node's addresses, e.g. agones.dev/public-address. Can also use NODE_ADDRESS_LABEL env variable")
+	pflag.String(podPriorityClassNameFlag, viper.GetString(podPriorityClassNameFlag), "Optional. The priorityClassName of GameServer Pods that don't set one. Can also use POD_PRIORITY_CLASS_NAME env variable")
+	pflag.String(podTolerationsFlag, viper.GetString(podTolerationsFlag), "Optional. The tolerations of GameServer Pods that don't set any, as a JSON list, e.g. [{\"key\": \"dedicated\", \"value\": \"gameservers\", \"effect\": \"NoSchedule\"}]. Can also use POD_TOLERATIONS env variable")
+	pflag.String(podNodeSelectorFlag, viper.GetString(podNodeSelectorFlag), "Optional. The nodeSelector of GameServer Pods that don't set one, as a JSON object, e.g. {\"dedicated\": \"gameservers\"}. Can also use POD_NODE_SELECTOR env variable")
 	pflag.String(keyFileFlag, viper.GetString(keyFileFlag), "Optional. Path to the key file")
 	pflag.String(certFileFlag, viper.GetString(certFileFlag), "Optional. Path to the crt file")
 	pflag.String(kubeconfigFlag, viper.GetString(kubeconfigFlag), "Optional. kubeconfig to run the controller out of the cluster. Only use it for debugging as webhook won't works.")
@@ -259,6 +495,19 @@ func parseEnvFlags() config {
 	pflag.Int32(apiServerBurstQPSFlag, 200, "Maximum burst queries per second to send to the API server")
 	pflag.String(logDirFlag, viper.GetString(logDirFlag), "If set, store logs in a given directory.")
 	pflag.Int32(logSizeLimitMBFlag, 1000, "Log file size limit in MB")
+	pflag.Duration(crdWaitTimeoutFlag, viper.GetDuration(crdWaitTimeoutFlag), "How long to wait for the Agones custom resource definitions to be established before failing. Can also use CRD_WAIT_TIMEOUT env variable")
+	pflag.Bool(partialStartFlag, viper.GetBool(partialStartFlag), "If custom resource definitions are not established in time, start the controllers whose custom resource definitions are, and keep retrying the rest. Can also use PARTIAL_START env variable")
+	pflag.String(versionSkewPolicyFlag, viper.GetString(versionSkewPolicyFlag), "What to do when the schema version of the custom resource definitions is not the one this controller was built for. Refuse fails the controller, and Compatible only fails it if a schema version is newer, or too old to be compatible. Can also use VERSION_SKEW_POLICY env variable")
+	pflag.Bool(fleetResourceEstimatesFlag, viper.GetBool(fleetResourceEstimatesFlag), "Annotate Fleets with the estimated CPU and memory requests of each of their GameServer Pods, including the SDK sidecar. Can also use FLEET_RESOURCE_ESTIMATES env variable")
+	pflag.Int32(sidecarRolloutFleetsFlag, viper.GetInt32(sidecarRolloutFleetsFlag), "When the sidecar image changes, replace the GameServers of Fleets created with a different one through their deployment strategy, this many Fleets at a time. 0 disables this. Can also use SIDECAR_ROLLOUT_FLEETS env variable")
+	pflag.Bool(leaderElectionFlag, viper.GetBool(leaderElectionFlag), "Elect a leader between the controller replicas, so that only the leader runs the controllers, while all replicas serve the webhooks and the allocation API. Can also use LEADER_ELECTION env variable")
+	pflag.String(leaderElectionNamespaceFlag, viper.GetString(leaderElectionNamespaceFlag), "The namespace of the ConfigMap that the controller replicas hold the leader election lock through. Can also use LEADER_ELECTION_NAMESPACE env variable")
+	pflag.Float64(allocationLogSampleRateFlag, viper.GetFloat64(allocationLogSampleRateFlag), "The fraction of allocation requests, between 0 and 1, that are logged with a summary of their selectors, their result, latency and retries. Can also use ALLOCATION_LOG_SAMPLE_RATE env variable")
+	pflag.Bool(allocationEventsFlag, viper.GetBool(allocationEventsFlag), "Record the namespace and user that allocated each GameServer in its events, and a summary of the allocations from each Fleet on the Fleet every minute. Can also use ALLOCATION_EVENTS env variable")
+	pflag.Float64(allocationRateLimitFlag, viper.GetFloat64(allocationRateLimitFlag), "How many allocation requests per second each controller replica serves, responding to any more with 429 Too Many Requests. 0 is no limit. Can also use ALLOCATION_RATE_LIMIT env variable")
+	pflag.String(namespacesFlag, viper.GetString(namespacesFlag), "Optional. Comma separated namespaces that the controllers watch, and the webhooks mutate and validate, instead of the whole cluster, e.g. team-a,team-b. Can also use NAMESPACES env variable")
+	pflag.String(podLabelSelectorFlag, viper.GetString(podLabelSelectorFlag), "Optional. Label selector of the Pods that the controllers cache, to save memory in clusters with many other Pods, e.g. stable.agones.dev/role=gameserver. The host ports of Pods that don't match are not known to the port allocator. Can also use POD_LABEL_SELECTOR env variable")
+	pflag.String(gameServerLabelSelectorFlag, viper.GetString(gameServerLabelSelectorFlag), "Optional. Label selector of the GameServers that the controllers cache and manage, and the webhooks mutate and validate. The GameServers of Fleets and GameServerSets must match it. Can also use GAMESERVER_LABEL_SELECTOR env variable")
 	pflag.Parse()
 
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
@@ -266,9 +515,26 @@ func parseEnvFlags() config {
 	runtime.Must(viper.BindEnv(sidecarCPULimitFlag))
 	runtime.Must(viper.BindEnv(sidecarCPURequestFlag))
 	runtime.Must(viper.BindEnv(pullSidecarFlag))
+	runtime.Must(viper.BindEnv(sidecarTokenExpirationFlag))
+	runtime.Must(viper.BindEnv(sidecarTokenAudienceFlag))
+	runtime.Must(viper.BindEnv(sidecarPingReportPeriodFlag))
 	runtime.Must(viper.BindEnv(sdkServerAccountFlag))
 	runtime.Must(viper.BindEnv(minPortFlag))
 	runtime.Must(viper.BindEnv(maxPortFlag))
+	runtime.Must(viper.BindEnv(stickyPortsFlag))
+	runtime.Must(viper.BindEnv(maxPortsPerGameServerFlag))
+	runtime.Must(viper.BindEnv(additionalPortRangesFlag))
+	runtime.Must(viper.BindEnv(errorRetentionFlag))
+	runtime.Must(viper.BindEnv(unhealthyRetentionFlag))
+	runtime.Must(viper.BindEnv(creationBurstFlag))
+	runtime.Must(viper.BindEnv(creationRateFlag))
+	runtime.Must(viper.BindEnv(maxPendingCreationsFlag))
+	runtime.Must(viper.BindEnv(nodeAddressPriorityFlag))
+	runtime.Must(viper.BindEnv(preferIPv6AddressFlag))
+	runtime.Must(viper.BindEnv(nodeAddressLabelFlag))
+	runtime.Must(viper.BindEnv(podPriorityClassNameFlag))
+	runtime.Must(viper.BindEnv(podTolerationsFlag))
+	runtime.Must(viper.BindEnv(podNodeSelectorFlag))
 	runtime.Must(viper.BindEnv(keyFileFlag))
 	runtime.Must(viper.BindEnv(certFileFlag))
 	runtime.Must(viper.BindEnv(kubeconfigFlag))
@@ -281,6 +547,19 @@ func parseEnvFlags() config {
 	runtime.Must(viper.BindEnv(apiServerBurstQPSFlag))
 	runtime.Must(viper.BindEnv(logDirFlag))
 	runtime.Must(viper.BindEnv(logSizeLimitMBFlag))
+	runtime.Must(viper.BindEnv(crdWaitTimeoutFlag))
+	runtime.Must(viper.BindEnv(partialStartFlag))
+	runtime.Must(viper.BindEnv(versionSkewPolicyFlag))
+	runtime.Must(viper.BindEnv(fleetResourceEstimatesFlag))
+	runtime.Must(viper.BindEnv(sidecarRolloutFleetsFlag))
+	runtime.Must(viper.BindEnv(leaderElectionFlag))
+	runtime.Must(viper.BindEnv(leaderElectionNamespaceFlag))
+	runtime.Must(viper.BindEnv(allocationLogSampleRateFlag))
+	runtime.Must(viper.BindEnv(allocationEventsFlag))
+	runtime.Must(viper.BindEnv(allocationRateLimitFlag))
+	runtime.Must(viper.BindEnv(namespacesFlag))
+	runtime.Must(viper.BindEnv(podLabelSelectorFlag))
+	runtime.Must(viper.BindEnv(gameServerLabelSelectorFlag))
 
 	request, err := resource.ParseQuantity(viper.GetString(sidecarCPURequestFlag))
 	if err != nil {
@@ -292,48 +571,122 @@ func parseEnvFlags() config {
 		logger.WithError(err).Fatalf("could not parse %s", sidecarCPULimitFlag)
 	}
 
+	portRanges, err := parsePortRanges(viper.GetString(additionalPortRangesFlag))
+	if err != nil {
+		logger.WithError(err).Fatalf("could not parse %s", additionalPortRangesFlag)
+	}
+
+	podDefaults, err := parsePodDefaults(viper.GetString(podPriorityClassNameFlag),
+		viper.GetString(podTolerationsFlag), viper.GetString(podNodeSelectorFlag))
+	if err != nil {
+		logger.WithError(err).Fatal("could not parse pod defaults")
+	}
+
 	return config{
-		MinPort:               int32(viper.GetInt64(minPortFlag)),
-		MaxPort:               int32(viper.GetInt64(maxPortFlag)),
-		SidecarImage:          viper.GetString(sidecarImageFlag),
-		SidecarCPURequest:     request,
-		SidecarCPULimit:       limit,
-		SdkServiceAccount:     viper.GetString(sdkServerAccountFlag),
-		AlwaysPullSidecar:     viper.GetBool(pullSidecarFlag),
-		KeyFile:               viper.GetString(keyFileFlag),
-		CertFile:              viper.GetString(certFileFlag),
-		KubeConfig:            viper.GetString(kubeconfigFlag),
-		PrometheusMetrics:     viper.GetBool(enablePrometheusMetricsFlag),
-		Stackdriver:           viper.GetBool(enableStackdriverMetricsFlag),
-		GCPProjectID:          viper.GetString(projectIDFlag),
-		NumWorkers:            int(viper.GetInt32(numWorkersFlag)),
-		APIServerSustainedQPS: int(viper.GetInt32(apiServerSustainedQPSFlag)),
-		APIServerBurstQPS:     int(viper.GetInt32(apiServerBurstQPSFlag)),
-		LogDir:                viper.GetString(logDirFlag),
-		LogSizeLimitMB:        int(viper.GetInt32(logSizeLimitMBFlag)),
+		MinPort:                 int32(viper.GetInt64(minPortFlag)),
+		MaxPort:                 int32(viper.GetInt64(maxPortFlag)),
+		StickyPorts:             viper.GetBool(stickyPortsFlag),
+		MaxPortsPerGameServer:   viper.GetInt(maxPortsPerGameServerFlag),
+		AdditionalPortRanges:    portRanges,
+		ErrorRetention:          viper.GetDuration(errorRetentionFlag),
+		UnhealthyRetention:      viper.GetDuration(unhealthyRetentionFlag),
+		CreationLimits:          gameserversets.CreationLimits{Burst: viper.GetInt(creationBurstFlag), Rate: viper.GetFloat64(creationRateFlag), MaxPending: viper.GetInt(maxPendingCreationsFlag)},
+		NodeAddressPriority:     parseNodeAddressPriority(viper.GetString(nodeAddressPriorityFlag)),
+		PreferIPv6Address:       viper.GetBool(preferIPv6AddressFlag),
+		NodeAddressLabel:        viper.GetString(nodeAddressLabelFlag),
+		PodDefaults:             podDefaults,
+		SidecarImage:            viper.GetString(sidecarImageFlag),
+		SidecarCPURequest:       request,
+		SidecarCPULimit:         limit,
+		SdkServiceAccount:       viper.GetString(sdkServerAccountFlag),
+		AlwaysPullSidecar:       viper.GetBool(pullSidecarFlag),
+		SidecarToken:            gameservers.SidecarToken{Audience: viper.GetString(sidecarTokenAudienceFlag), Expiration: viper.GetDuration(sidecarTokenExpirationFlag)},
+		SidecarPingReportPeriod: viper.GetDuration(sidecarPingReportPeriodFlag),
+		KeyFile:                 viper.GetString(keyFileFlag),
+		CertFile:                viper.GetString(certFileFlag),
+		KubeConfig:              viper.GetString(kubeconfigFlag),
+		PrometheusMetrics:       viper.GetBool(enablePrometheusMetricsFlag),
+		Stackdriver:             viper.GetBool(enableStackdriverMetricsFlag),
+		GCPProjectID:            viper.GetString(projectIDFlag),
+		NumWorkers:              int(viper.GetInt32(numWorkersFlag)),
+		APIServerSustainedQPS:   int(viper.GetInt32(apiServerSustainedQPSFlag)),
+		APIServerBurstQPS:       int(viper.GetInt32(apiServerBurstQPSFlag)),
+		LogDir:                  viper.GetString(logDirFlag),
+		LogSizeLimitMB:          int(viper.GetInt32(logSizeLimitMBFlag)),
+		CRDWaitTimeout:          viper.GetDuration(crdWaitTimeoutFlag),
+		PartialStart:            viper.GetBool(partialStartFlag),
+		VersionSkewPolicy:       viper.GetString(versionSkewPolicyFlag),
+		ResourceEstimates:       viper.GetBool(fleetResourceEstimatesFlag),
+		SidecarRolloutFleets:    int(viper.GetInt32(sidecarRolloutFleetsFlag)),
+		LeaderElection:          viper.GetBool(leaderElectionFlag),
+		LeaderElectionNS:        viper.GetString(leaderElectionNamespaceFlag),
+		AllocationLogSampleRate: viper.GetFloat64(allocationLogSampleRateFlag),
+		AllocationEvents:        viper.GetBool(allocationEventsFlag),
+		AllocationRateLimit:     viper.GetFloat64(allocationRateLimitFlag),
+		Namespaces:              parseNamespaces(viper.GetString(namespacesFlag)),
+		PodLabelSelector:        viper.GetString(podLabelSelectorFlag),
+		GameServerLabelSelector: viper.GetString(gameServerLabelSelectorFlag),
 	}
 }
 
 // config stores all required configuration to create a game server controller.
 type config struct {
-	MinPort               int32
-	MaxPort               int32
-	SidecarImage          string
-	SidecarCPURequest     resource.Quantity
-	SidecarCPULimit       resource.Quantity
-	SdkServiceAccount     string
-	AlwaysPullSidecar     bool
-	PrometheusMetrics     bool
-	Stackdriver           bool
-	KeyFile               string
-	CertFile              string
-	KubeConfig            string
-	GCPProjectID          string
-	NumWorkers            int
-	APIServerSustainedQPS int
-	APIServerBurstQPS     int
-	LogDir                string
-	LogSizeLimitMB        int
+	MinPort                 int32
+	MaxPort                 int32
+	StickyPorts             bool
+	MaxPortsPerGameServer   int
+	AdditionalPortRanges    map[string]gameservers.PortRange
+	ErrorRetention          time.Duration
+	UnhealthyRetention      time.Duration
+	CreationLimits          gameserversets.CreationLimits
+	NodeAddressPriority     []corev1.NodeAddressType
+	PreferIPv6Address       bool
+	NodeAddressLabel        string
+	PodDefaults             gameservers.PodDefaults
+	SidecarImage            string
+	SidecarCPURequest       resource.Quantity
+	SidecarCPULimit         resource.Quantity
+	SdkServiceAccount       string
+	AlwaysPullSidecar       bool
+	SidecarToken            gameservers.SidecarToken
+	SidecarPingReportPeriod time.Duration
+	PrometheusMetrics       bool
+	Stackdriver             bool
+	KeyFile                 string
+	CertFile                string
+	KubeConfig              string
+	GCPProjectID            string
+	NumWorkers              int
+	APIServerSustainedQPS   int
+	APIServerBurstQPS       int
+	LogDir                  string
+	LogSizeLimitMB          int
+	CRDWaitTimeout          time.Duration
+	PartialStart            bool
+	VersionSkewPolicy       string
+	ResourceEstimates       bool
+	SidecarRolloutFleets    int
+	LeaderElection          bool
+	LeaderElectionNS        string
+	AllocationLogSampleRate float64
+	AllocationEvents        bool
+	AllocationRateLimit     float64
+	Namespaces              []string
+	PodLabelSelector        string
+	GameServerLabelSelector string
+}
+
+// sidecarResources returns the resources that are set on the sdk sidecar
+// of each GameServer Pod, in the same way as the GameServer controller.
+func (c config) sidecarResources() corev1.ResourceRequirements {
+	r := corev1.ResourceRequirements{}
+	if !c.SidecarCPURequest.IsZero() {
+		r.Requests = corev1.ResourceList{corev1.ResourceCPU: c.SidecarCPURequest}
+	}
+	if !c.SidecarCPULimit.IsZero() {
+		r.Limits = corev1.ResourceList{corev1.ResourceCPU: c.SidecarCPULimit}
+	}
+	return r
 }
 
 // validate ensures the ctlConfig data is valid.
@@ -344,9 +697,159 @@ func (c config) validate() error {
 	if c.MaxPort < c.MinPort {
 		return errors.New("max Port cannot be set less that the Min Port")
 	}
+	ranges := map[string]gameservers.PortRange{v1alpha1.DefaultPortRange: {MinPort: c.MinPort, MaxPort: c.MaxPort}}
+	for name, r := range c.AdditionalPortRanges {
+		if name == v1alpha1.DefaultPortRange {
+			return errors.Errorf("port range %s is set by the Min Port and Max Port", name)
+		}
+		if r.MinPort <= 0 || r.MaxPort < r.MinPort {
+			return errors.Errorf("port range %s must have a min port greater than zero, and a max port no less than it", name)
+		}
+		for other, o := range ranges {
+			if r.MinPort <= o.MaxPort && o.MinPort <= r.MaxPort {
+				return errors.Errorf("port range %s overlaps port range %s", name, other)
+			}
+		}
+		ranges[name] = r
+	}
+	if c.MaxPortsPerGameServer < 1 {
+		return errors.New("max ports per gameserver must be at least 1")
+	}
+	if c.ErrorRetention < 0 {
+		return errors.New("error gameserver retention cannot be negative")
+	}
+	if c.UnhealthyRetention < 0 {
+		return errors.New("unhealthy gameserver retention cannot be negative")
+	}
+	if c.CreationLimits.Burst < 1 {
+		return errors.New("gameserver creation burst must be at least 1")
+	}
+	if c.CreationLimits.Rate < 0 {
+		return errors.New("gameserver creation rate cannot be negative")
+	}
+	if c.CreationLimits.MaxPending < 1 {
+		return errors.New("max pending gameservers must be at least 1")
+	}
+	if c.SidecarPingReportPeriod < 0 {
+		return errors.New("sidecar ping report period must not be negative")
+	}
+	if c.SidecarToken.Expiration != 0 && c.SidecarToken.Expiration < gameservers.MinSidecarTokenExpiration {
+		return errors.Errorf("sidecar token expiration must be 0, or at least %s", gameservers.MinSidecarTokenExpiration)
+	}
+	if len(c.NodeAddressPriority) == 0 {
+		return errors.New("node address priority must have at least one address type")
+	}
+	seen := map[corev1.NodeAddressType]bool{}
+	for _, t := range c.NodeAddressPriority {
+		switch t {
+		case corev1.NodeHostName, corev1.NodeExternalIP, corev1.NodeInternalIP, corev1.NodeExternalDNS, corev1.NodeInternalDNS:
+		default:
+			return errors.Errorf("node address type %s is not one of %s, %s, %s, %s or %s", t,
+				corev1.NodeHostName, corev1.NodeExternalIP, corev1.NodeInternalIP, corev1.NodeExternalDNS, corev1.NodeInternalDNS)
+		}
+		if seen[t] {
+			return errors.Errorf("node address type %s is in the node address priority more than once", t)
+		}
+		seen[t] = true
+	}
+	if c.NodeAddressLabel != "" {
+		if errs := validation.IsQualifiedName(c.NodeAddressLabel); len(errs) > 0 {
+			return errors.Errorf("node address label %s is not a valid label key: %s", c.NodeAddressLabel, strings.Join(errs, ", "))
+		}
+	}
+	if c.PodDefaults.PriorityClassName != "" {
+		if errs := validation.IsDNS1123Subdomain(c.PodDefaults.PriorityClassName); len(errs) > 0 {
+			return errors.Errorf("pod priority class name %s is not valid: %s", c.PodDefaults.PriorityClassName, strings.Join(errs, ", "))
+		}
+	}
+	for k, v := range c.PodDefaults.NodeSelector {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return errors.Errorf("pod node selector key %s is not a valid label key: %s", k, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return errors.Errorf("pod node selector value %s is not a valid label value: %s", v, strings.Join(errs, ", "))
+		}
+	}
+	if c.CRDWaitTimeout <= 0 {
+		return errors.New("crd wait timeout must be greater than zero")
+	}
+	if c.VersionSkewPolicy != versionSkewRefuse && c.VersionSkewPolicy != versionSkewCompatible {
+		return errors.Errorf("version skew policy %s is not one of %s or %s", c.VersionSkewPolicy, versionSkewRefuse, versionSkewCompatible)
+	}
+	if c.LeaderElection {
+		if errs := validation.IsDNS1123Label(c.LeaderElectionNS); len(errs) > 0 {
+			return errors.Errorf("leader election namespace %s is not valid: %s", c.LeaderElectionNS, strings.Join(errs, ", "))
+		}
+	}
+	if c.AllocationLogSampleRate < 0 || c.AllocationLogSampleRate > 1 {
+		return errors.New("allocation log sample rate must be between 0 and 1")
+	}
+	if c.AllocationRateLimit < 0 {
+		return errors.New("allocation rate limit must not be negative")
+	}
+	seenNamespaces := map[string]bool{}
+	for _, ns := range c.Namespaces {
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			return errors.Errorf("namespace %s is not valid: %s", ns, strings.Join(errs, ", "))
+		}
+		if seenNamespaces[ns] {
+			return errors.Errorf("namespace %s is in the namespaces more than once", ns)
+		}
+		seenNamespaces[ns] = true
+	}
+	if _, err := labels.Parse(c.PodLabelSelector); err != nil {
+		return errors.Wrapf(err, "pod label selector %s is not valid", c.PodLabelSelector)
+	}
+	if _, err := labels.Parse(c.GameServerLabelSelector); err != nil {
+		return errors.Wrapf(err, "gameserver label selector %s is not valid", c.GameServerLabelSelector)
+	}
 	return nil
 }
 
+// parsePortRanges parses named port ranges from JSON, in the form of {"name": [minPort, maxPort]}
+func parsePortRanges(s string) (map[string]gameservers.PortRange, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var raw map[string][2]int32
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, errors.Wrap(err, "port ranges must be in the form of {\"name\": [minPort, maxPort]}")
+	}
+	result := make(map[string]gameservers.PortRange, len(raw))
+	for name, r := range raw {
+		result[name] = gameservers.PortRange{MinPort: r[0], MaxPort: r[1]}
+	}
+	return result, nil
+}
+
+// parsePodDefaults parses the default tolerations of GameServer Pods from a JSON list, and their
+// default nodeSelector from a JSON object
+func parsePodDefaults(priorityClassName, tolerations, nodeSelector string) (gameservers.PodDefaults, error) {
+	result := gameservers.PodDefaults{PriorityClassName: priorityClassName}
+	if tolerations != "" {
+		if err := json.Unmarshal([]byte(tolerations), &result.Tolerations); err != nil {
+			return result, errors.Wrap(err, "pod tolerations must be a JSON list of tolerations")
+		}
+	}
+	if nodeSelector != "" {
+		if err := json.Unmarshal([]byte(nodeSelector), &result.NodeSelector); err != nil {
+			return result, errors.Wrap(err, "pod node selector must be a JSON object of labels")
+		}
+	}
+	return result, nil
+}
+
+// parseNodeAddressPriority parses a comma separated list of Node address types
+func parseNodeAddressPriority(s string) []corev1.NodeAddressType {
+	var result []corev1.NodeAddressType
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			result = append(result, corev1.NodeAddressType(t))
+		}
+	}
+	return result
+}
+
 type runner interface {
 	Run(workers int, stop <-chan struct{}) error
 }
@@ -47,13 +47,16 @@ const (
 	// specifically env vars
 	gameServerNameEnv = "GAMESERVER_NAME"
 	podNamespaceEnv   = "POD_NAMESPACE"
+	// the projected service account token to authenticate with, if the controller mounted one
+	sdkTokenFileEnv = "SDK_TOKEN_FILE"
 
 	// Flags (that can also be env vars)
-	localFlag   = "local"
-	fileFlag    = "file"
-	testFlag    = "test"
-	addressFlag = "address"
-	timeoutFlag = "timeout"
+	localFlag            = "local"
+	fileFlag             = "file"
+	testFlag             = "test"
+	addressFlag          = "address"
+	timeoutFlag          = "timeout"
+	pingReportPeriodFlag = "ping-report-period"
 )
 
 var (
@@ -95,6 +98,12 @@ func main() {
 		}
 		defer localSDK.Close()
 
+		// serve the health of the local game server, so development GameServers can be health checked
+		localMux := http.NewServeMux()
+		localMux.Handle("/", mux)
+		localMux.HandleFunc("/gshealthz", localSDK.HealthHandler)
+		httpServer.Handler = localMux
+
 		if ctlConf.Timeout != 0 {
 			go func() {
 				time.Sleep(time.Duration(ctlConf.Timeout) * time.Second)
@@ -120,6 +129,10 @@ func main() {
 		if err != nil {
 			logger.WithError(err).Fatal("Could not create in cluster config")
 		}
+		if tokenFile := viper.GetString(sdkTokenFileEnv); tokenFile != "" {
+			logger.WithField("path", tokenFile).Info("Authenticating with projected service account token")
+			withTokenFile(config, tokenFile)
+		}
 
 		var kubeClient *kubernetes.Clientset
 		kubeClient, err = kubernetes.NewForConfig(config)
@@ -135,7 +148,7 @@ func main() {
 
 		var s *sdkserver.SDKServer
 		s, err = sdkserver.NewSDKServer(viper.GetString(gameServerNameEnv),
-			viper.GetString(podNamespaceEnv), kubeClient, agonesClient)
+			viper.GetString(podNamespaceEnv), ctlConf.PingReportPeriod, kubeClient, agonesClient)
 		if err != nil {
 			logger.WithError(err).Fatalf("Could not start sidecar")
 		}
@@ -233,12 +246,14 @@ func parseEnvFlags() config {
 	viper.SetDefault(testFlag, "")
 	viper.SetDefault(addressFlag, "localhost")
 	viper.SetDefault(timeoutFlag, 0)
+	viper.SetDefault(pingReportPeriodFlag, 0)
 	pflag.Bool(localFlag, viper.GetBool(localFlag),
 		"Set this, or LOCAL env, to 'true' to run this binary in local development mode. Defaults to 'false'")
 	pflag.StringP(fileFlag, "f", viper.GetString(fileFlag), "Set this, or FILE env var to the path of a local yaml or json file that contains your GameServer resoure configuration")
 	pflag.String(addressFlag, viper.GetString(addressFlag), "The Address to bind the server grpcPort to. Defaults to 'localhost'")
 	pflag.Int(timeoutFlag, viper.GetInt(timeoutFlag), "Time of execution before close. Useful for tests")
 	pflag.String(testFlag, viper.GetString(testFlag), "List functions which shoud be called during the SDK Conformance test run.")
+	pflag.Duration(pingReportPeriodFlag, viper.GetDuration(pingReportPeriodFlag), "How often the last health ping is reported in the GameServer's status. 0 disables this. Can also use PING_REPORT_PERIOD env variable")
 	pflag.Parse()
 
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
@@ -247,23 +262,27 @@ func parseEnvFlags() config {
 	runtime.Must(viper.BindEnv(testFlag))
 	runtime.Must(viper.BindEnv(gameServerNameEnv))
 	runtime.Must(viper.BindEnv(podNamespaceEnv))
+	runtime.Must(viper.BindEnv(sdkTokenFileEnv))
 	runtime.Must(viper.BindEnv(timeoutFlag))
+	runtime.Must(viper.BindEnv(pingReportPeriodFlag))
 	runtime.Must(viper.BindPFlags(pflag.CommandLine))
 
 	return config{
-		IsLocal:   viper.GetBool(localFlag),
-		Address:   viper.GetString(addressFlag),
-		LocalFile: viper.GetString(fileFlag),
-		Timeout:   viper.GetInt(timeoutFlag),
-		Test:      viper.GetString(testFlag),
+		IsLocal:          viper.GetBool(localFlag),
+		Address:          viper.GetString(addressFlag),
+		LocalFile:        viper.GetString(fileFlag),
+		Timeout:          viper.GetInt(timeoutFlag),
+		Test:             viper.GetString(testFlag),
+		PingReportPeriod: viper.GetDuration(pingReportPeriodFlag),
 	}
 }
 
 // config is all the configuration for this program
 type config struct {
-	Address   string
-	IsLocal   bool
-	LocalFile string
-	Timeout   int
-	Test      string
+	Address          string
+	IsLocal          bool
+	LocalFile        string
+	Timeout          int
+	Test             string
+	PingReportPeriod time.Duration
 }
@@ -0,0 +1,92 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+// tokenRefreshPeriod is how often the token file is read again, which is well within
+// the time the kubelet refreshes a projected token before it expires
+const tokenRefreshPeriod = time.Minute
+
+// tokenFileRoundTripper authenticates requests with the bearer token in a file, such as a projected
+// service account token, which it reads again every tokenRefreshPeriod, as the kubelet refreshes it
+type tokenFileRoundTripper struct {
+	path string
+	rt   http.RoundTripper
+	now  func() time.Time
+
+	mu    sync.Mutex
+	token string
+	read  time.Time
+}
+
+// withTokenFile authenticates the requests of the config with the bearer token in the file at path,
+// rather than the token the config was created with
+func withTokenFile(config *rest.Config, path string) {
+	config.BearerToken = ""
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		return &tokenFileRoundTripper{path: path, rt: rt, now: time.Now}
+	}
+}
+
+// RoundTrip sends the request with the current token
+func (t *tokenFileRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.currentToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// requests must not be modified, so set the header on a copy
+	r := req.WithContext(req.Context())
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	return t.rt.RoundTrip(r)
+}
+
+// currentToken returns the token in the file, reading it again if it was last read
+// more than tokenRefreshPeriod ago. If it can't be read again, the last token is used.
+func (t *tokenFileRoundTripper) currentToken() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if t.token != "" && now.Sub(t.read) < tokenRefreshPeriod {
+		return t.token, nil
+	}
+
+	b, err := ioutil.ReadFile(t.path)
+	if err != nil {
+		if t.token != "" {
+			logger.WithError(err).WithField("path", t.path).Warn("Could not read the token file again, using the last token")
+			return t.token, nil
+		}
+		return "", errors.Wrapf(err, "could not read token file %s", t.path)
+	}
+	t.token = strings.TrimSpace(string(b))
+	t.read = now
+	return t.token, nil
+}
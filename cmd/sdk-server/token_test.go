@@ -0,0 +1,94 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTokenFileRoundTripper(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "token")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	path := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(path, []byte("first\n"), 0600))
+
+	var auth string
+	now := time.Now()
+	rt := &tokenFileRoundTripper{path: path, now: func() time.Time { return now },
+		rt: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			auth = req.Header.Get("Authorization")
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		})}
+
+	send := func() {
+		req, err := http.NewRequest(http.MethodGet, "https://kubernetes.default", nil)
+		require.NoError(t, err)
+		_, err = rt.RoundTrip(req)
+		require.NoError(t, err)
+		assert.Empty(t, req.Header.Get("Authorization"), "the request should not be modified")
+	}
+
+	send()
+	assert.Equal(t, "Bearer first", auth)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("second"), 0600))
+	send()
+	assert.Equal(t, "Bearer first", auth, "the token should not be read again within the refresh period")
+
+	now = now.Add(tokenRefreshPeriod)
+	send()
+	assert.Equal(t, "Bearer second", auth)
+
+	require.NoError(t, os.Remove(path))
+	now = now.Add(tokenRefreshPeriod)
+	send()
+	assert.Equal(t, "Bearer second", auth, "the last token should be used if the file can't be read")
+
+	rt = &tokenFileRoundTripper{path: path, now: time.Now, rt: rt.rt}
+	req, err := http.NewRequest(http.MethodGet, "https://kubernetes.default", nil)
+	require.NoError(t, err)
+	_, err = rt.RoundTrip(req)
+	assert.Error(t, err)
+}
+
+func TestWithTokenFile(t *testing.T) {
+	t.Parallel()
+
+	config := &rest.Config{BearerToken: "secret"}
+	withTokenFile(config, "/var/run/secrets/agones.dev/sidecar/token")
+	assert.Empty(t, config.BearerToken)
+	rt, ok := config.WrapTransport(http.DefaultTransport).(*tokenFileRoundTripper)
+	if assert.True(t, ok) {
+		assert.Equal(t, "/var/run/secrets/agones.dev/sidecar/token", rt.path)
+	}
+}
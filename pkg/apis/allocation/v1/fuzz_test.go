@@ -0,0 +1,65 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"agones.dev/agones/pkg/testing/fuzzer"
+	"github.com/mattbaird/jsonpatch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/diff"
+)
+
+const fuzzIterations = 100
+
+func TestFuzzGameServerAllocation(t *testing.T) {
+	t.Parallel()
+	seed := fuzzer.Seed()
+	f := fuzzer.New(seed)
+
+	for i := 0; i < fuzzIterations; i++ {
+		gsa := &GameServerAllocation{}
+		f.Fuzz(gsa)
+
+		// round trip
+		original, err := json.Marshal(gsa)
+		require.NoError(t, err, "seed %d", seed)
+		result := &GameServerAllocation{}
+		require.NoError(t, json.Unmarshal(original, result), "seed %d", seed)
+		require.True(t, equality.Semantic.DeepEqual(gsa, result), "JSON round trip is not equal, seed %d: %s", seed, diff.ObjectReflectDiff(gsa, result))
+
+		// defaulting is idempotent, and doesn't panic on validation
+		require.NotPanics(t, gsa.ApplyDefaults, "seed %d", seed)
+		once := gsa.DeepCopy()
+		gsa.ApplyDefaults()
+		require.True(t, equality.Semantic.DeepEqual(once, gsa), "ApplyDefaults is not idempotent, seed %d", seed)
+		require.NotPanics(t, func() { gsa.Validate() }, "seed %d", seed) // nolint: errcheck
+
+		// a patch of the defaults applies cleanly to the original
+		defaulted, err := json.Marshal(gsa)
+		require.NoError(t, err, "seed %d", seed)
+		patch, err := jsonpatch.CreatePatch(original, defaulted)
+		require.NoError(t, err, "seed %d", seed)
+		patched, err := fuzzer.ApplyJSONPatch(original, patch)
+		require.NoError(t, err, "seed %d", seed)
+		result = &GameServerAllocation{}
+		require.NoError(t, json.Unmarshal(patched, result), "seed %d", seed)
+		assert.True(t, equality.Semantic.DeepEqual(gsa, result), "patched object is not the defaulted object, seed %d: %s", seed, diff.ObjectReflectDiff(gsa, result))
+	}
+}
@@ -16,12 +16,16 @@ package v1
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"agones.dev/agones/pkg/apis"
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 const (
@@ -32,8 +36,28 @@ const (
 	// GameServerAllocationContention when the allocation is unsuccessful
 	// because of contention
 	GameServerAllocationContention GameServerAllocationState = "Contention"
+
+	// totalAnnotationSizeLimit is the maximum total size of the keys and values of an object's annotations,
+	// as enforced by the Kubernetes API server
+	totalAnnotationSizeLimit = 256 * 1024
+
+	// PriorityTypeCounter sorts GameServers by the available capacity of a Counter
+	PriorityTypeCounter PriorityType = "Counter"
+	// PriorityTypeList sorts GameServers by the available capacity of a List
+	PriorityTypeList PriorityType = "List"
+
+	// PriorityOrderAscending prefers GameServers with the least available capacity
+	PriorityOrderAscending PriorityOrder = "Ascending"
+	// PriorityOrderDescending prefers GameServers with the most available capacity
+	PriorityOrderDescending PriorityOrder = "Descending"
 )
 
+// PriorityType is whether a Priority refers to a Counter or a List
+type PriorityType string
+
+// PriorityOrder is the sort order of a Priority
+type PriorityOrder string
+
 // GameServerAllocationState is the Allocation state
 type GameServerAllocationState string
 
@@ -72,7 +96,28 @@ type GameServerAllocationSpec struct {
 	// Preferred ordered list of preferred allocations out of the `required` set.
 	// If the first selector is not matched,
 	// the selection attempts the second selector, and so on.
-	Preferred []metav1.LabelSelector `json:"preferred,omitempty"`
+	// If any of the selectors has a weight, GameServers are instead scored by the sum of the
+	// weights of the selectors they match, and the highest scoring GameServer is chosen.
+	Preferred []PreferredSelector `json:"preferred,omitempty"`
+
+	// Allocated if specified, Allocated GameServers that match this selector (as well as `required`)
+	// are also available for allocation, which allows multiple game sessions to be packed into a
+	// single game server process. Matching Allocated GameServers are chosen ahead of Ready ones.
+	// Use labels applied through MetaPatch to limit how many sessions a GameServer receives.
+	Allocated *metav1.LabelSelector `json:"allocated,omitempty"`
+
+	// NodeSelector filters GameServers (as well as `required`) on the labels of the Node they are running on
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+
+	// Counters filters GameServers (as well as `required`) on the available capacity of their Counters
+	Counters map[string]CounterSelector `json:"counters,omitempty"`
+
+	// Lists filters GameServers (as well as `required`) on the values and available capacity of their Lists
+	Lists map[string]ListSelector `json:"lists,omitempty"`
+
+	// Priorities decide between GameServers that match equally well, in order, by the available capacity
+	// of their Counters or Lists. If not set, the first GameServer found is chosen.
+	Priorities []Priority `json:"priorities,omitempty"`
 
 	// Scheduling strategy. Defaults to "Packed".
 	Scheduling apis.SchedulingStrategy `json:"scheduling"`
@@ -80,6 +125,46 @@ type GameServerAllocationSpec struct {
 	// MetaPatch is optional custom metadata that is added to the game server at allocation
 	// You can use this to tell the server necessary session data
 	MetaPatch MetaPatch `json:"metadata,omitempty"`
+
+	// IncludeOwners if true, the names and labels of the Fleet and GameServerSet that own the allocated
+	// GameServer are included in the status, e.g. to record which rollout of a Fleet served a match.
+	IncludeOwners bool `json:"includeOwners,omitempty"`
+}
+
+// PreferredSelector is a label selector for preferred GameServers, with an optional weight
+type PreferredSelector struct {
+	metav1.LabelSelector `json:",inline"`
+	// Weight of this selector when scoring GameServers. Defaults to 0, which means
+	// the order of the preferred selectors is used instead, if no selector has a weight.
+	Weight int32 `json:"weight,omitempty"`
+}
+
+// CounterSelector matches GameServers by the available capacity (capacity - count) of a Counter
+type CounterSelector struct {
+	// MinAvailable is the minimum available capacity. Defaults to 0.
+	MinAvailable int64 `json:"minAvailable,omitempty"`
+	// MaxAvailable is the maximum available capacity. Defaults to 0, which means no maximum.
+	MaxAvailable int64 `json:"maxAvailable,omitempty"`
+}
+
+// ListSelector matches GameServers by the values and available capacity (capacity - number of values) of a List
+type ListSelector struct {
+	// ContainsValue if set, the List must hold this value
+	ContainsValue string `json:"containsValue,omitempty"`
+	// MinAvailable is the minimum available capacity. Defaults to 0.
+	MinAvailable int64 `json:"minAvailable,omitempty"`
+	// MaxAvailable is the maximum available capacity. Defaults to 0, which means no maximum.
+	MaxAvailable int64 `json:"maxAvailable,omitempty"`
+}
+
+// Priority sorts GameServers by the available capacity of a Counter or List
+type Priority struct {
+	// Type is either "Counter" or "List"
+	Type PriorityType `json:"type"`
+	// Key is the name of the Counter or List
+	Key string `json:"key"`
+	// Order is either "Ascending" (default), which prefers the least available capacity, or "Descending"
+	Order PriorityOrder `json:"order,omitempty"`
 }
 
 // MultiClusterSetting specifies settings for multi-cluster allocation.
@@ -101,7 +186,7 @@ func (gsas *GameServerAllocationSpec) PreferredSelectors() ([]labels.Selector, e
 
 	var err error
 	for i, p := range gsas.Preferred {
-		list[i], err = metav1.LabelSelectorAsSelector(&p)
+		list[i], err = metav1.LabelSelectorAsSelector(&p.LabelSelector)
 		if err != nil {
 			break
 		}
@@ -110,6 +195,70 @@ func (gsas *GameServerAllocationSpec) PreferredSelectors() ([]labels.Selector, e
 	return list, errors.WithStack(err)
 }
 
+// IsPreferredWeighted returns true if any of the preferred selectors has a weight,
+// and therefore GameServers should be scored rather than matched in order
+func (gsas *GameServerAllocationSpec) IsPreferredWeighted() bool {
+	for _, p := range gsas.Preferred {
+		if p.Weight != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns true if the available capacity of the counter is within the selector's range
+func (cs CounterSelector) Matches(c v1alpha1.CounterStatus) bool {
+	return availableInRange(c.Available(), cs.MinAvailable, cs.MaxAvailable)
+}
+
+// Matches returns true if the list holds the selector's value (if set), and its
+// available capacity is within the selector's range
+func (ls ListSelector) Matches(l v1alpha1.ListStatus) bool {
+	if ls.ContainsValue != "" && !l.Contains(ls.ContainsValue) {
+		return false
+	}
+	return availableInRange(l.Available(), ls.MinAvailable, ls.MaxAvailable)
+}
+
+func availableInRange(available, min, max int64) bool {
+	if available < min {
+		return false
+	}
+	return max <= 0 || available <= max
+}
+
+// MatchesCountersAndLists returns true if the GameServer has all the Counters and Lists
+// in the spec, and they all match their selectors
+func (gsas *GameServerAllocationSpec) MatchesCountersAndLists(gs *v1alpha1.GameServer) bool {
+	for name, sel := range gsas.Counters {
+		c, ok := gs.Status.Counters[name]
+		if !ok || !sel.Matches(c) {
+			return false
+		}
+	}
+	for name, sel := range gsas.Lists {
+		l, ok := gs.Status.Lists[name]
+		if !ok || !sel.Matches(l) {
+			return false
+		}
+	}
+	return true
+}
+
+// Available returns the available capacity of the Counter or List the Priority refers to,
+// and false if the GameServer does not have it
+func (p Priority) Available(gs *v1alpha1.GameServer) (int64, bool) {
+	switch p.Type {
+	case PriorityTypeCounter:
+		c, ok := gs.Status.Counters[p.Key]
+		return c.Available(), ok
+	case PriorityTypeList:
+		l, ok := gs.Status.Lists[p.Key]
+		return l.Available(), ok
+	}
+	return 0, false
+}
+
 // GameServerAllocationStatus is the status for an GameServerAllocation resource
 type GameServerAllocationStatus struct {
 	// GameServerState is the current state of an GameServerAllocation, e.g. Allocated, or UnAllocated
@@ -118,6 +267,19 @@ type GameServerAllocationStatus struct {
 	Ports          []v1alpha1.GameServerStatusPort `json:"ports,omitempty"`
 	Address        string                          `json:"address,omitempty"`
 	NodeName       string                          `json:"nodeName,omitempty"`
+	// Addresses are all the addresses of the Node the allocated GameServer is running on
+	Addresses []corev1.NodeAddress `json:"addresses,omitempty"`
+	// Fleet is the Fleet that owns the allocated GameServer, if it is part of one, and the spec includes owners
+	Fleet *GameServerAllocationOwner `json:"fleet,omitempty"`
+	// GameServerSet is the GameServerSet that owns the allocated GameServer, if it is part of one,
+	// and the spec includes owners
+	GameServerSet *GameServerAllocationOwner `json:"gameServerSet,omitempty"`
+}
+
+// GameServerAllocationOwner is the name and labels of a Fleet or GameServerSet that owns an allocated GameServer
+type GameServerAllocationOwner struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // ApplyDefaults applies the default values to this GameServerAllocation
@@ -125,6 +287,12 @@ func (gsa *GameServerAllocation) ApplyDefaults() {
 	if gsa.Spec.Scheduling == "" {
 		gsa.Spec.Scheduling = apis.Packed
 	}
+
+	for i := range gsa.Spec.Priorities {
+		if gsa.Spec.Priorities[i].Order == "" {
+			gsa.Spec.Priorities[i].Order = PriorityOrderAscending
+		}
+	}
 }
 
 // Validate validation for the GameServerAllocation
@@ -143,5 +311,99 @@ func (gsa *GameServerAllocation) Validate() ([]metav1.StatusCause, bool) {
 			Message: fmt.Sprintf("Invalid value: %s, value must be either Packed or Distributed", gsa.Spec.Scheduling)})
 	}
 
+	for i, p := range gsa.Spec.Preferred {
+		if p.Weight < 0 {
+			causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+				Field:   fmt.Sprintf("spec.preferred[%d].weight", i),
+				Message: fmt.Sprintf("Invalid value: %d, value must not be negative", p.Weight)})
+		}
+	}
+
+	if gsa.Spec.NodeSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(gsa.Spec.NodeSelector); err != nil {
+			causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+				Field:   "spec.nodeSelector",
+				Message: fmt.Sprintf("Invalid value: %s", err)})
+		}
+	}
+
+	for name, c := range gsa.Spec.Counters {
+		if c.MaxAvailable > 0 && c.MaxAvailable < c.MinAvailable {
+			causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+				Field:   fmt.Sprintf("spec.counters.%s.maxAvailable", name),
+				Message: fmt.Sprintf("Invalid value: %d, value must not be less than minAvailable", c.MaxAvailable)})
+		}
+	}
+
+	for name, l := range gsa.Spec.Lists {
+		if l.MaxAvailable > 0 && l.MaxAvailable < l.MinAvailable {
+			causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+				Field:   fmt.Sprintf("spec.lists.%s.maxAvailable", name),
+				Message: fmt.Sprintf("Invalid value: %d, value must not be less than minAvailable", l.MaxAvailable)})
+		}
+	}
+
+	for i, p := range gsa.Spec.Priorities {
+		if p.Type != PriorityTypeCounter && p.Type != PriorityTypeList {
+			causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+				Field:   fmt.Sprintf("spec.priorities[%d].type", i),
+				Message: fmt.Sprintf("Invalid value: %s, value must be either Counter or List", p.Type)})
+		}
+		if p.Order != PriorityOrderAscending && p.Order != PriorityOrderDescending {
+			causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+				Field:   fmt.Sprintf("spec.priorities[%d].order", i),
+				Message: fmt.Sprintf("Invalid value: %s, value must be either Ascending or Descending", p.Order)})
+		}
+	}
+
+	causes = append(causes, gsa.Spec.MetaPatch.validate()...)
+
 	return causes, len(causes) == 0
 }
+
+// validate validates the labels and annotations of the MetaPatch the same way the Kubernetes
+// API server will, so that invalid metadata is rejected up front, rather than
+// failing the update of the GameServer once it has been allocated
+func (mp *MetaPatch) validate() []metav1.StatusCause {
+	var causes []metav1.StatusCause
+
+	for _, k := range sortedKeys(mp.Labels) {
+		for _, msg := range validation.IsQualifiedName(k) {
+			causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+				Field:   "spec.metadata.labels",
+				Message: fmt.Sprintf("Invalid key: %s, %s", k, msg)})
+		}
+		for _, msg := range validation.IsValidLabelValue(mp.Labels[k]) {
+			causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+				Field:   fmt.Sprintf("spec.metadata.labels.%s", k),
+				Message: fmt.Sprintf("Invalid value: %s, %s", mp.Labels[k], msg)})
+		}
+	}
+
+	size := 0
+	for _, k := range sortedKeys(mp.Annotations) {
+		for _, msg := range validation.IsQualifiedName(strings.ToLower(k)) {
+			causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+				Field:   "spec.metadata.annotations",
+				Message: fmt.Sprintf("Invalid key: %s, %s", k, msg)})
+		}
+		size += len(k) + len(mp.Annotations[k])
+	}
+	if size > totalAnnotationSizeLimit {
+		causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+			Field:   "spec.metadata.annotations",
+			Message: fmt.Sprintf("Invalid value: %d bytes, annotations must have at most %d bytes", size, totalAnnotationSizeLimit)})
+	}
+
+	return causes
+}
+
+// sortedKeys returns the keys of m in order, so that validation causes are reported in a stable order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
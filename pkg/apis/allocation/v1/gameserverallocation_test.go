@@ -15,6 +15,7 @@
 package v1
 
 import (
+	"strings"
 	"testing"
 
 	"agones.dev/agones/pkg/apis"
@@ -35,15 +36,23 @@ func TestGameServerAllocationApplyDefaults(t *testing.T) {
 	gsa = &GameServerAllocation{Spec: GameServerAllocationSpec{Scheduling: apis.Distributed}}
 	gsa.ApplyDefaults()
 	assert.Equal(t, apis.Distributed, gsa.Spec.Scheduling)
+
+	gsa = &GameServerAllocation{Spec: GameServerAllocationSpec{Priorities: []Priority{
+		{Type: PriorityTypeCounter, Key: "rooms"},
+		{Type: PriorityTypeList, Key: "players", Order: PriorityOrderDescending},
+	}}}
+	gsa.ApplyDefaults()
+	assert.Equal(t, PriorityOrderAscending, gsa.Spec.Priorities[0].Order)
+	assert.Equal(t, PriorityOrderDescending, gsa.Spec.Priorities[1].Order)
 }
 
 func TestGameServerAllocationSpecPreferredSelectors(t *testing.T) {
 	t.Parallel()
 
 	gsas := &GameServerAllocationSpec{
-		Preferred: []metav1.LabelSelector{
-			{MatchLabels: map[string]string{"check": "blue"}},
-			{MatchLabels: map[string]string{"check": "red"}},
+		Preferred: []PreferredSelector{
+			{LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"check": "blue"}}},
+			{LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"check": "red"}}},
 		},
 	}
 
@@ -84,4 +93,148 @@ func TestGameServerAllocationValidate(t *testing.T) {
 
 	assert.Equal(t, metav1.CauseTypeFieldValueInvalid, causes[0].Type)
 	assert.Equal(t, "spec.scheduling", causes[0].Field)
+
+	gsa = &GameServerAllocation{Spec: GameServerAllocationSpec{Preferred: []PreferredSelector{{Weight: 10}, {Weight: -1}}}}
+	gsa.ApplyDefaults()
+	causes, ok = gsa.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "spec.preferred[1].weight", causes[0].Field)
+
+	gsa = &GameServerAllocation{Spec: GameServerAllocationSpec{
+		Counters:   map[string]CounterSelector{"rooms": {MinAvailable: 5, MaxAvailable: 2}},
+		Lists:      map[string]ListSelector{"players": {MinAvailable: 3, MaxAvailable: 1}},
+		Priorities: []Priority{{Type: "FLERG", Key: "rooms", Order: "FLERG"}},
+	}}
+	gsa.ApplyDefaults()
+	causes, ok = gsa.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 4)
+	fields := []string{}
+	for _, c := range causes {
+		fields = append(fields, c.Field)
+	}
+	assert.ElementsMatch(t, []string{"spec.counters.rooms.maxAvailable", "spec.lists.players.maxAvailable",
+		"spec.priorities[0].type", "spec.priorities[0].order"}, fields)
+
+	gsa = &GameServerAllocation{Spec: GameServerAllocationSpec{MetaPatch: MetaPatch{
+		Labels:      map[string]string{"mode": "deathmatch", strings.Repeat("a", 64): "true", "map": strings.Repeat("b", 64)},
+		Annotations: map[string]string{"agones.dev/Owner": "user", "bad key": "value"},
+	}}}
+	gsa.ApplyDefaults()
+	causes, ok = gsa.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 3)
+	fields = []string{}
+	for _, c := range causes {
+		fields = append(fields, c.Field)
+	}
+	assert.ElementsMatch(t, []string{"spec.metadata.labels", "spec.metadata.labels.map", "spec.metadata.annotations"}, fields)
+
+	gsa = &GameServerAllocation{Spec: GameServerAllocationSpec{MetaPatch: MetaPatch{
+		Annotations: map[string]string{"payload": strings.Repeat("c", totalAnnotationSizeLimit)},
+	}}}
+	gsa.ApplyDefaults()
+	causes, ok = gsa.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "spec.metadata.annotations", causes[0].Field)
+	gsa = &GameServerAllocation{Spec: GameServerAllocationSpec{NodeSelector: &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "zone", Operator: "FLERG"}},
+	}}}
+	gsa.ApplyDefaults()
+	causes, ok = gsa.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "spec.nodeSelector", causes[0].Field)
+}
+
+func TestGameServerAllocationSpecIsPreferredWeighted(t *testing.T) {
+	t.Parallel()
+
+	gsas := &GameServerAllocationSpec{}
+	assert.False(t, gsas.IsPreferredWeighted())
+
+	gsas.Preferred = []PreferredSelector{{}, {}}
+	assert.False(t, gsas.IsPreferredWeighted())
+
+	gsas.Preferred[1].Weight = 5
+	assert.True(t, gsas.IsPreferredWeighted())
+}
+
+func TestGameServerAllocationSpecMatchesCountersAndLists(t *testing.T) {
+	t.Parallel()
+
+	gs := &v1alpha1.GameServer{Status: v1alpha1.GameServerStatus{
+		Counters: map[string]v1alpha1.CounterStatus{"rooms": {Count: 3, Capacity: 10}},
+		Lists:    map[string]v1alpha1.ListStatus{"players": {Capacity: 4, Values: []string{"a", "b"}}},
+	}}
+
+	fixtures := map[string]struct {
+		spec     GameServerAllocationSpec
+		expected bool
+	}{
+		"empty": {spec: GameServerAllocationSpec{}, expected: true},
+		"counter in range": {
+			spec:     GameServerAllocationSpec{Counters: map[string]CounterSelector{"rooms": {MinAvailable: 7, MaxAvailable: 7}}},
+			expected: true,
+		},
+		"counter under min": {
+			spec:     GameServerAllocationSpec{Counters: map[string]CounterSelector{"rooms": {MinAvailable: 8}}},
+			expected: false,
+		},
+		"counter over max": {
+			spec:     GameServerAllocationSpec{Counters: map[string]CounterSelector{"rooms": {MaxAvailable: 6}}},
+			expected: false,
+		},
+		"missing counter": {
+			spec:     GameServerAllocationSpec{Counters: map[string]CounterSelector{"sessions": {}}},
+			expected: false,
+		},
+		"list contains": {
+			spec:     GameServerAllocationSpec{Lists: map[string]ListSelector{"players": {ContainsValue: "b", MinAvailable: 2}}},
+			expected: true,
+		},
+		"list does not contain": {
+			spec:     GameServerAllocationSpec{Lists: map[string]ListSelector{"players": {ContainsValue: "c"}}},
+			expected: false,
+		},
+		"list under min": {
+			spec:     GameServerAllocationSpec{Lists: map[string]ListSelector{"players": {MinAvailable: 3}}},
+			expected: false,
+		},
+		"missing list": {
+			spec:     GameServerAllocationSpec{Lists: map[string]ListSelector{"teams": {}}},
+			expected: false,
+		},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, v.expected, v.spec.MatchesCountersAndLists(gs))
+		})
+	}
+}
+
+func TestPriorityAvailable(t *testing.T) {
+	t.Parallel()
+
+	gs := &v1alpha1.GameServer{Status: v1alpha1.GameServerStatus{
+		Counters: map[string]v1alpha1.CounterStatus{"rooms": {Count: 3, Capacity: 10}},
+		Lists:    map[string]v1alpha1.ListStatus{"players": {Capacity: 4, Values: []string{"a"}}},
+	}}
+
+	available, ok := Priority{Type: PriorityTypeCounter, Key: "rooms"}.Available(gs)
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), available)
+
+	available, ok = Priority{Type: PriorityTypeList, Key: "players"}.Available(gs)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), available)
+
+	_, ok = Priority{Type: PriorityTypeCounter, Key: "players"}.Available(gs)
+	assert.False(t, ok)
+
+	_, ok = Priority{Type: "FLERG", Key: "rooms"}.Available(gs)
+	assert.False(t, ok)
 }
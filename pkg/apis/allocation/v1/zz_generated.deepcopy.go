@@ -22,10 +22,27 @@ package v1
 
 import (
 	v1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
+	core_v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CounterSelector) DeepCopyInto(out *CounterSelector) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CounterSelector.
+func (in *CounterSelector) DeepCopy() *CounterSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(CounterSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GameServerAllocation) DeepCopyInto(out *GameServerAllocation) {
 	*out = *in
@@ -87,6 +104,29 @@ func (in *GameServerAllocationList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerAllocationOwner) DeepCopyInto(out *GameServerAllocationOwner) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerAllocationOwner.
+func (in *GameServerAllocationOwner) DeepCopy() *GameServerAllocationOwner {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerAllocationOwner)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GameServerAllocationSpec) DeepCopyInto(out *GameServerAllocationSpec) {
 	*out = *in
@@ -94,11 +134,48 @@ func (in *GameServerAllocationSpec) DeepCopyInto(out *GameServerAllocationSpec)
 	in.Required.DeepCopyInto(&out.Required)
 	if in.Preferred != nil {
 		in, out := &in.Preferred, &out.Preferred
-		*out = make([]meta_v1.LabelSelector, len(*in))
+		*out = make([]PreferredSelector, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Allocated != nil {
+		in, out := &in.Allocated, &out.Allocated
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(meta_v1.LabelSelector)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(meta_v1.LabelSelector)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.Counters != nil {
+		in, out := &in.Counters, &out.Counters
+		*out = make(map[string]CounterSelector, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Lists != nil {
+		in, out := &in.Lists, &out.Lists
+		*out = make(map[string]ListSelector, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Priorities != nil {
+		in, out := &in.Priorities, &out.Priorities
+		*out = make([]Priority, len(*in))
+		copy(*out, *in)
+	}
 	in.MetaPatch.DeepCopyInto(&out.MetaPatch)
 	return
 }
@@ -121,6 +198,21 @@ func (in *GameServerAllocationStatus) DeepCopyInto(out *GameServerAllocationStat
 		*out = make([]v1alpha1.GameServerStatusPort, len(*in))
 		copy(*out, *in)
 	}
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]core_v1.NodeAddress, len(*in))
+		copy(*out, *in)
+	}
+	if in.Fleet != nil {
+		in, out := &in.Fleet, &out.Fleet
+		*out = new(GameServerAllocationOwner)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GameServerSet != nil {
+		in, out := &in.GameServerSet, &out.GameServerSet
+		*out = new(GameServerAllocationOwner)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -134,6 +226,22 @@ func (in *GameServerAllocationStatus) DeepCopy() *GameServerAllocationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListSelector) DeepCopyInto(out *ListSelector) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListSelector.
+func (in *ListSelector) DeepCopy() *ListSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ListSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetaPatch) DeepCopyInto(out *MetaPatch) {
 	*out = *in
@@ -180,3 +288,36 @@ func (in *MultiClusterSetting) DeepCopy() *MultiClusterSetting {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreferredSelector) DeepCopyInto(out *PreferredSelector) {
+	*out = *in
+	in.LabelSelector.DeepCopyInto(&out.LabelSelector)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreferredSelector.
+func (in *PreferredSelector) DeepCopy() *PreferredSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(PreferredSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Priority) DeepCopyInto(out *Priority) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Priority.
+func (in *Priority) DeepCopy() *Priority {
+	if in == nil {
+		return nil
+	}
+	out := new(Priority)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -16,10 +16,13 @@ package v1
 
 import (
 	"crypto/x509"
+	"fmt"
 	"net/url"
+	"time"
 
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
 	admregv1b "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -53,6 +56,31 @@ type FleetAutoscalerSpec struct {
 
 	// Autoscaling policy
 	Policy FleetAutoscalerPolicy `json:"policy"`
+
+	// SyncIntervalSeconds is how often, in seconds, the size of the Fleet is computed with the policy,
+	// and the Fleet scaled to it. Defaults to 30, and can be at most 3600.
+	// +optional
+	SyncIntervalSeconds int32 `json:"syncIntervalSeconds,omitempty"`
+
+	// ScaleDownStabilizationWindowSeconds is how long, in seconds, the sizes that the policy computed
+	// are looked back over before the Fleet is scaled down: it is only scaled down to the largest of them,
+	// so that a brief drop in demand doesn't scale it down and back up again.
+	// Defaults to 0, which scales the Fleet down straight away, and can be at most 3600.
+	// +optional
+	ScaleDownStabilizationWindowSeconds int32 `json:"scaleDownStabilizationWindowSeconds,omitempty"`
+}
+
+// SyncInterval returns how often the Fleet is scaled
+func (s *FleetAutoscalerSpec) SyncInterval() time.Duration {
+	if s.SyncIntervalSeconds <= 0 {
+		return DefaultSyncIntervalSeconds * time.Second
+	}
+	return time.Duration(s.SyncIntervalSeconds) * time.Second
+}
+
+// ScaleDownStabilizationWindow returns how long the sizes of the Fleet are looked back over before it is scaled down
+func (s *FleetAutoscalerSpec) ScaleDownStabilizationWindow() time.Duration {
+	return time.Duration(s.ScaleDownStabilizationWindowSeconds) * time.Second
 }
 
 // FleetAutoscalerPolicy describes how to scale a fleet
@@ -66,6 +94,18 @@ type FleetAutoscalerPolicy struct {
 	// Webhook policy config params. Present only if FleetAutoscalerPolicyType = Webhook.
 	// +optional
 	Webhook *WebhookPolicy `json:"webhook,omitempty"`
+	// AllocationFailure policy config params. Present only if FleetAutoscalerPolicyType = AllocationFailure.
+	// +optional
+	AllocationFailure *AllocationFailurePolicy `json:"allocationFailure,omitempty"`
+	// Chain policy config params. Present only if FleetAutoscalerPolicyType = Chain.
+	// +optional
+	Chain *ChainPolicy `json:"chain,omitempty"`
+	// Counter policy config params. Present only if FleetAutoscalerPolicyType = Counter.
+	// +optional
+	Counter *CounterPolicy `json:"counter,omitempty"`
+	// List policy config params. Present only if FleetAutoscalerPolicyType = List.
+	// +optional
+	List *ListPolicy `json:"list,omitempty"`
 }
 
 // FleetAutoscalerPolicyType is the policy for autoscaling
@@ -79,8 +119,49 @@ const (
 	// WebhookPolicyType is a simple webhook strategy used for horizontal fleet scaling
 	// GameServers
 	WebhookPolicyType FleetAutoscalerPolicyType = "Webhook"
+	// AllocationFailurePolicyType is a buffering strategy, whose buffer grows with the allocations
+	// from the Fleet that have recently failed because it had no Ready GameServers
+	AllocationFailurePolicyType FleetAutoscalerPolicyType = "AllocationFailure"
+	// ChainPolicyType evaluates an ordered list of policies, and scales the Fleet with the
+	// first of them that can compute its size, or the largest size of all of them
+	ChainPolicyType FleetAutoscalerPolicyType = "Chain"
+	// CounterPolicyType is a buffering strategy for the available capacity of a counter
+	// of the Fleet's GameServers, rather than for Ready GameServers
+	CounterPolicyType FleetAutoscalerPolicyType = "Counter"
+	// ListPolicyType is a buffering strategy for the available capacity of a list
+	// of the Fleet's GameServers, rather than for Ready GameServers
+	ListPolicyType FleetAutoscalerPolicyType = "List"
+
+	// DefaultAllocationFailureWindowSeconds is how long allocation failures are scaled up for by default
+	DefaultAllocationFailureWindowSeconds = 60
+	// MaxAllocationFailureWindowSeconds is the longest that allocation failures can be scaled up for
+	MaxAllocationFailureWindowSeconds = 600
+
+	// DefaultSyncIntervalSeconds is how often a Fleet is scaled by default
+	DefaultSyncIntervalSeconds = 30
+	// MaxSyncIntervalSeconds is the longest that a Fleet can go without being scaled
+	MaxSyncIntervalSeconds = 3600
+	// MaxScaleDownStabilizationWindowSeconds is the longest that the sizes of a Fleet can be looked back over
+	// before it is scaled down
+	MaxScaleDownStabilizationWindowSeconds = 3600
+)
+
+// ChainSelection is how a ChainPolicy chooses which of its policies to scale the Fleet with
+type ChainSelection string
+
+const (
+	// ChainSelectFirst scales the Fleet with the first policy of the chain that computes its size without an error,
+	// so later policies are fallbacks for earlier ones
+	ChainSelectFirst ChainSelection = "First"
+	// ChainSelectMax scales the Fleet with the largest size that any of the policies of the chain computes
+	ChainSelectMax ChainSelection = "Max"
 )
 
+// WebhookSignatureHeader is the header that the signature of a webhook policy request body is sent in,
+// when the WebhookPolicy has a SigningSecret. Its value is `sha256=` followed by the hex encoded
+// HMAC-SHA256 of the request body.
+const WebhookSignatureHeader = "X-Agones-Signature"
+
 // BufferPolicy controls the desired behavior of the buffer policy.
 type BufferPolicy struct {
 	// MaxReplicas is the maximum amount of replicas that the fleet may have.
@@ -105,10 +186,111 @@ type BufferPolicy struct {
 	BufferSize intstr.IntOrString `json:"bufferSize"`
 }
 
+// AllocationFailurePolicy controls the desired behavior of the allocation failure policy.
+// The Fleet is scaled with its Buffer, which grows by ReplicasPerFailure replicas for each
+// allocation from the Fleet that failed, as it had no Ready GameServers, within the last WindowSeconds.
+type AllocationFailurePolicy struct {
+	// Buffer scales the Fleet while allocations from it are not failing
+	Buffer BufferPolicy `json:"buffer"`
+
+	// WindowSeconds is how long, in seconds, the Fleet is scaled up for after an allocation from it fails.
+	// Defaults to 60, and can be at most 600.
+	// +optional
+	WindowSeconds int32 `json:"windowSeconds,omitempty"`
+
+	// ReplicasPerFailure is how many replicas the buffer grows by for each failed allocation.
+	// Defaults to 1.
+	// +optional
+	ReplicasPerFailure int32 `json:"replicasPerFailure,omitempty"`
+}
+
+// Window returns how long allocation failures are taken into account for
+func (a *AllocationFailurePolicy) Window() time.Duration {
+	return time.Duration(a.WindowSeconds) * time.Second
+}
+
+// ChainPolicy controls the desired behavior of the chain policy.
+// Its policies are evaluated in order, and the Fleet is scaled with one of their results,
+// depending on the Selection.
+type ChainPolicy struct {
+	// Policies are the policies of the chain, in the order they are evaluated.
+	// They can not be Chain policies themselves.
+	Policies []FleetAutoscalerPolicy `json:"policies"`
+
+	// Selection is how the result of the chain is chosen, either First or Max. Defaults to First.
+	// +optional
+	Selection ChainSelection `json:"selection,omitempty"`
+}
+
+// CounterPolicy controls the desired behavior of the counter policy.
+// The Fleet is scaled so that the GameServers that are not Allocated add enough available capacity
+// of the counter, to the available capacity of the Allocated ones, to keep a buffer of it,
+// assuming each of them has the available capacity that the counter has in the Fleet's template.
+type CounterPolicy struct {
+	// Key is the name of the counter, which must be in the counters of the Fleet's template
+	Key string `json:"key"`
+
+	// MaxReplicas is the maximum amount of replicas that the fleet may have.
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// MinReplicas is the minimum amount of replicas that the fleet must have.
+	// If zero, it is ignored.
+	MinReplicas int32 `json:"minReplicas"`
+
+	// BufferSize is how much available capacity of the counter the autoscaler tries to keep across the Fleet.
+	// Value can be an absolute amount (ex: 100) or a percentage of the total capacity of the counter (ex: 20%).
+	BufferSize intstr.IntOrString `json:"bufferSize"`
+}
+
+// ListPolicy controls the desired behavior of the list policy.
+// It scales the Fleet for the available capacity of a list, just as a CounterPolicy does for a counter.
+type ListPolicy struct {
+	// Key is the name of the list, which must be in the lists of the Fleet's template
+	Key string `json:"key"`
+
+	// MaxReplicas is the maximum amount of replicas that the fleet may have.
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// MinReplicas is the minimum amount of replicas that the fleet must have.
+	// If zero, it is ignored.
+	MinReplicas int32 `json:"minReplicas"`
+
+	// BufferSize is how much available capacity of the list the autoscaler tries to keep across the Fleet.
+	// Value can be an absolute amount (ex: 100) or a percentage of the total capacity of the list (ex: 20%).
+	BufferSize intstr.IntOrString `json:"bufferSize"`
+}
+
 // WebhookPolicy controls the desired behavior of the webhook policy.
 // It contains the description of the webhook autoscaler service
 // used to form url which is accessible inside the cluster
-type WebhookPolicy admregv1b.WebhookClientConfig
+type WebhookPolicy struct {
+	// URL gives the location of the webhook, in standard URL form
+	// (`[scheme://]host:port/path`). Exactly one of `url` or `service`
+	// must be specified.
+	// +optional
+	URL *string `json:"url,omitempty"`
+
+	// Service is a reference to the service for this webhook. Either
+	// `service` or `url` must be specified.
+	// +optional
+	Service *admregv1b.ServiceReference `json:"service"`
+
+	// CABundle is a PEM encoded CA bundle which will be used to validate
+	// the webhook's server certificate. Required if the webhook uses HTTPS.
+	CABundle []byte `json:"caBundle"`
+
+	// ClientCertSecret is the name of a kubernetes.io/tls Secret in the FleetAutoscaler's namespace,
+	// whose certificate and key are presented to the webhook as a client certificate.
+	// Can only be used with HTTPS webhooks.
+	// +optional
+	ClientCertSecret string `json:"clientCertSecret,omitempty"`
+
+	// SigningSecret selects a key of a Secret in the FleetAutoscaler's namespace.
+	// If set, the body of each request is signed with HMAC-SHA256 using the key's value,
+	// and the signature is sent in the WebhookSignatureHeader header.
+	// +optional
+	SigningSecret *corev1.SecretKeySelector `json:"signingSecret,omitempty"`
+}
 
 // FleetAutoscalerStatus defines the current status of a FleetAutoscaler
 type FleetAutoscalerStatus struct {
@@ -165,14 +347,247 @@ type FleetAutoscaleReview struct {
 	Response *FleetAutoscaleResponse `json:"response"`
 }
 
+// ApplyDefaults applies default values to the FleetAutoscaler
+func (fas *FleetAutoscaler) ApplyDefaults() {
+	if fas.Spec.SyncIntervalSeconds == 0 {
+		fas.Spec.SyncIntervalSeconds = DefaultSyncIntervalSeconds
+	}
+	applyPolicyDefaults(&fas.Spec.Policy)
+}
+
+// applyPolicyDefaults applies default values to a policy, and each of the policies of a chain
+func applyPolicyDefaults(p *FleetAutoscalerPolicy) {
+	// the policy type can be inferred, if only one of the policies is set
+	if p.Type == "" {
+		var set []FleetAutoscalerPolicyType
+		if p.Buffer != nil {
+			set = append(set, BufferPolicyType)
+		}
+		if p.Webhook != nil {
+			set = append(set, WebhookPolicyType)
+		}
+		if p.AllocationFailure != nil {
+			set = append(set, AllocationFailurePolicyType)
+		}
+		if p.Chain != nil {
+			set = append(set, ChainPolicyType)
+		}
+		if p.Counter != nil {
+			set = append(set, CounterPolicyType)
+		}
+		if p.List != nil {
+			set = append(set, ListPolicyType)
+		}
+		if len(set) == 1 {
+			p.Type = set[0]
+		}
+	}
+
+	if a := p.AllocationFailure; a != nil {
+		if a.WindowSeconds == 0 {
+			a.WindowSeconds = DefaultAllocationFailureWindowSeconds
+		}
+		if a.ReplicasPerFailure == 0 {
+			a.ReplicasPerFailure = 1
+		}
+	}
+	if c := p.Chain; c != nil {
+		if c.Selection == "" {
+			c.Selection = ChainSelectFirst
+		}
+		for i := range c.Policies {
+			applyPolicyDefaults(&c.Policies[i])
+		}
+	}
+}
+
 // Validate validates the FleetAutoscaler scaling settings
 func (fas *FleetAutoscaler) Validate(causes []metav1.StatusCause) []metav1.StatusCause {
-	switch fas.Spec.Policy.Type {
+	if fas.Spec.SyncIntervalSeconds < 0 || fas.Spec.SyncIntervalSeconds > MaxSyncIntervalSeconds {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "syncIntervalSeconds",
+			Message: fmt.Sprintf("syncIntervalSeconds must be between 1 and %d", MaxSyncIntervalSeconds),
+		})
+	}
+	if fas.Spec.ScaleDownStabilizationWindowSeconds < 0 || fas.Spec.ScaleDownStabilizationWindowSeconds > MaxScaleDownStabilizationWindowSeconds {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "scaleDownStabilizationWindowSeconds",
+			Message: fmt.Sprintf("scaleDownStabilizationWindowSeconds must be between 0 and %d", MaxScaleDownStabilizationWindowSeconds),
+		})
+	}
+	return fas.Spec.Policy.validatePolicy(causes)
+}
+
+// validatePolicy validates the settings of the policy for its type
+func (p *FleetAutoscalerPolicy) validatePolicy(causes []metav1.StatusCause) []metav1.StatusCause {
+	switch p.Type {
 	case BufferPolicyType:
-		causes = fas.Spec.Policy.Buffer.ValidateBufferPolicy(causes)
+		causes = p.Buffer.ValidateBufferPolicy(causes)
 
 	case WebhookPolicyType:
-		causes = fas.Spec.Policy.Webhook.ValidateWebhookPolicy(causes)
+		causes = p.Webhook.ValidateWebhookPolicy(causes)
+
+	case AllocationFailurePolicyType:
+		causes = p.AllocationFailure.ValidateAllocationFailurePolicy(causes)
+
+	case ChainPolicyType:
+		causes = p.Chain.ValidateChainPolicy(causes)
+
+	case CounterPolicyType:
+		causes = p.Counter.ValidateCounterPolicy(causes)
+
+	case ListPolicyType:
+		causes = p.List.ValidateListPolicy(causes)
+	}
+	return causes
+}
+
+// ValidateChainPolicy validates the FleetAutoscaler Chain policy settings, and each of its policies
+func (c *ChainPolicy) ValidateChainPolicy(causes []metav1.StatusCause) []metav1.StatusCause {
+	if c == nil {
+		return append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "chain",
+			Message: "Chain policy config params are missing",
+		})
+	}
+	if len(c.Policies) == 0 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueRequired,
+			Field:   "policies",
+			Message: "Chain policy must have at least one policy",
+		})
+	}
+	if c.Selection != ChainSelectFirst && c.Selection != ChainSelectMax {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "selection",
+			Message: fmt.Sprintf("selection must be %s or %s", ChainSelectFirst, ChainSelectMax),
+		})
+	}
+	for i := range c.Policies {
+		p := &c.Policies[i]
+		switch p.Type {
+		case BufferPolicyType, WebhookPolicyType, AllocationFailurePolicyType, CounterPolicyType, ListPolicyType:
+			causes = p.validatePolicy(causes)
+		case ChainPolicyType:
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   fmt.Sprintf("policies[%d].type", i),
+				Message: "Chain policies can not be nested",
+			})
+		default:
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   fmt.Sprintf("policies[%d].type", i),
+				Message: fmt.Sprintf("type must be one of %s, %s, %s, %s or %s", BufferPolicyType, WebhookPolicyType, AllocationFailurePolicyType, CounterPolicyType, ListPolicyType),
+			})
+		}
+	}
+	return causes
+}
+
+// ValidateCounterPolicy validates the FleetAutoscaler Counter policy settings
+func (c *CounterPolicy) ValidateCounterPolicy(causes []metav1.StatusCause) []metav1.StatusCause {
+	if c == nil {
+		return append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "counter",
+			Message: "Counter policy config params are missing",
+		})
+	}
+	return validateCapacityPolicy(c.Key, c.MinReplicas, c.MaxReplicas, c.BufferSize, causes)
+}
+
+// ValidateListPolicy validates the FleetAutoscaler List policy settings
+func (l *ListPolicy) ValidateListPolicy(causes []metav1.StatusCause) []metav1.StatusCause {
+	if l == nil {
+		return append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "list",
+			Message: "List policy config params are missing",
+		})
+	}
+	return validateCapacityPolicy(l.Key, l.MinReplicas, l.MaxReplicas, l.BufferSize, causes)
+}
+
+// validateCapacityPolicy validates the settings that Counter and List policies have in common
+func validateCapacityPolicy(key string, minReplicas, maxReplicas int32, bufferSize intstr.IntOrString, causes []metav1.StatusCause) []metav1.StatusCause {
+	if key == "" {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueRequired,
+			Field:   "key",
+			Message: "key must be set",
+		})
+	}
+	if maxReplicas < 1 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "maxReplicas",
+			Message: "maxReplicas must be bigger than 0",
+		})
+	}
+	if minReplicas > maxReplicas {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "minReplicas",
+			Message: "minReplicas is bigger than maxReplicas",
+		})
+	}
+	if bufferSize.Type == intstr.Int {
+		if bufferSize.IntValue() <= 0 {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   "bufferSize",
+				Message: "bufferSize must be bigger than 0",
+			})
+		}
+		return causes
+	}
+	r, err := intstr.GetValueFromIntOrPercent(&bufferSize, 100, true)
+	if err != nil || r < 1 || r > 99 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "bufferSize",
+			Message: "bufferSize does not have a valid percentage value (1%-99%)",
+		})
+	}
+	// a fleet without any capacity has no capacity to take a percentage of
+	if minReplicas < 1 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "minReplicas",
+			Message: "minReplicas should be above 0 when used with percentage value bufferSize",
+		})
+	}
+	return causes
+}
+
+// ValidateAllocationFailurePolicy validates the FleetAutoscaler AllocationFailure policy settings
+func (a *AllocationFailurePolicy) ValidateAllocationFailurePolicy(causes []metav1.StatusCause) []metav1.StatusCause {
+	if a == nil {
+		return append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "allocationFailure",
+			Message: "AllocationFailure policy config params are missing",
+		})
+	}
+	causes = a.Buffer.ValidateBufferPolicy(causes)
+	if a.WindowSeconds < 1 || a.WindowSeconds > MaxAllocationFailureWindowSeconds {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "windowSeconds",
+			Message: fmt.Sprintf("windowSeconds must be between 1 and %d", MaxAllocationFailureWindowSeconds),
+		})
+	}
+	if a.ReplicasPerFailure < 1 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "replicasPerFailure",
+			Message: "replicasPerFailure must be bigger than 0",
+		})
 	}
 	return causes
 }
@@ -227,9 +642,29 @@ func (w *WebhookPolicy) ValidateWebhookPolicy(causes []metav1.StatusCause) []met
 					Message: "CABundle should be provided if HTTPS webhook is used",
 				})
 			}
+		} else if w.ClientCertSecret != "" {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   "clientCertSecret",
+				Message: "clientCertSecret can only be used with an HTTPS webhook",
+			})
 		}
 
 	}
+	if w.Service != nil && w.CABundle == nil && w.ClientCertSecret != "" {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "clientCertSecret",
+			Message: "clientCertSecret can only be used with an HTTPS webhook, which requires a caBundle",
+		})
+	}
+	if w.SigningSecret != nil && (w.SigningSecret.Name == "" || w.SigningSecret.Key == "") {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "signingSecret",
+			Message: "signingSecret requires both a name and a key",
+		})
+	}
 	return causes
 }
 
@@ -16,13 +16,74 @@ package v1
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	admregv1b "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+func TestFleetAutoscalerApplyDefaults(t *testing.T) {
+	t.Parallel()
+
+	fas := defaultFixture()
+	fas.Spec.Policy.Type = ""
+	fas.ApplyDefaults()
+	assert.Equal(t, BufferPolicyType, fas.Spec.Policy.Type)
+	assert.Equal(t, int32(0), fas.Spec.Policy.Buffer.MinReplicas)
+
+	fas = webhookFixture()
+	fas.Spec.Policy.Type = ""
+	fas.ApplyDefaults()
+	assert.Equal(t, WebhookPolicyType, fas.Spec.Policy.Type)
+
+	// can't tell which policy is meant
+	fas = defaultFixture()
+	fas.Spec.Policy.Type = ""
+	fas.Spec.Policy.Webhook = webhookFixture().Spec.Policy.Webhook
+	fas.ApplyDefaults()
+	assert.Equal(t, FleetAutoscalerPolicyType(""), fas.Spec.Policy.Type)
+
+	// minReplicas is not defaulted for a percentage bufferSize, it has to be set
+	fas = defaultFixture()
+	fas.Spec.Policy.Buffer.BufferSize = intstr.FromString("20%")
+	fas.ApplyDefaults()
+	assert.Equal(t, int32(0), fas.Spec.Policy.Buffer.MinReplicas)
+	causes := fas.Validate(nil)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, "minReplicas", causes[0].Field)
+	}
+}
+
+func TestFleetAutoscalerSyncInterval(t *testing.T) {
+	t.Parallel()
+
+	fas := defaultFixture()
+	assert.Equal(t, DefaultSyncIntervalSeconds*time.Second, fas.Spec.SyncInterval())
+	assert.Equal(t, time.Duration(0), fas.Spec.ScaleDownStabilizationWindow())
+	fas.ApplyDefaults()
+	assert.Equal(t, int32(DefaultSyncIntervalSeconds), fas.Spec.SyncIntervalSeconds)
+	assert.Empty(t, fas.Validate(nil))
+
+	fas.Spec.SyncIntervalSeconds = 10
+	fas.Spec.ScaleDownStabilizationWindowSeconds = 300
+	fas.ApplyDefaults()
+	assert.Equal(t, 10*time.Second, fas.Spec.SyncInterval())
+	assert.Equal(t, 5*time.Minute, fas.Spec.ScaleDownStabilizationWindow())
+	assert.Empty(t, fas.Validate(nil))
+
+	fas.Spec.SyncIntervalSeconds = MaxSyncIntervalSeconds + 1
+	fas.Spec.ScaleDownStabilizationWindowSeconds = -1
+	causes := fas.Validate(nil)
+	var fields []string
+	for _, c := range causes {
+		fields = append(fields, c.Field)
+	}
+	assert.Equal(t, []string{"syncIntervalSeconds", "scaleDownStabilizationWindowSeconds"}, fields)
+}
+
 func TestFleetAutoscalerValidateUpdate(t *testing.T) {
 	t.Parallel()
 
@@ -113,6 +174,174 @@ func TestFleetAutoscalerValidateUpdate(t *testing.T) {
 		assert.Equal(t, "minReplicas", causes[0].Field)
 	})
 }
+
+func TestFleetAutoscalerAllocationFailure(t *testing.T) {
+	t.Parallel()
+
+	newFixture := func() *FleetAutoscaler {
+		fas := defaultFixture()
+		fas.Spec.Policy = FleetAutoscalerPolicy{AllocationFailure: &AllocationFailurePolicy{
+			Buffer: BufferPolicy{BufferSize: intstr.FromString("20%"), MinReplicas: 1, MaxReplicas: 10},
+		}}
+		return fas
+	}
+
+	fas := newFixture()
+	fas.ApplyDefaults()
+	assert.Equal(t, AllocationFailurePolicyType, fas.Spec.Policy.Type)
+	a := fas.Spec.Policy.AllocationFailure
+	assert.Equal(t, int32(1), a.Buffer.MinReplicas)
+	assert.Equal(t, int32(DefaultAllocationFailureWindowSeconds), a.WindowSeconds)
+	assert.Equal(t, time.Minute, a.Window())
+	assert.Equal(t, int32(1), a.ReplicasPerFailure)
+	assert.Empty(t, fas.Validate(nil))
+
+	fas = newFixture()
+	fas.Spec.Policy.AllocationFailure.WindowSeconds = 120
+	fas.Spec.Policy.AllocationFailure.ReplicasPerFailure = 3
+	fas.ApplyDefaults()
+	assert.Equal(t, int32(120), fas.Spec.Policy.AllocationFailure.WindowSeconds)
+	assert.Equal(t, int32(3), fas.Spec.Policy.AllocationFailure.ReplicasPerFailure)
+
+	fas = newFixture()
+	fas.Spec.Policy.Type = AllocationFailurePolicyType
+	fas.Spec.Policy.AllocationFailure.Buffer.MinReplicas = 20
+	fas.Spec.Policy.AllocationFailure.WindowSeconds = MaxAllocationFailureWindowSeconds + 1
+	fas.Spec.Policy.AllocationFailure.ReplicasPerFailure = -1
+	causes := fas.Validate(nil)
+	var fields []string
+	for _, c := range causes {
+		fields = append(fields, c.Field)
+	}
+	assert.Equal(t, []string{"minReplicas", "windowSeconds", "replicasPerFailure"}, fields)
+
+	fas.Spec.Policy.AllocationFailure = nil
+	causes = fas.Validate(nil)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, "allocationFailure", causes[0].Field)
+	}
+
+	// can't tell which policy is meant
+	fas = newFixture()
+	fas.Spec.Policy.Buffer = defaultFixture().Spec.Policy.Buffer
+	fas.ApplyDefaults()
+	assert.Equal(t, FleetAutoscalerPolicyType(""), fas.Spec.Policy.Type)
+}
+
+func TestFleetAutoscalerChain(t *testing.T) {
+	t.Parallel()
+
+	newFixture := func() *FleetAutoscaler {
+		fas := defaultFixture()
+		fas.Spec.Policy = FleetAutoscalerPolicy{Chain: &ChainPolicy{Policies: []FleetAutoscalerPolicy{
+			{Webhook: webhookFixture().Spec.Policy.Webhook},
+			{Buffer: &BufferPolicy{BufferSize: intstr.FromString("20%"), MinReplicas: 1, MaxReplicas: 10}},
+		}}}
+		return fas
+	}
+
+	fas := newFixture()
+	fas.ApplyDefaults()
+	assert.Equal(t, ChainPolicyType, fas.Spec.Policy.Type)
+	c := fas.Spec.Policy.Chain
+	assert.Equal(t, ChainSelectFirst, c.Selection)
+	assert.Equal(t, WebhookPolicyType, c.Policies[0].Type)
+	assert.Equal(t, BufferPolicyType, c.Policies[1].Type)
+	assert.Empty(t, fas.Validate(nil))
+
+	fas = newFixture()
+	fas.Spec.Policy.Chain.Selection = ChainSelectMax
+	fas.ApplyDefaults()
+	assert.Equal(t, ChainSelectMax, fas.Spec.Policy.Chain.Selection)
+
+	fas = newFixture()
+	fas.ApplyDefaults()
+	fas.Spec.Policy.Chain.Selection = "Min"
+	fas.Spec.Policy.Chain.Policies[1].Buffer.MinReplicas = 20
+	fas.Spec.Policy.Chain.Policies = append(fas.Spec.Policy.Chain.Policies,
+		FleetAutoscalerPolicy{Type: ChainPolicyType, Chain: &ChainPolicy{}}, FleetAutoscalerPolicy{Type: "Unknown"})
+	causes := fas.Validate(nil)
+	var fields []string
+	for _, c := range causes {
+		fields = append(fields, c.Field)
+	}
+	assert.Equal(t, []string{"selection", "minReplicas", "policies[2].type", "policies[3].type"}, fields)
+
+	fas.Spec.Policy.Chain = &ChainPolicy{Selection: ChainSelectFirst}
+	causes = fas.Validate(nil)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, "policies", causes[0].Field)
+	}
+
+	fas.Spec.Policy.Chain = nil
+	causes = fas.Validate(nil)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, "chain", causes[0].Field)
+	}
+
+	// the copy of the chain doesn't share its policies
+	fas = newFixture()
+	fasCopy := fas.DeepCopy()
+	fasCopy.Spec.Policy.Chain.Policies[1].Buffer.MaxReplicas = 20
+	assert.Equal(t, int32(10), fas.Spec.Policy.Chain.Policies[1].Buffer.MaxReplicas)
+}
+
+func TestFleetAutoscalerCounterAndList(t *testing.T) {
+	t.Parallel()
+
+	fas := defaultFixture()
+	fas.Spec.Policy = FleetAutoscalerPolicy{Counter: &CounterPolicy{Key: "rooms", BufferSize: intstr.FromString("20%"), MaxReplicas: 10}}
+	fas.ApplyDefaults()
+	assert.Equal(t, CounterPolicyType, fas.Spec.Policy.Type)
+	assert.Equal(t, int32(0), fas.Spec.Policy.Counter.MinReplicas)
+	causes := fas.Validate(nil)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, "minReplicas", causes[0].Field)
+	}
+	fas.Spec.Policy.Counter.MinReplicas = 1
+	assert.Empty(t, fas.Validate(nil))
+
+	fas.Spec.Policy.Counter = &CounterPolicy{BufferSize: intstr.FromInt(0), MinReplicas: 20, MaxReplicas: 10}
+	causes = fas.Validate(nil)
+	var fields []string
+	for _, c := range causes {
+		fields = append(fields, c.Field)
+	}
+	assert.Equal(t, []string{"key", "minReplicas", "bufferSize"}, fields)
+
+	fas.Spec.Policy.Counter = nil
+	causes = fas.Validate(nil)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, "counter", causes[0].Field)
+	}
+
+	fas = defaultFixture()
+	fas.Spec.Policy = FleetAutoscalerPolicy{List: &ListPolicy{Key: "players", BufferSize: intstr.FromInt(10), MaxReplicas: 10}}
+	fas.ApplyDefaults()
+	assert.Equal(t, ListPolicyType, fas.Spec.Policy.Type)
+	assert.Equal(t, int32(0), fas.Spec.Policy.List.MinReplicas)
+	assert.Empty(t, fas.Validate(nil))
+
+	fas.Spec.Policy.List.BufferSize = intstr.FromString("100%")
+	causes = fas.Validate(nil)
+	fields = nil
+	for _, c := range causes {
+		fields = append(fields, c.Field)
+	}
+	assert.Equal(t, []string{"bufferSize", "minReplicas"}, fields)
+
+	// they can be chained with other policies
+	fas = defaultFixture()
+	fas.Spec.Policy = FleetAutoscalerPolicy{Chain: &ChainPolicy{Policies: []FleetAutoscalerPolicy{
+		{Counter: &CounterPolicy{Key: "rooms", BufferSize: intstr.FromInt(10), MaxReplicas: 10}},
+		{List: &ListPolicy{Key: "players", BufferSize: intstr.FromInt(10), MaxReplicas: 10}},
+	}}}
+	fas.ApplyDefaults()
+	assert.Equal(t, CounterPolicyType, fas.Spec.Policy.Chain.Policies[0].Type)
+	assert.Equal(t, ListPolicyType, fas.Spec.Policy.Chain.Policies[1].Type)
+	assert.Empty(t, fas.Validate(nil))
+}
+
 func TestFleetAutoscalerWebhookValidateUpdate(t *testing.T) {
 	t.Parallel()
 
@@ -203,6 +432,47 @@ func TestFleetAutoscalerWebhookValidateUpdate(t *testing.T) {
 		assert.Equal(t, "url", causes[0].Field)
 	})
 
+	t.Run("client certificate secret", func(t *testing.T) {
+		fas := webhookFixture()
+		url := "https://good.example.com"
+		fas.Spec.Policy.Webhook.URL = &url
+		fas.Spec.Policy.Webhook.Service = nil
+		fas.Spec.Policy.Webhook.CABundle = []byte(goodCaBundle)
+		fas.Spec.Policy.Webhook.ClientCertSecret = "client-cert"
+
+		causes := fas.Validate(nil)
+		assert.Len(t, causes, 0)
+
+		url = "http://bad.example.com"
+		fas.Spec.Policy.Webhook.CABundle = nil
+		causes = fas.Validate(nil)
+		assert.Len(t, causes, 1)
+		assert.Equal(t, "clientCertSecret", causes[0].Field)
+
+		// a service is only called over HTTPS if there is a CABundle
+		fas = webhookFixture()
+		fas.Spec.Policy.Webhook.ClientCertSecret = "client-cert"
+		causes = fas.Validate(nil)
+		assert.Len(t, causes, 1)
+		assert.Equal(t, "clientCertSecret", causes[0].Field)
+
+		fas.Spec.Policy.Webhook.CABundle = []byte(goodCaBundle)
+		causes = fas.Validate(nil)
+		assert.Len(t, causes, 0)
+	})
+
+	t.Run("signing secret", func(t *testing.T) {
+		fas := webhookFixture()
+		fas.Spec.Policy.Webhook.SigningSecret = &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "signing"}, Key: "key"}
+
+		causes := fas.Validate(nil)
+		assert.Len(t, causes, 0)
+
+		fas.Spec.Policy.Webhook.SigningSecret.Key = ""
+		causes = fas.Validate(nil)
+		assert.Len(t, causes, 1)
+		assert.Equal(t, "signingSecret", causes[0].Field)
+	})
 }
 
 func defaultFixture() *FleetAutoscaler {
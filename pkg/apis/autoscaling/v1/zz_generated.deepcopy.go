@@ -22,9 +22,27 @@ package v1
 
 import (
 	v1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AllocationFailurePolicy) DeepCopyInto(out *AllocationFailurePolicy) {
+	*out = *in
+	out.Buffer = in.Buffer
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AllocationFailurePolicy.
+func (in *AllocationFailurePolicy) DeepCopy() *AllocationFailurePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(AllocationFailurePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BufferPolicy) DeepCopyInto(out *BufferPolicy) {
 	*out = *in
@@ -42,6 +60,46 @@ func (in *BufferPolicy) DeepCopy() *BufferPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChainPolicy) DeepCopyInto(out *ChainPolicy) {
+	*out = *in
+	if in.Policies != nil {
+		in, out := &in.Policies, &out.Policies
+		*out = make([]FleetAutoscalerPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChainPolicy.
+func (in *ChainPolicy) DeepCopy() *ChainPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ChainPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CounterPolicy) DeepCopyInto(out *CounterPolicy) {
+	*out = *in
+	out.BufferSize = in.BufferSize
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CounterPolicy.
+func (in *CounterPolicy) DeepCopy() *CounterPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CounterPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FleetAutoscaleRequest) DeepCopyInto(out *FleetAutoscaleRequest) {
 	*out = *in
@@ -191,6 +249,42 @@ func (in *FleetAutoscalerPolicy) DeepCopyInto(out *FleetAutoscalerPolicy) {
 			(*in).DeepCopyInto(*out)
 		}
 	}
+	if in.AllocationFailure != nil {
+		in, out := &in.AllocationFailure, &out.AllocationFailure
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(AllocationFailurePolicy)
+			**out = **in
+		}
+	}
+	if in.Chain != nil {
+		in, out := &in.Chain, &out.Chain
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ChainPolicy)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.Counter != nil {
+		in, out := &in.Counter, &out.Counter
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(CounterPolicy)
+			**out = **in
+		}
+	}
+	if in.List != nil {
+		in, out := &in.List, &out.List
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ListPolicy)
+			**out = **in
+		}
+	}
 	return
 }
 
@@ -245,6 +339,23 @@ func (in *FleetAutoscalerStatus) DeepCopy() *FleetAutoscalerStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListPolicy) DeepCopyInto(out *ListPolicy) {
+	*out = *in
+	out.BufferSize = in.BufferSize
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListPolicy.
+func (in *ListPolicy) DeepCopy() *ListPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ListPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WebhookPolicy) DeepCopyInto(out *WebhookPolicy) {
 	*out = *in
@@ -271,6 +382,11 @@ func (in *WebhookPolicy) DeepCopyInto(out *WebhookPolicy) {
 		*out = make([]byte, len(*in))
 		copy(*out, *in)
 	}
+	if in.SigningSecret != nil {
+		in, out := &in.SigningSecret, &out.SigningSecret
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
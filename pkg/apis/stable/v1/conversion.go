@@ -0,0 +1,120 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// Convert converts a GameServer, Fleet or GameServerSet, serialised as JSON in either
+// v1alpha1 or v1, to apiVersion, which must also be one of those two versions.
+// It is the conversion handler of these CRDs.
+func Convert(raw []byte, apiVersion string) (k8sruntime.Object, error) {
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(raw, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding object")
+	}
+
+	switch apiVersion {
+	case SchemeGroupVersion.String():
+		switch o := obj.(type) {
+		case *v1alpha1.GameServer:
+			return ConvertGameServerFromV1alpha1(o), nil
+		case *v1alpha1.Fleet:
+			return ConvertFleetFromV1alpha1(o), nil
+		case *v1alpha1.GameServerSet:
+			return ConvertGameServerSetFromV1alpha1(o), nil
+		case *GameServer, *Fleet, *GameServerSet:
+			return obj, nil
+		}
+	case v1alpha1.SchemeGroupVersion.String():
+		switch o := obj.(type) {
+		case *GameServer:
+			return ConvertGameServerToV1alpha1(o), nil
+		case *Fleet:
+			return ConvertFleetToV1alpha1(o), nil
+		case *GameServerSet:
+			return ConvertGameServerSetToV1alpha1(o), nil
+		case *v1alpha1.GameServer, *v1alpha1.Fleet, *v1alpha1.GameServerSet:
+			return obj, nil
+		}
+	}
+
+	return nil, errors.Errorf("cannot convert %s to %s", obj.GetObjectKind().GroupVersionKind(), apiVersion)
+}
+
+// ConvertGameServerFromV1alpha1 returns the v1 version of a v1alpha1 GameServer
+func ConvertGameServerFromV1alpha1(in *v1alpha1.GameServer) *GameServer {
+	return &GameServer{
+		TypeMeta:   metav1.TypeMeta{APIVersion: SchemeGroupVersion.String(), Kind: "GameServer"},
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+		Spec:       *in.Spec.DeepCopy(),
+		Status:     *in.Status.DeepCopy(),
+	}
+}
+
+// ConvertGameServerToV1alpha1 returns the v1alpha1 version of a v1 GameServer
+func ConvertGameServerToV1alpha1(in *GameServer) *v1alpha1.GameServer {
+	return &v1alpha1.GameServer{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: "GameServer"},
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+		Spec:       *in.Spec.DeepCopy(),
+		Status:     *in.Status.DeepCopy(),
+	}
+}
+
+// ConvertFleetFromV1alpha1 returns the v1 version of a v1alpha1 Fleet
+func ConvertFleetFromV1alpha1(in *v1alpha1.Fleet) *Fleet {
+	return &Fleet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: SchemeGroupVersion.String(), Kind: "Fleet"},
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+		Spec:       *in.Spec.DeepCopy(),
+		Status:     *in.Status.DeepCopy(),
+	}
+}
+
+// ConvertFleetToV1alpha1 returns the v1alpha1 version of a v1 Fleet
+func ConvertFleetToV1alpha1(in *Fleet) *v1alpha1.Fleet {
+	return &v1alpha1.Fleet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: "Fleet"},
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+		Spec:       *in.Spec.DeepCopy(),
+		Status:     *in.Status.DeepCopy(),
+	}
+}
+
+// ConvertGameServerSetFromV1alpha1 returns the v1 version of a v1alpha1 GameServerSet
+func ConvertGameServerSetFromV1alpha1(in *v1alpha1.GameServerSet) *GameServerSet {
+	return &GameServerSet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: SchemeGroupVersion.String(), Kind: "GameServerSet"},
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+		Spec:       *in.Spec.DeepCopy(),
+		Status:     *in.Status.DeepCopy(),
+	}
+}
+
+// ConvertGameServerSetToV1alpha1 returns the v1alpha1 version of a v1 GameServerSet
+func ConvertGameServerSetToV1alpha1(in *GameServerSet) *v1alpha1.GameServerSet {
+	return &v1alpha1.GameServerSet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: "GameServerSet"},
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+		Spec:       *in.Spec.DeepCopy(),
+		Status:     *in.Status.DeepCopy(),
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	gs := &v1alpha1.GameServer{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "stable.agones.dev/v1alpha1", Kind: "GameServer"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", Labels: map[string]string{"a": "b"}},
+		Spec: v1alpha1.GameServerSpec{
+			Ports: []v1alpha1.GameServerPort{{Name: "default", ContainerPort: 7777, PortPolicy: v1alpha1.Dynamic}},
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "container", Image: "container/image"}}}},
+		},
+		Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReady, Address: "127.0.0.1"},
+	}
+	gs.ApplyDefaults()
+
+	raw, err := json.Marshal(gs)
+	assert.Nil(t, err)
+
+	obj, err := Convert(raw, "stable.agones.dev/v1")
+	assert.Nil(t, err)
+	v1gs, ok := obj.(*GameServer)
+	if assert.True(t, ok) {
+		assert.Equal(t, metav1.TypeMeta{APIVersion: "stable.agones.dev/v1", Kind: "GameServer"}, v1gs.TypeMeta)
+		assert.Equal(t, gs.ObjectMeta, v1gs.ObjectMeta)
+		assert.Equal(t, gs.Spec, v1gs.Spec)
+		assert.Equal(t, gs.Status, v1gs.Status)
+	}
+
+	// and back again
+	raw, err = json.Marshal(v1gs)
+	assert.Nil(t, err)
+	obj, err = Convert(raw, "stable.agones.dev/v1alpha1")
+	assert.Nil(t, err)
+	assert.Equal(t, gs, obj)
+
+	// same version
+	obj, err = Convert(raw, "stable.agones.dev/v1")
+	assert.Nil(t, err)
+	assert.Equal(t, v1gs, obj)
+
+	fleet := &v1alpha1.Fleet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "stable.agones.dev/v1alpha1", Kind: "Fleet"},
+		ObjectMeta: metav1.ObjectMeta{Name: "fleet"},
+		Spec:       v1alpha1.FleetSpec{Replicas: 3, Template: v1alpha1.GameServerTemplateSpec{Spec: gs.Spec}},
+	}
+	raw, err = json.Marshal(fleet)
+	assert.Nil(t, err)
+	obj, err = Convert(raw, "stable.agones.dev/v1")
+	assert.Nil(t, err)
+	if assert.IsType(t, &Fleet{}, obj) {
+		assert.Equal(t, int32(3), obj.(*Fleet).Spec.Replicas)
+		assert.Equal(t, gs.Spec, obj.(*Fleet).Spec.Template.Spec)
+	}
+
+	gsSet := &GameServerSet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "stable.agones.dev/v1", Kind: "GameServerSet"},
+		ObjectMeta: metav1.ObjectMeta{Name: "gsSet"},
+		Spec:       GameServerSetSpec{Replicas: 2},
+	}
+	raw, err = json.Marshal(gsSet)
+	assert.Nil(t, err)
+	obj, err = Convert(raw, "stable.agones.dev/v1alpha1")
+	assert.Nil(t, err)
+	if assert.IsType(t, &v1alpha1.GameServerSet{}, obj) {
+		assert.Equal(t, "stable.agones.dev/v1alpha1", obj.(*v1alpha1.GameServerSet).APIVersion)
+		assert.Equal(t, int32(2), obj.(*v1alpha1.GameServerSet).Spec.Replicas)
+	}
+
+	_, err = Convert(raw, "stable.agones.dev/v2")
+	assert.EqualError(t, err, "cannot convert stable.agones.dev/v1, Kind=GameServerSet to stable.agones.dev/v2")
+
+	_, err = Convert([]byte(`{"apiVersion": "v1", "kind": "Pod"}`), "stable.agones.dev/v1")
+	assert.EqualError(t, err, "cannot convert /v1, Kind=Pod to stable.agones.dev/v1")
+
+	_, err = Convert([]byte("{"), "stable.agones.dev/v1")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// totalAnnotationSizeLimit is the most bytes that the keys and values of the annotations
+// of an object can add up to, as enforced by the Kubernetes API server
+const totalAnnotationSizeLimit = 256 * 1024
+
+// AllocationOverflow is the labels and annotations that are applied to the Allocated GameServers
+// of a Fleet's outdated GameServerSets during a rollout, e.g. so that the game server can tell
+// its players to move to a GameServer of the new version
+type AllocationOverflow struct {
+	// Labels are added to the labels of the Allocated GameServers
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are added to the annotations of the Allocated GameServers
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Applied returns if all the labels and annotations of the AllocationOverflow are set on the GameServer
+func (ao *AllocationOverflow) Applied(gs *GameServer) bool {
+	for k, v := range ao.Labels {
+		if l, ok := gs.ObjectMeta.Labels[k]; !ok || l != v {
+			return false
+		}
+	}
+	for k, v := range ao.Annotations {
+		if a, ok := gs.ObjectMeta.Annotations[k]; !ok || a != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply sets the labels and annotations of the AllocationOverflow on the GameServer
+func (ao *AllocationOverflow) Apply(gs *GameServer) {
+	if len(ao.Labels) > 0 && gs.ObjectMeta.Labels == nil {
+		gs.ObjectMeta.Labels = make(map[string]string, len(ao.Labels))
+	}
+	for k, v := range ao.Labels {
+		gs.ObjectMeta.Labels[k] = v
+	}
+	if len(ao.Annotations) > 0 && gs.ObjectMeta.Annotations == nil {
+		gs.ObjectMeta.Annotations = make(map[string]string, len(ao.Annotations))
+	}
+	for k, v := range ao.Annotations {
+		gs.ObjectMeta.Annotations[k] = v
+	}
+}
+
+// Validate validates the labels and annotations of the AllocationOverflow the same way the
+// Kubernetes API server will, reporting the causes against the field, so that invalid metadata
+// is rejected up front, rather than failing the update of the GameServers during a rollout
+func (ao *AllocationOverflow) Validate(field string) []metav1.StatusCause {
+	var causes []metav1.StatusCause
+
+	for _, k := range sortedKeys(ao.Labels) {
+		for _, msg := range validation.IsQualifiedName(k) {
+			causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+				Field:   field + ".labels",
+				Message: fmt.Sprintf("Invalid key: %s, %s", k, msg)})
+		}
+		for _, msg := range validation.IsValidLabelValue(ao.Labels[k]) {
+			causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+				Field:   fmt.Sprintf("%s.labels.%s", field, k),
+				Message: fmt.Sprintf("Invalid value: %s, %s", ao.Labels[k], msg)})
+		}
+	}
+
+	size := 0
+	for _, k := range sortedKeys(ao.Annotations) {
+		for _, msg := range validation.IsQualifiedName(strings.ToLower(k)) {
+			causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+				Field:   field + ".annotations",
+				Message: fmt.Sprintf("Invalid key: %s, %s", k, msg)})
+		}
+		size += len(k) + len(ao.Annotations[k])
+	}
+	if size > totalAnnotationSizeLimit {
+		causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid,
+			Field:   field + ".annotations",
+			Message: fmt.Sprintf("Invalid value: %d bytes, annotations must have at most %d bytes", size, totalAnnotationSizeLimit)})
+	}
+
+	return causes
+}
+
+// sortedKeys returns the keys of the map in order, so that validation causes are reported consistently
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
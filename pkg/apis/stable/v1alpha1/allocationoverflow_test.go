@@ -0,0 +1,64 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAllocationOverflowApply(t *testing.T) {
+	t.Parallel()
+
+	ao := &AllocationOverflow{
+		Labels:      map[string]string{"version": "outdated"},
+		Annotations: map[string]string{"message": "please migrate"},
+	}
+	gs := &GameServer{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"version": "1", "game": "shooter"}}}
+	assert.False(t, ao.Applied(gs))
+
+	ao.Apply(gs)
+	assert.True(t, ao.Applied(gs))
+	assert.Equal(t, map[string]string{"version": "outdated", "game": "shooter"}, gs.ObjectMeta.Labels)
+	assert.Equal(t, map[string]string{"message": "please migrate"}, gs.ObjectMeta.Annotations)
+
+	gs.ObjectMeta.Annotations["message"] = "changed"
+	assert.False(t, ao.Applied(gs))
+
+	assert.True(t, (&AllocationOverflow{}).Applied(&GameServer{}))
+}
+
+func TestAllocationOverflowValidate(t *testing.T) {
+	t.Parallel()
+
+	ao := &AllocationOverflow{
+		Labels:      map[string]string{"version": "outdated"},
+		Annotations: map[string]string{"message": "please migrate"},
+	}
+	assert.Empty(t, ao.Validate("allocationOverflow"))
+
+	ao.Labels["bad key!"] = "value"
+	ao.Labels["version"] = strings.Repeat("a", 64)
+	ao.Annotations["big"] = strings.Repeat("a", totalAnnotationSizeLimit)
+	causes := ao.Validate("allocationOverflow")
+	if assert.Len(t, causes, 3) {
+		assert.Equal(t, "allocationOverflow.labels", causes[0].Field)
+		assert.Equal(t, "allocationOverflow.labels.version", causes[1].Field)
+		assert.Equal(t, "allocationOverflow.annotations", causes[2].Field)
+	}
+}
@@ -24,11 +24,42 @@ import (
 
 // Block of const Error messages
 const (
-	ErrContainerRequired        = "Container is required when using multiple containers in the pod template"
-	ErrHostPortDynamic          = "HostPort cannot be specified with a Dynamic PortPolicy"
-	ErrPortPolicyStatic         = "PortPolicy must be Static"
-	ErrContainerPortRequired    = "ContainerPort must be defined for Dynamic and Static PortPolicies"
-	ErrContainerPortPassthrough = "ContainerPort cannot be specified with Passthrough PortPolicy"
+	ErrContainerRequired              = "Container is required when using multiple containers in the pod template"
+	ErrHostPortDynamic                = "HostPort cannot be specified with a Dynamic PortPolicy"
+	ErrPortPolicyStatic               = "PortPolicy must be Static"
+	ErrHostPortNone                   = "HostPort cannot be specified with a None PortPolicy"
+	ErrPortPolicyNoneMixed            = "PortPolicy None cannot be mixed with other PortPolicies, as the GameServer's address is then the IP of its Pod"
+	ErrContainerPortRequired          = "ContainerPort must be defined for Dynamic, Static and None PortPolicies"
+	ErrContainerPortPassthrough       = "ContainerPort cannot be specified with Passthrough PortPolicy"
+	ErrRangeStatic                    = "Range cannot be specified with a Static PortPolicy"
+	ErrRangeNone                      = "Range cannot be specified with a None PortPolicy"
+	ErrRangePortRange                 = "Range must be empty or the same as the portRange of the Fleet or GameServerSet"
+	ErrPortContainerInvalid           = "Container must be the name of a container in the pod template"
+	ErrReadinessInvalid               = "Readiness must be either SDK or Pod"
+	ErrRestartPolicyInvalid           = "RestartPolicy must be one of Never, OnFailure or InPlace"
+	ErrRestartInPlacePodRestartPolicy = "The Pod restartPolicy must be Always for the InPlace RestartPolicy"
+	ErrBackoffLimitInvalid            = "BackoffLimit must not be negative"
+	ErrHostAliasIPInvalid             = "HostAlias ip must be a valid IP address"
+	ErrHostAliasHostnamesInvalid      = "HostAlias hostnames must be one or more valid DNS subdomain names"
+	ErrCounterInvalid                 = "Count must be between 0 and Capacity"
+	ErrListInvalid                    = "Values must not be more than Capacity, or contain duplicates"
+	ErrPlayerCapacityInvalid          = "Player capacity must not be negative"
+	ErrPreReadyServiceNameInvalid     = "PreReady service name must be a valid Service name"
+	ErrPreReadyServicePortInvalid     = "PreReady service port must be between 1 and 65535"
+	ErrPreReadyServicePathInvalid     = "PreReady service path must be an absolute path, without a query or fragment"
+	ErrPreReadyTimeoutInvalid         = "PreReady timeoutSeconds must be between 1 and 30"
+	ErrPreReadyFailurePolicyInvalid   = "PreReady failurePolicy must be either Fail or Ignore"
+	ErrSafeToEvictPolicyInvalid       = "Safe to evict policy must be either Managed or Never"
+	ErrEvictionSafeInvalid            = "Eviction safe must be one of Always, OnUpgrade or Never"
+	ErrSDKTokenMountInvalid           = "SDKToken mount must be one of Auto, Enabled, Disabled or Projected"
+	ErrSDKTokenAudienceInvalid        = "SDKToken audience must be set with, and only with, the Projected mount"
+	ErrSDKTokenExpirationInvalid      = "SDKToken expirationSeconds must be at least 600, and only set with the Projected mount"
+	ErrDisruptionBudgetInvalid        = "MinAvailable must be a non-negative integer, or a percentage between 0% and 100%"
+	ErrHealthDefaultsInvalid          = "Health defaults must not be negative"
+	ErrRevisionHistoryLimitInvalid    = "RevisionHistoryLimit must not be negative"
+	ErrProgressDeadlineInvalid        = "ProgressDeadlineSeconds must be at least 1"
+	ErrScaleDownPreferenceInvalid     = "ScaleDownPreference must be one of OldestFirst, NewestFirst or Cost"
+	ErrCanaryWeightInvalid            = "Canary weight must be between 1 and 99"
 )
 
 // crd is an interface to get Name and Kind of CRD
@@ -59,12 +90,52 @@ type gsSpec interface {
 	GetGameServerSpec() *GameServerSpec
 }
 
-// validateGSSpec Check GameserverSpec of a CRD
+// validatePortRange checks that the ports of the GameServer template of a CRD don't
+// set a Range other than the portRange that the CRD pins them to.
 // Used by Fleet and Gameserverset
-func validateGSSpec(gs gsSpec) []v1.StatusCause {
+func validatePortRange(portRange string, gsSpec *GameServerSpec) []v1.StatusCause {
+	var causes []v1.StatusCause
+	if portRange == "" {
+		return causes
+	}
+	for _, p := range gsSpec.Ports {
+		if p.PortPolicy != Static && p.PortPolicy != None && p.Range != "" && p.Range != portRange {
+			causes = append(causes, v1.StatusCause{
+				Type:    v1.CauseTypeFieldValueInvalid,
+				Field:   fmt.Sprintf("%s.range", p.Name),
+				Message: ErrRangePortRange,
+			})
+		}
+	}
+	return causes
+}
+
+// validateScaleDownPreference checks that the scaleDownPreference of a CRD is either not set, or known.
+// Used by Fleet and Gameserverset
+func validateScaleDownPreference(p ScaleDownPreference) []v1.StatusCause {
+	switch p {
+	case "", ScaleDownOldestFirst, ScaleDownNewestFirst, ScaleDownCost:
+		return nil
+	}
+	return []v1.StatusCause{{
+		Type:    v1.CauseTypeFieldValueInvalid,
+		Field:   "scaleDownPreference",
+		Message: ErrScaleDownPreferenceInvalid,
+	}}
+}
+
+// validateGSSpec Check GameserverSpec of a CRD
+// Used by Fleet and Gameserverset, which report the causes against the fields
+// of their template through the field prefix, e.g. "template.spec."
+func validateGSSpec(gs gsSpec, field string) []v1.StatusCause {
 	gsSpec := gs.GetGameServerSpec()
 	gsSpec.ApplyDefaults()
 	causes, _ := gsSpec.Validate("")
 
+	for i := range causes {
+		if causes[i].Field != "" {
+			causes[i].Field = field + causes[i].Field
+		}
+	}
 	return causes
 }
@@ -15,10 +15,18 @@
 package v1alpha1
 
 import (
+	"fmt"
+	"strconv"
+	"time"
+
 	"agones.dev/agones/pkg"
 	"agones.dev/agones/pkg/apis"
 	"agones.dev/agones/pkg/apis/stable"
+	"agones.dev/agones/pkg/util/cron"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -27,6 +35,54 @@ const (
 	// FleetNameLabel is the label that the name of the Fleet
 	// is set to on GameServerSet and GameServer  the Fleet controls
 	FleetNameLabel = stable.GroupName + "/fleet"
+	// FleetDeleteProtectionAnnotation is the annotation that protects a Fleet from being deleted.
+	// Set it to FleetDeleteProtectionAlways to reject every deletion, or FleetDeleteProtectionAllocated
+	// to reject deletion while the Fleet has Allocated GameServers
+	FleetDeleteProtectionAnnotation = stable.GroupName + "/delete-protection"
+	// FleetForceDeleteAnnotation is the annotation that, when set to "true", allows a Fleet
+	// protected with FleetDeleteProtectionAllocated to be deleted while it has Allocated GameServers
+	FleetForceDeleteAnnotation = stable.GroupName + "/force-delete"
+	// FleetCPURequestAnnotation is the annotation with the estimated CPU request of each of the
+	// Fleet's GameServer Pods, including the SDK sidecar
+	FleetCPURequestAnnotation = stable.GroupName + "/estimated-cpu-request"
+	// FleetMemoryRequestAnnotation is the annotation with the estimated memory request of each of the
+	// Fleet's GameServer Pods, including the SDK sidecar
+	FleetMemoryRequestAnnotation = stable.GroupName + "/estimated-memory-request"
+	// FleetRevisionAnnotation is the annotation with the revision of the Fleet's template
+	// that a GameServerSet was created from
+	FleetRevisionAnnotation = stable.GroupName + "/revision"
+	// FleetRollbackAnnotation is the annotation that, when set to the number of a revision in the
+	// Fleet's revision history, rolls the Fleet's template back to the template of that revision.
+	// The Fleet controller removes it once the rollback is done.
+	FleetRollbackAnnotation = stable.GroupName + "/rollback-to"
+	// FleetCanaryLabel is the label that is set to "true" on the GameServerSet
+	// of a Fleet's canary template
+	FleetCanaryLabel = stable.GroupName + "/canary"
+
+	// FleetDeleteProtectionAlways rejects all deletions of the Fleet
+	FleetDeleteProtectionAlways = "Always"
+	// FleetDeleteProtectionAllocated rejects deletion of the Fleet while it has Allocated GameServers
+	FleetDeleteProtectionAllocated = "Allocated"
+
+	// DefaultProgressDeadlineSeconds is the default number of seconds a rollout of a Fleet can go without progress
+	DefaultProgressDeadlineSeconds = int32(600)
+	// DefaultRevisionHistoryLimit is the default number of previous revisions of a Fleet's template that are kept
+	DefaultRevisionHistoryLimit = int32(10)
+
+	// MaxUpdateWindowDuration is the longest that an UpdateWindow can be open for
+	MaxUpdateWindowDuration = 7 * 24 * time.Hour
+)
+
+var (
+	// resourceEstimateAnnotations are the annotations of the resources that are estimated for a Fleet
+	resourceEstimateAnnotations = map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    FleetCPURequestAnnotation,
+		corev1.ResourceMemory: FleetMemoryRequestAnnotation,
+	}
+
+	// DefaultDisruptionBudgetMinAvailable is the default minAvailable of the PodDisruptionBudget of a Fleet,
+	// which keeps all of its Allocated GameServers from being evicted
+	DefaultDisruptionBudgetMinAvailable = intstr.FromString("100%")
 )
 
 // +genclient
@@ -61,10 +117,93 @@ type FleetSpec struct {
 	Strategy appsv1.DeploymentStrategy `json:"strategy"`
 	// Scheduling strategy. Defaults to "Packed".
 	Scheduling apis.SchedulingStrategy `json:"scheduling"`
+	// Paused stops a rollout of the template from progressing, and a change of the template from starting
+	// one, until it is unset. The Fleet can still be scaled, and its status is still maintained.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+	// PortRange is the name of the port range that all the Dynamic and Passthrough ports of this
+	// Fleet's GameServers are allocated from. If empty, each port's own range is used.
+	// +optional
+	PortRange string `json:"portRange,omitempty"`
+	// ScaleDownPreference is which Ready GameServers are deleted first when the Fleet is scaled down, one of
+	// "OldestFirst", "NewestFirst" or "Cost". If not set, it depends on the scheduling strategy.
+	// +optional
+	ScaleDownPreference ScaleDownPreference `json:"scaleDownPreference,omitempty"`
+	// UpdateWindows are the windows of time in which an update of the Fleet's GameServers, through
+	// its deployment strategy, can progress. Outside of them, the update is paused. If empty, updates
+	// can always progress.
+	// +optional
+	UpdateWindows []UpdateWindow `json:"updateWindows,omitempty"`
+	// DisruptionBudget configures the PodDisruptionBudget that protects the Pods of the Fleet's
+	// Allocated GameServers from voluntary disruptions, such as node drains
+	// +optional
+	DisruptionBudget *FleetDisruptionBudget `json:"disruptionBudget,omitempty"`
+	// HealthDefaults are the health checking settings of the Fleet's GameServers, for those that
+	// its template doesn't set
+	// +optional
+	HealthDefaults *FleetHealthDefaults `json:"healthDefaults,omitempty"`
+	// AllocationOverflow is the labels and annotations that are applied to the Allocated GameServers of
+	// the Fleet's outdated GameServerSets during a rollout
+	// +optional
+	AllocationOverflow *AllocationOverflow `json:"allocationOverflow,omitempty"`
+	// Canary is a second GameServer template, that a share of the Fleet's GameServers are
+	// created from, e.g. to try out a new game server build before rolling it out
+	// +optional
+	Canary *FleetCanary `json:"canary,omitempty"`
+	// ProgressDeadlineSeconds is the number of seconds a rollout of the Fleet can go without progress
+	// before its Progressing condition is set to False, with the ProgressDeadlineExceeded reason.
+	// Defaults to 600.
+	// +optional
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+	// RevisionHistoryLimit is the number of previous revisions of the template that are kept in the
+	// Fleet's status, so that it can be rolled back to them. Defaults to 10.
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
 	// Template the GameServer template to apply for this Fleet
 	Template GameServerTemplateSpec `json:"template"`
 }
 
+// UpdateWindow is a window of time in which an update of a Fleet's GameServers can progress
+type UpdateWindow struct {
+	// Schedule is when the window opens, as a five field cron expression in UTC, e.g. "0 2 * * 1-5"
+	// for 2am every weekday
+	Schedule string `json:"schedule"`
+	// Duration is how long the window stays open for, e.g. "4h"
+	Duration metav1.Duration `json:"duration"`
+}
+
+// FleetDisruptionBudget configures the PodDisruptionBudget of a Fleet
+type FleetDisruptionBudget struct {
+	// MinAvailable is the number, or percentage, of the Fleet's Allocated GameServers that must stay
+	// available during voluntary disruptions. Defaults to "100%".
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+}
+
+// FleetHealthDefaults are the defaults of the health checking of a Fleet's GameServers.
+// They are set on the Fleet's template by its mutating webhook.
+type FleetHealthDefaults struct {
+	// PeriodSeconds is the number of seconds each health ping has to occur in
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+	// FailureThreshold how many failures in a row constitutes unhealthy
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+	// InitialDelaySeconds initial delay before checking health
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+}
+
+// FleetCanary is the canary template of a Fleet
+type FleetCanary struct {
+	// Weight is the percentage, from 1 to 99, of the Fleet's replicas that are created from
+	// the canary template, rounded up
+	Weight int32 `json:"weight"`
+	// Template the GameServer template of the canary
+	Template GameServerTemplateSpec `json:"template"`
+}
+
+// GetGameServerSpec get underlying Gameserver specification
+func (c *FleetCanary) GetGameServerSpec() *GameServerSpec {
+	return &c.Template.Spec
+}
+
 // FleetStatus is the status of a Fleet
 type FleetStatus struct {
 	// Replicas the total number of current GameServer replicas
@@ -76,6 +215,110 @@ type FleetStatus struct {
 	ReservedReplicas int32 `json:"reservedReplicas"`
 	// AllocatedReplicas are the number of Allocated GameServer replicas
 	AllocatedReplicas int32 `json:"allocatedReplicas"`
+	// Players are the total player count and capacity of the GameServers that track players
+	Players *AggregatedPlayerStatus `json:"players,omitempty"`
+	// Counters are the total counts and capacities of the counters of the GameServers, by name
+	Counters map[string]AggregatedCounterStatus `json:"counters,omitempty"`
+	// Lists are the total number of values and capacities of the lists of the GameServers, by name
+	Lists map[string]AggregatedListStatus `json:"lists,omitempty"`
+	// UpdatedReplicas are the number of GameServer replicas of the Fleet's current template
+	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+	// CanaryReplicas are the number of GameServer replicas of the Fleet's canary template
+	CanaryReplicas int32 `json:"canaryReplicas,omitempty"`
+	// OutdatedSidecarReplicas are the number of GameServer replicas that run an outdated SDK sidecar image,
+	// while the controller is replacing them to roll out its current one
+	OutdatedSidecarReplicas int32 `json:"outdatedSidecarReplicas,omitempty"`
+	// Revision is the revision of the Fleet's current template
+	Revision int64 `json:"revision,omitempty"`
+	// RevisionHistory are the previous revisions of the Fleet's template, newest first
+	RevisionHistory []FleetRevision `json:"revisionHistory,omitempty"`
+	// Conditions are the latest observations of the progress of the Fleet's rollouts,
+	// maintained by the controller
+	Conditions []FleetCondition `json:"conditions,omitempty"`
+}
+
+// FleetConditionType is the type of a FleetCondition
+type FleetConditionType string
+
+const (
+	// FleetProgressing is whether a rollout of the Fleet is progressing, or has completed,
+	// or has gone without progress for longer than its progress deadline
+	FleetProgressing FleetConditionType = "Progressing"
+	// FleetReplicaFailure is whether GameServers of the Fleet could not be created
+	FleetReplicaFailure FleetConditionType = "ReplicaFailure"
+)
+
+// FleetCondition is an observation of the state of a Fleet
+type FleetCondition struct {
+	Type   FleetConditionType     `json:"type"`
+	Status corev1.ConditionStatus `json:"status"`
+	// LastUpdateTime is the last time the condition was updated, which for the Progressing
+	// condition is the last time the rollout progressed
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+	// LastTransitionTime is the last time the condition's status changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a machine readable, CamelCase reason for the condition's last transition
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable description of the condition's last transition
+	Message string `json:"message,omitempty"`
+}
+
+// Condition returns the condition of the given type, or nil if there isn't one
+func (s *FleetStatus) Condition(t FleetConditionType) *FleetCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == t {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition of the given type, and returns whether it changed.
+// The LastUpdateTime is moved to now whenever the condition changes, and the LastTransitionTime
+// only when its status changes.
+func (s *FleetStatus) SetCondition(t FleetConditionType, status corev1.ConditionStatus, reason, message string) bool {
+	now := metav1.Now()
+	c := s.Condition(t)
+	if c == nil {
+		s.Conditions = append(s.Conditions, FleetCondition{
+			Type:               t,
+			Status:             status,
+			LastUpdateTime:     now,
+			LastTransitionTime: now,
+			Reason:             reason,
+			Message:            message,
+		})
+		return true
+	}
+	if c.Status == status && c.Reason == reason && c.Message == message {
+		return false
+	}
+	if c.Status != status {
+		c.LastTransitionTime = now
+	}
+	c.Status = status
+	c.LastUpdateTime = now
+	c.Reason = reason
+	c.Message = message
+	return true
+}
+
+// RemoveCondition removes the condition of the given type, if there is one
+func (s *FleetStatus) RemoveCondition(t FleetConditionType) {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == t {
+			s.Conditions = append(s.Conditions[:i], s.Conditions[i+1:]...)
+			return
+		}
+	}
+}
+
+// FleetRevision is a previous revision of a Fleet's template
+type FleetRevision struct {
+	// Revision is the number of the revision, which increases with every change of the template
+	Revision int64 `json:"revision"`
+	// Template is the GameServer template of the revision
+	Template GameServerTemplateSpec `json:"template"`
 }
 
 // GameServerSet returns a single GameServerSet for this Fleet definition
@@ -83,8 +326,10 @@ func (f *Fleet) GameServerSet() *GameServerSet {
 	gsSet := &GameServerSet{
 		ObjectMeta: *f.Spec.Template.ObjectMeta.DeepCopy(),
 		Spec: GameServerSetSpec{
-			Template:   f.Spec.Template,
-			Scheduling: f.Spec.Scheduling,
+			Template:            f.Spec.Template,
+			Scheduling:          f.Spec.Scheduling,
+			PortRange:           f.Spec.PortRange,
+			ScaleDownPreference: f.Spec.ScaleDownPreference,
 		},
 	}
 
@@ -108,6 +353,54 @@ func (f *Fleet) GameServerSet() *GameServerSet {
 	return gsSet
 }
 
+// CanaryGameServerSet returns a GameServerSet for the Fleet's canary template,
+// or nil if the Fleet has no canary
+func (f *Fleet) CanaryGameServerSet() *GameServerSet {
+	if f.Spec.Canary == nil {
+		return nil
+	}
+	canary := f.DeepCopy()
+	canary.Spec.Template = f.Spec.Canary.Template
+	gsSet := canary.GameServerSet()
+	gsSet.ObjectMeta.Labels[FleetCanaryLabel] = "true"
+	return gsSet
+}
+
+// CanaryReplicas returns the number of the Fleet's replicas that are created from its canary template
+func (f *Fleet) CanaryReplicas() int32 {
+	if f.Spec.Canary == nil {
+		return 0
+	}
+	return (f.Spec.Replicas*f.Spec.Canary.Weight + 99) / 100
+}
+
+// PodDisruptionBudget returns the PodDisruptionBudget that protects the Pods of the Fleet's
+// Allocated GameServers
+func (f *Fleet) PodDisruptionBudget() *policyv1beta1.PodDisruptionBudget {
+	minAvailable := DefaultDisruptionBudgetMinAvailable
+	if f.Spec.DisruptionBudget != nil && f.Spec.DisruptionBudget.MinAvailable != nil {
+		minAvailable = *f.Spec.DisruptionBudget.MinAvailable
+	}
+
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      f.ObjectMeta.Name,
+			Namespace: f.ObjectMeta.Namespace,
+			Labels:    map[string]string{FleetNameLabel: f.ObjectMeta.Name},
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{FleetNameLabel: f.ObjectMeta.Name, PodAllocatedLabel: "true"},
+			},
+		},
+	}
+	ref := metav1.NewControllerRef(f, SchemeGroupVersion.WithKind("Fleet"))
+	pdb.ObjectMeta.OwnerReferences = append(pdb.ObjectMeta.OwnerReferences, *ref)
+
+	return pdb
+}
+
 // ApplyDefaults applies default values to the Fleet
 func (f *Fleet) ApplyDefaults() {
 	if f.Spec.Strategy.Type == "" {
@@ -131,6 +424,23 @@ func (f *Fleet) ApplyDefaults() {
 			f.Spec.Strategy.RollingUpdate.MaxUnavailable = &def
 		}
 	}
+	if f.Spec.DisruptionBudget == nil {
+		f.Spec.DisruptionBudget = &FleetDisruptionBudget{}
+	}
+	if f.Spec.DisruptionBudget.MinAvailable == nil {
+		def := DefaultDisruptionBudgetMinAvailable
+		f.Spec.DisruptionBudget.MinAvailable = &def
+	}
+	if f.Spec.ProgressDeadlineSeconds == nil {
+		def := DefaultProgressDeadlineSeconds
+		f.Spec.ProgressDeadlineSeconds = &def
+	}
+	if f.Spec.RevisionHistoryLimit == nil {
+		def := DefaultRevisionHistoryLimit
+		f.Spec.RevisionHistoryLimit = &def
+	}
+	f.ApplyHealthDefaults(nil)
+
 	// Add Agones version into Fleet Annotations
 	if f.ObjectMeta.Annotations == nil {
 		f.ObjectMeta.Annotations = make(map[string]string, 1)
@@ -138,6 +448,77 @@ func (f *Fleet) ApplyDefaults() {
 	f.ObjectMeta.Annotations[stable.VersionAnnotation] = pkg.Version
 }
 
+// ApplyHealthDefaults sets the health checking settings of the Fleet's template that it doesn't set
+// from its health defaults. When the Fleet is updated, previous are its health defaults before the
+// update, and the settings that still have their previous default value are set to the new one.
+func (f *Fleet) ApplyHealthDefaults(previous *FleetHealthDefaults) {
+	if f.Spec.HealthDefaults == nil {
+		return
+	}
+	if previous == nil {
+		previous = &FleetHealthDefaults{}
+	}
+
+	h := &f.Spec.Template.Spec.Health
+	applyHealthDefault(&h.PeriodSeconds, previous.PeriodSeconds, f.Spec.HealthDefaults.PeriodSeconds)
+	applyHealthDefault(&h.FailureThreshold, previous.FailureThreshold, f.Spec.HealthDefaults.FailureThreshold)
+	applyHealthDefault(&h.InitialDelaySeconds, previous.InitialDelaySeconds, f.Spec.HealthDefaults.InitialDelaySeconds)
+}
+
+// applyHealthDefault sets v to def if it is not set, or still has its previous default value
+func applyHealthDefault(v *int32, previous, def int32) {
+	if def > 0 && (*v == 0 || *v == previous) {
+		*v = def
+	}
+}
+
+// ApplyResourceEstimates annotates the Fleet with the estimated CPU and memory requests of each
+// of its GameServer Pods, including the given resources of the SDK sidecar. The annotation of a
+// resource that isn't requested is removed.
+func (f *Fleet) ApplyResourceEstimates(sidecar corev1.ResourceRequirements) {
+	requests := f.Spec.Template.Spec.PodResourceRequests(sidecar)
+	for name, annotation := range resourceEstimateAnnotations {
+		q, ok := requests[name]
+		if !ok || q.IsZero() {
+			delete(f.ObjectMeta.Annotations, annotation)
+			continue
+		}
+		if f.ObjectMeta.Annotations == nil {
+			f.ObjectMeta.Annotations = make(map[string]string, len(resourceEstimateAnnotations))
+		}
+		f.ObjectMeta.Annotations[annotation] = q.String()
+	}
+}
+
+// ResourceEstimates returns the estimated CPU and memory requests of each of the Fleet's GameServer Pods.
+// These come from its resource estimate annotations, or if it has none, from its template without
+// the SDK sidecar.
+func (f *Fleet) ResourceEstimates() corev1.ResourceList {
+	estimates := corev1.ResourceList{}
+	annotated := false
+	for name, annotation := range resourceEstimateAnnotations {
+		v, ok := f.ObjectMeta.Annotations[annotation]
+		if !ok {
+			continue
+		}
+		annotated = true
+		if q, err := resource.ParseQuantity(v); err == nil {
+			estimates[name] = q
+		}
+	}
+	if annotated {
+		return estimates
+	}
+
+	requests := f.Spec.Template.Spec.PodResourceRequests()
+	for name := range resourceEstimateAnnotations {
+		if q, ok := requests[name]; ok {
+			estimates[name] = q
+		}
+	}
+	return estimates
+}
+
 // GetGameServerSpec get underlying Gameserver specification
 func (f *Fleet) GetGameServerSpec() *GameServerSpec {
 	return &f.Spec.Template.Spec
@@ -174,15 +555,168 @@ func (f *Fleet) Validate() ([]metav1.StatusCause, bool) {
 		f.validateRollingUpdate(f.Spec.Strategy.RollingUpdate.MaxUnavailable, &causes, "MaxUnavailable")
 		f.validateRollingUpdate(f.Spec.Strategy.RollingUpdate.MaxSurge, &causes, "MaxSurge")
 	}
+	switch v := f.ObjectMeta.Annotations[FleetDeleteProtectionAnnotation]; v {
+	case "", FleetDeleteProtectionAlways, FleetDeleteProtectionAllocated:
+	default:
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "metadata.annotations." + FleetDeleteProtectionAnnotation,
+			Message: fmt.Sprintf("Invalid value: %s, value must be either %s or %s", v, FleetDeleteProtectionAlways, FleetDeleteProtectionAllocated),
+		})
+	}
+	causes = append(causes, validatePortRange(f.Spec.PortRange, f.GetGameServerSpec())...)
+	causes = append(causes, validateScaleDownPreference(f.Spec.ScaleDownPreference)...)
+	causes = append(causes, validateUnhealthyRetention(f.Spec.Template.ObjectMeta.Annotations, "template.metadata.annotations")...)
+	causes = append(causes, validateSafeToEvictPolicy(f.Spec.Template.ObjectMeta.Annotations, "template.metadata.annotations")...)
+	if f.Spec.DisruptionBudget != nil && f.Spec.DisruptionBudget.MinAvailable != nil {
+		minAvailable := f.Spec.DisruptionBudget.MinAvailable
+		r, err := intstr.GetValueFromIntOrPercent(minAvailable, 100, true)
+		if err != nil || r < 0 || (minAvailable.Type == intstr.String && r > 100) {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   "disruptionBudget.minAvailable",
+				Message: ErrDisruptionBudgetInvalid,
+			})
+		}
+	}
+	if d := f.Spec.HealthDefaults; d != nil && (d.PeriodSeconds < 0 || d.FailureThreshold < 0 || d.InitialDelaySeconds < 0) {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "healthDefaults",
+			Message: ErrHealthDefaultsInvalid,
+		})
+	}
+	if f.Spec.ProgressDeadlineSeconds != nil && *f.Spec.ProgressDeadlineSeconds < 1 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "progressDeadlineSeconds",
+			Message: ErrProgressDeadlineInvalid,
+		})
+	}
+	if f.Spec.RevisionHistoryLimit != nil && *f.Spec.RevisionHistoryLimit < 0 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "revisionHistoryLimit",
+			Message: ErrRevisionHistoryLimitInvalid,
+		})
+	}
+	for i, w := range f.Spec.UpdateWindows {
+		field := "updateWindows[" + strconv.Itoa(i) + "]"
+		if _, err := cron.Parse(w.Schedule); err != nil {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   field + ".schedule",
+				Message: err.Error(),
+			})
+		}
+		if w.Duration.Duration < time.Minute || w.Duration.Duration > MaxUpdateWindowDuration {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   field + ".duration",
+				Message: fmt.Sprintf("duration must be between 1m and %s", MaxUpdateWindowDuration),
+			})
+		}
+	}
+
 	// check Gameserver specification in a Fleet
-	gsCauses := validateGSSpec(f)
-	if len(gsCauses) > 0 {
-		causes = append(causes, gsCauses...)
+	causes = append(causes, validateGSSpec(f, "template.spec.")...)
+	if f.Spec.AllocationOverflow != nil {
+		causes = append(causes, f.Spec.AllocationOverflow.Validate("allocationOverflow")...)
+	}
+	if canary := f.Spec.Canary; canary != nil {
+		if canary.Weight < 1 || canary.Weight > 99 {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   "canary.weight",
+				Message: ErrCanaryWeightInvalid,
+			})
+		}
+		causes = append(causes, validatePortRange(f.Spec.PortRange, canary.GetGameServerSpec())...)
+		causes = append(causes, validateGSSpec(canary, "canary.template.spec.")...)
 	}
 
 	return causes, len(causes) == 0
 }
 
+// ValidateDeletion checks if the Fleet can be deleted, according to its
+// FleetDeleteProtectionAnnotation. If it can't there will be > 0 values in
+// the returned array
+func (f *Fleet) ValidateDeletion() ([]metav1.StatusCause, bool) {
+	var causes []metav1.StatusCause
+	field := "metadata.annotations." + FleetDeleteProtectionAnnotation
+
+	switch f.ObjectMeta.Annotations[FleetDeleteProtectionAnnotation] {
+	case "":
+	case FleetDeleteProtectionAlways:
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueNotSupported,
+			Field:   field,
+			Message: "Fleet is protected from deletion. Remove the annotation to delete it",
+		})
+	case FleetDeleteProtectionAllocated:
+		if f.Status.AllocatedReplicas > 0 && f.ObjectMeta.Annotations[FleetForceDeleteAnnotation] != "true" {
+			causes = append(causes, metav1.StatusCause{
+				Type:  metav1.CauseTypeFieldValueNotSupported,
+				Field: field,
+				Message: fmt.Sprintf("Fleet has %d Allocated GameServers. Set the %s annotation to \"true\" to delete it anyway",
+					f.Status.AllocatedReplicas, FleetForceDeleteAnnotation),
+			})
+		}
+	default:
+		causes = append(causes, metav1.StatusCause{
+			Type:  metav1.CauseTypeFieldValueInvalid,
+			Field: field,
+			Message: fmt.Sprintf("Invalid value: %s, value must be either %s or %s. Remove the annotation to delete the Fleet",
+				f.ObjectMeta.Annotations[FleetDeleteProtectionAnnotation], FleetDeleteProtectionAlways, FleetDeleteProtectionAllocated),
+		})
+	}
+
+	return causes, len(causes) == 0
+}
+
+// InUpdateWindow returns if the given time is within one of the Fleet's UpdateWindows, or the Fleet
+// has none. If it is not, it also returns how long until the next window opens, up to MaxUpdateWindowDuration.
+// UpdateWindows with an invalid schedule are ignored.
+func (f *Fleet) InUpdateWindow(now time.Time) (bool, time.Duration) {
+	if len(f.Spec.UpdateWindows) == 0 {
+		return true, 0
+	}
+
+	now = now.UTC()
+	wait := MaxUpdateWindowDuration
+	for _, w := range f.Spec.UpdateWindows {
+		s, err := cron.Parse(w.Schedule)
+		if err != nil {
+			continue
+		}
+		// a window is open if it opened within its duration
+		if opened, ok := s.Prev(now, w.Duration.Duration); ok && now.Before(opened.Add(w.Duration.Duration)) {
+			return true, 0
+		}
+		if next, ok := s.Next(now, wait); ok && next.Sub(now) < wait {
+			wait = next.Sub(now)
+		}
+	}
+	return false, wait
+}
+
+// ProgressDeadline returns how long a rollout of the Fleet can go without progress
+func (f *Fleet) ProgressDeadline() time.Duration {
+	seconds := DefaultProgressDeadlineSeconds
+	if f.Spec.ProgressDeadlineSeconds != nil {
+		seconds = *f.Spec.ProgressDeadlineSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// RevisionHistoryLimit returns the number of previous revisions of the Fleet's template to keep
+func (f *Fleet) RevisionHistoryLimit() int {
+	if f.Spec.RevisionHistoryLimit == nil {
+		return int(DefaultRevisionHistoryLimit)
+	}
+	return int(*f.Spec.RevisionHistoryLimit)
+}
+
 // UpperBoundReplicas returns whichever is smaller,
 // the value i, or the f.Spec.Replicas.
 func (f *Fleet) UpperBoundReplicas(i int32) int32 {
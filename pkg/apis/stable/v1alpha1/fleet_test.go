@@ -16,11 +16,13 @@ package v1alpha1
 
 import (
 	"testing"
+	"time"
 
 	"agones.dev/agones/pkg/apis"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/validation"
@@ -58,6 +60,10 @@ func TestFleetGameServerSetGameServer(t *testing.T) {
 	assert.Equal(t, f.Spec.Scheduling, gsSet.Spec.Scheduling)
 	assert.Equal(t, f.Spec.Template, gsSet.Spec.Template)
 	assert.True(t, metav1.IsControlledBy(gsSet, &f))
+
+	f.Spec.PortRange = "premium"
+	gsSet = f.GameServerSet()
+	assert.Equal(t, "premium", gsSet.Spec.PortRange)
 }
 
 func TestFleetApplyDefaults(t *testing.T) {
@@ -72,6 +78,44 @@ func TestFleetApplyDefaults(t *testing.T) {
 	assert.Equal(t, "25%", f.Spec.Strategy.RollingUpdate.MaxUnavailable.String())
 	assert.Equal(t, "25%", f.Spec.Strategy.RollingUpdate.MaxSurge.String())
 	assert.Equal(t, apis.Packed, f.Spec.Scheduling)
+	assert.Equal(t, "100%", f.Spec.DisruptionBudget.MinAvailable.String())
+	assert.Equal(t, DefaultRevisionHistoryLimit, *f.Spec.RevisionHistoryLimit)
+	assert.Equal(t, DefaultProgressDeadlineSeconds, *f.Spec.ProgressDeadlineSeconds)
+}
+
+func TestFleetResourceEstimates(t *testing.T) {
+	f := &Fleet{Spec: FleetSpec{Template: GameServerTemplateSpec{Spec: GameServerSpec{
+		Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "container",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("128Mi"),
+			}},
+		}}}},
+	}}}}
+
+	// without annotations, from the template alone
+	estimates := f.ResourceEstimates()
+	assert.Equal(t, "500m", estimates.Cpu().String())
+	assert.Equal(t, "128Mi", estimates.Memory().String())
+
+	sidecar := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("30m")}}
+	f.ApplyResourceEstimates(sidecar)
+	assert.Equal(t, "530m", f.ObjectMeta.Annotations[FleetCPURequestAnnotation])
+	assert.Equal(t, "128Mi", f.ObjectMeta.Annotations[FleetMemoryRequestAnnotation])
+
+	estimates = f.ResourceEstimates()
+	assert.Equal(t, "530m", estimates.Cpu().String())
+	assert.Equal(t, "128Mi", estimates.Memory().String())
+
+	// the annotation of a resource that's no longer requested is removed
+	delete(f.Spec.Template.Spec.Template.Spec.Containers[0].Resources.Requests, corev1.ResourceMemory)
+	f.ApplyResourceEstimates(sidecar)
+	assert.Equal(t, "530m", f.ObjectMeta.Annotations[FleetCPURequestAnnotation])
+	assert.NotContains(t, f.ObjectMeta.Annotations, FleetMemoryRequestAnnotation)
+	estimates = f.ResourceEstimates()
+	assert.Equal(t, "530m", estimates.Cpu().String())
+	assert.NotContains(t, estimates, corev1.ResourceMemory)
 }
 
 func TestFleetUpperBoundReplicas(t *testing.T) {
@@ -118,7 +162,7 @@ func TestFleetGameserverSpec(t *testing.T) {
 
 	assert.False(t, ok)
 	assert.Len(t, causes, 1)
-	assert.Equal(t, "container", causes[0].Field)
+	assert.Equal(t, "template.spec.container", causes[0].Field)
 
 	f.Spec.Template.Spec.Container = "testing"
 	causes, ok = f.Validate()
@@ -176,6 +220,344 @@ func TestFleetName(t *testing.T) {
 	assert.Len(t, causes, 0)
 }
 
+func TestFleetPortRange(t *testing.T) {
+	f := defaultFleet()
+	f.Spec.PortRange = "premium"
+	f.Spec.Template.Spec.Ports = []GameServerPort{
+		{Name: "game", ContainerPort: 7777},
+		{Name: "query", ContainerPort: 7778, Range: "premium"},
+		{Name: "static", ContainerPort: 7779, HostPort: 7779, PortPolicy: Static},
+	}
+	f.ApplyDefaults()
+	causes, ok := f.Validate()
+	assert.True(t, ok)
+	assert.Len(t, causes, 0)
+
+	f = defaultFleet()
+	f.Spec.PortRange = "premium"
+	f.Spec.Template.Spec.Ports = []GameServerPort{{Name: "query", ContainerPort: 7778, Range: "query"}}
+	f.ApplyDefaults()
+	causes, ok = f.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "query.range", causes[0].Field)
+	assert.Equal(t, ErrRangePortRange, causes[0].Message)
+}
+
+func TestFleetScaleDownPreference(t *testing.T) {
+	f := defaultFleet()
+	f.Spec.ScaleDownPreference = ScaleDownCost
+	f.ApplyDefaults()
+	causes, ok := f.Validate()
+	assert.True(t, ok)
+	assert.Len(t, causes, 0)
+	assert.Equal(t, ScaleDownCost, f.GameServerSet().Spec.ScaleDownPreference)
+
+	f.Spec.ScaleDownPreference = "Random"
+	causes, ok = f.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "scaleDownPreference", causes[0].Field)
+	assert.Equal(t, ErrScaleDownPreferenceInvalid, causes[0].Message)
+}
+
+func TestFleetUpdateWindows(t *testing.T) {
+	f := defaultFleet()
+	f.Spec.UpdateWindows = []UpdateWindow{{Schedule: "0 2 * * 1-5", Duration: metav1.Duration{Duration: 4 * time.Hour}}}
+	f.ApplyDefaults()
+	causes, ok := f.Validate()
+	assert.True(t, ok)
+	assert.Len(t, causes, 0)
+
+	f.Spec.UpdateWindows = append(f.Spec.UpdateWindows,
+		UpdateWindow{Schedule: "0 2 * *", Duration: metav1.Duration{Duration: time.Hour}},
+		UpdateWindow{Schedule: "0 2 * * *", Duration: metav1.Duration{Duration: 8 * 24 * time.Hour}})
+	causes, ok = f.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 2)
+	assert.Equal(t, "updateWindows[1].schedule", causes[0].Field)
+	assert.Equal(t, "updateWindows[2].duration", causes[1].Field)
+}
+
+func TestFleetDisruptionBudget(t *testing.T) {
+	f := defaultFleet()
+	f.ApplyDefaults()
+	causes, ok := f.Validate()
+	assert.True(t, ok)
+	assert.Len(t, causes, 0)
+
+	for _, v := range []intstr.IntOrString{intstr.FromInt(-1), intstr.FromString("101%"), intstr.FromString("all")} {
+		v := v
+		f.Spec.DisruptionBudget.MinAvailable = &v
+		causes, ok = f.Validate()
+		assert.False(t, ok, v.String())
+		if assert.Len(t, causes, 1, v.String()) {
+			assert.Equal(t, "disruptionBudget.minAvailable", causes[0].Field)
+			assert.Equal(t, ErrDisruptionBudgetInvalid, causes[0].Message)
+		}
+	}
+
+	minAvailable := intstr.FromInt(3)
+	f.Spec.DisruptionBudget.MinAvailable = &minAvailable
+	causes, ok = f.Validate()
+	assert.True(t, ok)
+	assert.Len(t, causes, 0)
+
+	pdb := f.PodDisruptionBudget()
+	assert.Equal(t, f.ObjectMeta.Name, pdb.ObjectMeta.Name)
+	assert.Equal(t, f.ObjectMeta.Namespace, pdb.ObjectMeta.Namespace)
+	assert.True(t, metav1.IsControlledBy(pdb, f))
+	assert.Equal(t, minAvailable, *pdb.Spec.MinAvailable)
+	assert.Equal(t, map[string]string{FleetNameLabel: f.ObjectMeta.Name, PodAllocatedLabel: "true"}, pdb.Spec.Selector.MatchLabels)
+
+	// Fleets created before the DisruptionBudget existed get the default
+	f.Spec.DisruptionBudget = nil
+	pdb = f.PodDisruptionBudget()
+	assert.Equal(t, DefaultDisruptionBudgetMinAvailable, *pdb.Spec.MinAvailable)
+}
+
+func TestFleetHealthDefaults(t *testing.T) {
+	f := defaultFleet()
+	f.Spec.HealthDefaults = &FleetHealthDefaults{PeriodSeconds: 10, FailureThreshold: 5}
+	f.Spec.Template.Spec.Health.FailureThreshold = 2
+	f.ApplyDefaults()
+	assert.Equal(t, int32(10), f.Spec.Template.Spec.Health.PeriodSeconds)
+	assert.Equal(t, int32(2), f.Spec.Template.Spec.Health.FailureThreshold)
+	assert.Equal(t, int32(0), f.Spec.Template.Spec.Health.InitialDelaySeconds)
+
+	// settings that still have the previous default follow the new one
+	previous := f.Spec.HealthDefaults
+	f.Spec.HealthDefaults = &FleetHealthDefaults{PeriodSeconds: 20, FailureThreshold: 6, InitialDelaySeconds: 3}
+	f.ApplyHealthDefaults(previous)
+	assert.Equal(t, int32(20), f.Spec.Template.Spec.Health.PeriodSeconds)
+	assert.Equal(t, int32(2), f.Spec.Template.Spec.Health.FailureThreshold)
+	assert.Equal(t, int32(3), f.Spec.Template.Spec.Health.InitialDelaySeconds)
+	causes, ok := f.Validate()
+	assert.True(t, ok)
+	assert.Len(t, causes, 0)
+
+	f.Spec.HealthDefaults.FailureThreshold = -1
+	causes, ok = f.Validate()
+	assert.False(t, ok)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, "healthDefaults", causes[0].Field)
+		assert.Equal(t, ErrHealthDefaultsInvalid, causes[0].Message)
+	}
+}
+
+func TestFleetRevisionHistoryLimit(t *testing.T) {
+	f := defaultFleet()
+	assert.Equal(t, int(DefaultRevisionHistoryLimit), f.RevisionHistoryLimit())
+
+	limit := int32(3)
+	f.Spec.RevisionHistoryLimit = &limit
+	assert.Equal(t, 3, f.RevisionHistoryLimit())
+	causes, ok := f.Validate()
+	assert.True(t, ok)
+	assert.Len(t, causes, 0)
+
+	limit = -1
+	causes, ok = f.Validate()
+	assert.False(t, ok)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, "revisionHistoryLimit", causes[0].Field)
+		assert.Equal(t, ErrRevisionHistoryLimitInvalid, causes[0].Message)
+	}
+}
+
+func TestFleetAllocationOverflow(t *testing.T) {
+	f := defaultFleet()
+	f.Spec.AllocationOverflow = &AllocationOverflow{Labels: map[string]string{"version": "outdated"}}
+	causes, ok := f.Validate()
+	assert.True(t, ok)
+	assert.Len(t, causes, 0)
+
+	f.Spec.AllocationOverflow.Labels["bad key!"] = "value"
+	causes, ok = f.Validate()
+	assert.False(t, ok)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, "allocationOverflow.labels", causes[0].Field)
+	}
+}
+
+func TestFleetCanary(t *testing.T) {
+	f := defaultFleet()
+	f.ObjectMeta.Name = "fleet"
+	f.Spec.Replicas = 10
+	assert.Equal(t, int32(0), f.CanaryReplicas())
+	assert.Nil(t, f.CanaryGameServerSet())
+
+	f.Spec.Canary = &FleetCanary{Weight: 10, Template: *f.Spec.Template.DeepCopy()}
+	f.Spec.Canary.Template.Spec.Template.Spec.Containers[0].Image = "testing/image:canary"
+	assert.Equal(t, int32(1), f.CanaryReplicas())
+	f.Spec.Canary.Weight = 25
+	assert.Equal(t, int32(3), f.CanaryReplicas())
+	f.Spec.Replicas = 0
+	assert.Equal(t, int32(0), f.CanaryReplicas())
+	f.Spec.Replicas = 10
+
+	gsSet := f.CanaryGameServerSet()
+	assert.Equal(t, f.Spec.Canary.Template, gsSet.Spec.Template)
+	assert.Equal(t, "fleet-", gsSet.ObjectMeta.GenerateName)
+	assert.Equal(t, "true", gsSet.ObjectMeta.Labels[FleetCanaryLabel])
+	assert.Equal(t, "fleet", gsSet.ObjectMeta.Labels[FleetNameLabel])
+	assert.True(t, metav1.IsControlledBy(gsSet, f))
+	assert.Equal(t, "testing/image", f.Spec.Template.Spec.Template.Spec.Containers[0].Image)
+
+	causes, ok := f.Validate()
+	assert.True(t, ok)
+	assert.Len(t, causes, 0)
+
+	f.Spec.Canary.Weight = 100
+	f.Spec.Canary.Template.Spec.Template.Spec.Containers = nil
+	causes, ok = f.Validate()
+	assert.False(t, ok)
+	if assert.Len(t, causes, 2) {
+		assert.Equal(t, "canary.weight", causes[0].Field)
+		assert.Equal(t, ErrCanaryWeightInvalid, causes[0].Message)
+		assert.Equal(t, "canary.template.spec.container", causes[1].Field)
+	}
+}
+
+func TestFleetProgressDeadline(t *testing.T) {
+	f := defaultFleet()
+	assert.Equal(t, 10*time.Minute, f.ProgressDeadline())
+
+	seconds := int32(30)
+	f.Spec.ProgressDeadlineSeconds = &seconds
+	assert.Equal(t, 30*time.Second, f.ProgressDeadline())
+	causes, ok := f.Validate()
+	assert.True(t, ok)
+	assert.Len(t, causes, 0)
+
+	seconds = 0
+	causes, ok = f.Validate()
+	assert.False(t, ok)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, "progressDeadlineSeconds", causes[0].Field)
+		assert.Equal(t, ErrProgressDeadlineInvalid, causes[0].Message)
+	}
+}
+
+func TestFleetStatusConditions(t *testing.T) {
+	s := &FleetStatus{}
+	assert.Nil(t, s.Condition(FleetProgressing))
+
+	assert.True(t, s.SetCondition(FleetProgressing, corev1.ConditionTrue, "GameServerSetUpdated", "progressing"))
+	c := s.Condition(FleetProgressing)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, corev1.ConditionTrue, c.Status)
+		assert.Equal(t, "GameServerSetUpdated", c.Reason)
+		assert.Equal(t, "progressing", c.Message)
+		assert.False(t, c.LastTransitionTime.IsZero())
+	}
+	assert.False(t, s.SetCondition(FleetProgressing, corev1.ConditionTrue, "GameServerSetUpdated", "progressing"))
+
+	transition := metav1.NewTime(time.Now().Add(-time.Hour))
+	c.LastTransitionTime = transition
+	c.LastUpdateTime = transition
+	assert.True(t, s.SetCondition(FleetProgressing, corev1.ConditionTrue, "NewGameServerSetAvailable", "done"))
+	assert.Equal(t, transition, c.LastTransitionTime)
+	assert.True(t, c.LastUpdateTime.After(transition.Time))
+
+	assert.True(t, s.SetCondition(FleetProgressing, corev1.ConditionFalse, "ProgressDeadlineExceeded", "stalled"))
+	assert.True(t, c.LastTransitionTime.After(transition.Time))
+
+	assert.True(t, s.SetCondition(FleetReplicaFailure, corev1.ConditionTrue, "FailedCreate", "quota"))
+	assert.Len(t, s.Conditions, 2)
+	s.RemoveCondition(FleetReplicaFailure)
+	assert.Len(t, s.Conditions, 1)
+	assert.Nil(t, s.Condition(FleetReplicaFailure))
+	s.RemoveCondition(FleetReplicaFailure)
+	assert.Len(t, s.Conditions, 1)
+}
+
+func TestFleetInUpdateWindow(t *testing.T) {
+	t.Parallel()
+
+	// a Wednesday
+	wed := time.Date(2019, time.July, 17, 0, 0, 0, 0, time.UTC)
+
+	f := defaultFleet()
+	open, wait := f.InUpdateWindow(wed)
+	assert.True(t, open)
+	assert.Equal(t, time.Duration(0), wait)
+
+	f.Spec.UpdateWindows = []UpdateWindow{
+		{Schedule: "0 2 * * 1-5", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+		{Schedule: "bad", Duration: metav1.Duration{Duration: time.Hour}},
+	}
+
+	open, wait = f.InUpdateWindow(wed.Add(time.Hour))
+	assert.False(t, open)
+	assert.Equal(t, time.Hour, wait)
+
+	open, _ = f.InUpdateWindow(wed.Add(2 * time.Hour))
+	assert.True(t, open)
+	open, _ = f.InUpdateWindow(wed.Add(5*time.Hour + 59*time.Minute))
+	assert.True(t, open)
+
+	open, wait = f.InUpdateWindow(wed.Add(6 * time.Hour))
+	assert.False(t, open)
+	assert.Equal(t, 20*time.Hour, wait)
+
+	// Friday after the window, to Monday
+	open, wait = f.InUpdateWindow(wed.AddDate(0, 0, 2).Add(12 * time.Hour))
+	assert.False(t, open)
+	assert.Equal(t, 62*time.Hour, wait)
+
+	f.Spec.UpdateWindows = append(f.Spec.UpdateWindows, UpdateWindow{Schedule: "30 1 * * *", Duration: metav1.Duration{Duration: time.Hour}})
+	open, wait = f.InUpdateWindow(wed.Add(time.Hour))
+	assert.False(t, open)
+	assert.Equal(t, 30*time.Minute, wait)
+	open, _ = f.InUpdateWindow(wed.Add(90 * time.Minute))
+	assert.True(t, open)
+}
+
+func TestFleetDeleteProtection(t *testing.T) {
+	f := defaultFleet()
+	f.ApplyDefaults()
+
+	causes, ok := f.ValidateDeletion()
+	assert.True(t, ok)
+	assert.Len(t, causes, 0)
+
+	f.ObjectMeta.Annotations[FleetDeleteProtectionAnnotation] = FleetDeleteProtectionAlways
+	causes, ok = f.Validate()
+	assert.True(t, ok)
+	assert.Len(t, causes, 0)
+	causes, ok = f.ValidateDeletion()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "metadata.annotations."+FleetDeleteProtectionAnnotation, causes[0].Field)
+
+	f.ObjectMeta.Annotations[FleetForceDeleteAnnotation] = "true"
+	_, ok = f.ValidateDeletion()
+	assert.False(t, ok)
+
+	f.ObjectMeta.Annotations[FleetDeleteProtectionAnnotation] = FleetDeleteProtectionAllocated
+	delete(f.ObjectMeta.Annotations, FleetForceDeleteAnnotation)
+	_, ok = f.ValidateDeletion()
+	assert.True(t, ok)
+
+	f.Status.AllocatedReplicas = 2
+	causes, ok = f.ValidateDeletion()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+
+	f.ObjectMeta.Annotations[FleetForceDeleteAnnotation] = "true"
+	_, ok = f.ValidateDeletion()
+	assert.True(t, ok)
+
+	f.ObjectMeta.Annotations[FleetDeleteProtectionAnnotation] = "FLERG"
+	causes, ok = f.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "metadata.annotations."+FleetDeleteProtectionAnnotation, causes[0].Field)
+	_, ok = f.ValidateDeletion()
+	assert.False(t, ok)
+}
+
 func TestSumStatusReplicas(t *testing.T) {
 	fixture := []*GameServerSet{
 		{Status: GameServerSetStatus{Replicas: 10}},
@@ -0,0 +1,145 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"agones.dev/agones/pkg/testing/fuzzer"
+	"github.com/mattbaird/jsonpatch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/diff"
+)
+
+const fuzzIterations = 100
+
+// defaultable is an API type that the mutating webhooks apply defaults to
+type defaultable interface {
+	runtime.Object
+	ApplyDefaults()
+}
+
+func fuzzFixtures() map[string]func() defaultable {
+	return map[string]func() defaultable{
+		"GameServer":    func() defaultable { return &GameServer{} },
+		"Fleet":         func() defaultable { return &Fleet{} },
+		"GameServerSet": func() defaultable { return &GameServerSet{} },
+	}
+}
+
+func TestFuzzRoundTrip(t *testing.T) {
+	t.Parallel()
+	seed := fuzzer.Seed()
+	f := fuzzer.New(seed)
+
+	for name, fixture := range fuzzFixtures() {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < fuzzIterations; i++ {
+				obj := fixture()
+				f.Fuzz(obj)
+
+				assert.True(t, equality.Semantic.DeepEqual(obj, obj.DeepCopyObject()), "DeepCopy is not equal, seed %d", seed)
+
+				raw, err := json.Marshal(obj)
+				require.NoError(t, err, "seed %d", seed)
+				result := fixture()
+				require.NoError(t, json.Unmarshal(raw, result), "seed %d", seed)
+				if !assert.True(t, equality.Semantic.DeepEqual(obj, result), "JSON round trip is not equal, seed %d", seed) {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestFuzzApplyDefaults(t *testing.T) {
+	t.Parallel()
+	seed := fuzzer.Seed()
+	f := fuzzer.New(seed)
+
+	for name, fixture := range fuzzFixtures() {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < fuzzIterations; i++ {
+				obj := fixture()
+				f.Fuzz(obj)
+
+				require.NotPanics(t, obj.ApplyDefaults, "seed %d", seed)
+				once := obj.DeepCopyObject()
+				obj.ApplyDefaults()
+				if !assert.True(t, equality.Semantic.DeepEqual(once, obj), "ApplyDefaults is not idempotent, seed %d", seed) {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestFuzzGameServerPod(t *testing.T) {
+	t.Parallel()
+	seed := fuzzer.Seed()
+	f := fuzzer.New(seed)
+
+	for i := 0; i < fuzzIterations; i++ {
+		gs := &GameServer{}
+		f.Fuzz(gs)
+		gs.ApplyDefaults()
+
+		require.NotPanics(t, func() { gs.Validate() }, "seed %d", seed) // nolint: errcheck
+		require.NotPanics(t, func() {
+			pod, err := gs.Pod(corev1.Container{Name: "sidecar", Image: "sidecar:latest"})
+			if err == nil {
+				assert.Equal(t, gs.ObjectMeta.Name, pod.ObjectMeta.Name)
+			}
+		}, "seed %d", seed)
+	}
+}
+
+func TestFuzzWebhookPatch(t *testing.T) {
+	t.Parallel()
+	seed := fuzzer.Seed()
+	f := fuzzer.New(seed)
+
+	for name, fixture := range fuzzFixtures() {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < fuzzIterations; i++ {
+				obj := fixture()
+				f.Fuzz(obj)
+
+				// what the mutation handlers do
+				original, err := json.Marshal(obj)
+				require.NoError(t, err, "seed %d", seed)
+				obj.ApplyDefaults()
+				defaulted, err := json.Marshal(obj)
+				require.NoError(t, err, "seed %d", seed)
+				patch, err := jsonpatch.CreatePatch(original, defaulted)
+				require.NoError(t, err, "seed %d", seed)
+
+				// what the api server does
+				patched, err := fuzzer.ApplyJSONPatch(original, patch)
+				require.NoError(t, err, "seed %d", seed)
+				result := fixture()
+				require.NoError(t, json.Unmarshal(patched, result), "seed %d", seed)
+				if !assert.True(t, equality.Semantic.DeepEqual(obj, result), "patched object is not the defaulted object, seed %d: %s %v", seed, diff.ObjectReflectDiff(obj, result), patch) {
+					return
+				}
+			}
+		})
+	}
+}
@@ -18,6 +18,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"net/url"
+	"path"
+	"reflect"
+	"strconv"
+	"time"
 
 	"github.com/mattbaird/jsonpatch"
 
@@ -28,6 +33,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 const (
@@ -69,6 +75,83 @@ const (
 	// Passthrough dynamically sets the `containerPort` to the same value as the dynamically selected hostPort.
 	// This will mean that users will need to lookup what port has been opened through the server side SDK.
 	Passthrough PortPolicy = "Passthrough"
+	// None PortPolicy means that no HostPort is opened, and game clients connect to the ContainerPort
+	// on the IP of the GameServer's Pod, which is then its address. This is for clusters
+	// whose Pod IPs can be routed to by game clients, such as on bare metal.
+	None PortPolicy = "None"
+
+	// DefaultPortRange is the name of the port range set by the controller's MIN_PORT and MAX_PORT,
+	// which Dynamic and Passthrough ports are allocated from, unless they name another port range
+	DefaultPortRange = "default"
+
+	// ProtocolTCPUDP Protocol exposes the same hostPort and containerPort over both TCP and UDP
+	ProtocolTCPUDP corev1.Protocol = "TCPUDP"
+
+	// ReadinessSDK means the GameServer moves to Ready when the game server process
+	// calls SDK.Ready()
+	ReadinessSDK ReadinessStrategy = "SDK"
+	// ReadinessPod means the GameServer moves to Ready when its backing Pod is reported as Ready
+	// by Kubernetes (i.e. all readiness probes pass), so that game server binaries that have no
+	// SDK integration can still be managed by Agones
+	ReadinessPod ReadinessStrategy = "Pod"
+
+	// RestartNever means the Pod of a GameServer is never recreated, and the GameServer
+	// stays Unhealthy once its game server container fails
+	RestartNever RestartPolicy = "Never"
+	// RestartOnFailure means the Pod of a standalone GameServer is recreated when its game server
+	// container fails, with an exponential back-off, up to the GameServer's BackoffLimit
+	RestartOnFailure RestartPolicy = "OnFailure"
+	// RestartInPlace means the game server container of a GameServer is restarted in its Pod by the kubelet
+	// when it fails its health checks or exits, and the GameServer moves back to Scheduled, rather than
+	// becoming Unhealthy, up to the GameServer's BackoffLimit. This applies to GameServers owned by a GameServerSet too.
+	RestartInPlace RestartPolicy = "InPlace"
+	// EvictionSafeAlways means the game server can be evicted at any time, by the cluster autoscaler,
+	// as well as by node upgrades and drains, as it handles SIGTERM within its termination grace period
+	EvictionSafeAlways EvictionSafe = "Always"
+	// EvictionSafeOnUpgrade means the game server can be evicted by node upgrades and drains,
+	// but not by the cluster autoscaler
+	EvictionSafeOnUpgrade EvictionSafe = "OnUpgrade"
+	// EvictionSafeNever means the game server is never evicted, neither by the cluster autoscaler,
+	// nor by node upgrades and drains, which are held off by a PodDisruptionBudget until it exits
+	EvictionSafeNever EvictionSafe = "Never"
+
+	// PreReadyFail means a GameServer stays RequestReady, and its PreReady webhook is retried,
+	// if the webhook can't be reached or does not respond within its timeout
+	PreReadyFail PreReadyFailurePolicy = "Fail"
+	// PreReadyIgnore means a GameServer moves to Ready anyway if its PreReady webhook can't be reached
+	// or does not respond within its timeout
+	PreReadyIgnore PreReadyFailurePolicy = "Ignore"
+	// DefaultPreReadyTimeoutSeconds is how long the PreReady webhook of a GameServer is waited on,
+	// unless it sets a TimeoutSeconds
+	DefaultPreReadyTimeoutSeconds = 10
+	// MaxPreReadyTimeoutSeconds is the longest a PreReady webhook can be waited on
+	MaxPreReadyTimeoutSeconds = 30
+	// DefaultPreReadyServicePort is the port of the Service of a PreReady webhook, unless it sets a Port
+	DefaultPreReadyServicePort = 80
+
+	// SDKTokenAuto means the game server container is only given the token of the Pod's service account
+	// if the Pod template sets its serviceAccountName, as it otherwise runs as the SDK service account
+	SDKTokenAuto SDKTokenMount = "Auto"
+	// SDKTokenEnabled means the game server container is always given the token of the Pod's service account,
+	// which is the SDK service account, unless the Pod template sets its serviceAccountName
+	SDKTokenEnabled SDKTokenMount = "Enabled"
+	// SDKTokenDisabled means the game server container is never given the token of the Pod's service account
+	SDKTokenDisabled SDKTokenMount = "Disabled"
+	// SDKTokenProjected means the game server container is not given the token of the Pod's service account,
+	// but a token projected for the SDKToken audience instead, e.g. to authenticate with an external service
+	SDKTokenProjected SDKTokenMount = "Projected"
+	// DefaultSDKTokenExpirationSeconds is how long a projected token is valid for, unless the GameServer
+	// sets an ExpirationSeconds. The kubelet refreshes the token before it expires.
+	DefaultSDKTokenExpirationSeconds = 3600
+	// MinSDKTokenExpirationSeconds is the shortest a projected token can be valid for
+	MinSDKTokenExpirationSeconds = 600
+	// SDKTokenProjectedPath is the path of the projected token in the game server container
+	SDKTokenProjectedPath = "/var/run/secrets/agones.dev/token"
+
+	// DefaultBackoffLimit is the number of times the Pod of a GameServer with the OnFailure
+	// RestartPolicy is recreated, or its game server container restarted with the InPlace
+	// RestartPolicy, unless the GameServer sets a BackoffLimit
+	DefaultBackoffLimit = 6
 
 	// RoleLabel is the label in which the Agones role is specified.
 	// Pods from a GameServer will have the value "gameserver"
@@ -84,6 +167,45 @@ const (
 	// DevAddressAnnotation is an annotation to indicate that a GameServer hosted outside of Agones.
 	// A locally hosted GameServer is not managed by Agones it is just simply registered.
 	DevAddressAnnotation = "stable.agones.dev/dev-address"
+	// DevStateAnnotation is an annotation to set the state that a GameServer with the DevAddressAnnotation
+	// starts in, which is one of Scheduled, Ready or Allocated. Defaults to Ready.
+	DevStateAnnotation = stable.GroupName + "/dev-state"
+	// DevHealthCheckAnnotation is an annotation to health check a GameServer with the DevAddressAnnotation,
+	// through the /gshealthz endpoint of the local SDK server. Its value is the http port of the local SDK server.
+	DevHealthCheckAnnotation = stable.GroupName + "/dev-health-check"
+	// ErrorTimeAnnotation is the annotation that stores when a GameServer moved to the Error state, in RFC3339 format
+	ErrorTimeAnnotation = stable.GroupName + "/error-time"
+	// ErrorMessageAnnotation is the annotation that stores why a GameServer moved to the Error state
+	ErrorMessageAnnotation = stable.GroupName + "/error-message"
+	// UnhealthyRetentionAnnotation is the annotation, e.g. set through a Fleet's template, that overrides how
+	// long an Unhealthy GameServer owned by a GameServerSet is retained before it is deleted, as a duration such as "30m"
+	UnhealthyRetentionAnnotation = stable.GroupName + "/unhealthy-retention"
+	// UnhealthyTimeAnnotation is the annotation that stores when a retained Unhealthy GameServer
+	// was first seen Unhealthy by its GameServerSet, in RFC3339 format
+	UnhealthyTimeAnnotation = stable.GroupName + "/unhealthy-time"
+	// PodSafeToEvictAnnotation is the annotation on a Pod that tells the cluster autoscaler
+	// whether it can evict the Pod when scaling down the Pod's node
+	PodSafeToEvictAnnotation = "cluster-autoscaler.kubernetes.io/safe-to-evict"
+	// SafeToEvictLabel is the label on the Pod of a GameServer with the Never Eviction, which the
+	// PodDisruptionBudget that holds off node upgrades and drains selects
+	SafeToEvictLabel = stable.GroupName + "/safe-to-evict"
+	// PodAllocatedLabel is the label that is set to "true" on the Pod of an Allocated GameServer,
+	// so that the PodDisruptionBudget of its Fleet can select it
+	PodAllocatedLabel = stable.GroupName + "/allocated"
+	// SafeToEvictPolicyAnnotation is the annotation, e.g. set through a Fleet's template, that sets how the
+	// PodSafeToEvictAnnotation of the Pod of a Packed GameServer is managed. Defaults to "Managed".
+	SafeToEvictPolicyAnnotation = stable.GroupName + "/safe-to-evict-policy"
+	// SafeToEvictManaged means the Pod of a Packed GameServer can be evicted by the cluster autoscaler
+	// while the GameServer is Scheduled, RequestReady or Ready, but not otherwise, such as while it is Allocated
+	SafeToEvictManaged = "Managed"
+	// SafeToEvictNever means the Pod of a Packed GameServer can never be evicted by the cluster autoscaler
+	SafeToEvictNever = "Never"
+	// TopologyZoneLabel is the label of the zone of a node, which is copied onto a GameServer
+	// from the node that it is scheduled on, so GameServers can be selected and counted by zone
+	TopologyZoneLabel = "topology.kubernetes.io/zone"
+	// TopologyRegionLabel is the label of the region of a node, which is copied onto a GameServer
+	// from the node that it is scheduled on, so GameServers can be selected and counted by region
+	TopologyRegionLabel = "topology.kubernetes.io/region"
 )
 
 var (
@@ -130,16 +252,267 @@ type GameServerSpec struct {
 	Health Health `json:"health,omitempty"`
 	// Scheduling strategy. Defaults to "Packed".
 	Scheduling apis.SchedulingStrategy `json:"scheduling,omitempty"`
+	// Readiness defines what moves the GameServer to Ready. Defaults to "SDK".
+	// When set to "Pod", the GameServer is marked Ready once its Pod is Ready, and SDK health checking is disabled.
+	Readiness ReadinessStrategy `json:"readiness,omitempty"`
+	// Counters are the initial values of the GameServer's counters, such as the number of rooms in use.
+	// Once the GameServer is created, they are maintained in the GameServer's status through the SDK.
+	Counters map[string]CounterStatus `json:"counters,omitempty"`
+	// Lists are the initial values of the GameServer's lists, such as the ids of connected players.
+	// Once the GameServer is created, they are maintained in the GameServer's status through the SDK.
+	Lists map[string]ListStatus `json:"lists,omitempty"`
+	// Players configures player tracking through the SDK
+	Players *PlayerSpec `json:"players,omitempty"`
+	// RestartPolicy defines whether the Pod of a GameServer that is not owned by a GameServerSet is recreated
+	// when its game server container fails. Defaults to "Never". GameServerSets replace failed GameServers instead,
+	// unless the RestartPolicy is "InPlace", which restarts the game server container within its Pod.
+	RestartPolicy RestartPolicy `json:"restartPolicy,omitempty"`
+	// BackoffLimit is the number of times the Pod is recreated with the OnFailure RestartPolicy,
+	// or the game server container is restarted with the InPlace RestartPolicy,
+	// before the GameServer stays Unhealthy. Defaults to 6.
+	BackoffLimit int32 `json:"backoffLimit,omitempty"`
+	// Eviction sets whether the game server can be evicted by the cluster autoscaler, and by node upgrades and drains.
+	// If not set, the cluster autoscaler can only evict the Pod of a Packed GameServer as per its
+	// SafeToEvictPolicyAnnotation.
+	Eviction *Eviction `json:"eviction,omitempty"`
+	// PreReady is an optional webhook that is called before the GameServer moves from RequestReady to Ready,
+	// e.g. to register the game server with an external directory
+	PreReady *PreReadyWebhook `json:"preReady,omitempty"`
+	// SDKToken configures which Kubernetes API token the game server container is given.
+	// If not set, it is only given the token of the Pod's service account if the Pod template
+	// sets its serviceAccountName.
+	SDKToken *SDKToken `json:"sdkToken,omitempty"`
 	// Template describes the Pod that will be created for the GameServer
 	Template corev1.PodTemplateSpec `json:"template"`
 }
 
+// CounterStatus is the current count of a counter, and how high it can go
+type CounterStatus struct {
+	// Count is the current count. Must be between 0 and Capacity
+	Count int64 `json:"count"`
+	// Capacity is the maximum value of Count
+	Capacity int64 `json:"capacity"`
+}
+
+// Available returns how much the counter can still be incremented by
+func (c CounterStatus) Available() int64 {
+	return c.Capacity - c.Count
+}
+
+// Validate returns an error if the count is negative, or more than the capacity
+func (c CounterStatus) Validate() error {
+	if c.Count < 0 || c.Count > c.Capacity {
+		return errors.New(ErrCounterInvalid)
+	}
+	return nil
+}
+
+// ListStatus is the current set of values of a list, and how many values it can hold
+type ListStatus struct {
+	// Capacity is the maximum number of Values
+	Capacity int64 `json:"capacity"`
+	// Values in the list
+	Values []string `json:"values,omitempty"`
+}
+
+// Available returns how many more values the list can hold
+func (l ListStatus) Available() int64 {
+	return l.Capacity - int64(len(l.Values))
+}
+
+// Validate returns an error if the list holds more values than its capacity, or holds duplicate values
+func (l ListStatus) Validate() error {
+	if l.Available() < 0 {
+		return errors.New(ErrListInvalid)
+	}
+	seen := make(map[string]bool, len(l.Values))
+	for _, v := range l.Values {
+		if seen[v] {
+			return errors.New(ErrListInvalid)
+		}
+		seen[v] = true
+	}
+	return nil
+}
+
+// Contains returns true if the list holds the value
+func (l ListStatus) Contains(value string) bool {
+	for _, v := range l.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// EvictionSafe is whether a game server can be evicted
+type EvictionSafe string
+
+// Eviction is how disruptable the game sessions of a GameServer are
+type Eviction struct {
+	// Safe is whether the game server can be evicted, one of "Always", "OnUpgrade" or "Never". Defaults to "Never".
+	Safe EvictionSafe `json:"safe,omitempty"`
+}
+
+// SDKTokenMount is which Kubernetes API token the game server container is given
+type SDKTokenMount string
+
+// SDKToken configures which Kubernetes API token the game server container is given. The SDK sidecar
+// always runs as the Pod's service account, which is the SDK service account unless the Pod template
+// sets its serviceAccountName.
+type SDKToken struct {
+	// Mount is one of "Auto", "Enabled", "Disabled" or "Projected". Defaults to "Auto".
+	Mount SDKTokenMount `json:"mount,omitempty"`
+	// Audience is the intended audience of the projected token. Only, and always, required with the Projected Mount.
+	Audience string `json:"audience,omitempty"`
+	// ExpirationSeconds is how long the projected token is valid for. Must be at least 600. Defaults to 3600.
+	ExpirationSeconds int64 `json:"expirationSeconds,omitempty"`
+}
+
+// PreReadyFailurePolicy is what happens when the PreReady webhook of a GameServer can't be reached,
+// or does not respond in time
+type PreReadyFailurePolicy string
+
+// PreReadyWebhook is a webhook that is sent a POST request with the GameServer as its JSON body,
+// once the GameServer has requested to be Ready. The GameServer only moves to Ready once the webhook
+// responds with a 200 status code, or as per its FailurePolicy.
+type PreReadyWebhook struct {
+	// Service is the Service of the webhook. Only Services in the namespace of the GameServer can be
+	// called, so that GameServers can't have the controller send requests to arbitrary hosts.
+	Service PreReadyService `json:"service"`
+	// TimeoutSeconds is how long to wait for the webhook to respond. Defaults to 10 seconds, and can be at most 30.
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// FailurePolicy is what happens if the webhook can't be reached, or does not respond within TimeoutSeconds,
+	// one of "Fail" or "Ignore". Defaults to "Fail". A response with any status code other than 200
+	// always keeps the GameServer RequestReady, and the webhook is retried.
+	FailurePolicy PreReadyFailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// PreReadyService is a reference to the Service of a PreReady webhook, in the namespace of the GameServer,
+// which is sent requests at `http://name.namespace.svc:port/path`
+type PreReadyService struct {
+	// Name of the Service
+	Name string `json:"name"`
+	// Port of the Service. Defaults to 80.
+	Port int32 `json:"port,omitempty"`
+	// Path of the webhook on the Service, e.g. /register
+	Path string `json:"path,omitempty"`
+}
+
+// PlayerSpec configures player tracking on the GameServer
+type PlayerSpec struct {
+	// InitialCapacity is the player capacity of the GameServer when it is created
+	InitialCapacity int64 `json:"initialCapacity,omitempty"`
+}
+
+// PlayerStatus is the players currently connected to the GameServer, as
+// tracked through the SDK
+type PlayerStatus struct {
+	// Count is the number of connected players
+	Count int64 `json:"count"`
+	// Capacity is the maximum number of players that can connect
+	Capacity int64 `json:"capacity"`
+	// IDs of the connected players
+	IDs []string `json:"ids,omitempty"`
+}
+
+// AggregatedPlayerStatus is the total player count and capacity
+// of a group of GameServers
+type AggregatedPlayerStatus struct {
+	// Count is the total number of connected players
+	Count int64 `json:"count"`
+	// Capacity is the total player capacity
+	Capacity int64 `json:"capacity"`
+}
+
+// Add adds the count and capacity of the PlayerStatus to the aggregate
+func (a *AggregatedPlayerStatus) Add(p *PlayerStatus) {
+	a.Count += p.Count
+	a.Capacity += p.Capacity
+}
+
+// Merge adds the count and capacity of another aggregate, such as a GameServerSet's, to the aggregate
+func (a *AggregatedPlayerStatus) Merge(o AggregatedPlayerStatus) {
+	a.Count += o.Count
+	a.Capacity += o.Capacity
+}
+
+// AggregatedCounterStatus is the total count and capacity of a counter
+// across a group of GameServers, and of the Allocated ones amongst them
+type AggregatedCounterStatus struct {
+	// AllocatedCount is the total count of the counter of the Allocated GameServers
+	AllocatedCount int64 `json:"allocatedCount"`
+	// AllocatedCapacity is the total capacity of the counter of the Allocated GameServers
+	AllocatedCapacity int64 `json:"allocatedCapacity"`
+	// Count is the total count of the counter
+	Count int64 `json:"count"`
+	// Capacity is the total capacity of the counter
+	Capacity int64 `json:"capacity"`
+}
+
+// Add adds the count and capacity of the counter of a GameServer to the aggregate
+func (a *AggregatedCounterStatus) Add(c CounterStatus, allocated bool) {
+	a.Count += c.Count
+	a.Capacity += c.Capacity
+	if allocated {
+		a.AllocatedCount += c.Count
+		a.AllocatedCapacity += c.Capacity
+	}
+}
+
+// Merge adds another aggregate to the aggregate
+func (a *AggregatedCounterStatus) Merge(o AggregatedCounterStatus) {
+	a.AllocatedCount += o.AllocatedCount
+	a.AllocatedCapacity += o.AllocatedCapacity
+	a.Count += o.Count
+	a.Capacity += o.Capacity
+}
+
+// AggregatedListStatus is the total number of values and capacity of a list
+// across a group of GameServers, and of the Allocated ones amongst them
+type AggregatedListStatus struct {
+	// AllocatedCount is the total number of values in the list of the Allocated GameServers
+	AllocatedCount int64 `json:"allocatedCount"`
+	// AllocatedCapacity is the total capacity of the list of the Allocated GameServers
+	AllocatedCapacity int64 `json:"allocatedCapacity"`
+	// Count is the total number of values in the list
+	Count int64 `json:"count"`
+	// Capacity is the total capacity of the list
+	Capacity int64 `json:"capacity"`
+}
+
+// Add adds the number of values and capacity of the list of a GameServer to the aggregate
+func (a *AggregatedListStatus) Add(l ListStatus, allocated bool) {
+	count := int64(len(l.Values))
+	a.Count += count
+	a.Capacity += l.Capacity
+	if allocated {
+		a.AllocatedCount += count
+		a.AllocatedCapacity += l.Capacity
+	}
+}
+
+// Merge adds another aggregate to the aggregate
+func (a *AggregatedListStatus) Merge(o AggregatedListStatus) {
+	a.AllocatedCount += o.AllocatedCount
+	a.AllocatedCapacity += o.AllocatedCapacity
+	a.Count += o.Count
+	a.Capacity += o.Capacity
+}
+
 // GameServerState is the state for the GameServer
 type GameServerState string
 
 // PortPolicy is the port policy for the GameServer
 type PortPolicy string
 
+// ReadinessStrategy is what determines when a GameServer becomes Ready
+type ReadinessStrategy string
+
+// RestartPolicy is what determines whether the Pod of a failed GameServer is recreated,
+// or its game server container restarted
+type RestartPolicy string
+
 // Health configures health checking on the GameServer
 type Health struct {
 	// Disabled is whether health checking is disabled or not
@@ -150,6 +523,20 @@ type Health struct {
 	FailureThreshold int32 `json:"failureThreshold,omitempty"`
 	// InitialDelaySeconds initial delay before checking health
 	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+	// ShutdownExitCodes are the exit codes of the game server container that are an expected shutdown,
+	// such as at the end of a match, which move the GameServer to Shutdown rather than Unhealthy
+	ShutdownExitCodes []int32 `json:"shutdownExitCodes,omitempty"`
+}
+
+// IsShutdownExitCode returns if the game server container exiting with the given code
+// is an expected shutdown
+func (h *Health) IsShutdownExitCode(code int32) bool {
+	for _, c := range h.ShutdownExitCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
 }
 
 // GameServerPort defines a set of Ports that
@@ -157,17 +544,26 @@ type Health struct {
 type GameServerPort struct {
 	// Name is the descriptive name of the port
 	Name string `json:"name,omitempty"`
+	// Container is the name of the container in the pod template that the port is opened on,
+	// such as a sidecar. Defaults to the game server container.
+	Container string `json:"container,omitempty"`
 	// PortPolicy defines the policy for how the HostPort is populated.
 	// Dynamic port will allocate a HostPort within the selected MIN_PORT and MAX_PORT range passed to the controller
 	// at installation time.
 	// When `Static` portPolicy is specified, `HostPort` is required, to specify the port that game clients will
-	// connect to
+	// connect to.
+	// When `None` portPolicy is specified, no HostPort is allocated, and game clients connect to the
+	// ContainerPort on the IP of the Pod
 	PortPolicy PortPolicy `json:"portPolicy,omitempty"`
 	// ContainerPort is the port that is being opened on the game server process
 	ContainerPort int32 `json:"containerPort,omitempty"`
 	// HostPort the port exposed on the host for clients to connect to
 	HostPort int32 `json:"hostPort,omitempty"`
-	// Protocol is the network protocol being used. Defaults to UDP. TCP is the only other option
+	// Range is the name of the port range that a Dynamic or Passthrough port is allocated from.
+	// Defaults to the default port range, set by the MIN_PORT and MAX_PORT passed to the controller.
+	// Other port ranges are configured on the controller at installation time.
+	Range string `json:"range,omitempty"`
+	// Protocol is the network protocol being used. Defaults to UDP. TCP and TCPUDP are other options
 	Protocol corev1.Protocol `json:"protocol,omitempty"`
 }
 
@@ -179,6 +575,99 @@ type GameServerStatus struct {
 	Address       string                 `json:"address"`
 	NodeName      string                 `json:"nodeName"`
 	ReservedUntil *metav1.Time           `json:"reservedUntil"`
+	// Addresses are all the addresses of the Node the GameServer is running on, such as its IPv4 and IPv6 addresses.
+	// Address is the one of these picked by the controller's node address priority.
+	// Not set when the ports of the GameServer have the None PortPolicy, as Address is then the IP of its Pod
+	Addresses []corev1.NodeAddress `json:"addresses,omitempty"`
+	// Counters are the current values of the counters defined in the spec, or set through the SDK
+	Counters map[string]CounterStatus `json:"counters,omitempty"`
+	// Lists are the current values of the lists defined in the spec, or set through the SDK
+	Lists map[string]ListStatus `json:"lists,omitempty"`
+	// Players are the players connected through the SDK. Only set if `spec.players` is set
+	Players *PlayerStatus `json:"players,omitempty"`
+	// Restarts is the number of times the Pod of the GameServer has been recreated, or its
+	// game server container restarted, through its RestartPolicy
+	Restarts int32 `json:"restarts,omitempty"`
+	// SDK is the state of the game server process' connection to the SDK, as reported by the SDK server.
+	// Not set until the game server process first calls the SDK
+	SDK *SDKStatus `json:"sdk,omitempty"`
+	// Conditions are the latest observations of the GameServer's progress towards being Ready,
+	// maintained by the controller
+	Conditions []GameServerCondition `json:"conditions,omitempty"`
+}
+
+// SDKStatus is the state of the game server process' connection to the SDK
+type SDKStatus struct {
+	// FirstCall is the first SDK call that the game server process made, e.g. Ready or Health
+	FirstCall string `json:"firstCall"`
+	// ConnectedTime is when the game server process first called the SDK
+	ConnectedTime metav1.Time `json:"connectedTime"`
+	// LastPingTime is when the SDK server last received a health ping from the game server process.
+	// It is only reported if the controller is configured to, and then only every so often, rather than
+	// on every ping, so it can lag behind by up to the report period
+	LastPingTime *metav1.Time `json:"lastPingTime,omitempty"`
+}
+
+// GameServerConditionType is the type of a GameServerCondition
+type GameServerConditionType string
+
+const (
+	// GameServerConditionPodReady is whether the Pod of the GameServer is Ready
+	GameServerConditionPodReady GameServerConditionType = "PodReady"
+	// GameServerConditionAddressPopulated is whether the address and ports of the GameServer
+	// have been populated from the Node its Pod was scheduled on
+	GameServerConditionAddressPopulated GameServerConditionType = "AddressPopulated"
+	// GameServerConditionSDKConnected is whether the game server process has called the SDK,
+	// mirrored from the GameServer's `status.sdk`
+	GameServerConditionSDKConnected GameServerConditionType = "SDKConnected"
+)
+
+// GameServerCondition is an observation of the state of a GameServer
+type GameServerCondition struct {
+	Type   GameServerConditionType `json:"type"`
+	Status corev1.ConditionStatus  `json:"status"`
+	// LastTransitionTime is the last time the condition's status changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a machine readable, CamelCase reason for the condition's last transition
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable description of the condition's last transition
+	Message string `json:"message,omitempty"`
+}
+
+// Condition returns the condition of the given type, or nil if there isn't one
+func (s *GameServerStatus) Condition(t GameServerConditionType) *GameServerCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == t {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition of the given type, and returns whether it changed.
+// The LastTransitionTime is only moved to now when the condition's status changes.
+func (s *GameServerStatus) SetCondition(t GameServerConditionType, status corev1.ConditionStatus, reason, message string) bool {
+	c := s.Condition(t)
+	if c == nil {
+		s.Conditions = append(s.Conditions, GameServerCondition{
+			Type:               t,
+			Status:             status,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		})
+		return true
+	}
+	if c.Status == status && c.Reason == reason && c.Message == message {
+		return false
+	}
+	if c.Status != status {
+		c.LastTransitionTime = metav1.Now()
+	}
+	c.Status = status
+	c.Reason = reason
+	c.Message = message
+	return true
 }
 
 // GameServerStatusPort shows the port that was allocated to a
@@ -196,18 +685,81 @@ func (gs *GameServer) ApplyDefaults() {
 		gs.ObjectMeta.Annotations = map[string]string{}
 	}
 	gs.ObjectMeta.Annotations[stable.VersionAnnotation] = pkg.Version
-	gs.ObjectMeta.Finalizers = append(gs.ObjectMeta.Finalizers, stable.GroupName)
+	// only add the finalizer once, so defaults can be applied more than once
+	hasFinalizer := false
+	for _, f := range gs.ObjectMeta.Finalizers {
+		if f == stable.GroupName {
+			hasFinalizer = true
+			break
+		}
+	}
+	if !hasFinalizer {
+		gs.ObjectMeta.Finalizers = append(gs.ObjectMeta.Finalizers, stable.GroupName)
+	}
 
 	gs.Spec.ApplyDefaults()
 	gs.applyStateDefaults()
+	gs.applyCounterAndListDefaults()
+	gs.applyPlayerDefaults()
 }
 
 // ApplyDefaults applies default values to the GameServerSpec if they are not already populated
 func (gss *GameServerSpec) ApplyDefaults() {
 	gss.applyContainerDefaults()
 	gss.applyPortDefaults()
+	gss.applyReadinessDefaults()
 	gss.applyHealthDefaults()
 	gss.applySchedulingDefaults()
+	gss.applyRestartDefaults()
+	gss.applyPreReadyDefaults()
+	gss.applyEvictionDefaults()
+	gss.applySDKTokenDefaults()
+}
+
+// applySDKTokenDefaults applies the SDKToken defaults, if it is set
+func (gss *GameServerSpec) applySDKTokenDefaults() {
+	if gss.SDKToken == nil {
+		return
+	}
+	if gss.SDKToken.Mount == "" {
+		gss.SDKToken.Mount = SDKTokenAuto
+	}
+	if gss.SDKToken.Mount == SDKTokenProjected && gss.SDKToken.ExpirationSeconds == 0 {
+		gss.SDKToken.ExpirationSeconds = DefaultSDKTokenExpirationSeconds
+	}
+}
+
+// applyEvictionDefaults applies the Eviction defaults, if it is set
+func (gss *GameServerSpec) applyEvictionDefaults() {
+	if gss.Eviction != nil && gss.Eviction.Safe == "" {
+		gss.Eviction.Safe = EvictionSafeNever
+	}
+}
+
+// applyPreReadyDefaults applies the PreReady webhook defaults, if there is one
+func (gss *GameServerSpec) applyPreReadyDefaults() {
+	if gss.PreReady == nil {
+		return
+	}
+	if gss.PreReady.TimeoutSeconds == 0 {
+		gss.PreReady.TimeoutSeconds = DefaultPreReadyTimeoutSeconds
+	}
+	if gss.PreReady.Service.Port == 0 {
+		gss.PreReady.Service.Port = DefaultPreReadyServicePort
+	}
+	if gss.PreReady.FailurePolicy == "" {
+		gss.PreReady.FailurePolicy = PreReadyFail
+	}
+}
+
+// applyRestartDefaults applies the restart policy defaults
+func (gss *GameServerSpec) applyRestartDefaults() {
+	if gss.RestartPolicy == "" {
+		gss.RestartPolicy = RestartNever
+	}
+	if (gss.RestartPolicy == RestartOnFailure || gss.RestartPolicy == RestartInPlace) && gss.BackoffLimit == 0 {
+		gss.BackoffLimit = DefaultBackoffLimit
+	}
 }
 
 // applyContainerDefaults applues the container defaults
@@ -217,6 +769,19 @@ func (gss *GameServerSpec) applyContainerDefaults() {
 	}
 }
 
+// applyReadinessDefaults applies readiness defaults.
+// Should be called before applyHealthDefaults(), as Pod readiness
+// disables SDK health checking.
+func (gss *GameServerSpec) applyReadinessDefaults() {
+	if gss.Readiness == "" {
+		gss.Readiness = ReadinessSDK
+	}
+	// there is no SDK to send health pings, so rely on the Pod instead
+	if gss.Readiness == ReadinessPod {
+		gss.Health.Disabled = true
+	}
+}
+
 // applyHealthDefaults applies health checking defaults
 func (gss *GameServerSpec) applyHealthDefaults() {
 	if !gss.Health.Disabled {
@@ -243,6 +808,32 @@ func (gs *GameServer) applyStateDefaults() {
 	}
 }
 
+// applyCounterAndListDefaults copies the initial counters and lists from the spec into
+// the status, unless they have already been set
+func (gs *GameServer) applyCounterAndListDefaults() {
+	if gs.Status.Counters == nil && len(gs.Spec.Counters) > 0 {
+		gs.Status.Counters = make(map[string]CounterStatus, len(gs.Spec.Counters))
+		for k, v := range gs.Spec.Counters {
+			gs.Status.Counters[k] = v
+		}
+	}
+	if gs.Status.Lists == nil && len(gs.Spec.Lists) > 0 {
+		gs.Status.Lists = make(map[string]ListStatus, len(gs.Spec.Lists))
+		for k, v := range gs.Spec.Lists {
+			v.Values = append([]string(nil), v.Values...)
+			gs.Status.Lists[k] = v
+		}
+	}
+}
+
+// applyPlayerDefaults sets the initial player status from the spec,
+// unless it has already been set
+func (gs *GameServer) applyPlayerDefaults() {
+	if gs.Status.Players == nil && gs.Spec.Players != nil {
+		gs.Status.Players = &PlayerStatus{Capacity: gs.Spec.Players.InitialCapacity}
+	}
+}
+
 // applyPortDefaults applies default values for all ports
 func (gss *GameServerSpec) applyPortDefaults() {
 	for i, p := range gss.Ports {
@@ -251,9 +842,17 @@ func (gss *GameServerSpec) applyPortDefaults() {
 			gss.Ports[i].PortPolicy = Dynamic
 		}
 
+		if p.Range == "" && gss.Ports[i].PortPolicy != Static && gss.Ports[i].PortPolicy != None {
+			gss.Ports[i].Range = DefaultPortRange
+		}
+
 		if p.Protocol == "" {
 			gss.Ports[i].Protocol = "UDP"
 		}
+
+		if p.Container == "" {
+			gss.Ports[i].Container = gss.Container
+		}
 	}
 }
 
@@ -307,7 +906,7 @@ func (gss GameServerSpec) Validate(devAddress string) ([]metav1.StatusCause, boo
 
 		// no host port when using dynamic PortPolicy
 		for _, p := range gss.Ports {
-			if p.PortPolicy == Dynamic || p.PortPolicy == Static {
+			if p.PortPolicy == Dynamic || p.PortPolicy == Static || p.PortPolicy == None {
 				if p.ContainerPort <= 0 {
 					causes = append(causes, metav1.StatusCause{
 						Type:    metav1.CauseTypeFieldValueInvalid,
@@ -332,8 +931,88 @@ func (gss GameServerSpec) Validate(devAddress string) ([]metav1.StatusCause, boo
 					Message: ErrHostPortDynamic,
 				})
 			}
+
+			if p.Range != "" && p.PortPolicy == Static {
+				causes = append(causes, metav1.StatusCause{
+					Type:    metav1.CauseTypeFieldValueInvalid,
+					Field:   fmt.Sprintf("%s.range", p.Name),
+					Message: ErrRangeStatic,
+				})
+			}
+
+			if p.PortPolicy == None {
+				if p.HostPort > 0 {
+					causes = append(causes, metav1.StatusCause{
+						Type:    metav1.CauseTypeFieldValueInvalid,
+						Field:   fmt.Sprintf("%s.hostPort", p.Name),
+						Message: ErrHostPortNone,
+					})
+				}
+				if p.Range != "" {
+					causes = append(causes, metav1.StatusCause{
+						Type:    metav1.CauseTypeFieldValueInvalid,
+						Field:   fmt.Sprintf("%s.range", p.Name),
+						Message: ErrRangeNone,
+					})
+				}
+			}
+
+			// a GameServer has a single address, which is the IP of its Pod for the None PortPolicy
+			if p.PortPolicy != None && gss.usesPodIP() {
+				causes = append(causes, metav1.StatusCause{
+					Type:    metav1.CauseTypeFieldValueInvalid,
+					Field:   fmt.Sprintf("%s.portPolicy", p.Name),
+					Message: ErrPortPolicyNoneMixed,
+				})
+			}
+
+			if p.Container != "" && gss.findContainer(p.Container) < 0 {
+				causes = append(causes, metav1.StatusCause{
+					Type:    metav1.CauseTypeFieldValueInvalid,
+					Field:   fmt.Sprintf("%s.container", p.Name),
+					Message: ErrPortContainerInvalid,
+				})
+			}
+		}
+
+		if gss.Readiness != "" && gss.Readiness != ReadinessSDK && gss.Readiness != ReadinessPod {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   "readiness",
+				Message: ErrReadinessInvalid,
+			})
+		}
+
+		if gss.RestartPolicy != "" && gss.RestartPolicy != RestartNever && gss.RestartPolicy != RestartOnFailure &&
+			gss.RestartPolicy != RestartInPlace {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   "restartPolicy",
+				Message: ErrRestartPolicyInvalid,
+			})
 		}
 
+		// the kubelet only restarts the game server container if the Pod allows it
+		if gss.RestartPolicy == RestartInPlace && gss.Template.Spec.RestartPolicy != "" &&
+			gss.Template.Spec.RestartPolicy != corev1.RestartPolicyAlways {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   "template.spec.restartPolicy",
+				Message: ErrRestartInPlacePodRestartPolicy,
+			})
+		}
+
+		if gss.BackoffLimit < 0 {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   "backoffLimit",
+				Message: ErrBackoffLimitInvalid,
+			})
+		}
+
+		// catch invalid /etc/hosts entries here, rather than when the Pod fails to be created
+		causes = append(causes, gss.validateHostAliases()...)
+
 		// make sure the container value points to a valid container
 		_, _, err := gss.FindGameServerContainer()
 		if err != nil {
@@ -344,10 +1023,157 @@ func (gss GameServerSpec) Validate(devAddress string) ([]metav1.StatusCause, boo
 			})
 		}
 	}
+
+	for name, c := range gss.Counters {
+		if err := c.Validate(); err != nil {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   fmt.Sprintf("counters.%s", name),
+				Message: err.Error(),
+			})
+		}
+	}
+
+	for name, l := range gss.Lists {
+		if err := l.Validate(); err != nil {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   fmt.Sprintf("lists.%s", name),
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if gss.Players != nil && gss.Players.InitialCapacity < 0 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "players.initialCapacity",
+			Message: ErrPlayerCapacityInvalid,
+		})
+	}
+
+	if gss.PreReady != nil {
+		causes = append(causes, gss.PreReady.validate()...)
+	}
+
+	if gss.Eviction != nil && gss.Eviction.Safe != "" && gss.Eviction.Safe != EvictionSafeAlways &&
+		gss.Eviction.Safe != EvictionSafeOnUpgrade && gss.Eviction.Safe != EvictionSafeNever {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "eviction.safe",
+			Message: ErrEvictionSafeInvalid,
+		})
+	}
+
+	if gss.SDKToken != nil {
+		causes = append(causes, gss.SDKToken.validate()...)
+	}
+
 	return causes, len(causes) == 0
 
 }
 
+// validateHostAliases validates the hostAliases of the Pod template of a GameServerSpec
+func (gss *GameServerSpec) validateHostAliases() []metav1.StatusCause {
+	var causes []metav1.StatusCause
+	for i, a := range gss.Template.Spec.HostAliases {
+		field := fmt.Sprintf("template.spec.hostAliases[%d]", i)
+		if net.ParseIP(a.IP) == nil {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   field + ".ip",
+				Message: ErrHostAliasIPInvalid,
+			})
+		}
+		valid := len(a.Hostnames) > 0
+		for _, h := range a.Hostnames {
+			if len(validation.IsDNS1123Subdomain(h)) > 0 {
+				valid = false
+			}
+		}
+		if !valid {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   field + ".hostnames",
+				Message: ErrHostAliasHostnamesInvalid,
+			})
+		}
+	}
+	return causes
+}
+
+// validate validates the PreReady webhook of a GameServerSpec
+func (w *PreReadyWebhook) validate() []metav1.StatusCause {
+	var causes []metav1.StatusCause
+	if len(validation.IsDNS1035Label(w.Service.Name)) > 0 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "preReady.service.name",
+			Message: ErrPreReadyServiceNameInvalid,
+		})
+	}
+	if w.Service.Port < 0 || w.Service.Port > 65535 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "preReady.service.port",
+			Message: ErrPreReadyServicePortInvalid,
+		})
+	}
+	if u, err := url.Parse(w.Service.Path); w.Service.Path != "" &&
+		(err != nil || u.Path != w.Service.Path || !path.IsAbs(u.Path)) {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "preReady.service.path",
+			Message: ErrPreReadyServicePathInvalid,
+		})
+	}
+	if w.TimeoutSeconds < 0 || w.TimeoutSeconds > MaxPreReadyTimeoutSeconds {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "preReady.timeoutSeconds",
+			Message: ErrPreReadyTimeoutInvalid,
+		})
+	}
+	if w.FailurePolicy != "" && w.FailurePolicy != PreReadyFail && w.FailurePolicy != PreReadyIgnore {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "preReady.failurePolicy",
+			Message: ErrPreReadyFailurePolicyInvalid,
+		})
+	}
+	return causes
+}
+
+// validate validates the mount of the SDKToken, and that only the Projected mount sets an audience
+// and expiration
+func (t *SDKToken) validate() []metav1.StatusCause {
+	var causes []metav1.StatusCause
+	switch t.Mount {
+	case "", SDKTokenAuto, SDKTokenEnabled, SDKTokenDisabled, SDKTokenProjected:
+	default:
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "sdkToken.mount",
+			Message: ErrSDKTokenMountInvalid,
+		})
+	}
+	if (t.Mount == SDKTokenProjected) != (t.Audience != "") {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "sdkToken.audience",
+			Message: ErrSDKTokenAudienceInvalid,
+		})
+	}
+	if t.ExpirationSeconds != 0 && (t.Mount != SDKTokenProjected || t.ExpirationSeconds < MinSDKTokenExpirationSeconds) {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "sdkToken.expirationSeconds",
+			Message: ErrSDKTokenExpirationInvalid,
+		})
+	}
+	return causes
+}
+
 // Validate validates the GameServer configuration.
 // If a GameServer is invalid there will be > 0 values in
 // the returned array
@@ -358,15 +1184,174 @@ func (gs *GameServer) Validate() ([]metav1.StatusCause, bool) {
 	devAddress, _ := gs.GetDevAddress()
 	gssCauses, _ := gs.Spec.Validate(devAddress)
 	causes = append(causes, gssCauses...)
+	causes = append(causes, gs.validateDevState()...)
+	causes = append(causes, gs.validateDevHealthCheck()...)
+	causes = append(causes, validateUnhealthyRetention(gs.ObjectMeta.Annotations, "annotations")...)
+	causes = append(causes, validateSafeToEvictPolicy(gs.ObjectMeta.Annotations, "annotations")...)
 	return causes, len(causes) == 0
 }
 
+// validateUnhealthyRetention validates the UnhealthyRetentionAnnotation, if the annotations have one
+func validateUnhealthyRetention(annotations map[string]string, field string) []metav1.StatusCause {
+	v, ok := annotations[UnhealthyRetentionAnnotation]
+	if !ok {
+		return nil
+	}
+	if d, err := time.ParseDuration(v); err != nil || d < 0 {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   fmt.Sprintf("%s.%s", field, UnhealthyRetentionAnnotation),
+			Message: fmt.Sprintf("Value '%s' of annotation '%s' must be a duration that is not negative, such as 30m", v, UnhealthyRetentionAnnotation),
+		}}
+	}
+	return nil
+}
+
+// validateSafeToEvictPolicy validates the SafeToEvictPolicyAnnotation, if the annotations have one
+func validateSafeToEvictPolicy(annotations map[string]string, field string) []metav1.StatusCause {
+	v, ok := annotations[SafeToEvictPolicyAnnotation]
+	if !ok || v == SafeToEvictManaged || v == SafeToEvictNever {
+		return nil
+	}
+	return []metav1.StatusCause{{
+		Type:    metav1.CauseTypeFieldValueInvalid,
+		Field:   fmt.Sprintf("%s.%s", field, SafeToEvictPolicyAnnotation),
+		Message: ErrSafeToEvictPolicyInvalid,
+	}}
+}
+
+// validateDevState validates the DevStateAnnotation, if the GameServer has one
+func (gs *GameServer) validateDevState() []metav1.StatusCause {
+	state, ok := gs.ObjectMeta.Annotations[DevStateAnnotation]
+	if !ok {
+		return nil
+	}
+	field := fmt.Sprintf("annotations.%s", DevStateAnnotation)
+	if _, isDev := gs.GetDevAddress(); !isDev {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   field,
+			Message: fmt.Sprintf("Annotation '%s' can only be set if GameServer is annotated with %s", DevStateAnnotation, DevAddressAnnotation),
+		}}
+	}
+	switch GameServerState(state) {
+	case GameServerStateScheduled, GameServerStateReady, GameServerStateAllocated:
+		return nil
+	}
+	return []metav1.StatusCause{{
+		Type:  metav1.CauseTypeFieldValueNotSupported,
+		Field: field,
+		Message: fmt.Sprintf("Value '%s' of annotation '%s' must be one of %s, %s or %s", state, DevStateAnnotation,
+			GameServerStateScheduled, GameServerStateReady, GameServerStateAllocated),
+	}}
+}
+
+// ValidateUpdate validates updates to a GameServer once its Pod exists. The argument
+// is the new GameServer, being passed into the old GameServer. The template and ports of the
+// GameServer cannot be changed, as they would silently diverge from those of the running Pod.
+func (gs *GameServer) ValidateUpdate(new *GameServer) ([]metav1.StatusCause, bool) {
+	var causes []metav1.StatusCause
+	if gs.Spec.Container != new.Spec.Container {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "container",
+			Message: "container cannot be updated once the Pod of the GameServer exists, delete and recreate the GameServer instead",
+		})
+	}
+	if !reflect.DeepEqual(gs.Spec.Template, new.Spec.Template) {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "template",
+			Message: "template values cannot be updated once the Pod of the GameServer exists, delete and recreate the GameServer instead",
+		})
+	}
+	if !reflect.DeepEqual(gs.Spec.Ports, new.Spec.Ports) {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "ports",
+			Message: "ports cannot be updated once the Pod of the GameServer exists, delete and recreate the GameServer instead",
+		})
+	}
+
+	return causes, len(causes) == 0
+}
+
+// validateDevHealthCheck validates the DevHealthCheckAnnotation, if the GameServer has one
+func (gs *GameServer) validateDevHealthCheck() []metav1.StatusCause {
+	if _, ok := gs.ObjectMeta.Annotations[DevHealthCheckAnnotation]; !ok {
+		return nil
+	}
+	field := fmt.Sprintf("annotations.%s", DevHealthCheckAnnotation)
+	if _, isDev := gs.GetDevAddress(); !isDev {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   field,
+			Message: fmt.Sprintf("Annotation '%s' can only be set if GameServer is annotated with %s", DevHealthCheckAnnotation, DevAddressAnnotation),
+		}}
+	}
+	if _, ok := gs.GetDevHealthCheckPort(); !ok {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   field,
+			Message: fmt.Sprintf("Value '%s' of annotation '%s' must be a port number", gs.ObjectMeta.Annotations[DevHealthCheckAnnotation], DevHealthCheckAnnotation),
+		}}
+	}
+	return nil
+}
+
 // GetDevAddress returns the address for game server.
 func (gs *GameServer) GetDevAddress() (string, bool) {
 	devAddress, hasDevAddress := gs.ObjectMeta.Annotations[DevAddressAnnotation]
 	return devAddress, hasDevAddress
 }
 
+// GetDevHealthCheckPort returns the http port of the local SDK server that a development game server
+// is health checked through, and whether it is health checked at all.
+func (gs *GameServer) GetDevHealthCheckPort() (int, bool) {
+	value, ok := gs.ObjectMeta.Annotations[DevHealthCheckAnnotation]
+	if !ok {
+		return 0, false
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, false
+	}
+	return port, true
+}
+
+// GetDevState returns the state a development game server starts in, which is
+// the value of its DevStateAnnotation, or Ready if it doesn't have one.
+func (gs *GameServer) GetDevState() GameServerState {
+	if state, ok := gs.ObjectMeta.Annotations[DevStateAnnotation]; ok && state != "" {
+		return GameServerState(state)
+	}
+	return GameServerStateReady
+}
+
+// PodSafeToEvict returns the value of the PodSafeToEvictAnnotation that the GameServer's Pod should have,
+// or false if it should not have one. If the GameServer sets its Eviction, it is "true" for Always, and "false" otherwise.
+// If not, only the Pod of a Packed GameServer has one, and can't be evicted before the GameServer
+// is Scheduled, or while it is Reserved or Allocated, and with the Never SafeToEvictPolicyAnnotation, not at all.
+func (gs *GameServer) PodSafeToEvict() (string, bool) {
+	if gs.Spec.Eviction != nil && gs.Spec.Eviction.Safe != "" {
+		if gs.Spec.Eviction.Safe == EvictionSafeAlways {
+			return "true", true
+		}
+		return "false", true
+	}
+	if gs.Spec.Scheduling != apis.Packed {
+		return "", false
+	}
+	if gs.ObjectMeta.Annotations[SafeToEvictPolicyAnnotation] == SafeToEvictNever {
+		return "false", true
+	}
+	switch gs.Status.State {
+	case GameServerStateScheduled, GameServerStateRequestReady, GameServerStateReady:
+		return "true", true
+	}
+	return "false", true
+}
+
 // IsDeletable returns false if the server is currently allocated/reserved and is not already in the
 // process of being deleted
 func (gs *GameServer) IsDeletable() bool {
@@ -386,15 +1371,24 @@ func (gs *GameServer) IsBeingDeleted() bool {
 // gameServer.Spec.Container. Returns the index and the value.
 // Returns an error if not found
 func (gss *GameServerSpec) FindGameServerContainer() (int, corev1.Container, error) {
-	for i, c := range gss.Template.Spec.Containers {
-		if c.Name == gss.Container {
-			return i, c, nil
-		}
+	if i := gss.findContainer(gss.Container); i >= 0 {
+		return i, gss.Template.Spec.Containers[i], nil
 	}
 
 	return -1, corev1.Container{}, errors.Errorf("Could not find a container named %s", gss.Container)
 }
 
+// findContainer returns the index of the container with the given name
+// in the pod template, or -1 if there is none
+func (gss *GameServerSpec) findContainer(name string) int {
+	for i, c := range gss.Template.Spec.Containers {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
 // FindGameServerContainer returns the container that is specified in
 // gameServer.Spec.Container. Returns the index and the value.
 // Returns an error if not found
@@ -415,6 +1409,46 @@ func (gs *GameServer) ApplyToPodGameServerContainer(pod *corev1.Pod, f func(core
 	return pod
 }
 
+// PodResourceRequests returns the resources the Kubernetes scheduler reserves for a Pod of this
+// GameServerSpec, and the given sidecars: the sum of the requests of its containers, or those
+// of its largest init container if they are larger. As with Kubernetes, a container's limit is
+// used as its request for any resource that it only has a limit for.
+func (gss *GameServerSpec) PodResourceRequests(sidecars ...corev1.ResourceRequirements) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, c := range gss.Template.Spec.Containers {
+		addResourceRequests(requests, c.Resources)
+	}
+	for _, r := range sidecars {
+		addResourceRequests(requests, r)
+	}
+	for _, c := range gss.Template.Spec.InitContainers {
+		init := corev1.ResourceList{}
+		addResourceRequests(init, c.Resources)
+		for name, q := range init {
+			if current, ok := requests[name]; !ok || q.Cmp(current) > 0 {
+				requests[name] = q
+			}
+		}
+	}
+	return requests
+}
+
+// addResourceRequests adds the effective requests of r to requests
+func addResourceRequests(requests corev1.ResourceList, r corev1.ResourceRequirements) {
+	for name, q := range r.Limits {
+		if _, ok := r.Requests[name]; !ok {
+			total := requests[name]
+			total.Add(q)
+			requests[name] = total
+		}
+	}
+	for name, q := range r.Requests {
+		total := requests[name]
+		total.Add(q)
+		requests[name] = total
+	}
+}
+
 // Pod creates a new Pod from the PodTemplateSpec
 // attached to the GameServer resource
 func (gs *GameServer) Pod(sidecars ...corev1.Container) (*corev1.Pod, error) {
@@ -425,21 +1459,34 @@ func (gs *GameServer) Pod(sidecars ...corev1.Container) (*corev1.Pod, error) {
 
 	gs.podObjectMeta(pod)
 
-	i, gsContainer, err := gs.FindGameServerContainer()
+	i, _, err := gs.FindGameServerContainer()
 	// this shouldn't happen, but if it does.
 	if err != nil {
 		return pod, err
 	}
 
 	for _, p := range gs.Spec.Ports {
+		// ports default to the game server container, but can be opened on any other container
+		c := i
+		if p.Container != "" {
+			if c = gs.Spec.findContainer(p.Container); c < 0 {
+				return pod, errors.Errorf("Could not find a container named %s for port %s", p.Container, p.Name)
+			}
+		}
+
 		cp := corev1.ContainerPort{
 			ContainerPort: p.ContainerPort,
 			HostPort:      p.HostPort,
 			Protocol:      p.Protocol,
 		}
-		gsContainer.Ports = append(gsContainer.Ports, cp)
+		// TCPUDP is not a Kubernetes protocol, so the port is opened once for each protocol
+		if p.Protocol == ProtocolTCPUDP {
+			cp.Protocol = corev1.ProtocolTCP
+			pod.Spec.Containers[c].Ports = append(pod.Spec.Containers[c].Ports, cp)
+			cp.Protocol = corev1.ProtocolUDP
+		}
+		pod.Spec.Containers[c].Ports = append(pod.Spec.Containers[c].Ports, cp)
 	}
-	pod.Spec.Containers[i] = gsContainer
 
 	pod.Spec.Containers = append(pod.Spec.Containers, sidecars...)
 
@@ -468,15 +1515,25 @@ func (gs *GameServer) podObjectMeta(pod *corev1.Pod) {
 	pod.ObjectMeta.Labels[RoleLabel] = GameServerLabelRole
 	// store the GameServer name as a label, for easy lookup later on
 	pod.ObjectMeta.Labels[GameServerPodLabel] = gs.ObjectMeta.Name
+	// store the Fleet name as a label, so the Fleet's PodDisruptionBudget can select its Pods
+	if fleet, ok := gs.ObjectMeta.Labels[FleetNameLabel]; ok {
+		pod.ObjectMeta.Labels[FleetNameLabel] = fleet
+	}
+	if gs.Status.State == GameServerStateAllocated {
+		pod.ObjectMeta.Labels[PodAllocatedLabel] = "true"
+	}
 	// store the GameServer container as an annotation, to make lookup at a Pod level easier
 	pod.ObjectMeta.Annotations[GameServerContainerAnnotation] = gs.Spec.Container
 	ref := metav1.NewControllerRef(gs, SchemeGroupVersion.WithKind("GameServer"))
 	pod.ObjectMeta.OwnerReferences = append(pod.ObjectMeta.OwnerReferences, *ref)
 
-	if gs.Spec.Scheduling == apis.Packed {
-		// This means that the autoscaler cannot remove the Node that this Pod is on.
-		// (and evict the Pod in the process)
-		pod.ObjectMeta.Annotations["cluster-autoscaler.kubernetes.io/safe-to-evict"] = "false"
+	// This means that the autoscaler cannot remove the Node that this Pod is on
+	// (and evict the Pod in the process), until the GameServer is Scheduled
+	if v, ok := gs.PodSafeToEvict(); ok {
+		pod.ObjectMeta.Annotations[PodSafeToEvictAnnotation] = v
+	}
+	if gs.Spec.Eviction != nil && gs.Spec.Eviction.Safe == EvictionSafeNever {
+		pod.ObjectMeta.Labels[SafeToEvictLabel] = "false"
 	}
 
 	// Add Agones version into Pod Annotations
@@ -525,6 +1582,38 @@ func (gs *GameServer) DisableServiceAccount(pod *corev1.Pod) {
 	})
 }
 
+// SDKTokenMount returns which token the game server container is given, "Auto" if it is not set
+func (gs *GameServer) SDKTokenMount() SDKTokenMount {
+	if gs.Spec.SDKToken == nil || gs.Spec.SDKToken.Mount == "" {
+		return SDKTokenAuto
+	}
+	return gs.Spec.SDKToken.Mount
+}
+
+// ProjectServiceAccountToken mounts a token of the Pod's service account, projected for the
+// audience of the SDKToken, at SDKTokenProjectedPath in the game server container
+func (gs *GameServer) ProjectServiceAccountToken(pod *corev1.Pod) {
+	expiration := gs.Spec.SDKToken.ExpirationSeconds
+	if expiration == 0 {
+		expiration = DefaultSDKTokenExpirationSeconds
+	}
+	vol := corev1.Volume{Name: "agones-sdk-token", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+		Sources: []corev1.VolumeProjection{{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+			Audience:          gs.Spec.SDKToken.Audience,
+			ExpirationSeconds: &expiration,
+			Path:              path.Base(SDKTokenProjectedPath),
+		}}},
+	}}}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, vol)
+	mount := corev1.VolumeMount{MountPath: path.Dir(SDKTokenProjectedPath), Name: vol.Name, ReadOnly: true}
+
+	gs.ApplyToPodGameServerContainer(pod, func(c corev1.Container) corev1.Container {
+		c.VolumeMounts = append(c.VolumeMounts, mount)
+
+		return c
+	})
+}
+
 // HasPortPolicy checks if there is a port with a given
 // PortPolicy
 func (gs *GameServer) HasPortPolicy(policy PortPolicy) bool {
@@ -538,9 +1627,23 @@ func (gs *GameServer) HasPortPolicy(policy PortPolicy) bool {
 
 // Status returns a GameServerSatusPort for this GameServerPort
 func (p GameServerPort) Status() GameServerStatusPort {
+	if p.PortPolicy == None {
+		return GameServerStatusPort{Name: p.Name, Port: p.ContainerPort}
+	}
 	return GameServerStatusPort{Name: p.Name, Port: p.HostPort}
 }
 
+// usesPodIP returns whether the address of a GameServer with this spec is the IP of its Pod,
+// rather than that of its Node, which is when any of its ports have the None PortPolicy
+func (gss *GameServerSpec) usesPodIP() bool {
+	for _, p := range gss.Ports {
+		if p.PortPolicy == None {
+			return true
+		}
+	}
+	return false
+}
+
 // CountPorts returns the number of
 // ports that match condition function
 func (gs *GameServer) CountPorts(f func(policy PortPolicy) bool) int {
@@ -576,3 +1679,24 @@ func (gs *GameServer) Patch(delta *GameServer) ([]byte, error) {
 	result, err = json.Marshal(patch)
 	return result, errors.Wrapf(err, "error creating json for patch for GameServer %s", gs.ObjectMeta.Name)
 }
+
+// StatePatch creates a JSONPatch, like Patch, that first tests that the
+// GameServer is still in its current State, so that it fails to apply if
+// the GameServer has moved to another State in the meantime
+func (gs *GameServer) StatePatch(delta *GameServer) ([]byte, error) {
+	var result []byte
+
+	patch, err := gs.Patch(delta)
+	if err != nil {
+		return result, err
+	}
+
+	var ops []jsonpatch.JsonPatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return result, errors.Wrapf(err, "error decoding patch for GameServer %s", gs.ObjectMeta.Name)
+	}
+	ops = append([]jsonpatch.JsonPatchOperation{jsonpatch.NewPatch("test", "/status/state", gs.Status.State)}, ops...)
+
+	result, err = json.Marshal(ops)
+	return result, errors.Wrapf(err, "error creating json for patch for GameServer %s", gs.ObjectMeta.Name)
+}
@@ -17,12 +17,14 @@ package v1alpha1
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"agones.dev/agones/pkg"
 	"agones.dev/agones/pkg/apis"
 	"agones.dev/agones/pkg/apis/stable"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
 )
@@ -64,6 +66,7 @@ func TestGameServerApplyDefaults(t *testing.T) {
 		protocol   corev1.Protocol
 		state      GameServerState
 		policy     PortPolicy
+		portRange  string
 		health     Health
 		scheduling apis.SchedulingStrategy
 	}
@@ -86,6 +89,7 @@ func TestGameServerApplyDefaults(t *testing.T) {
 				protocol:   "UDP",
 				state:      GameServerStatePortAllocation,
 				policy:     Dynamic,
+				portRange:  DefaultPortRange,
 				scheduling: apis.Packed,
 				health: Health{
 					Disabled:            false,
@@ -109,6 +113,7 @@ func TestGameServerApplyDefaults(t *testing.T) {
 				protocol:   "UDP",
 				state:      GameServerStatePortAllocation,
 				policy:     Passthrough,
+				portRange:  DefaultPortRange,
 				scheduling: apis.Packed,
 				health: Health{
 					Disabled:            false,
@@ -188,12 +193,34 @@ func TestGameServerApplyDefaults(t *testing.T) {
 				protocol:   "UDP",
 				state:      GameServerStatePortAllocation,
 				policy:     Dynamic,
+				portRange:  DefaultPortRange,
 				scheduling: apis.Packed,
 				health: Health{
 					Disabled: true,
 				},
 			},
 		},
+		"None port policy": {
+			gameServer: GameServer{
+				Spec: GameServerSpec{
+					Ports: []GameServerPort{{ContainerPort: 7777, PortPolicy: None}},
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+			},
+			container: "testing",
+			expected: expected{
+				protocol:   "UDP",
+				state:      GameServerStateCreating,
+				policy:     None,
+				scheduling: apis.Packed,
+				health: Health{
+					Disabled:            false,
+					FailureThreshold:    3,
+					InitialDelaySeconds: 5,
+					PeriodSeconds:       5,
+				},
+			},
+		},
 		"convert from legacy single port to multiple": {
 			gameServer: GameServer{
 				Spec: GameServerSpec{
@@ -230,9 +257,176 @@ func TestGameServerApplyDefaults(t *testing.T) {
 			assert.Contains(t, test.gameServer.ObjectMeta.Finalizers, stable.GroupName)
 			assert.Equal(t, test.container, spec.Container)
 			assert.Equal(t, test.expected.protocol, spec.Ports[0].Protocol)
+			assert.Equal(t, test.expected.portRange, spec.Ports[0].Range)
+			assert.Equal(t, test.container, spec.Ports[0].Container)
 			assert.Equal(t, test.expected.state, test.gameServer.Status.State)
 			assert.Equal(t, test.expected.health, test.gameServer.Spec.Health)
 			assert.Equal(t, test.expected.scheduling, test.gameServer.Spec.Scheduling)
+
+			// applying the defaults again does not add the finalizer twice
+			test.gameServer.ApplyDefaults()
+			assert.Equal(t, []string{stable.GroupName}, test.gameServer.ObjectMeta.Finalizers)
+		})
+	}
+}
+
+func TestGameServerApplyReadinessDefaults(t *testing.T) {
+	t.Parallel()
+
+	gs := GameServer{
+		Spec: GameServerSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	assert.Equal(t, ReadinessSDK, gs.Spec.Readiness)
+	assert.False(t, gs.Spec.Health.Disabled)
+
+	gs = GameServer{
+		Spec: GameServerSpec{
+			Readiness: ReadinessPod,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	assert.Equal(t, ReadinessPod, gs.Spec.Readiness)
+	assert.True(t, gs.Spec.Health.Disabled)
+}
+
+func TestGameServerApplyRestartDefaults(t *testing.T) {
+	t.Parallel()
+
+	gs := GameServer{
+		Spec: GameServerSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	assert.Equal(t, RestartNever, gs.Spec.RestartPolicy)
+	assert.Equal(t, int32(0), gs.Spec.BackoffLimit)
+
+	gs.Spec.RestartPolicy = RestartOnFailure
+	gs.ApplyDefaults()
+	assert.Equal(t, int32(DefaultBackoffLimit), gs.Spec.BackoffLimit)
+
+	gs.Spec.BackoffLimit = 2
+	gs.ApplyDefaults()
+	assert.Equal(t, int32(2), gs.Spec.BackoffLimit)
+
+	gs.Spec.RestartPolicy = RestartInPlace
+	gs.Spec.BackoffLimit = 0
+	gs.ApplyDefaults()
+	assert.Equal(t, int32(DefaultBackoffLimit), gs.Spec.BackoffLimit)
+}
+
+func TestGameServerApplyPreReadyDefaults(t *testing.T) {
+	t.Parallel()
+
+	gs := GameServer{
+		Spec: GameServerSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	assert.Nil(t, gs.Spec.PreReady)
+
+	gs.Spec.PreReady = &PreReadyWebhook{Service: PreReadyService{Name: "directory"}}
+	gs.ApplyDefaults()
+	assert.Equal(t, int32(DefaultPreReadyTimeoutSeconds), gs.Spec.PreReady.TimeoutSeconds)
+	assert.Equal(t, PreReadyFail, gs.Spec.PreReady.FailurePolicy)
+	assert.Equal(t, int32(DefaultPreReadyServicePort), gs.Spec.PreReady.Service.Port)
+
+	gs.Spec.PreReady = &PreReadyWebhook{Service: PreReadyService{Name: "directory", Port: 8080}, TimeoutSeconds: 3, FailurePolicy: PreReadyIgnore}
+	gs.ApplyDefaults()
+	assert.Equal(t, int32(3), gs.Spec.PreReady.TimeoutSeconds)
+	assert.Equal(t, PreReadyIgnore, gs.Spec.PreReady.FailurePolicy)
+	assert.Equal(t, int32(8080), gs.Spec.PreReady.Service.Port)
+}
+
+func TestGameServerApplyEvictionDefaults(t *testing.T) {
+	t.Parallel()
+
+	gs := GameServer{
+		Spec: GameServerSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	assert.Nil(t, gs.Spec.Eviction)
+
+	gs.Spec.Eviction = &Eviction{}
+	gs.ApplyDefaults()
+	assert.Equal(t, EvictionSafeNever, gs.Spec.Eviction.Safe)
+
+	gs.Spec.Eviction.Safe = EvictionSafeAlways
+	gs.ApplyDefaults()
+	assert.Equal(t, EvictionSafeAlways, gs.Spec.Eviction.Safe)
+
+	causes, ok := gs.Validate()
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	gs.Spec.Eviction.Safe = "Sometimes"
+	causes, ok = gs.Validate()
+	assert.False(t, ok)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, "eviction.safe", causes[0].Field)
+		assert.Equal(t, ErrEvictionSafeInvalid, causes[0].Message)
+	}
+}
+
+func TestGameServerApplySDKTokenDefaults(t *testing.T) {
+	t.Parallel()
+
+	gs := GameServer{
+		Spec: GameServerSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	assert.Nil(t, gs.Spec.SDKToken)
+	assert.Equal(t, SDKTokenAuto, gs.SDKTokenMount())
+
+	gs.Spec.SDKToken = &SDKToken{}
+	gs.ApplyDefaults()
+	assert.Equal(t, SDKTokenAuto, gs.Spec.SDKToken.Mount)
+	assert.Equal(t, int64(0), gs.Spec.SDKToken.ExpirationSeconds)
+
+	gs.Spec.SDKToken = &SDKToken{Mount: SDKTokenProjected, Audience: "vault"}
+	gs.ApplyDefaults()
+	assert.Equal(t, SDKTokenProjected, gs.SDKTokenMount())
+	assert.Equal(t, int64(DefaultSDKTokenExpirationSeconds), gs.Spec.SDKToken.ExpirationSeconds)
+
+	causes, ok := gs.Validate()
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	fixtures := map[string]struct {
+		token SDKToken
+		field string
+		msg   string
+	}{
+		"unknown mount": {token: SDKToken{Mount: "Sometimes"}, field: "sdkToken.mount", msg: ErrSDKTokenMountInvalid},
+		"projected, no audience": {token: SDKToken{Mount: SDKTokenProjected, ExpirationSeconds: 600},
+			field: "sdkToken.audience", msg: ErrSDKTokenAudienceInvalid},
+		"disabled, audience": {token: SDKToken{Mount: SDKTokenDisabled, Audience: "vault"},
+			field: "sdkToken.audience", msg: ErrSDKTokenAudienceInvalid},
+		"projected, short expiration": {token: SDKToken{Mount: SDKTokenProjected, Audience: "vault", ExpirationSeconds: 60},
+			field: "sdkToken.expirationSeconds", msg: ErrSDKTokenExpirationInvalid},
+		"enabled, expiration": {token: SDKToken{Mount: SDKTokenEnabled, ExpirationSeconds: 3600},
+			field: "sdkToken.expirationSeconds", msg: ErrSDKTokenExpirationInvalid},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			gsCopy := gs.DeepCopy()
+			gsCopy.Spec.SDKToken = v.token.DeepCopy()
+			causes, ok := gsCopy.Validate()
+			assert.False(t, ok)
+			if assert.Len(t, causes, 1) {
+				assert.Equal(t, v.field, causes[0].Field)
+				assert.Equal(t, v.msg, causes[0].Message)
+			}
 		})
 	}
 }
@@ -340,6 +534,353 @@ func TestGameServerValidate(t *testing.T) {
 	assert.Len(t, causes, 2)
 	assert.Contains(t, fields, "one.containerPort")
 	assert.Contains(t, fields, "two.hostPort")
+
+	gs = GameServer{
+		Spec: GameServerSpec{
+			Ports: []GameServerPort{{Name: "main", PortPolicy: Static, Range: "query", ContainerPort: 7777, HostPort: 7777}},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	causes, ok = gs.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "main.range", causes[0].Field)
+	assert.Equal(t, ErrRangeStatic, causes[0].Message)
+
+	gs = GameServer{
+		Spec: GameServerSpec{
+			Container: "testing",
+			Ports: []GameServerPort{
+				{Name: "stats", Container: "proxy", ContainerPort: 9090},
+				{Name: "missing", Container: "nope", ContainerPort: 9091},
+			},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "testing", Image: "testing/image"},
+					{Name: "proxy", Image: "testing/proxy"}}}}},
+	}
+	gs.ApplyDefaults()
+	causes, ok = gs.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "missing.container", causes[0].Field)
+	assert.Equal(t, ErrPortContainerInvalid, causes[0].Message)
+
+	gs = GameServer{
+		Spec: GameServerSpec{
+			Readiness: "Wrong",
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	causes, ok = gs.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "readiness", causes[0].Field)
+	assert.Equal(t, ErrReadinessInvalid, causes[0].Message)
+
+	gs = GameServer{
+		Spec: GameServerSpec{
+			RestartPolicy: "Always",
+			BackoffLimit:  -1,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	causes, ok = gs.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 2)
+	assert.Equal(t, "restartPolicy", causes[0].Field)
+	assert.Equal(t, ErrRestartPolicyInvalid, causes[0].Message)
+	assert.Equal(t, "backoffLimit", causes[1].Field)
+	assert.Equal(t, ErrBackoffLimitInvalid, causes[1].Message)
+
+	gs = GameServer{
+		Spec: GameServerSpec{
+			RestartPolicy: RestartInPlace,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	causes, ok = gs.Validate()
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	gs.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	causes, ok = gs.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "template.spec.restartPolicy", causes[0].Field)
+	assert.Equal(t, ErrRestartInPlacePodRestartPolicy, causes[0].Message)
+
+	gs = GameServer{
+		Spec: GameServerSpec{
+			Counters: map[string]CounterStatus{"rooms": {Count: 1, Capacity: 10}, "bad": {Count: 11, Capacity: 10}},
+			Lists: map[string]ListStatus{"players": {Capacity: 2, Values: []string{"a"}},
+				"full": {Capacity: 1, Values: []string{"a", "b"}}, "dupe": {Capacity: 5, Values: []string{"a", "a"}}},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	causes, ok = gs.Validate()
+	fields = []string{}
+	for _, f := range causes {
+		fields = append(fields, f.Field)
+	}
+	assert.False(t, ok)
+	assert.Len(t, causes, 3)
+	assert.Contains(t, fields, "counters.bad")
+	assert.Contains(t, fields, "lists.full")
+	assert.Contains(t, fields, "lists.dupe")
+
+	gs = GameServer{
+		Spec: GameServerSpec{
+			PreReady: &PreReadyWebhook{Service: PreReadyService{Name: "directory", Port: 8080, Path: "/register"}},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	causes, ok = gs.Validate()
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	gs.Spec.PreReady = &PreReadyWebhook{Service: PreReadyService{Name: "directory.example.com", Port: 65536, Path: "//example.com/register"},
+		TimeoutSeconds: 31, FailurePolicy: "Retry"}
+	causes, ok = gs.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 5)
+	assert.Equal(t, "preReady.service.name", causes[0].Field)
+	assert.Equal(t, ErrPreReadyServiceNameInvalid, causes[0].Message)
+	assert.Equal(t, "preReady.service.port", causes[1].Field)
+	assert.Equal(t, ErrPreReadyServicePortInvalid, causes[1].Message)
+	assert.Equal(t, "preReady.service.path", causes[2].Field)
+	assert.Equal(t, ErrPreReadyServicePathInvalid, causes[2].Message)
+	assert.Equal(t, "preReady.timeoutSeconds", causes[3].Field)
+	assert.Equal(t, ErrPreReadyTimeoutInvalid, causes[3].Message)
+	assert.Equal(t, "preReady.failurePolicy", causes[4].Field)
+	assert.Equal(t, ErrPreReadyFailurePolicyInvalid, causes[4].Message)
+
+	gs.Spec.PreReady = &PreReadyWebhook{Service: PreReadyService{Name: "directory", Path: "/register?next=http://example.com"}}
+	causes, ok = gs.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "preReady.service.path", causes[0].Field)
+
+	gs = GameServer{
+		Spec: GameServerSpec{
+			Ports: []GameServerPort{{Name: "main", ContainerPort: 7777, PortPolicy: None},
+				{Name: "query", ContainerPort: 7778, PortPolicy: None}},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	causes, ok = gs.Validate()
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	gs.Spec.Ports = []GameServerPort{{Name: "main", PortPolicy: None, HostPort: 7777, Range: DefaultPortRange},
+		{Name: "query", ContainerPort: 7778, PortPolicy: Dynamic}}
+	causes, ok = gs.Validate()
+	assert.False(t, ok)
+	assert.Equal(t, []metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldValueInvalid, Field: "main.containerPort", Message: ErrContainerPortRequired},
+		{Type: metav1.CauseTypeFieldValueInvalid, Field: "main.hostPort", Message: ErrHostPortNone},
+		{Type: metav1.CauseTypeFieldValueInvalid, Field: "main.range", Message: ErrRangeNone},
+		{Type: metav1.CauseTypeFieldValueInvalid, Field: "query.portPolicy", Message: ErrPortPolicyNoneMixed},
+	}, causes)
+
+	gs = GameServer{
+		Spec: GameServerSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					HostAliases: []corev1.HostAlias{{IP: "10.0.0.5", Hostnames: []string{"master.example.com", "master"}}},
+					Containers:  []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	causes, ok = gs.Validate()
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	gs.Spec.Template.Spec.HostAliases = append(gs.Spec.Template.Spec.HostAliases,
+		corev1.HostAlias{IP: "master", Hostnames: []string{"Not_A_Host"}}, corev1.HostAlias{IP: "::1"})
+	causes, ok = gs.Validate()
+	assert.False(t, ok)
+	assert.Equal(t, []metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldValueInvalid, Field: "template.spec.hostAliases[1].ip", Message: ErrHostAliasIPInvalid},
+		{Type: metav1.CauseTypeFieldValueInvalid, Field: "template.spec.hostAliases[1].hostnames", Message: ErrHostAliasHostnamesInvalid},
+		{Type: metav1.CauseTypeFieldValueInvalid, Field: "template.spec.hostAliases[2].hostnames", Message: ErrHostAliasHostnamesInvalid},
+	}, causes)
+}
+
+func TestGameServerValidateUpdate(t *testing.T) {
+	gs := GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: GameServerSpec{
+			Container: "testing",
+			Ports:     []GameServerPort{{Name: "main", ContainerPort: 7777, HostPort: 7001}},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}},
+			},
+		},
+	}
+
+	causes, ok := gs.ValidateUpdate(gs.DeepCopy())
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	newGS := gs.DeepCopy()
+	newGS.ObjectMeta.Labels = map[string]string{"mode": "deathmatch"}
+	newGS.Status.State = GameServerStateReady
+	causes, ok = gs.ValidateUpdate(newGS)
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	newGS = gs.DeepCopy()
+	newGS.Spec.Template.Spec.Containers[0].Image = "testing/image:2"
+	causes, ok = gs.ValidateUpdate(newGS)
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "template", causes[0].Field)
+
+	newGS = gs.DeepCopy()
+	newGS.Spec.Ports[0].HostPort = 7002
+	causes, ok = gs.ValidateUpdate(newGS)
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "ports", causes[0].Field)
+
+	newGS.Spec.Template.Spec.Containers[0].Image = "testing/image:2"
+	causes, ok = gs.ValidateUpdate(newGS)
+	assert.False(t, ok)
+	assert.Len(t, causes, 2)
+	newGS = gs.DeepCopy()
+	newGS.Spec.Container = "other"
+	causes, ok = gs.ValidateUpdate(newGS)
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "container", causes[0].Field)
+}
+
+func TestGameServerApplyCounterAndListDefaults(t *testing.T) {
+	t.Parallel()
+
+	gs := GameServer{
+		Spec: GameServerSpec{
+			Counters: map[string]CounterStatus{"rooms": {Count: 1, Capacity: 10}},
+			Lists:    map[string]ListStatus{"players": {Capacity: 5, Values: []string{"a"}}},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	assert.Equal(t, gs.Spec.Counters, gs.Status.Counters)
+	assert.Equal(t, gs.Spec.Lists, gs.Status.Lists)
+
+	// status is not shared with the spec
+	gs.Status.Lists["players"].Values[0] = "b"
+	assert.Equal(t, "a", gs.Spec.Lists["players"].Values[0])
+
+	// existing status is not overwritten
+	gs.Status.Counters = map[string]CounterStatus{"rooms": {Count: 5, Capacity: 10}}
+	gs.ApplyDefaults()
+	assert.Equal(t, int64(5), gs.Status.Counters["rooms"].Count)
+
+	gs = GameServer{}
+	gs.ApplyDefaults()
+	assert.Nil(t, gs.Status.Counters)
+	assert.Nil(t, gs.Status.Lists)
+}
+
+func TestCounterAndListStatus(t *testing.T) {
+	t.Parallel()
+
+	c := CounterStatus{Count: 3, Capacity: 10}
+	assert.Equal(t, int64(7), c.Available())
+	assert.NoError(t, c.Validate())
+	assert.Error(t, CounterStatus{Count: -1, Capacity: 10}.Validate())
+	assert.Error(t, CounterStatus{Count: 1, Capacity: 0}.Validate())
+
+	l := ListStatus{Capacity: 3, Values: []string{"a", "b"}}
+	assert.Equal(t, int64(1), l.Available())
+	assert.True(t, l.Contains("a"))
+	assert.False(t, l.Contains("c"))
+	assert.NoError(t, l.Validate())
+	assert.Error(t, ListStatus{Capacity: 1, Values: []string{"a", "b"}}.Validate())
+	assert.Error(t, ListStatus{Capacity: 3, Values: []string{"a", "a"}}.Validate())
+}
+
+func TestGameServerStatusSetCondition(t *testing.T) {
+	t.Parallel()
+
+	status := GameServerStatus{}
+	assert.Nil(t, status.Condition(GameServerConditionPodReady))
+
+	assert.True(t, status.SetCondition(GameServerConditionPodReady, corev1.ConditionFalse, "PodNotReady", "Pod is not Ready"))
+	c := status.Condition(GameServerConditionPodReady)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, corev1.ConditionFalse, c.Status)
+		assert.Equal(t, "PodNotReady", c.Reason)
+		assert.False(t, c.LastTransitionTime.IsZero())
+	}
+
+	// unchanged, so nothing to update
+	assert.False(t, status.SetCondition(GameServerConditionPodReady, corev1.ConditionFalse, "PodNotReady", "Pod is not Ready"))
+
+	// the transition time only moves when the status changes
+	transitioned := metav1.NewTime(time.Now().Add(-time.Hour))
+	status.Conditions[0].LastTransitionTime = transitioned
+	assert.True(t, status.SetCondition(GameServerConditionPodReady, corev1.ConditionFalse, "ContainersNotReady", "containers with unready status"))
+	assert.Equal(t, transitioned, status.Condition(GameServerConditionPodReady).LastTransitionTime)
+	assert.True(t, status.SetCondition(GameServerConditionPodReady, corev1.ConditionTrue, "PodReady", "Pod is Ready"))
+	assert.NotEqual(t, transitioned, status.Condition(GameServerConditionPodReady).LastTransitionTime)
+
+	assert.True(t, status.SetCondition(GameServerConditionSDKConnected, corev1.ConditionTrue, "SDKCalled", "Received Ready from the game server"))
+	assert.Len(t, status.Conditions, 2)
+}
+
+func TestGameServerSpecPodResourceRequests(t *testing.T) {
+	t.Parallel()
+
+	resources := func(cpuRequest, cpuLimit, memRequest string) corev1.ResourceRequirements {
+		r := corev1.ResourceRequirements{Requests: corev1.ResourceList{}, Limits: corev1.ResourceList{}}
+		if cpuRequest != "" {
+			r.Requests[corev1.ResourceCPU] = resource.MustParse(cpuRequest)
+		}
+		if cpuLimit != "" {
+			r.Limits[corev1.ResourceCPU] = resource.MustParse(cpuLimit)
+		}
+		if memRequest != "" {
+			r.Requests[corev1.ResourceMemory] = resource.MustParse(memRequest)
+		}
+		return r
+	}
+
+	gss := GameServerSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "a", Resources: resources("100m", "200m", "64Mi")},
+			{Name: "b", Resources: resources("", "50m", "")},
+			{Name: "c"},
+		},
+	}}}
+
+	requests := gss.PodResourceRequests()
+	assert.Equal(t, "150m", requests.Cpu().String())
+	assert.Equal(t, "64Mi", requests.Memory().String())
+
+	requests = gss.PodResourceRequests(resources("30m", "", ""))
+	assert.Equal(t, "180m", requests.Cpu().String())
+	assert.Equal(t, "64Mi", requests.Memory().String())
+
+	// the largest init container only counts if it is larger
+	gss.Template.Spec.InitContainers = []corev1.Container{
+		{Name: "small", Resources: resources("10m", "", "1Gi")},
+		{Name: "large", Resources: resources("", "1", "")},
+	}
+	requests = gss.PodResourceRequests()
+	assert.Equal(t, "1", requests.Cpu().String())
+	assert.Equal(t, "1Gi", requests.Memory().String())
+
+	assert.Empty(t, (&GameServerSpec{}).PodResourceRequests())
 }
 
 func TestGameServerPod(t *testing.T) {
@@ -358,6 +899,22 @@ func TestGameServerPod(t *testing.T) {
 	assert.Equal(t, fixture.Spec.Ports[0].ContainerPort, pod.Spec.Containers[0].Ports[0].ContainerPort)
 	assert.Equal(t, corev1.Protocol("UDP"), pod.Spec.Containers[0].Ports[0].Protocol)
 	assert.True(t, metav1.IsControlledBy(pod, fixture))
+	assert.Nil(t, pod.Spec.TerminationGracePeriodSeconds)
+
+	aliases := []corev1.HostAlias{{IP: "10.0.0.5", Hostnames: []string{"master.example.com"}}}
+	fixture.Spec.Template.Spec.HostAliases = aliases
+	pod, err = fixture.Pod()
+	assert.Nil(t, err, "Pod should not return an error")
+	assert.Equal(t, aliases, pod.Spec.HostAliases)
+	fixture.Spec.Template.Spec.HostAliases = nil
+
+	// the controller deletes the Pod without overriding its grace period, so this is what is used on shutdown
+	grace := int64(120)
+	fixture.Spec.Template.Spec.TerminationGracePeriodSeconds = &grace
+	pod, err = fixture.Pod()
+	assert.Nil(t, err, "Pod should not return an error")
+	assert.Equal(t, &grace, pod.Spec.TerminationGracePeriodSeconds)
+	fixture.Spec.Template.Spec.TerminationGracePeriodSeconds = nil
 
 	sidecar := corev1.Container{Name: "sidecar", Image: "container/sidecar"}
 	fixture.Spec.Template.Spec.ServiceAccountName = "other-agones-sdk"
@@ -369,6 +926,32 @@ func TestGameServerPod(t *testing.T) {
 	assert.Equal(t, "container", pod.Spec.Containers[0].Name)
 	assert.Equal(t, "sidecar", pod.Spec.Containers[1].Name)
 	assert.True(t, metav1.IsControlledBy(pod, fixture))
+
+	fixture.Spec.Template.Spec.Containers = append(fixture.Spec.Template.Spec.Containers, corev1.Container{Name: "proxy", Image: "container/proxy"})
+	fixture.Spec.Ports = append(fixture.Spec.Ports, GameServerPort{Name: "stats", Container: "proxy", ContainerPort: 9090, HostPort: 9090})
+	pod, err = fixture.Pod()
+	assert.Nil(t, err, "Pod should not return an error")
+	assert.Len(t, pod.Spec.Containers[0].Ports, 1)
+	assert.Equal(t, "proxy", pod.Spec.Containers[1].Name)
+	assert.Len(t, pod.Spec.Containers[1].Ports, 1)
+	assert.Equal(t, int32(9090), pod.Spec.Containers[1].Ports[0].ContainerPort)
+	assert.Equal(t, int32(9090), pod.Spec.Containers[1].Ports[0].HostPort)
+
+	fixture.Spec.Ports[1].Container = "nope"
+	_, err = fixture.Pod()
+	assert.NotNil(t, err)
+
+	fixture.Spec.Ports = fixture.Spec.Ports[:1]
+	fixture.Spec.Template.Spec.Containers = fixture.Spec.Template.Spec.Containers[:1]
+	fixture.Spec.Ports[0].Protocol = ProtocolTCPUDP
+	pod, err = fixture.Pod()
+	assert.Nil(t, err, "Pod should not return an error")
+	assert.Len(t, pod.Spec.Containers[0].Ports, 2)
+	for i, protocol := range []corev1.Protocol{corev1.ProtocolTCP, corev1.ProtocolUDP} {
+		assert.Equal(t, protocol, pod.Spec.Containers[0].Ports[i].Protocol)
+		assert.Equal(t, fixture.Spec.Ports[0].HostPort, pod.Spec.Containers[0].Ports[i].HostPort)
+		assert.Equal(t, fixture.Spec.Ports[0].ContainerPort, pod.Spec.Containers[0].Ports[i].ContainerPort)
+	}
 }
 
 func TestGameServerPodObjectMeta(t *testing.T) {
@@ -406,6 +989,44 @@ func TestGameServerPodObjectMeta(t *testing.T) {
 
 		assert.Equal(t, "", pod.ObjectMeta.Annotations["cluster-autoscaler.kubernetes.io/safe-to-evict"])
 	})
+
+	t.Run("fleet", func(t *testing.T) {
+		gs := fixture.DeepCopy()
+		gs.ObjectMeta.Labels = map[string]string{FleetNameLabel: "fleet"}
+		gs.Status.State = GameServerStateAllocated
+		pod := &corev1.Pod{}
+
+		gs.podObjectMeta(pod)
+		f(t, gs, pod)
+
+		assert.Equal(t, "fleet", pod.ObjectMeta.Labels[FleetNameLabel])
+		assert.Equal(t, "true", pod.ObjectMeta.Labels[PodAllocatedLabel])
+	})
+
+	t.Run("never eviction", func(t *testing.T) {
+		gs := fixture.DeepCopy()
+		gs.Spec.Scheduling = apis.Distributed
+		gs.Spec.Eviction = &Eviction{Safe: EvictionSafeNever}
+		pod := &corev1.Pod{}
+
+		gs.podObjectMeta(pod)
+		f(t, gs, pod)
+
+		assert.Equal(t, "false", pod.ObjectMeta.Annotations[PodSafeToEvictAnnotation])
+		assert.Equal(t, "false", pod.ObjectMeta.Labels[SafeToEvictLabel])
+	})
+
+	t.Run("on upgrade eviction", func(t *testing.T) {
+		gs := fixture.DeepCopy()
+		gs.Spec.Eviction = &Eviction{Safe: EvictionSafeOnUpgrade}
+		pod := &corev1.Pod{}
+
+		gs.podObjectMeta(pod)
+		f(t, gs, pod)
+
+		assert.Equal(t, "false", pod.ObjectMeta.Annotations[PodSafeToEvictAnnotation])
+		assert.NotContains(t, pod.ObjectMeta.Labels, SafeToEvictLabel)
+	})
 }
 
 func TestGameServerPodScheduling(t *testing.T) {
@@ -453,6 +1074,35 @@ func TestGameServerDisableServiceAccount(t *testing.T) {
 	assert.Equal(t, "/var/run/secrets/kubernetes.io/serviceaccount", pod.Spec.Containers[0].VolumeMounts[0].MountPath)
 }
 
+func TestGameServerProjectServiceAccountToken(t *testing.T) {
+	t.Parallel()
+
+	gs := &GameServer{ObjectMeta: metav1.ObjectMeta{Name: "gameserver", UID: "1234"}, Spec: GameServerSpec{
+		SDKToken: &SDKToken{Mount: SDKTokenProjected, Audience: "vault"},
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "container", Image: "container/image"}},
+			},
+		}}}
+
+	gs.ApplyDefaults()
+	pod, err := gs.Pod()
+	assert.NoError(t, err)
+
+	gs.ProjectServiceAccountToken(pod)
+	if assert.Len(t, pod.Spec.Containers[0].VolumeMounts, 1) {
+		mount := pod.Spec.Containers[0].VolumeMounts[0]
+		assert.Equal(t, "/var/run/secrets/agones.dev", mount.MountPath)
+		assert.True(t, mount.ReadOnly)
+	}
+	if assert.Len(t, pod.Spec.Volumes, 1) && assert.NotNil(t, pod.Spec.Volumes[0].Projected) {
+		token := pod.Spec.Volumes[0].Projected.Sources[0].ServiceAccountToken
+		assert.Equal(t, "vault", token.Audience)
+		assert.Equal(t, int64(3600), *token.ExpirationSeconds)
+		assert.Equal(t, "token", token.Path)
+	}
+}
+
 func TestGameServerCountPorts(t *testing.T) {
 	fixture := &GameServer{Spec: GameServerSpec{Ports: []GameServerPort{
 		{PortPolicy: Dynamic},
@@ -481,6 +1131,20 @@ func TestGameServerPatch(t *testing.T) {
 
 	assert.Contains(t, string(patch), `{"op":"replace","path":"/spec/container","value":"bear"}`)
 }
+
+func TestGameServerStatePatch(t *testing.T) {
+	fixture := &GameServer{ObjectMeta: metav1.ObjectMeta{Name: "lucy"},
+		Status: GameServerStatus{State: GameServerStateScheduled}}
+
+	delta := fixture.DeepCopy()
+	delta.Status.State = GameServerStateRequestReady
+
+	patch, err := fixture.StatePatch(delta)
+	assert.Nil(t, err)
+
+	assert.Equal(t, `[{"op":"test","path":"/status/state","value":"Scheduled"},`+
+		`{"op":"replace","path":"/status/state","value":"RequestReady"}]`, string(patch))
+}
 func TestGameServerGetDevAddress(t *testing.T) {
 	devGs := &GameServer{
 		ObjectMeta: metav1.ObjectMeta{
@@ -508,6 +1172,212 @@ func TestGameServerGetDevAddress(t *testing.T) {
 	assert.Equal(t, "", devAddress, "dev-address IP address should be 127.1.1.1")
 }
 
+func TestGameServerDevState(t *testing.T) {
+	devGs := &GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "dev-game",
+			Namespace:   "default",
+			Annotations: map[string]string{DevAddressAnnotation: ipFixture},
+		},
+		Spec: GameServerSpec{
+			Ports: []GameServerPort{{Name: "main", HostPort: 7777, ContainerPort: 7777, PortPolicy: Static}},
+		},
+	}
+	assert.Equal(t, GameServerStateReady, devGs.GetDevState())
+	causes, ok := devGs.Validate()
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	for _, state := range []GameServerState{GameServerStateScheduled, GameServerStateReady, GameServerStateAllocated} {
+		devGs.ObjectMeta.Annotations[DevStateAnnotation] = string(state)
+		assert.Equal(t, state, devGs.GetDevState())
+		causes, ok = devGs.Validate()
+		assert.True(t, ok, string(state))
+		assert.Empty(t, causes, string(state))
+	}
+
+	devGs.ObjectMeta.Annotations[DevStateAnnotation] = string(GameServerStateShutdown)
+	causes, ok = devGs.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, fmt.Sprintf("annotations.%s", DevStateAnnotation), causes[0].Field)
+	assert.Equal(t, metav1.CauseTypeFieldValueNotSupported, causes[0].Type)
+
+	regularGs := devGs.DeepCopy()
+	regularGs.ObjectMeta.Annotations = map[string]string{DevStateAnnotation: string(GameServerStateAllocated)}
+	regularGs.Spec.Container = "container"
+	regularGs.Spec.Template = corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "container", Image: "container/image"}},
+	}}
+	causes, ok = regularGs.Validate()
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, fmt.Sprintf("annotations.%s", DevStateAnnotation), causes[0].Field)
+}
+
+func TestGameServerDevHealthCheck(t *testing.T) {
+	devGs := &GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "dev-game",
+			Namespace:   "default",
+			Annotations: map[string]string{DevAddressAnnotation: ipFixture},
+		},
+		Spec: GameServerSpec{
+			Ports: []GameServerPort{{Name: "main", HostPort: 7777, ContainerPort: 7777, PortPolicy: Static}},
+		},
+	}
+	_, ok := devGs.GetDevHealthCheckPort()
+	assert.False(t, ok)
+
+	devGs.ObjectMeta.Annotations[DevHealthCheckAnnotation] = "59358"
+	port, ok := devGs.GetDevHealthCheckPort()
+	assert.True(t, ok)
+	assert.Equal(t, 59358, port)
+	causes, ok := devGs.Validate()
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	for _, value := range []string{"", "nope", "0", "70000"} {
+		devGs.ObjectMeta.Annotations[DevHealthCheckAnnotation] = value
+		_, ok = devGs.GetDevHealthCheckPort()
+		assert.False(t, ok, value)
+		causes, ok = devGs.Validate()
+		assert.False(t, ok, value)
+		if assert.Len(t, causes, 1, value) {
+			assert.Equal(t, fmt.Sprintf("annotations.%s", DevHealthCheckAnnotation), causes[0].Field)
+		}
+	}
+
+	delete(devGs.ObjectMeta.Annotations, DevAddressAnnotation)
+	devGs.ObjectMeta.Annotations[DevHealthCheckAnnotation] = "59358"
+	causes = devGs.validateDevHealthCheck()
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, fmt.Sprintf("annotations.%s", DevHealthCheckAnnotation), causes[0].Field)
+	}
+}
+
+func TestGameServerValidateUnhealthyRetention(t *testing.T) {
+	gs := &GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default",
+			Annotations: map[string]string{UnhealthyRetentionAnnotation: "30m"}},
+		Spec: GameServerSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	causes, ok := gs.Validate()
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	for _, value := range []string{"", "nope", "-1m", "30"} {
+		gs.ObjectMeta.Annotations[UnhealthyRetentionAnnotation] = value
+		causes, ok = gs.Validate()
+		assert.False(t, ok, value)
+		if assert.Len(t, causes, 1, value) {
+			assert.Equal(t, fmt.Sprintf("annotations.%s", UnhealthyRetentionAnnotation), causes[0].Field)
+		}
+	}
+
+	f := defaultFleet()
+	f.Spec.Template.ObjectMeta.Annotations = map[string]string{UnhealthyRetentionAnnotation: "nope"}
+	f.ApplyDefaults()
+	causes, ok = f.Validate()
+	assert.False(t, ok)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, fmt.Sprintf("template.metadata.annotations.%s", UnhealthyRetentionAnnotation), causes[0].Field)
+	}
+
+	gsSet := f.GameServerSet()
+	causes, ok = gsSet.Validate()
+	assert.False(t, ok)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, fmt.Sprintf("template.metadata.annotations.%s", UnhealthyRetentionAnnotation), causes[0].Field)
+	}
+}
+
+func TestGameServerValidateSafeToEvictPolicy(t *testing.T) {
+	gs := &GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default",
+			Annotations: map[string]string{SafeToEvictPolicyAnnotation: SafeToEvictNever}},
+		Spec: GameServerSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "testing", Image: "testing/image"}}}}},
+	}
+	gs.ApplyDefaults()
+	causes, ok := gs.Validate()
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	gs.ObjectMeta.Annotations[SafeToEvictPolicyAnnotation] = "Always"
+	causes, ok = gs.Validate()
+	assert.False(t, ok)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, fmt.Sprintf("annotations.%s", SafeToEvictPolicyAnnotation), causes[0].Field)
+		assert.Equal(t, ErrSafeToEvictPolicyInvalid, causes[0].Message)
+	}
+
+	f := defaultFleet()
+	f.Spec.Template.ObjectMeta.Annotations = map[string]string{SafeToEvictPolicyAnnotation: "Always"}
+	f.ApplyDefaults()
+	causes, ok = f.Validate()
+	assert.False(t, ok)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, fmt.Sprintf("template.metadata.annotations.%s", SafeToEvictPolicyAnnotation), causes[0].Field)
+	}
+
+	gsSet := f.GameServerSet()
+	causes, ok = gsSet.Validate()
+	assert.False(t, ok)
+	if assert.Len(t, causes, 1) {
+		assert.Equal(t, fmt.Sprintf("template.metadata.annotations.%s", SafeToEvictPolicyAnnotation), causes[0].Field)
+	}
+}
+
+func TestGameServerPodSafeToEvict(t *testing.T) {
+	t.Parallel()
+
+	fixtures := map[string]struct {
+		scheduling apis.SchedulingStrategy
+		policy     string
+		eviction   EvictionSafe
+		state      GameServerState
+		expected   string
+		expectedOk bool
+	}{
+		"distributed":           {scheduling: apis.Distributed, state: GameServerStateReady, expected: "", expectedOk: false},
+		"creating":              {scheduling: apis.Packed, state: GameServerStateCreating, expected: "false", expectedOk: true},
+		"scheduled":             {scheduling: apis.Packed, state: GameServerStateScheduled, expected: "true", expectedOk: true},
+		"ready":                 {scheduling: apis.Packed, state: GameServerStateReady, expected: "true", expectedOk: true},
+		"reserved":              {scheduling: apis.Packed, state: GameServerStateReserved, expected: "false", expectedOk: true},
+		"allocated":             {scheduling: apis.Packed, state: GameServerStateAllocated, expected: "false", expectedOk: true},
+		"ready, managed policy": {scheduling: apis.Packed, policy: SafeToEvictManaged, state: GameServerStateReady, expected: "true", expectedOk: true},
+		"ready, never policy":   {scheduling: apis.Packed, policy: SafeToEvictNever, state: GameServerStateReady, expected: "false", expectedOk: true},
+		"allocated, always eviction": {scheduling: apis.Packed, eviction: EvictionSafeAlways, state: GameServerStateAllocated,
+			expected: "true", expectedOk: true},
+		"distributed, always eviction": {scheduling: apis.Distributed, eviction: EvictionSafeAlways, state: GameServerStateReady,
+			expected: "true", expectedOk: true},
+		"ready, on upgrade eviction": {scheduling: apis.Packed, eviction: EvictionSafeOnUpgrade, state: GameServerStateReady,
+			expected: "false", expectedOk: true},
+		"ready, never eviction": {scheduling: apis.Packed, policy: SafeToEvictManaged, eviction: EvictionSafeNever,
+			state: GameServerStateReady, expected: "false", expectedOk: true},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			gs := &GameServer{Spec: GameServerSpec{Scheduling: v.scheduling}, Status: GameServerStatus{State: v.state}}
+			if v.policy != "" {
+				gs.ObjectMeta.Annotations = map[string]string{SafeToEvictPolicyAnnotation: v.policy}
+			}
+			if v.eviction != "" {
+				gs.Spec.Eviction = &Eviction{Safe: v.eviction}
+			}
+			value, ok := gs.PodSafeToEvict()
+			assert.Equal(t, v.expected, value)
+			assert.Equal(t, v.expectedOk, ok)
+		})
+	}
+}
+
 func TestGameServerIsDeletable(t *testing.T) {
 	gs := &GameServer{Status: GameServerStatus{State: GameServerStateStarting}}
 	assert.True(t, gs.IsDeletable())
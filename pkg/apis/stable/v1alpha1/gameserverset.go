@@ -16,7 +16,9 @@ package v1alpha1
 
 import (
 	"reflect"
+	"strconv"
 
+	"agones.dev/agones/pkg"
 	"agones.dev/agones/pkg/apis"
 	"agones.dev/agones/pkg/apis/stable"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -26,8 +28,26 @@ const (
 	// GameServerSetGameServerLabel is the label that the name of the GameServerSet
 	// is set on the GameServer the GameServerSet controls
 	GameServerSetGameServerLabel = stable.GroupName + "/gameserverset"
+	// GameServerSetSidecarImageAnnotation is the annotation with the SDK sidecar image of the controller
+	// that created the GameServerSet of a Fleet
+	GameServerSetSidecarImageAnnotation = stable.GroupName + "/sidecar-image"
+	// GameServerScaleDownCostAnnotation is the annotation with the cost of deleting a GameServer, as an integer,
+	// which the Cost ScaleDownPreference deletes the GameServers with the lowest of first. Defaults to 0.
+	// The game server sets it with SDK.SetAnnotation("scale-down-cost", ...).
+	GameServerScaleDownCostAnnotation = stable.GroupName + "/sdk-scale-down-cost"
+
+	// ScaleDownOldestFirst deletes the oldest GameServers first
+	ScaleDownOldestFirst ScaleDownPreference = "OldestFirst"
+	// ScaleDownNewestFirst deletes the newest GameServers first
+	ScaleDownNewestFirst ScaleDownPreference = "NewestFirst"
+	// ScaleDownCost deletes the GameServers with the lowest GameServerScaleDownCostAnnotation first,
+	// and those of the same cost in the order of the scheduling strategy
+	ScaleDownCost ScaleDownPreference = "Cost"
 )
 
+// ScaleDownPreference is which of its GameServers a GameServerSet deletes first when it is scaled down
+type ScaleDownPreference string
+
 // +genclient
 // +genclient:method=GetScale,verb=get,subresource=scale,result=k8s.io/api/extensions/v1beta1.Scale
 // +genclient:method=UpdateScale,verb=update,subresource=scale,input=k8s.io/api/extensions/v1beta1.Scale,result=k8s.io/api/extensions/v1beta1.Scale
@@ -60,6 +80,18 @@ type GameServerSetSpec struct {
 	Replicas int32 `json:"replicas"`
 	// Scheduling strategy. Defaults to "Packed".
 	Scheduling apis.SchedulingStrategy `json:"scheduling,omitempty"`
+	// PortRange is the name of the port range that all the Dynamic and Passthrough ports of this
+	// GameServerSet's GameServers are allocated from. If empty, each port's own range is used.
+	// +optional
+	PortRange string `json:"portRange,omitempty"`
+	// AllocationOverflow is the labels and annotations that are applied to the Allocated GameServers of
+	// this GameServerSet. The Fleet controller sets it on the outdated GameServerSets of a Fleet.
+	// +optional
+	AllocationOverflow *AllocationOverflow `json:"allocationOverflow,omitempty"`
+	// ScaleDownPreference is which GameServers are deleted first when the GameServerSet is scaled down, one of
+	// "OldestFirst", "NewestFirst" or "Cost". If not set, it depends on the scheduling strategy.
+	// +optional
+	ScaleDownPreference ScaleDownPreference `json:"scaleDownPreference,omitempty"`
 	// Template the GameServer template to apply for this GameServerSet
 	Template GameServerTemplateSpec `json:"template"`
 }
@@ -76,6 +108,39 @@ type GameServerSetStatus struct {
 	AllocatedReplicas int32 `json:"allocatedReplicas"`
 	// ShutdownReplicas are the number of Shutdown GameServers replicas
 	ShutdownReplicas int32 `json:"shutdownReplicas"`
+	// Players are the total player count and capacity of the GameServers that track players
+	Players *AggregatedPlayerStatus `json:"players,omitempty"`
+	// Counters are the total counts and capacities of the counters of the GameServers, by name
+	Counters map[string]AggregatedCounterStatus `json:"counters,omitempty"`
+	// Lists are the total number of values and capacities of the lists of the GameServers, by name
+	Lists map[string]AggregatedListStatus `json:"lists,omitempty"`
+	// CreationFailures are the distinct reasons GameServers could not be created since the GameServerSet
+	// last created one, such as an exceeded quota, or an invalid template
+	CreationFailures []GameServerSetCreationFailure `json:"creationFailures,omitempty"`
+}
+
+// GameServerSetCreationFailure is a reason that GameServers of a GameServerSet could not be created
+type GameServerSetCreationFailure struct {
+	// Reason is the reason the Kubernetes API gave for the failure, e.g. Forbidden or Invalid
+	Reason metav1.StatusReason `json:"reason"`
+	// Message is the error message for the failure
+	Message string `json:"message"`
+	// Count is the number of times a GameServer could not be created for this reason, since the
+	// GameServerSet last created one
+	Count int32 `json:"count"`
+}
+
+// ApplyDefaults applies default values to the GameServerSet
+func (gsSet *GameServerSet) ApplyDefaults() {
+	if gsSet.Spec.Scheduling == "" {
+		gsSet.Spec.Scheduling = apis.Packed
+	}
+
+	// Add Agones version into GameServerSet Annotations
+	if gsSet.ObjectMeta.Annotations == nil {
+		gsSet.ObjectMeta.Annotations = make(map[string]string, 1)
+	}
+	gsSet.ObjectMeta.Annotations[stable.VersionAnnotation] = pkg.Version
 }
 
 // ValidateUpdate validates when updates occur. The argument
@@ -89,6 +154,14 @@ func (gsSet *GameServerSet) ValidateUpdate(new *GameServerSet) ([]metav1.StatusC
 			Message: "template values cannot be updated after creation",
 		})
 	}
+	if gsSet.Spec.PortRange != new.Spec.PortRange {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "portRange",
+			Message: "portRange cannot be updated after creation",
+		})
+	}
+	causes = append(causes, validateScaleDownPreference(new.Spec.ScaleDownPreference)...)
 
 	return causes, len(causes) == 0
 }
@@ -96,9 +169,16 @@ func (gsSet *GameServerSet) ValidateUpdate(new *GameServerSet) ([]metav1.StatusC
 // Validate validates when Create occur. Check the name size
 func (gsSet *GameServerSet) Validate() ([]metav1.StatusCause, bool) {
 	causes := validateName(gsSet)
+	causes = append(causes, validatePortRange(gsSet.Spec.PortRange, gsSet.GetGameServerSpec())...)
+	causes = append(causes, validateUnhealthyRetention(gsSet.Spec.Template.ObjectMeta.Annotations, "template.metadata.annotations")...)
+	causes = append(causes, validateSafeToEvictPolicy(gsSet.Spec.Template.ObjectMeta.Annotations, "template.metadata.annotations")...)
+	if gsSet.Spec.AllocationOverflow != nil {
+		causes = append(causes, gsSet.Spec.AllocationOverflow.Validate("allocationOverflow")...)
+	}
+	causes = append(causes, validateScaleDownPreference(gsSet.Spec.ScaleDownPreference)...)
 
 	// check Gameserver specification in a Gameserverset
-	gsCauses := validateGSSpec(gsSet)
+	gsCauses := validateGSSpec(gsSet, "template.spec.")
 	if len(gsCauses) > 0 {
 		causes = append(causes, gsCauses...)
 	}
@@ -106,6 +186,16 @@ func (gsSet *GameServerSet) Validate() ([]metav1.StatusCause, bool) {
 	return causes, len(causes) == 0
 }
 
+// Revision returns the revision of the Fleet's template that the GameServerSet was created from,
+// or 0 if it doesn't have one
+func (gsSet *GameServerSet) Revision() int64 {
+	r, err := strconv.ParseInt(gsSet.ObjectMeta.Annotations[FleetRevisionAnnotation], 10, 64)
+	if err != nil || r < 0 {
+		return 0
+	}
+	return r
+}
+
 // GetGameServerSpec get underlying Gameserver specification
 func (gsSet *GameServerSet) GetGameServerSpec() *GameServerSpec {
 	return &gsSet.Spec.Template.Spec
@@ -120,6 +210,13 @@ func (gsSet *GameServerSet) GameServer() *GameServer {
 	}
 
 	gs.Spec.Scheduling = gsSet.Spec.Scheduling
+	if gsSet.Spec.PortRange != "" {
+		for i, p := range gs.Spec.Ports {
+			if p.PortPolicy != Static && p.PortPolicy != None {
+				gs.Spec.Ports[i].Range = gsSet.Spec.PortRange
+			}
+		}
+	}
 
 	// Switch to GenerateName, so that we always get a Unique name for the GameServer, and there
 	// can be no collisions
@@ -17,12 +17,30 @@ package v1alpha1
 import (
 	"testing"
 
+	"agones.dev/agones/pkg"
+	"agones.dev/agones/pkg/apis"
+	"agones.dev/agones/pkg/apis/stable"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
 )
 
+func TestGameServerSetApplyDefaults(t *testing.T) {
+	gsSet := &GameServerSet{}
+
+	// gate
+	assert.EqualValues(t, "", gsSet.Spec.Scheduling)
+
+	gsSet.ApplyDefaults()
+	assert.Equal(t, apis.Packed, gsSet.Spec.Scheduling)
+	assert.Equal(t, pkg.Version, gsSet.ObjectMeta.Annotations[stable.VersionAnnotation])
+
+	gsSet = &GameServerSet{Spec: GameServerSetSpec{Scheduling: apis.Distributed}}
+	gsSet.ApplyDefaults()
+	assert.Equal(t, apis.Distributed, gsSet.Spec.Scheduling)
+}
+
 func TestGameServerSetGameServer(t *testing.T) {
 	gsSet := GameServerSet{
 		ObjectMeta: metav1.ObjectMeta{
@@ -57,6 +75,24 @@ func TestGameServerSetGameServer(t *testing.T) {
 
 	assert.Equal(t, gs.Spec, gsSet.Spec.Template.Spec)
 	assert.True(t, metav1.IsControlledBy(gs, &gsSet))
+
+	gsSet.Spec.PortRange = "premium"
+	gsSet.Spec.Template.Spec.Ports = append(gsSet.Spec.Template.Spec.Ports, GameServerPort{ContainerPort: 1235, HostPort: 1235, PortPolicy: Static})
+	gs = gsSet.GameServer()
+	assert.Equal(t, "premium", gs.Spec.Ports[0].Range)
+	assert.Equal(t, "", gs.Spec.Ports[1].Range)
+	assert.Equal(t, "", gsSet.Spec.Template.Spec.Ports[0].Range)
+}
+
+func TestGameServerSetRevision(t *testing.T) {
+	gsSet := &GameServerSet{}
+	assert.Equal(t, int64(0), gsSet.Revision())
+
+	gsSet.ObjectMeta.Annotations = map[string]string{FleetRevisionAnnotation: "4"}
+	assert.Equal(t, int64(4), gsSet.Revision())
+
+	gsSet.ObjectMeta.Annotations[FleetRevisionAnnotation] = "nope"
+	assert.Equal(t, int64(0), gsSet.Revision())
 }
 
 // TestGameServerSetValidateUpdate test GameServerSet Validate() and ValidateUpdate()
@@ -88,6 +124,25 @@ func TestGameServerSetValidateUpdate(t *testing.T) {
 	assert.Len(t, causes, 1)
 	assert.Equal(t, "template", causes[0].Field)
 
+	newGSS = gsSet.DeepCopy()
+	newGSS.Spec.PortRange = "premium"
+	causes, ok = gsSet.ValidateUpdate(newGSS)
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "portRange", causes[0].Field)
+
+	newGSS = gsSet.DeepCopy()
+	newGSS.Spec.ScaleDownPreference = ScaleDownNewestFirst
+	causes, ok = gsSet.ValidateUpdate(newGSS)
+	assert.True(t, ok)
+	assert.Empty(t, causes)
+
+	newGSS.Spec.ScaleDownPreference = "Random"
+	causes, ok = gsSet.ValidateUpdate(newGSS)
+	assert.False(t, ok)
+	assert.Len(t, causes, 1)
+	assert.Equal(t, "scaleDownPreference", causes[0].Field)
+
 	newGSS = gsSet.DeepCopy()
 	nameLen := validation.LabelValueMaxLength + 1
 	bytes := make([]byte, nameLen)
@@ -123,5 +178,5 @@ func TestGameServerSetValidateUpdate(t *testing.T) {
 
 	assert.False(t, ok)
 	assert.Len(t, causes, 2)
-	assert.Equal(t, "container", causes[0].Field)
+	assert.Equal(t, "template.spec.container", causes[0].Field)
 }
@@ -21,16 +21,128 @@
 package v1alpha1
 
 import (
+	core_v1 "k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AllocationOverflow) DeepCopyInto(out *AllocationOverflow) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AllocationOverflow.
+func (in *AllocationOverflow) DeepCopy() *AllocationOverflow {
+	if in == nil {
+		return nil
+	}
+	out := new(AllocationOverflow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AggregatedCounterStatus) DeepCopyInto(out *AggregatedCounterStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AggregatedCounterStatus.
+func (in *AggregatedCounterStatus) DeepCopy() *AggregatedCounterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AggregatedCounterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AggregatedListStatus) DeepCopyInto(out *AggregatedListStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AggregatedListStatus.
+func (in *AggregatedListStatus) DeepCopy() *AggregatedListStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AggregatedListStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AggregatedPlayerStatus) DeepCopyInto(out *AggregatedPlayerStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AggregatedPlayerStatus.
+func (in *AggregatedPlayerStatus) DeepCopy() *AggregatedPlayerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AggregatedPlayerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CounterStatus) DeepCopyInto(out *CounterStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CounterStatus.
+func (in *CounterStatus) DeepCopy() *CounterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CounterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Eviction) DeepCopyInto(out *Eviction) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Eviction.
+func (in *Eviction) DeepCopy() *Eviction {
+	if in == nil {
+		return nil
+	}
+	out := new(Eviction)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Fleet) DeepCopyInto(out *Fleet) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -52,6 +164,78 @@ func (in *Fleet) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FleetCanary) DeepCopyInto(out *FleetCanary) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetCanary.
+func (in *FleetCanary) DeepCopy() *FleetCanary {
+	if in == nil {
+		return nil
+	}
+	out := new(FleetCanary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FleetCondition) DeepCopyInto(out *FleetCondition) {
+	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetCondition.
+func (in *FleetCondition) DeepCopy() *FleetCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(FleetCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FleetDisruptionBudget) DeepCopyInto(out *FleetDisruptionBudget) {
+	*out = *in
+	if in.MinAvailable != nil {
+		in, out := &in.MinAvailable, &out.MinAvailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetDisruptionBudget.
+func (in *FleetDisruptionBudget) DeepCopy() *FleetDisruptionBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(FleetDisruptionBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FleetHealthDefaults) DeepCopyInto(out *FleetHealthDefaults) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetHealthDefaults.
+func (in *FleetHealthDefaults) DeepCopy() *FleetHealthDefaults {
+	if in == nil {
+		return nil
+	}
+	out := new(FleetHealthDefaults)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FleetList) DeepCopyInto(out *FleetList) {
 	*out = *in
@@ -85,10 +269,62 @@ func (in *FleetList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FleetRevision) DeepCopyInto(out *FleetRevision) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetRevision.
+func (in *FleetRevision) DeepCopy() *FleetRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(FleetRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FleetSpec) DeepCopyInto(out *FleetSpec) {
 	*out = *in
 	in.Strategy.DeepCopyInto(&out.Strategy)
+	if in.UpdateWindows != nil {
+		in, out := &in.UpdateWindows, &out.UpdateWindows
+		*out = make([]UpdateWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.DisruptionBudget != nil {
+		in, out := &in.DisruptionBudget, &out.DisruptionBudget
+		*out = new(FleetDisruptionBudget)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthDefaults != nil {
+		in, out := &in.HealthDefaults, &out.HealthDefaults
+		*out = new(FleetHealthDefaults)
+		**out = **in
+	}
+	if in.AllocationOverflow != nil {
+		in, out := &in.AllocationOverflow, &out.AllocationOverflow
+		*out = new(AllocationOverflow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(FleetCanary)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ProgressDeadlineSeconds != nil {
+		in, out := &in.ProgressDeadlineSeconds, &out.ProgressDeadlineSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RevisionHistoryLimit != nil {
+		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
 	in.Template.DeepCopyInto(&out.Template)
 	return
 }
@@ -106,6 +342,43 @@ func (in *FleetSpec) DeepCopy() *FleetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FleetStatus) DeepCopyInto(out *FleetStatus) {
 	*out = *in
+	if in.Players != nil {
+		in, out := &in.Players, &out.Players
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(AggregatedPlayerStatus)
+			**out = **in
+		}
+	}
+	if in.Counters != nil {
+		in, out := &in.Counters, &out.Counters
+		*out = make(map[string]AggregatedCounterStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Lists != nil {
+		in, out := &in.Lists, &out.Lists
+		*out = make(map[string]AggregatedListStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RevisionHistory != nil {
+		in, out := &in.RevisionHistory, &out.RevisionHistory
+		*out = make([]FleetRevision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]FleetCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -147,6 +420,23 @@ func (in *GameServer) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerCondition) DeepCopyInto(out *GameServerCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerCondition.
+func (in *GameServerCondition) DeepCopy() *GameServerCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GameServerList) DeepCopyInto(out *GameServerList) {
 	*out = *in
@@ -202,7 +492,7 @@ func (in *GameServerSet) DeepCopyInto(out *GameServerSet) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -224,6 +514,22 @@ func (in *GameServerSet) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerSetCreationFailure) DeepCopyInto(out *GameServerSetCreationFailure) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerSetCreationFailure.
+func (in *GameServerSetCreationFailure) DeepCopy() *GameServerSetCreationFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerSetCreationFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GameServerSetList) DeepCopyInto(out *GameServerSetList) {
 	*out = *in
@@ -260,6 +566,11 @@ func (in *GameServerSetList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GameServerSetSpec) DeepCopyInto(out *GameServerSetSpec) {
 	*out = *in
+	if in.AllocationOverflow != nil {
+		in, out := &in.AllocationOverflow, &out.AllocationOverflow
+		*out = new(AllocationOverflow)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Template.DeepCopyInto(&out.Template)
 	return
 }
@@ -277,6 +588,34 @@ func (in *GameServerSetSpec) DeepCopy() *GameServerSetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GameServerSetStatus) DeepCopyInto(out *GameServerSetStatus) {
 	*out = *in
+	if in.Players != nil {
+		in, out := &in.Players, &out.Players
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(AggregatedPlayerStatus)
+			**out = **in
+		}
+	}
+	if in.Counters != nil {
+		in, out := &in.Counters, &out.Counters
+		*out = make(map[string]AggregatedCounterStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Lists != nil {
+		in, out := &in.Lists, &out.Lists
+		*out = make(map[string]AggregatedListStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.CreationFailures != nil {
+		in, out := &in.CreationFailures, &out.CreationFailures
+		*out = make([]GameServerSetCreationFailure, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -298,7 +637,57 @@ func (in *GameServerSpec) DeepCopyInto(out *GameServerSpec) {
 		*out = make([]GameServerPort, len(*in))
 		copy(*out, *in)
 	}
-	out.Health = in.Health
+	in.Health.DeepCopyInto(&out.Health)
+	if in.Counters != nil {
+		in, out := &in.Counters, &out.Counters
+		*out = make(map[string]CounterStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Lists != nil {
+		in, out := &in.Lists, &out.Lists
+		*out = make(map[string]ListStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Players != nil {
+		in, out := &in.Players, &out.Players
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(PlayerSpec)
+			**out = **in
+		}
+	}
+	if in.Eviction != nil {
+		in, out := &in.Eviction, &out.Eviction
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(Eviction)
+			**out = **in
+		}
+	}
+	if in.PreReady != nil {
+		in, out := &in.PreReady, &out.PreReady
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(PreReadyWebhook)
+			**out = **in
+		}
+	}
+	if in.SDKToken != nil {
+		in, out := &in.SDKToken, &out.SDKToken
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(SDKToken)
+			**out = **in
+		}
+	}
 	in.Template.DeepCopyInto(&out.Template)
 	return
 }
@@ -329,6 +718,50 @@ func (in *GameServerStatus) DeepCopyInto(out *GameServerStatus) {
 			*out = (*in).DeepCopy()
 		}
 	}
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]core_v1.NodeAddress, len(*in))
+		copy(*out, *in)
+	}
+	if in.Counters != nil {
+		in, out := &in.Counters, &out.Counters
+		*out = make(map[string]CounterStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Lists != nil {
+		in, out := &in.Lists, &out.Lists
+		*out = make(map[string]ListStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Players != nil {
+		in, out := &in.Players, &out.Players
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(PlayerStatus)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.SDK != nil {
+		in, out := &in.SDK, &out.SDK
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(SDKStatus)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]GameServerCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -379,6 +812,11 @@ func (in *GameServerTemplateSpec) DeepCopy() *GameServerTemplateSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Health) DeepCopyInto(out *Health) {
 	*out = *in
+	if in.ShutdownExitCodes != nil {
+		in, out := &in.ShutdownExitCodes, &out.ShutdownExitCodes
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -391,3 +829,152 @@ func (in *Health) DeepCopy() *Health {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListStatus) DeepCopyInto(out *ListStatus) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListStatus.
+func (in *ListStatus) DeepCopy() *ListStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ListStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlayerSpec) DeepCopyInto(out *PlayerSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlayerSpec.
+func (in *PlayerSpec) DeepCopy() *PlayerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PlayerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlayerStatus) DeepCopyInto(out *PlayerStatus) {
+	*out = *in
+	if in.IDs != nil {
+		in, out := &in.IDs, &out.IDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlayerStatus.
+func (in *PlayerStatus) DeepCopy() *PlayerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PlayerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreReadyService) DeepCopyInto(out *PreReadyService) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreReadyService.
+func (in *PreReadyService) DeepCopy() *PreReadyService {
+	if in == nil {
+		return nil
+	}
+	out := new(PreReadyService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreReadyWebhook) DeepCopyInto(out *PreReadyWebhook) {
+	*out = *in
+	out.Service = in.Service
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreReadyWebhook.
+func (in *PreReadyWebhook) DeepCopy() *PreReadyWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(PreReadyWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SDKStatus) DeepCopyInto(out *SDKStatus) {
+	*out = *in
+	in.ConnectedTime.DeepCopyInto(&out.ConnectedTime)
+	if in.LastPingTime != nil {
+		in, out := &in.LastPingTime, &out.LastPingTime
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = (*in).DeepCopy()
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SDKStatus.
+func (in *SDKStatus) DeepCopy() *SDKStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SDKStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SDKToken) DeepCopyInto(out *SDKToken) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SDKToken.
+func (in *SDKToken) DeepCopy() *SDKToken {
+	if in == nil {
+		return nil
+	}
+	out := new(SDKToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdateWindow) DeepCopyInto(out *UpdateWindow) {
+	*out = *in
+	out.Duration = in.Duration
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateWindow.
+func (in *UpdateWindow) DeepCopy() *UpdateWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdateWindow)
+	in.DeepCopyInto(out)
+	return out
+}
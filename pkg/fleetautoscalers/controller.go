@@ -17,6 +17,7 @@ package fleetautoscalers
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"time"
 
 	"agones.dev/agones/pkg/apis/autoscaling"
@@ -34,6 +35,7 @@ import (
 	"agones.dev/agones/pkg/util/webhooks"
 	"agones.dev/agones/pkg/util/workerqueue"
 	"github.com/heptiolabs/healthcheck"
+	"github.com/mattbaird/jsonpatch"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	admv1beta1 "k8s.io/api/admission/v1beta1"
@@ -43,9 +45,12 @@ import (
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corev1lister "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 )
@@ -54,35 +59,50 @@ import (
 type Controller struct {
 	baseLogger            *logrus.Entry
 	crdGetter             v1beta1.CustomResourceDefinitionInterface
+	crdWaitTimeout        time.Duration
 	fleetGetter           typedstablev1alpha1.FleetsGetter
 	fleetLister           listerstablev1alpha1.FleetLister
 	fleetSynced           cache.InformerSynced
 	fleetAutoscalerGetter typedautoscalingv1.FleetAutoscalersGetter
 	fleetAutoscalerLister listerautoscalingv1.FleetAutoscalerLister
 	fleetAutoscalerSynced cache.InformerSynced
+	secretLister          corev1lister.SecretLister
+	secretSynced          cache.InformerSynced
+	failureCounter        *FailureCounter
+	stabilizer            *stabilizer
 	workerqueue           *workerqueue.WorkerQueue
 	recorder              record.EventRecorder
 }
 
-// NewController returns a controller for a FleetAutoscaler
+// NewController returns a controller for a FleetAutoscaler.
+// If failureCounter is nil, allocation failures are not counted, and AllocationFailure policies are rejected.
 func NewController(
 	wh *webhooks.WebHook,
 	health healthcheck.Handler,
+	crdWaitTimeout time.Duration,
+	failureCounter *FailureCounter,
 	kubeClient kubernetes.Interface,
+	kubeInformerFactory informers.SharedInformerFactory,
 	extClient extclientset.Interface,
 	agonesClient versioned.Interface,
 	agonesInformerFactory externalversions.SharedInformerFactory) *Controller {
 
 	autoscaler := agonesInformerFactory.Autoscaling().V1().FleetAutoscalers()
 	fleetInformer := agonesInformerFactory.Stable().V1alpha1().Fleets()
+	secrets := kubeInformerFactory.Core().V1().Secrets()
 	c := &Controller{
 		crdGetter:             extClient.ApiextensionsV1beta1().CustomResourceDefinitions(),
+		crdWaitTimeout:        crdWaitTimeout,
 		fleetGetter:           agonesClient.StableV1alpha1(),
 		fleetLister:           fleetInformer.Lister(),
 		fleetSynced:           fleetInformer.Informer().HasSynced,
 		fleetAutoscalerGetter: agonesClient.AutoscalingV1(),
 		fleetAutoscalerLister: autoscaler.Lister(),
 		fleetAutoscalerSynced: autoscaler.Informer().HasSynced,
+		secretLister:          secrets.Lister(),
+		secretSynced:          secrets.Informer().HasSynced,
+		failureCounter:        failureCounter,
+		stabilizer:            newStabilizer(),
 	}
 	c.baseLogger = runtime.NewLoggerWithType(c)
 	c.workerqueue = workerqueue.NewWorkerQueue(c.syncFleetAutoscaler, c.baseLogger, logfields.FleetAutoscalerKey, autoscaling.GroupName+".FleetAutoscalerController")
@@ -94,29 +114,39 @@ func NewController(
 	c.recorder = eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "fleetautoscaler-controller"})
 
 	kind := autoscalingv1.Kind("FleetAutoscaler")
+	wh.AddHandler("/mutate", kind, admv1beta1.Create, c.mutationHandler)
+	wh.AddHandler("/mutate", kind, admv1beta1.Update, c.mutationHandler)
 	wh.AddHandler("/validate", kind, admv1beta1.Create, c.validationHandler)
 	wh.AddHandler("/validate", kind, admv1beta1.Update, c.validationHandler)
 
 	autoscaler.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: c.workerqueue.Enqueue,
-		UpdateFunc: func(_, newObj interface{}) {
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// FleetAutoscalers are synced every sync interval, rather than on each resync of the informer
+			if oldObj.(*autoscalingv1.FleetAutoscaler).ObjectMeta.ResourceVersion == newObj.(*autoscalingv1.FleetAutoscaler).ObjectMeta.ResourceVersion {
+				return
+			}
 			c.workerqueue.Enqueue(newObj)
 		},
 	})
 
+	if failureCounter != nil {
+		failureCounter.AddHandler(c.enqueueAllocationFailureAutoscalers)
+	}
+
 	return c
 }
 
 // Run the FleetAutoscaler controller. Will block until stop is closed.
 // Runs threadiness number workers to process the rate limited queue
 func (c *Controller) Run(workers int, stop <-chan struct{}) error {
-	err := crd.WaitForEstablishedCRD(c.crdGetter, "fleetautoscalers."+autoscaling.GroupName, c.baseLogger)
+	err := crd.WaitForEstablishedCRD(c.crdGetter, "fleetautoscalers."+autoscaling.GroupName, c.crdWaitTimeout, c.baseLogger)
 	if err != nil {
 		return err
 	}
 
 	c.baseLogger.Info("Wait for cache sync")
-	if !cache.WaitForCacheSync(stop, c.fleetSynced, c.fleetAutoscalerSynced) {
+	if !cache.WaitForCacheSync(stop, c.fleetSynced, c.fleetAutoscalerSynced, c.secretSynced) {
 		return errors.New("failed to wait for caches to sync")
 	}
 
@@ -136,6 +166,44 @@ func (c *Controller) loggerForFleetAutoscaler(fas *autoscalingv1.FleetAutoscaler
 	return c.loggerForFleetAutoscalerKey(fasName).WithField("fas", fas)
 }
 
+// mutationHandler will intercept when a FleetAutoscaler is created or updated,
+// and set its default values
+// nolint:dupl
+func (c *Controller) mutationHandler(review admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error) {
+	obj := review.Request.Object
+	fas := &autoscalingv1.FleetAutoscaler{}
+	err := json.Unmarshal(obj.Raw, fas)
+	if err != nil {
+		c.baseLogger.WithField("review", review).WithError(err).Info("mutationHandler")
+		return review, errors.Wrapf(err, "error unmarshalling original FleetAutoscaler json: %s", obj.Raw)
+	}
+
+	fas.ApplyDefaults()
+
+	newFas, err := json.Marshal(fas)
+	if err != nil {
+		return review, errors.Wrapf(err, "error marshalling default applied FleetAutoscaler %s to json", fas.ObjectMeta.Name)
+	}
+
+	patch, err := jsonpatch.CreatePatch(obj.Raw, newFas)
+	if err != nil {
+		return review, errors.Wrapf(err, "error creating patch for FleetAutoscaler %s", fas.ObjectMeta.Name)
+	}
+
+	jsn, err := json.Marshal(patch)
+	if err != nil {
+		return review, errors.Wrapf(err, "error creating json for patch for FleetAutoscaler %s", fas.ObjectMeta.Name)
+	}
+
+	c.loggerForFleetAutoscaler(fas).WithField("patch", string(jsn)).Info("patch created!")
+
+	pt := admv1beta1.PatchTypeJSONPatch
+	review.Response.PatchType = &pt
+	review.Response.Patch = jsn
+
+	return review, nil
+}
+
 // validationHandler will intercept when a FleetAutoscaler is created, and
 // validate its settings.
 func (c *Controller) validationHandler(review admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error) {
@@ -147,8 +215,18 @@ func (c *Controller) validationHandler(review admv1beta1.AdmissionReview) (admv1
 		return review, errors.Wrapf(err, "error unmarshalling original FleetAutoscaler json: %s", obj.Raw)
 	}
 
-	var causes []metav1.StatusCause
+	causes, err := crd.UnknownFieldCauses(reflect.TypeOf(autoscalingv1.FleetAutoscaler{}), obj.Raw)
+	if err != nil {
+		return review, errors.Wrapf(err, "error checking FleetAutoscaler json for unknown fields: %s", obj.Raw)
+	}
 	causes = fas.Validate(causes)
+	if c.failureCounter == nil && hasAllocationFailurePolicy(&fas.Spec.Policy) {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueNotSupported,
+			Field:   "type",
+			Message: "AllocationFailure policies are not supported when the controller runs with leader election, as allocation failures are only counted by the replica that serves the allocation",
+		})
+	}
 	if len(causes) != 0 {
 		review.Response.Allowed = false
 		details := metav1.StatusDetails{
@@ -168,6 +246,20 @@ func (c *Controller) validationHandler(review admv1beta1.AdmissionReview) (admv1
 	return review, nil
 }
 
+// warnMaxReplicasBelowFleet records a warning event if the maxReplicas of a Buffer policy are below the current
+// replicas of the FleetAutoscaler's fleet, as the fleet will be scaled down to them, Allocated GameServers aside.
+// This does not make the FleetAutoscaler invalid, as the fleet may be being scaled down on purpose.
+func (c *Controller) warnMaxReplicasBelowFleet(fas *autoscalingv1.FleetAutoscaler, fleet *stablev1alpha1.Fleet) {
+	b := fas.Spec.Policy.Buffer
+	if fas.Spec.Policy.Type != autoscalingv1.BufferPolicyType || b == nil {
+		return
+	}
+	if fleet.Spec.Replicas > b.MaxReplicas {
+		c.recorder.Eventf(fas, corev1.EventTypeWarning, "MaxReplicasBelowFleet",
+			"maxReplicas %d is below the %d replicas of fleet %s, which will be scaled down to it", b.MaxReplicas, fleet.Spec.Replicas, fleet.ObjectMeta.Name)
+	}
+}
+
 // syncFleetAutoscaler scales the attached fleet and
 // synchronizes the FleetAutoscaler CRD
 func (c *Controller) syncFleetAutoscaler(key string) error {
@@ -185,11 +277,15 @@ func (c *Controller) syncFleetAutoscaler(key string) error {
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			c.loggerForFleetAutoscalerKey(key).Info(fmt.Sprintf("FleetAutoscaler %s from namespace %s is no longer available for syncing", name, namespace))
+			c.stabilizer.forget(key)
 			return nil
 		}
 		return errors.Wrapf(err, "error retrieving FleetAutoscaler %s from namespace %s", name, namespace)
 	}
 
+	// sync again after the sync interval, whatever happens in this sync
+	c.workerqueue.EnqueueAfter(fas, fas.Spec.SyncInterval())
+
 	// Retrieve the fleet by spec name
 	fleet, err := c.fleetLister.Fleets(namespace).Get(fas.Spec.FleetName)
 	if err != nil {
@@ -210,8 +306,10 @@ func (c *Controller) syncFleetAutoscaler(key string) error {
 		return err
 	}
 
+	c.warnMaxReplicasBelowFleet(fas, fleet)
+
 	currentReplicas := fleet.Status.Replicas
-	desiredReplicas, scalingLimited, err := computeDesiredFleetSize(fas, fleet)
+	desiredReplicas, scalingLimited, err := computeDesiredFleetSize(fas, fleet, c.secretLister.Secrets(fas.ObjectMeta.Namespace), c.failureCounter)
 	if err != nil {
 		c.recorder.Eventf(fas, corev1.EventTypeWarning, "FleetAutoscaler",
 			"Error calculating desired fleet size on FleetAutoscaler %s. Error: %s", fas.ObjectMeta.Name, err.Error())
@@ -221,6 +319,7 @@ func (c *Controller) syncFleetAutoscaler(key string) error {
 		}
 		return errors.Wrapf(err, "error calculating autoscaling fleet: %s", fleet.ObjectMeta.Name)
 	}
+	desiredReplicas = c.stabilizer.stabilize(key, desiredReplicas, fleet.Spec.Replicas, fas.Spec.ScaleDownStabilizationWindow())
 
 	// Scale the fleet to the new size
 	if err = c.scaleFleet(fas, fleet, desiredReplicas); err != nil {
@@ -230,6 +329,33 @@ func (c *Controller) syncFleetAutoscaler(key string) error {
 	return c.updateStatus(fas, currentReplicas, desiredReplicas, desiredReplicas != fleet.Spec.Replicas, scalingLimited)
 }
 
+// enqueueAllocationFailureAutoscalers syncs the FleetAutoscalers with an AllocationFailure policy
+// of the Fleet that an allocation failed from straight away, so the Fleet is scaled up quickly
+func (c *Controller) enqueueAllocationFailureAutoscalers(namespace, fleetName string) {
+	list, err := c.fleetAutoscalerLister.FleetAutoscalers(namespace).List(labels.Everything())
+	if err != nil {
+		c.baseLogger.WithError(err).WithField("namespace", namespace).Warn("could not list fleetautoscalers")
+		return
+	}
+	for _, fas := range list {
+		if fas.Spec.FleetName == fleetName && hasAllocationFailurePolicy(&fas.Spec.Policy) {
+			c.workerqueue.Enqueue(fas)
+		}
+	}
+}
+
+// hasAllocationFailurePolicy returns whether the policy is an allocation failure policy, or a chain with one
+func hasAllocationFailurePolicy(p *autoscalingv1.FleetAutoscalerPolicy) bool {
+	if p.Type == autoscalingv1.ChainPolicyType && p.Chain != nil {
+		for i := range p.Chain.Policies {
+			if p.Chain.Policies[i].Type == autoscalingv1.AllocationFailurePolicyType {
+				return true
+			}
+		}
+	}
+	return p.Type == autoscalingv1.AllocationFailurePolicyType
+}
+
 // scaleFleet scales the fleet of the autoscaler to a new number of replicas
 func (c *Controller) scaleFleet(fas *autoscalingv1.FleetAutoscaler, f *stablev1alpha1.Fleet, replicas int32) error {
 	if replicas != f.Spec.Replicas {
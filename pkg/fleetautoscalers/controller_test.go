@@ -18,13 +18,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
 	stablev1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
 	agtesting "agones.dev/agones/pkg/testing"
 	"agones.dev/agones/pkg/util/webhooks"
 	"github.com/heptiolabs/healthcheck"
+	"github.com/mattbaird/jsonpatch"
 	"github.com/stretchr/testify/assert"
 	admv1beta1 "k8s.io/api/admission/v1beta1"
 	admregv1b "k8s.io/api/admissionregistration/v1beta1"
@@ -74,6 +77,123 @@ func TestControllerCreationValidationHandler(t *testing.T) {
 		assert.Equal(t, metav1.StatusReasonInvalid, result.Response.Result.Reason)
 		assert.NotEmpty(t, result.Response.Result.Details)
 	})
+
+	t.Run("fleet autoscaler with an unknown field", func(t *testing.T) {
+		c, m := newFakeController()
+		fas, _ := defaultFixtures()
+
+		_, cancel := agtesting.StartInformers(m)
+		defer cancel()
+
+		review, err := newAdmissionReview(*fas)
+		assert.Nil(t, err)
+		review.Request.Object.Raw = []byte(strings.Replace(string(review.Request.Object.Raw), `"maxReplicas"`, `"maxReplica"`, 1))
+
+		result, err := c.validationHandler(review)
+		assert.Nil(t, err)
+		assert.False(t, result.Response.Allowed, fmt.Sprintf("%#v", result.Response))
+		assert.Equal(t, metav1.StatusReasonInvalid, result.Response.Result.Reason)
+		if assert.NotEmpty(t, result.Response.Result.Details.Causes) {
+			assert.Equal(t, "spec.policy.buffer.maxReplica", result.Response.Result.Details.Causes[0].Field)
+		}
+	})
+
+	t.Run("allocation failure policy", func(t *testing.T) {
+		fas, _ := defaultFixtures()
+		fas.Spec.Policy = autoscalingv1.FleetAutoscalerPolicy{
+			Type: autoscalingv1.AllocationFailurePolicyType,
+			AllocationFailure: &autoscalingv1.AllocationFailurePolicy{
+				Buffer:             autoscalingv1.BufferPolicy{BufferSize: intstr.FromInt(5), MinReplicas: 10, MaxReplicas: 100},
+				WindowSeconds:      60,
+				ReplicasPerFailure: 2,
+			},
+		}
+		review, err := newAdmissionReview(*fas)
+		assert.Nil(t, err)
+
+		// allocation failures are counted
+		c, _ := newFakeController()
+		c.failureCounter = NewFailureCounter()
+		result, err := c.validationHandler(review)
+		assert.Nil(t, err)
+		assert.True(t, result.Response.Allowed, fmt.Sprintf("%#v", result.Response))
+
+		// allocation failures are not counted, e.g. with leader election
+		c, _ = newFakeController()
+		result, err = c.validationHandler(review)
+		assert.Nil(t, err)
+		assert.False(t, result.Response.Allowed, fmt.Sprintf("%#v", result.Response))
+		if assert.Len(t, result.Response.Result.Details.Causes, 1) {
+			assert.Equal(t, metav1.CauseTypeFieldValueNotSupported, result.Response.Result.Details.Causes[0].Type)
+		}
+	})
+}
+
+func TestControllerMutationHandler(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newFakeController()
+	fas, _ := defaultFixtures()
+	fas.Spec.Policy.Type = ""
+	fas.Spec.Policy.Buffer.BufferSize = intstr.FromString("20%")
+
+	review, err := newAdmissionReview(*fas)
+	assert.Nil(t, err)
+
+	result, err := c.mutationHandler(review)
+	assert.Nil(t, err)
+	assert.True(t, result.Response.Allowed)
+	assert.Equal(t, admv1beta1.PatchTypeJSONPatch, *result.Response.PatchType)
+
+	patch := &jsonpatch.ByPath{}
+	err = json.Unmarshal(result.Response.Patch, patch)
+	assert.Nil(t, err)
+	found := map[string]interface{}{}
+	for _, p := range *patch {
+		assert.Contains(t, []string{"add", "replace"}, p.Operation)
+		found[p.Path] = p.Value
+	}
+	assert.Equal(t, string(autoscalingv1.BufferPolicyType), found["/spec/policy/type"])
+	// minReplicas is left to validation, rather than defaulted
+	assert.NotContains(t, found, "/spec/policy/buffer/minReplicas")
+}
+
+func TestControllerSyncFleetAutoscalerMaxReplicasBelowFleet(t *testing.T) {
+	t.Parallel()
+
+	for name, maxReplicas := range map[string]int32{"below fleet": 5, "above fleet": 100} {
+		t.Run(name, func(t *testing.T) {
+			c, m := newFakeController()
+			fas, f := defaultFixtures()
+			fas.Spec.Policy.Buffer.BufferSize = intstr.FromInt(2)
+			fas.Spec.Policy.Buffer.MaxReplicas = maxReplicas
+
+			m.AgonesClient.AddReactor("list", "fleetautoscalers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, &autoscalingv1.FleetAutoscalerList{Items: []autoscalingv1.FleetAutoscaler{*fas}}, nil
+			})
+			m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, &stablev1alpha1.FleetList{Items: []stablev1alpha1.Fleet{*f}}, nil
+			})
+			m.AgonesClient.AddReactor("update", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, action.(k8stesting.UpdateAction).GetObject(), nil
+			})
+			m.AgonesClient.AddReactor("update", "fleetautoscalers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, action.(k8stesting.UpdateAction).GetObject(), nil
+			})
+
+			_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.fleetAutoscalerSynced)
+			defer cancel()
+
+			err := c.syncFleetAutoscaler("default/fas-1")
+			assert.Nil(t, err)
+
+			if maxReplicas < f.Spec.Replicas {
+				agtesting.AssertEventContains(t, m.FakeRecorder.Events, "MaxReplicasBelowFleet")
+			} else {
+				assert.NotContains(t, <-m.FakeRecorder.Events, "MaxReplicasBelowFleet")
+			}
+		})
+	}
 }
 
 func TestWebhookControllerCreationValidationHandler(t *testing.T) {
@@ -290,6 +410,49 @@ func TestControllerSyncFleetAutoscaler(t *testing.T) {
 
 		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "FailedGetFleet")
 	})
+
+	t.Run("scaling down is stabilized", func(t *testing.T) {
+		t.Parallel()
+		c, m := newFakeController()
+		fas, f := defaultFixtures()
+		fas.Spec.Policy.Buffer.BufferSize = intstr.FromInt(8)
+		fas.Spec.ScaleDownStabilizationWindowSeconds = 300
+
+		f.Spec.Replicas = 20
+		f.Status.Replicas = 20
+		f.Status.AllocatedReplicas = 5
+		f.Status.ReadyReplicas = 15
+
+		// the policy computed the current size of the fleet within the window
+		c.stabilizer.stabilize("default/fas-1", 20, 20, fas.Spec.ScaleDownStabilizationWindow())
+
+		m.AgonesClient.AddReactor("list", "fleetautoscalers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &autoscalingv1.FleetAutoscalerList{Items: []autoscalingv1.FleetAutoscaler{*fas}}, nil
+		})
+
+		m.AgonesClient.AddReactor("update", "fleetautoscalers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			ca := action.(k8stesting.UpdateAction)
+			fas := ca.GetObject().(*autoscalingv1.FleetAutoscaler)
+			assert.Equal(t, fas.Status.DesiredReplicas, int32(20))
+			return true, fas, nil
+		})
+
+		m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &stablev1alpha1.FleetList{Items: []stablev1alpha1.Fleet{*f}}, nil
+		})
+
+		m.AgonesClient.AddReactor("update", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "fleet should not update")
+			return false, nil, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.fleetAutoscalerSynced)
+		defer cancel()
+
+		err := c.syncFleetAutoscaler("default/fas-1")
+		assert.Nil(t, err)
+		agtesting.AssertNoEvent(t, m.FakeRecorder.Events)
+	})
 }
 
 func TestControllerScaleFleet(t *testing.T) {
@@ -507,7 +670,7 @@ func defaultWebhookFixtures() (*autoscalingv1.FleetAutoscaler, *stablev1alpha1.F
 func newFakeController() (*Controller, agtesting.Mocks) {
 	m := agtesting.NewMocks()
 	wh := webhooks.NewWebHook(http.NewServeMux())
-	c := NewController(wh, healthcheck.NewHandler(), m.KubeClient, m.ExtClient, m.AgonesClient, m.AgonesInformerFactory)
+	c := NewController(wh, healthcheck.NewHandler(), time.Minute, nil, m.KubeClient, m.KubeInformerFactory, m.ExtClient, m.AgonesClient, m.AgonesInformerFactory)
 	c.recorder = m.FakeRecorder
 	return c, m
 }
@@ -530,3 +693,17 @@ func newAdmissionReview(fas autoscalingv1.FleetAutoscaler) (admv1beta1.Admission
 	}
 	return review, err
 }
+
+func TestHasAllocationFailurePolicy(t *testing.T) {
+	t.Parallel()
+
+	allocationFailure := autoscalingv1.FleetAutoscalerPolicy{Type: autoscalingv1.AllocationFailurePolicyType}
+	buffer := autoscalingv1.FleetAutoscalerPolicy{Type: autoscalingv1.BufferPolicyType}
+
+	assert.True(t, hasAllocationFailurePolicy(&allocationFailure))
+	assert.False(t, hasAllocationFailurePolicy(&buffer))
+	assert.True(t, hasAllocationFailurePolicy(&autoscalingv1.FleetAutoscalerPolicy{Type: autoscalingv1.ChainPolicyType,
+		Chain: &autoscalingv1.ChainPolicy{Policies: []autoscalingv1.FleetAutoscalerPolicy{buffer, allocationFailure}}}))
+	assert.False(t, hasAllocationFailurePolicy(&autoscalingv1.FleetAutoscalerPolicy{Type: autoscalingv1.ChainPolicyType,
+		Chain: &autoscalingv1.ChainPolicy{Policies: []autoscalingv1.FleetAutoscalerPolicy{buffer}}}))
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleetautoscalers
+
+import (
+	"sync"
+	"time"
+
+	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
+)
+
+// failureRetention is how long allocation failures are kept for, which is the longest
+// window that a FleetAutoscaler can scale up for them
+const failureRetention = autoscalingv1.MaxAllocationFailureWindowSeconds * time.Second
+
+// FailureCounter counts the allocations from each Fleet that recently failed because it
+// had no Ready GameServers, so FleetAutoscalers can scale Fleets up when they happen.
+// The failures are only kept in memory, so they are lost when the controller restarts.
+type FailureCounter struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+	handlers []func(namespace, fleetName string)
+	now      func() time.Time
+}
+
+// NewFailureCounter returns a new FailureCounter
+func NewFailureCounter() *FailureCounter {
+	return &FailureCounter{failures: map[string][]time.Time{}, now: time.Now}
+}
+
+// AddHandler adds a handler that is called, without blocking the allocation,
+// whenever an allocation from a Fleet fails
+func (fc *FailureCounter) AddHandler(handler func(namespace, fleetName string)) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.handlers = append(fc.handlers, handler)
+}
+
+// Failures returns how many allocations from the Fleet failed within the last window.
+// Safe to call on a nil FailureCounter, which has no failures.
+func (fc *FailureCounter) Failures(namespace, fleetName string, window time.Duration) int {
+	if fc == nil {
+		return 0
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	since := fc.now().Add(-window)
+	count := 0
+	for _, t := range fc.failures[namespace+"/"+fleetName] {
+		if t.After(since) {
+			count++
+		}
+	}
+	return count
+}
+
+// RecordFailure records a failed allocation from the Fleet
+func (fc *FailureCounter) RecordFailure(namespace, fleetName string) {
+	fc.mu.Lock()
+	key := namespace + "/" + fleetName
+	now := fc.now()
+	since := now.Add(-failureRetention)
+	times := fc.failures[key]
+	i := 0
+	for i < len(times) && !times[i].After(since) {
+		i++
+	}
+	fc.failures[key] = append(times[i:], now)
+	handlers := fc.handlers
+	fc.mu.Unlock()
+
+	for _, handler := range handlers {
+		go handler(namespace, fleetName)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleetautoscalers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureCounter(t *testing.T) {
+	t.Parallel()
+
+	var nilCounter *FailureCounter
+	assert.Equal(t, 0, nilCounter.Failures("default", "fleet", time.Minute))
+
+	fc := NewFailureCounter()
+	now := time.Now()
+	fc.now = func() time.Time { return now }
+
+	handled := make(chan string, 10)
+	fc.AddHandler(func(namespace, fleetName string) {
+		handled <- namespace + "/" + fleetName
+	})
+
+	fc.RecordFailure("default", "fleet")
+	now = now.Add(30 * time.Second)
+	fc.RecordFailure("default", "fleet")
+	fc.RecordFailure("default", "other")
+
+	for i := 0; i < 3; i++ {
+		select {
+		case key := <-handled:
+			assert.Contains(t, []string{"default/fleet", "default/other"}, key)
+		case <-time.After(time.Second):
+			assert.FailNow(t, "handler was not called")
+		}
+	}
+
+	assert.Equal(t, 2, fc.Failures("default", "fleet", time.Minute))
+	assert.Equal(t, 1, fc.Failures("default", "fleet", 10*time.Second))
+	assert.Equal(t, 1, fc.Failures("default", "other", time.Minute))
+	assert.Equal(t, 0, fc.Failures("other", "fleet", time.Minute))
+
+	// failures older than the longest window are dropped
+	now = now.Add(failureRetention)
+	fc.RecordFailure("default", "fleet")
+	assert.Len(t, fc.failures["default/fleet"], 1)
+}
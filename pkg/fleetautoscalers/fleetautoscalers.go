@@ -17,8 +17,12 @@
 package fleetautoscalers
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -31,28 +35,168 @@ import (
 	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
 	stablev1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/uuid"
+	corev1lister "k8s.io/client-go/listers/core/v1"
 )
 
 var client = http.Client{
 	Timeout: 15 * time.Second,
 }
 
-// computeDesiredFleetSize computes the new desired size of the given fleet
-func computeDesiredFleetSize(fas *autoscalingv1.FleetAutoscaler, f *stablev1alpha1.Fleet) (int32, bool, error) {
+// computeDesiredFleetSize computes the new desired size of the given fleet.
+// secrets are the Secrets of the FleetAutoscaler's namespace, that a webhook policy
+// gets its client certificate and signing key from. failures are the recent allocation
+// failures that an allocation failure policy scales the fleet up for.
+func computeDesiredFleetSize(fas *autoscalingv1.FleetAutoscaler, f *stablev1alpha1.Fleet, secrets corev1lister.SecretNamespaceLister,
+	failures *FailureCounter) (int32, bool, error) {
+	return applyPolicy(&fas.Spec.Policy, f, secrets, failures)
+}
+
+// applyPolicy computes the new desired size of the fleet with the policy for its type
+func applyPolicy(p *autoscalingv1.FleetAutoscalerPolicy, f *stablev1alpha1.Fleet, secrets corev1lister.SecretNamespaceLister,
+	failures *FailureCounter) (int32, bool, error) {
 
-	switch fas.Spec.Policy.Type {
+	switch p.Type {
 	case autoscalingv1.BufferPolicyType:
-		return applyBufferPolicy(fas.Spec.Policy.Buffer, f)
+		return applyBufferPolicy(p.Buffer, f)
 	case autoscalingv1.WebhookPolicyType:
-		return applyWebhookPolicy(fas.Spec.Policy.Webhook, f)
+		return applyWebhookPolicy(p.Webhook, f, secrets)
+	case autoscalingv1.AllocationFailurePolicyType:
+		a := p.AllocationFailure
+		return applyAllocationFailurePolicy(a, f, failures.Failures(f.ObjectMeta.Namespace, f.ObjectMeta.Name, a.Window()))
+	case autoscalingv1.ChainPolicyType:
+		return applyChainPolicy(p.Chain, f, secrets, failures)
+	case autoscalingv1.CounterPolicyType:
+		return applyCounterPolicy(p.Counter, f)
+	case autoscalingv1.ListPolicyType:
+		return applyListPolicy(p.List, f)
+	}
+
+	return f.Status.Replicas, false, errors.New("wrong policy type, should be one of: Buffer, Webhook, AllocationFailure, Chain, Counter, List")
+}
+
+// applyChainPolicy evaluates the policies of the chain in order, and returns the result of the first of them
+// that computes the size of the fleet without an error, or the largest result, depending on the selection of the chain
+func applyChainPolicy(c *autoscalingv1.ChainPolicy, f *stablev1alpha1.Fleet, secrets corev1lister.SecretNamespaceLister,
+	failures *FailureCounter) (int32, bool, error) {
+
+	var replicas int32
+	var limited, found bool
+	var errs []string
+	for i := range c.Policies {
+		p := &c.Policies[i]
+		if p.Type == autoscalingv1.ChainPolicyType {
+			errs = append(errs, fmt.Sprintf("policy %d: chain policies can not be nested", i))
+			continue
+		}
+		r, l, err := applyPolicy(p, f, secrets, failures)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("policy %d (%s): %s", i, p.Type, err))
+			continue
+		}
+		if c.Selection != autoscalingv1.ChainSelectMax {
+			return r, l, nil
+		}
+		if !found || r > replicas {
+			replicas, limited, found = r, l, true
+		}
+	}
+
+	if !found {
+		return f.Status.Replicas, false, errors.Errorf("no policy of the chain could compute the size of the fleet: %s", strings.Join(errs, "; "))
+	}
+	return replicas, limited, nil
+}
+
+// applyAllocationFailurePolicy scales the fleet with the buffer of the policy, grown by
+// the replicas per failure for each of the recent failures of allocations from the fleet
+func applyAllocationFailurePolicy(a *autoscalingv1.AllocationFailurePolicy, f *stablev1alpha1.Fleet, failures int) (int32, bool, error) {
+	replicas, limited, err := applyBufferPolicy(&a.Buffer, f)
+	if err != nil || failures <= 0 {
+		return replicas, limited, err
 	}
 
-	return f.Status.Replicas, false, errors.New("wrong policy type, should be one of: Buffer, Webhook")
+	replicas += int32(failures) * a.ReplicasPerFailure
+	limited = false
+	if replicas > a.Buffer.MaxReplicas {
+		replicas = a.Buffer.MaxReplicas
+		limited = true
+	}
+	return replicas, limited, nil
+}
+
+// applyCounterPolicy scales the fleet to keep a buffer of available capacity of a counter of its GameServers
+func applyCounterPolicy(c *autoscalingv1.CounterPolicy, f *stablev1alpha1.Fleet) (int32, bool, error) {
+	counter, ok := f.Spec.Template.Spec.Counters[c.Key]
+	if !ok {
+		return f.Status.Replicas, false, errors.Errorf("the template of the fleet does not have counter %s", c.Key)
+	}
+	status := f.Status.Counters[c.Key]
+	return applyCapacityBuffer(c.MinReplicas, c.MaxReplicas, c.BufferSize, status.AllocatedCount, status.AllocatedCapacity,
+		counter.Available(), f)
 }
 
-func applyWebhookPolicy(w *autoscalingv1.WebhookPolicy, f *stablev1alpha1.Fleet) (int32, bool, error) {
+// applyListPolicy scales the fleet to keep a buffer of available capacity of a list of its GameServers
+func applyListPolicy(l *autoscalingv1.ListPolicy, f *stablev1alpha1.Fleet) (int32, bool, error) {
+	list, ok := f.Spec.Template.Spec.Lists[l.Key]
+	if !ok {
+		return f.Status.Replicas, false, errors.Errorf("the template of the fleet does not have list %s", l.Key)
+	}
+	status := f.Status.Lists[l.Key]
+	return applyCapacityBuffer(l.MinReplicas, l.MaxReplicas, l.BufferSize, status.AllocatedCount, status.AllocatedCapacity,
+		list.Available(), f)
+}
+
+// applyCapacityBuffer computes how many replicas the fleet needs to keep a buffer of available capacity,
+// when its Allocated replicas have the given count and capacity, and each of its other replicas has
+// the available capacity of a new one
+func applyCapacityBuffer(minReplicas, maxReplicas int32, bufferSize intstr.IntOrString, allocatedCount, allocatedCapacity, perReplica int64,
+	f *stablev1alpha1.Fleet) (int32, bool, error) {
+	if perReplica <= 0 {
+		return f.Status.Replicas, false, errors.New("the GameServers of the fleet are created without any available capacity")
+	}
+
+	allocatedAvailable := allocatedCapacity - allocatedCount
+	if allocatedAvailable < 0 {
+		allocatedAvailable = 0
+	}
+
+	// the available capacity that the replicas which are not Allocated need to add
+	var needed float64
+	if bufferSize.Type == intstr.Int {
+		needed = float64(int64(bufferSize.IntValue()) - allocatedAvailable)
+	} else {
+		bufferPercent, err := intstr.GetValueFromIntOrPercent(&bufferSize, 100, true)
+		if err != nil {
+			return f.Status.Replicas, false, err
+		}
+		// the available capacity must be bufferPercent of the total capacity, which grows with each replica that is added:
+		// allocatedAvailable + needed = bufferPercent/100 * (allocatedCapacity + needed)
+		needed = float64(int64(bufferPercent)*allocatedCapacity-100*allocatedAvailable) / float64(100-bufferPercent)
+	}
+
+	replicas := f.Status.AllocatedReplicas
+	if needed > 0 {
+		// use Math.Ceil to round the result up
+		replicas += int32(math.Ceil(needed / float64(perReplica)))
+	}
+
+	limited := false
+	if replicas < minReplicas {
+		replicas = minReplicas
+		limited = true
+	}
+	if replicas > maxReplicas {
+		replicas = maxReplicas
+		limited = true
+	}
+
+	return replicas, limited, nil
+}
+
+func applyWebhookPolicy(w *autoscalingv1.WebhookPolicy, f *stablev1alpha1.Fleet, secrets corev1lister.SecretNamespaceLister) (int32, bool, error) {
 	faReq := autoscalingv1.FleetAutoscaleReview{
 		Request: &autoscalingv1.FleetAutoscaleRequest{
 			UID:       uuid.NewUUID(),
@@ -77,14 +221,16 @@ func applyWebhookPolicy(w *autoscalingv1.WebhookPolicy, f *stablev1alpha1.Fleet)
 			return f.Status.Replicas, false, err
 		}
 
-		if w.Service.Namespace == "" {
-			w.Service.Namespace = "default"
+		// don't default the namespace on the policy itself, as it is shared with the informer cache
+		namespace := w.Service.Namespace
+		if namespace == "" {
+			namespace = "default"
 		}
 		scheme := "http://"
 		if w.CABundle != nil {
 			scheme = "https://"
 		}
-		urlStr = fmt.Sprintf("%s%s.%s.svc:8000/%s", scheme, w.Service.Name, w.Service.Namespace, servicePath)
+		urlStr = fmt.Sprintf("%s%s.%s.svc:8000/%s", scheme, w.Service.Name, namespace, servicePath)
 	}
 	if urlStr == "" {
 		return f.Status.Replicas, false, errors.New("URL was not provided")
@@ -95,25 +241,43 @@ func applyWebhookPolicy(w *autoscalingv1.WebhookPolicy, f *stablev1alpha1.Fleet)
 		return f.Status.Replicas, false, err
 	}
 
-	// We could have multiple fleetautoscalers with different CABundles defined,
-	// so we switch client.Transport before each POST request
+	// We could have multiple fleetautoscalers with different CABundles and client certificates defined,
+	// so each POST request gets its own client.Transport
+	webhookClient := client
 	if u.Scheme == "https" {
 		rootCAs := x509.NewCertPool()
 		if ok := rootCAs.AppendCertsFromPEM(w.CABundle); !ok {
 			return f.Status.Replicas, false, errors.New("no certs were appended from caBundle")
 		}
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{
-				RootCAs: rootCAs,
-			},
+		tlsConfig := &tls.Config{
+			RootCAs: rootCAs,
+		}
+		if w.ClientCertSecret != "" {
+			cert, err := webhookClientCertificate(w.ClientCertSecret, secrets)
+			if err != nil {
+				return f.Status.Replicas, false, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		webhookClient.Transport = &http.Transport{
+			TLSClientConfig: tlsConfig,
 		}
-		client.Transport = tr
 	}
-	res, err := client.Post(
-		urlStr,
-		"application/json",
-		strings.NewReader(string(b)),
-	)
+
+	req, err := http.NewRequest(http.MethodPost, urlStr, bytes.NewReader(b))
+	if err != nil {
+		return f.Status.Replicas, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.SigningSecret != nil {
+		signature, err := webhookSignature(b, w.SigningSecret, secrets)
+		if err != nil {
+			return f.Status.Replicas, false, err
+		}
+		req.Header.Set(autoscalingv1.WebhookSignatureHeader, signature)
+	}
+
+	res, err := webhookClient.Do(req)
 	if err != nil {
 		return f.Status.Replicas, false, err
 	}
@@ -129,12 +293,50 @@ func applyWebhookPolicy(w *autoscalingv1.WebhookPolicy, f *stablev1alpha1.Fleet)
 	if err != nil {
 		return f.Status.Replicas, false, err
 	}
+	if faResp.Response == nil {
+		return f.Status.Replicas, false, fmt.Errorf("no response in the FleetAutoscaleReview from the server: %s", urlStr)
+	}
 	if faResp.Response.Scale {
 		return faResp.Response.Replicas, false, nil
 	}
 	return f.Status.Replicas, false, nil
 }
 
+// webhookClientCertificate loads the client certificate for a webhook policy from a kubernetes.io/tls Secret
+func webhookClientCertificate(secretName string, secrets corev1lister.SecretNamespaceLister) (tls.Certificate, error) {
+	if secrets == nil {
+		return tls.Certificate{}, errors.Errorf("could not get client certificate secret %s", secretName)
+	}
+	secret, err := secrets.Get(secretName)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrapf(err, "could not get client certificate secret %s", secretName)
+	}
+	cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return tls.Certificate{}, errors.Wrapf(err, "could not load client certificate from secret %s", secretName)
+	}
+	return cert, nil
+}
+
+// webhookSignature returns the value of the WebhookSignatureHeader for the body of a webhook policy request,
+// signed with the key selected by the signing secret
+func webhookSignature(body []byte, selector *corev1.SecretKeySelector, secrets corev1lister.SecretNamespaceLister) (string, error) {
+	if secrets == nil {
+		return "", errors.Errorf("could not get signing secret %s", selector.Name)
+	}
+	secret, err := secrets.Get(selector.Name)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not get signing secret %s", selector.Name)
+	}
+	key, ok := secret.Data[selector.Key]
+	if !ok || len(key) == 0 {
+		return "", errors.Errorf("signing secret %s does not have key %s", selector.Name, selector.Key)
+	}
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
 func applyBufferPolicy(b *autoscalingv1.BufferPolicy, f *stablev1alpha1.Fleet) (int32, bool, error) {
 	var replicas int32
 
@@ -17,16 +17,34 @@
 package fleetautoscalers
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
+	stablev1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 const (
@@ -45,7 +63,7 @@ func TestComputeDesiredFleetSize(t *testing.T) {
 	f.Status.AllocatedReplicas = 40
 	f.Status.ReadyReplicas = 10
 
-	replicas, limited, err := computeDesiredFleetSize(fas, f)
+	replicas, limited, err := computeDesiredFleetSize(fas, f, nil, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, replicas, int32(60))
 	assert.Equal(t, limited, false)
@@ -53,7 +71,7 @@ func TestComputeDesiredFleetSize(t *testing.T) {
 	// test empty Policy Type
 	f.Status.Replicas = 61
 	fas.Spec.Policy.Type = ""
-	replicas, limited, err = computeDesiredFleetSize(fas, f)
+	replicas, limited, err = computeDesiredFleetSize(fas, f, nil, nil)
 	assert.NotNil(t, err)
 	assert.Equal(t, replicas, int32(61))
 	assert.Equal(t, limited, false)
@@ -165,6 +183,188 @@ func (t testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func TestApplyAllocationFailurePolicy(t *testing.T) {
+	t.Parallel()
+
+	_, f := defaultFixtures()
+	f.Status.Replicas = 50
+	f.Status.AllocatedReplicas = 40
+	a := &autoscalingv1.AllocationFailurePolicy{
+		Buffer:             autoscalingv1.BufferPolicy{BufferSize: intstr.FromInt(10), MinReplicas: 55, MaxReplicas: 100},
+		WindowSeconds:      60,
+		ReplicasPerFailure: 2,
+	}
+
+	fixtures := map[string]struct {
+		failures int
+		replicas int32
+		limited  bool
+	}{
+		"no failures, buffer policy":      {failures: 0, replicas: 55, limited: true},
+		"failures grow the buffer":        {failures: 5, replicas: 65, limited: false},
+		"failures limited by maxReplicas": {failures: 50, replicas: 100, limited: true},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			replicas, limited, err := applyAllocationFailurePolicy(a, f, v.failures)
+			assert.Nil(t, err)
+			assert.Equal(t, v.replicas, replicas)
+			assert.Equal(t, v.limited, limited)
+		})
+	}
+
+	t.Run("compute desired fleet size", func(t *testing.T) {
+		fas, _ := defaultFixtures()
+		fas.Spec.Policy = autoscalingv1.FleetAutoscalerPolicy{Type: autoscalingv1.AllocationFailurePolicyType, AllocationFailure: a}
+		replicas, limited, err := computeDesiredFleetSize(fas, f, nil, NewFailureCounter())
+		assert.Nil(t, err)
+		assert.Equal(t, int32(55), replicas)
+		assert.True(t, limited)
+	})
+}
+
+func TestApplyChainPolicy(t *testing.T) {
+	t.Parallel()
+
+	_, f := defaultFixtures()
+	f.Status.Replicas = 50
+	f.Status.AllocatedReplicas = 40
+
+	unreachable := "http://127.0.0.1:0/scale"
+	webhook := autoscalingv1.FleetAutoscalerPolicy{Type: autoscalingv1.WebhookPolicyType, Webhook: &autoscalingv1.WebhookPolicy{URL: &unreachable}}
+	small := autoscalingv1.FleetAutoscalerPolicy{Type: autoscalingv1.BufferPolicyType,
+		Buffer: &autoscalingv1.BufferPolicy{BufferSize: intstr.FromInt(5), MaxReplicas: 100}}
+	large := autoscalingv1.FleetAutoscalerPolicy{Type: autoscalingv1.BufferPolicyType,
+		Buffer: &autoscalingv1.BufferPolicy{BufferSize: intstr.FromInt(20), MaxReplicas: 100}}
+
+	fixtures := map[string]struct {
+		chain    autoscalingv1.ChainPolicy
+		replicas int32
+		err      bool
+	}{
+		"first policy": {
+			chain:    autoscalingv1.ChainPolicy{Policies: []autoscalingv1.FleetAutoscalerPolicy{small, large}, Selection: autoscalingv1.ChainSelectFirst},
+			replicas: 45,
+		},
+		"falls back when a policy fails": {
+			chain:    autoscalingv1.ChainPolicy{Policies: []autoscalingv1.FleetAutoscalerPolicy{webhook, large}, Selection: autoscalingv1.ChainSelectFirst},
+			replicas: 60,
+		},
+		"max of the policies": {
+			chain:    autoscalingv1.ChainPolicy{Policies: []autoscalingv1.FleetAutoscalerPolicy{small, webhook, large}, Selection: autoscalingv1.ChainSelectMax},
+			replicas: 60,
+		},
+		"all policies fail": {
+			chain:    autoscalingv1.ChainPolicy{Policies: []autoscalingv1.FleetAutoscalerPolicy{webhook}, Selection: autoscalingv1.ChainSelectMax},
+			replicas: 50,
+			err:      true,
+		},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			fas, _ := defaultFixtures()
+			fas.Spec.Policy = autoscalingv1.FleetAutoscalerPolicy{Type: autoscalingv1.ChainPolicyType, Chain: v.chain.DeepCopy()}
+			replicas, _, err := computeDesiredFleetSize(fas, f, nil, nil)
+			if v.err {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, v.replicas, replicas)
+		})
+	}
+}
+
+func TestApplyCounterPolicy(t *testing.T) {
+	t.Parallel()
+
+	_, f := defaultFixtures()
+	f.Spec.Template.Spec.Counters = map[string]stablev1alpha1.CounterStatus{"rooms": {Capacity: 10}}
+	f.Status.Replicas = 8
+	f.Status.AllocatedReplicas = 4
+	f.Status.Counters = map[string]stablev1alpha1.AggregatedCounterStatus{"rooms": {AllocatedCount: 30, AllocatedCapacity: 40, Count: 30, Capacity: 80}}
+
+	fixtures := map[string]struct {
+		policy   autoscalingv1.CounterPolicy
+		replicas int32
+		limited  bool
+		err      bool
+	}{
+		"allocated capacity is not enough": {
+			policy:   autoscalingv1.CounterPolicy{Key: "rooms", BufferSize: intstr.FromInt(25), MaxReplicas: 100},
+			replicas: 6,
+		},
+		"allocated capacity is enough": {
+			policy:   autoscalingv1.CounterPolicy{Key: "rooms", BufferSize: intstr.FromInt(5), MaxReplicas: 100},
+			replicas: 4,
+		},
+		"percentage of the capacity": {
+			policy:   autoscalingv1.CounterPolicy{Key: "rooms", BufferSize: intstr.FromString("50%"), MinReplicas: 1, MaxReplicas: 100},
+			replicas: 6,
+		},
+		"limited by maxReplicas": {
+			policy:   autoscalingv1.CounterPolicy{Key: "rooms", BufferSize: intstr.FromInt(25), MaxReplicas: 5},
+			replicas: 5,
+			limited:  true,
+		},
+		"limited by minReplicas": {
+			policy:   autoscalingv1.CounterPolicy{Key: "rooms", BufferSize: intstr.FromInt(5), MinReplicas: 8, MaxReplicas: 100},
+			replicas: 8,
+			limited:  true,
+		},
+		"counter is not in the template": {
+			policy:   autoscalingv1.CounterPolicy{Key: "players", BufferSize: intstr.FromInt(5), MaxReplicas: 100},
+			replicas: 8,
+			err:      true,
+		},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			fas, _ := defaultFixtures()
+			policy := v.policy
+			fas.Spec.Policy = autoscalingv1.FleetAutoscalerPolicy{Type: autoscalingv1.CounterPolicyType, Counter: &policy}
+			replicas, limited, err := computeDesiredFleetSize(fas, f, nil, nil)
+			if v.err {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, v.replicas, replicas)
+			assert.Equal(t, v.limited, limited)
+		})
+	}
+
+	t.Run("no available capacity in the template", func(t *testing.T) {
+		full := f.DeepCopy()
+		full.Spec.Template.Spec.Counters["rooms"] = stablev1alpha1.CounterStatus{Count: 10, Capacity: 10}
+		replicas, _, err := applyCounterPolicy(&autoscalingv1.CounterPolicy{Key: "rooms", BufferSize: intstr.FromInt(5), MaxReplicas: 100}, full)
+		assert.NotNil(t, err)
+		assert.Equal(t, int32(8), replicas)
+	})
+}
+
+func TestApplyListPolicy(t *testing.T) {
+	t.Parallel()
+
+	_, f := defaultFixtures()
+	f.Spec.Template.Spec.Lists = map[string]stablev1alpha1.ListStatus{"players": {Capacity: 8}}
+	f.Status.Replicas = 5
+	f.Status.AllocatedReplicas = 2
+	f.Status.Lists = map[string]stablev1alpha1.AggregatedListStatus{"players": {AllocatedCount: 10, AllocatedCapacity: 16, Count: 10, Capacity: 40}}
+
+	replicas, limited, err := applyListPolicy(&autoscalingv1.ListPolicy{Key: "players", BufferSize: intstr.FromInt(20), MaxReplicas: 100}, f)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(4), replicas)
+	assert.False(t, limited)
+
+	replicas, _, err = applyListPolicy(&autoscalingv1.ListPolicy{Key: "teams", BufferSize: intstr.FromInt(20), MaxReplicas: 100}, f)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(5), replicas)
+}
+
 func TestApplyWebhookPolicy(t *testing.T) {
 	t.Parallel()
 
@@ -181,7 +381,7 @@ func TestApplyWebhookPolicy(t *testing.T) {
 	f.Status.AllocatedReplicas = 10
 	f.Status.ReadyReplicas = 40
 
-	replicas, limited, err := applyWebhookPolicy(w, f)
+	replicas, limited, err := applyWebhookPolicy(w, f, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, f.Spec.Replicas, replicas)
 	assert.Equal(t, limited, false)
@@ -190,7 +390,7 @@ func TestApplyWebhookPolicy(t *testing.T) {
 	f.Status.Replicas = f.Spec.Replicas
 	f.Status.AllocatedReplicas = 40
 	f.Status.ReadyReplicas = 10
-	replicas, limited, err = applyWebhookPolicy(w, f)
+	replicas, limited, err = applyWebhookPolicy(w, f, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, f.Status.Replicas*scaleFactor, replicas)
 	assert.Equal(t, limited, false)
@@ -199,8 +399,133 @@ func TestApplyWebhookPolicy(t *testing.T) {
 	f.Status.Replicas = f.Spec.Replicas
 	f.Status.AllocatedReplicas = 35
 	f.Status.ReadyReplicas = 15
-	replicas, limited, err = applyWebhookPolicy(w, f)
+	replicas, limited, err = applyWebhookPolicy(w, f, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, replicas, f.Spec.Replicas)
 	assert.Equal(t, limited, false)
 }
+
+func TestApplyWebhookPolicyNoResponse(t *testing.T) {
+	t.Parallel()
+
+	fas, f := defaultWebhookFixtures()
+	w := fas.Spec.Policy.Webhook
+	w.Service = nil
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(rw, `{}`)
+		assert.Nil(t, err)
+	}))
+	defer server.Close()
+	w.URL = &(server.URL)
+
+	replicas, limited, err := applyWebhookPolicy(w, f, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, f.Status.Replicas, replicas)
+	assert.False(t, limited)
+}
+
+func TestApplyWebhookPolicySigningSecret(t *testing.T) {
+	t.Parallel()
+
+	fas, f := defaultWebhookFixtures()
+	w := fas.Spec.Policy.Webhook
+	w.Service = nil
+	w.SigningSecret = &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "signing"}, Key: "key"}
+
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.Nil(t, err)
+		mac := hmac.New(sha256.New, []byte("secret-key"))
+		_, _ = mac.Write(body)
+		signature = r.Header.Get(autoscalingv1.WebhookSignatureHeader)
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), signature)
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		testServer{}.ServeHTTP(rw, r)
+	}))
+	defer server.Close()
+	w.URL = &(server.URL)
+
+	secrets := newSecretNamespaceLister(t, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "signing", Namespace: "default"},
+		Data: map[string][]byte{"key": []byte("secret-key")}})
+
+	replicas, _, err := applyWebhookPolicy(w, f, secrets)
+	assert.Nil(t, err)
+	assert.Equal(t, f.Status.Replicas, replicas)
+	assert.NotEmpty(t, signature)
+
+	w.SigningSecret.Key = "missing"
+	_, _, err = applyWebhookPolicy(w, f, secrets)
+	assert.NotNil(t, err)
+
+	w.SigningSecret.Name = "missing"
+	_, _, err = applyWebhookPolicy(w, f, secrets)
+	assert.NotNil(t, err)
+}
+
+func TestApplyWebhookPolicyClientCertSecret(t *testing.T) {
+	t.Parallel()
+
+	fas, f := defaultWebhookFixtures()
+	w := fas.Spec.Policy.Webhook
+	w.Service = nil
+	w.ClientCertSecret = "client-cert"
+
+	clientCert, clientKey := selfSignedCertificate(t)
+	clientCAs := x509.NewCertPool()
+	assert.True(t, clientCAs.AppendCertsFromPEM(clientCert))
+
+	server := httptest.NewUnstartedServer(testServer{})
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	w.URL = &(server.URL)
+	w.CABundle = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	// without the client certificate, the server rejects the request
+	_, _, err := applyWebhookPolicy(w, f, newSecretNamespaceLister(t))
+	assert.NotNil(t, err)
+
+	secrets := newSecretNamespaceLister(t, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "client-cert", Namespace: "default"},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{corev1.TLSCertKey: clientCert, corev1.TLSPrivateKeyKey: clientKey}})
+	replicas, _, err := applyWebhookPolicy(w, f, secrets)
+	assert.Nil(t, err)
+	assert.Equal(t, f.Status.Replicas, replicas)
+
+	w.ClientCertSecret = ""
+	_, _, err = applyWebhookPolicy(w, f, secrets)
+	assert.NotNil(t, err)
+}
+
+// newSecretNamespaceLister returns a lister for the default namespace that lists the given secrets
+func newSecretNamespaceLister(t *testing.T, secrets ...*corev1.Secret) corev1lister.SecretNamespaceLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, s := range secrets {
+		assert.Nil(t, indexer.Add(s))
+	}
+	return corev1lister.NewSecretLister(indexer).Secrets("default")
+}
+
+// selfSignedCertificate returns a PEM encoded self signed certificate and its key
+func selfSignedCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fleetautoscaler"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
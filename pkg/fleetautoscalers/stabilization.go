@@ -0,0 +1,84 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleetautoscalers
+
+import (
+	"sync"
+	"time"
+)
+
+// recommendation is a size of a Fleet that the policy of its FleetAutoscaler computed
+type recommendation struct {
+	time     time.Time
+	replicas int32
+}
+
+// stabilizer keeps the sizes that the policy of each FleetAutoscaler recently computed for its Fleet,
+// so that the Fleet is only scaled down to the largest of them within its scale down stabilization window.
+// They are kept in memory, so they start over when the controller restarts.
+type stabilizer struct {
+	mu              sync.Mutex
+	recommendations map[string][]recommendation
+	now             func() time.Time
+}
+
+// newStabilizer returns a new stabilizer
+func newStabilizer() *stabilizer {
+	return &stabilizer{recommendations: map[string][]recommendation{}, now: time.Now}
+}
+
+// stabilize records replicas as the latest size computed for the Fleet of the FleetAutoscaler with the key,
+// and returns the size to scale the Fleet to from its current replicas: when that is a scale down, it is the
+// largest size computed within the window, up to the current replicas
+func (s *stabilizer) stabilize(key string, replicas, current int32, window time.Duration) int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if window <= 0 {
+		delete(s.recommendations, key)
+		return replicas
+	}
+
+	now := s.now()
+	since := now.Add(-window)
+	recs := s.recommendations[key]
+	i := 0
+	for i < len(recs) && !recs[i].time.After(since) {
+		i++
+	}
+	recs = append(recs[i:], recommendation{time: now, replicas: replicas})
+	s.recommendations[key] = recs
+
+	if replicas >= current {
+		return replicas
+	}
+	stable := replicas
+	for _, r := range recs {
+		if r.replicas > stable {
+			stable = r.replicas
+		}
+	}
+	if stable > current {
+		stable = current
+	}
+	return stable
+}
+
+// forget drops the sizes computed for the Fleet of the FleetAutoscaler with the key, once it has been deleted
+func (s *stabilizer) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.recommendations, key)
+}
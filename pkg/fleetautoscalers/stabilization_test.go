@@ -0,0 +1,57 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleetautoscalers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStabilizer(t *testing.T) {
+	t.Parallel()
+
+	s := newStabilizer()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	window := time.Minute
+
+	// scaling up isn't held back
+	assert.Equal(t, int32(20), s.stabilize("default/fas", 20, 10, window))
+
+	// scaling down is held at the largest size within the window
+	now = now.Add(20 * time.Second)
+	assert.Equal(t, int32(20), s.stabilize("default/fas", 12, 20, window))
+	now = now.Add(20 * time.Second)
+	assert.Equal(t, int32(20), s.stabilize("default/fas", 15, 20, window))
+
+	// the first size has left the window
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, int32(15), s.stabilize("default/fas", 10, 20, window))
+
+	// but never above the current size
+	assert.Equal(t, int32(14), s.stabilize("default/fas", 10, 14, window))
+
+	// other FleetAutoscalers have their own sizes
+	assert.Equal(t, int32(5), s.stabilize("default/other", 5, 8, window))
+
+	// without a window, the fleet is scaled down straight away, and the sizes are dropped
+	assert.Equal(t, int32(10), s.stabilize("default/fas", 10, 20, 0))
+	assert.NotContains(t, s.recommendations, "default/fas")
+
+	s.forget("default/other")
+	assert.Empty(t, s.recommendations)
+}
@@ -18,6 +18,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
+	"time"
 
 	"agones.dev/agones/pkg/apis/stable"
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
@@ -25,7 +28,9 @@ import (
 	"agones.dev/agones/pkg/client/clientset/versioned"
 	getterv1alpha1 "agones.dev/agones/pkg/client/clientset/versioned/typed/stable/v1alpha1"
 	"agones.dev/agones/pkg/client/informers/externalversions"
+	listerautoscalingv1 "agones.dev/agones/pkg/client/listers/autoscaling/v1"
 	listerv1alpha1 "agones.dev/agones/pkg/client/listers/stable/v1alpha1"
+	"agones.dev/agones/pkg/util/apiserver"
 	"agones.dev/agones/pkg/util/crd"
 	"agones.dev/agones/pkg/util/logfields"
 	"agones.dev/agones/pkg/util/runtime"
@@ -38,39 +43,74 @@ import (
 	admv1beta1 "k8s.io/api/admission/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1api "k8s.io/api/policy/v1beta1"
 	extclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	policyv1beta1 "k8s.io/client-go/kubernetes/typed/policy/v1beta1"
+	policylisterv1beta1 "k8s.io/client-go/listers/policy/v1beta1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 )
 
+const (
+	// progressingReasonUpdated is the reason of the Progressing condition of a Fleet while its rollout progresses
+	progressingReasonUpdated = "GameServerSetUpdated"
+	// progressingReasonAvailable is the reason of the Progressing condition of a Fleet once its rollout has completed
+	progressingReasonAvailable = "NewGameServerSetAvailable"
+	// progressingReasonPaused is the reason of the Progressing condition of a Fleet while its rollout is paused,
+	// or outside of its update windows
+	progressingReasonPaused = "FleetPaused"
+	// progressingReasonDeadlineExceeded is the reason of the Progressing condition of a Fleet whose rollout
+	// has gone without progress for longer than its progress deadline
+	progressingReasonDeadlineExceeded = "ProgressDeadlineExceeded"
+)
+
 // Controller is a the GameServerSet controller
 type Controller struct {
 	baseLogger          *logrus.Entry
 	crdGetter           v1beta1.CustomResourceDefinitionInterface
+	crdWaitTimeout      time.Duration
 	gameServerSetGetter getterv1alpha1.GameServerSetsGetter
 	gameServerSetLister listerv1alpha1.GameServerSetLister
 	gameServerSetSynced cache.InformerSynced
 	fleetGetter         getterv1alpha1.FleetsGetter
 	fleetLister         listerv1alpha1.FleetLister
 	fleetSynced         cache.InformerSynced
-	workerqueue         *workerqueue.WorkerQueue
-	recorder            record.EventRecorder
+	// fleetAutoscalerLister finds the Fleets that are autoscaled, which FleetScales can't scale
+	fleetAutoscalerLister listerautoscalingv1.FleetAutoscalerLister
+	fleetAutoscalerSynced cache.InformerSynced
+	pdbGetter             policyv1beta1.PodDisruptionBudgetsGetter
+	pdbLister             policylisterv1beta1.PodDisruptionBudgetLister
+	pdbSynced             cache.InformerSynced
+	workerqueue           *workerqueue.WorkerQueue
+	recorder              record.EventRecorder
+	resourceEstimates     bool
+	sidecarResources      corev1.ResourceRequirements
+	sidecarRollout        *sidecarRollout
 }
 
 // NewController returns a new fleets crd controller
 func NewController(
 	wh *webhooks.WebHook,
+	api *apiserver.APIServer,
 	health healthcheck.Handler,
+	crdWaitTimeout time.Duration,
+	resourceEstimates bool,
+	sidecarResources corev1.ResourceRequirements,
+	sidecarImage string,
+	sidecarRolloutFleets int,
 	kubeClient kubernetes.Interface,
 	extClient extclientset.Interface,
 	agonesClient versioned.Interface,
+	kubeInformerFactory informers.SharedInformerFactory,
 	agonesInformerFactory externalversions.SharedInformerFactory) *Controller {
 
 	gameServerSets := agonesInformerFactory.Stable().V1alpha1().GameServerSets()
@@ -79,14 +119,28 @@ func NewController(
 	fleets := agonesInformerFactory.Stable().V1alpha1().Fleets()
 	fInformer := fleets.Informer()
 
+	fleetAutoscalers := agonesInformerFactory.Autoscaling().V1().FleetAutoscalers()
+
+	pdbs := kubeInformerFactory.Policy().V1beta1().PodDisruptionBudgets()
+	pdbInformer := pdbs.Informer()
+
 	c := &Controller{
-		crdGetter:           extClient.ApiextensionsV1beta1().CustomResourceDefinitions(),
-		gameServerSetGetter: agonesClient.StableV1alpha1(),
-		gameServerSetLister: gameServerSets.Lister(),
-		gameServerSetSynced: gsSetInformer.HasSynced,
-		fleetGetter:         agonesClient.StableV1alpha1(),
-		fleetLister:         fleets.Lister(),
-		fleetSynced:         fInformer.HasSynced,
+		crdGetter:             extClient.ApiextensionsV1beta1().CustomResourceDefinitions(),
+		crdWaitTimeout:        crdWaitTimeout,
+		gameServerSetGetter:   agonesClient.StableV1alpha1(),
+		gameServerSetLister:   gameServerSets.Lister(),
+		gameServerSetSynced:   gsSetInformer.HasSynced,
+		fleetGetter:           agonesClient.StableV1alpha1(),
+		fleetLister:           fleets.Lister(),
+		fleetSynced:           fInformer.HasSynced,
+		fleetAutoscalerLister: fleetAutoscalers.Lister(),
+		fleetAutoscalerSynced: fleetAutoscalers.Informer().HasSynced,
+		pdbGetter:             kubeClient.PolicyV1beta1(),
+		pdbLister:             pdbs.Lister(),
+		pdbSynced:             pdbInformer.HasSynced,
+		resourceEstimates:     resourceEstimates,
+		sidecarResources:      sidecarResources,
+		sidecarRollout:        newSidecarRollout(sidecarImage, sidecarRolloutFleets),
 	}
 
 	c.baseLogger = runtime.NewLoggerWithType(c)
@@ -99,8 +153,11 @@ func NewController(
 	c.recorder = eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "fleet-controller"})
 
 	wh.AddHandler("/mutate", stablev1alpha1.Kind("Fleet"), admv1beta1.Create, c.creationMutationHandler)
-	wh.AddHandler("/validate", v1alpha1.Kind("Fleet"), admv1beta1.Create, c.creationValidationHandler)
-	wh.AddHandler("/validate", v1alpha1.Kind("Fleet"), admv1beta1.Update, c.creationValidationHandler)
+	wh.AddHandler("/mutate", stablev1alpha1.Kind("Fleet"), admv1beta1.Update, c.updateMutationHandler)
+	wh.AddHandler("/validate", v1alpha1.Kind("Fleet"), admv1beta1.Create, c.validationHandler)
+	wh.AddHandler("/validate", v1alpha1.Kind("Fleet"), admv1beta1.Update, c.validationHandler)
+	wh.AddHandler("/validate", v1alpha1.Kind("Fleet"), admv1beta1.Delete, c.deletionValidationHandler)
+	c.registerAPIResource(api)
 
 	fInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: c.workerqueue.Enqueue,
@@ -120,16 +177,59 @@ func NewController(
 		},
 	})
 
+	// recreate the PodDisruptionBudget of a Fleet if it is deleted
+	pdbInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			// Could be a DeletedFinalStateUnknown, in which case, just ignore it
+			if pdb, ok := obj.(*policyv1beta1api.PodDisruptionBudget); ok {
+				c.ownerFleetEventHandler(pdb)
+			}
+		},
+	})
+
 	return c
 }
 
 // creationMutationHandler is the handler for the mutating webhook that sets the
-// the default values on the Fleet
+// the default values on the Fleet, and its resource estimates if they are enabled
 // Should only be called on fleet create operations.
-// nolint:dupl
 func (c *Controller) creationMutationHandler(review admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error) {
 	c.baseLogger.WithField("review", review).Info("creationMutationHandler")
 
+	return c.patchFleet(review, func(fleet *stablev1alpha1.Fleet) {
+		fleet.ApplyDefaults()
+		if c.resourceEstimates {
+			fleet.ApplyResourceEstimates(c.sidecarResources)
+		}
+	})
+}
+
+// updateMutationHandler is the handler for the mutating webhook that applies the
+// health defaults of the Fleet to its template, and keeps its resource estimates
+// up to date with its template if they are enabled
+// Should only be called on fleet update operations.
+func (c *Controller) updateMutationHandler(review admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error) {
+	c.baseLogger.WithField("review", review).Info("updateMutationHandler")
+
+	old := &stablev1alpha1.Fleet{}
+	if raw := review.Request.OldObject.Raw; len(raw) > 0 {
+		if err := json.Unmarshal(raw, old); err != nil {
+			return review, errors.Wrapf(err, "error unmarshalling old Fleet json: %s", raw)
+		}
+	}
+
+	return c.patchFleet(review, func(fleet *stablev1alpha1.Fleet) {
+		fleet.ApplyHealthDefaults(old.Spec.HealthDefaults)
+		if c.resourceEstimates {
+			fleet.ApplyResourceEstimates(c.sidecarResources)
+		}
+	})
+}
+
+// patchFleet applies mutate to the Fleet in the review, and sets the
+// json patch of the changes it made as the response of the review
+// nolint:dupl
+func (c *Controller) patchFleet(review admv1beta1.AdmissionReview, mutate func(*stablev1alpha1.Fleet)) (admv1beta1.AdmissionReview, error) {
 	obj := review.Request.Object
 	fleet := &stablev1alpha1.Fleet{}
 	err := json.Unmarshal(obj.Raw, fleet)
@@ -139,11 +239,11 @@ func (c *Controller) creationMutationHandler(review admv1beta1.AdmissionReview)
 
 	// This is the main logic of this function
 	// the rest is really just json plumbing
-	fleet.ApplyDefaults()
+	mutate(fleet)
 
 	newFleet, err := json.Marshal(fleet)
 	if err != nil {
-		return review, errors.Wrapf(err, "error marshalling default applied Fleet %s to json", fleet.ObjectMeta.Name)
+		return review, errors.Wrapf(err, "error marshalling mutated Fleet %s to json", fleet.ObjectMeta.Name)
 	}
 
 	patch, err := jsonpatch.CreatePatch(obj.Raw, newFleet)
@@ -165,10 +265,11 @@ func (c *Controller) creationMutationHandler(review admv1beta1.AdmissionReview)
 	return review, nil
 }
 
-// creationValidationHandler that validates a Fleet when it is created
+// validationHandler that validates a Fleet, including the GameServerSpec of its template,
+// so that an invalid template is rejected before any GameServers are created from it.
 // Should only be called on Fleet create and Update operations.
-func (c *Controller) creationValidationHandler(review admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error) {
-	c.baseLogger.WithField("review", review).Info("creationValidationHandler")
+func (c *Controller) validationHandler(review admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error) {
+	c.baseLogger.WithField("review", review).Info("validationHandler")
 
 	obj := review.Request.Object
 	fleet := &stablev1alpha1.Fleet{}
@@ -178,6 +279,14 @@ func (c *Controller) creationValidationHandler(review admv1beta1.AdmissionReview
 	}
 
 	causes, ok := fleet.Validate()
+	unknown, err := crd.UnknownFieldCauses(reflect.TypeOf(stablev1alpha1.Fleet{}), obj.Raw)
+	if err != nil {
+		return review, errors.Wrapf(err, "error checking Fleet json for unknown fields: %s", obj.Raw)
+	}
+	if len(unknown) > 0 {
+		ok = false
+		causes = append(causes, unknown...)
+	}
 	if !ok {
 		review.Response.Allowed = false
 		details := metav1.StatusDetails{
@@ -200,23 +309,96 @@ func (c *Controller) creationValidationHandler(review admv1beta1.AdmissionReview
 	return review, nil
 }
 
+// deletionValidationHandler rejects the deletion of a Fleet that is protected
+// by its delete protection annotation.
+// Should only be called on Fleet delete operations.
+func (c *Controller) deletionValidationHandler(review admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error) {
+	c.baseLogger.WithField("review", review).Info("deletionValidationHandler")
+
+	fleet := &stablev1alpha1.Fleet{}
+	// the deleted object is only sent in the review from Kubernetes 1.15 onwards,
+	// so fall back to the cache if it is missing
+	if obj := review.Request.OldObject; len(obj.Raw) > 0 {
+		if err := json.Unmarshal(obj.Raw, fleet); err != nil {
+			return review, errors.Wrapf(err, "error unmarshalling original Fleet json: %s", obj.Raw)
+		}
+	} else {
+		var err error
+		fleet, err = c.fleetLister.Fleets(review.Request.Namespace).Get(review.Request.Name)
+		if k8serrors.IsNotFound(err) {
+			return review, nil
+		}
+		if err != nil {
+			return review, errors.Wrapf(err, "error retrieving Fleet %s/%s", review.Request.Namespace, review.Request.Name)
+		}
+	}
+
+	causes, ok := fleet.ValidateDeletion()
+	if !ok {
+		review.Response.Allowed = false
+		details := metav1.StatusDetails{
+			Name:   review.Request.Name,
+			Group:  review.Request.Kind.Group,
+			Kind:   review.Request.Kind.Kind,
+			Causes: causes,
+		}
+		review.Response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: "Fleet is protected from deletion",
+			Reason:  metav1.StatusReasonForbidden,
+			Details: &details,
+		}
+
+		c.loggerForFleet(fleet).WithField("review", review).Info("Fleet deletion rejected")
+		return review, nil
+	}
+
+	return review, nil
+}
+
 // Run the Fleet controller. Will block until stop is closed.
 // Runs threadiness number workers to process the rate limited queue
 func (c *Controller) Run(workers int, stop <-chan struct{}) error {
-	err := crd.WaitForEstablishedCRD(c.crdGetter, "fleets.stable.agones.dev", c.baseLogger)
+	err := crd.WaitForEstablishedCRD(c.crdGetter, "fleets.stable.agones.dev", c.crdWaitTimeout, c.baseLogger)
 	if err != nil {
 		return err
 	}
 
 	c.baseLogger.Info("Wait for cache sync")
-	if !cache.WaitForCacheSync(stop, c.gameServerSetSynced, c.fleetSynced) {
+	if !cache.WaitForCacheSync(stop, c.gameServerSetSynced, c.fleetSynced, c.fleetAutoscalerSynced, c.pdbSynced) {
 		return errors.New("failed to wait for caches to sync")
 	}
+	if c.sidecarRollout.enabled() {
+		if err := c.resumeSidecarRollouts(); err != nil {
+			return err
+		}
+	}
 
 	c.workerqueue.Run(workers, stop)
 	return nil
 }
 
+// resumeSidecarRollouts counts the Fleets that were already replacing the GameServers of outdated
+// GameServerSets before the controller started, from their GameServerSets, so that no other Fleet starts
+// replacing its GameServers while the maximum number of Fleets already are
+func (c *Controller) resumeSidecarRollouts() error {
+	fleets, err := c.fleetLister.List(labels.Everything())
+	if err != nil {
+		return errors.Wrap(err, "error listing fleets")
+	}
+	for _, fleet := range fleets {
+		list, err := ListGameServerSetsByFleetOwner(c.gameServerSetLister, fleet)
+		if err != nil {
+			return err
+		}
+		_, list = filterGameServerSetByCanary(fleet, list)
+		if active, rest := c.filterGameServerSetByActive(fleet, list); c.sidecarRollout.replacing(active, rest) {
+			c.sidecarRollout.resume(fleet.ObjectMeta.Namespace + "/" + fleet.ObjectMeta.Name)
+		}
+	}
+	return nil
+}
+
 func (c *Controller) loggerForFleetKey(key string) *logrus.Entry {
 	return logfields.AugmentLogEntry(c.baseLogger, logfields.FleetKey, key)
 }
@@ -232,19 +414,24 @@ func (c *Controller) loggerForFleet(f *v1alpha1.Fleet) *logrus.Entry {
 // gameServerSetEventHandler enqueues the owning Fleet for this GameServerSet,
 // assuming that it has one
 func (c *Controller) gameServerSetEventHandler(obj interface{}) {
-	gsSet := obj.(*stablev1alpha1.GameServerSet)
-	ref := metav1.GetControllerOf(gsSet)
-	if ref == nil {
+	c.ownerFleetEventHandler(obj.(*stablev1alpha1.GameServerSet))
+}
+
+// ownerFleetEventHandler enqueues the Fleet that controls the given object,
+// assuming that it has one
+func (c *Controller) ownerFleetEventHandler(obj metav1.Object) {
+	ref := metav1.GetControllerOf(obj)
+	if ref == nil || ref.Kind != "Fleet" {
 		return
 	}
 
-	fleet, err := c.fleetLister.Fleets(gsSet.ObjectMeta.Namespace).Get(ref.Name)
+	fleet, err := c.fleetLister.Fleets(obj.GetNamespace()).Get(ref.Name)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			c.baseLogger.WithField("ref", ref).Info("Owner Fleet no longer available for syncing")
 		} else {
 			runtime.HandleError(c.loggerForFleet(fleet).WithField("ref", ref),
-				errors.Wrap(err, "error retrieving GameServerSet owner"))
+				errors.Wrap(err, "error retrieving Fleet owner"))
 		}
 		return
 	}
@@ -268,22 +455,61 @@ func (c *Controller) syncFleet(key string) error {
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			c.loggerForFleetKey(key).Info("Fleet is no longer available for syncing")
+			c.sidecarRollout.finish(key)
 			return nil
 		}
 		return errors.Wrapf(err, "error retrieving fleet %s from namespace %s", name, namespace)
 	}
 
+	// roll back first, as the update of the Fleet syncs it again with the rolled back template
+	if _, ok := fleet.ObjectMeta.Annotations[stablev1alpha1.FleetRollbackAnnotation]; ok {
+		return c.rollbackFleet(fleet)
+	}
+
 	list, err := ListGameServerSetsByFleetOwner(c.gameServerSetLister, fleet)
 	if err != nil {
 		return err
 	}
 
+	canary, list := filterGameServerSetByCanary(fleet, list)
 	active, rest := c.filterGameServerSetByActive(fleet, list)
+	if c.sidecarRollout.enabled() {
+		active, rest = c.rollOutSidecar(key, fleet, active, rest)
+	}
+
+	if err := c.updateAllocationOverflow(fleet, rest); err != nil {
+		return err
+	}
+	if err := c.upsertCanaryGameServerSet(fleet, canary); err != nil {
+		return err
+	}
+	// the canary's share of the replicas is taken out of the rest of the Fleet's
+	if fleet.Spec.Canary != nil {
+		canaryReplicas := fleet.CanaryReplicas()
+		fleet = fleet.DeepCopy()
+		fleet.Spec.Replicas -= canaryReplicas
+	}
+
+	// a paused Fleet doesn't start rolling out a new template, so it keeps its GameServerSets as they are
+	if active == nil && fleet.Spec.Paused {
+		c.loggerForFleet(fleet).Info("fleet is paused, not creating GameServerSet for its template")
+		if err := c.upsertPodDisruptionBudget(fleet); err != nil {
+			return err
+		}
+		return c.updateFleetStatus(fleet)
+	}
 
 	// if there isn't an active gameServerSet, create one (but don't persist yet)
 	if active == nil {
 		c.loggerForFleet(fleet).Info("could not find active GameServerSet, creating")
 		active = fleet.GameServerSet()
+		setAnnotation(active, stablev1alpha1.GameServerSetSidecarImageAnnotation, c.sidecarRollout.image)
+	}
+
+	// a new template, or one that was rolled back to, gets a newer revision than any before it
+	revision := active.Revision()
+	if latest := latestRevision(fleet, list); active.ObjectMeta.UID == "" || revision < latest {
+		revision = latest + 1
 	}
 
 	replicas, err := c.applyDeploymentStrategy(fleet, active, rest)
@@ -294,18 +520,109 @@ func (c *Controller) syncFleet(key string) error {
 		return err
 	}
 
-	if err := c.upsertGameServerSet(fleet, active, replicas); err != nil {
+	if err := c.upsertGameServerSet(fleet, active, replicas, revision); err != nil {
+		return err
+	}
+	if err := c.upsertPodDisruptionBudget(fleet); err != nil {
 		return err
 	}
 	return c.updateFleetStatus(fleet)
 }
 
+// rollOutSidecar makes an active GameServerSet that was created with an outdated sidecar image inactive,
+// so that a new one is created, and its GameServers are replaced through the Fleet's deployment strategy.
+// This waits while the maximum number of Fleets are already replacing their GameServers, and doesn't start
+// while the Fleet is paused.
+func (c *Controller) rollOutSidecar(key string, fleet *stablev1alpha1.Fleet, active *stablev1alpha1.GameServerSet,
+	rest []*stablev1alpha1.GameServerSet) (*stablev1alpha1.GameServerSet, []*stablev1alpha1.GameServerSet) {
+	if active == nil || fleet.Spec.Paused || !c.sidecarRollout.outdated(active) {
+		c.sidecarRollout.track(key, rest)
+		return active, rest
+	}
+
+	if !c.sidecarRollout.start(key) {
+		c.loggerForFleet(fleet).Info("waiting for other fleets to roll out the sidecar image")
+		c.workerqueue.EnqueueAfter(fleet, sidecarRolloutRetry)
+		return active, rest
+	}
+	c.recorder.Eventf(fleet, corev1.EventTypeNormal, "RollingOutSidecar",
+		"Replacing the GameServers of GameServerSet %s to roll out sidecar image %s", active.ObjectMeta.Name, c.sidecarRollout.image)
+	return nil, append(rest, active)
+}
+
+// rollbackFleet sets the template of the Fleet to that of the revision in its rollback annotation,
+// from its revision history, and removes the annotation
+func (c *Controller) rollbackFleet(fleet *stablev1alpha1.Fleet) error {
+	fCopy := fleet.DeepCopy()
+	value := fCopy.ObjectMeta.Annotations[stablev1alpha1.FleetRollbackAnnotation]
+	delete(fCopy.ObjectMeta.Annotations, stablev1alpha1.FleetRollbackAnnotation)
+
+	var revision *stablev1alpha1.FleetRevision
+	if r, err := strconv.ParseInt(value, 10, 64); err == nil {
+		for i := range fleet.Status.RevisionHistory {
+			if fleet.Status.RevisionHistory[i].Revision == r {
+				revision = &fleet.Status.RevisionHistory[i]
+				break
+			}
+		}
+	}
+	if revision != nil {
+		fCopy.Spec.Template = *revision.Template.DeepCopy()
+	}
+
+	if _, err := c.fleetGetter.Fleets(fCopy.ObjectMeta.Namespace).Update(fCopy); err != nil {
+		return errors.Wrapf(err, "error rolling back fleet %s", fCopy.ObjectMeta.Name)
+	}
+	if revision == nil {
+		c.recorder.Eventf(fleet, corev1.EventTypeWarning, "RollbackRevisionNotFound",
+			"Unable to find revision %s in the revision history of the Fleet", value)
+		return nil
+	}
+	c.recorder.Eventf(fleet, corev1.EventTypeNormal, "RollingBack", "Rolling back to revision %d", revision.Revision)
+	return nil
+}
+
+// upsertPodDisruptionBudget creates the PodDisruptionBudget that protects the Pods of the Fleet's
+// Allocated GameServers, if it doesn't exist, and recreates it if its spec doesn't match the Fleet's,
+// as the spec of a PodDisruptionBudget can't be updated.
+func (c *Controller) upsertPodDisruptionBudget(fleet *stablev1alpha1.Fleet) error {
+	pdb := fleet.PodDisruptionBudget()
+	pdbs := c.pdbGetter.PodDisruptionBudgets(fleet.ObjectMeta.Namespace)
+
+	existing, err := c.pdbLister.PodDisruptionBudgets(fleet.ObjectMeta.Namespace).Get(pdb.ObjectMeta.Name)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "error retrieving PodDisruptionBudget for fleet %s", fleet.ObjectMeta.Name)
+	}
+
+	if existing != nil {
+		if !metav1.IsControlledBy(existing, fleet) {
+			c.recorder.Eventf(fleet, corev1.EventTypeWarning, "PodDisruptionBudgetExists",
+				"PodDisruptionBudget %s already exists, and is not controlled by this Fleet", existing.ObjectMeta.Name)
+			return nil
+		}
+		if reflect.DeepEqual(existing.Spec, pdb.Spec) {
+			return nil
+		}
+		if err := pdbs.Delete(existing.ObjectMeta.Name, nil); err != nil && !k8serrors.IsNotFound(err) {
+			return errors.Wrapf(err, "error deleting PodDisruptionBudget for fleet %s", fleet.ObjectMeta.Name)
+		}
+	}
+
+	if _, err := pdbs.Create(pdb); err != nil {
+		return errors.Wrapf(err, "error creating PodDisruptionBudget for fleet %s", fleet.ObjectMeta.Name)
+	}
+	c.recorder.Eventf(fleet, corev1.EventTypeNormal, "CreatingPodDisruptionBudget",
+		"Created PodDisruptionBudget %s with minAvailable %s", pdb.ObjectMeta.Name, pdb.Spec.MinAvailable.String())
+	return nil
+}
+
 // upsertGameServerSet if the GameServerSet is new, insert it
-// if the replicas do not match the active
+// if the replicas or revision do not match the active
 // GameServerSet, then update it
-func (c *Controller) upsertGameServerSet(fleet *stablev1alpha1.Fleet, active *stablev1alpha1.GameServerSet, replicas int32) error {
+func (c *Controller) upsertGameServerSet(fleet *stablev1alpha1.Fleet, active *stablev1alpha1.GameServerSet, replicas int32, revision int64) error {
 	if active.ObjectMeta.UID == "" {
 		active.Spec.Replicas = replicas
+		setRevision(active, revision)
 		gsSets := c.gameServerSetGetter.GameServerSets(active.ObjectMeta.Namespace)
 		gsSet, err := gsSets.Create(active)
 		if err != nil {
@@ -329,10 +646,15 @@ func (c *Controller) upsertGameServerSet(fleet *stablev1alpha1.Fleet, active *st
 		return nil
 	}
 
-	if replicas != active.Spec.Replicas || active.Spec.Scheduling != fleet.Spec.Scheduling {
+	if replicas != active.Spec.Replicas || active.Spec.Scheduling != fleet.Spec.Scheduling || revision != active.Revision() ||
+		active.Spec.ScaleDownPreference != fleet.Spec.ScaleDownPreference || active.Spec.AllocationOverflow != nil {
 		gsSetCopy := active.DeepCopy()
 		gsSetCopy.Spec.Replicas = replicas
 		gsSetCopy.Spec.Scheduling = fleet.Spec.Scheduling
+		gsSetCopy.Spec.ScaleDownPreference = fleet.Spec.ScaleDownPreference
+		// the GameServerSet may have been outdated before the template was rolled back to it
+		gsSetCopy.Spec.AllocationOverflow = nil
+		setRevision(gsSetCopy, revision)
 		gsSetCopy, err := c.gameServerSetGetter.GameServerSets(fleet.ObjectMeta.Namespace).Update(gsSetCopy)
 		if err != nil {
 			return errors.Wrapf(err, "error updating replicas for gameserverset for fleet %s", fleet.ObjectMeta.Name)
@@ -344,6 +666,73 @@ func (c *Controller) upsertGameServerSet(fleet *stablev1alpha1.Fleet, active *st
 	return nil
 }
 
+// updateAllocationOverflow sets the allocation overflow of the Fleet on its outdated GameServerSets,
+// so that it is applied to their Allocated GameServers. The updated GameServerSets replace those in
+// rest, so that the deployment strategy updates the latest versions of them.
+func (c *Controller) updateAllocationOverflow(fleet *stablev1alpha1.Fleet, rest []*stablev1alpha1.GameServerSet) error {
+	for i, gsSet := range rest {
+		if reflect.DeepEqual(gsSet.Spec.AllocationOverflow, fleet.Spec.AllocationOverflow) {
+			continue
+		}
+		gsSetCopy := gsSet.DeepCopy()
+		gsSetCopy.Spec.AllocationOverflow = fleet.Spec.AllocationOverflow.DeepCopy()
+		updated, err := c.gameServerSetGetter.GameServerSets(gsSetCopy.ObjectMeta.Namespace).Update(gsSetCopy)
+		if err != nil {
+			return errors.Wrapf(err, "error updating allocation overflow of gameserverset %s", gsSetCopy.ObjectMeta.Name)
+		}
+		rest[i] = updated
+	}
+	return nil
+}
+
+// upsertCanaryGameServerSet creates the GameServerSet of the Fleet's canary template, if it is new,
+// or scales it to the canary's share of the Fleet's replicas. A paused Fleet doesn't create it.
+func (c *Controller) upsertCanaryGameServerSet(fleet *stablev1alpha1.Fleet, canary *stablev1alpha1.GameServerSet) error {
+	replicas := fleet.CanaryReplicas()
+	if canary == nil {
+		if fleet.Spec.Canary == nil || fleet.Spec.Paused {
+			return nil
+		}
+		canary = fleet.CanaryGameServerSet()
+		canary.Spec.Replicas = replicas
+		setAnnotation(canary, stablev1alpha1.GameServerSetSidecarImageAnnotation, c.sidecarRollout.image)
+		gsSets := c.gameServerSetGetter.GameServerSets(canary.ObjectMeta.Namespace)
+		gsSet, err := gsSets.Create(canary)
+		if err != nil {
+			return errors.Wrapf(err, "error creating canary gameserverset for fleet %s", fleet.ObjectMeta.Name)
+		}
+
+		// extra step which is needed to set
+		// default values for GameServerSet Status Subresource
+		gsSetCopy := gsSet.DeepCopy()
+		gsSetCopy.Status.ReadyReplicas = 0
+		gsSetCopy.Status.Replicas = 0
+		gsSetCopy.Status.AllocatedReplicas = 0
+		if _, err = gsSets.UpdateStatus(gsSetCopy); err != nil {
+			return errors.Wrapf(err, "error updating status of canary gameserverset for fleet %s", fleet.ObjectMeta.Name)
+		}
+
+		c.recorder.Eventf(fleet, corev1.EventTypeNormal, "CreatingGameServerSet",
+			"Created canary GameServerSet %s", gsSet.ObjectMeta.Name)
+		return nil
+	}
+
+	if replicas != canary.Spec.Replicas || canary.Spec.Scheduling != fleet.Spec.Scheduling ||
+		canary.Spec.ScaleDownPreference != fleet.Spec.ScaleDownPreference {
+		gsSetCopy := canary.DeepCopy()
+		gsSetCopy.Spec.Replicas = replicas
+		gsSetCopy.Spec.Scheduling = fleet.Spec.Scheduling
+		gsSetCopy.Spec.ScaleDownPreference = fleet.Spec.ScaleDownPreference
+		if _, err := c.gameServerSetGetter.GameServerSets(fleet.ObjectMeta.Namespace).Update(gsSetCopy); err != nil {
+			return errors.Wrapf(err, "error updating replicas for canary gameserverset for fleet %s", fleet.ObjectMeta.Name)
+		}
+		c.recorder.Eventf(fleet, corev1.EventTypeNormal, "ScalingGameServerSet",
+			"Scaling canary GameServerSet %s from %d to %d", canary.ObjectMeta.Name, canary.Spec.Replicas, replicas)
+	}
+
+	return nil
+}
+
 // applyDeploymentStrategy applies the Fleet > Spec > Deployment strategy to all the non-active
 // GameServerSets that are passed in
 func (c *Controller) applyDeploymentStrategy(fleet *stablev1alpha1.Fleet, active *stablev1alpha1.GameServerSet, rest []*stablev1alpha1.GameServerSet) (int32, error) {
@@ -354,6 +743,17 @@ func (c *Controller) applyDeploymentStrategy(fleet *stablev1alpha1.Fleet, active
 		return fleet.Spec.Replicas, nil
 	}
 
+	if fleet.Spec.Paused {
+		c.loggerForFleet(fleet).Info("fleet is paused, pausing deployment")
+		return c.pausedDeployment(fleet, rest), nil
+	}
+
+	if open, wait := fleet.InUpdateWindow(time.Now()); !open {
+		c.loggerForFleet(fleet).WithField("wait", wait).Info("outside of update windows, pausing deployment")
+		c.workerqueue.EnqueueAfter(fleet, wait)
+		return c.pausedDeployment(fleet, rest), nil
+	}
+
 	switch fleet.Spec.Strategy.Type {
 	case appsv1.RecreateDeploymentStrategyType:
 		return c.recreateDeployment(fleet, rest)
@@ -364,6 +764,17 @@ func (c *Controller) applyDeploymentStrategy(fleet *stablev1alpha1.Fleet, active
 	return 0, errors.Errorf("unexpected deployment strategy type: %s", fleet.Spec.Strategy.Type)
 }
 
+// pausedDeployment leaves all non-active GameServerSets as they are while the Fleet is paused, or outside
+// of its update windows, and returns the replica count for the active GameServerSet, so that the Fleet can
+// still be scaled through it
+func (c *Controller) pausedDeployment(fleet *stablev1alpha1.Fleet, rest []*stablev1alpha1.GameServerSet) int32 {
+	replicas := fleet.Spec.Replicas
+	for _, gsSet := range rest {
+		replicas -= gsSet.Spec.Replicas
+	}
+	return fleet.LowerBoundReplicas(replicas)
+}
+
 // deleteEmptyGameServerSets deletes all GameServerServerSets
 // That have `Status > Replicas` of 0
 func (c *Controller) deleteEmptyGameServerSets(fleet *stablev1alpha1.Fleet, list []*stablev1alpha1.GameServerSet) error {
@@ -519,21 +930,204 @@ func (c *Controller) updateFleetStatus(fleet *stablev1alpha1.Fleet) error {
 	if err != nil {
 		return err
 	}
+	previous := fCopy.Status.DeepCopy()
 	fCopy.Status.Replicas = 0
 	fCopy.Status.ReadyReplicas = 0
 	fCopy.Status.ReservedReplicas = 0
 	fCopy.Status.AllocatedReplicas = 0
+	fCopy.Status.UpdatedReplicas = 0
+	fCopy.Status.Players = nil
+	fCopy.Status.Counters = nil
+	fCopy.Status.Lists = nil
+	fCopy.Status.OutdatedSidecarReplicas = 0
+
+	fCopy.Status.CanaryReplicas = 0
+
+	// the GameServerSet of a new template may not be listed yet, so keep the current revision until it is
+	canary, current := filterGameServerSetByCanary(fCopy, list)
+	active, rest := c.filterGameServerSetByActive(fCopy, current)
+	if active != nil && active.Revision() > 0 {
+		fCopy.Status.Revision = active.Revision()
+	}
+	if active != nil {
+		fCopy.Status.UpdatedReplicas = active.Status.Replicas
+	}
+	if canary != nil {
+		fCopy.Status.CanaryReplicas = canary.Status.Replicas
+		fCopy.Status.UpdatedReplicas += canary.Status.Replicas
+	}
+	fCopy.Status.RevisionHistory = revisionHistory(fCopy, rest)
 
 	for _, gsSet := range list {
 		fCopy.Status.Replicas += gsSet.Status.Replicas
 		fCopy.Status.ReadyReplicas += gsSet.Status.ReadyReplicas
 		fCopy.Status.ReservedReplicas += gsSet.Status.ReservedReplicas
 		fCopy.Status.AllocatedReplicas += gsSet.Status.AllocatedReplicas
+		if p := gsSet.Status.Players; p != nil {
+			if fCopy.Status.Players == nil {
+				fCopy.Status.Players = &stablev1alpha1.AggregatedPlayerStatus{}
+			}
+			fCopy.Status.Players.Merge(*p)
+		}
+		for name, a := range gsSet.Status.Counters {
+			if fCopy.Status.Counters == nil {
+				fCopy.Status.Counters = map[string]stablev1alpha1.AggregatedCounterStatus{}
+			}
+			total := fCopy.Status.Counters[name]
+			total.Merge(a)
+			fCopy.Status.Counters[name] = total
+		}
+		for name, a := range gsSet.Status.Lists {
+			if fCopy.Status.Lists == nil {
+				fCopy.Status.Lists = map[string]stablev1alpha1.AggregatedListStatus{}
+			}
+			total := fCopy.Status.Lists[name]
+			total.Merge(a)
+			fCopy.Status.Lists[name] = total
+		}
+		if c.sidecarRollout.enabled() && c.sidecarRollout.outdated(gsSet) {
+			fCopy.Status.OutdatedSidecarReplicas += gsSet.Status.Replicas
+		}
 	}
+	c.updateFleetConditions(fCopy, previous, active, list)
+
 	_, err = c.fleetGetter.Fleets(fCopy.ObjectMeta.Namespace).UpdateStatus(fCopy)
 	return errors.Wrapf(err, "error updating status of fleet %s", fCopy.ObjectMeta.Name)
 }
 
+// updateFleetConditions sets the ReplicaFailure condition of the Fleet from the creation failures of its
+// GameServerSets, and its Progressing condition from how its rollout progressed since its previous status.
+// A rollout that goes without progress for longer than the Fleet's progress deadline is reported as stalled.
+func (c *Controller) updateFleetConditions(fleet *stablev1alpha1.Fleet, previous *stablev1alpha1.FleetStatus,
+	active *stablev1alpha1.GameServerSet, list []*stablev1alpha1.GameServerSet) {
+	status := &fleet.Status
+
+	failure := ""
+	for _, gsSet := range list {
+		if len(gsSet.Status.CreationFailures) > 0 {
+			failure = fmt.Sprintf("GameServerSet %s: %s", gsSet.ObjectMeta.Name, gsSet.Status.CreationFailures[0].Message)
+			break
+		}
+	}
+	if failure != "" {
+		status.SetCondition(stablev1alpha1.FleetReplicaFailure, corev1.ConditionTrue, "FailedCreate", failure)
+	} else {
+		status.RemoveCondition(stablev1alpha1.FleetReplicaFailure)
+	}
+
+	deadline := fleet.ProgressDeadline()
+	progressing := status.Condition(stablev1alpha1.FleetProgressing)
+	open, _ := fleet.InUpdateWindow(time.Now())
+	switch {
+	case active != nil && status.UpdatedReplicas == fleet.Spec.Replicas && status.Replicas == fleet.Spec.Replicas &&
+		status.ReadyReplicas+status.ReservedReplicas+status.AllocatedReplicas >= fleet.Spec.Replicas:
+		status.SetCondition(stablev1alpha1.FleetProgressing, corev1.ConditionTrue, progressingReasonAvailable,
+			fmt.Sprintf("GameServerSet %s has successfully progressed", active.ObjectMeta.Name))
+	case fleet.Spec.Paused || !open:
+		status.SetCondition(stablev1alpha1.FleetProgressing, corev1.ConditionUnknown, progressingReasonPaused,
+			"Fleet rollout is paused")
+	case progressing == nil || progressing.Reason == progressingReasonAvailable || progressing.Reason == progressingReasonPaused ||
+		progressed(status, previous):
+		status.SetCondition(stablev1alpha1.FleetProgressing, corev1.ConditionTrue, progressingReasonUpdated, "Fleet is progressing")
+		status.Condition(stablev1alpha1.FleetProgressing).LastUpdateTime = metav1.Now()
+		// check the deadline, even if nothing else changes in the meantime
+		c.workerqueue.EnqueueAfter(fleet, deadline)
+	case progressing.Status == corev1.ConditionTrue && time.Since(progressing.LastUpdateTime.Time) > deadline:
+		message := fmt.Sprintf("Fleet has not progressed for more than %s", deadline)
+		status.SetCondition(stablev1alpha1.FleetProgressing, corev1.ConditionFalse, progressingReasonDeadlineExceeded, message)
+		c.recorder.Event(fleet, corev1.EventTypeWarning, progressingReasonDeadlineExceeded, message)
+	case progressing.Status == corev1.ConditionTrue:
+		c.workerqueue.EnqueueAfter(fleet, deadline-time.Since(progressing.LastUpdateTime.Time))
+	}
+}
+
+// progressed returns if a rollout of a Fleet progressed between its previous and current status, by having
+// more GameServers of its current template, more Ready GameServers, or fewer GameServers of previous templates
+func progressed(current, previous *stablev1alpha1.FleetStatus) bool {
+	return current.UpdatedReplicas > previous.UpdatedReplicas || current.ReadyReplicas > previous.ReadyReplicas ||
+		current.Replicas-current.UpdatedReplicas < previous.Replicas-previous.UpdatedReplicas
+}
+
+// revisionHistory returns the revision history of the Fleet, with the revisions of its inactive
+// GameServerSets added, and those of its current template removed. It is sorted newest first,
+// and trimmed to the Fleet's revision history limit.
+func revisionHistory(fleet *stablev1alpha1.Fleet, rest []*stablev1alpha1.GameServerSet) []stablev1alpha1.FleetRevision {
+	revisions := make(map[int64]stablev1alpha1.FleetRevision, len(fleet.Status.RevisionHistory)+len(rest))
+	for _, r := range fleet.Status.RevisionHistory {
+		revisions[r.Revision] = r
+	}
+	for _, gsSet := range rest {
+		if r := gsSet.Revision(); r > 0 {
+			revisions[r] = stablev1alpha1.FleetRevision{Revision: r, Template: gsSet.Spec.Template}
+		}
+	}
+
+	var history []stablev1alpha1.FleetRevision
+	for _, r := range revisions {
+		if r.Revision == fleet.Status.Revision || reflect.DeepEqual(r.Template, fleet.Spec.Template) {
+			continue
+		}
+		history = append(history, r)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Revision > history[j].Revision
+	})
+	if limit := fleet.RevisionHistoryLimit(); len(history) > limit {
+		history = history[:limit]
+	}
+	return history
+}
+
+// latestRevision returns the latest revision of the Fleet's template, from its status
+// and its GameServerSets
+func latestRevision(fleet *stablev1alpha1.Fleet, list []*stablev1alpha1.GameServerSet) int64 {
+	latest := fleet.Status.Revision
+	for _, r := range fleet.Status.RevisionHistory {
+		if r.Revision > latest {
+			latest = r.Revision
+		}
+	}
+	for _, gsSet := range list {
+		if r := gsSet.Revision(); r > latest {
+			latest = r
+		}
+	}
+	return latest
+}
+
+// setRevision sets the revision annotation of the GameServerSet
+func setRevision(gsSet *stablev1alpha1.GameServerSet, revision int64) {
+	setAnnotation(gsSet, stablev1alpha1.FleetRevisionAnnotation, strconv.FormatInt(revision, 10))
+}
+
+// setAnnotation sets the annotation of the GameServerSet to value
+func setAnnotation(gsSet *stablev1alpha1.GameServerSet, annotation, value string) {
+	if gsSet.ObjectMeta.Annotations == nil {
+		gsSet.ObjectMeta.Annotations = make(map[string]string, 1)
+	}
+	gsSet.ObjectMeta.Annotations[annotation] = value
+}
+
+// filterGameServerSetByCanary returns the GameServerSet of the Fleet's canary template (or nil if it
+// doesn't exist, or the Fleet has no canary) and then the rest of the GameServerSets that are controlled
+// by this Fleet. The GameServerSets of previous canary templates are part of the rest, so that they are
+// replaced through the Fleet's deployment strategy.
+func filterGameServerSetByCanary(fleet *stablev1alpha1.Fleet, list []*stablev1alpha1.GameServerSet) (*stablev1alpha1.GameServerSet, []*stablev1alpha1.GameServerSet) {
+	var canary *stablev1alpha1.GameServerSet
+	var rest []*stablev1alpha1.GameServerSet
+
+	for _, gsSet := range list {
+		if canary == nil && fleet.Spec.Canary != nil && gsSet.ObjectMeta.Labels[stablev1alpha1.FleetCanaryLabel] == "true" &&
+			reflect.DeepEqual(gsSet.Spec.Template, fleet.Spec.Canary.Template) && gsSet.Spec.PortRange == fleet.Spec.PortRange {
+			canary = gsSet
+		} else {
+			rest = append(rest, gsSet)
+		}
+	}
+
+	return canary, rest
+}
+
 // filterGameServerSetByActive returns the active GameServerSet (or nil if it
 // doesn't exist) and then the rest of the GameServerSets that are controlled
 // by this Fleet
@@ -542,7 +1136,16 @@ func (c *Controller) filterGameServerSetByActive(fleet *stablev1alpha1.Fleet, li
 	var rest []*stablev1alpha1.GameServerSet
 
 	for _, gsSet := range list {
-		if reflect.DeepEqual(gsSet.Spec.Template, fleet.Spec.Template) {
+		if reflect.DeepEqual(gsSet.Spec.Template, fleet.Spec.Template) && gsSet.Spec.PortRange == fleet.Spec.PortRange {
+			// while the sidecar image is rolled out, the template can have a GameServerSet with an outdated
+			// sidecar image as well, and the one with the current image is the active one
+			if c.sidecarRollout.enabled() && active != nil {
+				if !c.sidecarRollout.outdated(active) {
+					rest = append(rest, gsSet)
+					continue
+				}
+				rest = append(rest, active)
+			}
 			active = gsSet
 		} else {
 			rest = append(rest, gsSet)
@@ -17,13 +17,17 @@ package fleets
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"agones.dev/agones/pkg/apis"
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
 	agtesting "agones.dev/agones/pkg/testing"
+	"agones.dev/agones/pkg/testing/fuzzer"
+	"agones.dev/agones/pkg/util/apiserver"
 	"agones.dev/agones/pkg/util/webhooks"
 	"github.com/heptiolabs/healthcheck"
 	"github.com/mattbaird/jsonpatch"
@@ -31,6 +35,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	admv1beta1 "k8s.io/api/admission/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -58,6 +65,7 @@ func TestControllerSyncFleet(t *testing.T) {
 			created = true
 			assert.True(t, metav1.IsControlledBy(gsSet, f))
 			assert.Equal(t, f.Spec.Replicas, gsSet.Spec.Replicas)
+			assert.Equal(t, "1", gsSet.ObjectMeta.Annotations[v1alpha1.FleetRevisionAnnotation])
 
 			return true, gsSet, nil
 		})
@@ -100,7 +108,11 @@ func TestControllerSyncFleet(t *testing.T) {
 			return true, nil, nil
 		})
 
-		_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.gameServerSetSynced)
+		m.KubeClient.AddReactor("list", "poddisruptionbudgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &policyv1beta1.PodDisruptionBudgetList{Items: []policyv1beta1.PodDisruptionBudget{*f.PodDisruptionBudget()}}, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.gameServerSetSynced, c.pdbSynced)
 		defer cancel()
 
 		err := c.syncFleet("default/fleet-1")
@@ -183,6 +195,41 @@ func TestControllerSyncFleet(t *testing.T) {
 		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "ScalingGameServerSet")
 	})
 
+	t.Run("gameserverset with different scale down preference", func(t *testing.T) {
+		f := defaultFixture()
+		f.Spec.ScaleDownPreference = v1alpha1.ScaleDownCost
+		c, m := newFakeController()
+		gsSet := f.GameServerSet()
+		gsSet.ObjectMeta.Name = "gsSet1"
+		gsSet.ObjectMeta.UID = "1234"
+		gsSet.Spec.Replicas = f.Spec.Replicas
+		gsSet.Spec.ScaleDownPreference = ""
+		updated := false
+
+		m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.FleetList{Items: []v1alpha1.Fleet{*f}}, nil
+		})
+
+		m.AgonesClient.AddReactor("list", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.GameServerSetList{Items: []v1alpha1.GameServerSet{*gsSet}}, nil
+		})
+
+		m.AgonesClient.AddReactor("update", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			updated = true
+			gsSet := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.GameServerSet)
+			assert.Equal(t, f.Spec.Replicas, gsSet.Spec.Replicas)
+			assert.Equal(t, v1alpha1.ScaleDownCost, gsSet.Spec.ScaleDownPreference)
+			return true, gsSet, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.gameServerSetSynced)
+		defer cancel()
+
+		err := c.syncFleet("default/fleet-1")
+		assert.Nil(t, err)
+		assert.True(t, updated, "gameserverset should have been updated")
+	})
+
 	t.Run("gameserverset with different image details", func(t *testing.T) {
 		f := defaultFixture()
 		f.Spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
@@ -242,50 +289,553 @@ func TestControllerSyncFleet(t *testing.T) {
 		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "ScalingGameServerSet")
 		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "CreatingGameServerSet")
 	})
+
+	t.Run("paused fleet with a new template", func(t *testing.T) {
+		f := defaultFixture()
+		f.Spec.Paused = true
+		f.Spec.Template.Spec.Ports = []v1alpha1.GameServerPort{{HostPort: 5555}}
+		c, m := newFakeController()
+		gsSet := f.GameServerSet()
+		gsSet.ObjectMeta.Name = "gsSet1"
+		gsSet.ObjectMeta.UID = "4321"
+		gsSet.Spec.Template.Spec.Ports = []v1alpha1.GameServerPort{{HostPort: 7777}}
+		gsSet.Spec.Replicas = f.Spec.Replicas
+		gsSet.Status.Replicas = f.Spec.Replicas
+		statusUpdated := false
+
+		m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.FleetList{Items: []v1alpha1.Fleet{*f}}, nil
+		})
+		m.AgonesClient.AddReactor("list", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.GameServerSetList{Items: []v1alpha1.GameServerSet{*gsSet}}, nil
+		})
+		m.AgonesClient.AddReactor("create", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "gameserverset should not be created")
+			return true, nil, nil
+		})
+		m.AgonesClient.AddReactor("update", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "gameserverset should not be updated")
+			return true, nil, nil
+		})
+		m.AgonesClient.AddReactor("update", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			ua := action.(k8stesting.UpdateAction)
+			fleet := ua.GetObject().(*v1alpha1.Fleet)
+			statusUpdated = ua.GetSubresource() == "status"
+			assert.Equal(t, f.Spec.Replicas, fleet.Status.Replicas)
+			return true, fleet, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.gameServerSetSynced)
+		defer cancel()
+
+		err := c.syncFleet("default/fleet-1")
+		assert.Nil(t, err)
+		assert.True(t, statusUpdated, "fleet status should have been updated")
+	})
+
+	t.Run("gameserverset with an outdated sidecar image", func(t *testing.T) {
+		f := defaultFixture()
+		f.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+		c, m := newFakeController()
+		c.sidecarRollout = newSidecarRollout("sidecar:2", 1)
+		gsSet := f.GameServerSet()
+		gsSet.ObjectMeta.Name = "gsSet1"
+		gsSet.ObjectMeta.UID = "4321"
+		gsSet.ObjectMeta.Annotations = map[string]string{v1alpha1.GameServerSetSidecarImageAnnotation: "sidecar:1"}
+		gsSet.Spec.Replicas = f.Spec.Replicas
+		gsSet.Status.Replicas = f.Spec.Replicas
+		created := false
+		scaledDown := false
+
+		m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.FleetList{Items: []v1alpha1.Fleet{*f}}, nil
+		})
+		m.AgonesClient.AddReactor("list", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.GameServerSetList{Items: []v1alpha1.GameServerSet{*gsSet}}, nil
+		})
+		m.AgonesClient.AddReactor("create", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			created = true
+			gsSet := action.(k8stesting.CreateAction).GetObject().(*v1alpha1.GameServerSet)
+			assert.Equal(t, "sidecar:2", gsSet.ObjectMeta.Annotations[v1alpha1.GameServerSetSidecarImageAnnotation])
+			assert.Equal(t, f.Spec.Template, gsSet.Spec.Template)
+			return true, gsSet, nil
+		})
+		m.AgonesClient.AddReactor("update", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			ua := action.(k8stesting.UpdateAction)
+			gsSet := ua.GetObject().(*v1alpha1.GameServerSet)
+			if ua.GetSubresource() == "" && gsSet.ObjectMeta.Name == "gsSet1" {
+				scaledDown = true
+				assert.Equal(t, int32(0), gsSet.Spec.Replicas)
+			}
+			return true, gsSet, nil
+		})
+		m.AgonesClient.AddReactor("update", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			fleet := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.Fleet)
+			assert.Equal(t, gsSet.Status.Replicas, fleet.Status.OutdatedSidecarReplicas)
+			return true, fleet, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.gameServerSetSynced)
+		defer cancel()
+
+		err := c.syncFleet("default/fleet-1")
+		assert.Nil(t, err)
+		assert.True(t, created, "gameserverset should have been created")
+		assert.True(t, scaledDown, "outdated gameserverset should have been scaled down")
+		assert.Equal(t, map[string]bool{"default/fleet-1": true}, c.sidecarRollout.fleets)
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "RollingOutSidecar")
+
+		// another fleet waits for this one to finish
+		c.sidecarRollout.fleets = map[string]bool{"default/other": true}
+		created = false
+		scaledDown = false
+		err = c.syncFleet("default/fleet-1")
+		assert.Nil(t, err)
+		assert.False(t, created, "gameserverset should not have been created")
+		assert.False(t, scaledDown, "outdated gameserverset should not have been scaled down")
+	})
+
+	t.Run("rollback to a revision", func(t *testing.T) {
+		f := defaultFixture()
+		f.ObjectMeta.Annotations = map[string]string{v1alpha1.FleetRollbackAnnotation: "1"}
+		f.Status.Revision = 2
+		previous := f.Spec.Template.DeepCopy()
+		previous.Spec.Ports = []v1alpha1.GameServerPort{{HostPort: 7777}}
+		f.Status.RevisionHistory = []v1alpha1.FleetRevision{{Revision: 1, Template: *previous}}
+		c, m := newFakeController()
+		updated := false
+
+		m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.FleetList{Items: []v1alpha1.Fleet{*f}}, nil
+		})
+		m.AgonesClient.AddReactor("update", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			updated = true
+			fleet := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.Fleet)
+			assert.Equal(t, *previous, fleet.Spec.Template)
+			assert.NotContains(t, fleet.ObjectMeta.Annotations, v1alpha1.FleetRollbackAnnotation)
+			return true, fleet, nil
+		})
+		m.AgonesClient.AddReactor("create", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "gameserverset should not be created")
+			return true, nil, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.fleetSynced)
+		defer cancel()
+
+		err := c.syncFleet("default/fleet-1")
+		assert.Nil(t, err)
+		assert.True(t, updated, "fleet should have been updated")
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Rolling back to revision 1")
+	})
+
+	t.Run("rollback to a revision that isn't in the history", func(t *testing.T) {
+		f := defaultFixture()
+		f.ObjectMeta.Annotations = map[string]string{v1alpha1.FleetRollbackAnnotation: "5"}
+		c, m := newFakeController()
+		updated := false
+
+		m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.FleetList{Items: []v1alpha1.Fleet{*f}}, nil
+		})
+		m.AgonesClient.AddReactor("update", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			updated = true
+			fleet := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.Fleet)
+			assert.Equal(t, f.Spec.Template, fleet.Spec.Template)
+			assert.NotContains(t, fleet.ObjectMeta.Annotations, v1alpha1.FleetRollbackAnnotation)
+			return true, fleet, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.fleetSynced)
+		defer cancel()
+
+		err := c.syncFleet("default/fleet-1")
+		assert.Nil(t, err)
+		assert.True(t, updated, "fleet should have been updated")
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "RollbackRevisionNotFound")
+	})
+
+	t.Run("gameserverset rolled back to gets a new revision", func(t *testing.T) {
+		f := defaultFixture()
+		f.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+		f.Status.Revision = 2
+		c, m := newFakeController()
+		gsSet := f.GameServerSet()
+		gsSet.ObjectMeta.Name = "gsSet1"
+		gsSet.ObjectMeta.UID = "1234"
+		gsSet.ObjectMeta.Annotations = map[string]string{v1alpha1.FleetRevisionAnnotation: "1"}
+		gsSet.Spec.Replicas = f.Spec.Replicas
+		updated := false
+
+		m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.FleetList{Items: []v1alpha1.Fleet{*f}}, nil
+		})
+		m.AgonesClient.AddReactor("list", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.GameServerSetList{Items: []v1alpha1.GameServerSet{*gsSet}}, nil
+		})
+		m.AgonesClient.AddReactor("update", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			updated = true
+			gsSet := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.GameServerSet)
+			assert.Equal(t, int64(3), gsSet.Revision())
+			return true, gsSet, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.gameServerSetSynced)
+		defer cancel()
+
+		err := c.syncFleet("default/fleet-1")
+		assert.Nil(t, err)
+		assert.True(t, updated, "gameserverset should have been updated")
+	})
+
+	t.Run("canary, create its gameserverset", func(t *testing.T) {
+		f := defaultFixture()
+		f.Spec.Replicas = 10
+		f.Spec.Canary = &v1alpha1.FleetCanary{Weight: 20, Template: *f.Spec.Template.DeepCopy()}
+		f.Spec.Canary.Template.Spec.Ports = []v1alpha1.GameServerPort{{ContainerPort: 7777}}
+		c, m := newFakeController()
+		var replicas []int32
+
+		m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.FleetList{Items: []v1alpha1.Fleet{*f}}, nil
+		})
+		m.AgonesClient.AddReactor("create", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gsSet := action.(k8stesting.CreateAction).GetObject().(*v1alpha1.GameServerSet)
+			assert.True(t, metav1.IsControlledBy(gsSet, f))
+			if gsSet.ObjectMeta.Labels[v1alpha1.FleetCanaryLabel] == "true" {
+				assert.Equal(t, f.Spec.Canary.Template, gsSet.Spec.Template)
+				assert.NotContains(t, gsSet.ObjectMeta.Annotations, v1alpha1.FleetRevisionAnnotation)
+			} else {
+				assert.Equal(t, f.Spec.Template, gsSet.Spec.Template)
+			}
+			replicas = append(replicas, gsSet.Spec.Replicas)
+			return true, gsSet, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.fleetSynced)
+		defer cancel()
+
+		err := c.syncFleet("default/fleet-1")
+		assert.Nil(t, err)
+		assert.Equal(t, []int32{2, 8}, replicas)
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Created canary GameServerSet")
+	})
+
+	t.Run("canary removed, scale its gameserverset down", func(t *testing.T) {
+		f := defaultFixture()
+		f.Spec.Replicas = 10
+		f.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+		f.Spec.Canary = &v1alpha1.FleetCanary{Weight: 20, Template: *f.Spec.Template.DeepCopy()}
+		f.Spec.Canary.Template.Spec.Ports = []v1alpha1.GameServerPort{{ContainerPort: 7777}}
+		canary := f.CanaryGameServerSet()
+		canary.ObjectMeta.Name = "canary"
+		canary.ObjectMeta.UID = "4321"
+		canary.Spec.Replicas = 2
+		canary.Status.Replicas = 2
+		f.Spec.Canary = nil
+		gsSet := f.GameServerSet()
+		gsSet.ObjectMeta.Name = "gsSet1"
+		gsSet.ObjectMeta.UID = "1234"
+		gsSet.ObjectMeta.Annotations = map[string]string{v1alpha1.FleetRevisionAnnotation: "1"}
+		gsSet.Spec.Replicas = 8
+		gsSet.Status.Replicas = 8
+		c, m := newFakeController()
+		updated := map[string]int32{}
+
+		m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.FleetList{Items: []v1alpha1.Fleet{*f}}, nil
+		})
+		m.AgonesClient.AddReactor("list", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.GameServerSetList{Items: []v1alpha1.GameServerSet{*gsSet, *canary}}, nil
+		})
+		m.AgonesClient.AddReactor("update", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gsSet := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.GameServerSet)
+			updated[gsSet.ObjectMeta.Name] = gsSet.Spec.Replicas
+			return true, gsSet, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.gameServerSetSynced)
+		defer cancel()
+
+		err := c.syncFleet("default/fleet-1")
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]int32{"canary": 0, "gsSet1": 10}, updated)
+	})
+}
+
+func TestControllerCreationMutationHandler(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newFakeController()
+	gvk := metav1.GroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind("Fleet"))
+
+	fixture := v1alpha1.Fleet{}
+
+	raw, err := json.Marshal(fixture)
+	assert.Nil(t, err)
+	review := admv1beta1.AdmissionReview{
+		Request: &admv1beta1.AdmissionRequest{
+			Kind:      gvk,
+			Operation: admv1beta1.Create,
+			Object: runtime.RawExtension{
+				Raw: raw,
+			},
+		},
+		Response: &admv1beta1.AdmissionResponse{Allowed: true},
+	}
+
+	result, err := c.creationMutationHandler(review)
+	assert.Nil(t, err)
+	assert.True(t, result.Response.Allowed)
+	assert.Equal(t, admv1beta1.PatchTypeJSONPatch, *result.Response.PatchType)
+
+	patch := &jsonpatch.ByPath{}
+	err = json.Unmarshal(result.Response.Patch, patch)
+	assert.Nil(t, err)
+
+	assertContains := func(patch *jsonpatch.ByPath, op jsonpatch.JsonPatchOperation) {
+		found := false
+		for _, p := range *patch {
+			if assert.ObjectsAreEqualValues(p, op) {
+				found = true
+			}
+		}
+
+		assert.True(t, found, "Could not find operation %#v in patch %v", op, *patch)
+	}
+
+	assertContains(patch, jsonpatch.JsonPatchOperation{Operation: "add", Path: "/spec/strategy/type", Value: "RollingUpdate"})
+}
+
+func TestControllerResourceEstimatesMutationHandler(t *testing.T) {
+	t.Parallel()
+
+	m := agtesting.NewMocks()
+	sidecar := corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("30m")}}
+	c := NewController(webhooks.NewWebHook(http.NewServeMux()), apiserver.NewAPIServer(http.NewServeMux()), healthcheck.NewHandler(), time.Minute, true, sidecar, "sidecar:1", 0,
+		m.KubeClient, m.ExtClient, m.AgonesClient, m.KubeInformerFactory, m.AgonesInformerFactory)
+
+	gvk := metav1.GroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind("Fleet"))
+	fixture := defaultFixture()
+	fixture.Spec.Template.Spec.Template.Spec.Containers = []corev1.Container{{
+		Name:  "container",
+		Image: "container/image",
+		Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("500m"),
+			corev1.ResourceMemory: resource.MustParse("64Mi"),
+		}},
+	}}
+
+	mutate := func(f *v1alpha1.Fleet, op admv1beta1.Operation, handler func(admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error)) *v1alpha1.Fleet {
+		raw, err := json.Marshal(f)
+		assert.Nil(t, err)
+		review := admv1beta1.AdmissionReview{
+			Request: &admv1beta1.AdmissionRequest{
+				Kind:      gvk,
+				Operation: op,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+			Response: &admv1beta1.AdmissionResponse{Allowed: true},
+		}
+
+		result, err := handler(review)
+		assert.Nil(t, err)
+		assert.True(t, result.Response.Allowed)
+
+		var patch []jsonpatch.JsonPatchOperation
+		assert.Nil(t, json.Unmarshal(result.Response.Patch, &patch))
+		patched, err := fuzzer.ApplyJSONPatch(raw, patch)
+		assert.Nil(t, err)
+		mutated := &v1alpha1.Fleet{}
+		assert.Nil(t, json.Unmarshal(patched, mutated))
+		return mutated
+	}
+
+	created := mutate(fixture, admv1beta1.Create, c.creationMutationHandler)
+	assert.Equal(t, "530m", created.ObjectMeta.Annotations[v1alpha1.FleetCPURequestAnnotation])
+	assert.Equal(t, "64Mi", created.ObjectMeta.Annotations[v1alpha1.FleetMemoryRequestAnnotation])
+
+	created.Spec.Template.Spec.Template.Spec.Containers[0].Resources = corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+	}
+	updated := mutate(created, admv1beta1.Update, c.updateMutationHandler)
+	assert.Equal(t, "1030m", updated.ObjectMeta.Annotations[v1alpha1.FleetCPURequestAnnotation])
+	assert.NotContains(t, updated.ObjectMeta.Annotations, v1alpha1.FleetMemoryRequestAnnotation)
+}
+
+func TestControllerHealthDefaultsMutationHandler(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newFakeController()
+	gvk := metav1.GroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind("Fleet"))
+	mutate := func(f, old *v1alpha1.Fleet, op admv1beta1.Operation, handler func(admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error)) *v1alpha1.Fleet {
+		raw, err := json.Marshal(f)
+		assert.Nil(t, err)
+		review := admv1beta1.AdmissionReview{
+			Request: &admv1beta1.AdmissionRequest{
+				Kind:      gvk,
+				Operation: op,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+			Response: &admv1beta1.AdmissionResponse{Allowed: true},
+		}
+		if old != nil {
+			oldRaw, err := json.Marshal(old)
+			assert.Nil(t, err)
+			review.Request.OldObject = runtime.RawExtension{Raw: oldRaw}
+		}
+
+		result, err := handler(review)
+		assert.Nil(t, err)
+		assert.True(t, result.Response.Allowed)
+
+		var patch []jsonpatch.JsonPatchOperation
+		assert.Nil(t, json.Unmarshal(result.Response.Patch, &patch))
+		patched, err := fuzzer.ApplyJSONPatch(raw, patch)
+		assert.Nil(t, err)
+		mutated := &v1alpha1.Fleet{}
+		assert.Nil(t, json.Unmarshal(patched, mutated))
+		return mutated
+	}
+
+	fixture := defaultFixture()
+	fixture.Spec.HealthDefaults = &v1alpha1.FleetHealthDefaults{PeriodSeconds: 10, FailureThreshold: 5}
+	fixture.Spec.Template.Spec.Health.FailureThreshold = 2
+
+	created := mutate(fixture, nil, admv1beta1.Create, c.creationMutationHandler)
+	assert.Equal(t, int32(10), created.Spec.Template.Spec.Health.PeriodSeconds)
+	assert.Equal(t, int32(2), created.Spec.Template.Spec.Health.FailureThreshold)
+
+	changed := created.DeepCopy()
+	changed.Spec.HealthDefaults = &v1alpha1.FleetHealthDefaults{PeriodSeconds: 20, FailureThreshold: 6, InitialDelaySeconds: 3}
+	updated := mutate(changed, created, admv1beta1.Update, c.updateMutationHandler)
+	assert.Equal(t, int32(20), updated.Spec.Template.Spec.Health.PeriodSeconds)
+	assert.Equal(t, int32(2), updated.Spec.Template.Spec.Health.FailureThreshold)
+	assert.Equal(t, int32(3), updated.Spec.Template.Spec.Health.InitialDelaySeconds)
+	assert.NotContains(t, updated.ObjectMeta.Annotations, v1alpha1.FleetCPURequestAnnotation)
+}
+
+func TestControllerValidationHandler(t *testing.T) {
+	t.Parallel()
+
+	gvk := metav1.GroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind("Fleet"))
+	newReview := func(f *v1alpha1.Fleet, op admv1beta1.Operation) admv1beta1.AdmissionReview {
+		raw, err := json.Marshal(f)
+		assert.Nil(t, err)
+		return admv1beta1.AdmissionReview{
+			Request: &admv1beta1.AdmissionRequest{
+				Kind:      gvk,
+				Operation: op,
+				Name:      f.ObjectMeta.Name,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+			Response: &admv1beta1.AdmissionResponse{Allowed: true},
+		}
+	}
+
+	for _, op := range []admv1beta1.Operation{admv1beta1.Create, admv1beta1.Update} {
+		t.Run(string(op), func(t *testing.T) {
+			c, _ := newFakeController()
+			f := defaultFixture()
+			f.Spec.Template.Spec.Template.Spec.Containers = []corev1.Container{{Name: "container", Image: "myimage"}}
+
+			result, err := c.validationHandler(newReview(f, op))
+			assert.Nil(t, err)
+			assert.True(t, result.Response.Allowed)
+
+			f.Spec.Template.Spec.Container = "missing"
+			f.Spec.Template.Spec.Template.Spec.Containers = append(f.Spec.Template.Spec.Template.Spec.Containers,
+				corev1.Container{Name: "container2", Image: "myimage"})
+			result, err = c.validationHandler(newReview(f, op))
+			assert.Nil(t, err)
+			if assert.False(t, result.Response.Allowed) {
+				assert.Equal(t, metav1.StatusReasonInvalid, result.Response.Result.Reason)
+			}
+			if assert.Len(t, result.Response.Result.Details.Causes, 1) {
+				assert.Equal(t, "template.spec.container", result.Response.Result.Details.Causes[0].Field)
+			}
+		})
+	}
+
+	t.Run("unknown fields", func(t *testing.T) {
+		c, _ := newFakeController()
+		f := defaultFixture()
+		f.Spec.Template.Spec.Template.Spec.Containers = []corev1.Container{{Name: "container", Image: "myimage"}}
+		review := newReview(f, admv1beta1.Create)
+		review.Request.Object.Raw = []byte(strings.Replace(string(review.Request.Object.Raw), `"replicas":5`, `"replica":5`, 1))
+
+		result, err := c.validationHandler(review)
+		assert.Nil(t, err)
+		if assert.False(t, result.Response.Allowed) {
+			assert.Equal(t, metav1.StatusReasonInvalid, result.Response.Result.Reason)
+		}
+		if assert.Len(t, result.Response.Result.Details.Causes, 1) {
+			assert.Equal(t, "spec.replica", result.Response.Result.Details.Causes[0].Field)
+		}
+	})
 }
 
-func TestControllerCreationMutationHandler(t *testing.T) {
+func TestControllerDeletionValidationHandler(t *testing.T) {
 	t.Parallel()
 
-	c, _ := newFakeController()
 	gvk := metav1.GroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind("Fleet"))
-
-	fixture := v1alpha1.Fleet{}
-
-	raw, err := json.Marshal(fixture)
-	assert.Nil(t, err)
-	review := admv1beta1.AdmissionReview{
-		Request: &admv1beta1.AdmissionRequest{
-			Kind:      gvk,
-			Operation: admv1beta1.Create,
-			Object: runtime.RawExtension{
-				Raw: raw,
+	newReview := func(f *v1alpha1.Fleet, old bool) admv1beta1.AdmissionReview {
+		review := admv1beta1.AdmissionReview{
+			Request: &admv1beta1.AdmissionRequest{
+				Kind:      gvk,
+				Operation: admv1beta1.Delete,
+				Name:      f.ObjectMeta.Name,
+				Namespace: f.ObjectMeta.Namespace,
 			},
-		},
-		Response: &admv1beta1.AdmissionResponse{Allowed: true},
+			Response: &admv1beta1.AdmissionResponse{Allowed: true},
+		}
+		if old {
+			raw, err := json.Marshal(f)
+			assert.Nil(t, err)
+			review.Request.OldObject = runtime.RawExtension{Raw: raw}
+		}
+		return review
 	}
 
-	result, err := c.creationMutationHandler(review)
-	assert.Nil(t, err)
-	assert.True(t, result.Response.Allowed)
-	assert.Equal(t, admv1beta1.PatchTypeJSONPatch, *result.Response.PatchType)
+	t.Run("old object in review", func(t *testing.T) {
+		c, _ := newFakeController()
+		f := defaultFixture()
 
-	patch := &jsonpatch.ByPath{}
-	err = json.Unmarshal(result.Response.Patch, patch)
-	assert.Nil(t, err)
+		result, err := c.deletionValidationHandler(newReview(f, true))
+		assert.Nil(t, err)
+		assert.True(t, result.Response.Allowed)
 
-	assertContains := func(patch *jsonpatch.ByPath, op jsonpatch.JsonPatchOperation) {
-		found := false
-		for _, p := range *patch {
-			if assert.ObjectsAreEqualValues(p, op) {
-				found = true
-			}
-		}
+		f.ObjectMeta.Annotations = map[string]string{v1alpha1.FleetDeleteProtectionAnnotation: v1alpha1.FleetDeleteProtectionAlways}
+		result, err = c.deletionValidationHandler(newReview(f, true))
+		assert.Nil(t, err)
+		assert.False(t, result.Response.Allowed)
+		assert.Equal(t, metav1.StatusReasonForbidden, result.Response.Result.Reason)
+		assert.Len(t, result.Response.Result.Details.Causes, 1)
+	})
 
-		assert.True(t, found, "Could not find operation %#v in patch %v", op, *patch)
-	}
+	t.Run("fleet from cache", func(t *testing.T) {
+		c, m := newFakeController()
+		f := defaultFixture()
+		f.ObjectMeta.Annotations = map[string]string{v1alpha1.FleetDeleteProtectionAnnotation: v1alpha1.FleetDeleteProtectionAllocated}
+		f.Status.AllocatedReplicas = 1
 
-	assertContains(patch, jsonpatch.JsonPatchOperation{Operation: "add", Path: "/spec/strategy/type", Value: "RollingUpdate"})
+		m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.FleetList{Items: []v1alpha1.Fleet{*f}}, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.fleetSynced)
+		defer cancel()
+
+		result, err := c.deletionValidationHandler(newReview(f, false))
+		assert.Nil(t, err)
+		assert.False(t, result.Response.Allowed)
+
+		missing := defaultFixture()
+		missing.ObjectMeta.Name = "missing"
+		result, err = c.deletionValidationHandler(newReview(missing, false))
+		assert.Nil(t, err)
+		assert.True(t, result.Response.Allowed)
+	})
 }
 
 func TestControllerRun(t *testing.T) {
@@ -354,6 +904,55 @@ func TestControllerRun(t *testing.T) {
 	assert.Equal(t, expected, f())
 }
 
+func TestControllerResumeSidecarRollouts(t *testing.T) {
+	t.Parallel()
+
+	annotated := func(gsSet *v1alpha1.GameServerSet, name, image string) v1alpha1.GameServerSet {
+		gsSet.ObjectMeta.Name = name
+		gsSet.ObjectMeta.Annotations = map[string]string{v1alpha1.GameServerSetSidecarImageAnnotation: image}
+		return *gsSet
+	}
+
+	// replacing the GameServers of its outdated GameServerSet
+	f1 := defaultFixture()
+	old := f1.GameServerSet()
+	old.Spec.Template.Spec.Ports = []v1alpha1.GameServerPort{{HostPort: 9999}}
+
+	// waiting to start replacing them
+	f2 := defaultFixture()
+	f2.ObjectMeta.Name = "fleet-2"
+	f2.ObjectMeta.UID = "5678"
+
+	// created before the rollout of sidecar images
+	f3 := defaultFixture()
+	f3.ObjectMeta.Name = "fleet-3"
+	f3.ObjectMeta.UID = "9012"
+	unannotated := f3.GameServerSet()
+	unannotated.ObjectMeta.Name = "gsSet4"
+
+	c, m := newFakeController()
+	c.sidecarRollout = newSidecarRollout("sidecar:2", 1)
+	m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &v1alpha1.FleetList{Items: []v1alpha1.Fleet{*f1, *f2, *f3}}, nil
+	})
+	m.AgonesClient.AddReactor("list", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &v1alpha1.GameServerSetList{Items: []v1alpha1.GameServerSet{
+			annotated(f1.GameServerSet(), "gsSet1", "sidecar:2"),
+			annotated(old, "gsSet2", "sidecar:1"),
+			annotated(f2.GameServerSet(), "gsSet3", "sidecar:1"),
+			*unannotated,
+		}}, nil
+	})
+
+	_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.gameServerSetSynced)
+	defer cancel()
+
+	err := c.resumeSidecarRollouts()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"default/fleet-1": true}, c.sidecarRollout.fleets)
+	assert.False(t, c.sidecarRollout.start("default/fleet-2"))
+}
+
 func TestControllerUpdateFleetStatus(t *testing.T) {
 	t.Parallel()
 
@@ -362,18 +961,26 @@ func TestControllerUpdateFleetStatus(t *testing.T) {
 
 	gsSet1 := fleet.GameServerSet()
 	gsSet1.ObjectMeta.Name = "gsSet1"
+	gsSet1.ObjectMeta.Annotations = map[string]string{v1alpha1.FleetRevisionAnnotation: "2"}
 	gsSet1.Status.Replicas = 3
 	gsSet1.Status.ReadyReplicas = 2
 	gsSet1.Status.ReservedReplicas = 4
 	gsSet1.Status.AllocatedReplicas = 1
+	gsSet1.Status.Counters = map[string]v1alpha1.AggregatedCounterStatus{"rooms": {AllocatedCount: 1, AllocatedCapacity: 2, Count: 3, Capacity: 6}}
+	gsSet1.Status.Players = &v1alpha1.AggregatedPlayerStatus{Count: 4, Capacity: 30}
 
 	gsSet2 := fleet.GameServerSet()
 	// nolint:goconst
 	gsSet2.ObjectMeta.Name = "gsSet2"
+	gsSet2.ObjectMeta.Annotations = map[string]string{v1alpha1.FleetRevisionAnnotation: "1"}
+	gsSet2.Spec.Template.Spec.Ports = []v1alpha1.GameServerPort{{HostPort: 7777}}
 	gsSet2.Status.Replicas = 5
 	gsSet2.Status.ReadyReplicas = 5
 	gsSet2.Status.ReservedReplicas = 3
 	gsSet2.Status.AllocatedReplicas = 2
+	gsSet2.Status.Counters = map[string]v1alpha1.AggregatedCounterStatus{"rooms": {AllocatedCount: 2, AllocatedCapacity: 4, Count: 2, Capacity: 10}}
+	gsSet2.Status.Lists = map[string]v1alpha1.AggregatedListStatus{"players": {Count: 1, Capacity: 5}}
+	gsSet2.Status.Players = &v1alpha1.AggregatedPlayerStatus{Count: 7, Capacity: 50}
 
 	m.AgonesClient.AddReactor("list", "gameserversets",
 		func(action k8stesting.Action) (bool, runtime.Object, error) {
@@ -391,6 +998,14 @@ func TestControllerUpdateFleetStatus(t *testing.T) {
 			assert.Equal(t, gsSet1.Status.ReadyReplicas+gsSet2.Status.ReadyReplicas, fleet.Status.ReadyReplicas)
 			assert.Equal(t, gsSet1.Status.ReservedReplicas+gsSet2.Status.ReservedReplicas, fleet.Status.ReservedReplicas)
 			assert.Equal(t, gsSet1.Status.AllocatedReplicas+gsSet2.Status.AllocatedReplicas, fleet.Status.AllocatedReplicas)
+			assert.Equal(t, gsSet1.Status.Replicas, fleet.Status.UpdatedReplicas)
+			assert.Equal(t, map[string]v1alpha1.AggregatedCounterStatus{
+				"rooms": {AllocatedCount: 3, AllocatedCapacity: 6, Count: 5, Capacity: 16},
+			}, fleet.Status.Counters)
+			assert.Equal(t, map[string]v1alpha1.AggregatedListStatus{"players": {Count: 1, Capacity: 5}}, fleet.Status.Lists)
+			assert.Equal(t, &v1alpha1.AggregatedPlayerStatus{Count: 11, Capacity: 80}, fleet.Status.Players)
+			assert.Equal(t, int64(2), fleet.Status.Revision)
+			assert.Equal(t, []v1alpha1.FleetRevision{{Revision: 1, Template: gsSet2.Spec.Template}}, fleet.Status.RevisionHistory)
 			return true, fleet, nil
 		})
 
@@ -402,6 +1017,277 @@ func TestControllerUpdateFleetStatus(t *testing.T) {
 	assert.True(t, updated)
 }
 
+func TestControllerUpdateFleetConditions(t *testing.T) {
+	t.Parallel()
+
+	fixture := func() (*v1alpha1.Fleet, *v1alpha1.GameServerSet) {
+		f := defaultFixture()
+		f.Spec.Replicas = 5
+		active := f.GameServerSet()
+		active.ObjectMeta.Name = "active"
+		active.Status.Replicas = 5
+		active.Status.ReadyReplicas = 5
+		f.Status.Replicas = 5
+		f.Status.ReadyReplicas = 5
+		f.Status.UpdatedReplicas = 5
+		return f, active
+	}
+
+	t.Run("rollout complete", func(t *testing.T) {
+		c, _ := newFakeController()
+		f, active := fixture()
+		c.updateFleetConditions(f, f.Status.DeepCopy(), active, []*v1alpha1.GameServerSet{active})
+
+		cond := f.Status.Condition(v1alpha1.FleetProgressing)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionTrue, cond.Status)
+			assert.Equal(t, progressingReasonAvailable, cond.Reason)
+		}
+		assert.Nil(t, f.Status.Condition(v1alpha1.FleetReplicaFailure))
+	})
+
+	t.Run("rollout progressing", func(t *testing.T) {
+		c, _ := newFakeController()
+		f, active := fixture()
+		previous := f.Status.DeepCopy()
+		previous.UpdatedReplicas = 2
+		f.Status.UpdatedReplicas = 3
+		active.Status.Replicas = 3
+		f.Status.SetCondition(v1alpha1.FleetProgressing, corev1.ConditionTrue, progressingReasonUpdated, "Fleet is progressing")
+		f.Status.Condition(v1alpha1.FleetProgressing).LastUpdateTime = metav1.NewTime(time.Now().Add(-time.Hour))
+		c.updateFleetConditions(f, previous, active, []*v1alpha1.GameServerSet{active})
+
+		cond := f.Status.Condition(v1alpha1.FleetProgressing)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionTrue, cond.Status)
+			assert.Equal(t, progressingReasonUpdated, cond.Reason)
+			assert.WithinDuration(t, time.Now(), cond.LastUpdateTime.Time, time.Minute)
+		}
+	})
+
+	t.Run("rollout stalled", func(t *testing.T) {
+		c, m := newFakeController()
+		f, active := fixture()
+		f.Status.UpdatedReplicas = 3
+		active.Status.Replicas = 3
+		active.Status.ReadyReplicas = 0
+		f.Status.SetCondition(v1alpha1.FleetProgressing, corev1.ConditionTrue, progressingReasonUpdated, "Fleet is progressing")
+		f.Status.Condition(v1alpha1.FleetProgressing).LastUpdateTime = metav1.NewTime(time.Now().Add(-time.Hour))
+		c.updateFleetConditions(f, f.Status.DeepCopy(), active, []*v1alpha1.GameServerSet{active})
+
+		cond := f.Status.Condition(v1alpha1.FleetProgressing)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionFalse, cond.Status)
+			assert.Equal(t, progressingReasonDeadlineExceeded, cond.Reason)
+		}
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, progressingReasonDeadlineExceeded)
+	})
+
+	t.Run("rollout paused", func(t *testing.T) {
+		c, _ := newFakeController()
+		f, active := fixture()
+		f.Spec.Paused = true
+		f.Status.UpdatedReplicas = 3
+		active.Status.Replicas = 3
+		c.updateFleetConditions(f, f.Status.DeepCopy(), active, []*v1alpha1.GameServerSet{active})
+
+		cond := f.Status.Condition(v1alpha1.FleetProgressing)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionUnknown, cond.Status)
+			assert.Equal(t, progressingReasonPaused, cond.Reason)
+		}
+	})
+
+	t.Run("replica failure", func(t *testing.T) {
+		c, _ := newFakeController()
+		f, active := fixture()
+		active.Status.CreationFailures = []v1alpha1.GameServerSetCreationFailure{{Reason: "Forbidden", Message: "exceeded quota", Count: 1}}
+		c.updateFleetConditions(f, f.Status.DeepCopy(), active, []*v1alpha1.GameServerSet{active})
+
+		cond := f.Status.Condition(v1alpha1.FleetReplicaFailure)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionTrue, cond.Status)
+			assert.Equal(t, "FailedCreate", cond.Reason)
+			assert.Equal(t, "GameServerSet active: exceeded quota", cond.Message)
+		}
+
+		active.Status.CreationFailures = nil
+		c.updateFleetConditions(f, f.Status.DeepCopy(), active, []*v1alpha1.GameServerSet{active})
+		assert.Nil(t, f.Status.Condition(v1alpha1.FleetReplicaFailure))
+	})
+}
+
+func TestRevisionHistory(t *testing.T) {
+	t.Parallel()
+
+	template := func(port int32) v1alpha1.GameServerTemplateSpec {
+		tmpl := defaultFixture().Spec.Template
+		tmpl.Spec.Ports = []v1alpha1.GameServerPort{{HostPort: port}}
+		return tmpl
+	}
+	gsSet := func(revision string, port int32) *v1alpha1.GameServerSet {
+		return &v1alpha1.GameServerSet{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{v1alpha1.FleetRevisionAnnotation: revision}},
+			Spec:       v1alpha1.GameServerSetSpec{Template: template(port)},
+		}
+	}
+
+	f := defaultFixture()
+	f.Spec.Template = template(5)
+	f.Status.Revision = 5
+	f.Status.RevisionHistory = []v1alpha1.FleetRevision{
+		{Revision: 3, Template: template(3)},
+		{Revision: 2, Template: template(2)},
+		{Revision: 1, Template: template(5)},
+	}
+	rest := []*v1alpha1.GameServerSet{gsSet("4", 4), gsSet("", 9)}
+
+	history := revisionHistory(f, rest)
+	assert.Equal(t, []v1alpha1.FleetRevision{
+		{Revision: 4, Template: template(4)},
+		{Revision: 3, Template: template(3)},
+		{Revision: 2, Template: template(2)},
+	}, history)
+
+	limit := int32(2)
+	f.Spec.RevisionHistoryLimit = &limit
+	history = revisionHistory(f, rest)
+	assert.Equal(t, []v1alpha1.FleetRevision{
+		{Revision: 4, Template: template(4)},
+		{Revision: 3, Template: template(3)},
+	}, history)
+
+	assert.Equal(t, int64(5), latestRevision(f, rest))
+	assert.Equal(t, int64(7), latestRevision(f, append(rest, gsSet("7", 7))))
+}
+
+func TestControllerUpdateAllocationOverflow(t *testing.T) {
+	t.Parallel()
+
+	f := defaultFixture()
+	f.Spec.AllocationOverflow = &v1alpha1.AllocationOverflow{Labels: map[string]string{"version": "outdated"}}
+	c, m := newFakeController()
+
+	outdated := f.GameServerSet()
+	outdated.ObjectMeta.Name = "outdated"
+	current := f.GameServerSet()
+	current.ObjectMeta.Name = "current"
+	current.Spec.AllocationOverflow = f.Spec.AllocationOverflow.DeepCopy()
+
+	var updated []string
+	m.AgonesClient.AddReactor("update", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gsSet := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.GameServerSet)
+		assert.Equal(t, f.Spec.AllocationOverflow, gsSet.Spec.AllocationOverflow)
+		gsSet.ObjectMeta.ResourceVersion = "2"
+		updated = append(updated, gsSet.ObjectMeta.Name)
+		return true, gsSet, nil
+	})
+
+	rest := []*v1alpha1.GameServerSet{outdated, current}
+	assert.Nil(t, c.updateAllocationOverflow(f, rest))
+	assert.Equal(t, []string{"outdated"}, updated)
+	assert.Equal(t, "2", rest[0].ObjectMeta.ResourceVersion)
+	assert.Equal(t, current, rest[1])
+
+	// removing it from the Fleet removes it from the GameServerSets
+	f.Spec.AllocationOverflow = nil
+	updated = nil
+	assert.Nil(t, c.updateAllocationOverflow(f, rest))
+	assert.Equal(t, []string{"outdated", "current"}, updated)
+}
+
+func TestControllerUpsertCanaryGameServerSet(t *testing.T) {
+	t.Parallel()
+
+	fixture := func() *v1alpha1.Fleet {
+		f := defaultFixture()
+		f.Spec.Replicas = 10
+		f.Spec.Canary = &v1alpha1.FleetCanary{Weight: 25, Template: *f.Spec.Template.DeepCopy()}
+		return f
+	}
+
+	t.Run("no canary", func(t *testing.T) {
+		c, m := newFakeController()
+		f := defaultFixture()
+		m.AgonesClient.AddReactor("create", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "gameserverset should not be created")
+			return true, nil, nil
+		})
+		assert.Nil(t, c.upsertCanaryGameServerSet(f, nil))
+	})
+
+	t.Run("paused fleet doesn't create it", func(t *testing.T) {
+		c, m := newFakeController()
+		f := fixture()
+		f.Spec.Paused = true
+		m.AgonesClient.AddReactor("create", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "gameserverset should not be created")
+			return true, nil, nil
+		})
+		assert.Nil(t, c.upsertCanaryGameServerSet(f, nil))
+	})
+
+	t.Run("scale", func(t *testing.T) {
+		c, m := newFakeController()
+		f := fixture()
+		canary := f.CanaryGameServerSet()
+		canary.ObjectMeta.Name = "canary"
+		canary.Spec.Replicas = 1
+		updated := false
+		m.AgonesClient.AddReactor("update", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			updated = true
+			gsSet := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.GameServerSet)
+			assert.Equal(t, int32(3), gsSet.Spec.Replicas)
+			return true, gsSet, nil
+		})
+		assert.Nil(t, c.upsertCanaryGameServerSet(f, canary))
+		assert.True(t, updated)
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Scaling canary GameServerSet canary from 1 to 3")
+	})
+
+	t.Run("noop", func(t *testing.T) {
+		c, m := newFakeController()
+		f := fixture()
+		canary := f.CanaryGameServerSet()
+		canary.Spec.Replicas = 3
+		m.AgonesClient.AddReactor("update", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "gameserverset should not be updated")
+			return true, nil, nil
+		})
+		assert.Nil(t, c.upsertCanaryGameServerSet(f, canary))
+		agtesting.AssertNoEvent(t, m.FakeRecorder.Events)
+	})
+}
+
+func TestFilterGameServerSetByCanary(t *testing.T) {
+	t.Parallel()
+
+	f := defaultFixture()
+	f.Spec.Canary = &v1alpha1.FleetCanary{Weight: 10, Template: *f.Spec.Template.DeepCopy()}
+	f.Spec.Canary.Template.Spec.Ports = []v1alpha1.GameServerPort{{ContainerPort: 7777}}
+
+	gsSet := f.GameServerSet()
+	gsSet.ObjectMeta.Name = "gsSet"
+	canary := f.CanaryGameServerSet()
+	canary.ObjectMeta.Name = "canary"
+	previous := f.CanaryGameServerSet()
+	previous.ObjectMeta.Name = "previous"
+	previous.Spec.Template.Spec.Ports = []v1alpha1.GameServerPort{{ContainerPort: 7000}}
+	// same template as the canary, but not a canary GameServerSet
+	other := f.GameServerSet()
+	other.ObjectMeta.Name = "other"
+	other.Spec.Template = f.Spec.Canary.Template
+
+	c, rest := filterGameServerSetByCanary(f, []*v1alpha1.GameServerSet{gsSet, previous, canary, other})
+	assert.Equal(t, canary, c)
+	assert.Equal(t, []*v1alpha1.GameServerSet{gsSet, previous, other}, rest)
+
+	f.Spec.Canary = nil
+	c, rest = filterGameServerSetByCanary(f, []*v1alpha1.GameServerSet{gsSet, canary})
+	assert.Nil(t, c)
+	assert.Equal(t, []*v1alpha1.GameServerSet{gsSet, canary}, rest)
+}
+
 func TestControllerFilterGameServerSetByActive(t *testing.T) {
 	t.Parallel()
 
@@ -425,6 +1311,13 @@ func TestControllerFilterGameServerSetByActive(t *testing.T) {
 	active, rest = c.filterGameServerSetByActive(f, []*v1alpha1.GameServerSet{gsSet1, gsSet2})
 	assert.Nil(t, active)
 	assert.Equal(t, []*v1alpha1.GameServerSet{gsSet1, gsSet2}, rest)
+
+	// different port range
+	gsSet3 := f.GameServerSet()
+	f.Spec.PortRange = "premium"
+	active, rest = c.filterGameServerSetByActive(f, []*v1alpha1.GameServerSet{gsSet3})
+	assert.Nil(t, active)
+	assert.Equal(t, []*v1alpha1.GameServerSet{gsSet3}, rest)
 }
 
 func TestControllerRecreateDeployment(t *testing.T) {
@@ -485,7 +1378,7 @@ func TestControllerApplyDeploymentStrategy(t *testing.T) {
 			},
 		},
 		string(appsv1.RollingUpdateDeploymentStrategyType): {
-			strategyType:         appsv1.RecreateDeploymentStrategyType,
+			strategyType:         appsv1.RollingUpdateDeploymentStrategyType,
 			gsSet1StatusReplicas: 10,
 			gsSet2StatusReplicas: 1,
 			expected: expected{
@@ -519,7 +1412,7 @@ func TestControllerApplyDeploymentStrategy(t *testing.T) {
 				ua := action.(k8stesting.UpdateAction)
 				gsSet := ua.GetObject().(*v1alpha1.GameServerSet)
 				assert.Equal(t, gsSet1.ObjectMeta.Name, gsSet.ObjectMeta.Name)
-				assert.Equal(t, int32(0), gsSet.Spec.Replicas)
+				assert.Equal(t, v.expected.inactiveReplicas, gsSet.Spec.Replicas)
 
 				return true, gsSet, nil
 			})
@@ -527,7 +1420,7 @@ func TestControllerApplyDeploymentStrategy(t *testing.T) {
 			replicas, err := c.applyDeploymentStrategy(f, f.GameServerSet(), []*v1alpha1.GameServerSet{gsSet1, gsSet2})
 			assert.Nil(t, err)
 			assert.True(t, updated, "update should happen")
-			assert.Equal(t, f.Spec.Replicas, replicas)
+			assert.Equal(t, v.expected.replicas, replicas)
 		})
 	}
 
@@ -544,6 +1437,56 @@ func TestControllerApplyDeploymentStrategy(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, f.Spec.Replicas, replicas)
 	})
+
+	t.Run("outside of update windows", func(t *testing.T) {
+		f := defaultFixture()
+		f.Spec.Replicas = 12
+		// a one minute window that opens in a few hours
+		next := time.Now().UTC().Add(3 * time.Hour)
+		f.Spec.UpdateWindows = []v1alpha1.UpdateWindow{
+			{Schedule: fmt.Sprintf("0 %d * * *", next.Hour()), Duration: metav1.Duration{Duration: time.Minute}}}
+
+		gsSet1 := f.GameServerSet()
+		gsSet1.ObjectMeta.Name = "gsSet1"
+		gsSet1.Spec.Replicas = 10
+		gsSet1.Status.Replicas = 10
+
+		c, m := newFakeController()
+		m.AgonesClient.AddReactor("update", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "inactive gameserverset should not be updated")
+			return true, nil, nil
+		})
+
+		replicas, err := c.applyDeploymentStrategy(f, f.GameServerSet(), []*v1alpha1.GameServerSet{gsSet1})
+		assert.Nil(t, err)
+		assert.Equal(t, int32(2), replicas)
+
+		f.Spec.Replicas = 5
+		replicas, err = c.applyDeploymentStrategy(f, f.GameServerSet(), []*v1alpha1.GameServerSet{gsSet1})
+		assert.Nil(t, err)
+		assert.Equal(t, int32(0), replicas)
+	})
+
+	t.Run("paused", func(t *testing.T) {
+		f := defaultFixture()
+		f.Spec.Replicas = 12
+		f.Spec.Paused = true
+
+		gsSet1 := f.GameServerSet()
+		gsSet1.ObjectMeta.Name = "gsSet1"
+		gsSet1.Spec.Replicas = 10
+		gsSet1.Status.Replicas = 10
+
+		c, m := newFakeController()
+		m.AgonesClient.AddReactor("update", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "inactive gameserverset should not be updated")
+			return true, nil, nil
+		})
+
+		replicas, err := c.applyDeploymentStrategy(f, f.GameServerSet(), []*v1alpha1.GameServerSet{gsSet1})
+		assert.Nil(t, err)
+		assert.Equal(t, int32(2), replicas)
+	})
 }
 
 func TestControllerUpsertGameServerSet(t *testing.T) {
@@ -560,11 +1503,12 @@ func TestControllerUpsertGameServerSet(t *testing.T) {
 			ca := action.(k8stesting.CreateAction)
 			gsSet := ca.GetObject().(*v1alpha1.GameServerSet)
 			assert.Equal(t, replicas, gsSet.Spec.Replicas)
+			assert.Equal(t, int64(3), gsSet.Revision())
 
 			return true, gsSet, nil
 		})
 
-		err := c.upsertGameServerSet(f, gsSet, replicas)
+		err := c.upsertGameServerSet(f, gsSet, replicas, 3)
 		assert.Nil(t, err)
 
 		assert.True(t, created, "Should be created")
@@ -587,7 +1531,7 @@ func TestControllerUpsertGameServerSet(t *testing.T) {
 			return true, gsSet, nil
 		})
 
-		err := c.upsertGameServerSet(f, gsSet, replicas)
+		err := c.upsertGameServerSet(f, gsSet, replicas, 0)
 		assert.Nil(t, err)
 
 		assert.True(t, update, "Should be update")
@@ -611,10 +1555,32 @@ func TestControllerUpsertGameServerSet(t *testing.T) {
 			return false, nil, nil
 		})
 
-		err := c.upsertGameServerSet(f, gsSet, replicas)
+		err := c.upsertGameServerSet(f, gsSet, replicas, 0)
 		assert.Nil(t, err)
 		agtesting.AssertNoEvent(t, m.FakeRecorder.Events)
 	})
+
+	t.Run("new revision", func(t *testing.T) {
+		c, m := newFakeController()
+		gsSet := f.GameServerSet()
+		gsSet.ObjectMeta.UID = "1234"
+		gsSet.ObjectMeta.Annotations = map[string]string{v1alpha1.FleetRevisionAnnotation: "2"}
+		gsSet.Spec.Replicas = replicas
+		update := false
+
+		m.AgonesClient.AddReactor("update", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			update = true
+			gsSet := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.GameServerSet)
+			assert.Equal(t, replicas, gsSet.Spec.Replicas)
+			assert.Equal(t, int64(5), gsSet.Revision())
+
+			return true, gsSet, nil
+		})
+
+		err := c.upsertGameServerSet(f, gsSet, replicas, 5)
+		assert.Nil(t, err)
+		assert.True(t, update, "Should be update")
+	})
 }
 
 func TestControllerDeleteEmptyGameServerSets(t *testing.T) {
@@ -781,10 +1747,94 @@ func TestControllerRollingUpdateDeployment(t *testing.T) {
 }
 
 // newFakeController returns a controller, backed by the fake Clientset
+func TestControllerUpsertPodDisruptionBudget(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no PodDisruptionBudget", func(t *testing.T) {
+		f := defaultFixture()
+		c, m := newFakeController()
+		created := false
+
+		m.KubeClient.AddReactor("create", "poddisruptionbudgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			created = true
+			pdb := action.(k8stesting.CreateAction).GetObject().(*policyv1beta1.PodDisruptionBudget)
+			assert.Equal(t, f.ObjectMeta.Name, pdb.ObjectMeta.Name)
+			assert.True(t, metav1.IsControlledBy(pdb, f))
+			assert.Equal(t, v1alpha1.DefaultDisruptionBudgetMinAvailable, *pdb.Spec.MinAvailable)
+			assert.Equal(t, map[string]string{v1alpha1.FleetNameLabel: f.ObjectMeta.Name, v1alpha1.PodAllocatedLabel: "true"},
+				pdb.Spec.Selector.MatchLabels)
+			return true, pdb, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.pdbSynced)
+		defer cancel()
+
+		err := c.upsertPodDisruptionBudget(f)
+		assert.Nil(t, err)
+		assert.True(t, created)
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "CreatingPodDisruptionBudget")
+	})
+
+	t.Run("changed minAvailable", func(t *testing.T) {
+		f := defaultFixture()
+		c, m := newFakeController()
+		pdb := f.PodDisruptionBudget()
+		minAvailable := intstr.FromInt(2)
+		f.Spec.DisruptionBudget = &v1alpha1.FleetDisruptionBudget{MinAvailable: &minAvailable}
+		deleted := false
+		created := false
+
+		m.KubeClient.AddReactor("list", "poddisruptionbudgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &policyv1beta1.PodDisruptionBudgetList{Items: []policyv1beta1.PodDisruptionBudget{*pdb}}, nil
+		})
+		m.KubeClient.AddReactor("delete", "poddisruptionbudgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			deleted = true
+			assert.Equal(t, pdb.ObjectMeta.Name, action.(k8stesting.DeleteAction).GetName())
+			return true, nil, nil
+		})
+		m.KubeClient.AddReactor("create", "poddisruptionbudgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.True(t, deleted, "should delete before creating")
+			created = true
+			pdb := action.(k8stesting.CreateAction).GetObject().(*policyv1beta1.PodDisruptionBudget)
+			assert.Equal(t, minAvailable, *pdb.Spec.MinAvailable)
+			return true, pdb, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.pdbSynced)
+		defer cancel()
+
+		err := c.upsertPodDisruptionBudget(f)
+		assert.Nil(t, err)
+		assert.True(t, created)
+	})
+
+	t.Run("not controlled by the Fleet", func(t *testing.T) {
+		f := defaultFixture()
+		c, m := newFakeController()
+		pdb := f.PodDisruptionBudget()
+		pdb.ObjectMeta.OwnerReferences = nil
+
+		m.KubeClient.AddReactor("list", "poddisruptionbudgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &policyv1beta1.PodDisruptionBudgetList{Items: []policyv1beta1.PodDisruptionBudget{*pdb}}, nil
+		})
+		m.KubeClient.AddReactor("create", "poddisruptionbudgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "PodDisruptionBudget should not be created")
+			return true, nil, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.pdbSynced)
+		defer cancel()
+
+		err := c.upsertPodDisruptionBudget(f)
+		assert.Nil(t, err)
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "PodDisruptionBudgetExists")
+	})
+}
+
 func newFakeController() (*Controller, agtesting.Mocks) {
 	m := agtesting.NewMocks()
 	wh := webhooks.NewWebHook(http.NewServeMux())
-	c := NewController(wh, healthcheck.NewHandler(), m.KubeClient, m.ExtClient, m.AgonesClient, m.AgonesInformerFactory)
+	c := NewController(wh, apiserver.NewAPIServer(http.NewServeMux()), healthcheck.NewHandler(), time.Minute, false, corev1.ResourceRequirements{}, "sidecar:1", 0, m.KubeClient, m.ExtClient, m.AgonesClient, m.KubeInformerFactory, m.AgonesInformerFactory)
 	c.recorder = m.FakeRecorder
 	return c, m
 }
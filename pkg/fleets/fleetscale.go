@@ -0,0 +1,252 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	stablev1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"agones.dev/agones/pkg/util/apiserver"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// ScalingGroupName is the API group that FleetScales are served under
+	ScalingGroupName = "scaling.agones.dev"
+	// FleetScaleKind is the kind of a FleetScale
+	FleetScaleKind = "FleetScale"
+)
+
+// ScalingGroupVersion is the group version that FleetScales are served under
+var ScalingGroupVersion = schema.GroupVersion{Group: ScalingGroupName, Version: "v1"}
+
+// FleetScale scales all of the Fleets in a namespace that match its selector to the same
+// number of replicas in one operation. Either all of the Fleets are scaled, or none are.
+type FleetScale struct {
+	metav1.TypeMeta `json:",inline"`
+	Spec            FleetScaleSpec   `json:"spec"`
+	Status          FleetScaleStatus `json:"status,omitempty"`
+}
+
+// FleetScaleSpec is the Fleets to scale, and what to scale them to
+type FleetScaleSpec struct {
+	// Selector selects the Fleets to scale by their labels. It must not be empty.
+	Selector metav1.LabelSelector `json:"selector"`
+	// Replicas is the number of replicas to scale each of the Fleets to
+	Replicas int32 `json:"replicas"`
+}
+
+// FleetScaleStatus is the Fleets that were scaled
+type FleetScaleStatus struct {
+	Fleets []ScaledFleet `json:"fleets,omitempty"`
+}
+
+// ScaledFleet is a Fleet that was scaled by a FleetScale
+type ScaledFleet struct {
+	Name             string `json:"name"`
+	PreviousReplicas int32  `json:"previousReplicas"`
+	Replicas         int32  `json:"replicas"`
+}
+
+// registerAPIResource registers the api resource for FleetScales
+func (c *Controller) registerAPIResource(api *apiserver.APIServer) {
+	resource := metav1.APIResource{
+		Name:         "fleetscales",
+		SingularName: "fleetscale",
+		Namespaced:   true,
+		Kind:         FleetScaleKind,
+		Verbs: []string{
+			"create",
+		},
+	}
+	api.AddAPIResource(ScalingGroupVersion.String(), resource, c.fleetScaleHandler, apiserver.AllowMethods(http.MethodPost))
+}
+
+// fleetScaleHandler scales the Fleets selected by the FleetScale that is posted to it
+func (c *Controller) fleetScaleHandler(w http.ResponseWriter, r *http.Request, namespace string) error {
+	if r.Body != nil {
+		defer r.Body.Close() // nolint: errcheck
+	}
+
+	fs := &FleetScale{}
+	if err := json.NewDecoder(r.Body).Decode(fs); err != nil {
+		http.Error(w, fmt.Sprintf("could not decode FleetScale: %s", err), http.StatusBadRequest)
+		return nil
+	}
+
+	fleets, causes, err := c.fleetsToScale(namespace, fs)
+	if err != nil {
+		return err
+	}
+	if len(causes) > 0 {
+		status := &metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusFailure,
+			Message:  "FleetScale is invalid, so no Fleets were scaled",
+			Reason:   metav1.StatusReasonInvalid,
+			Details: &metav1.StatusDetails{
+				Kind:   FleetScaleKind,
+				Group:  ScalingGroupName,
+				Causes: causes,
+			},
+			Code: http.StatusUnprocessableEntity,
+		}
+		w.Header().Set(apiserver.ContentTypeHeader, k8sruntime.ContentTypeJSON)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return errors.Wrap(json.NewEncoder(w).Encode(status), "error encoding status")
+	}
+
+	if err := c.scaleFleets(fleets, fs); err != nil {
+		return err
+	}
+
+	fs.TypeMeta = metav1.TypeMeta{Kind: FleetScaleKind, APIVersion: ScalingGroupVersion.String()}
+	w.Header().Set(apiserver.ContentTypeHeader, k8sruntime.ContentTypeJSON)
+	return errors.Wrap(json.NewEncoder(w).Encode(fs), "error encoding FleetScale")
+}
+
+// fleetsToScale returns the Fleets in the namespace that the FleetScale selects, sorted by name,
+// and the reasons it can't scale them, if any of them can't be scaled
+func (c *Controller) fleetsToScale(namespace string, fs *FleetScale) ([]*stablev1alpha1.Fleet, []metav1.StatusCause, error) {
+	var causes []metav1.StatusCause
+	if fs.Spec.Replicas < 0 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "spec.replicas",
+			Message: "replicas must not be negative",
+		})
+	}
+	if len(fs.Spec.Selector.MatchLabels) == 0 && len(fs.Spec.Selector.MatchExpressions) == 0 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueRequired,
+			Field:   "spec.selector",
+			Message: "selector must not be empty",
+		})
+		return nil, causes, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&fs.Spec.Selector)
+	if err != nil {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "spec.selector",
+			Message: err.Error(),
+		})
+		return nil, causes, nil
+	}
+
+	fleets, err := c.fleetLister.Fleets(namespace).List(selector)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error listing fleets in namespace %s", namespace)
+	}
+	if len(fleets) == 0 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueNotFound,
+			Field:   "spec.selector",
+			Message: fmt.Sprintf("no Fleets in namespace %s match the selector", namespace),
+		})
+		return nil, causes, nil
+	}
+	sort.Slice(fleets, func(i, j int) bool { return fleets[i].ObjectMeta.Name < fleets[j].ObjectMeta.Name })
+
+	fasList, err := c.fleetAutoscalerLister.FleetAutoscalers(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error listing fleetautoscalers in namespace %s", namespace)
+	}
+	autoscaled := map[string]string{}
+	for _, fas := range fasList {
+		autoscaled[fas.Spec.FleetName] = fas.ObjectMeta.Name
+	}
+
+	for _, f := range fleets {
+		if !f.ObjectMeta.DeletionTimestamp.IsZero() {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   f.ObjectMeta.Name,
+				Message: "Fleet is being deleted",
+			})
+		}
+		if fas, ok := autoscaled[f.ObjectMeta.Name]; ok {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   f.ObjectMeta.Name,
+				Message: fmt.Sprintf("Fleet is scaled by FleetAutoscaler %s", fas),
+			})
+		}
+	}
+
+	return fleets, causes, nil
+}
+
+// scaleFleets scales each of the Fleets to the replicas of the FleetScale, and records them in its status.
+// If any of them can't be scaled, the Fleets that were already scaled are scaled back to their previous replicas.
+func (c *Controller) scaleFleets(fleets []*stablev1alpha1.Fleet, fs *FleetScale) error {
+	fs.Status.Fleets = nil
+	for _, f := range fleets {
+		previous, err := c.scaleFleet(f.ObjectMeta.Namespace, f.ObjectMeta.Name, fs.Spec.Replicas)
+		if err != nil {
+			err = errors.Wrapf(err, "error scaling fleet %s, so no Fleets were scaled", f.ObjectMeta.Name)
+
+			var failed []string
+			for _, scaled := range fs.Status.Fleets {
+				if _, rerr := c.scaleFleet(f.ObjectMeta.Namespace, scaled.Name, scaled.PreviousReplicas); rerr != nil {
+					c.loggerForFleet(f).WithError(rerr).WithField("fleet", scaled.Name).Error("could not scale fleet back to its previous replicas")
+					failed = append(failed, scaled.Name)
+				}
+			}
+			if len(failed) > 0 {
+				err = errors.Wrapf(err, "could not scale fleets %s back to their previous replicas", strings.Join(failed, ", "))
+			}
+			fs.Status.Fleets = nil
+			return err
+		}
+
+		fs.Status.Fleets = append(fs.Status.Fleets, ScaledFleet{
+			Name:             f.ObjectMeta.Name,
+			PreviousReplicas: previous,
+			Replicas:         fs.Spec.Replicas,
+		})
+	}
+
+	for i, scaled := range fs.Status.Fleets {
+		c.recorder.Eventf(fleets[i], corev1.EventTypeNormal, "ScalingFleet",
+			"Scaling Fleet from %d to %d replicas with a FleetScale", scaled.PreviousReplicas, scaled.Replicas)
+	}
+
+	return nil
+}
+
+// scaleFleet updates the replicas of the latest version of the Fleet, and returns its previous replicas
+func (c *Controller) scaleFleet(namespace, name string, replicas int32) (int32, error) {
+	f, err := c.fleetGetter.Fleets(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	if f.Spec.Replicas == replicas {
+		return replicas, nil
+	}
+	fCopy := f.DeepCopy()
+	fCopy.Spec.Replicas = replicas
+	_, err = c.fleetGetter.Fleets(namespace).Update(fCopy)
+	return f.Spec.Replicas, err
+}
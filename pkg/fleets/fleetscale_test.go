@@ -0,0 +1,198 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleets
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	agtesting "agones.dev/agones/pkg/testing"
+	"agones.dev/agones/pkg/util/apiserver"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFleetScaleFixtures returns Fleets in three regions, all for the same tournament
+func newFleetScaleFixtures() []v1alpha1.Fleet {
+	var fleets []v1alpha1.Fleet
+	for _, region := range []string{"us", "eu", "asia"} {
+		f := defaultFixture()
+		f.ObjectMeta.Name = "tournament-" + region
+		f.ObjectMeta.Labels = map[string]string{"tournament": "finals", "region": region}
+		fleets = append(fleets, *f)
+	}
+	return fleets
+}
+
+// postFleetScale posts the FleetScale to the fleet scale handler of the controller
+func postFleetScale(t *testing.T, c *Controller, fs *FleetScale) *httptest.ResponseRecorder {
+	b, err := json.Marshal(fs)
+	require.NoError(t, err)
+	r, err := http.NewRequest(http.MethodPost, "/apis/scaling.agones.dev/v1/namespaces/default/fleetscales", bytes.NewReader(b))
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	require.NoError(t, c.fleetScaleHandler(rec, r, "default"))
+	return rec
+}
+
+func TestControllerFleetScaleHandler(t *testing.T) {
+	t.Parallel()
+
+	fleets := newFleetScaleFixtures()
+	c, m := newFakeController()
+	m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &v1alpha1.FleetList{Items: fleets}, nil
+	})
+	m.AgonesClient.AddReactor("get", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.GetAction).GetName()
+		for i := range fleets {
+			if fleets[i].ObjectMeta.Name == name {
+				return true, fleets[i].DeepCopy(), nil
+			}
+		}
+		return false, nil, nil
+	})
+	updated := map[string]int32{}
+	m.AgonesClient.AddReactor("update", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		f := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.Fleet)
+		updated[f.ObjectMeta.Name] = f.Spec.Replicas
+		return true, f, nil
+	})
+	_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.fleetAutoscalerSynced)
+	defer cancel()
+
+	fs := &FleetScale{Spec: FleetScaleSpec{Selector: metav1.LabelSelector{MatchLabels: map[string]string{"tournament": "finals"}}, Replicas: 20}}
+	rec := postFleetScale(t, c, fs)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	result := &FleetScale{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(result))
+	assert.Equal(t, FleetScaleKind, result.Kind)
+	assert.Equal(t, "scaling.agones.dev/v1", result.APIVersion)
+	assert.Equal(t, []ScaledFleet{
+		{Name: "tournament-asia", PreviousReplicas: 5, Replicas: 20},
+		{Name: "tournament-eu", PreviousReplicas: 5, Replicas: 20},
+		{Name: "tournament-us", PreviousReplicas: 5, Replicas: 20},
+	}, result.Status.Fleets)
+	assert.Equal(t, map[string]int32{"tournament-asia": 20, "tournament-eu": 20, "tournament-us": 20}, updated)
+	agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Scaling Fleet from 5 to 20 replicas with a FleetScale")
+
+	// no fleets match
+	fs.Spec.Selector.MatchLabels["tournament"] = "semifinals"
+	rec = postFleetScale(t, c, fs)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "no Fleets in namespace default match the selector")
+
+	rec = postFleetScale(t, c, &FleetScale{Spec: FleetScaleSpec{Replicas: 20}})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "selector must not be empty")
+
+	mux := http.NewServeMux()
+	c.registerAPIResource(apiserver.NewAPIServer(mux))
+	r, err := http.NewRequest(http.MethodGet, "/apis/scaling.agones.dev/v1/namespaces/default/fleetscales", nil)
+	require.NoError(t, err)
+	getRec := httptest.NewRecorder()
+	mux.ServeHTTP(getRec, r)
+	assert.Equal(t, http.StatusMethodNotAllowed, getRec.Code)
+}
+
+func TestControllerFleetsToScale(t *testing.T) {
+	t.Parallel()
+
+	fleets := newFleetScaleFixtures()
+	now := metav1.Now()
+	fleets[1].ObjectMeta.DeletionTimestamp = &now
+	fas := &autoscalingv1.FleetAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: "fas", Namespace: "default"},
+		Spec: autoscalingv1.FleetAutoscalerSpec{FleetName: fleets[0].ObjectMeta.Name}}
+
+	c, m := newFakeController()
+	m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &v1alpha1.FleetList{Items: fleets}, nil
+	})
+	m.AgonesClient.AddReactor("list", "fleetautoscalers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &autoscalingv1.FleetAutoscalerList{Items: []autoscalingv1.FleetAutoscaler{*fas}}, nil
+	})
+	_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.fleetAutoscalerSynced)
+	defer cancel()
+
+	selected, causes, err := c.fleetsToScale("default", &FleetScale{Spec: FleetScaleSpec{
+		Selector: metav1.LabelSelector{MatchLabels: map[string]string{"tournament": "finals"}}, Replicas: -1}})
+	require.NoError(t, err)
+	assert.Len(t, selected, 3)
+	assert.Equal(t, []metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.replicas", Message: "replicas must not be negative"},
+		{Type: metav1.CauseTypeFieldValueInvalid, Field: "tournament-eu", Message: "Fleet is being deleted"},
+		{Type: metav1.CauseTypeFieldValueInvalid, Field: "tournament-us", Message: "Fleet is scaled by FleetAutoscaler fas"},
+	}, causes)
+
+	selected, causes, err = c.fleetsToScale("default", &FleetScale{Spec: FleetScaleSpec{
+		Selector: metav1.LabelSelector{MatchLabels: map[string]string{"region": "asia"}}, Replicas: 10}})
+	require.NoError(t, err)
+	assert.Empty(t, causes)
+	if assert.Len(t, selected, 1) {
+		assert.Equal(t, "tournament-asia", selected[0].ObjectMeta.Name)
+	}
+}
+
+func TestControllerScaleFleetsRollback(t *testing.T) {
+	t.Parallel()
+
+	fleets := newFleetScaleFixtures()
+	c, m := newFakeController()
+	current := map[string]int32{}
+	for _, f := range fleets {
+		current[f.ObjectMeta.Name] = f.Spec.Replicas
+	}
+	m.AgonesClient.AddReactor("get", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.GetAction).GetName()
+		for i := range fleets {
+			if fleets[i].ObjectMeta.Name == name {
+				f := fleets[i].DeepCopy()
+				f.Spec.Replicas = current[name]
+				return true, f, nil
+			}
+		}
+		return false, nil, nil
+	})
+	m.AgonesClient.AddReactor("update", "fleets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		f := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.Fleet)
+		if f.ObjectMeta.Name == "tournament-us" {
+			return true, nil, errors.New("update failed")
+		}
+		current[f.ObjectMeta.Name] = f.Spec.Replicas
+		return true, f, nil
+	})
+
+	var selected []*v1alpha1.Fleet
+	for i := range fleets {
+		selected = append(selected, &fleets[i])
+	}
+	fs := &FleetScale{Spec: FleetScaleSpec{Replicas: 20}}
+	err := c.scaleFleets(selected, fs)
+	assert.EqualError(t, err, "error scaling fleet tournament-us, so no Fleets were scaled: update failed")
+	assert.Empty(t, fs.Status.Fleets)
+	// the fleets that were scaled before the failure are scaled back
+	assert.Equal(t, map[string]int32{"tournament-us": 5, "tournament-eu": 5, "tournament-asia": 5}, current)
+	agtesting.AssertNoEvent(t, m.FakeRecorder.Events)
+}
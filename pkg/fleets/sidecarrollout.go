@@ -0,0 +1,111 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleets
+
+import (
+	"sync"
+	"time"
+
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+)
+
+// sidecarRolloutRetry is how long a Fleet waits before checking again if it can start
+// replacing its GameServers, while the maximum number of Fleets already are
+const sidecarRolloutRetry = 30 * time.Second
+
+// sidecarRollout coordinates the replacement of the GameServers of Fleets whose GameServerSets
+// were created with a different SDK sidecar image than the current one, so that only a limited
+// number of Fleets replace their GameServers at a time
+type sidecarRollout struct {
+	image     string
+	maxFleets int
+
+	mu sync.Mutex
+	// fleets are the keys of the Fleets that are replacing their GameServers
+	fleets map[string]bool
+}
+
+// newSidecarRollout returns a sidecarRollout of the sidecar image, which replaces the GameServers
+// of at most maxFleets Fleets at a time. It is disabled if maxFleets is 0.
+func newSidecarRollout(image string, maxFleets int) *sidecarRollout {
+	return &sidecarRollout{image: image, maxFleets: maxFleets, fleets: map[string]bool{}}
+}
+
+// enabled returns if the GameServers of Fleets are replaced when the sidecar image changes
+func (r *sidecarRollout) enabled() bool {
+	return r.maxFleets > 0
+}
+
+// outdated returns if the GameServerSet was created with a different sidecar image than the current one.
+// GameServerSets that weren't annotated with their sidecar image, as they were created by an earlier version
+// of the controller, are treated as current, until the next change of their Fleet's template replaces them.
+func (r *sidecarRollout) outdated(gsSet *v1alpha1.GameServerSet) bool {
+	image, ok := gsSet.ObjectMeta.Annotations[v1alpha1.GameServerSetSidecarImageAnnotation]
+	return ok && image != r.image
+}
+
+// replacing returns if a Fleet is replacing the GameServers of outdated GameServerSets, as its
+// active GameServerSet is current, but any of its inactive GameServerSets are outdated
+func (r *sidecarRollout) replacing(active *v1alpha1.GameServerSet, rest []*v1alpha1.GameServerSet) bool {
+	return active != nil && !r.outdated(active) && r.anyOutdated(rest)
+}
+
+// anyOutdated returns if any of the GameServerSets are outdated
+func (r *sidecarRollout) anyOutdated(list []*v1alpha1.GameServerSet) bool {
+	for _, gsSet := range list {
+		if r.outdated(gsSet) {
+			return true
+		}
+	}
+	return false
+}
+
+// start returns if the Fleet of key can start replacing its GameServers, as fewer than the maximum
+// number of Fleets are, and if so counts it as replacing them until it is finished
+func (r *sidecarRollout) start(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fleets[key] {
+		return true
+	}
+	if len(r.fleets) >= r.maxFleets {
+		return false
+	}
+	r.fleets[key] = true
+	return true
+}
+
+// resume counts the Fleet of key as replacing its GameServers, even over the maximum number of Fleets,
+// as it already was when the controller started
+func (r *sidecarRollout) resume(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fleets[key] = true
+}
+
+// track finishes the Fleet of key replacing its GameServers once none of its inactive GameServerSets are
+// outdated. It never starts counting a Fleet, so that only start can take one of the maximum number of Fleets.
+func (r *sidecarRollout) track(key string, rest []*v1alpha1.GameServerSet) {
+	if !r.anyOutdated(rest) {
+		r.finish(key)
+	}
+}
+
+// finish stops counting the Fleet of key as replacing its GameServers
+func (r *sidecarRollout) finish(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.fleets, key)
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fleets
+
+import (
+	"testing"
+
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSidecarRollout(t *testing.T) {
+	t.Parallel()
+
+	gsSet := func(image string) *v1alpha1.GameServerSet {
+		return &v1alpha1.GameServerSet{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{v1alpha1.GameServerSetSidecarImageAnnotation: image}}}
+	}
+
+	assert.False(t, newSidecarRollout("sidecar:2", 0).enabled())
+
+	r := newSidecarRollout("sidecar:2", 2)
+	assert.True(t, r.enabled())
+	assert.False(t, r.outdated(gsSet("sidecar:2")))
+	assert.True(t, r.outdated(gsSet("sidecar:1")))
+	assert.False(t, r.outdated(&v1alpha1.GameServerSet{}))
+
+	assert.True(t, r.replacing(gsSet("sidecar:2"), []*v1alpha1.GameServerSet{{}, gsSet("sidecar:1")}))
+	assert.False(t, r.replacing(gsSet("sidecar:2"), []*v1alpha1.GameServerSet{{}, gsSet("sidecar:2")}))
+	assert.False(t, r.replacing(gsSet("sidecar:1"), []*v1alpha1.GameServerSet{gsSet("sidecar:1")}))
+	assert.False(t, r.replacing(nil, []*v1alpha1.GameServerSet{gsSet("sidecar:1")}))
+
+	assert.True(t, r.start("default/a"))
+	assert.True(t, r.start("default/a"))
+	assert.True(t, r.start("default/b"))
+	assert.False(t, r.start("default/c"))
+
+	// tracking a Fleet with outdated GameServerSets doesn't start counting it
+	r.track("default/c", []*v1alpha1.GameServerSet{gsSet("sidecar:2"), gsSet("sidecar:1")})
+	assert.Len(t, r.fleets, 2)
+	assert.False(t, r.fleets["default/c"])
+
+	// a Fleet that was already replacing its GameServers is counted, even over the maximum
+	r.resume("default/c")
+	assert.Len(t, r.fleets, 3)
+	r.track("default/c", []*v1alpha1.GameServerSet{gsSet("sidecar:2"), gsSet("sidecar:1")})
+	assert.Len(t, r.fleets, 3)
+
+	r.track("default/a", []*v1alpha1.GameServerSet{gsSet("sidecar:2")})
+	r.finish("default/c")
+	assert.Len(t, r.fleets, 1)
+	assert.True(t, r.start("default/d"))
+	assert.False(t, r.start("default/e"))
+}
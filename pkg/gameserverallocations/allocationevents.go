@@ -0,0 +1,167 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserverallocations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	stablev1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"agones.dev/agones/pkg/util/apiserver"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// unknownRequester is the requester of allocation requests without an authenticated user
+	unknownRequester = "unknown"
+	// unverifiedSuffix marks the requester of allocation requests whose user was not verified
+	// to be authenticated by the Kubernetes API server
+	unverifiedSuffix = " (unverified)"
+	// fleetAllocationEventPeriod is how often an event summarising the allocations
+	// of each Fleet is recorded, so a busy Fleet doesn't flood the API server with events
+	fleetAllocationEventPeriod = time.Minute
+)
+
+// requesterKey is the context key of the requester of an allocation request
+type requesterKey struct{}
+
+// requester is the client identity of an allocation request
+type requester struct {
+	namespace string
+	user      string
+}
+
+// String returns the requester as it is shown in events
+func (r requester) String() string {
+	return r.user + " from namespace " + r.namespace
+}
+
+// requesterOf returns the requester of an allocation request in namespace, from the
+// user that the apiserver.RemoteUser middleware added to the context of the request
+func requesterOf(r *http.Request, namespace string) requester {
+	user, ok := apiserver.UserFrom(r.Context())
+	if !ok {
+		return requester{namespace: namespace, user: unknownRequester}
+	}
+	if !user.Verified {
+		return requester{namespace: namespace, user: user.Name + unverifiedSuffix}
+	}
+	return requester{namespace: namespace, user: user.Name}
+}
+
+// identifyRequester is a middleware that adds the requester of an allocation request
+// to the context of the request, when allocation events are recorded
+func (c *Controller) identifyRequester(next apiserver.CRDHandler) apiserver.CRDHandler {
+	return func(w http.ResponseWriter, r *http.Request, namespace string) error {
+		if c.allocationEvents {
+			r = r.WithContext(withRequester(r.Context(), requesterOf(r, namespace)))
+		}
+		return next(w, r, namespace)
+	}
+}
+
+// withRequester returns a copy of ctx that carries req
+func withRequester(ctx context.Context, req requester) context.Context {
+	return context.WithValue(ctx, requesterKey{}, req)
+}
+
+// requesterFrom returns the requester carried by ctx, if there is one
+func requesterFrom(ctx context.Context) (requester, bool) {
+	req, ok := ctx.Value(requesterKey{}).(requester)
+	return req, ok
+}
+
+// fleetAllocations counts the allocations from each Fleet by each requester,
+// between the events that summarise them
+type fleetAllocations struct {
+	mu     sync.Mutex
+	counts map[string]map[requester]int
+}
+
+// add counts an allocation from the Fleet with the namespaced key by req
+func (fa *fleetAllocations) add(key string, req requester) {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	if fa.counts == nil {
+		fa.counts = map[string]map[requester]int{}
+	}
+	if fa.counts[key] == nil {
+		fa.counts[key] = map[requester]int{}
+	}
+	fa.counts[key][req]++
+}
+
+// reset returns the counts of allocations since the last reset, and clears them
+func (fa *fleetAllocations) reset() map[string]map[requester]int {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	counts := fa.counts
+	fa.counts = nil
+	return counts
+}
+
+// allocatedEventMessage returns the message of the event recorded on a GameServer
+// when it is allocated by the request with ctx
+func (c *Controller) allocatedEventMessage(ctx context.Context) string {
+	if req, ok := requesterFrom(ctx); ok {
+		return "Allocated by " + req.String()
+	}
+	return "Allocated"
+}
+
+// recordAllocation counts the allocation of gs by the request with ctx towards
+// the next event of its Fleet, if allocation events are enabled
+func (c *Controller) recordAllocation(ctx context.Context, gs *stablev1alpha1.GameServer) {
+	req, ok := requesterFrom(ctx)
+	if !ok {
+		return
+	}
+	fleetName := gs.ObjectMeta.Labels[stablev1alpha1.FleetNameLabel]
+	if fleetName == "" {
+		return
+	}
+	c.fleetAllocations.add(gs.ObjectMeta.Namespace+"/"+fleetName, req)
+}
+
+// recordFleetAllocationEvents records an event on each Fleet that GameServers have
+// been allocated from since the last time, with how many each requester allocated
+func (c *Controller) recordFleetAllocationEvents() {
+	for key, counts := range c.fleetAllocations.reset() {
+		parts := strings.SplitN(key, "/", 2)
+		fleet, err := c.fleetLister.Fleets(parts[0]).Get(parts[1])
+		if err != nil {
+			if !k8serrors.IsNotFound(err) {
+				c.baseLogger.WithError(err).WithField("fleet", key).Warn("could not get fleet to record allocation event")
+			}
+			continue
+		}
+
+		total := 0
+		var requesters []string
+		for req, n := range counts {
+			total += n
+			requesters = append(requesters, fmt.Sprintf("%s (%d)", req, n))
+		}
+		sort.Strings(requesters)
+		c.recorder.Eventf(fleet, corev1.EventTypeNormal, "Allocated", "Allocated %d GameServers in the last %s: %s",
+			total, fleetAllocationEventPeriod, strings.Join(requesters, ", "))
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserverallocations
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	stablev1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
+	agtesting "agones.dev/agones/pkg/testing"
+	"agones.dev/agones/pkg/util/apiserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRequesterOf(t *testing.T) {
+	t.Parallel()
+
+	r, err := http.NewRequest(http.MethodPost, "/apis/allocation.agones.dev/v1/namespaces/default/gameserverallocations", nil)
+	require.NoError(t, err)
+	assert.Equal(t, requester{namespace: "default", user: unknownRequester}, requesterOf(r, "default"))
+
+	user := apiserver.User{Name: "system:serviceaccount:mm:matchmaker", Verified: true}
+	req := requesterOf(r.WithContext(apiserver.WithUser(r.Context(), user)), "default")
+	assert.Equal(t, requester{namespace: "default", user: "system:serviceaccount:mm:matchmaker"}, req)
+	assert.Equal(t, "system:serviceaccount:mm:matchmaker from namespace default", req.String())
+
+	// anyone can set the user headers, so a user that was not verified is marked as such
+	user.Verified = false
+	req = requesterOf(r.WithContext(apiserver.WithUser(r.Context(), user)), "default")
+	assert.Equal(t, requester{namespace: "default", user: "system:serviceaccount:mm:matchmaker (unverified)"}, req)
+}
+
+func TestControllerIdentifyRequester(t *testing.T) {
+	t.Parallel()
+
+	r, err := http.NewRequest(http.MethodPost, "/apis/allocation.agones.dev/v1/namespaces/default/gameserverallocations", nil)
+	require.NoError(t, err)
+	r.Header.Set(apiserver.RemoteUserHeader, "matchmaker")
+
+	var found bool
+	var req requester
+	c, _ := newFakeController()
+	handler := apiserver.Chain(func(_ http.ResponseWriter, r *http.Request, _ string) error {
+		req, found = requesterFrom(r.Context())
+		return nil
+	}, apiserver.RemoteUser(apiserver.RequestHeaderAuth{}), c.identifyRequester)
+
+	require.NoError(t, handler(nil, r, "default"))
+	assert.False(t, found, "requesters are only identified with allocation events")
+
+	c.allocationEvents = true
+	require.NoError(t, handler(nil, r, "default"))
+	assert.True(t, found)
+	assert.Equal(t, requester{namespace: "default", user: "matchmaker (unverified)"}, req)
+}
+
+func TestControllerAllocatedEventMessage(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newFakeController()
+	assert.Equal(t, "Allocated", c.allocatedEventMessage(context.Background()))
+
+	ctx := withRequester(context.Background(), requester{namespace: "default", user: "matchmaker"})
+	assert.Equal(t, "Allocated by matchmaker from namespace default", c.allocatedEventMessage(ctx))
+}
+
+func TestControllerRecordFleetAllocationEvents(t *testing.T) {
+	t.Parallel()
+
+	f, _, gsList := defaultFixtures(4)
+	c, m := newFakeController()
+	m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		return true, &stablev1alpha1.FleetList{Items: []stablev1alpha1.Fleet{*f}}, nil
+	})
+	_, cancel := agtesting.StartInformers(m, c.fleetSynced)
+	defer cancel()
+
+	// no allocations, no events
+	c.recordFleetAllocationEvents()
+	agtesting.AssertNoEvent(t, m.FakeRecorder.Events)
+
+	mm := withRequester(context.Background(), requester{namespace: "default", user: "matchmaker"})
+	admin := withRequester(context.Background(), requester{namespace: "default", user: "admin"})
+	c.recordAllocation(mm, &gsList[0])
+	c.recordAllocation(mm, &gsList[1])
+	c.recordAllocation(admin, &gsList[2])
+	// allocations without a requester aren't counted
+	c.recordAllocation(context.Background(), &gsList[3])
+	// nor are GameServers that aren't in a Fleet
+	c.recordAllocation(mm, &stablev1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "standalone", Namespace: "default"}})
+	// nor Fleets that have been deleted
+	gone := gsList[3].DeepCopy()
+	gone.ObjectMeta.Labels = map[string]string{stablev1alpha1.FleetNameLabel: "deleted"}
+	c.recordAllocation(mm, gone)
+
+	c.recordFleetAllocationEvents()
+	agtesting.AssertEventContains(t, m.FakeRecorder.Events,
+		"Allocated 3 GameServers in the last 1m0s: admin from namespace default (1), matchmaker from namespace default (2)")
+	agtesting.AssertNoEvent(t, m.FakeRecorder.Events)
+
+	// the counts are reset after each event
+	c.recordFleetAllocationEvents()
+	agtesting.AssertNoEvent(t, m.FakeRecorder.Events)
+}
@@ -15,9 +15,12 @@
 package gameserverallocations
 
 import (
+	"sort"
 	"sync"
 
 	stablev1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // gameserver cache to keep the Ready state gameserver.
@@ -71,9 +74,74 @@ func (e *gameServerCacheEntry) Range(f func(key string, gs *stablev1alpha1.GameS
 	}
 }
 
+// List returns up to limit of the GameServers in the cache whose keys come after continueKey, ordered by key,
+// and the key to continue from for the next page, which is empty if there are no more. A limit of zero or less
+// returns all of them. Each page is a snapshot of the cache, so it can be iterated over at any pace while the
+// cache changes, and as pages are continued by key, GameServers that stay in the cache are always listed once,
+// and in the same order.
+func (e *gameServerCacheEntry) List(limit int, continueKey string) ([]*stablev1alpha1.GameServer, string) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	keys := make([]string, 0, len(e.cache))
+	for k := range e.cache {
+		if k > continueKey {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	next := ""
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+		next = keys[limit-1]
+	}
+
+	list := make([]*stablev1alpha1.GameServer, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, e.cache[k])
+	}
+	return list, next
+}
+
 // Len returns the current length of the cache
 func (e *gameServerCacheEntry) Len() int {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 	return len(e.cache)
 }
+
+// nodeLabelCache keeps the labels of each Node by name, so that GameServers can be selected by the
+// labels of the Node they run on, joined on their Status.NodeName, without getting the Node.
+type nodeLabelCache struct {
+	mu    sync.RWMutex
+	cache map[string]labels.Set
+}
+
+// Store saves the labels of the Node, and returns true if they changed.
+func (n *nodeLabelCache) Store(node *corev1.Node) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.cache == nil {
+		n.cache = map[string]labels.Set{}
+	}
+	if current, ok := n.cache[node.ObjectMeta.Name]; ok && labels.Equals(current, node.ObjectMeta.Labels) {
+		return false
+	}
+	n.cache[node.ObjectMeta.Name] = labels.Merge(nil, node.ObjectMeta.Labels)
+	return true
+}
+
+// Delete deletes the labels of the named Node.
+func (n *nodeLabelCache) Delete(name string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.cache, name)
+}
+
+// Labels returns the labels of the named Node, which are empty if the Node is not known.
+func (n *nodeLabelCache) Labels(name string) labels.Set {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.cache[name]
+}
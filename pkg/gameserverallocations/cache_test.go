@@ -19,7 +19,9 @@ import (
 
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 func TestGameServerCacheEntry(t *testing.T) {
@@ -58,3 +60,64 @@ func TestGameServerCacheEntry(t *testing.T) {
 	assert.Nil(t, gs)
 	assert.False(t, ok)
 }
+
+func TestGameServerCacheEntryList(t *testing.T) {
+	cache := gameServerCacheEntry{}
+
+	list, next := cache.List(2, "")
+	assert.Empty(t, list)
+	assert.Empty(t, next)
+
+	for _, name := range []string{"gs3", "gs1", "gs5", "gs2", "gs4"} {
+		cache.Store(name, &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	names := func(list []*v1alpha1.GameServer) []string {
+		var result []string
+		for _, gs := range list {
+			result = append(result, gs.ObjectMeta.Name)
+		}
+		return result
+	}
+
+	list, next = cache.List(0, "")
+	assert.Equal(t, []string{"gs1", "gs2", "gs3", "gs4", "gs5"}, names(list))
+	assert.Empty(t, next)
+
+	list, next = cache.List(2, "")
+	assert.Equal(t, []string{"gs1", "gs2"}, names(list))
+	assert.Equal(t, "gs2", next)
+
+	// changes to the cache between pages don't affect what has been listed,
+	// or the order of what comes next
+	cache.Delete("gs1")
+	cache.Delete("gs3")
+	cache.Store("gs6", &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "gs6"}})
+
+	list, next = cache.List(2, next)
+	assert.Equal(t, []string{"gs4", "gs5"}, names(list))
+	assert.Equal(t, "gs5", next)
+
+	list, next = cache.List(2, next)
+	assert.Equal(t, []string{"gs6"}, names(list))
+	assert.Empty(t, next)
+}
+
+func TestNodeLabelCache(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"zone": "a"}}}
+
+	cache := nodeLabelCache{}
+	assert.Empty(t, cache.Labels("node1"))
+
+	assert.True(t, cache.Store(node))
+	assert.Equal(t, labels.Set{"zone": "a"}, cache.Labels("node1"))
+	assert.False(t, cache.Store(node.DeepCopy()), "labels haven't changed")
+
+	// the cache has its own copy of the labels
+	node.ObjectMeta.Labels["zone"] = "b"
+	assert.Equal(t, labels.Set{"zone": "a"}, cache.Labels("node1"))
+	assert.True(t, cache.Store(node))
+	assert.Equal(t, labels.Set{"zone": "b"}, cache.Labels("node1"))
+
+	cache.Delete("node1")
+	assert.Empty(t, cache.Labels("node1"))
+}
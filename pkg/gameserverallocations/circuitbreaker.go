@@ -0,0 +1,151 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserverallocations
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+const (
+	// circuitWindowSize is the number of most recent requests to an endpoint
+	// that its error rate is calculated from
+	circuitWindowSize = 10
+	// circuitMinRequests is the number of requests an endpoint needs to have
+	// in its window before its circuit can open
+	circuitMinRequests = 5
+	// circuitErrorRate is the error rate at which an endpoint's circuit opens
+	circuitErrorRate = 0.5
+	// circuitOpenDuration is how long an open circuit skips its endpoint,
+	// before a single trial request is let through
+	circuitOpenDuration = 30 * time.Second
+)
+
+// circuitState is the state of the circuit of a single endpoint
+type circuitState int
+
+const (
+	// circuitClosed sends requests to the endpoint
+	circuitClosed circuitState = iota
+	// circuitOpen skips the endpoint
+	circuitOpen
+	// circuitHalfOpen has sent a trial request to the endpoint, and skips it
+	// until the trial request's result is recorded
+	circuitHalfOpen
+)
+
+// circuit tracks the recent results of requests to a single endpoint
+type circuit struct {
+	state circuitState
+	// failures is a ring buffer of the most recent results, true being a failure
+	failures []bool
+	next     int
+	openedAt time.Time
+}
+
+// errorRate returns the rate of failures in the circuit's window
+func (c *circuit) errorRate() float64 {
+	if len(c.failures) == 0 {
+		return 0
+	}
+	count := 0
+	for _, f := range c.failures {
+		if f {
+			count++
+		}
+	}
+	return float64(count) / float64(len(c.failures))
+}
+
+// add adds a result to the circuit's window, replacing the oldest one if it is full
+func (c *circuit) add(failure bool) {
+	if len(c.failures) < circuitWindowSize {
+		c.failures = append(c.failures, failure)
+		return
+	}
+	c.failures[c.next] = failure
+	c.next = (c.next + 1) % circuitWindowSize
+}
+
+// circuitBreaker is an error rate based circuit breaker for remote allocation endpoints,
+// so that an endpoint that keeps failing is skipped, rather than every allocation
+// waiting for its requests to it to fail.
+type circuitBreaker struct {
+	mutex    sync.Mutex
+	clock    clock.Clock
+	circuits map[string]*circuit
+}
+
+// newCircuitBreaker returns a circuitBreaker with all circuits closed
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{clock: clock.RealClock{}, circuits: map[string]*circuit{}}
+}
+
+// allow returns true if a request can be sent to the endpoint.
+// Once an open circuit has been open for circuitOpenDuration, a single
+// trial request is allowed, which decides whether the circuit closes again.
+func (cb *circuitBreaker) allow(endpoint string) bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	c, ok := cb.circuits[endpoint]
+	if !ok {
+		return true
+	}
+
+	switch c.state {
+	case circuitOpen:
+		if cb.clock.Since(c.openedAt) < circuitOpenDuration {
+			return false
+		}
+		c.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// record records the result of a request to the endpoint, and opens
+// or closes its circuit accordingly
+func (cb *circuitBreaker) record(endpoint string, success bool) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	c, ok := cb.circuits[endpoint]
+	if !ok {
+		c = &circuit{}
+		cb.circuits[endpoint] = c
+	}
+
+	switch c.state {
+	case circuitHalfOpen:
+		if success {
+			*c = circuit{}
+			return
+		}
+		c.state = circuitOpen
+		c.openedAt = cb.clock.Now()
+	case circuitClosed:
+		c.add(!success)
+		if len(c.failures) >= circuitMinRequests && c.errorRate() >= circuitErrorRate {
+			c.state = circuitOpen
+			c.openedAt = cb.clock.Now()
+		}
+	}
+}
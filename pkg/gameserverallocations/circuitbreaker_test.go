@@ -0,0 +1,92 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserverallocations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	const endpoint = "https://remote"
+
+	t.Run("opens on error rate", func(t *testing.T) {
+		cb := newCircuitBreaker()
+		assert.True(t, cb.allow(endpoint))
+
+		// not enough requests to open
+		for i := 0; i < circuitMinRequests-1; i++ {
+			cb.record(endpoint, false)
+			assert.True(t, cb.allow(endpoint))
+		}
+		cb.record(endpoint, false)
+		assert.False(t, cb.allow(endpoint))
+		assert.True(t, cb.allow("https://other"))
+	})
+
+	t.Run("stays closed below the error rate", func(t *testing.T) {
+		cb := newCircuitBreaker()
+		for i := 0; i < circuitWindowSize*2; i++ {
+			cb.record(endpoint, i%3 != 0)
+			assert.True(t, cb.allow(endpoint))
+		}
+
+		// old successes leave the window
+		for i := 0; i < circuitWindowSize/2; i++ {
+			cb.record(endpoint, false)
+		}
+		assert.False(t, cb.allow(endpoint))
+	})
+
+	t.Run("half open", func(t *testing.T) {
+		cb := newCircuitBreaker()
+		fc := clock.NewFakeClock(time.Now())
+		cb.clock = fc
+
+		for i := 0; i < circuitMinRequests; i++ {
+			cb.record(endpoint, false)
+		}
+		assert.False(t, cb.allow(endpoint))
+
+		fc.Step(circuitOpenDuration)
+		// only a single trial request
+		assert.True(t, cb.allow(endpoint))
+		assert.False(t, cb.allow(endpoint))
+
+		// a failed trial opens the circuit again
+		cb.record(endpoint, false)
+		assert.False(t, cb.allow(endpoint))
+		fc.Step(circuitOpenDuration - time.Second)
+		assert.False(t, cb.allow(endpoint))
+		fc.Step(time.Second)
+		assert.True(t, cb.allow(endpoint))
+
+		// a successful trial closes it
+		cb.record(endpoint, true)
+		assert.True(t, cb.allow(endpoint))
+		assert.True(t, cb.allow(endpoint))
+
+		// with a clean window
+		for i := 0; i < circuitMinRequests-1; i++ {
+			cb.record(endpoint, false)
+		}
+		assert.True(t, cb.allow(endpoint))
+	})
+}
@@ -16,15 +16,18 @@ package gameserverallocations
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"math/rand"
 	"net/http"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
@@ -37,6 +40,7 @@ import (
 	multiclusterlisterv1alpha1 "agones.dev/agones/pkg/client/listers/multicluster/v1alpha1"
 	listerv1alpha1 "agones.dev/agones/pkg/client/listers/stable/v1alpha1"
 	"agones.dev/agones/pkg/gameservers"
+	"agones.dev/agones/pkg/metrics"
 	"agones.dev/agones/pkg/util/apiserver"
 	"agones.dev/agones/pkg/util/https"
 	"agones.dev/agones/pkg/util/logfields"
@@ -45,7 +49,9 @@ import (
 	"github.com/heptiolabs/healthcheck"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
@@ -67,6 +73,11 @@ var (
 	ErrNoGameServerReady = errors.New("Could not find a Ready GameServer")
 	// ErrConflictInGameServerSelection is returned when the candidate gameserver already allocated
 	ErrConflictInGameServerSelection = errors.New("The Gameserver was already allocated")
+	// ErrNoAllocationEndpointAvailable is returned when all the allocation endpoints
+	// of a remote cluster are skipped, as their circuit breakers are open
+	ErrNoAllocationEndpointAvailable = errors.New("All allocation endpoints of the remote cluster are unavailable")
+	// ErrCacheSyncing is returned when the Ready GameServer cache has not finished its initial sync
+	ErrCacheSyncing = errors.New("The Ready GameServer cache is still syncing")
 )
 
 const (
@@ -76,10 +87,14 @@ const (
 	maxBatchQueue         = 100
 	maxBatchBeforeRefresh = 100
 	batchWaitTime         = 500 * time.Millisecond
+	// cacheSyncRetryAfterSeconds is how long clients are asked to wait while the cache syncs
+	cacheSyncRetryAfterSeconds int32 = 1
 )
 
 // request is an async request for allocation
 type request struct {
+	// ctx is the context of the HTTP request, so the allocation can be abandoned if the client goes away
+	ctx      context.Context
 	gsa      *allocationv1.GameServerAllocation
 	response chan response
 }
@@ -96,12 +111,36 @@ type Controller struct {
 	baseLogger       *logrus.Entry
 	counter          *gameservers.PerNodeCounter
 	readyGameServers gameServerCacheEntry
+	// cacheSynced is true once readyGameServers has been populated on startup
+	cacheSynced      bool
+	cacheSyncedMutex sync.RWMutex
+	// Allocated gameservers, for allocations that allow re-allocation
+	allocatedGameServers gameServerCacheEntry
+	// labels of each Node, for allocations with a node selector
+	nodeLabels nodeLabelCache
+	nodeSynced cache.InformerSynced
+	// per fleet counts of the ready and allocated caches, as last recorded to metrics
+	readyCacheCounts     map[string]int64
+	allocatedCacheCounts map[string]int64
 	// Instead of selecting the top one, controller selects a random one
 	// from the topNGameServerCount of Ready gameservers
-	topNGameServerCount    int
+	topNGameServerCount int
+	// logSampleRate is the fraction of allocation requests that are logged, between 0 and 1
+	logSampleRate float64
+	// allocationEvents records who allocated each GameServer in its events, and a summary on its Fleet
+	allocationEvents bool
+	fleetAllocations fleetAllocations
+	// rateLimit is how many allocation requests per second are served, or 0 for no limit
+	rateLimit float64
+	// failureRecorder records the allocations from each Fleet that failed, for FleetAutoscalers
+	failureRecorder        FailureRecorder
 	gameServerSynced       cache.InformerSynced
 	gameServerGetter       getterv1alpha1.GameServersGetter
 	gameServerLister       listerv1alpha1.GameServerLister
+	gameServerSetSynced    cache.InformerSynced
+	gameServerSetLister    listerv1alpha1.GameServerSetLister
+	fleetSynced            cache.InformerSynced
+	fleetLister            listerv1alpha1.FleetLister
 	allocationPolicyLister multiclusterlisterv1alpha1.GameServerAllocationPolicyLister
 	allocationPolicySynced cache.InformerSynced
 	secretLister           corev1lister.SecretLister
@@ -110,6 +149,8 @@ type Controller struct {
 	workerqueue            *workerqueue.WorkerQueue
 	recorder               record.EventRecorder
 	pendingRequests        chan request
+	// circuit breakers for the allocation endpoints of remote clusters
+	endpointCircuitBreaker *circuitBreaker
 }
 
 var allocationRetry = wait.Backoff{
@@ -124,6 +165,10 @@ func NewController(apiServer *apiserver.APIServer,
 	health healthcheck.Handler,
 	counter *gameservers.PerNodeCounter,
 	topNGameServerCnt int,
+	logSampleRate float64,
+	allocationEvents bool,
+	rateLimit float64,
+	failureRecorder FailureRecorder,
 	kubeClient kubernetes.Interface,
 	kubeInformerFactory informers.SharedInformerFactory,
 	agonesClient versioned.Interface,
@@ -134,14 +179,26 @@ func NewController(apiServer *apiserver.APIServer,
 	c := &Controller{
 		counter:                counter,
 		topNGameServerCount:    topNGameServerCnt,
+		logSampleRate:          logSampleRate,
+		allocationEvents:       allocationEvents,
+		rateLimit:              rateLimit,
+		failureRecorder:        failureRecorder,
 		gameServerSynced:       agonesInformer.GameServers().Informer().HasSynced,
 		gameServerGetter:       agonesClient.StableV1alpha1(),
 		gameServerLister:       agonesInformer.GameServers().Lister(),
+		gameServerSetSynced:    agonesInformer.GameServerSets().Informer().HasSynced,
+		gameServerSetLister:    agonesInformer.GameServerSets().Lister(),
+		fleetSynced:            agonesInformer.Fleets().Informer().HasSynced,
+		fleetLister:            agonesInformer.Fleets().Lister(),
 		allocationPolicyLister: agonesInformerFactory.Multicluster().V1alpha1().GameServerAllocationPolicies().Lister(),
 		allocationPolicySynced: agonesInformerFactory.Multicluster().V1alpha1().GameServerAllocationPolicies().Informer().HasSynced,
 		secretLister:           kubeInformerFactory.Core().V1().Secrets().Lister(),
 		secretSynced:           kubeInformerFactory.Core().V1().Secrets().Informer().HasSynced,
+		nodeSynced:             kubeInformerFactory.Core().V1().Nodes().Informer().HasSynced,
 		pendingRequests:        make(chan request, maxBatchQueue),
+		endpointCircuitBreaker: newCircuitBreaker(),
+		readyCacheCounts:       map[string]int64{},
+		allocatedCacheCounts:   map[string]int64{},
 	}
 	c.baseLogger = runtime.NewLoggerWithType(c)
 	c.workerqueue = workerqueue.NewWorkerQueue(c.syncGameServers, c.baseLogger, logfields.GameServerKey, stable.GroupName+".GameServerUpdateController")
@@ -156,7 +213,7 @@ func NewController(apiServer *apiserver.APIServer,
 
 	agonesInformer.GameServers().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			// only interested in if the old / new state was/is Ready
+			// only interested in if the old / new state was/is Ready or Allocated
 			oldGs := oldObj.(*stablev1alpha1.GameServer)
 			newGs := newObj.(*stablev1alpha1.GameServer)
 			key, ok := c.getKey(newGs)
@@ -165,13 +222,23 @@ func NewController(apiServer *apiserver.APIServer,
 			}
 			if newGs.IsBeingDeleted() {
 				c.readyGameServers.Delete(key)
-			} else if oldGs.Status.State == stablev1alpha1.GameServerStateReady || newGs.Status.State == stablev1alpha1.GameServerStateReady {
+				c.allocatedGameServers.Delete(key)
+				return
+			}
+			if oldGs.Status.State == stablev1alpha1.GameServerStateReady || newGs.Status.State == stablev1alpha1.GameServerStateReady {
 				if newGs.Status.State == stablev1alpha1.GameServerStateReady {
 					c.readyGameServers.Store(key, newGs)
 				} else {
 					c.readyGameServers.Delete(key)
 				}
 			}
+			if oldGs.Status.State == stablev1alpha1.GameServerStateAllocated || newGs.Status.State == stablev1alpha1.GameServerStateAllocated {
+				if newGs.Status.State == stablev1alpha1.GameServerStateAllocated {
+					c.allocatedGameServers.Store(key, newGs)
+				} else {
+					c.allocatedGameServers.Delete(key)
+				}
+			}
 		},
 		DeleteFunc: func(obj interface{}) {
 			gs, ok := obj.(*stablev1alpha1.GameServer)
@@ -181,6 +248,27 @@ func NewController(apiServer *apiserver.APIServer,
 			var key string
 			if key, ok = c.getKey(gs); ok {
 				c.readyGameServers.Delete(key)
+				c.allocatedGameServers.Delete(key)
+			}
+		},
+	})
+
+	kubeInformerFactory.Core().V1().Nodes().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.nodeLabels.Store(obj.(*corev1.Node))
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			node := newObj.(*corev1.Node)
+			if c.nodeLabels.Store(node) {
+				c.baseLogger.WithField("node", node.ObjectMeta.Name).Debug("Node labels changed")
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			// Could be a DeletedFinalStateUnknown, in which case, use its key
+			if node, ok := obj.(*corev1.Node); ok {
+				c.nodeLabels.Delete(node.ObjectMeta.Name)
+			} else if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				c.nodeLabels.Delete(tombstone.Key)
 			}
 		},
 	})
@@ -200,7 +288,18 @@ func (c *Controller) registerAPIResource(api *apiserver.APIServer) {
 		},
 		ShortNames: []string{"gsa"},
 	}
-	api.AddAPIResource(allocationv1.SchemeGroupVersion.String(), resource, c.allocationHandler)
+	api.AddAPIResource(allocationv1.SchemeGroupVersion.String(), resource, c.allocationHandler, c.allocationMiddlewares()...)
+}
+
+// allocationMiddlewares are the middlewares that allocation requests are routed through to the allocationHandler
+func (c *Controller) allocationMiddlewares() []apiserver.Middleware {
+	middlewares := []apiserver.Middleware{apiserver.AllowMethods(http.MethodPost)}
+	if c.rateLimit > 0 {
+		// allow bursts of up to a second's worth of requests
+		limiter := rate.NewLimiter(rate.Limit(c.rateLimit), int(math.Ceil(c.rateLimit)))
+		middlewares = append(middlewares, apiserver.RateLimit(limiter))
+	}
+	return append(middlewares, c.identifyRequester, c.sampleRequests)
 }
 
 // Run runs this controller. Will block until stop is closed.
@@ -208,7 +307,7 @@ func (c *Controller) registerAPIResource(api *apiserver.APIServer) {
 func (c *Controller) Run(_ int, stop <-chan struct{}) error {
 	c.stop = stop
 	c.baseLogger.Info("Wait for cache sync")
-	if !cache.WaitForCacheSync(stop, c.gameServerSynced, c.secretSynced, c.allocationPolicySynced) {
+	if !cache.WaitForCacheSync(stop, c.gameServerSynced, c.gameServerSetSynced, c.fleetSynced, c.secretSynced, c.allocationPolicySynced, c.nodeSynced) {
 		return errors.New("failed to wait for caches to sync")
 	}
 
@@ -217,10 +316,17 @@ func (c *Controller) Run(_ int, stop <-chan struct{}) error {
 	if err != nil {
 		return err
 	}
+	c.setCacheSynced()
 
 	// workers and logic for batching allocations
 	go c.runLocalAllocations(maxBatchQueue)
 
+	go wait.Until(c.recordCacheMetrics, metrics.MetricResyncPeriod, stop)
+
+	if c.allocationEvents {
+		go wait.Until(c.recordFleetAllocationEvents, fleetAllocationEventPeriod, stop)
+	}
+
 	// we don't want mutiple workers refresh cache at the same time so one worker will be better.
 	// Also we don't expect to have too many failures when allocating
 	c.workerqueue.Run(1, stop)
@@ -244,21 +350,13 @@ func (c *Controller) loggerForGameServerAllocation(gsa *allocationv1.GameServerA
 	return c.loggerForGameServerAllocationKey(gsaName).WithField("gsa", gsa)
 }
 
-// allocationHandler CRDHandler for allocating a gameserver. Only accepts POST
-// commands
+// allocationHandler CRDHandler for allocating a gameserver. Only receives POST
+// commands, through the allocationMiddlewares
 func (c *Controller) allocationHandler(w http.ResponseWriter, r *http.Request, namespace string) error {
 	if r.Body != nil {
 		defer r.Body.Close() // nolint: errcheck
 	}
 
-	log := https.LogRequest(c.baseLogger, r)
-
-	if r.Method != http.MethodPost {
-		log.Warn("allocation handler only supports POST")
-		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
-		return nil
-	}
-
 	gsa, err := c.allocationDeserialization(r, namespace)
 	if err != nil {
 		return err
@@ -290,12 +388,42 @@ func (c *Controller) allocationHandler(w http.ResponseWriter, r *http.Request, n
 		return c.serialisation(r, w, status, apiserver.Codecs)
 	}
 
+	ctx := r.Context()
+	rl := requestLogFrom(ctx)
+
 	// If multi-cluster setting is enabled, allocate base on the multicluster allocation policy.
 	var out *allocationv1.GameServerAllocation
 	if gsa.Spec.MultiClusterSetting.Enabled {
-		out, err = c.applyMultiClusterAllocation(gsa)
+		out, err = c.applyMultiClusterAllocation(ctx, gsa)
 	} else {
-		out, err = c.allocateFromLocalCluster(gsa)
+		out, err = c.allocateFromLocalCluster(ctx, gsa)
+	}
+	c.logAllocationRequest(rl, gsa, out, err)
+
+	if errors.Cause(err) == ErrCacheSyncing {
+		https.LogRequest(c.baseLogger, r).Warn("allocation requested while the Ready GameServer cache is syncing")
+		status := &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: ErrCacheSyncing.Error(),
+			Reason:  metav1.StatusReasonServiceUnavailable,
+			Details: &metav1.StatusDetails{
+				Kind:              "GameServerAllocation",
+				Group:             allocationv1.SchemeGroupVersion.Group,
+				RetryAfterSeconds: cacheSyncRetryAfterSeconds,
+			},
+			Code: http.StatusServiceUnavailable,
+		}
+		var gvks []schema.GroupVersionKind
+		gvks, _, err = apiserver.Scheme.ObjectKinds(status)
+		if err != nil {
+			return errors.Wrap(err, "could not find objectkinds for status")
+		}
+
+		status.TypeMeta = metav1.TypeMeta{Kind: gvks[0].Kind, APIVersion: gvks[0].Version}
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(cacheSyncRetryAfterSeconds)))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return c.serialisation(r, w, status, apiserver.Codecs)
 	}
 
 	if err != nil {
@@ -305,15 +433,44 @@ func (c *Controller) allocationHandler(w http.ResponseWriter, r *http.Request, n
 	return c.serialisation(r, w, out, scheme.Codecs)
 }
 
+// setCacheSynced marks the Ready GameServer cache as populated,
+// so allocations can be served
+func (c *Controller) setCacheSynced() {
+	c.cacheSyncedMutex.Lock()
+	defer c.cacheSyncedMutex.Unlock()
+	c.cacheSynced = true
+}
+
+// isCacheSynced returns true once the Ready GameServer cache has
+// finished its initial sync
+func (c *Controller) isCacheSynced() bool {
+	c.cacheSyncedMutex.RLock()
+	defer c.cacheSyncedMutex.RUnlock()
+	return c.cacheSynced
+}
+
 // allocateFromLocalCluster allocates gameservers from the local cluster.
-func (c *Controller) allocateFromLocalCluster(gsa *allocationv1.GameServerAllocation) (*allocationv1.GameServerAllocation, error) {
+// The allocation is abandoned if ctx is done before a GameServer has been allocated.
+func (c *Controller) allocateFromLocalCluster(ctx context.Context, gsa *allocationv1.GameServerAllocation) (*allocationv1.GameServerAllocation, error) {
+	// until the cache is built every allocation would come back UnAllocated
+	if !c.isCacheSynced() {
+		return nil, ErrCacheSyncing
+	}
+
+	rl := requestLogFrom(ctx)
 	var gs *stablev1alpha1.GameServer
 	err := Retry(allocationRetry, func() error {
 		var err error
-		gs, err = c.allocate(gsa)
+		rl.attempt()
+		gs, err = c.allocate(ctx, gsa)
 		return err
 	})
 
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		c.loggerForGameServerAllocation(gsa).WithError(err).Info("game server allocation abandoned")
+		return nil, err
+	}
+
 	if err != nil && err != ErrNoGameServerReady && err != ErrConflictInGameServerSelection {
 		// this will trigger syncing of the cache (assuming cache might not be up to date)
 		c.workerqueue.EnqueueImmediately(gs)
@@ -322,6 +479,7 @@ func (c *Controller) allocateFromLocalCluster(gsa *allocationv1.GameServerAlloca
 
 	if err == ErrNoGameServerReady {
 		gsa.Status.State = allocationv1.GameServerAllocationUnAllocated
+		c.recordAllocationFailure(gsa)
 	} else if err == ErrConflictInGameServerSelection {
 		gsa.Status.State = allocationv1.GameServerAllocationContention
 	} else {
@@ -330,16 +488,40 @@ func (c *Controller) allocateFromLocalCluster(gsa *allocationv1.GameServerAlloca
 		gsa.Status.GameServerName = gs.ObjectMeta.Name
 		gsa.Status.Ports = gs.Status.Ports
 		gsa.Status.Address = gs.Status.Address
+		gsa.Status.Addresses = gs.Status.Addresses
 		gsa.Status.NodeName = gs.Status.NodeName
+		if gsa.Spec.IncludeOwners {
+			c.setOwners(gsa, gs)
+		}
 	}
 
 	c.loggerForGameServerAllocation(gsa).Info("game server allocation")
 	return gsa, nil
 }
 
+// setOwners sets the Fleet and GameServerSet of the allocated GameServer, from their caches,
+// in the status of the GameServerAllocation. If either has been deleted since, only its name is set.
+// The owners are copied, so that the GameServerAllocation doesn't share their labels with the caches.
+func (c *Controller) setOwners(gsa *allocationv1.GameServerAllocation, gs *stablev1alpha1.GameServer) {
+	if name, ok := gs.ObjectMeta.Labels[stablev1alpha1.GameServerSetGameServerLabel]; ok {
+		owner := &allocationv1.GameServerAllocationOwner{Name: name}
+		if gsSet, err := c.gameServerSetLister.GameServerSets(gs.ObjectMeta.Namespace).Get(name); err == nil {
+			owner.Labels = gsSet.ObjectMeta.Labels
+		}
+		gsa.Status.GameServerSet = owner.DeepCopy()
+	}
+	if name, ok := gs.ObjectMeta.Labels[stablev1alpha1.FleetNameLabel]; ok {
+		owner := &allocationv1.GameServerAllocationOwner{Name: name}
+		if fleet, err := c.fleetLister.Fleets(gs.ObjectMeta.Namespace).Get(name); err == nil {
+			owner.Labels = fleet.ObjectMeta.Labels
+		}
+		gsa.Status.Fleet = owner.DeepCopy()
+	}
+}
+
 // applyMultiClusterAllocation retrieves allocation policies and iterate on policies.
 // Then allocate gameservers from local or remote cluster accordingly.
-func (c *Controller) applyMultiClusterAllocation(gsa *allocationv1.GameServerAllocation) (result *allocationv1.GameServerAllocation, err error) {
+func (c *Controller) applyMultiClusterAllocation(ctx context.Context, gsa *allocationv1.GameServerAllocation) (result *allocationv1.GameServerAllocation, err error) {
 
 	selector := labels.Everything()
 	if len(gsa.Spec.MultiClusterSetting.PolicySelector.MatchLabels)+len(gsa.Spec.MultiClusterSetting.PolicySelector.MatchExpressions) != 0 {
@@ -363,12 +545,13 @@ func (c *Controller) applyMultiClusterAllocation(gsa *allocationv1.GameServerAll
 			break
 		}
 		if connectionInfo.ClusterName == gsa.ObjectMeta.ClusterName {
-			result, err = c.allocateFromLocalCluster(gsa)
+			result, err = c.allocateFromLocalCluster(ctx, gsa)
 			c.baseLogger.Error(err)
 		} else {
 			result, err = c.allocateFromRemoteCluster(*gsa, connectionInfo, gsa.ObjectMeta.Namespace)
 			c.baseLogger.Error(err)
 		}
+		metrics.RecordMultiClusterAllocation(connectionInfo.ClusterName, result != nil)
 		if result != nil {
 			return result, nil
 		}
@@ -397,24 +580,31 @@ func (c *Controller) allocateFromRemoteCluster(gsa allocationv1.GameServerAlloca
 		return nil, err
 	}
 
-	// TODO: Retry on transient error --> response.StatusCode >= 500
+	// Endpoints that keep failing have their circuit opened, and are skipped
+	// until their circuit breaker lets a trial request through.
+	err = ErrNoAllocationEndpointAvailable
 	for i, endpoint := range connectionInfo.AllocationEndpoints {
-		response, err := client.Post(endpoint, "application/json", bytes.NewBuffer(body))
-		if err != nil {
-			return nil, err
+		logger := c.baseLogger.WithField("endpoint", endpoint)
+		if !c.endpointCircuitBreaker.allow(endpoint) {
+			logger.Debug("Circuit breaker is open, skipping endpoint")
+			continue
 		}
-		defer response.Body.Close() // nolint: errcheck
 
-		data, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			return nil, err
+		statusCode, data, postErr := postAllocation(client, endpoint, body)
+		c.endpointCircuitBreaker.record(endpoint, postErr == nil && statusCode < 500)
+		if postErr != nil {
+			// If the endpoint could not be reached try a different endpoint
+			logger.WithError(postErr).Warn("The request could not be sent, trying next endpoint")
+			err = postErr
+			continue
 		}
-		if response.StatusCode >= 500 && (i+1) < len(connectionInfo.AllocationEndpoints) {
+		if statusCode >= 500 && (i+1) < len(connectionInfo.AllocationEndpoints) {
 			// If there is a server error try a different endpoint
-			c.baseLogger.WithError(err).WithField("endpoint", endpoint).Warn("The request sent failed, trying next endpoint")
+			logger.WithField("statusCode", statusCode).Warn("The request sent failed, trying next endpoint")
+			err = errors.New(string(data))
 			continue
 		}
-		if response.StatusCode >= 400 {
+		if statusCode >= 400 {
 			// For error responses return the body without deserializing to an object.
 			return nil, errors.New(string(data))
 		}
@@ -423,9 +613,25 @@ func (c *Controller) allocateFromRemoteCluster(gsa allocationv1.GameServerAlloca
 		if err != nil {
 			return nil, err
 		}
-		break
+		return &gsaResult, nil
+	}
+	return nil, err
+}
+
+// postAllocation sends the serialised GameServerAllocation to a remote allocation endpoint,
+// and returns the status code and body of the response.
+func postAllocation(client *http.Client, endpoint string, body []byte) (int, []byte, error) {
+	response, err := client.Post(endpoint, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return 0, nil, err
 	}
-	return &gsaResult, nil
+	defer response.Body.Close() // nolint: errcheck
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return response.StatusCode, data, nil
 }
 
 // createRemoteClusterRestClient creates a rest client with proper certs to make a remote call.
@@ -532,17 +738,28 @@ func (c *Controller) serialisation(r *http.Request, w http.ResponseWriter, obj k
 
 // allocate allocated a GameServer from a given GameServerAllocation
 // this sets up allocation through a batch process.
-func (c *Controller) allocate(gsa *allocationv1.GameServerAllocation) (*stablev1alpha1.GameServer, error) {
+// If ctx is done before the batch gets to the request, it is abandoned without
+// taking a GameServer, and ctx.Err() is returned.
+func (c *Controller) allocate(ctx context.Context, gsa *allocationv1.GameServerAllocation) (*stablev1alpha1.GameServer, error) {
 	// creates an allocation request. This contains the requested GameServerAllocation, as well as the
-	// channel we expect the return values to come back for this GameServerAllocation
-	req := request{gsa: gsa, response: make(chan response)}
+	// channel we expect the return values to come back for this GameServerAllocation.
+	// The channel is buffered, so the batch never blocks on a request that has been abandoned
+	req := request{ctx: ctx, gsa: gsa, response: make(chan response, 1)}
 
 	// this pushes the request into the batching process
-	c.pendingRequests <- req
+	select {
+	case c.pendingRequests <- req:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.stop:
+		return nil, errors.New("shutting down")
+	}
 
 	select {
 	case res := <-req.response: // wait for the batch to be completed
 		return res.gs, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	case <-c.stop:
 		return nil, errors.New("shutting down")
 	}
@@ -577,6 +794,10 @@ func (c *Controller) runLocalAllocations(updateWorkerCount int) {
 	// waiting to concurrently attempt to move the GameServer into an Allocated state, and return the result to
 	// GameServerAllocation request's response channel
 
+	// If the GameServerAllocation allows re-allocation of Allocated GameServers, the same process is first run
+	// against a sorted list of Allocated GameServers (allocatedList), and only if no match is found there,
+	// do we move on to the Ready GameServers.
+
 	// Then we get the next item off the batch (c.pendingRequests), and do this all over again, but this time, we have
 	// an already sorted list of GameServers, so we only need to find one that matches our GameServerAllocation
 	// selectors, and put it into updateQueue
@@ -587,23 +808,56 @@ func (c *Controller) runLocalAllocations(updateWorkerCount int) {
 	// continued.
 
 	var list []*stablev1alpha1.GameServer
+	var allocatedList []*stablev1alpha1.GameServer
 	requestCount := 0
 
 	for {
 		select {
 		case req := <-c.pendingRequests:
+			// the client has gone away, so don't take a GameServer for it
+			if err := req.ctx.Err(); err != nil {
+				req.response <- response{request: req, gs: nil, err: err}
+				continue
+			}
+
 			// refresh the list after every 100 allocations made in a single batch
 			requestCount++
 			if requestCount >= maxBatchBeforeRefresh {
 				list = nil
+				allocatedList = nil
 				requestCount = 0
 			}
 
+			if req.gsa.Spec.Allocated != nil {
+				if allocatedList == nil {
+					allocatedList = c.listSortedGameServers(&c.allocatedGameServers)
+				}
+
+				gs, index, err := findGameServerOnNodes(req.gsa, allocatedList, &c.nodeLabels, findAllocatedGameServerForAllocation)
+				if err == nil {
+					// remove the game server while it is being updated, it is stored again once the update is complete
+					allocatedList = append(allocatedList[:index], allocatedList[index+1:]...)
+
+					key, _ := cache.MetaNamespaceKeyFunc(gs)
+					if ok := c.allocatedGameServers.Delete(key); !ok {
+						req.response <- response{request: req, gs: nil, err: ErrConflictInGameServerSelection}
+						continue
+					}
+
+					updateQueue <- response{request: req, gs: gs.DeepCopy(), err: nil}
+					continue
+				}
+				if err != ErrNoGameServerReady {
+					req.response <- response{request: req, gs: nil, err: err}
+					continue
+				}
+			}
+
 			if list == nil {
 				list = c.listSortedReadyGameServers()
 			}
 
-			gs, index, err := findGameServerForAllocation(req.gsa, list)
+			gs, index, err := findGameServerOnNodes(req.gsa, list, &c.nodeLabels, findGameServerForAllocation)
 			if err != nil {
 				req.response <- response{request: req, gs: nil, err: err}
 				continue
@@ -624,6 +878,7 @@ func (c *Controller) runLocalAllocations(updateWorkerCount int) {
 			return
 		default:
 			list = nil
+			allocatedList = nil
 			requestCount = 0
 			// slow down cpu churn, and allow items to batch
 			time.Sleep(batchWaitTime)
@@ -644,19 +899,52 @@ func (c *Controller) allocationUpdateWorkers(workerCount int) chan<- response {
 			for {
 				select {
 				case res := <-updateQueue:
+					reallocation := res.gs.Status.State == stablev1alpha1.GameServerStateAllocated
+					// the client went away while the GameServer was queued, so put it back
+					if err := res.request.ctx.Err(); err != nil {
+						key, _ := cache.MetaNamespaceKeyFunc(res.gs)
+						if reallocation {
+							c.allocatedGameServers.Store(key, res.gs)
+						} else {
+							c.readyGameServers.Store(key, res.gs)
+						}
+						res.gs = nil
+						res.err = err
+						res.request.response <- res
+						continue
+					}
+
 					gsCopy := res.gs.DeepCopy()
-					c.patchMetadata(gsCopy, res.request.gsa.Spec.MetaPatch)
+					PatchMetadata(gsCopy, res.request.gsa.Spec.MetaPatch)
 					gsCopy.Status.State = stablev1alpha1.GameServerStateAllocated
 
+					// the update fails with a conflict if the cached GameServer is out of date, e.g. because
+					// another replica of this controller has allocated it, so it is never allocated twice
 					gs, err := c.gameServerGetter.GameServers(res.gs.ObjectMeta.Namespace).Update(gsCopy)
-					if err != nil {
-						key, _ := cache.MetaNamespaceKeyFunc(gs)
+					if k8serrors.IsConflict(err) || k8serrors.IsNotFound(err) {
+						// don't put the out of date GameServer back, the informer will store its latest version
+						// if it can still be allocated, and retry the allocation with another GameServer
+						c.loggerForGameServerKey(res.gs.ObjectMeta.Namespace + "/" + res.gs.ObjectMeta.Name).WithError(err).Debug("allocated gameserver has changed")
+						res.err = ErrConflictInGameServerSelection
+					} else if err != nil {
+						key, _ := cache.MetaNamespaceKeyFunc(res.gs)
 						// since we could not allocate, we should put it back
-						c.readyGameServers.Store(key, gs)
+						if reallocation {
+							c.allocatedGameServers.Store(key, res.gs)
+						} else {
+							c.readyGameServers.Store(key, res.gs)
+						}
 						res.err = errors.Wrap(err, "error updating allocated gameserver")
 					} else {
 						res.gs = gs
-						c.recorder.Event(res.gs, corev1.EventTypeNormal, string(res.gs.Status.State), "Allocated")
+						if reallocation {
+							// the update may not have changed anything, so don't wait on the informer
+							// to make this GameServer available for re-allocation again
+							key, _ := cache.MetaNamespaceKeyFunc(gs)
+							c.allocatedGameServers.Store(key, gs)
+						}
+						c.recorder.Event(res.gs, corev1.EventTypeNormal, string(res.gs.Status.State), c.allocatedEventMessage(res.request.ctx))
+						c.recordAllocation(res.request.ctx, res.gs)
 					}
 
 					res.request.response <- res
@@ -673,19 +961,21 @@ func (c *Controller) allocationUpdateWorkers(workerCount int) chan<- response {
 // listSortedReadyGameServers returns a list of the cache ready gameservers
 // sorted by most allocated to least
 func (c *Controller) listSortedReadyGameServers() []*stablev1alpha1.GameServer {
-	length := c.readyGameServers.Len()
-	if length == 0 {
-		return []*stablev1alpha1.GameServer{}
+	return c.listSortedGameServers(&c.readyGameServers)
+}
+
+// listSortedGameServers returns a list of the gameservers in the given cache
+// sorted by most allocated to least. GameServers that sort equally are in key order,
+// so the same cache contents always result in the same list.
+func (c *Controller) listSortedGameServers(entry *gameServerCacheEntry) []*stablev1alpha1.GameServer {
+	list, _ := entry.List(0, "")
+	if len(list) == 0 {
+		return list
 	}
 
-	list := make([]*stablev1alpha1.GameServer, 0, length)
-	c.readyGameServers.Range(func(_ string, gs *stablev1alpha1.GameServer) bool {
-		list = append(list, gs)
-		return true
-	})
 	counts := c.counter.Counts()
 
-	sort.Slice(list, func(i, j int) bool {
+	sort.SliceStable(list, func(i, j int) bool {
 		gs1 := list[i]
 		gs2 := list[j]
 
@@ -699,10 +989,11 @@ func (c *Controller) listSortedReadyGameServers() []*stablev1alpha1.GameServer {
 			return true
 		}
 
-		if c1.Allocated > c2.Allocated {
+		// Reserved gameservers fill up a node in the same way as Allocated ones
+		if c1.Allocated+c1.Reserved > c2.Allocated+c2.Reserved {
 			return true
 		}
-		if c1.Allocated < c2.Allocated {
+		if c1.Allocated+c1.Reserved < c2.Allocated+c2.Reserved {
 			return false
 		}
 
@@ -722,8 +1013,8 @@ func (c *Controller) listSortedReadyGameServers() []*stablev1alpha1.GameServer {
 	return list
 }
 
-// patch the labels and annotations of an allocated GameServer with metadata from a GameServerAllocation
-func (c *Controller) patchMetadata(gs *stablev1alpha1.GameServer, fam allocationv1.MetaPatch) {
+// PatchMetadata patches the labels and annotations of an allocated GameServer with metadata from a GameServerAllocation
+func PatchMetadata(gs *stablev1alpha1.GameServer, fam allocationv1.MetaPatch) {
 	// patch ObjectMeta labels
 	if fam.Labels != nil {
 		if gs.ObjectMeta.Labels == nil {
@@ -752,7 +1043,7 @@ func (c *Controller) syncGameServers(key string) error {
 	return c.syncReadyGSServerCache()
 }
 
-// syncReadyGSServerCache syncs the gameserver cache and updates the local cache for any changes.
+// syncReadyGSServerCache syncs the gameserver cache and updates the local Ready and Allocated caches for any changes.
 func (c *Controller) syncReadyGSServerCache() error {
 	c.baseLogger.Info("Wait for cache sync")
 	if !cache.WaitForCacheSync(c.stop, c.gameServerSynced) {
@@ -773,9 +1064,46 @@ func (c *Controller) syncReadyGSServerCache() error {
 		}
 	}
 
+	refreshGameServerCache(&c.readyGameServers, stablev1alpha1.GameServerStateReady, currGameservers)
+	refreshGameServerCache(&c.allocatedGameServers, stablev1alpha1.GameServerStateAllocated, currGameservers)
+
+	return nil
+}
+
+// recordCacheMetrics records the number of GameServers per fleet in the Ready and Allocated caches,
+// so that they can be compared with the number of GameServers that are actually Ready and Allocated.
+// this is not meant to be called concurrently
+func (c *Controller) recordCacheMetrics() {
+	countGameServerCache(&c.readyGameServers, c.readyCacheCounts)
+	countGameServerCache(&c.allocatedGameServers, c.allocatedCacheCounts)
+
+	for fleet, count := range c.readyCacheCounts {
+		metrics.RecordAllocationCacheCount("ready", fleet, count)
+	}
+	for fleet, count := range c.allocatedCacheCounts {
+		metrics.RecordAllocationCacheCount("allocated", fleet, count)
+	}
+}
+
+// countGameServerCache counts the GameServers in the cache entry per fleet name into counts.
+// Fleets that are no longer in the cache are kept with a count of zero, as a gauge can't be removed,
+// and would otherwise keep reporting its last value.
+func countGameServerCache(entry *gameServerCacheEntry, counts map[string]int64) {
+	for fleet := range counts {
+		counts[fleet] = 0
+	}
+	entry.Range(func(_ string, gs *stablev1alpha1.GameServer) bool {
+		counts[gs.ObjectMeta.Labels[stablev1alpha1.FleetNameLabel]]++
+		return true
+	})
+}
+
+// refreshGameServerCache updates the cache entry with the GameServers in currGameservers
+// that are in the given state and not being deleted, and removes everything else.
+func refreshGameServerCache(entry *gameServerCacheEntry, state stablev1alpha1.GameServerState, currGameservers map[string]*stablev1alpha1.GameServer) {
 	// first remove the gameservers are not in the list anymore
 	tobeDeletedGSInCache := make([]string, 0)
-	c.readyGameServers.Range(func(key string, gs *stablev1alpha1.GameServer) bool {
+	entry.Range(func(key string, gs *stablev1alpha1.GameServer) bool {
 		if _, ok := currGameservers[key]; !ok {
 			tobeDeletedGSInCache = append(tobeDeletedGSInCache, key)
 		}
@@ -783,23 +1111,21 @@ func (c *Controller) syncReadyGSServerCache() error {
 	})
 
 	for _, staleGSKey := range tobeDeletedGSInCache {
-		c.readyGameServers.Delete(staleGSKey)
+		entry.Delete(staleGSKey)
 	}
 
 	// refresh the cache of possible allocatable GameServers
 	for key, gs := range currGameservers {
-		if gsCache, ok := c.readyGameServers.Load(key); ok {
-			if !(gs.DeletionTimestamp.IsZero() && gs.Status.State == stablev1alpha1.GameServerStateReady) {
-				c.readyGameServers.Delete(key)
+		if gsCache, ok := entry.Load(key); ok {
+			if !(gs.DeletionTimestamp.IsZero() && gs.Status.State == state) {
+				entry.Delete(key)
 			} else if gs.ObjectMeta.ResourceVersion != gsCache.ObjectMeta.ResourceVersion {
-				c.readyGameServers.Store(key, gs)
+				entry.Store(key, gs)
 			}
-		} else if gs.DeletionTimestamp.IsZero() && gs.Status.State == stablev1alpha1.GameServerStateReady {
-			c.readyGameServers.Store(key, gs)
+		} else if gs.DeletionTimestamp.IsZero() && gs.Status.State == state {
+			entry.Store(key, gs)
 		}
 	}
-
-	return nil
 }
 
 // getKey extract the key of gameserver object
@@ -825,6 +1151,9 @@ func Retry(backoff wait.Backoff, fn func() error) error {
 			return true, nil
 		case err == ErrNoGameServerReady:
 			return true, err
+		case err == context.Canceled || err == context.DeadlineExceeded:
+			// there is no one waiting on the result anymore
+			return true, err
 		default:
 			lastConflictErr = err
 			return false, nil
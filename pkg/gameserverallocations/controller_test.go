@@ -16,6 +16,7 @@ package gameserverallocations
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -38,6 +39,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -116,12 +118,28 @@ func TestControllerAllocationHandler(t *testing.T) {
 		rec := httptest.NewRecorder()
 		assert.NoError(t, err)
 
-		err = c.allocationHandler(rec, r, "default")
+		err = apiserver.Chain(c.allocationHandler, c.allocationMiddlewares()...)(rec, r, "default")
 		assert.NoError(t, err)
 
 		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
 	})
 
+	t.Run("rate limited", func(t *testing.T) {
+		c, _ := newFakeController()
+		c.rateLimit = 1
+		handler := apiserver.Chain(func(_ http.ResponseWriter, _ *http.Request, _ string) error {
+			return nil
+		}, c.allocationMiddlewares()...)
+
+		rec := httptest.NewRecorder()
+		assert.NoError(t, handler(rec, httptest.NewRequest(http.MethodPost, "/", nil), "default"))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = httptest.NewRecorder()
+		assert.NoError(t, handler(rec, httptest.NewRequest(http.MethodPost, "/", nil), "default"))
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	})
+
 	t.Run("invalid gameserverallocation", func(t *testing.T) {
 		c, _ := newFakeController()
 		gsa := &allocationv1.GameServerAllocation{
@@ -146,6 +164,30 @@ func TestControllerAllocationHandler(t *testing.T) {
 
 		assert.Equal(t, metav1.StatusReasonInvalid, s.Reason)
 	})
+
+	t.Run("cache syncing", func(t *testing.T) {
+		c, _ := newFakeController()
+		gsa := &allocationv1.GameServerAllocation{
+			Spec: allocationv1.GameServerAllocationSpec{
+				Required: metav1.LabelSelector{MatchLabels: map[string]string{stablev1alpha1.FleetNameLabel: "fleet"}},
+			}}
+		r, err := createRequest(gsa)
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		err = c.allocationHandler(rec, r, "default")
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+		s := &metav1.Status{}
+		err = json.NewDecoder(rec.Body).Decode(s)
+		assert.NoError(t, err)
+
+		assert.Equal(t, metav1.StatusReasonServiceUnavailable, s.Reason)
+		assert.Equal(t, ErrCacheSyncing.Error(), s.Message)
+		assert.Equal(t, int32(1), s.Details.RetryAfterSeconds)
+	})
 }
 
 func TestControllerAllocate(t *testing.T) {
@@ -195,7 +237,7 @@ func TestControllerAllocate(t *testing.T) {
 		}}
 	gsa.ApplyDefaults()
 
-	gs, err := c.allocate(&gsa)
+	gs, err := c.allocate(context.Background(), &gsa)
 	assert.Nil(t, err)
 	assert.Equal(t, stablev1alpha1.GameServerStateAllocated, gs.Status.State)
 	assert.True(t, updated)
@@ -211,19 +253,19 @@ func TestControllerAllocate(t *testing.T) {
 	}
 
 	updated = false
-	gs, err = c.allocate(&gsa)
+	gs, err = c.allocate(context.Background(), &gsa)
 	assert.Nil(t, err)
 	assert.Equal(t, stablev1alpha1.GameServerStateAllocated, gs.Status.State)
 	assert.True(t, updated)
 
 	updated = false
-	gs, err = c.allocate(&gsa)
+	gs, err = c.allocate(context.Background(), &gsa)
 	assert.Nil(t, err)
 	assert.Equal(t, stablev1alpha1.GameServerStateAllocated, gs.Status.State)
 	assert.True(t, updated)
 
 	updated = false
-	_, err = c.allocate(&gsa)
+	_, err = c.allocate(context.Background(), &gsa)
 	assert.NotNil(t, err)
 	assert.Equal(t, ErrNoGameServerReady, err)
 	assert.False(t, updated)
@@ -278,27 +320,27 @@ func TestControllerAllocatePriority(t *testing.T) {
 
 	run(t, "packed", func(t *testing.T, c *Controller, gas *allocationv1.GameServerAllocation) {
 		// priority should be node1, then node2
-		gs1, err := c.allocate(gas)
+		gs1, err := c.allocate(context.Background(), gas)
 		assert.NoError(t, err)
 		assert.Equal(t, n1, gs1.Status.NodeName)
 
-		gs2, err := c.allocate(gas)
+		gs2, err := c.allocate(context.Background(), gas)
 		assert.NoError(t, err)
 		assert.Equal(t, n1, gs2.Status.NodeName)
 		assert.NotEqual(t, gs1.ObjectMeta.Name, gs2.ObjectMeta.Name)
 
-		gs3, err := c.allocate(gas)
+		gs3, err := c.allocate(context.Background(), gas)
 		assert.NoError(t, err)
 		assert.Equal(t, n1, gs3.Status.NodeName)
 		assert.NotContains(t, []string{gs1.ObjectMeta.Name, gs2.ObjectMeta.Name}, gs3.ObjectMeta.Name)
 
-		gs4, err := c.allocate(gas)
+		gs4, err := c.allocate(context.Background(), gas)
 		assert.NoError(t, err)
 		assert.Equal(t, n2, gs4.Status.NodeName)
 		assert.NotContains(t, []string{gs1.ObjectMeta.Name, gs2.ObjectMeta.Name, gs3.ObjectMeta.Name}, gs4.ObjectMeta.Name)
 
 		// should have none left
-		_, err = c.allocate(gas)
+		_, err = c.allocate(context.Background(), gas)
 		assert.Equal(t, err, ErrNoGameServerReady)
 	})
 
@@ -309,23 +351,23 @@ func TestControllerAllocatePriority(t *testing.T) {
 
 		// distributed is randomised, so no set pattern
 
-		gs1, err := c.allocate(gas)
+		gs1, err := c.allocate(context.Background(), gas)
 		assert.NoError(t, err)
 
-		gs2, err := c.allocate(gas)
+		gs2, err := c.allocate(context.Background(), gas)
 		assert.NoError(t, err)
 		assert.NotEqual(t, gs1.ObjectMeta.Name, gs2.ObjectMeta.Name)
 
-		gs3, err := c.allocate(gas)
+		gs3, err := c.allocate(context.Background(), gas)
 		assert.NoError(t, err)
 		assert.NotContains(t, []string{gs1.ObjectMeta.Name, gs2.ObjectMeta.Name}, gs3.ObjectMeta.Name)
 
-		gs4, err := c.allocate(gas)
+		gs4, err := c.allocate(context.Background(), gas)
 		assert.NoError(t, err)
 		assert.NotContains(t, []string{gs1.ObjectMeta.Name, gs2.ObjectMeta.Name, gs3.ObjectMeta.Name}, gs4.ObjectMeta.Name)
 
 		// should have none left
-		_, err = c.allocate(gas)
+		_, err = c.allocate(context.Background(), gas)
 		assert.Equal(t, err, ErrNoGameServerReady)
 	})
 }
@@ -371,11 +413,11 @@ func TestControllerRunLocalAllocations(t *testing.T) {
 		gsa.ApplyDefaults()
 
 		// line up 3 in a batch
-		j1 := request{gsa: gsa.DeepCopy(), response: make(chan response)}
+		j1 := request{ctx: context.Background(), gsa: gsa.DeepCopy(), response: make(chan response)}
 		c.pendingRequests <- j1
-		j2 := request{gsa: gsa.DeepCopy(), response: make(chan response)}
+		j2 := request{ctx: context.Background(), gsa: gsa.DeepCopy(), response: make(chan response)}
 		c.pendingRequests <- j2
-		j3 := request{gsa: gsa.DeepCopy(), response: make(chan response)}
+		j3 := request{ctx: context.Background(), gsa: gsa.DeepCopy(), response: make(chan response)}
 		c.pendingRequests <- j3
 
 		go c.runLocalAllocations(3)
@@ -404,6 +446,68 @@ func TestControllerRunLocalAllocations(t *testing.T) {
 		assert.Equal(t, 3, updateCount)
 	})
 
+	t.Run("re-allocation of allocated gameservers", func(t *testing.T) {
+		f, _, gsList := defaultFixtures(3)
+		gsList[1].Status.State = stablev1alpha1.GameServerStateAllocated
+		gsList[1].ObjectMeta.Labels["sessions"] = "1"
+
+		c, m := newFakeController()
+		m.AgonesClient.AddReactor("list", "gameservers", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+			return true, &stablev1alpha1.GameServerList{Items: gsList}, nil
+		})
+		m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+			uo := action.(k8stesting.UpdateAction)
+			gs := uo.GetObject().(*stablev1alpha1.GameServer)
+
+			return true, gs, nil
+		})
+
+		stop, cancel := agtesting.StartInformers(m, c.gameServerSynced)
+		defer cancel()
+
+		// This call initializes the cache
+		err := c.syncReadyGSServerCache()
+		assert.Nil(t, err)
+
+		err = c.counter.Run(0, stop)
+		assert.Nil(t, err)
+
+		gsa := &allocationv1.GameServerAllocation{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: defaultNs,
+			},
+			Spec: allocationv1.GameServerAllocationSpec{
+				Required:  metav1.LabelSelector{MatchLabels: map[string]string{stablev1alpha1.FleetNameLabel: f.ObjectMeta.Name}},
+				Allocated: &metav1.LabelSelector{MatchLabels: map[string]string{"sessions": "1"}},
+				MetaPatch: allocationv1.MetaPatch{Labels: map[string]string{"sessions": "2"}},
+			}}
+		gsa.ApplyDefaults()
+
+		j1 := request{ctx: context.Background(), gsa: gsa.DeepCopy(), response: make(chan response)}
+		c.pendingRequests <- j1
+
+		go c.runLocalAllocations(3)
+
+		res1 := <-j1.response
+		assert.NoError(t, res1.err)
+		if assert.NotNil(t, res1.gs) {
+			assert.Equal(t, gsList[1].ObjectMeta.Name, res1.gs.ObjectMeta.Name)
+			assert.Equal(t, stablev1alpha1.GameServerStateAllocated, res1.gs.Status.State)
+			assert.Equal(t, "2", res1.gs.ObjectMeta.Labels["sessions"])
+		}
+
+		// the label no longer matches, so a Ready gameserver should be allocated
+		j2 := request{ctx: context.Background(), gsa: gsa.DeepCopy(), response: make(chan response)}
+		c.pendingRequests <- j2
+
+		res2 := <-j2.response
+		assert.NoError(t, res2.err)
+		if assert.NotNil(t, res2.gs) {
+			assert.NotEqual(t, gsList[1].ObjectMeta.Name, res2.gs.ObjectMeta.Name)
+			assert.Equal(t, stablev1alpha1.GameServerStateAllocated, res2.gs.Status.State)
+		}
+	})
+
 	t.Run("no gameservers", func(t *testing.T) {
 		c, m := newFakeController()
 		stop, cancel := agtesting.StartInformers(m, c.gameServerSynced)
@@ -426,7 +530,7 @@ func TestControllerRunLocalAllocations(t *testing.T) {
 			}}
 		gsa.ApplyDefaults()
 
-		j1 := request{gsa: gsa.DeepCopy(), response: make(chan response)}
+		j1 := request{ctx: context.Background(), gsa: gsa.DeepCopy(), response: make(chan response)}
 		c.pendingRequests <- j1
 
 		go c.runLocalAllocations(3)
@@ -436,6 +540,111 @@ func TestControllerRunLocalAllocations(t *testing.T) {
 		assert.Error(t, res1.err)
 		assert.Equal(t, ErrNoGameServerReady, res1.err)
 	})
+
+	t.Run("abandoned request", func(t *testing.T) {
+		f, _, gsList := defaultFixtures(1)
+		c, m := newFakeController()
+		m.AgonesClient.AddReactor("list", "gameservers", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+			return true, &stablev1alpha1.GameServerList{Items: gsList}, nil
+		})
+		m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+			uo := action.(k8stesting.UpdateAction)
+			gs := uo.GetObject().(*stablev1alpha1.GameServer)
+			return true, gs, nil
+		})
+
+		stop, cancel := agtesting.StartInformers(m, c.gameServerSynced)
+		defer cancel()
+
+		// This call initializes the cache
+		err := c.syncReadyGSServerCache()
+		assert.Nil(t, err)
+
+		err = c.counter.Run(0, stop)
+		assert.Nil(t, err)
+
+		gsa := &allocationv1.GameServerAllocation{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: defaultNs,
+			},
+			Spec: allocationv1.GameServerAllocationSpec{
+				Required: metav1.LabelSelector{MatchLabels: map[string]string{stablev1alpha1.FleetNameLabel: f.ObjectMeta.Name}},
+			}}
+		gsa.ApplyDefaults()
+
+		ctx, abandon := context.WithCancel(context.Background())
+		abandon()
+		j1 := request{ctx: ctx, gsa: gsa.DeepCopy(), response: make(chan response, 1)}
+		c.pendingRequests <- j1
+		j2 := request{ctx: context.Background(), gsa: gsa.DeepCopy(), response: make(chan response)}
+		c.pendingRequests <- j2
+
+		go c.runLocalAllocations(3)
+
+		res1 := <-j1.response
+		assert.Nil(t, res1.gs)
+		assert.Equal(t, context.Canceled, res1.err)
+
+		// the only GameServer was not taken by the abandoned request
+		res2 := <-j2.response
+		assert.NoError(t, res2.err)
+		if assert.NotNil(t, res2.gs) {
+			assert.Equal(t, gsList[0].ObjectMeta.Name, res2.gs.ObjectMeta.Name)
+		}
+
+		_, err = c.allocate(ctx, gsa.DeepCopy())
+		assert.Equal(t, context.Canceled, err)
+	})
+}
+
+func TestControllerSetOwners(t *testing.T) {
+	t.Parallel()
+
+	f, gsSet, gsList := defaultFixtures(2)
+	f.ObjectMeta.Labels = map[string]string{"team": "blue"}
+	gsSet.ObjectMeta.Labels = map[string]string{stablev1alpha1.FleetNameLabel: f.ObjectMeta.Name, "build": "42"}
+	c, m := newFakeController()
+
+	m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		return true, &stablev1alpha1.FleetList{Items: []stablev1alpha1.Fleet{*f}}, nil
+	})
+	m.AgonesClient.AddReactor("list", "gameserversets", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		return true, &stablev1alpha1.GameServerSetList{Items: []stablev1alpha1.GameServerSet{*gsSet}}, nil
+	})
+
+	_, cancel := agtesting.StartInformers(m, c.fleetSynced, c.gameServerSetSynced)
+	defer cancel()
+
+	gsa := &allocationv1.GameServerAllocation{}
+	c.setOwners(gsa, &gsList[0])
+	assert.Equal(t, &allocationv1.GameServerAllocationOwner{Name: f.ObjectMeta.Name, Labels: f.ObjectMeta.Labels}, gsa.Status.Fleet)
+	assert.Equal(t, &allocationv1.GameServerAllocationOwner{Name: gsSet.ObjectMeta.Name, Labels: gsSet.ObjectMeta.Labels}, gsa.Status.GameServerSet)
+
+	// the labels are not shared with the caches
+	gsa.Status.Fleet.Labels["team"] = "red"
+	gsa.Status.GameServerSet.Labels["build"] = "43"
+	cached, err := c.fleetLister.Fleets(f.ObjectMeta.Namespace).Get(f.ObjectMeta.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, "blue", cached.ObjectMeta.Labels["team"])
+	cachedGSSet, err := c.gameServerSetLister.GameServerSets(gsSet.ObjectMeta.Namespace).Get(gsSet.ObjectMeta.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, "42", cachedGSSet.ObjectMeta.Labels["build"])
+
+	// deleted owners only have their names
+	gs := gsList[1].DeepCopy()
+	gs.ObjectMeta.Labels[stablev1alpha1.FleetNameLabel] = "deleted-fleet"
+	gs.ObjectMeta.Labels[stablev1alpha1.GameServerSetGameServerLabel] = "deleted-gsset"
+	gsa = &allocationv1.GameServerAllocation{}
+	c.setOwners(gsa, gs)
+	assert.Equal(t, &allocationv1.GameServerAllocationOwner{Name: "deleted-fleet"}, gsa.Status.Fleet)
+	assert.Equal(t, &allocationv1.GameServerAllocationOwner{Name: "deleted-gsset"}, gsa.Status.GameServerSet)
+
+	// GameServers without owners
+	gs.ObjectMeta.Labels = nil
+	gsa = &allocationv1.GameServerAllocation{}
+	c.setOwners(gsa, gs)
+	assert.Nil(t, gsa.Status.Fleet)
+	assert.Nil(t, gsa.Status.GameServerSet)
 }
 
 func TestAllocationApiResource(t *testing.T) {
@@ -545,6 +754,27 @@ func TestControllerRunCacheSync(t *testing.T) {
 	assertCacheEntries(0)
 }
 
+func TestCountGameServerCache(t *testing.T) {
+	t.Parallel()
+
+	var entry gameServerCacheEntry
+	entry.Store("default/gs1", &stablev1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "gs1", Namespace: "default",
+		Labels: map[string]string{stablev1alpha1.FleetNameLabel: "fleet-a"}}})
+	entry.Store("default/gs2", &stablev1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "gs2", Namespace: "default",
+		Labels: map[string]string{stablev1alpha1.FleetNameLabel: "fleet-a"}}})
+	entry.Store("default/gs3", &stablev1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "gs3", Namespace: "default"}})
+
+	counts := map[string]int64{}
+	countGameServerCache(&entry, counts)
+	assert.Equal(t, map[string]int64{"fleet-a": 2, "": 1}, counts)
+
+	// fleets that have left the cache are zeroed, rather than removed
+	entry.Delete("default/gs1")
+	entry.Delete("default/gs2")
+	countGameServerCache(&entry, counts)
+	assert.Equal(t, map[string]int64{"fleet-a": 0, "": 1}, counts)
+}
+
 func TestGetRandomlySelectedGS(t *testing.T) {
 	c, _ := newFakeController()
 	c.topNGameServerCount = 5
@@ -589,6 +819,7 @@ func TestControllerAllocationUpdateWorkers(t *testing.T) {
 		}
 		r := response{
 			request: request{
+				ctx:      context.Background(),
 				gsa:      &allocationv1.GameServerAllocation{},
 				response: make(chan response),
 			},
@@ -628,6 +859,7 @@ func TestControllerAllocationUpdateWorkers(t *testing.T) {
 		}
 		r = response{
 			request: request{
+				ctx:      context.Background(),
 				gsa:      &allocationv1.GameServerAllocation{},
 				response: make(chan response),
 			},
@@ -663,6 +895,7 @@ func TestControllerAllocationUpdateWorkers(t *testing.T) {
 
 		r := response{
 			request: request{
+				ctx:      context.Background(),
 				gsa:      &allocationv1.GameServerAllocation{},
 				response: make(chan response),
 			},
@@ -698,6 +931,89 @@ func TestControllerAllocationUpdateWorkers(t *testing.T) {
 		assert.True(t, ok)
 		assert.Equal(t, gs1.ObjectMeta.Name, cached.ObjectMeta.Name)
 	})
+
+	t.Run("conflict on update", func(t *testing.T) {
+		c, m := newFakeController()
+
+		gs1 := &stablev1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: "gs1", ResourceVersion: "1"},
+		}
+		key, err := cache.MetaNamespaceKeyFunc(gs1)
+		assert.NoError(t, err)
+
+		r := response{
+			request: request{
+				ctx:      context.Background(),
+				gsa:      &allocationv1.GameServerAllocation{},
+				response: make(chan response),
+			},
+			gs: gs1,
+		}
+
+		// e.g. another replica of the controller has already allocated it
+		m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+			return true, &stablev1alpha1.GameServer{}, k8serrors.NewConflict(stablev1alpha1.Resource("gameservers"), gs1.ObjectMeta.Name, errors.New("the object has been modified"))
+		})
+
+		updateQueue := c.allocationUpdateWorkers(1)
+
+		go func() {
+			updateQueue <- r
+		}()
+
+		r = <-r.request.response
+
+		assert.Equal(t, ErrConflictInGameServerSelection, r.err)
+		agtesting.AssertNoEvent(t, m.FakeRecorder.Events)
+
+		// the out of date GameServer is not put back
+		_, ok := c.readyGameServers.Load(key)
+		assert.False(t, ok)
+	})
+
+	t.Run("abandoned request", func(t *testing.T) {
+		c, m := newFakeController()
+
+		updated := false
+		gs1 := &stablev1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: "gs1"},
+		}
+		key, err := cache.MetaNamespaceKeyFunc(gs1)
+		assert.NoError(t, err)
+
+		ctx, abandon := context.WithCancel(context.Background())
+		abandon()
+		r := response{
+			request: request{
+				ctx:      ctx,
+				gsa:      &allocationv1.GameServerAllocation{},
+				response: make(chan response),
+			},
+			gs: gs1,
+		}
+
+		m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+			updated = true
+			return true, nil, nil
+		})
+
+		updateQueue := c.allocationUpdateWorkers(1)
+
+		go func() {
+			updateQueue <- r
+		}()
+
+		r = <-r.request.response
+
+		assert.False(t, updated)
+		assert.Equal(t, context.Canceled, r.err)
+		assert.Nil(t, r.gs)
+		agtesting.AssertNoEvent(t, m.FakeRecorder.Events)
+
+		cached, ok := c.readyGameServers.Load(key)
+		assert.True(t, ok)
+		assert.Equal(t, gs1.ObjectMeta.Name, cached.ObjectMeta.Name)
+	})
 }
 
 func TestControllerListSortedReadyGameServers(t *testing.T) {
@@ -1279,7 +1595,7 @@ func newFakeController() (*Controller, agtesting.Mocks) {
 	m.Mux = http.NewServeMux()
 	counter := gameservers.NewPerNodeCounter(m.KubeInformerFactory, m.AgonesInformerFactory)
 	api := apiserver.NewAPIServer(m.Mux)
-	c := NewController(api, healthcheck.NewHandler(), counter, 1, m.KubeClient, m.KubeInformerFactory, m.AgonesClient, m.AgonesInformerFactory)
+	c := NewController(api, healthcheck.NewHandler(), counter, 1, 0, false, 0, nil, m.KubeClient, m.KubeInformerFactory, m.AgonesClient, m.AgonesInformerFactory)
 	c.recorder = m.FakeRecorder
 	return c, m
 }
@@ -0,0 +1,55 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserverallocations
+
+import (
+	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
+	stablev1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// FailureRecorder records the allocations from Fleets that failed because they had no Ready GameServers
+type FailureRecorder interface {
+	// RecordFailure records a failed allocation from the Fleet
+	RecordFailure(namespace, fleetName string)
+}
+
+// recordAllocationFailure records a failure against each of the Fleets in the namespace of gsa
+// whose GameServers match its required selector, when it could not find a Ready GameServer
+func (c *Controller) recordAllocationFailure(gsa *allocationv1.GameServerAllocation) {
+	if c.failureRecorder == nil {
+		return
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&gsa.Spec.Required)
+	if err != nil {
+		return
+	}
+	fleets, err := c.fleetLister.Fleets(gsa.ObjectMeta.Namespace).List(labels.Everything())
+	if err != nil {
+		c.loggerForGameServerAllocation(gsa).WithError(err).Warn("could not list fleets to record allocation failure")
+		return
+	}
+	for _, f := range fleets {
+		// the labels that the GameServers of the Fleet have
+		set := labels.Set{stablev1alpha1.FleetNameLabel: f.ObjectMeta.Name}
+		for k, v := range f.Spec.Template.ObjectMeta.Labels {
+			set[k] = v
+		}
+		if selector.Matches(set) {
+			c.failureRecorder.RecordFailure(f.ObjectMeta.Namespace, f.ObjectMeta.Name)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserverallocations
+
+import (
+	"testing"
+
+	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
+	stablev1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
+	agtesting "agones.dev/agones/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestControllerRecordAllocationFailure(t *testing.T) {
+	t.Parallel()
+
+	f, _, _ := defaultFixtures(0)
+	f.Spec.Template.ObjectMeta.Labels = map[string]string{"mode": "deathmatch"}
+	other := f.DeepCopy()
+	other.ObjectMeta.Name = "fleet-2"
+	other.Spec.Template.ObjectMeta.Labels = map[string]string{"mode": "ctf"}
+
+	c, m := newFakeController()
+	recorder := &fakeFailureRecorder{failures: map[string]int{}}
+	c.failureRecorder = recorder
+	m.AgonesClient.AddReactor("list", "fleets", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		return true, &stablev1alpha1.FleetList{Items: []stablev1alpha1.Fleet{*f, *other}}, nil
+	})
+	_, cancel := agtesting.StartInformers(m, c.fleetSynced)
+	defer cancel()
+
+	gsa := func(required map[string]string) *allocationv1.GameServerAllocation {
+		return &allocationv1.GameServerAllocation{ObjectMeta: metav1.ObjectMeta{Namespace: defaultNs},
+			Spec: allocationv1.GameServerAllocationSpec{Required: metav1.LabelSelector{MatchLabels: required}}}
+	}
+
+	c.recordAllocationFailure(gsa(map[string]string{stablev1alpha1.FleetNameLabel: f.ObjectMeta.Name}))
+	c.recordAllocationFailure(gsa(map[string]string{"mode": "deathmatch"}))
+	c.recordAllocationFailure(gsa(map[string]string{"mode": "racing"}))
+	c.recordAllocationFailure(gsa(nil))
+
+	assert.Equal(t, 3, recorder.failures[defaultNs+"/"+f.ObjectMeta.Name])
+	assert.Equal(t, 1, recorder.failures[defaultNs+"/"+other.ObjectMeta.Name])
+}
+
+// fakeFailureRecorder counts the failures recorded for each Fleet
+type fakeFailureRecorder struct {
+	failures map[string]int
+}
+
+func (f *fakeFailureRecorder) RecordFailure(namespace, fleetName string) {
+	f.failures[namespace+"/"+fleetName]++
+}
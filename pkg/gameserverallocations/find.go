@@ -30,11 +30,16 @@ import (
 // that the gameserver was found at in `list`, in case you want to remove it from the list
 // Packed: will search list from start to finish
 // Distributed: will search in a random order through the list
+// If the preferred selectors are weighted, the gameserver matching `required` with the highest total weight
+// of matching preferred selectors is chosen, with ties going to the first found.
+// Gameservers must also match the `counters` and `lists` selectors, and if `priorities` are set, they decide
+// between gameservers that match equally well, rather than the first found.
 // It is assumed that all gameservers passed in, are Ready and not being deleted, and are sorted in Packed priority order
 func findGameServerForAllocation(gsa *allocationv1.GameServerAllocation, list []*stablev1alpha1.GameServer) (*stablev1alpha1.GameServer, int, error) {
 	type result struct {
 		gs    *stablev1alpha1.GameServer
 		index int
+		score int32
 	}
 
 	requiredSelector, err := metav1.LabelSelectorAsSelector(&gsa.Spec.Required)
@@ -48,7 +53,14 @@ func findGameServerForAllocation(gsa *allocationv1.GameServerAllocation, list []
 	}
 
 	var required *result
+	var weighted *result
 	preferred := make([]*result, len(preferredSelector))
+	isWeighted := gsa.Spec.IsPreferredWeighted()
+
+	// better returns true if gs should replace the current result r
+	better := func(r *result, gs *stablev1alpha1.GameServer) bool {
+		return r == nil || isHigherPriority(gsa.Spec.Priorities, gs, r.gs)
+	}
 
 	var loop func(list []*stablev1alpha1.GameServer, f func(i int, gs *stablev1alpha1.GameServer))
 
@@ -87,21 +99,49 @@ func findGameServerForAllocation(gsa *allocationv1.GameServerAllocation, list []
 			return
 		}
 
+		if !gsa.Spec.MatchesCountersAndLists(gs) {
+			return
+		}
+
 		set := labels.Set(gs.ObjectMeta.Labels)
 
+		if isWeighted {
+			if !requiredSelector.Matches(set) {
+				return
+			}
+			if better(required, gs) {
+				required = &result{gs: gs, index: i}
+			}
+
+			var score int32
+			for j, sel := range preferredSelector {
+				if sel.Matches(set) {
+					score += gsa.Spec.Preferred[j].Weight
+				}
+			}
+			if score > 0 && (weighted == nil || score > weighted.score || (score == weighted.score && better(weighted, gs))) {
+				weighted = &result{gs: gs, index: i, score: score}
+			}
+			return
+		}
+
 		// first look at preferred
 		for j, sel := range preferredSelector {
-			if preferred[j] == nil && sel.Matches(set) {
+			if sel.Matches(set) && better(preferred[j], gs) {
 				preferred[j] = &result{gs: gs, index: i}
 			}
 		}
 
 		// then look at required
-		if required == nil && requiredSelector.Matches(set) {
+		if requiredSelector.Matches(set) && better(required, gs) {
 			required = &result{gs: gs, index: i}
 		}
 	})
 
+	if weighted != nil {
+		return weighted.gs, weighted.index, nil
+	}
+
 	for _, r := range preferred {
 		if r != nil {
 			return r.gs, r.index, nil
@@ -114,3 +154,101 @@ func findGameServerForAllocation(gsa *allocationv1.GameServerAllocation, list []
 
 	return required.gs, required.index, nil
 }
+
+// isHigherPriority returns true if a should be chosen over b, according to the priorities,
+// which are compared in order. A gameserver that has the Counter or List of a priority
+// is chosen over one that does not.
+func isHigherPriority(priorities []allocationv1.Priority, a, b *stablev1alpha1.GameServer) bool {
+	for _, p := range priorities {
+		aAvailable, aOk := p.Available(a)
+		bAvailable, bOk := p.Available(b)
+
+		if aOk != bOk {
+			return aOk
+		}
+		if aAvailable == bAvailable {
+			continue
+		}
+		if p.Order == allocationv1.PriorityOrderDescending {
+			return aAvailable > bAvailable
+		}
+		return aAvailable < bAvailable
+	}
+	return false
+}
+
+// findAllocatedGameServerForAllocation finds an Allocated gameserver that can be allocated again, by filtering
+// `list` by the `allocated` selector on the GameServerAllocation, and then applying the same preferred and required
+// selection as findGameServerForAllocation. The returned index is the index in `list`.
+// It is assumed that all gameservers passed in, are Allocated and not being deleted, and are sorted in Packed priority order
+func findAllocatedGameServerForAllocation(gsa *allocationv1.GameServerAllocation, list []*stablev1alpha1.GameServer) (*stablev1alpha1.GameServer, int, error) {
+	if gsa.Spec.Allocated == nil {
+		return nil, -1, ErrNoGameServerReady
+	}
+
+	allocatedSelector, err := metav1.LabelSelectorAsSelector(gsa.Spec.Allocated)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "could not convert GameServerAllocation allocated selector")
+	}
+
+	var filtered []*stablev1alpha1.GameServer
+	var indices []int
+	for i, gs := range list {
+		if allocatedSelector.Matches(labels.Set(gs.ObjectMeta.Labels)) {
+			filtered = append(filtered, gs)
+			indices = append(indices, i)
+		}
+	}
+
+	gs, index, err := findGameServerForAllocation(gsa, filtered)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	return gs, indices[index], nil
+}
+
+// findGameServerOnNodes finds a gameserver with `find`, out of the gameservers in `list` that run on a Node that
+// matches the `nodeSelector` of the GameServerAllocation, looking up the labels of each Node in `nodes`.
+// If there is no `nodeSelector`, all of `list` is searched. The returned index is the index in `list`.
+func findGameServerOnNodes(gsa *allocationv1.GameServerAllocation, list []*stablev1alpha1.GameServer, nodes *nodeLabelCache,
+	find func(*allocationv1.GameServerAllocation, []*stablev1alpha1.GameServer) (*stablev1alpha1.GameServer, int, error)) (*stablev1alpha1.GameServer, int, error) {
+	if gsa.Spec.NodeSelector == nil {
+		return find(gsa, list)
+	}
+
+	nodeSelector, err := metav1.LabelSelectorAsSelector(gsa.Spec.NodeSelector)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "could not convert GameServerAllocation node selector")
+	}
+
+	var filtered []*stablev1alpha1.GameServer
+	var indices []int
+	for i, gs := range list {
+		if nodeSelector.Matches(nodes.Labels(gs.Status.NodeName)) {
+			filtered = append(filtered, gs)
+			indices = append(indices, i)
+		}
+	}
+
+	gs, index, err := find(gsa, filtered)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	return gs, indices[index], nil
+}
+
+// FindGameServer finds the GameServer that the controller would allocate for the GameServerAllocation, out of
+// GameServers that run on Nodes without labels: one of `allocated` that matches its `allocated` selector,
+// ahead of one of `ready`. The returned index is the index in the list it was found in, which is `allocated`
+// if reallocated is true.
+func FindGameServer(gsa *allocationv1.GameServerAllocation, allocated, ready []*stablev1alpha1.GameServer) (gs *stablev1alpha1.GameServer, index int, reallocated bool, err error) {
+	var nodes nodeLabelCache
+	gs, index, err = findGameServerOnNodes(gsa, allocated, &nodes, findAllocatedGameServerForAllocation)
+	if err != ErrNoGameServerReady {
+		return gs, index, true, err
+	}
+	gs, index, err = findGameServerOnNodes(gsa, ready, &nodes, findGameServerForAllocation)
+	return gs, index, false, err
+}
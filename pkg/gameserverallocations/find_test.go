@@ -23,6 +23,7 @@ import (
 	stablev1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
 	agtesting "agones.dev/agones/pkg/testing"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	k8stesting "k8s.io/client-go/testing"
@@ -46,8 +47,8 @@ func TestFindGameServerForAllocationPacked(t *testing.T) {
 
 	n := metav1.Now()
 	prefGsa := gsa.DeepCopy()
-	prefGsa.Spec.Preferred = append(prefGsa.Spec.Preferred, metav1.LabelSelector{
-		MatchLabels: map[string]string{"preferred": "true"},
+	prefGsa.Spec.Preferred = append(prefGsa.Spec.Preferred, allocationv1.PreferredSelector{
+		LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"preferred": "true"}},
 	})
 
 	fixtures := map[string]struct {
@@ -253,3 +254,257 @@ func TestFindGameServerForAllocationDistributed(t *testing.T) {
 	assert.FailNow(t, "We should get a different gameserver by now")
 
 }
+
+func TestFindAllocatedGameServerForAllocation(t *testing.T) {
+	t.Parallel()
+
+	labels := map[string]string{"role": "gameserver"}
+	full := map[string]string{"role": "gameserver", "sessions": "full"}
+
+	gsa := &allocationv1.GameServerAllocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: defaultNs},
+		Spec: allocationv1.GameServerAllocationSpec{
+			Required: metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Scheduling: apis.Packed,
+		},
+	}
+
+	list := []*stablev1alpha1.GameServer{
+		{ObjectMeta: metav1.ObjectMeta{Name: "gs1", Namespace: defaultNs, Labels: full}, Status: stablev1alpha1.GameServerStatus{NodeName: "node1", State: stablev1alpha1.GameServerStateAllocated}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "gs2", Namespace: defaultNs, Labels: labels}, Status: stablev1alpha1.GameServerStatus{NodeName: "node1", State: stablev1alpha1.GameServerStateAllocated}},
+	}
+
+	// no allocated selector means no re-allocation
+	gs, _, err := findAllocatedGameServerForAllocation(gsa, list)
+	assert.Equal(t, ErrNoGameServerReady, err)
+	assert.Nil(t, gs)
+
+	gsa.Spec.Allocated = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "sessions", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"full"}}},
+	}
+	gs, index, err := findAllocatedGameServerForAllocation(gsa, list)
+	assert.NoError(t, err)
+	if assert.NotNil(t, gs) {
+		assert.Equal(t, "gs2", gs.ObjectMeta.Name)
+		assert.Equal(t, 1, index)
+		assert.Equal(t, gs, list[index])
+	}
+
+	list = list[:1]
+	gs, _, err = findAllocatedGameServerForAllocation(gsa, list)
+	assert.Equal(t, ErrNoGameServerReady, err)
+	assert.Nil(t, gs)
+}
+
+func TestFindGameServerOnNodes(t *testing.T) {
+	t.Parallel()
+
+	labels := map[string]string{"role": "gameserver"}
+	nodes := &nodeLabelCache{}
+	nodes.Store(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"zone": "a"}}})
+	nodes.Store(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2", Labels: map[string]string{"zone": "b"}}})
+
+	gsa := &allocationv1.GameServerAllocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: defaultNs},
+		Spec: allocationv1.GameServerAllocationSpec{
+			Required:   metav1.LabelSelector{MatchLabels: labels},
+			Scheduling: apis.Packed,
+		},
+	}
+
+	list := []*stablev1alpha1.GameServer{
+		{ObjectMeta: metav1.ObjectMeta{Name: "gs1", Namespace: defaultNs, Labels: labels}, Status: stablev1alpha1.GameServerStatus{NodeName: "node1", State: stablev1alpha1.GameServerStateReady}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "gs2", Namespace: defaultNs, Labels: labels}, Status: stablev1alpha1.GameServerStatus{NodeName: "node2", State: stablev1alpha1.GameServerStateReady}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "gs3", Namespace: defaultNs, Labels: labels}, Status: stablev1alpha1.GameServerStatus{NodeName: "node3", State: stablev1alpha1.GameServerStateReady}},
+	}
+
+	// no node selector searches the whole list
+	gs, index, err := findGameServerOnNodes(gsa, list, nodes, findGameServerForAllocation)
+	assert.NoError(t, err)
+	if assert.NotNil(t, gs) {
+		assert.Equal(t, "gs1", gs.ObjectMeta.Name)
+		assert.Equal(t, 0, index)
+	}
+
+	gsa.Spec.NodeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"zone": "b"}}
+	gs, index, err = findGameServerOnNodes(gsa, list, nodes, findGameServerForAllocation)
+	assert.NoError(t, err)
+	if assert.NotNil(t, gs) {
+		assert.Equal(t, "gs2", gs.ObjectMeta.Name)
+		assert.Equal(t, 1, index)
+		assert.Equal(t, gs, list[index])
+	}
+
+	// node3 isn't in the cache, so has no labels
+	gsa.Spec.NodeSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "zone", Operator: metav1.LabelSelectorOpDoesNotExist}},
+	}
+	gs, index, err = findGameServerOnNodes(gsa, list, nodes, findGameServerForAllocation)
+	assert.NoError(t, err)
+	if assert.NotNil(t, gs) {
+		assert.Equal(t, "gs3", gs.ObjectMeta.Name)
+		assert.Equal(t, 2, index)
+	}
+
+	gsa.Spec.NodeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"zone": "c"}}
+	gs, _, err = findGameServerOnNodes(gsa, list, nodes, findGameServerForAllocation)
+	assert.Equal(t, ErrNoGameServerReady, err)
+	assert.Nil(t, gs)
+}
+
+func TestFindGameServerForAllocationWeighted(t *testing.T) {
+	t.Parallel()
+
+	labels := map[string]string{"role": "gameserver"}
+	gsa := &allocationv1.GameServerAllocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: defaultNs},
+		Spec: allocationv1.GameServerAllocationSpec{
+			Required: metav1.LabelSelector{MatchLabels: labels},
+			Preferred: []allocationv1.PreferredSelector{
+				{LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"region": "us"}}, Weight: 10},
+				{LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"version": "1.2"}}, Weight: 1},
+			},
+			Scheduling: apis.Packed,
+		},
+	}
+
+	newGs := func(name string, extra map[string]string) *stablev1alpha1.GameServer {
+		l := map[string]string{"role": "gameserver"}
+		for k, v := range extra {
+			l[k] = v
+		}
+		return &stablev1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: defaultNs, Labels: l},
+			Status: stablev1alpha1.GameServerStatus{NodeName: "node1", State: stablev1alpha1.GameServerStateReady}}
+	}
+
+	list := []*stablev1alpha1.GameServer{
+		newGs("gs1", nil),
+		newGs("gs2", map[string]string{"version": "1.2"}),
+		newGs("gs3", map[string]string{"region": "us"}),
+		newGs("gs4", map[string]string{"region": "us", "version": "1.2"}),
+		{ObjectMeta: metav1.ObjectMeta{Name: "gs5", Namespace: defaultNs, Labels: map[string]string{"region": "us", "version": "1.2", "role": "other"}},
+			Status: stablev1alpha1.GameServerStatus{NodeName: "node1", State: stablev1alpha1.GameServerStateReady}},
+	}
+
+	// highest score, that also matches required
+	gs, index, err := findGameServerForAllocation(gsa, list)
+	assert.NoError(t, err)
+	assert.Equal(t, "gs4", gs.ObjectMeta.Name)
+	assert.Equal(t, 3, index)
+
+	// strong preference beats the weak preference
+	list = append(list[:index], list[index+1:]...)
+	gs, index, err = findGameServerForAllocation(gsa, list)
+	assert.NoError(t, err)
+	assert.Equal(t, "gs3", gs.ObjectMeta.Name)
+
+	list = append(list[:index], list[index+1:]...)
+	gs, index, err = findGameServerForAllocation(gsa, list)
+	assert.NoError(t, err)
+	assert.Equal(t, "gs2", gs.ObjectMeta.Name)
+
+	// no preferred match, so fall back to required
+	list = append(list[:index], list[index+1:]...)
+	gs, index, err = findGameServerForAllocation(gsa, list)
+	assert.NoError(t, err)
+	assert.Equal(t, "gs1", gs.ObjectMeta.Name)
+
+	list = append(list[:index], list[index+1:]...)
+	gs, _, err = findGameServerForAllocation(gsa, list)
+	assert.Equal(t, ErrNoGameServerReady, err)
+	assert.Nil(t, gs)
+}
+
+func TestFindGameServerForAllocationCountersAndLists(t *testing.T) {
+	t.Parallel()
+
+	newGs := func(name string, rooms int64, players []string) *stablev1alpha1.GameServer {
+		return &stablev1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: defaultNs},
+			Status: stablev1alpha1.GameServerStatus{NodeName: "node1", State: stablev1alpha1.GameServerStateReady,
+				Counters: map[string]stablev1alpha1.CounterStatus{"rooms": {Count: rooms, Capacity: 10}},
+				Lists:    map[string]stablev1alpha1.ListStatus{"players": {Capacity: 4, Values: players}},
+			}}
+	}
+
+	list := []*stablev1alpha1.GameServer{
+		newGs("gs1", 10, nil),
+		newGs("gs2", 2, []string{"a", "b", "c"}),
+		newGs("gs3", 8, []string{"a"}),
+		newGs("gs4", 5, nil),
+		{ObjectMeta: metav1.ObjectMeta{Name: "gs5", Namespace: defaultNs},
+			Status: stablev1alpha1.GameServerStatus{NodeName: "node1", State: stablev1alpha1.GameServerStateReady}},
+	}
+
+	fixtures := map[string]struct {
+		spec     allocationv1.GameServerAllocationSpec
+		expected string
+	}{
+		"no filters": {
+			spec:     allocationv1.GameServerAllocationSpec{},
+			expected: "gs1",
+		},
+		"counter min available": {
+			spec: allocationv1.GameServerAllocationSpec{
+				Counters: map[string]allocationv1.CounterSelector{"rooms": {MinAvailable: 1}},
+			},
+			expected: "gs2",
+		},
+		"counter max available": {
+			spec: allocationv1.GameServerAllocationSpec{
+				Counters: map[string]allocationv1.CounterSelector{"rooms": {MinAvailable: 1, MaxAvailable: 5}},
+			},
+			expected: "gs3",
+		},
+		"list contains value": {
+			spec: allocationv1.GameServerAllocationSpec{
+				Lists: map[string]allocationv1.ListSelector{"players": {ContainsValue: "a", MinAvailable: 2}},
+			},
+			expected: "gs3",
+		},
+		"ascending priority packs": {
+			spec: allocationv1.GameServerAllocationSpec{
+				Counters:   map[string]allocationv1.CounterSelector{"rooms": {MinAvailable: 1}},
+				Priorities: []allocationv1.Priority{{Type: allocationv1.PriorityTypeCounter, Key: "rooms", Order: allocationv1.PriorityOrderAscending}},
+			},
+			expected: "gs3",
+		},
+		"descending priority spreads": {
+			spec: allocationv1.GameServerAllocationSpec{
+				Priorities: []allocationv1.Priority{{Type: allocationv1.PriorityTypeList, Key: "players", Order: allocationv1.PriorityOrderDescending}},
+			},
+			expected: "gs1",
+		},
+		"priority without the counter": {
+			spec: allocationv1.GameServerAllocationSpec{
+				Priorities: []allocationv1.Priority{{Type: allocationv1.PriorityTypeCounter, Key: "missing"}},
+			},
+			expected: "gs1",
+		},
+		"no match": {
+			spec: allocationv1.GameServerAllocationSpec{
+				Counters: map[string]allocationv1.CounterSelector{"rooms": {MinAvailable: 9}},
+			},
+		},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			gsa := &allocationv1.GameServerAllocation{ObjectMeta: metav1.ObjectMeta{Namespace: defaultNs}, Spec: v.spec}
+			gsa.ApplyDefaults()
+			_, ok := gsa.Validate()
+			assert.True(t, ok)
+
+			gs, _, err := findGameServerForAllocation(gsa, list)
+			if v.expected == "" {
+				assert.Equal(t, ErrNoGameServerReady, err)
+				return
+			}
+			assert.NoError(t, err)
+			if assert.NotNil(t, gs) {
+				assert.Equal(t, v.expected, gs.ObjectMeta.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserverallocations
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+
+	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
+	"agones.dev/agones/pkg/util/apiserver"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// requestLogKey is the context key of the requestLog of an allocation request
+type requestLogKey struct{}
+
+// requestLog collects the details of an allocation request that has been
+// sampled for logging, while it is allocated
+type requestLog struct {
+	start    time.Time
+	attempts int
+}
+
+// withRequestLog returns a copy of ctx that carries rl
+func withRequestLog(ctx context.Context, rl *requestLog) context.Context {
+	return context.WithValue(ctx, requestLogKey{}, rl)
+}
+
+// requestLogFrom returns the requestLog carried by ctx, or nil if the
+// allocation request has not been sampled
+func requestLogFrom(ctx context.Context) *requestLog {
+	rl, _ := ctx.Value(requestLogKey{}).(*requestLog)
+	return rl
+}
+
+// attempt records an attempt to allocate a GameServer for the request.
+// Safe to call on a nil requestLog.
+func (rl *requestLog) attempt() {
+	if rl != nil {
+		rl.attempts++
+	}
+}
+
+// sampleAllocationRequest returns a context for an allocation request, that carries
+// a requestLog if the request is sampled for logging, along with the requestLog
+func (c *Controller) sampleAllocationRequest(ctx context.Context) (context.Context, *requestLog) {
+	if c.logSampleRate <= 0 || (c.logSampleRate < 1 && rand.Float64() >= c.logSampleRate) {
+		return ctx, nil
+	}
+	rl := &requestLog{start: time.Now()}
+	return withRequestLog(ctx, rl), rl
+}
+
+// sampleRequests is a middleware that samples allocation requests for logging, by adding
+// a requestLog to the context of each request that is sampled
+func (c *Controller) sampleRequests(next apiserver.CRDHandler) apiserver.CRDHandler {
+	return func(w http.ResponseWriter, r *http.Request, namespace string) error {
+		ctx, _ := c.sampleAllocationRequest(r.Context())
+		return next(w, r.WithContext(ctx), namespace)
+	}
+}
+
+// logAllocationRequest logs a summary of the selectors of gsa, the result of
+// allocating it, how long that took and how many times it was retried.
+// Does nothing if the request was not sampled.
+func (c *Controller) logAllocationRequest(rl *requestLog, gsa, out *allocationv1.GameServerAllocation, err error) {
+	if rl == nil {
+		return
+	}
+
+	retries := 0
+	if rl.attempts > 1 {
+		retries = rl.attempts - 1
+	}
+	fields := logrus.Fields{
+		"namespace":  gsa.ObjectMeta.Namespace,
+		"required":   metav1.FormatLabelSelector(&gsa.Spec.Required),
+		"preferred":  len(gsa.Spec.Preferred),
+		"scheduling": gsa.Spec.Scheduling,
+		"latency":    time.Since(rl.start).String(),
+		"retries":    retries,
+	}
+	if gsa.Spec.Allocated != nil {
+		fields["allocated"] = metav1.FormatLabelSelector(gsa.Spec.Allocated)
+	}
+	if gsa.Spec.NodeSelector != nil {
+		fields["nodeSelector"] = metav1.FormatLabelSelector(gsa.Spec.NodeSelector)
+	}
+	if gsa.Spec.MultiClusterSetting.Enabled {
+		fields["multiCluster"] = true
+	}
+
+	switch {
+	case err != nil:
+		fields["result"] = "Error"
+		fields["error"] = err.Error()
+	case out != nil:
+		fields["result"] = out.Status.State
+		if out.Status.GameServerName != "" {
+			fields["gameServer"] = out.Status.GameServerName
+		}
+	}
+
+	c.baseLogger.WithFields(fields).Info("allocation request")
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserverallocations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
+	"agones.dev/agones/pkg/util/apiserver"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestControllerSampleAllocationRequest(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newFakeController()
+	ctx, rl := c.sampleAllocationRequest(context.Background())
+	assert.Nil(t, rl)
+	assert.Nil(t, requestLogFrom(ctx))
+	// a request that isn't sampled has no attempts to record
+	requestLogFrom(ctx).attempt()
+
+	c.logSampleRate = 1
+	ctx, rl = c.sampleAllocationRequest(context.Background())
+	if assert.NotNil(t, rl) {
+		assert.Equal(t, rl, requestLogFrom(ctx))
+		requestLogFrom(ctx).attempt()
+		requestLogFrom(ctx).attempt()
+		assert.Equal(t, 2, rl.attempts)
+	}
+}
+
+func TestControllerLogAllocationRequest(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newFakeController()
+	buf := bytes.NewBuffer(nil)
+	logger := logrus.New()
+	logger.Out = buf
+	logger.Formatter = &logrus.JSONFormatter{}
+	c.baseLogger = logrus.NewEntry(logger)
+
+	gsa := &allocationv1.GameServerAllocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: defaultNs},
+		Spec: allocationv1.GameServerAllocationSpec{
+			Required: metav1.LabelSelector{MatchLabels: map[string]string{"fleet": "simple"}},
+		},
+	}
+	out := gsa.DeepCopy()
+	out.Status.State = allocationv1.GameServerAllocationAllocated
+	out.Status.GameServerName = "gs1"
+
+	// not sampled
+	c.logAllocationRequest(nil, gsa, out, nil)
+	assert.Equal(t, 0, buf.Len())
+
+	c.logAllocationRequest(&requestLog{attempts: 3}, gsa, out, nil)
+	entry := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "allocation request", entry["msg"])
+	assert.Equal(t, "fleet=simple", entry["required"])
+	assert.Equal(t, string(allocationv1.GameServerAllocationAllocated), entry["result"])
+	assert.Equal(t, "gs1", entry["gameServer"])
+	assert.Equal(t, float64(2), entry["retries"])
+	assert.Contains(t, entry, "latency")
+
+	t.Run("handler", func(t *testing.T) {
+		buf.Reset()
+		c.logSampleRate = 1
+
+		b := bytes.NewBuffer(nil)
+		assert.NoError(t, json.NewEncoder(b).Encode(gsa))
+		r, err := http.NewRequest(http.MethodPost, "/", b)
+		assert.NoError(t, err)
+		r.Header.Set("Content-Type", k8sruntime.ContentTypeJSON)
+
+		// the cache hasn't synced, so the allocation fails
+		err = apiserver.Chain(c.allocationHandler, c.allocationMiddlewares()...)(httptest.NewRecorder(), r, defaultNs)
+		assert.NoError(t, err)
+		// the handler logs other entries as well
+		var entry map[string]interface{}
+		for dec := json.NewDecoder(buf); dec.More(); {
+			e := map[string]interface{}{}
+			assert.NoError(t, dec.Decode(&e))
+			if e["msg"] == "allocation request" {
+				entry = e
+			}
+		}
+		assert.Equal(t, "Error", entry["result"])
+		assert.Equal(t, ErrCacheSyncing.Error(), entry["error"])
+		assert.Equal(t, float64(0), entry["retries"])
+	})
+}
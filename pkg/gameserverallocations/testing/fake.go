@@ -0,0 +1,200 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testing has a fake backend for the allocation API, for load and end to end tests
+package testing
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
+	stablev1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"agones.dev/agones/pkg/client/clientset/versioned"
+	agonesfake "agones.dev/agones/pkg/client/clientset/versioned/fake"
+	"agones.dev/agones/pkg/gameserverallocations"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	// fakeGameServersPerNode is how many of the synthetic GameServers of a FakeBackend share a node
+	fakeGameServersPerNode = 10
+	// fakeMinPort is the first port of the synthetic GameServers of a FakeBackend
+	fakeMinPort = 7000
+)
+
+// FakeBackend allocates from an in-memory, synthetic Fleet of Ready GameServers rather than a cluster,
+// with the same selection, metadata patching and responses as the allocation controller, so that
+// matchmakers can be load tested against the allocation API locally.
+type FakeBackend struct {
+	mu           sync.Mutex
+	fleetName    string
+	namespace    string
+	releaseAfter time.Duration
+	// ready starts out in Packed order, i.e. grouped by node, and released GameServers are added to its end
+	ready     []*stablev1alpha1.GameServer
+	allocated []*stablev1alpha1.GameServer
+}
+
+// NewFakeBackend returns a FakeBackend with a Fleet of size Ready GameServers in the namespace.
+// If releaseAfter is more than 0, Allocated GameServers go back to Ready that long after being
+// allocated, as if their game session had ended, so that the Fleet doesn't run out.
+func NewFakeBackend(fleetName, namespace string, size int, releaseAfter time.Duration) *FakeBackend {
+	f := &FakeBackend{
+		fleetName:    fleetName,
+		namespace:    namespace,
+		releaseAfter: releaseAfter,
+		ready:        make([]*stablev1alpha1.GameServer, 0, size),
+	}
+	for i := 0; i < size; i++ {
+		f.ready = append(f.ready, f.gameServer(i))
+	}
+	return f
+}
+
+// gameServer returns the i-th synthetic, Ready GameServer of the Fleet
+func (f *FakeBackend) gameServer(i int) *stablev1alpha1.GameServer {
+	node := i / fakeGameServersPerNode
+	address := fmt.Sprintf("10.%d.%d.%d", (node>>16)&0xff, (node>>8)&0xff, node&0xff)
+	return &stablev1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", f.fleetName, i),
+			Namespace: f.namespace,
+			Labels:    map[string]string{stablev1alpha1.FleetNameLabel: f.fleetName},
+		},
+		Status: stablev1alpha1.GameServerStatus{
+			State:     stablev1alpha1.GameServerStateReady,
+			Ports:     []stablev1alpha1.GameServerStatusPort{{Name: "default", Port: int32(fakeMinPort + i%fakeGameServersPerNode)}},
+			Address:   address,
+			Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: address}},
+			NodeName:  fmt.Sprintf("%s-node-%d", f.fleetName, node),
+		},
+	}
+}
+
+// Clientset returns an Agones clientset that creates GameServerAllocations through the FakeBackend
+func (f *FakeBackend) Clientset() versioned.Interface {
+	clientset := &agonesfake.Clientset{}
+	clientset.AddReactor("create", "gameserverallocations", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		gsa := action.(k8stesting.CreateAction).GetObject().(*allocationv1.GameServerAllocation).DeepCopy()
+		// as the API server would, allocate in the namespace of the request
+		gsa.ObjectMeta.Namespace = action.GetNamespace()
+		result, err := f.Allocate(gsa)
+		return true, result, err
+	})
+	return clientset
+}
+
+// Allocate allocates a GameServer for the GameServerAllocation, as the allocation controller would.
+// Multi-cluster settings are ignored, as there is only the one synthetic Fleet, and as its Nodes
+// have no labels, only GameServerAllocations without a node selector are allocated.
+func (f *FakeBackend) Allocate(gsa *allocationv1.GameServerAllocation) (*allocationv1.GameServerAllocation, error) {
+	gsa.ApplyDefaults()
+	if causes, ok := gsa.Validate(); !ok {
+		return nil, &k8serrors.StatusError{ErrStatus: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: fmt.Sprintf("GameServerAllocation is invalid: Invalid value: %#v", gsa),
+			Reason:  metav1.StatusReasonInvalid,
+			Details: &metav1.StatusDetails{
+				Kind:   "GameServerAllocation",
+				Group:  allocationv1.SchemeGroupVersion.Group,
+				Causes: causes,
+			},
+			Code: http.StatusUnprocessableEntity,
+		}}
+	}
+
+	gs, err := f.allocate(gsa)
+	if err == gameserverallocations.ErrNoGameServerReady {
+		gsa.Status.State = allocationv1.GameServerAllocationUnAllocated
+		return gsa, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	gsa.ObjectMeta.Name = gs.ObjectMeta.Name
+	gsa.Status.State = allocationv1.GameServerAllocationAllocated
+	gsa.Status.GameServerName = gs.ObjectMeta.Name
+	gsa.Status.Ports = gs.Status.Ports
+	gsa.Status.Address = gs.Status.Address
+	gsa.Status.Addresses = gs.Status.Addresses
+	gsa.Status.NodeName = gs.Status.NodeName
+	if gsa.Spec.IncludeOwners {
+		// the synthetic Fleet has no GameServerSet, or labels
+		gsa.Status.Fleet = &allocationv1.GameServerAllocationOwner{Name: f.fleetName}
+	}
+	return gsa, nil
+}
+
+// allocate finds a GameServer for the GameServerAllocation, re-allocating Allocated GameServers
+// that match its `allocated` selector ahead of Ready ones, and moves it to Allocated
+func (f *FakeBackend) allocate(gsa *allocationv1.GameServerAllocation) (*stablev1alpha1.GameServer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	gs, index, reallocated, err := gameserverallocations.FindGameServer(gsa, f.allocated, f.ready)
+	if err != nil {
+		return nil, err
+	}
+	if reallocated {
+		gameserverallocations.PatchMetadata(gs, gsa.Spec.MetaPatch)
+		return gs.DeepCopy(), nil
+	}
+	f.ready = append(f.ready[:index], f.ready[index+1:]...)
+
+	gameserverallocations.PatchMetadata(gs, gsa.Spec.MetaPatch)
+	gs.Status.State = stablev1alpha1.GameServerStateAllocated
+	f.allocated = append(f.allocated, gs)
+
+	if f.releaseAfter > 0 {
+		name := gs.ObjectMeta.Name
+		time.AfterFunc(f.releaseAfter, func() {
+			f.release(name)
+		})
+	}
+
+	return gs.DeepCopy(), nil
+}
+
+// release moves the named Allocated GameServer back to Ready, without the metadata
+// that was patched onto it when it was allocated
+func (f *FakeBackend) release(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, gs := range f.allocated {
+		if gs.ObjectMeta.Name == name {
+			f.allocated = append(f.allocated[:i], f.allocated[i+1:]...)
+			gs.ObjectMeta.Labels = map[string]string{stablev1alpha1.FleetNameLabel: f.fleetName}
+			gs.ObjectMeta.Annotations = nil
+			gs.Status.State = stablev1alpha1.GameServerStateReady
+			f.ready = append(f.ready, gs)
+			return
+		}
+	}
+}
+
+// Counts returns the number of Ready and Allocated GameServers of the FakeBackend
+func (f *FakeBackend) Counts() (ready, allocated int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.ready), len(f.allocated)
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testing
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"agones.dev/agones/pkg/apis"
+	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestFakeBackendAllocate(t *testing.T) {
+	t.Parallel()
+
+	f := NewFakeBackend("fleet", "default", 20, 0)
+	gsa := func() *allocationv1.GameServerAllocation {
+		return &allocationv1.GameServerAllocation{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+			Spec: allocationv1.GameServerAllocationSpec{
+				Required:  metav1.LabelSelector{MatchLabels: map[string]string{v1alpha1.FleetNameLabel: "fleet"}},
+				MetaPatch: allocationv1.MetaPatch{Labels: map[string]string{"mode": "deathmatch"}},
+			}}
+	}
+
+	result, err := f.Allocate(gsa())
+	assert.Nil(t, err)
+	assert.Equal(t, allocationv1.GameServerAllocationAllocated, result.Status.State)
+	assert.Equal(t, "fleet-0", result.Status.GameServerName)
+	assert.Equal(t, "fleet-node-0", result.Status.NodeName)
+	assert.NotEmpty(t, result.Status.Address)
+	assert.Len(t, result.Status.Ports, 1)
+
+	// Packed allocates from the same node
+	result, err = f.Allocate(gsa())
+	assert.Nil(t, err)
+	assert.Equal(t, "fleet-node-0", result.Status.NodeName)
+
+	// re-allocate with the allocated selector
+	reallocate := gsa()
+	reallocate.Spec.Allocated = &metav1.LabelSelector{MatchLabels: map[string]string{"mode": "deathmatch"}}
+	result, err = f.Allocate(reallocate)
+	assert.Nil(t, err)
+	assert.Equal(t, "fleet-0", result.Status.GameServerName)
+	ready, allocated := f.Counts()
+	assert.Equal(t, 18, ready)
+	assert.Equal(t, 2, allocated)
+
+	// required doesn't match
+	other := gsa()
+	other.Spec.Required.MatchLabels[v1alpha1.FleetNameLabel] = "other"
+	result, err = f.Allocate(other)
+	assert.Nil(t, err)
+	assert.Equal(t, allocationv1.GameServerAllocationUnAllocated, result.Status.State)
+
+	// invalid
+	invalid := gsa()
+	invalid.Spec.Scheduling = apis.SchedulingStrategy("Random")
+	_, err = f.Allocate(invalid)
+	if assert.NotNil(t, err) {
+		assert.True(t, k8serrors.IsInvalid(err))
+		assert.Equal(t, int32(http.StatusUnprocessableEntity), err.(k8serrors.APIStatus).Status().Code)
+	}
+
+	// with the owners of the GameServer
+	owners := gsa()
+	owners.Spec.IncludeOwners = true
+	result, err = f.Allocate(owners)
+	assert.Nil(t, err)
+	assert.Equal(t, &allocationv1.GameServerAllocationOwner{Name: "fleet"}, result.Status.Fleet)
+	assert.Nil(t, result.Status.GameServerSet)
+
+	// run out
+	for i := 0; i < 17; i++ {
+		result, err = f.Allocate(gsa())
+		assert.Nil(t, err)
+		assert.Equal(t, allocationv1.GameServerAllocationAllocated, result.Status.State)
+	}
+	result, err = f.Allocate(gsa())
+	assert.Nil(t, err)
+	assert.Equal(t, allocationv1.GameServerAllocationUnAllocated, result.Status.State)
+}
+
+func TestFakeBackendRelease(t *testing.T) {
+	t.Parallel()
+
+	f := NewFakeBackend("fleet", "default", 1, 10*time.Millisecond)
+	client := f.Clientset().AllocationV1().GameServerAllocations("default")
+
+	gsa := &allocationv1.GameServerAllocation{Spec: allocationv1.GameServerAllocationSpec{
+		MetaPatch: allocationv1.MetaPatch{Labels: map[string]string{"mode": "deathmatch"}}}}
+	result, err := client.Create(gsa.DeepCopy())
+	assert.Nil(t, err)
+	assert.Equal(t, allocationv1.GameServerAllocationAllocated, result.Status.State)
+
+	err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		ready, _ := f.Counts()
+		return ready == 1, nil
+	})
+	assert.Nil(t, err)
+
+	// released without the patched labels
+	gsa.Spec.Required = metav1.LabelSelector{MatchLabels: map[string]string{"mode": "deathmatch"}}
+	result, err = client.Create(gsa)
+	assert.Nil(t, err)
+	assert.Equal(t, allocationv1.GameServerAllocationUnAllocated, result.Status.State)
+}
@@ -15,9 +15,15 @@
 package gameservers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -44,6 +50,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
@@ -55,6 +62,37 @@ import (
 	"k8s.io/client-go/util/workqueue"
 )
 
+const (
+	// failureDomainZoneLabel is the deprecated zone label of nodes, from before TopologyZoneLabel
+	failureDomainZoneLabel = "failure-domain.beta.kubernetes.io/zone"
+	// failureDomainRegionLabel is the deprecated region label of nodes, from before TopologyRegionLabel
+	failureDomainRegionLabel = "failure-domain.beta.kubernetes.io/region"
+)
+
+// PodDefaults are the scheduling settings that are applied to the Pods of GameServers that
+// don't set them, such as to steer all game server Pods onto a dedicated node pool
+type PodDefaults struct {
+	PriorityClassName string
+	Tolerations       []corev1.Toleration
+	NodeSelector      map[string]string
+}
+
+// apply sets each of the PodDefaults on the Pod, unless the Pod already sets it
+func (d PodDefaults) apply(pod *corev1.Pod) {
+	if pod.Spec.PriorityClassName == "" {
+		pod.Spec.PriorityClassName = d.PriorityClassName
+	}
+	if len(pod.Spec.Tolerations) == 0 && len(d.Tolerations) > 0 {
+		pod.Spec.Tolerations = append([]corev1.Toleration(nil), d.Tolerations...)
+	}
+	if len(pod.Spec.NodeSelector) == 0 && len(d.NodeSelector) > 0 {
+		pod.Spec.NodeSelector = make(map[string]string, len(d.NodeSelector))
+		for k, v := range d.NodeSelector {
+			pod.Spec.NodeSelector[k] = v
+		}
+	}
+}
+
 // Controller is a the main GameServer crd controller
 type Controller struct {
 	baseLogger             *logrus.Entry
@@ -63,7 +101,16 @@ type Controller struct {
 	sidecarCPURequest      resource.Quantity
 	sidecarCPULimit        resource.Quantity
 	sdkServiceAccount      string
+	sidecarToken           SidecarToken
+	sidecarPingReport      time.Duration
+	errorRetention         time.Duration
+	nodeAddressPriority    []corev1.NodeAddressType
+	preferIPv6Address      bool
+	nodeAddressLabel       string
+	podDefaults            PodDefaults
+	devHealthChecks        *devHealthChecks
 	crdGetter              v1beta1.CustomResourceDefinitionInterface
+	crdWaitTimeout         time.Duration
 	podGetter              typedcorev1.PodsGetter
 	podLister              corelisterv1.PodLister
 	podSynced              cache.InformerSynced
@@ -73,24 +120,48 @@ type Controller struct {
 	nodeLister             corelisterv1.NodeLister
 	nodeSynced             cache.InformerSynced
 	portAllocator          *PortAllocator
+	maxPorts               int
 	healthController       *HealthController
 	workerqueue            *workerqueue.WorkerQueue
 	creationWorkerQueue    *workerqueue.WorkerQueue // handles creation only
 	deletionWorkerQueue    *workerqueue.WorkerQueue // handles deletion only
 	stop                   <-chan struct{}
 	recorder               record.EventRecorder
+	// preReadyClient calls the PreReady webhooks of GameServers, with the timeout of each webhook
+	preReadyClient *http.Client
+	preReadyMutex  sync.Mutex
+	// preReadyCalls are the PreReady webhook calls that are in flight, or whose result hasn't been synced yet,
+	// by GameServer UID
+	preReadyCalls map[types.UID]*preReadyCall
+}
+
+// preReadyCall is a call to the PreReady webhook of a GameServer, which is made off of the sync workers
+type preReadyCall struct {
+	done bool
+	err  error
 }
 
 // NewController returns a new gameserver crd controller
 func NewController(
 	wh *webhooks.WebHook,
 	health healthcheck.Handler,
+	crdWaitTimeout time.Duration,
 	minPort, maxPort int32,
+	additionalPortRanges map[string]PortRange,
+	stickyPorts bool,
+	maxPorts int,
 	sidecarImage string,
 	alwaysPullSidecarImage bool,
 	sidecarCPURequest resource.Quantity,
 	sidecarCPULimit resource.Quantity,
 	sdkServiceAccount string,
+	sidecarToken SidecarToken,
+	sidecarPingReport time.Duration,
+	errorRetention time.Duration,
+	nodeAddressPriority []corev1.NodeAddressType,
+	preferIPv6Address bool,
+	nodeAddressLabel string,
+	podDefaults PodDefaults,
 	kubeClient kubernetes.Interface,
 	kubeInformerFactory informers.SharedInformerFactory,
 	extClient extclientset.Interface,
@@ -107,7 +178,16 @@ func NewController(
 		sidecarCPURequest:      sidecarCPURequest,
 		alwaysPullSidecarImage: alwaysPullSidecarImage,
 		sdkServiceAccount:      sdkServiceAccount,
+		sidecarToken:           sidecarToken,
+		sidecarPingReport:      sidecarPingReport,
+		errorRetention:         errorRetention,
+		nodeAddressPriority:    nodeAddressPriority,
+		preferIPv6Address:      preferIPv6Address,
+		nodeAddressLabel:       nodeAddressLabel,
+		podDefaults:            podDefaults,
+		devHealthChecks:        newDevHealthChecks(),
 		crdGetter:              extClient.ApiextensionsV1beta1().CustomResourceDefinitions(),
+		crdWaitTimeout:         crdWaitTimeout,
 		podGetter:              kubeClient.CoreV1(),
 		podLister:              pods.Lister(),
 		podSynced:              pods.Informer().HasSynced,
@@ -116,8 +196,11 @@ func NewController(
 		gameServerSynced:       gsInformer.HasSynced,
 		nodeLister:             kubeInformerFactory.Core().V1().Nodes().Lister(),
 		nodeSynced:             kubeInformerFactory.Core().V1().Nodes().Informer().HasSynced,
-		portAllocator:          NewPortAllocator(minPort, maxPort, kubeInformerFactory, agonesInformerFactory),
+		portAllocator:          NewPortAllocator(minPort, maxPort, additionalPortRanges, stickyPorts, kubeInformerFactory, agonesInformerFactory),
+		maxPorts:               maxPorts,
 		healthController:       NewHealthController(health, kubeClient, agonesClient, kubeInformerFactory, agonesInformerFactory),
+		preReadyClient:         &http.Client{},
+		preReadyCalls:          map[types.UID]*preReadyCall{},
 	}
 
 	c.baseLogger = runtime.NewLoggerWithType(c)
@@ -136,17 +219,30 @@ func NewController(
 
 	wh.AddHandler("/mutate", v1alpha1.Kind("GameServer"), admv1beta1.Create, c.creationMutationHandler)
 	wh.AddHandler("/validate", v1alpha1.Kind("GameServer"), admv1beta1.Create, c.creationValidationHandler)
+	wh.AddHandler("/validate", v1alpha1.Kind("GameServer"), admv1beta1.Update, c.updateValidationHandler)
 
 	gsInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: c.enqueueGameServerBasedOnState,
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			// no point in processing unless there is a State change
+			// no point in processing unless there is a State change, the game server process
+			// has just connected to the SDK, or an Allocated GameServer has new annotations to copy to its Pod
 			oldGs := oldObj.(*v1alpha1.GameServer)
 			newGs := newObj.(*v1alpha1.GameServer)
-			if oldGs.Status.State != newGs.Status.State || oldGs.ObjectMeta.DeletionTimestamp != newGs.ObjectMeta.DeletionTimestamp {
+			if oldGs.Status.State != newGs.Status.State || oldGs.ObjectMeta.DeletionTimestamp != newGs.ObjectMeta.DeletionTimestamp ||
+				(oldGs.Status.SDK == nil) != (newGs.Status.SDK == nil) ||
+				(newGs.Status.State == v1alpha1.GameServerStateAllocated && !reflect.DeepEqual(oldGs.ObjectMeta.Annotations, newGs.ObjectMeta.Annotations)) {
 				c.enqueueGameServerBasedOnState(newGs)
 			}
 		},
+		DeleteFunc: func(obj interface{}) {
+			// a deleted GameServer is not synced again, so drop its PreReady webhook call.
+			// Could be a DeletedFinalStateUnknown, in which case, just ignore it
+			if gs, ok := obj.(*v1alpha1.GameServer); ok {
+				c.preReadyMutex.Lock()
+				delete(c.preReadyCalls, gs.ObjectMeta.UID)
+				c.preReadyMutex.Unlock()
+			}
+		},
 	})
 
 	// track pod deletions, for when GameServers are deleted
@@ -155,8 +251,10 @@ func NewController(
 			oldPod := oldObj.(*corev1.Pod)
 			if isGameServerPod(oldPod) {
 				newPod := newObj.(*corev1.Pod)
-				//  node name has changed -- i.e. it has been scheduled
-				if oldPod.Spec.NodeName != newPod.Spec.NodeName {
+				//  node name has changed -- i.e. it has been scheduled, or it has been given an IP,
+				// or the pod readiness or its running containers have changed, for the GameServer's conditions
+				if oldPod.Spec.NodeName != newPod.Spec.NodeName || oldPod.Status.PodIP != newPod.Status.PodIP ||
+					isPodReady(oldPod) != isPodReady(newPod) || runningContainers(oldPod) != runningContainers(newPod) {
 					owner := metav1.GetControllerOf(newPod)
 					c.workerqueue.Enqueue(cache.ExplicitKey(newPod.ObjectMeta.Namespace + "/" + owner.Name))
 				}
@@ -267,6 +365,18 @@ func (c *Controller) creationValidationHandler(review admv1beta1.AdmissionReview
 	c.loggerForGameServer(gs).WithField("review", review).Info("creationValidationHandler")
 
 	causes, ok := gs.Validate()
+	if portCauses := c.validatePortRanges(gs); len(portCauses) > 0 {
+		ok = false
+		causes = append(causes, portCauses...)
+	}
+	unknown, err := crd.UnknownFieldCauses(reflect.TypeOf(v1alpha1.GameServer{}), obj.Raw)
+	if err != nil {
+		return review, errors.Wrapf(err, "error checking GameServer json for unknown fields: %s", obj.Raw)
+	}
+	if len(unknown) > 0 {
+		ok = false
+		causes = append(causes, unknown...)
+	}
 	if !ok {
 		review.Response.Allowed = false
 		details := metav1.StatusDetails{
@@ -289,12 +399,108 @@ func (c *Controller) creationValidationHandler(review admv1beta1.AdmissionReview
 	return review, nil
 }
 
+// updateValidationHandler that validates a GameServer when it is updated, rejecting changes
+// to its template and ports once its Pod exists.
+// Should only be called on gameserver update operations.
+func (c *Controller) updateValidationHandler(review admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error) {
+	newGs := &v1alpha1.GameServer{}
+	oldGs := &v1alpha1.GameServer{}
+
+	newObj := review.Request.Object
+	if err := json.Unmarshal(newObj.Raw, newGs); err != nil {
+		c.baseLogger.WithField("review", review).WithError(err).Info("updateValidationHandler failed to unmarshal JSON")
+		return review, errors.Wrapf(err, "error unmarshalling new GameServer json: %s", newObj.Raw)
+	}
+
+	oldObj := review.Request.OldObject
+	if err := json.Unmarshal(oldObj.Raw, oldGs); err != nil {
+		c.baseLogger.WithField("review", review).WithError(err).Info("updateValidationHandler failed to unmarshal JSON")
+		return review, errors.Wrapf(err, "error unmarshalling old GameServer json: %s", oldObj.Raw)
+	}
+
+	// development GameServers don't have a Pod
+	if _, isDev := oldGs.GetDevAddress(); isDev {
+		return review, nil
+	}
+	// until the Pod is created, or while it is recreated, the spec can still be changed
+	if _, err := c.podLister.Pods(oldGs.ObjectMeta.Namespace).Get(oldGs.ObjectMeta.Name); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return review, nil
+		}
+		return review, errors.Wrapf(err, "error retrieving Pod for GameServer %s", oldGs.ObjectMeta.Name)
+	}
+
+	causes, ok := oldGs.ValidateUpdate(newGs)
+	if !ok {
+		review.Response.Allowed = false
+		details := metav1.StatusDetails{
+			Name:   review.Request.Name,
+			Group:  review.Request.Kind.Group,
+			Kind:   review.Request.Kind.Kind,
+			Causes: causes,
+		}
+		review.Response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: "GameServer update is invalid",
+			Reason:  metav1.StatusReasonInvalid,
+			Details: &details,
+		}
+
+		c.loggerForGameServer(newGs).WithField("review", review).Info("Invalid GameServer update")
+		return review, nil
+	}
+
+	return review, nil
+}
+
+// validatePortRanges checks that the GameServer has no more Dynamic and Passthrough ports than the
+// maximum, and that the port ranges they are allocated from exist, and have enough ports for all of them
+func (c *Controller) validatePortRanges(gs *v1alpha1.GameServer) []metav1.StatusCause {
+	var causes []metav1.StatusCause
+	counts := countPortRanges(gs)
+	names := make([]string, 0, len(counts))
+	total := 0
+	for name, count := range counts {
+		names = append(names, name)
+		total += count
+	}
+	sort.Strings(names)
+
+	if total > c.maxPorts {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   "ports",
+			Message: fmt.Sprintf("GameServer requests %d Dynamic and Passthrough ports, but at most %d are allowed", total, c.maxPorts),
+		})
+	}
+
+	for _, name := range names {
+		size, ok := c.portAllocator.portRangeSize(name)
+		if !ok {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   "ports.range",
+				Message: fmt.Sprintf("Port range %s does not exist", name),
+			})
+			continue
+		}
+		if counts[name] > size {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Field:   "ports",
+				Message: fmt.Sprintf("GameServer requests %d Dynamic and Passthrough ports from port range %s, but it only has %d", counts[name], name, size),
+			})
+		}
+	}
+	return causes
+}
+
 // Run the GameServer controller. Will block until stop is closed.
 // Runs threadiness number workers to process the rate limited queue
 func (c *Controller) Run(workers int, stop <-chan struct{}) error {
 	c.stop = stop
 
-	err := crd.WaitForEstablishedCRD(c.crdGetter, "gameservers.stable.agones.dev", c.baseLogger)
+	err := crd.WaitForEstablishedCRD(c.crdGetter, "gameservers.stable.agones.dev", c.crdWaitTimeout, c.baseLogger)
 	if err != nil {
 		return err
 	}
@@ -369,12 +575,39 @@ func (c *Controller) syncGameServer(key string) error {
 	if gs, err = c.syncGameServerStartingState(gs); err != nil {
 		return err
 	}
+	if gs, err = c.syncGameServerConditions(gs); err != nil {
+		return err
+	}
+	if gs, err = c.syncGameServerPodReadyState(gs); err != nil {
+		return err
+	}
+	if gs, err = c.syncGameServerReservedState(gs); err != nil {
+		return err
+	}
 	if gs, err = c.syncGameServerRequestReadyState(gs); err != nil {
 		return err
 	}
 	if gs, err = c.syncDevelopmentGameServer(gs); err != nil {
 		return err
 	}
+	if gs, err = c.syncDevelopmentGameServerHealth(gs); err != nil {
+		return err
+	}
+	if gs, err = c.syncGameServerPodAnnotations(gs); err != nil {
+		return err
+	}
+	if gs, err = c.syncGameServerPodSafeToEvict(gs); err != nil {
+		return err
+	}
+	if gs, err = c.syncGameServerPodAllocatedLabel(gs); err != nil {
+		return err
+	}
+	if gs, err = c.syncGameServerErrorState(gs); err != nil {
+		return err
+	}
+	if gs, err = c.syncGameServerUnhealthyState(gs); err != nil {
+		return err
+	}
 	if err = c.syncGameServerShutdownState(gs); err != nil {
 		return err
 	}
@@ -400,7 +633,8 @@ func (c *Controller) syncGameServerDeletionTimestamp(gs *v1alpha1.GameServer) (*
 
 	_, isDev := gs.GetDevAddress()
 	if pod != nil && !isDev {
-		// only need to do this once
+		// only need to do this once.
+		// No grace period is passed, so the terminationGracePeriodSeconds of the Pod's template is respected
 		if pod.ObjectMeta.DeletionTimestamp.IsZero() {
 			err = c.podGetter.Pods(pod.ObjectMeta.Namespace).Delete(pod.ObjectMeta.Name, nil)
 			if err != nil {
@@ -433,13 +667,16 @@ func (c *Controller) syncGameServerPortAllocationState(gs *v1alpha1.GameServer)
 		return gs, nil
 	}
 
-	gsCopy := c.portAllocator.Allocate(gs.DeepCopy())
+	gsCopy, err := c.portAllocator.Allocate(gs.DeepCopy())
+	if err != nil {
+		return c.moveToErrorState(gs, err.Error())
+	}
 
 	gsCopy.Status.State = v1alpha1.GameServerStateCreating
 	c.recorder.Event(gs, corev1.EventTypeNormal, string(gs.Status.State), "Port allocated")
 
 	c.loggerForGameServer(gsCopy).Info("Syncing Port Allocation GameServerState")
-	gs, err := c.gameServerGetter.GameServers(gs.ObjectMeta.Namespace).Update(gsCopy)
+	gs, err = patchGameServer(c.gameServerGetter, gs, gsCopy)
 	if err != nil {
 		// if the GameServer doesn't get updated with the port data, then put the port
 		// back in the pool, as it will get retried on the next pass
@@ -477,14 +714,16 @@ func (c *Controller) syncGameServerCreatingState(gs *v1alpha1.GameServer) (*v1al
 
 	gsCopy := gs.DeepCopy()
 	gsCopy.Status.State = v1alpha1.GameServerStateStarting
-	gs, err = c.gameServerGetter.GameServers(gs.ObjectMeta.Namespace).Update(gsCopy)
+	gs, err = patchGameServer(c.gameServerGetter, gs, gsCopy)
 	if err != nil {
 		return gs, errors.Wrapf(err, "error updating GameServer %s to Starting state", gs.Name)
 	}
 	return gs, nil
 }
 
-// syncDevelopmentGameServer manages advances a development gameserver to Ready status and registers its address and ports.
+// syncDevelopmentGameServer manages advances a development gameserver to its initial state, which is Ready
+// unless it has a DevStateAnnotation, and registers its address and ports. A development gameserver that
+// is moved to RequestReady is moved to Ready.
 func (c *Controller) syncDevelopmentGameServer(gs *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
 	// do not sync if the server is deleting.
 	if !(gs.ObjectMeta.DeletionTimestamp.IsZero()) {
@@ -496,8 +735,15 @@ func (c *Controller) syncDevelopmentGameServer(gs *v1alpha1.GameServer) (*v1alph
 		return gs, nil
 	}
 
-	if !(gs.Status.State == v1alpha1.GameServerStateReady) {
+	var state v1alpha1.GameServerState
+	switch gs.Status.State {
+	case "", v1alpha1.GameServerStatePortAllocation, v1alpha1.GameServerStateCreating:
 		c.loggerForGameServer(gs).Info("GS is a development game server and will not be managed by Agones.")
+		state = gs.GetDevState()
+	case v1alpha1.GameServerStateRequestReady:
+		state = v1alpha1.GameServerStateReady
+	default:
+		return gs, nil
 	}
 
 	gsCopy := gs.DeepCopy()
@@ -505,12 +751,11 @@ func (c *Controller) syncDevelopmentGameServer(gs *v1alpha1.GameServer) (*v1alph
 	for _, p := range gs.Spec.Ports {
 		ports = append(ports, p.Status())
 	}
-	// TODO: Use UpdateStatus() when it's available.
-	gsCopy.Status.State = v1alpha1.GameServerStateReady
+	gsCopy.Status.State = state
 	gsCopy.Status.Ports = ports
 	gsCopy.Status.Address = devIPAddress
 	gsCopy.Status.NodeName = devIPAddress
-	gs, err := c.gameServerGetter.GameServers(gs.ObjectMeta.Namespace).Update(gsCopy)
+	gs, err := patchGameServer(c.gameServerGetter, gs, gsCopy)
 	if err != nil {
 		return gs, errors.Wrapf(err, "error updating GameServer %s to %v status", gs.Name, gs.Status)
 	}
@@ -530,12 +775,31 @@ func (c *Controller) createGameServerPod(gs *v1alpha1.GameServer) (*v1alpha1.Gam
 		return gs, err
 	}
 
-	// if the service account is not set, then you are in the "opinionated"
-	// mode. If the user sets the service account, we assume they know what they are
-	// doing, and don't disable the gameserver container.
-	if pod.Spec.ServiceAccountName == "" {
+	c.podDefaults.apply(pod)
+	c.sidecarToken.applyToPod(gs, pod)
+
+	// the SDK sidecar runs as the SDK service account, unless the user sets the service account.
+	// Whether the gameserver container gets its token is up to the GameServer's SDKToken, and if that's
+	// Auto, you are in the "opinionated" mode: if the user sets the service account, we assume they know
+	// what they are doing, and don't disable the gameserver container.
+	customServiceAccount := pod.Spec.ServiceAccountName != ""
+	if !customServiceAccount {
 		pod.Spec.ServiceAccountName = c.sdkServiceAccount
+	}
+	switch gs.SDKTokenMount() {
+	case v1alpha1.SDKTokenAuto:
+		if customServiceAccount {
+			c.sidecarToken.applyToGameServerContainer(gs, pod)
+		} else {
+			gs.DisableServiceAccount(pod)
+		}
+	case v1alpha1.SDKTokenEnabled:
+		c.sidecarToken.applyToGameServerContainer(gs, pod)
+	case v1alpha1.SDKTokenDisabled:
+		gs.DisableServiceAccount(pod)
+	case v1alpha1.SDKTokenProjected:
 		gs.DisableServiceAccount(pod)
+		gs.ProjectServiceAccountToken(pod)
 	}
 
 	c.addGameServerHealthCheck(gs, pod)
@@ -603,6 +867,12 @@ func (c *Controller) sidecar(gs *v1alpha1.GameServer) corev1.Container {
 	if c.alwaysPullSidecarImage {
 		sidecar.ImagePullPolicy = corev1.PullAlways
 	}
+
+	if c.sidecarPingReport > 0 {
+		sidecar.Env = append(sidecar.Env, corev1.EnvVar{Name: "PING_REPORT_PERIOD", Value: c.sidecarPingReport.String()})
+	}
+
+	c.sidecarToken.applyToSidecar(&sidecar)
 	return sidecar
 }
 
@@ -654,11 +924,12 @@ func (c *Controller) syncGameServerStartingState(gs *v1alpha1.GameServer) (*v1al
 	// if we can't get the address, then go into queue backoff
 	gsCopy, err = c.applyGameServerAddressAndPort(gsCopy, pod)
 	if err != nil {
-		return gs, err
+		return gs, c.syncGameServerAddressNotPopulated(gs, pod, err)
 	}
+	setPodConditions(gsCopy, pod)
 
 	gsCopy.Status.State = v1alpha1.GameServerStateScheduled
-	gs, err = c.gameServerGetter.GameServers(gs.ObjectMeta.Namespace).Update(gsCopy)
+	gs, err = patchGameServer(c.gameServerGetter, gs, gsCopy)
 	if err != nil {
 		return gs, errors.Wrapf(err, "error updating GameServer %s to Scheduled state", gs.Name)
 	}
@@ -667,26 +938,503 @@ func (c *Controller) syncGameServerStartingState(gs *v1alpha1.GameServer) (*v1al
 	return gs, nil
 }
 
+// syncGameServerAddressNotPopulated records why the address of a Starting GameServer could not be
+// populated in its AddressPopulated condition, and returns err so that the GameServer is retried
+func (c *Controller) syncGameServerAddressNotPopulated(gs *v1alpha1.GameServer, pod *corev1.Pod, err error) error {
+	reason := "NodeAddressNotFound"
+	if pod.Spec.NodeName == "" {
+		reason = "PodNotScheduled"
+	} else if gs.HasPortPolicy(v1alpha1.None) {
+		reason = "PodIPNotFound"
+	}
+
+	gsCopy := gs.DeepCopy()
+	changed := gsCopy.Status.SetCondition(v1alpha1.GameServerConditionAddressPopulated, corev1.ConditionFalse, reason, err.Error())
+	if !(setPodConditions(gsCopy, pod) || changed) {
+		return err
+	}
+	if _, updateErr := patchGameServer(c.gameServerGetter, gs, gsCopy); updateErr != nil {
+		return errors.Wrapf(updateErr, "error updating the conditions of GameServer %s", gs.ObjectMeta.Name)
+	}
+	return err
+}
+
 // applyGameServerAddressAndPort gets the backing Pod for the GamesServer,
-// and sets the allocated Address and Port values to it and returns it.
+// and sets the allocated Address and Port values, and the topology labels of its node, to it and returns it.
+// GameServers whose ports have the None PortPolicy are given the IP of their Pod instead.
 func (c *Controller) applyGameServerAddressAndPort(gs *v1alpha1.GameServer, pod *corev1.Pod) (*v1alpha1.GameServer, error) {
-	addr, err := c.address(gs, pod)
-	if err != nil {
-		return gs, errors.Wrapf(err, "error getting external address for GameServer %s", gs.ObjectMeta.Name)
+	reason, message := "NodeAddressFound", fmt.Sprintf("Address populated from Node %s", pod.Spec.NodeName)
+	if gs.HasPortPolicy(v1alpha1.None) {
+		if pod.Status.PodIP == "" {
+			return gs, errors.Errorf("Pod %s of GameServer %s does not have an IP yet", pod.ObjectMeta.Name, gs.ObjectMeta.Name)
+		}
+		gs.Status.Address = pod.Status.PodIP
+		gs.Status.Addresses = nil
+		reason, message = "PodIPFound", fmt.Sprintf("Address populated from the IP of Pod %s", pod.ObjectMeta.Name)
+	} else {
+		addr, addresses, err := c.address(gs, pod)
+		if err != nil {
+			return gs, errors.Wrapf(err, "error getting external address for GameServer %s", gs.ObjectMeta.Name)
+		}
+		gs.Status.Address = addr
+		gs.Status.Addresses = addresses
 	}
 
-	gs.Status.Address = addr
 	gs.Status.NodeName = pod.Spec.NodeName
+	c.applyTopologyLabels(gs, pod)
 	// HostPort is always going to be populated, even when dynamic
 	// This will be a double up of information, but it will be easier to read
 	gs.Status.Ports = make([]v1alpha1.GameServerStatusPort, len(gs.Spec.Ports))
 	for i, p := range gs.Spec.Ports {
 		gs.Status.Ports[i] = p.Status()
 	}
+	gs.Status.SetCondition(v1alpha1.GameServerConditionAddressPopulated, corev1.ConditionTrue, reason, message)
+
+	return gs, nil
+}
+
+// applyTopologyLabels copies the zone and region labels of the node that the Pod is scheduled on
+// onto the GameServer, falling back to the deprecated failure-domain labels of older nodes
+func (c *Controller) applyTopologyLabels(gs *v1alpha1.GameServer, pod *corev1.Pod) {
+	if pod.Spec.NodeName == "" {
+		return
+	}
+	node, err := c.nodeLister.Get(pod.Spec.NodeName)
+	if err != nil {
+		c.loggerForGameServer(gs).WithError(err).Warn("could not get node to copy its topology labels")
+		return
+	}
+
+	for label, deprecated := range map[string]string{
+		v1alpha1.TopologyZoneLabel:   failureDomainZoneLabel,
+		v1alpha1.TopologyRegionLabel: failureDomainRegionLabel,
+	} {
+		value, ok := node.ObjectMeta.Labels[label]
+		if !ok {
+			value, ok = node.ObjectMeta.Labels[deprecated]
+		}
+		if !ok {
+			continue
+		}
+		if gs.ObjectMeta.Labels == nil {
+			gs.ObjectMeta.Labels = map[string]string{}
+		}
+		gs.ObjectMeta.Labels[label] = value
+	}
+}
+
+// setPodConditions sets the conditions of the GameServer that are observed from its Pod,
+// and returns whether any of them changed
+func setPodConditions(gs *v1alpha1.GameServer, pod *corev1.Pod) bool {
+	podReadyChanged := setPodReadyCondition(gs, pod)
+	sdkConnectedChanged := setSDKConnectedCondition(gs, pod)
+	return podReadyChanged || sdkConnectedChanged
+}
+
+// setPodReadyCondition sets the PodReady condition of the GameServer from the Ready condition
+// of its Pod, and returns whether it changed
+func setPodReadyCondition(gs *v1alpha1.GameServer, pod *corev1.Pod) bool {
+	status, reason, message := corev1.ConditionFalse, "PodNotReady", "Pod is not Ready"
+	for _, pc := range pod.Status.Conditions {
+		if pc.Type == corev1.PodReady {
+			status = pc.Status
+			if pc.Status == corev1.ConditionTrue {
+				reason, message = "PodReady", "Pod is Ready"
+			} else if pc.Reason != "" {
+				reason, message = pc.Reason, pc.Message
+			}
+		}
+	}
+	return gs.Status.SetCondition(v1alpha1.GameServerConditionPodReady, status, reason, message)
+}
+
+// setSDKConnectedCondition mirrors the SDK status reported by the SDK server into the SDKConnected
+// condition of the GameServer, telling a game server process that never called the SDK apart from a
+// game server container that hasn't started yet. Returns whether the condition changed.
+func setSDKConnectedCondition(gs *v1alpha1.GameServer, pod *corev1.Pod) bool {
+	if gs.Status.SDK != nil {
+		return gs.Status.SetCondition(v1alpha1.GameServerConditionSDKConnected, corev1.ConditionTrue, "SDKCalled",
+			fmt.Sprintf("Received %s from the game server", gs.Status.SDK.FirstCall))
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == gs.Spec.Container && cs.State.Running != nil {
+			return gs.Status.SetCondition(v1alpha1.GameServerConditionSDKConnected, corev1.ConditionFalse, "SDKNotCalled",
+				"The game server container is running, but has not called the SDK")
+		}
+	}
+	return gs.Status.SetCondition(v1alpha1.GameServerConditionSDKConnected, corev1.ConditionFalse, "ContainerNotRunning",
+		"Waiting for the game server container to start")
+}
+
+// syncGameServerConditions keeps the conditions of a GameServer that are observed from its Pod,
+// PodReady and SDKConnected, in step with it once it has one
+func (c *Controller) syncGameServerConditions(gs *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+	if !gs.ObjectMeta.DeletionTimestamp.IsZero() {
+		return gs, nil
+	}
+	switch gs.Status.State {
+	case "", v1alpha1.GameServerStatePortAllocation, v1alpha1.GameServerStateCreating,
+		v1alpha1.GameServerStateShutdown, v1alpha1.GameServerStateError:
+		return gs, nil
+	}
+	if _, isDev := gs.GetDevAddress(); isDev {
+		return gs, nil
+	}
+
+	pod, err := c.gameServerPod(gs)
+	if k8serrors.IsNotFound(err) {
+		return gs, nil
+	}
+	if err != nil {
+		return gs, err
+	}
+
+	gsCopy := gs.DeepCopy()
+	if !setPodConditions(gsCopy, pod) {
+		return gs, nil
+	}
+	gs, err = patchGameServer(c.gameServerGetter, gs, gsCopy)
+	if err != nil {
+		return gs, errors.Wrapf(err, "error updating the conditions of GameServer %s", gsCopy.ObjectMeta.Name)
+	}
+
+	return gs, nil
+}
+
+// syncGameServerPodReadyState moves a Scheduled GameServer that uses Pod readiness
+// to RequestReady once its backing Pod is Ready, standing in for the SDK.Ready() call
+func (c *Controller) syncGameServerPodReadyState(gs *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+	if !(gs.Status.State == v1alpha1.GameServerStateScheduled && gs.ObjectMeta.DeletionTimestamp.IsZero()) ||
+		gs.Spec.Readiness != v1alpha1.ReadinessPod {
+		return gs, nil
+	}
+	if _, isDev := gs.GetDevAddress(); isDev {
+		return gs, nil
+	}
+
+	pod, err := c.gameServerPod(gs)
+	if err != nil {
+		return gs, err
+	}
+	if !isPodReady(pod) {
+		return gs, nil
+	}
+
+	c.loggerForGameServer(gs).Info("Syncing Pod Ready State")
+
+	gsCopy := gs.DeepCopy()
+	gsCopy.Status.State = v1alpha1.GameServerStateRequestReady
+	gs, err = patchGameServer(c.gameServerGetter, gs, gsCopy)
+	if err != nil {
+		return gs, errors.Wrapf(err, "error updating GameServer %s to RequestReady state", gs.ObjectMeta.Name)
+	}
+	c.recorder.Event(gs, corev1.EventTypeNormal, string(gs.Status.State), "Pod is Ready")
+
+	return gs, nil
+}
+
+// syncGameServerReservedState moves a Reserved GameServer to RequestReady once its
+// reservation has expired. If it has not expired yet, the GameServer is synced again when it does.
+func (c *Controller) syncGameServerReservedState(gs *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+	if !(gs.Status.State == v1alpha1.GameServerStateReserved && gs.ObjectMeta.DeletionTimestamp.IsZero()) ||
+		gs.Status.ReservedUntil == nil {
+		return gs, nil
+	}
+
+	if remaining := gs.Status.ReservedUntil.Sub(time.Now()); remaining > 0 {
+		c.workerqueue.EnqueueAfter(gs, remaining)
+		return gs, nil
+	}
+
+	c.loggerForGameServer(gs).Info("Syncing Reserved State")
+
+	gsCopy := gs.DeepCopy()
+	gsCopy.Status.State = v1alpha1.GameServerStateRequestReady
+	gsCopy.Status.ReservedUntil = nil
+	gs, err := patchGameServer(c.gameServerGetter, gs, gsCopy)
+	if err != nil {
+		return gs, errors.Wrapf(err, "error updating GameServer %s to RequestReady state", gsCopy.ObjectMeta.Name)
+	}
+	c.recorder.Event(gs, corev1.EventTypeNormal, string(gs.Status.State), "Reservation expired")
+
+	return gs, nil
+}
+
+// syncGameServerPodAnnotations copies the annotations of an Allocated GameServer, such as the ones
+// set by the MetaPatch of a GameServerAllocation, onto its Pod, so that the game server process can
+// read them from a downward API volume of the Pod's `metadata.annotations`.
+func (c *Controller) syncGameServerPodAnnotations(gs *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+	if !(gs.Status.State == v1alpha1.GameServerStateAllocated && gs.ObjectMeta.DeletionTimestamp.IsZero()) {
+		return gs, nil
+	}
+	if _, isDev := gs.GetDevAddress(); isDev {
+		return gs, nil
+	}
+
+	pod, err := c.gameServerPod(gs)
+	if k8serrors.IsNotFound(err) {
+		return gs, nil
+	}
+	if err != nil {
+		return gs, err
+	}
+
+	var podCopy *corev1.Pod
+	for k, v := range gs.ObjectMeta.Annotations {
+		if current, ok := pod.ObjectMeta.Annotations[k]; ok && current == v {
+			continue
+		}
+		if podCopy == nil {
+			podCopy = pod.DeepCopy()
+			if podCopy.ObjectMeta.Annotations == nil {
+				podCopy.ObjectMeta.Annotations = make(map[string]string, len(gs.ObjectMeta.Annotations))
+			}
+		}
+		podCopy.ObjectMeta.Annotations[k] = v
+	}
+	if podCopy == nil {
+		return gs, nil
+	}
+
+	c.loggerForGameServer(gs).Info("Syncing Pod annotations")
+	if _, err := c.podGetter.Pods(pod.ObjectMeta.Namespace).Update(podCopy); err != nil {
+		return gs, errors.Wrapf(err, "error updating annotations of Pod for GameServer %s", gs.ObjectMeta.Name)
+	}
+
+	return gs, nil
+}
+
+// syncGameServerPodSafeToEvict sets the cluster autoscaler's safe-to-evict annotation on the Pod of a Packed
+// GameServer as per its state, so that the cluster autoscaler can remove nodes with Ready game servers,
+// but not nodes with Allocated ones.
+// The annotation is patched, so that it doesn't conflict with syncGameServerPodAnnotations updating the same Pod.
+func (c *Controller) syncGameServerPodSafeToEvict(gs *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+	if !gs.ObjectMeta.DeletionTimestamp.IsZero() {
+		return gs, nil
+	}
+	switch gs.Status.State {
+	case v1alpha1.GameServerStateScheduled, v1alpha1.GameServerStateRequestReady, v1alpha1.GameServerStateReady,
+		v1alpha1.GameServerStateReserved, v1alpha1.GameServerStateAllocated:
+	default:
+		return gs, nil
+	}
+	if _, isDev := gs.GetDevAddress(); isDev {
+		return gs, nil
+	}
+	safeToEvict, ok := gs.PodSafeToEvict()
+	if !ok {
+		return gs, nil
+	}
+
+	pod, err := c.gameServerPod(gs)
+	if k8serrors.IsNotFound(err) {
+		return gs, nil
+	}
+	if err != nil {
+		return gs, err
+	}
+	if pod.ObjectMeta.Annotations[v1alpha1.PodSafeToEvictAnnotation] == safeToEvict {
+		return gs, nil
+	}
+
+	c.loggerForGameServer(gs).WithField("safeToEvict", safeToEvict).Info("Syncing Pod safe-to-evict annotation")
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{v1alpha1.PodSafeToEvictAnnotation: safeToEvict},
+		},
+	})
+	if err != nil {
+		return gs, errors.Wrapf(err, "error creating safe-to-evict patch for Pod of GameServer %s", gs.ObjectMeta.Name)
+	}
+	if _, err := c.podGetter.Pods(pod.ObjectMeta.Namespace).Patch(pod.ObjectMeta.Name, types.MergePatchType, patch); err != nil {
+		return gs, errors.Wrapf(err, "error updating safe-to-evict annotation of Pod for GameServer %s", gs.ObjectMeta.Name)
+	}
+
+	return gs, nil
+}
+
+// syncGameServerPodAllocatedLabel sets the PodAllocatedLabel on the Pod of an Allocated GameServer of a Fleet,
+// and removes it once the GameServer is no longer Allocated, so that the Fleet's PodDisruptionBudget only
+// protects the Pods of Allocated GameServers.
+// The label is patched, so that it doesn't conflict with syncGameServerPodAnnotations updating the same Pod.
+func (c *Controller) syncGameServerPodAllocatedLabel(gs *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+	if !gs.ObjectMeta.DeletionTimestamp.IsZero() {
+		return gs, nil
+	}
+	if _, ok := gs.ObjectMeta.Labels[v1alpha1.FleetNameLabel]; !ok {
+		return gs, nil
+	}
+	switch gs.Status.State {
+	case v1alpha1.GameServerStateScheduled, v1alpha1.GameServerStateRequestReady, v1alpha1.GameServerStateReady,
+		v1alpha1.GameServerStateReserved, v1alpha1.GameServerStateAllocated:
+	default:
+		return gs, nil
+	}
+	if _, isDev := gs.GetDevAddress(); isDev {
+		return gs, nil
+	}
+
+	pod, err := c.gameServerPod(gs)
+	if k8serrors.IsNotFound(err) {
+		return gs, nil
+	}
+	if err != nil {
+		return gs, err
+	}
+
+	allocated := gs.Status.State == v1alpha1.GameServerStateAllocated
+	if _, ok := pod.ObjectMeta.Labels[v1alpha1.PodAllocatedLabel]; ok == allocated {
+		return gs, nil
+	}
+
+	c.loggerForGameServer(gs).WithField("allocated", allocated).Info("Syncing Pod allocated label")
+	// a null value removes the label
+	var value interface{}
+	if allocated {
+		value = "true"
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{v1alpha1.PodAllocatedLabel: value},
+		},
+	})
+	if err != nil {
+		return gs, errors.Wrapf(err, "error creating allocated label patch for Pod of GameServer %s", gs.ObjectMeta.Name)
+	}
+	if _, err := c.podGetter.Pods(pod.ObjectMeta.Namespace).Patch(pod.ObjectMeta.Name, types.MergePatchType, patch); err != nil {
+		return gs, errors.Wrapf(err, "error updating allocated label of Pod for GameServer %s", gs.ObjectMeta.Name)
+	}
 
 	return gs, nil
 }
 
+// syncGameServerErrorState deletes a GameServer that has been in the Error state for longer than
+// the error retention period, unless it is owned by a GameServerSet, which replaces it itself.
+// If the retention period has not passed yet, the GameServer is synced again when it does.
+func (c *Controller) syncGameServerErrorState(gs *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+	if !(gs.Status.State == v1alpha1.GameServerStateError && gs.ObjectMeta.DeletionTimestamp.IsZero()) ||
+		c.errorRetention <= 0 || metav1.GetControllerOf(gs) != nil {
+		return gs, nil
+	}
+
+	// GameServers that moved to the Error state before the annotation existed
+	// are retained from when they were created
+	errorTime := gs.ObjectMeta.CreationTimestamp.Time
+	if v, ok := gs.ObjectMeta.Annotations[v1alpha1.ErrorTimeAnnotation]; ok {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			errorTime = t
+		}
+	}
+
+	if remaining := errorTime.Add(c.errorRetention).Sub(time.Now()); remaining > 0 {
+		c.workerqueue.EnqueueAfter(gs, remaining)
+		return gs, nil
+	}
+
+	c.loggerForGameServer(gs).Info("Syncing Error State")
+
+	msg := fmt.Sprintf("Deleting after being in the Error state for longer than %s", c.errorRetention)
+	if cause := gs.ObjectMeta.Annotations[v1alpha1.ErrorMessageAnnotation]; cause != "" {
+		msg += ": " + cause
+	}
+	c.recorder.Event(gs, corev1.EventTypeWarning, string(gs.Status.State), msg)
+
+	p := metav1.DeletePropagationBackground
+	err := c.gameServerGetter.GameServers(gs.ObjectMeta.Namespace).Delete(gs.ObjectMeta.Name, &metav1.DeleteOptions{PropagationPolicy: &p})
+	if err != nil {
+		return gs, errors.Wrapf(err, "error deleting GameServer %s in Error state", gs.ObjectMeta.Name)
+	}
+
+	return gs, nil
+}
+
+// syncGameServerUnhealthyState recreates the Pod of an Unhealthy GameServer that is not owned by a GameServerSet,
+// if its RestartPolicy is OnFailure and it has not reached its BackoffLimit. The failed Pod is deleted once
+// the back-off since it failed has passed, and the GameServer moves back to Creating once the Pod is gone.
+func (c *Controller) syncGameServerUnhealthyState(gs *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+	if !(gs.Status.State == v1alpha1.GameServerStateUnhealthy && gs.ObjectMeta.DeletionTimestamp.IsZero()) ||
+		gs.Spec.RestartPolicy != v1alpha1.RestartOnFailure || gs.Status.Restarts >= gs.Spec.BackoffLimit ||
+		metav1.GetControllerOf(gs) != nil {
+		return gs, nil
+	}
+	if _, isDev := gs.GetDevAddress(); isDev {
+		return gs, nil
+	}
+
+	pod, err := c.gameServerPod(gs)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return gs, err
+	}
+	if err == nil {
+		// the GameServer is synced again once the Pod is deleted
+		if !pod.ObjectMeta.DeletionTimestamp.IsZero() {
+			return gs, nil
+		}
+		if remaining := podFailureTime(gs, pod).Add(restartBackoff(gs.Status.Restarts)).Sub(time.Now()); remaining > 0 {
+			c.workerqueue.EnqueueAfter(gs, remaining)
+			return gs, nil
+		}
+
+		c.loggerForGameServer(gs).Info("Deleting failed Pod to restart GameServer")
+		err = c.podGetter.Pods(pod.ObjectMeta.Namespace).Delete(pod.ObjectMeta.Name, nil)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return gs, errors.Wrapf(err, "error deleting failed Pod for GameServer %s", gs.ObjectMeta.Name)
+		}
+		return gs, nil
+	}
+
+	c.loggerForGameServer(gs).Info("Syncing Unhealthy State")
+
+	gsCopy := gs.DeepCopy()
+	gsCopy.Status.State = v1alpha1.GameServerStateCreating
+	gsCopy.Status.Restarts++
+	gsCopy.Status.Address = ""
+	gsCopy.Status.Addresses = nil
+	gsCopy.Status.NodeName = ""
+	gs, err = patchGameServer(c.gameServerGetter, gs, gsCopy)
+	if err != nil {
+		return gs, errors.Wrapf(err, "error updating GameServer %s to Creating state", gsCopy.ObjectMeta.Name)
+	}
+	c.recorder.Event(gs, corev1.EventTypeNormal, string(gs.Status.State),
+		fmt.Sprintf("Restarting after failure, restart %d of %d", gs.Status.Restarts, gs.Spec.BackoffLimit))
+
+	return gs, nil
+}
+
+// podFailureTime returns when the game server container of the Pod last terminated,
+// or the zero time if that is not known
+func podFailureTime(gs *v1alpha1.GameServer, pod *corev1.Pod) time.Time {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name != gs.Spec.Container {
+			continue
+		}
+		if cs.State.Terminated != nil {
+			return cs.State.Terminated.FinishedAt.Time
+		}
+		if cs.LastTerminationState.Terminated != nil {
+			return cs.LastTerminationState.Terminated.FinishedAt.Time
+		}
+	}
+	return time.Time{}
+}
+
+// restartBackoff returns how long to wait after a failure before the Pod of a GameServer is
+// recreated, doubling with each restart, up to a maximum
+func restartBackoff(restarts int32) time.Duration {
+	const (
+		base    = 10 * time.Second
+		maximum = 5 * time.Minute
+	)
+	d := base
+	for i := int32(0); i < restarts && d < maximum; i++ {
+		d *= 2
+	}
+	if d > maximum {
+		return maximum
+	}
+	return d
+}
+
 // syncGameServerRequestReadyState checks if the Game Server is Requesting to be ready,
 // and then adds the IP and Port information to the Status and marks the GameServer
 // as Ready
@@ -721,8 +1469,18 @@ func (c *Controller) syncGameServerRequestReadyState(gs *v1alpha1.GameServer) (*
 		}
 	}
 
+	// the webhook is sent the address and ports, so that it can e.g. register the game server with a directory
+	done, err := c.preReady(gsCopy)
+	if !done {
+		return gs, nil
+	}
+	if err != nil {
+		c.recorder.Event(gs, corev1.EventTypeWarning, string(gs.Status.State), err.Error())
+		return gs, err
+	}
+
 	gsCopy.Status.State = v1alpha1.GameServerStateReady
-	gs, err := c.gameServerGetter.GameServers(gs.ObjectMeta.Namespace).Update(gsCopy)
+	gs, err = patchGameServer(c.gameServerGetter, gs, gsCopy)
 	if err != nil {
 		return gs, errors.Wrapf(err, "error setting Ready, Port and address on GameServer %s Status", gs.ObjectMeta.Name)
 	}
@@ -734,6 +1492,80 @@ func (c *Controller) syncGameServerRequestReadyState(gs *v1alpha1.GameServer) (*
 	return gs, nil
 }
 
+// preReady returns true once the PreReady webhook of the GameServer has been called, or if it doesn't have one,
+// with an error if the GameServer should stay RequestReady, so that the webhook is retried with the workerqueue's
+// backoff. The webhook is called in the background, so that a slow webhook doesn't hold up a sync worker,
+// and the GameServer is enqueued again once it responds.
+func (c *Controller) preReady(gs *v1alpha1.GameServer) (bool, error) {
+	if gs.Spec.PreReady == nil {
+		return true, nil
+	}
+
+	c.preReadyMutex.Lock()
+	defer c.preReadyMutex.Unlock()
+	if call, ok := c.preReadyCalls[gs.ObjectMeta.UID]; ok {
+		if !call.done {
+			return false, nil
+		}
+		delete(c.preReadyCalls, gs.ObjectMeta.UID)
+		return true, call.err
+	}
+
+	call := &preReadyCall{}
+	c.preReadyCalls[gs.ObjectMeta.UID] = call
+	go func() {
+		err := c.callPreReadyWebhook(gs)
+		c.preReadyMutex.Lock()
+		call.done = true
+		call.err = err
+		c.preReadyMutex.Unlock()
+		c.workerqueue.Enqueue(gs)
+	}()
+	return false, nil
+}
+
+// callPreReadyWebhook calls the PreReady webhook of the GameServer, on its Service in the GameServer's namespace,
+// and returns an error if the GameServer should stay RequestReady
+func (c *Controller) callPreReadyWebhook(gs *v1alpha1.GameServer) error {
+	w := gs.Spec.PreReady
+	port := w.Service.Port
+	if port == 0 {
+		port = v1alpha1.DefaultPreReadyServicePort
+	}
+	u := fmt.Sprintf("http://%s.%s.svc:%d%s", w.Service.Name, gs.ObjectMeta.Namespace, port, w.Service.Path)
+
+	body, err := json.Marshal(gs)
+	if err != nil {
+		return errors.Wrapf(err, "error marshalling GameServer %s for its PreReady webhook", gs.ObjectMeta.Name)
+	}
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "error creating PreReady webhook request for GameServer %s", gs.ObjectMeta.Name)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	timeout := w.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = v1alpha1.DefaultPreReadyTimeoutSeconds
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	res, err := c.preReadyClient.Do(req.WithContext(ctx))
+	if err != nil {
+		if w.FailurePolicy == v1alpha1.PreReadyIgnore {
+			c.loggerForGameServer(gs).WithError(err).Warn("PreReady webhook failed, ignoring as per its failurePolicy")
+			return nil
+		}
+		return errors.Wrapf(err, "error calling PreReady webhook of GameServer %s", gs.ObjectMeta.Name)
+	}
+	defer res.Body.Close() // nolint: errcheck
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("PreReady webhook of GameServer %s responded with status code %d", gs.ObjectMeta.Name, res.StatusCode)
+	}
+	return nil
+}
+
 // syncGameServerShutdownState deletes the GameServer (and therefore the backing Pod) if it is in shutdown state
 func (c *Controller) syncGameServerShutdownState(gs *v1alpha1.GameServer) error {
 	if !(gs.Status.State == v1alpha1.GameServerStateShutdown && gs.ObjectMeta.DeletionTimestamp.IsZero()) {
@@ -755,8 +1587,13 @@ func (c *Controller) syncGameServerShutdownState(gs *v1alpha1.GameServer) error
 func (c *Controller) moveToErrorState(gs *v1alpha1.GameServer, msg string) (*v1alpha1.GameServer, error) {
 	copy := gs.DeepCopy()
 	copy.Status.State = v1alpha1.GameServerStateError
+	if copy.ObjectMeta.Annotations == nil {
+		copy.ObjectMeta.Annotations = make(map[string]string, 2)
+	}
+	copy.ObjectMeta.Annotations[v1alpha1.ErrorTimeAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	copy.ObjectMeta.Annotations[v1alpha1.ErrorMessageAnnotation] = msg
 
-	gs, err := c.gameServerGetter.GameServers(gs.ObjectMeta.Namespace).Update(copy)
+	gs, err := patchGameServer(c.gameServerGetter, gs, copy)
 	if err != nil {
 		return gs, errors.Wrapf(err, "error moving GameServer %s to Error State", gs.ObjectMeta.Name)
 	}
@@ -765,6 +1602,27 @@ func (c *Controller) moveToErrorState(gs *v1alpha1.GameServer, msg string) (*v1a
 	return gs, nil
 }
 
+// patchGameServer patches gs with the changes that have been made to gsCopy, rather than
+// updating the whole GameServer, so that the controller's state transitions don't conflict
+// with changes made to other fields at the same time, such as the labels and annotations
+// set through the SDK. The patch fails if gs has moved out of its State in the meantime,
+// so that a transition is never made from a State the GameServer is no longer in.
+// The patch isn't retried here: the API server already retries it on conflicts with concurrent
+// writes, as it has no resourceVersion, and a failed State test is Invalid, so the
+// GameServer has to be synced again from its new State, through the workerqueue.
+func patchGameServer(getter getterv1alpha1.GameServersGetter, gs, gsCopy *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+	patch, err := gs.StatePatch(gsCopy)
+	if err != nil {
+		return gs, err
+	}
+
+	result, err := getter.GameServers(gs.ObjectMeta.Namespace).Patch(gs.ObjectMeta.Name, types.JSONPatchType, patch)
+	if err != nil {
+		return gs, err
+	}
+	return result, nil
+}
+
 // gameServerPod returns the Pod for this Game Server, or an error if there are none,
 // or it cannot be determined (there are more than one, which should not happen)
 func (c *Controller) gameServerPod(gs *v1alpha1.GameServer) (*corev1.Pod, error) {
@@ -787,31 +1645,64 @@ func (c *Controller) gameServerPod(gs *v1alpha1.GameServer) (*corev1.Pod, error)
 	return pod, errors.Wrapf(err, "error retrieving pod for GameServer %s", gs.ObjectMeta.Name)
 }
 
-// address returns the IP that the given Pod is being run on
-// This should be the externalIP, but if the externalIP is
-// not set, it will fall back to the internalIP with a warning.
+// address returns the address of the Node that the given Pod is being run on, along with all of the Node's addresses.
+// If nodeAddressLabel is set, and the Node has a non-empty value for that label, the label's value is the address.
+// Otherwise this is the first address of the Node's that has a type in nodeAddressPriority, in that order,
+// which by default is the externalIP, falling back to the internalIP with a warning.
 // (basically because minikube only has an internalIP)
-func (c *Controller) address(gs *v1alpha1.GameServer, pod *corev1.Pod) (string, error) {
+func (c *Controller) address(gs *v1alpha1.GameServer, pod *corev1.Pod) (string, []corev1.NodeAddress, error) {
 	node, err := c.nodeLister.Get(pod.Spec.NodeName)
 	if err != nil {
-		return "", errors.Wrapf(err, "error retrieving node %s for Pod %s", pod.Spec.NodeName, pod.ObjectMeta.Name)
+		return "", nil, errors.Wrapf(err, "error retrieving node %s for Pod %s", pod.Spec.NodeName, pod.ObjectMeta.Name)
 	}
 
-	for _, a := range node.Status.Addresses {
-		if a.Type == corev1.NodeExternalIP && net.ParseIP(a.Address) != nil {
-			return a.Address, nil
+	addresses := make([]corev1.NodeAddress, len(node.Status.Addresses))
+	copy(addresses, node.Status.Addresses)
+
+	if c.nodeAddressLabel != "" {
+		if addr := strings.TrimSpace(node.ObjectMeta.Labels[c.nodeAddressLabel]); addr != "" {
+			return addr, addresses, nil
 		}
 	}
 
-	// minikube only has an InternalIP on a Node, so we'll fall back to that.
-	c.loggerForGameServer(gs).WithField("node", node.ObjectMeta.Name).Warn("Could not find ExternalIP. Falling back to Internal")
-	for _, a := range node.Status.Addresses {
-		if a.Type == corev1.NodeInternalIP && net.ParseIP(a.Address) != nil {
-			return a.Address, nil
+	for i, t := range c.nodeAddressPriority {
+		if addr, ok := c.nodeAddressOfType(node, t); ok {
+			if i > 0 {
+				c.loggerForGameServer(gs).WithField("node", node.ObjectMeta.Name).
+					Warnf("Could not find %s. Falling back to %s", c.nodeAddressPriority[0], t)
+			}
+			return addr, addresses, nil
 		}
 	}
 
-	return "", errors.Errorf("Could not find an address for Node: %s", node.ObjectMeta.Name)
+	return "", nil, errors.Errorf("Could not find an address for Node: %s", node.ObjectMeta.Name)
+}
+
+// nodeAddressOfType returns the first of the Node's addresses of the given type, or its first IPv6 address
+// of that type if preferIPv6Address is set. Only valid IP addresses are returned for the IP address types.
+func (c *Controller) nodeAddressOfType(node *corev1.Node, t corev1.NodeAddressType) (string, bool) {
+	result := ""
+	for _, a := range node.Status.Addresses {
+		if a.Type != t || a.Address == "" {
+			continue
+		}
+		if t == corev1.NodeExternalIP || t == corev1.NodeInternalIP {
+			ip := net.ParseIP(a.Address)
+			if ip == nil {
+				continue
+			}
+			if c.preferIPv6Address && ip.To4() == nil {
+				return a.Address, true
+			}
+		}
+		if result == "" {
+			result = a.Address
+		}
+		if !c.preferIPv6Address {
+			break
+		}
+	}
+	return result, result != ""
 }
 
 // isGameServerPod returns if this Pod is a Pod that comes from a GameServer
@@ -823,3 +1714,24 @@ func isGameServerPod(pod *corev1.Pod) bool {
 
 	return false
 }
+
+// runningContainers returns the number of containers of the Pod that are running
+func runningContainers(pod *corev1.Pod) int {
+	count := 0
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Running != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// isPodReady returns true if the Pod has a PodReady condition that is True
+func isPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
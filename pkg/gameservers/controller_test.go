@@ -18,8 +18,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"agones.dev/agones/pkg/apis/stable"
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
@@ -27,6 +32,7 @@ import (
 	"agones.dev/agones/pkg/util/webhooks"
 	"github.com/heptiolabs/healthcheck"
 	"github.com/mattbaird/jsonpatch"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	admv1beta1 "k8s.io/api/admission/v1beta1"
@@ -36,8 +42,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	k8stesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
@@ -95,9 +103,11 @@ func TestControllerSyncGameServer(t *testing.T) {
 			gameServers := &v1alpha1.GameServerList{Items: []v1alpha1.GameServer{*fixture}}
 			return true, gameServers, nil
 		})
-		mocks.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
-			ua := action.(k8stesting.UpdateAction)
-			gs := ua.GetObject().(*v1alpha1.GameServer)
+		// each patch is made to the GameServer that the previous one resulted in
+		patched := fixture
+		mocks.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gs := patchedGameServer(t, patched, action)
+			patched = gs
 			updateCount++
 			expectedState := v1alpha1.GameServerState("notastate")
 			switch updateCount {
@@ -191,9 +201,8 @@ func TestControllerSyncGameServerWithDevIP(t *testing.T) {
 			gameServers := &v1alpha1.GameServerList{Items: []v1alpha1.GameServer{*fixture}}
 			return true, gameServers, nil
 		})
-		mocks.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
-			ua := action.(k8stesting.UpdateAction)
-			gs := ua.GetObject().(*v1alpha1.GameServer)
+		mocks.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gs := patchedGameServer(t, fixture, action)
 			updateCount++
 			expectedState := v1alpha1.GameServerStateReady
 
@@ -217,6 +226,61 @@ func TestControllerSyncGameServerWithDevIP(t *testing.T) {
 		assert.Equal(t, 1, updateCount, "update reactor should fire once")
 	})
 
+	runDevSync := func(t *testing.T, fixture *v1alpha1.GameServer) []v1alpha1.GameServer {
+		c, mocks := newFakeController()
+		var updated []v1alpha1.GameServer
+
+		mocks.AgonesClient.AddReactor("list", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &v1alpha1.GameServerList{Items: []v1alpha1.GameServer{*fixture}}, nil
+		})
+		mocks.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gs := patchedGameServer(t, fixture, action)
+			updated = append(updated, *gs)
+			return true, gs, nil
+		})
+
+		_, cancel := agtesting.StartInformers(mocks, c.gameServerSynced)
+		defer cancel()
+
+		err := c.syncGameServer("default/test")
+		assert.Nil(t, err)
+		return updated
+	}
+
+	t.Run("Creating a new GameServer with a dev state", func(t *testing.T) {
+		fixture := templateDevGs.DeepCopy()
+		fixture.ObjectMeta.Annotations[v1alpha1.DevStateAnnotation] = string(v1alpha1.GameServerStateAllocated)
+		fixture.ApplyDefaults()
+
+		updated := runDevSync(t, fixture)
+		if assert.Len(t, updated, 1) {
+			assert.Equal(t, v1alpha1.GameServerStateAllocated, updated[0].Status.State)
+			assert.Equal(t, ipFixture, updated[0].Status.Address)
+			assert.Equal(t, int32(7777), updated[0].Status.Ports[0].Port)
+		}
+	})
+
+	t.Run("RequestReady GameServer moves to Ready", func(t *testing.T) {
+		fixture := templateDevGs.DeepCopy()
+		fixture.ObjectMeta.Annotations[v1alpha1.DevStateAnnotation] = string(v1alpha1.GameServerStateScheduled)
+		fixture.ApplyDefaults()
+		fixture.Status.State = v1alpha1.GameServerStateRequestReady
+
+		updated := runDevSync(t, fixture)
+		if assert.Len(t, updated, 1) {
+			assert.Equal(t, v1alpha1.GameServerStateReady, updated[0].Status.State)
+		}
+	})
+
+	t.Run("Allocated GameServer is not moved back to Ready", func(t *testing.T) {
+		fixture := templateDevGs.DeepCopy()
+		fixture.ApplyDefaults()
+		fixture.Status.State = v1alpha1.GameServerStateAllocated
+
+		updated := runDevSync(t, fixture)
+		assert.Empty(t, updated)
+	})
+
 	t.Run("When a GameServer has been deleted, the sync operation should be a noop", func(t *testing.T) {
 		runReconcileDeleteGameServer(t, &v1alpha1.GameServer{
 			ObjectMeta: metav1.ObjectMeta{
@@ -441,6 +505,253 @@ func TestControllerCreationValidationHandler(t *testing.T) {
 		assert.Equal(t, review.Request.Kind.Group, result.Response.Result.Details.Group)
 		assert.NotEmpty(t, result.Response.Result.Details.Causes)
 	})
+
+	t.Run("more ports than the port range", func(t *testing.T) {
+		fixture := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: newSingleContainerSpec()}
+		fixture.Spec.Ports = nil
+		// the port range is 10 to 20
+		for i := 0; i < 12; i++ {
+			fixture.Spec.Ports = append(fixture.Spec.Ports, v1alpha1.GameServerPort{Name: strconv.Itoa(i), ContainerPort: int32(7000 + i)})
+		}
+		fixture.ApplyDefaults()
+
+		raw, err := json.Marshal(fixture)
+		assert.Nil(t, err)
+		review := admv1beta1.AdmissionReview{
+			Request: &admv1beta1.AdmissionRequest{
+				Kind:      GameServerKind,
+				Operation: admv1beta1.Create,
+				Object: runtime.RawExtension{
+					Raw: raw,
+				},
+			},
+			Response: &admv1beta1.AdmissionResponse{Allowed: true},
+		}
+
+		result, err := c.creationValidationHandler(review)
+		assert.Nil(t, err)
+		assert.False(t, result.Response.Allowed)
+		assert.Equal(t, metav1.StatusReasonInvalid, result.Response.Result.Reason)
+		assert.Len(t, result.Response.Result.Details.Causes, 1)
+		assert.Equal(t, "ports", result.Response.Result.Details.Causes[0].Field)
+
+		// exactly the size of the port range is fine
+		fixture.Spec.Ports = fixture.Spec.Ports[:11]
+		raw, err = json.Marshal(fixture)
+		assert.Nil(t, err)
+		review.Request.Object.Raw = raw
+		review.Response = &admv1beta1.AdmissionResponse{Allowed: true}
+
+		result, err = c.creationValidationHandler(review)
+		assert.Nil(t, err)
+		assert.True(t, result.Response.Allowed)
+	})
+
+	t.Run("more ports than the maximum", func(t *testing.T) {
+		fixture := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: newSingleContainerSpec()}
+		fixture.Spec.Ports = nil
+		// the maximum is 16, the port range is 10 to 20, and the query port range is 30 to 35
+		for i := 0; i < 11; i++ {
+			fixture.Spec.Ports = append(fixture.Spec.Ports, v1alpha1.GameServerPort{Name: strconv.Itoa(i), ContainerPort: int32(7000 + i)})
+		}
+		for i := 11; i < 17; i++ {
+			fixture.Spec.Ports = append(fixture.Spec.Ports, v1alpha1.GameServerPort{Name: strconv.Itoa(i), Range: "query", ContainerPort: int32(7000 + i)})
+		}
+		fixture.ApplyDefaults()
+
+		raw, err := json.Marshal(fixture)
+		assert.Nil(t, err)
+		review := admv1beta1.AdmissionReview{
+			Request: &admv1beta1.AdmissionRequest{
+				Kind:      GameServerKind,
+				Operation: admv1beta1.Create,
+				Object: runtime.RawExtension{
+					Raw: raw,
+				},
+			},
+			Response: &admv1beta1.AdmissionResponse{Allowed: true},
+		}
+
+		result, err := c.creationValidationHandler(review)
+		assert.Nil(t, err)
+		assert.False(t, result.Response.Allowed)
+		assert.Equal(t, metav1.StatusReasonInvalid, result.Response.Result.Reason)
+		assert.Len(t, result.Response.Result.Details.Causes, 1)
+		assert.Equal(t, "ports", result.Response.Result.Details.Causes[0].Field)
+		assert.Contains(t, result.Response.Result.Details.Causes[0].Message, "at most 16")
+
+		// exactly the maximum is fine
+		fixture.Spec.Ports = fixture.Spec.Ports[:16]
+		raw, err = json.Marshal(fixture)
+		assert.Nil(t, err)
+		review.Request.Object.Raw = raw
+		review.Response = &admv1beta1.AdmissionResponse{Allowed: true}
+
+		result, err = c.creationValidationHandler(review)
+		assert.Nil(t, err)
+		assert.True(t, result.Response.Allowed)
+
+		// Static ports do not count towards the maximum
+		fixture.Spec.Ports = append(fixture.Spec.Ports, v1alpha1.GameServerPort{Name: "static", PortPolicy: v1alpha1.Static, HostPort: 9000, ContainerPort: 9000})
+		fixture.ApplyDefaults()
+		raw, err = json.Marshal(fixture)
+		assert.Nil(t, err)
+		review.Request.Object.Raw = raw
+		review.Response = &admv1beta1.AdmissionResponse{Allowed: true}
+
+		result, err = c.creationValidationHandler(review)
+		assert.Nil(t, err)
+		assert.True(t, result.Response.Allowed)
+	})
+
+	t.Run("port ranges", func(t *testing.T) {
+		fixture := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: newSingleContainerSpec()}
+		fixture.Spec.Ports = []v1alpha1.GameServerPort{{Name: "default", Range: "missing", ContainerPort: 7777}}
+		fixture.ApplyDefaults()
+
+		raw, err := json.Marshal(fixture)
+		assert.Nil(t, err)
+		review := admv1beta1.AdmissionReview{
+			Request: &admv1beta1.AdmissionRequest{
+				Kind:      GameServerKind,
+				Operation: admv1beta1.Create,
+				Object: runtime.RawExtension{
+					Raw: raw,
+				},
+			},
+			Response: &admv1beta1.AdmissionResponse{Allowed: true},
+		}
+
+		result, err := c.creationValidationHandler(review)
+		assert.Nil(t, err)
+		assert.False(t, result.Response.Allowed)
+		assert.Len(t, result.Response.Result.Details.Causes, 1)
+		assert.Equal(t, "ports.range", result.Response.Result.Details.Causes[0].Field)
+
+		// the query port range is 30 to 35
+		fixture.Spec.Ports = nil
+		for i := 0; i < 7; i++ {
+			fixture.Spec.Ports = append(fixture.Spec.Ports, v1alpha1.GameServerPort{Name: strconv.Itoa(i), Range: "query", ContainerPort: int32(7000 + i)})
+		}
+		fixture.ApplyDefaults()
+		raw, err = json.Marshal(fixture)
+		assert.Nil(t, err)
+		review.Request.Object.Raw = raw
+		review.Response = &admv1beta1.AdmissionResponse{Allowed: true}
+
+		result, err = c.creationValidationHandler(review)
+		assert.Nil(t, err)
+		assert.False(t, result.Response.Allowed)
+		assert.Len(t, result.Response.Result.Details.Causes, 1)
+		assert.Equal(t, "ports", result.Response.Result.Details.Causes[0].Field)
+
+		fixture.Spec.Ports = fixture.Spec.Ports[:6]
+		raw, err = json.Marshal(fixture)
+		assert.Nil(t, err)
+		review.Request.Object.Raw = raw
+		review.Response = &admv1beta1.AdmissionResponse{Allowed: true}
+
+		result, err = c.creationValidationHandler(review)
+		assert.Nil(t, err)
+		assert.True(t, result.Response.Allowed)
+	})
+}
+
+func TestControllerUpdateValidationHandler(t *testing.T) {
+	t.Parallel()
+
+	newReview := func(t *testing.T, oldGs, newGs *v1alpha1.GameServer) admv1beta1.AdmissionReview {
+		oldRaw, err := json.Marshal(oldGs)
+		assert.Nil(t, err)
+		newRaw, err := json.Marshal(newGs)
+		assert.Nil(t, err)
+		return admv1beta1.AdmissionReview{
+			Request: &admv1beta1.AdmissionRequest{
+				Kind:      GameServerKind,
+				Operation: admv1beta1.Update,
+				Object:    runtime.RawExtension{Raw: newRaw},
+				OldObject: runtime.RawExtension{Raw: oldRaw},
+			},
+			Response: &admv1beta1.AdmissionResponse{Allowed: true},
+		}
+	}
+
+	newFixture := func() *v1alpha1.GameServer {
+		fixture := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: newSingleContainerSpec()}
+		fixture.ApplyDefaults()
+		return fixture
+	}
+
+	setup := func(t *testing.T, pods ...corev1.Pod) (*Controller, context.CancelFunc) {
+		c, mocks := newFakeController()
+		mocks.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &corev1.PodList{Items: pods}, nil
+		})
+		_, cancel := agtesting.StartInformers(mocks, c.podSynced)
+		return c, cancel
+	}
+
+	t.Run("pod exists, template changed", func(t *testing.T) {
+		oldGs := newFixture()
+		pod, err := oldGs.Pod()
+		assert.Nil(t, err)
+		c, cancel := setup(t, *pod)
+		defer cancel()
+
+		newGs := oldGs.DeepCopy()
+		newGs.Spec.Template.Spec.Containers[0].Image = "container/image:2"
+		result, err := c.updateValidationHandler(newReview(t, oldGs, newGs))
+		assert.Nil(t, err)
+		assert.False(t, result.Response.Allowed)
+		assert.Equal(t, metav1.StatusFailure, result.Response.Result.Status)
+		assert.Equal(t, metav1.StatusReasonInvalid, result.Response.Result.Reason)
+		assert.Len(t, result.Response.Result.Details.Causes, 1)
+		assert.Equal(t, "template", result.Response.Result.Details.Causes[0].Field)
+	})
+
+	t.Run("pod exists, metadata and status changed", func(t *testing.T) {
+		oldGs := newFixture()
+		pod, err := oldGs.Pod()
+		assert.Nil(t, err)
+		c, cancel := setup(t, *pod)
+		defer cancel()
+
+		newGs := oldGs.DeepCopy()
+		newGs.ObjectMeta.Labels = map[string]string{"mode": "deathmatch"}
+		newGs.Status.State = v1alpha1.GameServerStateAllocated
+		result, err := c.updateValidationHandler(newReview(t, oldGs, newGs))
+		assert.Nil(t, err)
+		assert.True(t, result.Response.Allowed)
+	})
+
+	t.Run("no pod yet", func(t *testing.T) {
+		c, cancel := setup(t)
+		defer cancel()
+
+		oldGs := newFixture()
+		newGs := oldGs.DeepCopy()
+		newGs.Spec.Ports[0].HostPort = 7010
+		result, err := c.updateValidationHandler(newReview(t, oldGs, newGs))
+		assert.Nil(t, err)
+		assert.True(t, result.Response.Allowed)
+	})
+
+	t.Run("development gameserver", func(t *testing.T) {
+		c, cancel := setup(t)
+		defer cancel()
+
+		oldGs := newFixture()
+		oldGs.ObjectMeta.Annotations = map[string]string{v1alpha1.DevAddressAnnotation: ipFixture}
+		newGs := oldGs.DeepCopy()
+		newGs.Spec.Ports[0].HostPort = 7010
+		result, err := c.updateValidationHandler(newReview(t, oldGs, newGs))
+		assert.Nil(t, err)
+		assert.True(t, result.Response.Allowed)
+	})
 }
 
 func TestControllerSyncGameServerDeletionTimestamp(t *testing.T) {
@@ -561,10 +872,9 @@ func TestControllerSyncGameServerPortAllocationState(t *testing.T) {
 
 		updated := false
 
-		mocks.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		mocks.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			updated = true
-			ua := action.(k8stesting.UpdateAction)
-			gs := ua.GetObject().(*v1alpha1.GameServer)
+			gs := patchedGameServer(t, fixture, action)
 			assert.Equal(t, fixture.ObjectMeta.Name, gs.ObjectMeta.Name)
 			port := gs.Spec.Ports[0]
 			assert.Equal(t, v1alpha1.Dynamic, port.PortPolicy)
@@ -588,6 +898,40 @@ func TestControllerSyncGameServerPortAllocationState(t *testing.T) {
 		assert.True(t, 10 <= port.HostPort && port.HostPort <= 20, "%s not in range", port.HostPort)
 	})
 
+	t.Run("Gameserver with more ports than the port range", func(t *testing.T) {
+		t.Parallel()
+		c, mocks := newFakeController()
+		fixture := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec:   newSingleContainerSpec(),
+			Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStatePortAllocation},
+		}
+		fixture.Spec.Ports = nil
+		for i := 0; i < 12; i++ {
+			fixture.Spec.Ports = append(fixture.Spec.Ports, v1alpha1.GameServerPort{Name: strconv.Itoa(i), ContainerPort: int32(7000 + i)})
+		}
+		fixture.ApplyDefaults()
+
+		mocks.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gs := patchedGameServer(t, fixture, action)
+			assert.Equal(t, v1alpha1.GameServerStateError, gs.Status.State)
+			for _, p := range gs.Spec.Ports {
+				assert.Empty(t, p.HostPort)
+			}
+			return true, gs, nil
+		})
+
+		_, cancel := agtesting.StartInformers(mocks, c.gameServerSynced, c.portAllocator.nodeSynced)
+		defer cancel()
+		err := c.portAllocator.syncAll()
+		assert.Nil(t, err)
+
+		result, err := c.syncGameServerPortAllocationState(fixture)
+		assert.Nil(t, err)
+		assert.Equal(t, v1alpha1.GameServerStateError, result.Status.State)
+		assert.Empty(t, c.portAllocator.gameServerRegistry)
+		agtesting.AssertEventContains(t, mocks.FakeRecorder.Events, "not enough ports")
+	})
+
 	t.Run("Gameserver with unknown state", func(t *testing.T) {
 		testNoChange(t, "Unknown", func(c *Controller, fixture *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
 			return c.syncGameServerPortAllocationState(fixture)
@@ -625,10 +969,9 @@ func TestControllerSyncGameServerCreatingState(t *testing.T) {
 			assert.True(t, metav1.IsControlledBy(pod, fixture))
 			return true, pod, nil
 		})
-		m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			gsUpdated = true
-			ua := action.(k8stesting.UpdateAction)
-			gs := ua.GetObject().(*v1alpha1.GameServer)
+			gs := patchedGameServer(t, fixture, action)
 			assert.Equal(t, v1alpha1.GameServerStateStarting, gs.Status.State)
 			return true, gs, nil
 		})
@@ -662,10 +1005,9 @@ func TestControllerSyncGameServerCreatingState(t *testing.T) {
 			podCreated = true
 			return true, nil, nil
 		})
-		m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			gsUpdated = true
-			ua := action.(k8stesting.UpdateAction)
-			gs := ua.GetObject().(*v1alpha1.GameServer)
+			gs := patchedGameServer(t, fixture, action)
 			assert.Equal(t, v1alpha1.GameServerStateStarting, gs.Status.State)
 			return true, gs, nil
 		})
@@ -690,10 +1032,9 @@ func TestControllerSyncGameServerCreatingState(t *testing.T) {
 			podCreated = true
 			return true, nil, k8serrors.NewInvalid(schema.GroupKind{}, "test", field.ErrorList{})
 		})
-		mocks.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		mocks.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			gsUpdated = true
-			ua := action.(k8stesting.UpdateAction)
-			gs := ua.GetObject().(*v1alpha1.GameServer)
+			gs := patchedGameServer(t, fixture, action)
 			assert.Equal(t, v1alpha1.GameServerStateError, gs.Status.State)
 			return true, gs, nil
 		})
@@ -722,6 +1063,39 @@ func TestControllerSyncGameServerCreatingState(t *testing.T) {
 	})
 }
 
+func TestControllerApplyTopologyLabels(t *testing.T) {
+	t.Parallel()
+
+	nodes := []corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "topology", Labels: map[string]string{
+			v1alpha1.TopologyZoneLabel: "us-west1-a", v1alpha1.TopologyRegionLabel: "us-west1",
+			failureDomainZoneLabel: "old-zone", failureDomainRegionLabel: "old-region"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "failure-domain", Labels: map[string]string{
+			failureDomainZoneLabel: "europe-west1-b", failureDomainRegionLabel: "europe-west1"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "unlabelled"}},
+	}
+
+	c, m := newFakeController()
+	m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.NodeList{Items: nodes}, nil
+	})
+	_, cancel := agtesting.StartInformers(m, c.nodeSynced)
+	defer cancel()
+
+	labels := func(nodeName string, gsLabels map[string]string) map[string]string {
+		gs := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", Labels: gsLabels}}
+		c.applyTopologyLabels(gs, &corev1.Pod{Spec: corev1.PodSpec{NodeName: nodeName}})
+		return gs.ObjectMeta.Labels
+	}
+
+	assert.Equal(t, map[string]string{v1alpha1.TopologyZoneLabel: "us-west1-a", v1alpha1.TopologyRegionLabel: "us-west1"}, labels("topology", nil))
+	assert.Equal(t, map[string]string{"mode": "ctf", v1alpha1.TopologyZoneLabel: "europe-west1-b", v1alpha1.TopologyRegionLabel: "europe-west1"},
+		labels("failure-domain", map[string]string{"mode": "ctf", v1alpha1.TopologyZoneLabel: "template-zone"}))
+	assert.Nil(t, labels("unlabelled", nil))
+	assert.Nil(t, labels("missing", nil))
+	assert.Nil(t, labels("", nil))
+}
+
 func TestControllerSyncGameServerStartingState(t *testing.T) {
 	t.Parallel()
 
@@ -732,7 +1106,8 @@ func TestControllerSyncGameServerStartingState(t *testing.T) {
 		return fixture
 	}
 
-	node := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName}, Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Address: ipFixture, Type: corev1.NodeExternalIP}}}}
+	node := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName, Labels: map[string]string{v1alpha1.TopologyZoneLabel: "us-west1-a"}},
+		Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Address: ipFixture, Type: corev1.NodeExternalIP}}}}
 
 	t.Run("sync from Stating state, with no issues", func(t *testing.T) {
 		c, m := newFakeController()
@@ -749,10 +1124,9 @@ func TestControllerSyncGameServerStartingState(t *testing.T) {
 		m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
 		})
-		m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			gsUpdated = true
-			ua := action.(k8stesting.UpdateAction)
-			gs := ua.GetObject().(*v1alpha1.GameServer)
+			gs := patchedGameServer(t, gsFixture, action)
 			assert.Equal(t, v1alpha1.GameServerStateScheduled, gs.Status.State)
 			return true, gs, nil
 		})
@@ -766,9 +1140,49 @@ func TestControllerSyncGameServerStartingState(t *testing.T) {
 		assert.True(t, gsUpdated)
 		assert.Equal(t, gs.Status.NodeName, node.ObjectMeta.Name)
 		assert.Equal(t, gs.Status.Address, ipFixture)
+		assert.Equal(t, "us-west1-a", gs.ObjectMeta.Labels[v1alpha1.TopologyZoneLabel])
 
 		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Address and port populated")
 		assert.NotEmpty(t, gs.Status.Ports)
+		assert.Equal(t, corev1.ConditionTrue, gs.Status.Condition(v1alpha1.GameServerConditionAddressPopulated).Status)
+		assert.Equal(t, corev1.ConditionFalse, gs.Status.Condition(v1alpha1.GameServerConditionPodReady).Status)
+		assert.Equal(t, "ContainerNotRunning", gs.Status.Condition(v1alpha1.GameServerConditionSDKConnected).Reason)
+	})
+
+	t.Run("sync from Starting state, with the Pod not scheduled yet", func(t *testing.T) {
+		c, m := newFakeController()
+		gsFixture := newFixture()
+		pod, err := gsFixture.Pod()
+		assert.Nil(t, err)
+		updateCount := 0
+
+		m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
+		})
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			updateCount++
+			gs := patchedGameServer(t, gsFixture, action)
+			assert.Equal(t, v1alpha1.GameServerStateStarting, gs.Status.State)
+			condition := gs.Status.Condition(v1alpha1.GameServerConditionAddressPopulated)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, corev1.ConditionFalse, condition.Status)
+				assert.Equal(t, "PodNotScheduled", condition.Reason)
+			}
+			gsFixture = gs
+			return true, gs, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.gameServerSynced, c.podSynced, c.nodeSynced)
+		defer cancel()
+
+		_, err = c.syncGameServerStartingState(gsFixture)
+		assert.Error(t, err)
+		assert.Equal(t, 1, updateCount)
+
+		// the condition is only updated when it changes
+		_, err = c.syncGameServerStartingState(gsFixture)
+		assert.Error(t, err)
+		assert.Equal(t, 1, updateCount)
 	})
 
 	t.Run("GameServer with unknown state", func(t *testing.T) {
@@ -844,7 +1258,41 @@ func TestControllerCreateGameServerPod(t *testing.T) {
 		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Pod")
 	})
 
-	t.Run("service account", func(t *testing.T) {
+	t.Run("sidecar ping report period", func(t *testing.T) {
+		c, _ := newFakeController()
+		c.sidecarPingReport = 5 * time.Minute
+		sidecar := c.sidecar(newFixture())
+		assert.Contains(t, sidecar.Env, corev1.EnvVar{Name: "PING_REPORT_PERIOD", Value: "5m0s"})
+	})
+
+	t.Run("pod defaults", func(t *testing.T) {
+		c, m := newFakeController()
+		toleration := corev1.Toleration{Key: "dedicated", Value: "gameservers", Effect: corev1.TaintEffectNoSchedule}
+		c.podDefaults = PodDefaults{
+			PriorityClassName: "game",
+			Tolerations:       []corev1.Toleration{toleration},
+			NodeSelector:      map[string]string{"dedicated": "gameservers"},
+		}
+		fixture := newFixture()
+		fixture.Spec.Template.Spec.NodeSelector = map[string]string{"pool": "premium"}
+		created := false
+
+		m.KubeClient.AddReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			created = true
+			pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+			assert.Equal(t, "game", pod.Spec.PriorityClassName)
+			assert.Equal(t, []corev1.Toleration{toleration}, pod.Spec.Tolerations)
+			// set by the GameServer, so not defaulted
+			assert.Equal(t, map[string]string{"pool": "premium"}, pod.Spec.NodeSelector)
+			return true, pod, nil
+		})
+
+		_, err := c.createGameServerPod(fixture)
+		assert.Nil(t, err)
+		assert.True(t, created)
+	})
+
+	t.Run("service account", func(t *testing.T) {
 		c, m := newFakeController()
 		fixture := newFixture()
 		fixture.Spec.Template.Spec.ServiceAccountName = "foobar"
@@ -866,6 +1314,60 @@ func TestControllerCreateGameServerPod(t *testing.T) {
 		assert.True(t, created)
 	})
 
+	t.Run("sdk token", func(t *testing.T) {
+		fixtures := map[string]struct {
+			serviceAccount string
+			token          v1alpha1.SDKToken
+			mounts         []string
+		}{
+			"enabled": {
+				token: v1alpha1.SDKToken{Mount: v1alpha1.SDKTokenEnabled},
+			},
+			"disabled, with service account": {
+				serviceAccount: "foobar",
+				token:          v1alpha1.SDKToken{Mount: v1alpha1.SDKTokenDisabled},
+				mounts:         []string{"/var/run/secrets/kubernetes.io/serviceaccount"},
+			},
+			"projected": {
+				token:  v1alpha1.SDKToken{Mount: v1alpha1.SDKTokenProjected, Audience: "vault"},
+				mounts: []string{"/var/run/secrets/kubernetes.io/serviceaccount", "/var/run/secrets/agones.dev"},
+			},
+		}
+
+		for k, v := range fixtures {
+			t.Run(k, func(t *testing.T) {
+				c, m := newFakeController()
+				fixture := newFixture()
+				fixture.Spec.Template.Spec.ServiceAccountName = v.serviceAccount
+				fixture.Spec.SDKToken = v.token.DeepCopy()
+				fixture.ApplyDefaults()
+
+				created := false
+				m.KubeClient.AddReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					created = true
+					pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+					if v.serviceAccount == "" {
+						assert.Equal(t, "sdk-service-account", pod.Spec.ServiceAccountName)
+					} else {
+						assert.Equal(t, v.serviceAccount, pod.Spec.ServiceAccountName)
+					}
+					var mounts []string
+					for _, vm := range pod.Spec.Containers[0].VolumeMounts {
+						mounts = append(mounts, vm.MountPath)
+					}
+					assert.Equal(t, v.mounts, mounts)
+					assert.Empty(t, pod.Spec.Containers[1].VolumeMounts)
+
+					return true, pod, nil
+				})
+
+				_, err := c.createGameServerPod(fixture)
+				assert.Nil(t, err)
+				assert.True(t, created)
+			})
+		}
+	})
+
 	t.Run("invalid podspec", func(t *testing.T) {
 		c, mocks := newFakeController()
 		fixture := newFixture()
@@ -876,11 +1378,12 @@ func TestControllerCreateGameServerPod(t *testing.T) {
 			podCreated = true
 			return true, nil, k8serrors.NewInvalid(schema.GroupKind{}, "test", field.ErrorList{})
 		})
-		mocks.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		mocks.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			gsUpdated = true
-			ua := action.(k8stesting.UpdateAction)
-			gs := ua.GetObject().(*v1alpha1.GameServer)
+			gs := patchedGameServer(t, fixture, action)
 			assert.Equal(t, v1alpha1.GameServerStateError, gs.Status.State)
+			assert.NotEmpty(t, gs.ObjectMeta.Annotations[v1alpha1.ErrorTimeAnnotation])
+			assert.NotEmpty(t, gs.ObjectMeta.Annotations[v1alpha1.ErrorMessageAnnotation])
 			return true, gs, nil
 		})
 
@@ -916,7 +1419,649 @@ func TestControllerApplyGameServerAddressAndPort(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, gs.Spec.Ports[0].HostPort, gs.Status.Ports[0].Port)
 	assert.Equal(t, ipFixture, gs.Status.Address)
+	assert.Equal(t, node.Status.Addresses, gs.Status.Addresses)
 	assert.Equal(t, node.ObjectMeta.Name, gs.Status.NodeName)
+
+	// the None PortPolicy uses the IP of the Pod, and the ContainerPort
+	gsFixture = &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateStarting}}
+	gsFixture.Spec.Ports[0].PortPolicy = v1alpha1.None
+	gsFixture.Spec.Ports[0].HostPort = 0
+	gsFixture.ApplyDefaults()
+	pod, err = gsFixture.Pod()
+	assert.Nil(t, err)
+	pod.Spec.NodeName = node.ObjectMeta.Name
+	assert.Equal(t, int32(0), pod.Spec.Containers[0].Ports[0].HostPort)
+
+	_, err = c.applyGameServerAddressAndPort(gsFixture.DeepCopy(), pod)
+	assert.EqualError(t, err, "Pod test of GameServer test does not have an IP yet")
+
+	pod.Status.PodIP = "10.0.0.12"
+	gs, err = c.applyGameServerAddressAndPort(gsFixture.DeepCopy(), pod)
+	assert.Nil(t, err)
+	assert.Equal(t, []v1alpha1.GameServerStatusPort{{Name: gs.Spec.Ports[0].Name, Port: gs.Spec.Ports[0].ContainerPort}}, gs.Status.Ports)
+	assert.Equal(t, "10.0.0.12", gs.Status.Address)
+	assert.Empty(t, gs.Status.Addresses)
+	assert.Equal(t, node.ObjectMeta.Name, gs.Status.NodeName)
+	if assert.Len(t, gs.Status.Conditions, 1) {
+		assert.Equal(t, "PodIPFound", gs.Status.Conditions[0].Reason)
+	}
+}
+
+func TestControllerSyncGameServerPodAnnotations(t *testing.T) {
+	t.Parallel()
+
+	newFixture := func() *v1alpha1.GameServer {
+		gs := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateAllocated}}
+		gs.ApplyDefaults()
+		return gs
+	}
+
+	t.Run("Allocated GameServer with new annotations", func(t *testing.T) {
+		c, m := newFakeController()
+		gsFixture := newFixture()
+		pod, err := gsFixture.Pod()
+		assert.Nil(t, err)
+		gsFixture.ObjectMeta.Annotations["map"] = "dust"
+		podUpdated := false
+
+		m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
+		})
+		m.KubeClient.AddReactor("update", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			podUpdated = true
+			ua := action.(k8stesting.UpdateAction)
+			pod := ua.GetObject().(*corev1.Pod)
+			for k, v := range gsFixture.ObjectMeta.Annotations {
+				assert.Equal(t, v, pod.ObjectMeta.Annotations[k])
+			}
+			return true, pod, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.podSynced)
+		defer cancel()
+
+		_, err = c.syncGameServerPodAnnotations(gsFixture)
+		assert.Nil(t, err, "should not error")
+		assert.True(t, podUpdated, "Pod wasn't updated")
+	})
+
+	t.Run("Pod already has the annotations", func(t *testing.T) {
+		c, m := newFakeController()
+		gsFixture := newFixture()
+		gsFixture.ObjectMeta.Annotations["map"] = "dust"
+		pod, err := gsFixture.Pod()
+		assert.Nil(t, err)
+		pod.ObjectMeta.Annotations = map[string]string{}
+		for k, v := range gsFixture.ObjectMeta.Annotations {
+			pod.ObjectMeta.Annotations[k] = v
+		}
+
+		m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
+		})
+		m.KubeClient.AddReactor("update", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not update")
+			return false, nil, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.podSynced)
+		defer cancel()
+
+		_, err = c.syncGameServerPodAnnotations(gsFixture)
+		assert.Nil(t, err, "should not error")
+	})
+
+	t.Run("GameServer is not Allocated", func(t *testing.T) {
+		c, m := newFakeController()
+		gsFixture := newFixture()
+		gsFixture.Status.State = v1alpha1.GameServerStateReady
+		gsFixture.ObjectMeta.Annotations["map"] = "dust"
+
+		m.KubeClient.AddReactor("update", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not update")
+			return false, nil, nil
+		})
+
+		_, err := c.syncGameServerPodAnnotations(gsFixture)
+		assert.Nil(t, err, "should not error")
+	})
+}
+
+func TestControllerSyncGameServerPodSafeToEvict(t *testing.T) {
+	t.Parallel()
+
+	fixtures := map[string]struct {
+		state    v1alpha1.GameServerState
+		policy   string
+		current  string
+		expected string
+	}{
+		"ready":                 {state: v1alpha1.GameServerStateReady, current: "false", expected: "true"},
+		"allocated":             {state: v1alpha1.GameServerStateAllocated, current: "true", expected: "false"},
+		"reserved":              {state: v1alpha1.GameServerStateReserved, current: "true", expected: "false"},
+		"ready, never policy":   {state: v1alpha1.GameServerStateReady, policy: v1alpha1.SafeToEvictNever, current: "true", expected: "false"},
+		"ready, already synced": {state: v1alpha1.GameServerStateReady, current: "true"},
+		"creating":              {state: v1alpha1.GameServerStateCreating, current: "false"},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			c, m := newFakeController()
+			gsFixture := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateCreating}}
+			gsFixture.ApplyDefaults()
+			if v.policy != "" {
+				gsFixture.ObjectMeta.Annotations[v1alpha1.SafeToEvictPolicyAnnotation] = v.policy
+			}
+			pod, err := gsFixture.Pod()
+			assert.Nil(t, err)
+			pod.ObjectMeta.Annotations[v1alpha1.PodSafeToEvictAnnotation] = v.current
+			gsFixture.Status.State = v.state
+			patched := ""
+
+			m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
+			})
+			m.KubeClient.AddReactor("patch", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				pa := action.(k8stesting.PatchAction)
+				assert.Equal(t, pod.ObjectMeta.Name, pa.GetName())
+				patch := map[string]map[string]map[string]string{}
+				assert.Nil(t, json.Unmarshal(pa.GetPatch(), &patch))
+				patched = patch["metadata"]["annotations"][v1alpha1.PodSafeToEvictAnnotation]
+				return true, pod, nil
+			})
+
+			_, cancel := agtesting.StartInformers(m, c.podSynced)
+			defer cancel()
+
+			_, err = c.syncGameServerPodSafeToEvict(gsFixture)
+			assert.Nil(t, err, "should not error")
+			assert.Equal(t, v.expected, patched)
+		})
+	}
+}
+
+func TestControllerSyncGameServerPodAllocatedLabel(t *testing.T) {
+	t.Parallel()
+
+	fixtures := map[string]struct {
+		state    v1alpha1.GameServerState
+		fleet    bool
+		labelled bool
+		patched  bool
+		expected interface{}
+	}{
+		"allocated":                 {state: v1alpha1.GameServerStateAllocated, fleet: true, patched: true, expected: "true"},
+		"allocated, already synced": {state: v1alpha1.GameServerStateAllocated, fleet: true, labelled: true},
+		"ready again":               {state: v1alpha1.GameServerStateReady, fleet: true, labelled: true, patched: true},
+		"ready":                     {state: v1alpha1.GameServerStateReady, fleet: true},
+		"allocated, no fleet":       {state: v1alpha1.GameServerStateAllocated},
+		"shutdown":                  {state: v1alpha1.GameServerStateShutdown, fleet: true, labelled: true},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			c, m := newFakeController()
+			gsFixture := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateCreating}}
+			if v.fleet {
+				gsFixture.ObjectMeta.Labels = map[string]string{v1alpha1.FleetNameLabel: "fleet"}
+			}
+			gsFixture.ApplyDefaults()
+			pod, err := gsFixture.Pod()
+			assert.Nil(t, err)
+			if v.labelled {
+				pod.ObjectMeta.Labels[v1alpha1.PodAllocatedLabel] = "true"
+			}
+			gsFixture.Status.State = v.state
+			patched := false
+			var value interface{}
+
+			m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
+			})
+			m.KubeClient.AddReactor("patch", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				pa := action.(k8stesting.PatchAction)
+				assert.Equal(t, pod.ObjectMeta.Name, pa.GetName())
+				patch := map[string]map[string]map[string]interface{}{}
+				assert.Nil(t, json.Unmarshal(pa.GetPatch(), &patch))
+				patched = true
+				var ok bool
+				value, ok = patch["metadata"]["labels"][v1alpha1.PodAllocatedLabel]
+				assert.True(t, ok)
+				return true, pod, nil
+			})
+
+			_, cancel := agtesting.StartInformers(m, c.podSynced)
+			defer cancel()
+
+			_, err = c.syncGameServerPodAllocatedLabel(gsFixture)
+			assert.Nil(t, err, "should not error")
+			assert.Equal(t, v.patched, patched)
+			assert.Equal(t, v.expected, value)
+		})
+	}
+}
+
+func TestControllerSyncGameServerPodReadyState(t *testing.T) {
+	t.Parallel()
+
+	newFixture := func() *v1alpha1.GameServer {
+		gs := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateScheduled}}
+		gs.Spec.Readiness = v1alpha1.ReadinessPod
+		gs.ApplyDefaults()
+		return gs
+	}
+
+	t.Run("Pod is Ready", func(t *testing.T) {
+		c, m := newFakeController()
+		gsFixture := newFixture()
+		pod, err := gsFixture.Pod()
+		assert.Nil(t, err)
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		gsUpdated := false
+
+		m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
+		})
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gsUpdated = true
+			gs := patchedGameServer(t, gsFixture, action)
+			assert.Equal(t, v1alpha1.GameServerStateRequestReady, gs.Status.State)
+			return true, gs, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.podSynced)
+		defer cancel()
+
+		gs, err := c.syncGameServerPodReadyState(gsFixture)
+		assert.Nil(t, err, "should not error")
+		assert.True(t, gsUpdated, "GameServer wasn't updated")
+		assert.Equal(t, v1alpha1.GameServerStateRequestReady, gs.Status.State)
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Pod is Ready")
+	})
+
+	t.Run("Pod is not Ready", func(t *testing.T) {
+		c, m := newFakeController()
+		gsFixture := newFixture()
+		pod, err := gsFixture.Pod()
+		assert.Nil(t, err)
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}}
+
+		m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
+		})
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not update")
+			return false, nil, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.podSynced)
+		defer cancel()
+
+		gs, err := c.syncGameServerPodReadyState(gsFixture)
+		assert.Nil(t, err, "should not error")
+		assert.Equal(t, v1alpha1.GameServerStateScheduled, gs.Status.State)
+	})
+
+	t.Run("SDK readiness", func(t *testing.T) {
+		testNoChange(t, v1alpha1.GameServerStateScheduled, func(c *Controller, fixture *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+			return c.syncGameServerPodReadyState(fixture)
+		})
+	})
+
+	t.Run("GameServer with non zero deletion datetime", func(t *testing.T) {
+		testWithNonZeroDeletionTimestamp(t, func(c *Controller, fixture *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+			return c.syncGameServerPodReadyState(fixture)
+		})
+	})
+}
+
+func TestControllerSyncGameServerConditions(t *testing.T) {
+	t.Parallel()
+
+	newFixture := func() *v1alpha1.GameServer {
+		gs := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReady}}
+		gs.ApplyDefaults()
+		return gs
+	}
+
+	t.Run("Pod readiness changed", func(t *testing.T) {
+		c, m := newFakeController()
+		gsFixture := newFixture()
+		gsFixture.Status.SetCondition(v1alpha1.GameServerConditionPodReady, corev1.ConditionTrue, "PodReady", "Pod is Ready")
+		pod, err := gsFixture.Pod()
+		assert.Nil(t, err)
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse,
+			Reason: "ContainersNotReady", Message: "containers with unready status: [container]"}}
+		gsUpdated := false
+
+		m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
+		})
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gsUpdated = true
+			gs := patchedGameServer(t, gsFixture, action)
+			return true, gs, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.podSynced)
+		defer cancel()
+
+		gs, err := c.syncGameServerConditions(gsFixture)
+		assert.Nil(t, err, "should not error")
+		assert.True(t, gsUpdated, "GameServer wasn't updated")
+		assert.Equal(t, v1alpha1.GameServerStateReady, gs.Status.State)
+		condition := gs.Status.Condition(v1alpha1.GameServerConditionPodReady)
+		if assert.NotNil(t, condition) {
+			assert.Equal(t, corev1.ConditionFalse, condition.Status)
+			assert.Equal(t, "ContainersNotReady", condition.Reason)
+			assert.Equal(t, "containers with unready status: [container]", condition.Message)
+		}
+	})
+
+	t.Run("Pod unchanged", func(t *testing.T) {
+		c, m := newFakeController()
+		gsFixture := newFixture()
+		pod, err := gsFixture.Pod()
+		assert.Nil(t, err)
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		setPodConditions(gsFixture, pod)
+
+		m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
+		})
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not update")
+			return false, nil, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.podSynced)
+		defer cancel()
+
+		_, err = c.syncGameServerConditions(gsFixture)
+		assert.Nil(t, err, "should not error")
+	})
+
+	t.Run("SDK connection", func(t *testing.T) {
+		gsFixture := newFixture()
+		pod, err := gsFixture.Pod()
+		assert.Nil(t, err)
+
+		assertSDKConnected := func(status corev1.ConditionStatus, reason string) {
+			condition := gsFixture.Status.Condition(v1alpha1.GameServerConditionSDKConnected)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, status, condition.Status)
+				assert.Equal(t, reason, condition.Reason)
+			}
+		}
+
+		assert.True(t, setSDKConnectedCondition(gsFixture, pod))
+		assertSDKConnected(corev1.ConditionFalse, "ContainerNotRunning")
+
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: gsFixture.Spec.Container,
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}}
+		assert.True(t, setSDKConnectedCondition(gsFixture, pod))
+		assertSDKConnected(corev1.ConditionFalse, "SDKNotCalled")
+		assert.False(t, setSDKConnectedCondition(gsFixture, pod))
+
+		gsFixture.Status.SDK = &v1alpha1.SDKStatus{FirstCall: "Health", ConnectedTime: metav1.Now()}
+		assert.True(t, setSDKConnectedCondition(gsFixture, pod))
+		assertSDKConnected(corev1.ConditionTrue, "SDKCalled")
+		assert.Equal(t, "Received Health from the game server",
+			gsFixture.Status.Condition(v1alpha1.GameServerConditionSDKConnected).Message)
+	})
+
+	t.Run("GameServer without a Pod yet", func(t *testing.T) {
+		testNoChange(t, v1alpha1.GameServerStateCreating, func(c *Controller, fixture *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+			return c.syncGameServerConditions(fixture)
+		})
+	})
+
+	t.Run("GameServer with non zero deletion datetime", func(t *testing.T) {
+		testWithNonZeroDeletionTimestamp(t, func(c *Controller, fixture *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+			return c.syncGameServerConditions(fixture)
+		})
+	})
+}
+
+func TestControllerSyncGameServerReservedState(t *testing.T) {
+	t.Parallel()
+
+	newFixture := func(until *metav1.Time) *v1alpha1.GameServer {
+		gs := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReserved, ReservedUntil: until}}
+		gs.ApplyDefaults()
+		return gs
+	}
+
+	t.Run("reservation has expired", func(t *testing.T) {
+		c, m := newFakeController()
+		past := metav1.NewTime(time.Now().Add(-time.Second))
+		fixture := newFixture(&past)
+		gsUpdated := false
+
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gsUpdated = true
+			gs := patchedGameServer(t, fixture, action)
+			assert.Equal(t, v1alpha1.GameServerStateRequestReady, gs.Status.State)
+			assert.Nil(t, gs.Status.ReservedUntil)
+			return true, gs, nil
+		})
+
+		gs, err := c.syncGameServerReservedState(fixture)
+		assert.NoError(t, err)
+		assert.True(t, gsUpdated, "GameServer wasn't updated")
+		assert.Equal(t, v1alpha1.GameServerStateRequestReady, gs.Status.State)
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Reservation expired")
+	})
+
+	t.Run("reservation has not expired, or has no expiry", func(t *testing.T) {
+		c, m := newFakeController()
+		future := metav1.NewTime(time.Now().Add(time.Hour))
+
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not update")
+			return false, nil, nil
+		})
+
+		for _, fixture := range []*v1alpha1.GameServer{newFixture(&future), newFixture(nil)} {
+			gs, err := c.syncGameServerReservedState(fixture)
+			assert.NoError(t, err)
+			assert.Equal(t, v1alpha1.GameServerStateReserved, gs.Status.State)
+		}
+	})
+}
+
+func TestControllerSyncGameServerErrorState(t *testing.T) {
+	t.Parallel()
+
+	newFixture := func(erroredAt time.Time) *v1alpha1.GameServer {
+		gs := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default",
+			Annotations: map[string]string{
+				v1alpha1.ErrorTimeAnnotation:    erroredAt.UTC().Format(time.RFC3339),
+				v1alpha1.ErrorMessageAnnotation: "Pod could not be created",
+			}},
+			Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateError}}
+		gs.ApplyDefaults()
+		return gs
+	}
+
+	t.Run("retention has passed", func(t *testing.T) {
+		c, m := newFakeController()
+		deleted := false
+
+		m.AgonesClient.AddReactor("delete", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			deleted = true
+			assert.Equal(t, "test", action.(k8stesting.DeleteAction).GetName())
+			return true, nil, nil
+		})
+
+		_, err := c.syncGameServerErrorState(newFixture(time.Now().Add(-2 * time.Hour)))
+		assert.NoError(t, err)
+		assert.True(t, deleted, "GameServer should be deleted")
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Pod could not be created")
+	})
+
+	t.Run("retention has not passed, is disabled, or GameServer is owned", func(t *testing.T) {
+		c, m := newFakeController()
+
+		m.AgonesClient.AddReactor("delete", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not delete")
+			return false, nil, nil
+		})
+
+		_, err := c.syncGameServerErrorState(newFixture(time.Now()))
+		assert.NoError(t, err)
+
+		owned := newFixture(time.Now().Add(-2 * time.Hour))
+		owned.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(
+			&v1alpha1.GameServerSet{ObjectMeta: metav1.ObjectMeta{Name: "gss", UID: "1234"}}, v1alpha1.SchemeGroupVersion.WithKind("GameServerSet"))}
+		_, err = c.syncGameServerErrorState(owned)
+		assert.NoError(t, err)
+
+		c.errorRetention = 0
+		_, err = c.syncGameServerErrorState(newFixture(time.Now().Add(-2 * time.Hour)))
+		assert.NoError(t, err)
+	})
+}
+
+func TestControllerSyncGameServerUnhealthyState(t *testing.T) {
+	t.Parallel()
+
+	newFixture := func() *v1alpha1.GameServer {
+		gs := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateUnhealthy,
+				Address: "1.2.3.4", Addresses: []corev1.NodeAddress{{Address: "1.2.3.4", Type: corev1.NodeExternalIP}},
+				NodeName: nodeFixtureName, Restarts: 1}}
+		gs.Spec.RestartPolicy = v1alpha1.RestartOnFailure
+		gs.ApplyDefaults()
+		return gs
+	}
+	newPod := func(gs *v1alpha1.GameServer, failedAt time.Time) *corev1.Pod {
+		pod, err := gs.Pod()
+		assert.NoError(t, err)
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: gs.Spec.Container,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(failedAt)}}}}
+		return pod
+	}
+
+	t.Run("failed pod after back-off", func(t *testing.T) {
+		c, m := newFakeController()
+		gs := newFixture()
+		pod := newPod(gs, time.Now().Add(-time.Minute))
+		deleted := false
+
+		m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
+		})
+		m.KubeClient.AddReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			deleted = true
+			assert.Equal(t, pod.ObjectMeta.Name, action.(k8stesting.DeleteAction).GetName())
+			return true, nil, nil
+		})
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not update")
+			return false, nil, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.podSynced)
+		defer cancel()
+
+		_, err := c.syncGameServerUnhealthyState(gs)
+		assert.NoError(t, err)
+		assert.True(t, deleted, "Pod should be deleted")
+	})
+
+	t.Run("failed pod within back-off", func(t *testing.T) {
+		c, m := newFakeController()
+		gs := newFixture()
+		pod := newPod(gs, time.Now())
+
+		m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
+		})
+		m.KubeClient.AddReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not delete")
+			return false, nil, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.podSynced)
+		defer cancel()
+
+		_, err := c.syncGameServerUnhealthyState(gs)
+		assert.NoError(t, err)
+	})
+
+	t.Run("no pod", func(t *testing.T) {
+		c, m := newFakeController()
+		fixture := newFixture()
+		gsUpdated := false
+
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gsUpdated = true
+			gs := patchedGameServer(t, fixture, action)
+			assert.Equal(t, v1alpha1.GameServerStateCreating, gs.Status.State)
+			assert.Equal(t, int32(2), gs.Status.Restarts)
+			assert.Empty(t, gs.Status.Address)
+			assert.Empty(t, gs.Status.Addresses)
+			assert.Empty(t, gs.Status.NodeName)
+			return true, gs, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.podSynced)
+		defer cancel()
+
+		gs, err := c.syncGameServerUnhealthyState(fixture)
+		assert.NoError(t, err)
+		assert.True(t, gsUpdated, "GameServer should be updated")
+		assert.Equal(t, v1alpha1.GameServerStateCreating, gs.Status.State)
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "restart 2 of 6")
+	})
+
+	t.Run("never restarted, backoff limit reached, or GameServer is owned", func(t *testing.T) {
+		c, m := newFakeController()
+
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not update")
+			return false, nil, nil
+		})
+		m.KubeClient.AddReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not delete")
+			return false, nil, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m, c.podSynced)
+		defer cancel()
+
+		never := newFixture()
+		never.Spec.RestartPolicy = v1alpha1.RestartNever
+		limit := newFixture()
+		limit.Status.Restarts = limit.Spec.BackoffLimit
+		owned := newFixture()
+		owned.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(
+			&v1alpha1.GameServerSet{ObjectMeta: metav1.ObjectMeta{Name: "gss", UID: "1234"}}, v1alpha1.SchemeGroupVersion.WithKind("GameServerSet"))}
+
+		for _, fixture := range []*v1alpha1.GameServer{never, limit, owned} {
+			gs, err := c.syncGameServerUnhealthyState(fixture)
+			assert.NoError(t, err)
+			assert.Equal(t, v1alpha1.GameServerStateUnhealthy, gs.Status.State)
+		}
+	})
+}
+
+func TestRestartBackoff(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 10*time.Second, restartBackoff(0))
+	assert.Equal(t, 20*time.Second, restartBackoff(1))
+	assert.Equal(t, 160*time.Second, restartBackoff(4))
+	assert.Equal(t, 5*time.Minute, restartBackoff(5))
+	assert.Equal(t, 5*time.Minute, restartBackoff(100))
 }
 
 func TestControllerSyncGameServerRequestReadyState(t *testing.T) {
@@ -936,10 +2081,9 @@ func TestControllerSyncGameServerRequestReadyState(t *testing.T) {
 		m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
 		})
-		m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			gsUpdated = true
-			ua := action.(k8stesting.UpdateAction)
-			gs := ua.GetObject().(*v1alpha1.GameServer)
+			gs := patchedGameServer(t, gsFixture, action)
 			assert.Equal(t, v1alpha1.GameServerStateReady, gs.Status.State)
 			return true, gs, nil
 		})
@@ -966,7 +2110,8 @@ func TestControllerSyncGameServerRequestReadyState(t *testing.T) {
 		gsUpdated := false
 
 		ipFixture := "12.12.12.12"
-		nodeFixture := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName}, Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Address: ipFixture, Type: corev1.NodeExternalIP}}}}
+		nodeFixture := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName,
+			Labels: map[string]string{v1alpha1.TopologyZoneLabel: "us-west1-a"}}, Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Address: ipFixture, Type: corev1.NodeExternalIP}}}}
 
 		m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			return true, &corev1.NodeList{Items: []corev1.Node{nodeFixture}}, nil
@@ -975,10 +2120,9 @@ func TestControllerSyncGameServerRequestReadyState(t *testing.T) {
 		m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
 		})
-		m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			gsUpdated = true
-			ua := action.(k8stesting.UpdateAction)
-			gs := ua.GetObject().(*v1alpha1.GameServer)
+			gs := patchedGameServer(t, gsFixture, action)
 			assert.Equal(t, v1alpha1.GameServerStateReady, gs.Status.State)
 			return true, gs, nil
 		})
@@ -993,11 +2137,96 @@ func TestControllerSyncGameServerRequestReadyState(t *testing.T) {
 
 		assert.Equal(t, gs.Status.NodeName, nodeFixture.ObjectMeta.Name)
 		assert.Equal(t, gs.Status.Address, ipFixture)
+		assert.Equal(t, "us-west1-a", gs.ObjectMeta.Labels[v1alpha1.TopologyZoneLabel])
 
 		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Address and port populated")
 		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "SDK.Ready() complete")
 	})
 
+	t.Run("GameServer with a PreReady webhook", func(t *testing.T) {
+		status := http.StatusOK
+		var received *v1alpha1.GameServer
+		var receivedURL string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			receivedURL = "http://" + r.Host + r.URL.String()
+			received = &v1alpha1.GameServer{}
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(received))
+			w.WriteHeader(status)
+		}))
+		defer ts.Close()
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		// Services resolve to the test servers by name
+		services := map[string]string{"directory.default.svc:80": ts.Listener.Addr().String(), "closed.default.svc:80": closed.Listener.Addr().String()}
+		client := &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, services[addr])
+		}}}
+
+		fixtures := map[string]struct {
+			service       string
+			status        int
+			failurePolicy v1alpha1.PreReadyFailurePolicy
+			expectReady   bool
+		}{
+			"ok":                  {service: "directory", status: http.StatusOK, expectReady: true},
+			"not ok":              {service: "directory", status: http.StatusServiceUnavailable, failurePolicy: v1alpha1.PreReadyIgnore},
+			"unreachable, fail":   {service: "closed", failurePolicy: v1alpha1.PreReadyFail},
+			"unreachable, ignore": {service: "closed", failurePolicy: v1alpha1.PreReadyIgnore, expectReady: true},
+		}
+
+		for k, v := range fixtures {
+			t.Run(k, func(t *testing.T) {
+				c, m := newFakeController()
+				c.preReadyClient = client
+				status = v.status
+				received = nil
+
+				gsFixture := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "1234"},
+					Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateRequestReady}}
+				gsFixture.Spec.PreReady = &v1alpha1.PreReadyWebhook{Service: v1alpha1.PreReadyService{Name: v.service, Path: "/register"},
+					FailurePolicy: v.failurePolicy}
+				gsFixture.ApplyDefaults()
+				gsFixture.Status.NodeName = "node"
+				gsFixture.Status.Address = ipFixture
+				gsUpdated := false
+
+				m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					gsUpdated = true
+					gs := patchedGameServer(t, gsFixture, action)
+					return true, gs, nil
+				})
+
+				// the webhook is called in the background, so the first sync doesn't change the GameServer
+				gs, err := c.syncGameServerRequestReadyState(gsFixture)
+				assert.Nil(t, err)
+				assert.False(t, gsUpdated)
+				assert.Equal(t, v1alpha1.GameServerStateRequestReady, gs.Status.State)
+				assert.True(t, waitForPreReadyCall(c, gsFixture.ObjectMeta.UID), "PreReady webhook call did not complete")
+
+				gs, err = c.syncGameServerRequestReadyState(gsFixture)
+				assert.Equal(t, v.expectReady, gsUpdated)
+				if v.expectReady {
+					assert.Nil(t, err)
+					assert.Equal(t, v1alpha1.GameServerStateReady, gs.Status.State)
+				} else {
+					assert.Error(t, err)
+					assert.Equal(t, v1alpha1.GameServerStateRequestReady, gs.Status.State)
+					agtesting.AssertEventContains(t, m.FakeRecorder.Events, "PreReady webhook of GameServer test")
+				}
+				assert.Empty(t, c.preReadyCalls, "the result of the PreReady webhook call should have been used")
+				if v.service == "directory" {
+					if assert.NotNil(t, received) {
+						assert.Equal(t, "http://directory.default.svc:80/register", receivedURL)
+						assert.Equal(t, "test", received.ObjectMeta.Name)
+						assert.Equal(t, ipFixture, received.Status.Address)
+					}
+				}
+			})
+		}
+	})
+
 	for _, s := range []v1alpha1.GameServerState{"Unknown", v1alpha1.GameServerStateUnhealthy} {
 		name := fmt.Sprintf("GameServer with %s state", s)
 		t.Run(name, func(t *testing.T) {
@@ -1060,6 +2289,9 @@ func TestControllerAddress(t *testing.T) {
 
 	fixture := map[string]struct {
 		node            corev1.Node
+		priority        []corev1.NodeAddressType
+		preferIPv6      bool
+		addressLabel    string
 		expectedAddress string
 	}{
 		"node with external ip": {
@@ -1078,6 +2310,81 @@ func TestControllerAddress(t *testing.T) {
 				}}},
 			expectedAddress: "9.9.9.8",
 		},
+		"internal ip preferred": {
+			node: corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName},
+				Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+					{Address: "9.9.9.8", Type: corev1.NodeExternalIP},
+					{Address: "12.12.12.12", Type: corev1.NodeInternalIP},
+				}}},
+			priority:        []corev1.NodeAddressType{corev1.NodeInternalIP, corev1.NodeExternalIP},
+			expectedAddress: "12.12.12.12",
+		},
+		"external dns preferred": {
+			node: corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName},
+				Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+					{Address: "9.9.9.8", Type: corev1.NodeExternalIP},
+					{Address: "node.example.com", Type: corev1.NodeExternalDNS},
+				}}},
+			priority:        []corev1.NodeAddressType{corev1.NodeExternalDNS, corev1.NodeExternalIP},
+			expectedAddress: "node.example.com",
+		},
+		"falls back to the next type": {
+			node: corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName},
+				Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+					{Address: "9.9.9.8", Type: corev1.NodeExternalIP},
+				}}},
+			priority:        []corev1.NodeAddressType{corev1.NodeExternalDNS, corev1.NodeExternalIP},
+			expectedAddress: "9.9.9.8",
+		},
+		"ipv6 preferred": {
+			node: corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName},
+				Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+					{Address: "9.9.9.8", Type: corev1.NodeExternalIP},
+					{Address: "2001:db8::1", Type: corev1.NodeExternalIP},
+				}}},
+			preferIPv6:      true,
+			expectedAddress: "2001:db8::1",
+		},
+		"ipv6 preferred, but only ipv4": {
+			node: corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName},
+				Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+					{Address: "9.9.9.8", Type: corev1.NodeExternalIP},
+					{Address: "2001:db8::1", Type: corev1.NodeInternalIP},
+				}}},
+			preferIPv6:      true,
+			expectedAddress: "9.9.9.8",
+		},
+		"ipv6 not preferred": {
+			node: corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName},
+				Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+					{Address: "2001:db8::1", Type: corev1.NodeExternalIP},
+					{Address: "9.9.9.8", Type: corev1.NodeExternalIP},
+				}}},
+			expectedAddress: "2001:db8::1",
+		},
+		"address from node label": {
+			node: corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName, Labels: map[string]string{"agones.dev/public-address": "7.7.7.7"}},
+				Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+					{Address: "9.9.9.8", Type: corev1.NodeExternalIP},
+				}}},
+			addressLabel:    "agones.dev/public-address",
+			expectedAddress: "7.7.7.7",
+		},
+		"address label not on node": {
+			node: corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName, Labels: map[string]string{"agones.dev/public-address": ""}},
+				Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+					{Address: "9.9.9.8", Type: corev1.NodeExternalIP},
+				}}},
+			addressLabel:    "agones.dev/public-address",
+			expectedAddress: "9.9.9.8",
+		},
+		"address label not configured": {
+			node: corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName, Labels: map[string]string{"agones.dev/public-address": "7.7.7.7"}},
+				Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+					{Address: "9.9.9.8", Type: corev1.NodeExternalIP},
+				}}},
+			expectedAddress: "9.9.9.8",
+		},
 	}
 
 	dummyGS := &v1alpha1.GameServer{}
@@ -1086,6 +2393,11 @@ func TestControllerAddress(t *testing.T) {
 	for name, fixture := range fixture {
 		t.Run(name, func(t *testing.T) {
 			c, mocks := newFakeController()
+			if fixture.priority != nil {
+				c.nodeAddressPriority = fixture.priority
+			}
+			c.preferIPv6Address = fixture.preferIPv6
+			c.nodeAddressLabel = fixture.addressLabel
 			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"},
 				Spec: corev1.PodSpec{NodeName: fixture.node.ObjectMeta.Name}}
 
@@ -1102,11 +2414,67 @@ func TestControllerAddress(t *testing.T) {
 			_, cancel := agtesting.StartInformers(mocks, c.gameServerSynced, podSynced, nodeSynced)
 			defer cancel()
 
-			addr, err := c.address(dummyGS, &pod)
+			addr, addresses, err := c.address(dummyGS, &pod)
 			assert.Nil(t, err)
 			assert.Equal(t, fixture.expectedAddress, addr)
+			assert.Equal(t, fixture.node.Status.Addresses, addresses)
 		})
 	}
+
+	t.Run("no address of a type in the priority", func(t *testing.T) {
+		c, mocks := newFakeController()
+		c.nodeAddressPriority = []corev1.NodeAddressType{corev1.NodeExternalDNS}
+		node := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeFixtureName},
+			Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Address: "9.9.9.8", Type: corev1.NodeExternalIP}}}}
+		pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}, Spec: corev1.PodSpec{NodeName: node.ObjectMeta.Name}}
+
+		mocks.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &corev1.NodeList{Items: []corev1.Node{node}}, nil
+		})
+
+		_, cancel := agtesting.StartInformers(mocks, c.nodeSynced)
+		defer cancel()
+
+		_, _, err := c.address(dummyGS, &pod)
+		assert.Error(t, err)
+	})
+}
+
+func TestPatchGameServer(t *testing.T) {
+	t.Parallel()
+
+	fixture := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateScheduled}}
+	fixture.ApplyDefaults()
+	gsCopy := fixture.DeepCopy()
+	gsCopy.Status.State = v1alpha1.GameServerStateRequestReady
+
+	t.Run("patch", func(t *testing.T) {
+		m := agtesting.NewMocks()
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gs := patchedGameServer(t, fixture, action)
+			return true, gs, nil
+		})
+
+		gs, err := patchGameServer(m.AgonesClient.StableV1alpha1(), fixture, gsCopy)
+		assert.NoError(t, err)
+		assert.Equal(t, v1alpha1.GameServerStateRequestReady, gs.Status.State)
+	})
+
+	t.Run("failed state test is not retried", func(t *testing.T) {
+		m := agtesting.NewMocks()
+		count := 0
+		m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			count++
+			return true, nil, k8serrors.NewInvalid(schema.GroupKind{}, "test", field.ErrorList{})
+		})
+
+		gs, err := patchGameServer(m.AgonesClient.StableV1alpha1(), fixture, gsCopy)
+		assert.Error(t, err)
+		assert.True(t, k8serrors.IsInvalid(errors.Cause(err)))
+		assert.Equal(t, 1, count)
+		assert.Equal(t, fixture, gs)
+	})
 }
 
 func TestControllerGameServerPod(t *testing.T) {
@@ -1236,7 +2604,7 @@ func testNoChange(t *testing.T, state v1alpha1.GameServerState, f func(*Controll
 		Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: state}}
 	fixture.ApplyDefaults()
 	updated := false
-	mocks.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+	mocks.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
 		updated = true
 		return true, nil, nil
 	})
@@ -1256,7 +2624,7 @@ func testWithNonZeroDeletionTimestamp(t *testing.T, f func(*Controller, *v1alpha
 		Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateShutdown}}
 	fixture.ApplyDefaults()
 	updated := false
-	mocks.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+	mocks.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
 		updated = true
 		return true, nil, nil
 	})
@@ -1271,14 +2639,27 @@ func testWithNonZeroDeletionTimestamp(t *testing.T, f func(*Controller, *v1alpha
 func newFakeController() (*Controller, agtesting.Mocks) {
 	m := agtesting.NewMocks()
 	wh := webhooks.NewWebHook(http.NewServeMux())
-	c := NewController(wh, healthcheck.NewHandler(),
-		10, 20, "sidecar:dev", false,
-		resource.MustParse("0.05"), resource.MustParse("0.1"), "sdk-service-account",
+	c := NewController(wh, healthcheck.NewHandler(), time.Minute,
+		10, 20, map[string]PortRange{"query": {MinPort: 30, MaxPort: 35}}, false, 16, "sidecar:dev", false,
+		resource.MustParse("0.05"), resource.MustParse("0.1"), "sdk-service-account", SidecarToken{}, 0, time.Hour,
+		[]corev1.NodeAddressType{corev1.NodeExternalIP, corev1.NodeInternalIP}, false, "", PodDefaults{},
 		m.KubeClient, m.KubeInformerFactory, m.ExtClient, m.AgonesClient, m.AgonesInformerFactory)
 	c.recorder = m.FakeRecorder
 	return c, m
 }
 
+// waitForPreReadyCall waits for the PreReady webhook call of the GameServer to complete,
+// and returns false if it doesn't
+func waitForPreReadyCall(c *Controller, uid types.UID) bool {
+	err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		c.preReadyMutex.Lock()
+		defer c.preReadyMutex.Unlock()
+		call, ok := c.preReadyCalls[uid]
+		return ok && call.done, nil
+	})
+	return err == nil
+}
+
 func newSingleContainerSpec() v1alpha1.GameServerSpec {
 	return v1alpha1.GameServerSpec{
 		Ports: []v1alpha1.GameServerPort{{ContainerPort: 7777, HostPort: 9999, PortPolicy: v1alpha1.Static}},
@@ -1289,3 +2670,67 @@ func newSingleContainerSpec() v1alpha1.GameServerSpec {
 		},
 	}
 }
+
+// patchedGameServer applies the JSON Patch of a patch action to gs, as the API server would,
+// and returns the result. The test fails if any of the test operations of the patch fail.
+func patchedGameServer(t *testing.T, gs *v1alpha1.GameServer, action k8stesting.Action) *v1alpha1.GameServer {
+	var doc interface{}
+	b, err := json.Marshal(gs)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(b, &doc))
+
+	var ops []jsonpatch.JsonPatchOperation
+	assert.NoError(t, json.Unmarshal(action.(k8stesting.PatchAction).GetPatch(), &ops))
+	for _, op := range ops {
+		doc = applyPatchOperation(t, doc, strings.Split(op.Path, "/")[1:], op)
+	}
+
+	result := &v1alpha1.GameServer{}
+	b, err = json.Marshal(doc)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(b, result))
+	return result
+}
+
+// applyPatchOperation applies op to the value at path within doc, and returns the resulting doc
+func applyPatchOperation(t *testing.T, doc interface{}, path []string, op jsonpatch.JsonPatchOperation) interface{} {
+	if len(path) == 0 {
+		if op.Operation == "test" {
+			assert.Equal(t, op.Value, doc, "patch test operation failed: %s", op.Path)
+			return doc
+		}
+		return op.Value
+	}
+
+	key := strings.Replace(strings.Replace(path[0], "~1", "/", -1), "~0", "~", -1)
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 && op.Operation == "remove" {
+			delete(d, key)
+			return d
+		}
+		d[key] = applyPatchOperation(t, d[key], path[1:], op)
+		return d
+	case []interface{}:
+		if key == "-" {
+			return append(d, op.Value)
+		}
+		i, err := strconv.Atoi(key)
+		assert.NoError(t, err)
+		if len(path) == 1 {
+			switch op.Operation {
+			case "remove":
+				return append(d[:i], d[i+1:]...)
+			case "add":
+				return append(d[:i], append([]interface{}{op.Value}, d[i:]...)...)
+			}
+		}
+		d[i] = applyPatchOperation(t, d[i], path[1:], op)
+		return d
+	case nil:
+		// adding to a field that doesn't exist yet
+		return applyPatchOperation(t, map[string]interface{}{}, path, op)
+	}
+	assert.FailNow(t, "invalid patch path", op.Path)
+	return doc
+}
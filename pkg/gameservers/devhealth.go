@@ -0,0 +1,148 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// devHealthCheckTimeout is how long a health check of a development GameServer can take
+const devHealthCheckTimeout = 2 * time.Second
+
+// devHealthChecks tracks the health checks of development GameServers that have the
+// DevHealthCheckAnnotation, which are made against the /gshealthz endpoint of the local SDK
+// server that they run alongside
+type devHealthChecks struct {
+	client *http.Client
+	mu     sync.Mutex
+	checks map[string]devHealthCheck
+}
+
+// devHealthCheck is when a development GameServer was last health checked,
+// and how many of its health checks have failed in a row
+type devHealthCheck struct {
+	lastChecked time.Time
+	failures    int32
+}
+
+func newDevHealthChecks() *devHealthChecks {
+	return &devHealthChecks{
+		client: &http.Client{Timeout: devHealthCheckTimeout},
+		checks: map[string]devHealthCheck{},
+	}
+}
+
+// check checks the health of the GameServer, if its health period has passed since it was last checked,
+// and returns how many health checks have failed in a row, and how long until it should be checked again
+func (d *devHealthChecks) check(gs *v1alpha1.GameServer, port int) (int32, time.Duration) {
+	key := gs.ObjectMeta.Namespace + "/" + gs.ObjectMeta.Name
+	period := time.Duration(gs.Spec.Health.PeriodSeconds) * time.Second
+
+	d.mu.Lock()
+	check := d.checks[key]
+	d.mu.Unlock()
+	if remaining := check.lastChecked.Add(period).Sub(time.Now()); remaining > 0 {
+		return check.failures, remaining
+	}
+
+	check.lastChecked = time.Now()
+	if d.healthy(gs, port) {
+		check.failures = 0
+	} else {
+		check.failures++
+	}
+
+	d.mu.Lock()
+	d.checks[key] = check
+	d.mu.Unlock()
+	return check.failures, period
+}
+
+// healthy returns if the /gshealthz endpoint of the local SDK server responds with a 200
+func (d *devHealthChecks) healthy(gs *v1alpha1.GameServer, port int) bool {
+	address, _ := gs.GetDevAddress()
+	resp, err := d.client.Get(fmt.Sprintf("http://%s/gshealthz", net.JoinHostPort(address, strconv.Itoa(port))))
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	return resp.StatusCode == http.StatusOK
+}
+
+// forget stops tracking the health checks of the GameServer
+func (d *devHealthChecks) forget(gs *v1alpha1.GameServer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.checks, gs.ObjectMeta.Namespace+"/"+gs.ObjectMeta.Name)
+}
+
+// syncDevelopmentGameServerHealth health checks a development GameServer with the DevHealthCheckAnnotation
+// once it has started, and moves it to Unhealthy once FailureThreshold health checks have failed in a row,
+// so a local game server that has crashed doesn't stay Ready.
+func (c *Controller) syncDevelopmentGameServerHealth(gs *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+	if _, isDev := gs.GetDevAddress(); !isDev {
+		return gs, nil
+	}
+	port, ok := gs.GetDevHealthCheckPort()
+	if !ok || gs.Spec.Health.Disabled {
+		return gs, nil
+	}
+
+	if gs.IsBeingDeleted() {
+		c.devHealthChecks.forget(gs)
+		return gs, nil
+	}
+	switch gs.Status.State {
+	case v1alpha1.GameServerStateScheduled, v1alpha1.GameServerStateReady,
+		v1alpha1.GameServerStateReserved, v1alpha1.GameServerStateAllocated:
+	default:
+		c.devHealthChecks.forget(gs)
+		return gs, nil
+	}
+
+	delay := time.Duration(gs.Spec.Health.InitialDelaySeconds) * time.Second
+	if remaining := gs.ObjectMeta.CreationTimestamp.Add(delay).Sub(time.Now()); remaining > 0 {
+		c.workerqueue.EnqueueAfter(gs, remaining)
+		return gs, nil
+	}
+
+	failures, next := c.devHealthChecks.check(gs, port)
+	if failures < gs.Spec.Health.FailureThreshold {
+		c.workerqueue.EnqueueAfter(gs, next)
+		return gs, nil
+	}
+
+	c.loggerForGameServer(gs).WithField("failures", failures).Info("Development GameServer has failed its health checks")
+	c.devHealthChecks.forget(gs)
+
+	gsCopy := gs.DeepCopy()
+	gsCopy.Status.State = v1alpha1.GameServerStateUnhealthy
+	gs, err := patchGameServer(c.gameServerGetter, gs, gsCopy)
+	if err != nil {
+		return gs, errors.Wrapf(err, "error updating development GameServer %s to Unhealthy", gsCopy.ObjectMeta.Name)
+	}
+	c.recorder.Event(gs, corev1.EventTypeWarning, string(gs.Status.State), "Local SDK server health check failed")
+
+	return gs, nil
+}
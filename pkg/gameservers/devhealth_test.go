@@ -0,0 +1,169 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	agtesting "agones.dev/agones/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestControllerSyncDevelopmentGameServerHealth(t *testing.T) {
+	t.Parallel()
+
+	// newFixture returns a Ready development GameServer that is health checked through the given local SDK server
+	newFixture := func(t *testing.T, server *httptest.Server) *v1alpha1.GameServer {
+		u, err := url.Parse(server.URL)
+		assert.Nil(t, err)
+		host, port, err := net.SplitHostPort(u.Host)
+		assert.Nil(t, err)
+
+		gs := &v1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default",
+				Annotations: map[string]string{
+					v1alpha1.DevAddressAnnotation:     host,
+					v1alpha1.DevHealthCheckAnnotation: port,
+				}},
+			Spec: v1alpha1.GameServerSpec{
+				Ports: []v1alpha1.GameServerPort{{ContainerPort: 7777, HostPort: 7777, PortPolicy: v1alpha1.Static}},
+			},
+		}
+		gs.ApplyDefaults()
+		gs.Spec.Health.InitialDelaySeconds = 0
+		gs.Spec.Health.FailureThreshold = 2
+		gs.Status.State = v1alpha1.GameServerStateReady
+		return gs
+	}
+
+	newServer := func(status *int32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/gshealthz", r.URL.Path)
+			w.WriteHeader(int(atomic.LoadInt32(status)))
+		}))
+	}
+
+	t.Run("healthy", func(t *testing.T) {
+		status := int32(http.StatusOK)
+		server := newServer(&status)
+		defer server.Close()
+
+		c, mocks := newFakeController()
+		mocks.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not update a healthy GameServer")
+			return true, nil, nil
+		})
+
+		fixture := newFixture(t, server)
+		result, err := c.syncDevelopmentGameServerHealth(fixture)
+		assert.Nil(t, err)
+		assert.Equal(t, v1alpha1.GameServerStateReady, result.Status.State)
+	})
+
+	t.Run("unhealthy after the failure threshold", func(t *testing.T) {
+		status := int32(http.StatusInternalServerError)
+		server := newServer(&status)
+		defer server.Close()
+
+		c, mocks := newFakeController()
+		fixture := newFixture(t, server)
+		updated := false
+		mocks.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			updated = true
+			gs := patchedGameServer(t, fixture, action)
+			assert.Equal(t, v1alpha1.GameServerStateUnhealthy, gs.Status.State)
+			return true, gs, nil
+		})
+
+		_, err := c.syncDevelopmentGameServerHealth(fixture)
+		assert.Nil(t, err)
+		assert.False(t, updated, "one failure is under the threshold")
+
+		// checks are only made once per health period
+		_, err = c.syncDevelopmentGameServerHealth(fixture)
+		assert.Nil(t, err)
+		assert.False(t, updated, "should not check again before the health period has passed")
+
+		key := fixture.ObjectMeta.Namespace + "/" + fixture.ObjectMeta.Name
+		check := c.devHealthChecks.checks[key]
+		check.lastChecked = time.Now().Add(-time.Minute)
+		c.devHealthChecks.checks[key] = check
+
+		result, err := c.syncDevelopmentGameServerHealth(fixture)
+		assert.Nil(t, err)
+		assert.True(t, updated, "should be moved to Unhealthy")
+		assert.Equal(t, v1alpha1.GameServerStateUnhealthy, result.Status.State)
+		assert.NotContains(t, c.devHealthChecks.checks, key)
+		agtesting.AssertEventContains(t, mocks.FakeRecorder.Events, "Local SDK server health check failed")
+	})
+
+	t.Run("recovers before the failure threshold", func(t *testing.T) {
+		status := int32(http.StatusInternalServerError)
+		server := newServer(&status)
+		defer server.Close()
+
+		c, _ := newFakeController()
+		fixture := newFixture(t, server)
+		key := fixture.ObjectMeta.Namespace + "/" + fixture.ObjectMeta.Name
+
+		_, err := c.syncDevelopmentGameServerHealth(fixture)
+		assert.Nil(t, err)
+		assert.Equal(t, int32(1), c.devHealthChecks.checks[key].failures)
+
+		atomic.StoreInt32(&status, http.StatusOK)
+		check := c.devHealthChecks.checks[key]
+		check.lastChecked = time.Now().Add(-time.Minute)
+		c.devHealthChecks.checks[key] = check
+
+		_, err = c.syncDevelopmentGameServerHealth(fixture)
+		assert.Nil(t, err)
+		assert.Equal(t, int32(0), c.devHealthChecks.checks[key].failures)
+	})
+
+	t.Run("not health checked", func(t *testing.T) {
+		c, mocks := newFakeController()
+		mocks.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not update the GameServer")
+			return true, nil, nil
+		})
+
+		status := int32(http.StatusInternalServerError)
+		server := newServer(&status)
+		defer server.Close()
+
+		noAnnotation := newFixture(t, server)
+		delete(noAnnotation.ObjectMeta.Annotations, v1alpha1.DevHealthCheckAnnotation)
+		disabled := newFixture(t, server)
+		disabled.Spec.Health.Disabled = true
+		creating := newFixture(t, server)
+		creating.Status.State = v1alpha1.GameServerStateCreating
+
+		for _, gs := range []*v1alpha1.GameServer{noAnnotation, disabled, creating} {
+			_, err := c.syncDevelopmentGameServerHealth(gs)
+			assert.Nil(t, err)
+		}
+		assert.Empty(t, c.devHealthChecks.checks)
+	})
+}
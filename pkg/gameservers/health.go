@@ -15,6 +15,7 @@
 package gameservers
 
 import (
+	"fmt"
 	"strings"
 
 	"agones.dev/agones/pkg/apis/stable"
@@ -84,7 +85,7 @@ func NewHealthController(health healthcheck.Handler,
 	podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			pod := newObj.(*corev1.Pod)
-			if isGameServerPod(pod) && hc.isUnhealthy(pod) {
+			if isGameServerPod(pod) && (hc.isUnhealthy(pod) || hc.restartedContainer(oldObj.(*corev1.Pod), pod)) {
 				owner := metav1.GetControllerOf(pod)
 				hc.workerqueue.Enqueue(cache.ExplicitKey(pod.ObjectMeta.Namespace + "/" + owner.Name))
 			}
@@ -132,6 +133,38 @@ func (hc *HealthController) failedContainer(pod *corev1.Pod) bool {
 	return false
 }
 
+// restartedContainer checks if the kubelet has restarted the game server container
+// between the old and new versions of the Pod
+func (hc *HealthController) restartedContainer(oldPod, newPod *corev1.Pod) bool {
+	return restartCount(oldPod) < restartCount(newPod)
+}
+
+// restartCount returns the number of times the game server container of the Pod has been restarted
+func restartCount(pod *corev1.Pod) int32 {
+	container := pod.Annotations[v1alpha1.GameServerContainerAnnotation]
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == container {
+			return cs.RestartCount
+		}
+	}
+	return 0
+}
+
+// terminatedState returns how the game server container of the Pod last terminated, whether it is still
+// terminated, or has since been restarted by the kubelet. Returns nil if it hasn't terminated.
+func terminatedState(pod *corev1.Pod) *corev1.ContainerStateTerminated {
+	container := pod.Annotations[v1alpha1.GameServerContainerAnnotation]
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == container {
+			if cs.State.Terminated != nil {
+				return cs.State.Terminated
+			}
+			return cs.LastTerminationState.Terminated
+		}
+	}
+	return nil
+}
+
 // Run processes the rate limited queue.
 // Will block until stop is closed
 func (hc *HealthController) Run(stop <-chan struct{}) error {
@@ -183,11 +216,21 @@ func (hc *HealthController) syncGameServer(key string) error {
 		return nil
 	}
 
+	shutdown, err := hc.expectedShutdown(gs)
+	if err != nil || shutdown {
+		return err
+	}
+
+	restarted, err := hc.restartInPlace(gs)
+	if err != nil || restarted {
+		return err
+	}
+
 	hc.loggerForGameServer(gs).Info("Issue with GameServer pod, marking as GameServerStateUnhealthy")
 	gsCopy := gs.DeepCopy()
 	gsCopy.Status.State = v1alpha1.GameServerStateUnhealthy
 
-	if _, err := hc.gameServerGetter.GameServers(gs.ObjectMeta.Namespace).Update(gsCopy); err != nil {
+	if _, err := patchGameServer(hc.gameServerGetter, gs, gsCopy); err != nil {
 		return errors.Wrapf(err, "error updating GameServer %s to unhealthy", gs.ObjectMeta.Name)
 	}
 
@@ -195,3 +238,103 @@ func (hc *HealthController) syncGameServer(key string) error {
 
 	return nil
 }
+
+// expectedShutdown moves a GameServer to Shutdown, rather than Unhealthy, if its game server container
+// exited with one of the ShutdownExitCodes of its Health. Returns false if it didn't.
+func (hc *HealthController) expectedShutdown(gs *v1alpha1.GameServer) (bool, error) {
+	if len(gs.Spec.Health.ShutdownExitCodes) == 0 {
+		return false, nil
+	}
+
+	pod, err := hc.podLister.Pods(gs.ObjectMeta.Namespace).Get(gs.ObjectMeta.Name)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "error retrieving pod for GameServer %s", gs.ObjectMeta.Name)
+	}
+	if !metav1.IsControlledBy(pod, gs) {
+		return false, nil
+	}
+
+	terminated := terminatedState(pod)
+	if terminated == nil || !gs.Spec.Health.IsShutdownExitCode(terminated.ExitCode) {
+		return false, nil
+	}
+
+	hc.loggerForGameServer(gs).WithField("exitCode", terminated.ExitCode).
+		Info("Game server container exited with a shutdown exit code, marking as GameServerStateShutdown")
+	gsCopy := gs.DeepCopy()
+	gsCopy.Status.State = v1alpha1.GameServerStateShutdown
+
+	gs, err = patchGameServer(hc.gameServerGetter, gs, gsCopy)
+	if err != nil {
+		return false, errors.Wrapf(err, "error updating GameServer %s to Shutdown", gsCopy.ObjectMeta.Name)
+	}
+
+	hc.recorder.Event(gs, corev1.EventTypeNormal, string(gs.Status.State),
+		fmt.Sprintf("Game server container exited with shutdown exit code %d", terminated.ExitCode))
+
+	return true, nil
+}
+
+// restartInPlace moves a GameServer with the InPlace RestartPolicy back to Scheduled once the kubelet
+// restarts its game server container, up to its BackoffLimit, and counts the restarts in its Status,
+// including those from before it started. Returns false if the GameServer should become Unhealthy instead,
+// such as when its Pod has been deleted, or has failed altogether.
+func (hc *HealthController) restartInPlace(gs *v1alpha1.GameServer) (bool, error) {
+	if gs.Spec.RestartPolicy != v1alpha1.RestartInPlace || gs.Status.Restarts >= gs.Spec.BackoffLimit {
+		return false, nil
+	}
+
+	pod, err := hc.podLister.Pods(gs.ObjectMeta.Namespace).Get(gs.ObjectMeta.Name)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "error retrieving pod for GameServer %s", gs.ObjectMeta.Name)
+	}
+	if !metav1.IsControlledBy(pod, gs) || !pod.ObjectMeta.DeletionTimestamp.IsZero() ||
+		pod.Status.Phase == corev1.PodFailed || hc.unschedulableWithNoFreePorts(pod) {
+		return false, nil
+	}
+
+	// the kubelet's restart count of the game server container also counts the restarts from before
+	// the game server started, which are otherwise not seen here
+	restarts := restartCount(pod)
+	started := false
+	switch gs.Status.State {
+	case v1alpha1.GameServerStateRequestReady, v1alpha1.GameServerStateReady,
+		v1alpha1.GameServerStateReserved, v1alpha1.GameServerStateAllocated:
+		started = true
+		if restarts <= gs.Status.Restarts {
+			restarts = gs.Status.Restarts + 1
+		}
+	default:
+		// the game server has yet to start, so it doesn't need resetting, only its restarts counting
+		if restarts <= gs.Status.Restarts {
+			return true, nil
+		}
+	}
+	if restarts > gs.Spec.BackoffLimit {
+		return false, nil
+	}
+
+	gsCopy := gs.DeepCopy()
+	gsCopy.Status.Restarts = restarts
+	if started {
+		hc.loggerForGameServer(gs).Info("Game server container restarted in place, marking as GameServerStateScheduled")
+		gsCopy.Status.State = v1alpha1.GameServerStateScheduled
+		gsCopy.Status.ReservedUntil = nil
+	}
+
+	gs, err = patchGameServer(hc.gameServerGetter, gs, gsCopy)
+	if err != nil {
+		return false, errors.Wrapf(err, "error updating GameServer %s restarts", gsCopy.ObjectMeta.Name)
+	}
+
+	hc.recorder.Event(gs, corev1.EventTypeNormal, string(gs.Status.State),
+		fmt.Sprintf("Game server container restarted in place, restart %d of %d", gs.Status.Restarts, gs.Spec.BackoffLimit))
+
+	return true, nil
+}
@@ -134,10 +134,9 @@ func TestHealthControllerSyncGameServer(t *testing.T) {
 				got = true
 				return true, &v1alpha1.GameServerList{Items: []v1alpha1.GameServer{gs}}, nil
 			})
-			m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
 				updated = true
-				ua := action.(k8stesting.UpdateAction)
-				gsObj := ua.GetObject().(*v1alpha1.GameServer)
+				gsObj := patchedGameServer(t, &gs, action)
 				assert.Equal(t, v1alpha1.GameServerStateUnhealthy, gsObj.Status.State)
 				return true, gsObj, nil
 			})
@@ -154,6 +153,213 @@ func TestHealthControllerSyncGameServer(t *testing.T) {
 	}
 }
 
+func TestHealthControllerRestartedContainer(t *testing.T) {
+	t.Parallel()
+
+	m := agtesting.NewMocks()
+	hc := NewHealthController(healthcheck.NewHandler(), m.KubeClient, m.AgonesClient, m.KubeInformerFactory, m.AgonesInformerFactory)
+
+	gs := v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Spec: newSingleContainerSpec()}
+	gs.ApplyDefaults()
+
+	oldPod, err := gs.Pod()
+	assert.Nil(t, err)
+	oldPod.Status = corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: gs.Spec.Container, RestartCount: 1}}}
+
+	newPod := oldPod.DeepCopy()
+	assert.False(t, hc.restartedContainer(oldPod, newPod))
+
+	newPod.Status.ContainerStatuses[0].RestartCount = 2
+	assert.True(t, hc.restartedContainer(oldPod, newPod))
+
+	newPod.Status.ContainerStatuses[0].Name = "Not a matching name"
+	assert.False(t, hc.restartedContainer(oldPod, newPod))
+}
+
+func TestHealthControllerSyncGameServerRestartInPlace(t *testing.T) {
+	t.Parallel()
+
+	type expected struct {
+		state    v1alpha1.GameServerState
+		restarts int32
+	}
+	fixtures := map[string]struct {
+		state        v1alpha1.GameServerState
+		restarts     int32
+		restartCount int32
+		noPod        bool
+		podPhase     corev1.PodPhase
+		expected     expected
+	}{
+		"ready": {
+			state:    v1alpha1.GameServerStateReady,
+			expected: expected{state: v1alpha1.GameServerStateScheduled, restarts: 1},
+		},
+		"allocated": {
+			state:    v1alpha1.GameServerStateAllocated,
+			restarts: 2,
+			expected: expected{state: v1alpha1.GameServerStateScheduled, restarts: 3},
+		},
+		"ready, restarted before it was ready": {
+			state:        v1alpha1.GameServerStateReady,
+			restarts:     1,
+			restartCount: 3,
+			expected:     expected{state: v1alpha1.GameServerStateScheduled, restarts: 3},
+		},
+		"not started yet": {
+			state:    v1alpha1.GameServerStateScheduled,
+			expected: expected{state: v1alpha1.GameServerStateScheduled},
+		},
+		"not started yet, restarted": {
+			state:        v1alpha1.GameServerStateScheduled,
+			restarts:     1,
+			restartCount: 2,
+			expected:     expected{state: v1alpha1.GameServerStateScheduled, restarts: 2},
+		},
+		"not started yet, backoff limit exceeded": {
+			state:        v1alpha1.GameServerStateScheduled,
+			restarts:     v1alpha1.DefaultBackoffLimit,
+			restartCount: v1alpha1.DefaultBackoffLimit + 1,
+			expected:     expected{state: v1alpha1.GameServerStateUnhealthy, restarts: v1alpha1.DefaultBackoffLimit},
+		},
+		"backoff limit reached": {
+			state:    v1alpha1.GameServerStateReady,
+			restarts: v1alpha1.DefaultBackoffLimit,
+			expected: expected{state: v1alpha1.GameServerStateUnhealthy, restarts: v1alpha1.DefaultBackoffLimit},
+		},
+		"pod deleted": {
+			state:    v1alpha1.GameServerStateReady,
+			noPod:    true,
+			expected: expected{state: v1alpha1.GameServerStateUnhealthy},
+		},
+		"pod failed": {
+			state:    v1alpha1.GameServerStateReady,
+			podPhase: corev1.PodFailed,
+			expected: expected{state: v1alpha1.GameServerStateUnhealthy},
+		},
+	}
+
+	for name, test := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			m := agtesting.NewMocks()
+			hc := NewHealthController(healthcheck.NewHandler(), m.KubeClient, m.AgonesClient, m.KubeInformerFactory, m.AgonesInformerFactory)
+			hc.recorder = m.FakeRecorder
+
+			gs := v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}, Spec: newSingleContainerSpec(),
+				Status: v1alpha1.GameServerStatus{State: test.state, Restarts: test.restarts}}
+			gs.Spec.RestartPolicy = v1alpha1.RestartInPlace
+			gs.ApplyDefaults()
+
+			pod, err := gs.Pod()
+			assert.Nil(t, err)
+			pod.Status.Phase = test.podPhase
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: gs.Spec.Container, RestartCount: test.restartCount}}
+			pods := &corev1.PodList{}
+			if !test.noPod {
+				pods.Items = append(pods.Items, *pod)
+			}
+
+			m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, pods, nil
+			})
+			m.AgonesClient.AddReactor("list", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, &v1alpha1.GameServerList{Items: []v1alpha1.GameServer{gs}}, nil
+			})
+			state := test.state
+			restarts := test.restarts
+			m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				gsObj := patchedGameServer(t, &gs, action)
+				state = gsObj.Status.State
+				restarts = gsObj.Status.Restarts
+				return true, gsObj, nil
+			})
+
+			_, cancel := agtesting.StartInformers(m, hc.podSynced, hc.gameServerSynced)
+			defer cancel()
+
+			err = hc.syncGameServer("default/test")
+			assert.Nil(t, err, err)
+			assert.Equal(t, test.expected.state, state)
+			assert.Equal(t, test.expected.restarts, restarts)
+			if test.expected.restarts > test.restarts {
+				agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Game server container restarted")
+			}
+		})
+	}
+}
+
+func TestHealthControllerSyncGameServerShutdownExitCode(t *testing.T) {
+	t.Parallel()
+
+	fixtures := map[string]struct {
+		exitCodes []int32
+		status    corev1.ContainerStatus
+		expected  v1alpha1.GameServerState
+	}{
+		"shutdown exit code": {
+			exitCodes: []int32{0},
+			status:    corev1.ContainerStatus{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+			expected:  v1alpha1.GameServerStateShutdown,
+		},
+		"restarted after shutdown exit code": {
+			exitCodes: []int32{0, 10},
+			status: corev1.ContainerStatus{State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 10}}},
+			expected: v1alpha1.GameServerStateShutdown,
+		},
+		"other exit code": {
+			exitCodes: []int32{0},
+			status:    corev1.ContainerStatus{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}},
+			expected:  v1alpha1.GameServerStateUnhealthy,
+		},
+		"no shutdown exit codes": {
+			status:   corev1.ContainerStatus{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+			expected: v1alpha1.GameServerStateUnhealthy,
+		},
+	}
+
+	for name, test := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			m := agtesting.NewMocks()
+			hc := NewHealthController(healthcheck.NewHandler(), m.KubeClient, m.AgonesClient, m.KubeInformerFactory, m.AgonesInformerFactory)
+			hc.recorder = m.FakeRecorder
+
+			gs := v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}, Spec: newSingleContainerSpec(),
+				Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateAllocated}}
+			gs.Spec.Health.ShutdownExitCodes = test.exitCodes
+			gs.ApplyDefaults()
+
+			pod, err := gs.Pod()
+			assert.Nil(t, err)
+			test.status.Name = gs.Spec.Container
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{test.status}
+
+			m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, &corev1.PodList{Items: []corev1.Pod{*pod}}, nil
+			})
+			m.AgonesClient.AddReactor("list", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, &v1alpha1.GameServerList{Items: []v1alpha1.GameServer{gs}}, nil
+			})
+			var state v1alpha1.GameServerState
+			m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				gsObj := patchedGameServer(t, &gs, action)
+				state = gsObj.Status.State
+				return true, gsObj, nil
+			})
+
+			_, cancel := agtesting.StartInformers(m, hc.podSynced, hc.gameServerSynced)
+			defer cancel()
+
+			err = hc.syncGameServer("default/test")
+			assert.Nil(t, err, err)
+			assert.Equal(t, test.expected, state)
+			if test.expected == v1alpha1.GameServerStateShutdown {
+				agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Game server container exited with shutdown exit code")
+			}
+		})
+	}
+}
+
 func TestHealthControllerRun(t *testing.T) {
 	m := agtesting.NewMocks()
 	hc := NewHealthController(healthcheck.NewHandler(), m.KubeClient, m.AgonesClient, m.KubeInformerFactory, m.AgonesInformerFactory)
@@ -165,21 +371,20 @@ func TestHealthControllerRun(t *testing.T) {
 	podWatch := watch.NewFake()
 	m.KubeClient.AddWatchReactor("pods", k8stesting.DefaultWatchReactor(podWatch, nil))
 
+	gs := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}, Spec: newSingleContainerSpec(),
+		Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReady}}
+	gs.ApplyDefaults()
+
 	updated := make(chan bool)
 	defer close(updated)
-	m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+	m.AgonesClient.AddReactor("patch", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
 		defer func() {
 			updated <- true
 		}()
-		ua := action.(k8stesting.UpdateAction)
-		gsObj := ua.GetObject().(*v1alpha1.GameServer)
+		gsObj := patchedGameServer(t, gs, action)
 		assert.Equal(t, v1alpha1.GameServerStateUnhealthy, gsObj.Status.State)
 		return true, gsObj, nil
 	})
-
-	gs := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}, Spec: newSingleContainerSpec(),
-		Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReady}}
-	gs.ApplyDefaults()
 	pod, err := gs.Pod()
 	assert.Nil(t, err)
 
@@ -29,8 +29,9 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
-// PerNodeCounter counts how many Allocated and
-// Ready GameServers currently exist on each node.
+// PerNodeCounter counts how many Allocated, Reserved and
+// Ready GameServers currently exist on each node, in total
+// and for each Fleet.
 // This is useful for scheduling allocations, fleet management
 // mostly under a Packed strategy
 type PerNodeCounter struct {
@@ -39,6 +40,8 @@ type PerNodeCounter struct {
 	gameServerLister listerv1alpha1.GameServerLister
 	countMutex       sync.RWMutex
 	counts           map[string]*NodeCount
+	// fleetCounts are the counts of each node, by the namespace/name key of the Fleet of the GameServers
+	fleetCounts map[string]map[string]*NodeCount
 }
 
 // NodeCount is just a convenience data structure for
@@ -48,6 +51,8 @@ type NodeCount struct {
 	Ready int64
 	// Allocated is allocated out
 	Allocated int64
+	// Reserved is reserved count
+	Reserved int64
 }
 
 // NewPerNodeCounter returns a new PerNodeCounter
@@ -63,6 +68,7 @@ func NewPerNodeCounter(
 		gameServerLister: gameServers.Lister(),
 		countMutex:       sync.RWMutex{},
 		counts:           map[string]*NodeCount{},
+		fleetCounts:      map[string]map[string]*NodeCount{},
 	}
 
 	ac.logger = runtime.NewLoggerWithType(ac)
@@ -70,46 +76,44 @@ func NewPerNodeCounter(
 	gsInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			gs := obj.(*v1alpha1.GameServer)
-
-			switch gs.Status.State {
-			case v1alpha1.GameServerStateReady:
-				ac.inc(gs, 1, 0)
-			case v1alpha1.GameServerStateAllocated:
-				ac.inc(gs, 0, 1)
-			}
+			ac.inc(gs, stateCount(gs.Status.State))
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			oldGS := oldObj.(*v1alpha1.GameServer)
 			newGS := newObj.(*v1alpha1.GameServer)
 
-			var ready int64
-			var allocated int64
-
-			if oldGS.Status.State == v1alpha1.GameServerStateReady && newGS.Status.State != v1alpha1.GameServerStateReady {
-				ready = -1
-			} else if newGS.Status.State == v1alpha1.GameServerStateReady && oldGS.Status.State != v1alpha1.GameServerStateReady {
-				ready = 1
-			}
-
-			if oldGS.Status.State == v1alpha1.GameServerStateAllocated && newGS.Status.State != v1alpha1.GameServerStateAllocated {
-				allocated = -1
-			} else if newGS.Status.State == v1alpha1.GameServerStateAllocated && oldGS.Status.State != v1alpha1.GameServerStateAllocated {
-				allocated = 1
+			if oldGS.Status.State == newGS.Status.State {
+				return
 			}
-
-			ac.inc(newGS, ready, allocated)
+			ac.inc(oldGS, stateCount(oldGS.Status.State).negate())
+			ac.inc(newGS, stateCount(newGS.Status.State))
 		},
 		DeleteFunc: func(obj interface{}) {
 			gs, ok := obj.(*v1alpha1.GameServer)
 			if !ok {
 				return
 			}
+			ac.inc(gs, stateCount(gs.Status.State).negate())
+		},
+	})
+
+	// remove the records of the fleet when it is deleted
+	agonesInformerFactory.Stable().V1alpha1().Fleets().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			fleet, ok := obj.(*v1alpha1.Fleet)
+			if !ok {
+				return
+			}
 
-			switch gs.Status.State {
-			case v1alpha1.GameServerStateReady:
-				ac.inc(gs, -1, 0)
-			case v1alpha1.GameServerStateAllocated:
-				ac.inc(gs, 0, -1)
+			ac.countMutex.Lock()
+			defer ac.countMutex.Unlock()
+
+			key := fleetKey(fleet.ObjectMeta.Namespace, fleet.ObjectMeta.Name)
+			for node, fleets := range ac.fleetCounts {
+				delete(fleets, key)
+				if len(fleets) == 0 {
+					delete(ac.fleetCounts, node)
+				}
 			}
 		},
 	})
@@ -126,6 +130,7 @@ func NewPerNodeCounter(
 			defer ac.countMutex.Unlock()
 
 			delete(ac.counts, node.ObjectMeta.Name)
+			delete(ac.fleetCounts, node.ObjectMeta.Name)
 		},
 	})
 
@@ -149,22 +154,12 @@ func (pnc *PerNodeCounter) Run(_ int, stop <-chan struct{}) error {
 		return errors.Wrap(err, "error attempting to list all GameServers")
 	}
 
-	counts := map[string]*NodeCount{}
+	pnc.counts = map[string]*NodeCount{}
+	pnc.fleetCounts = map[string]map[string]*NodeCount{}
 	for _, gs := range gsList {
-		_, ok := counts[gs.Status.NodeName]
-		if !ok {
-			counts[gs.Status.NodeName] = &NodeCount{}
-		}
-
-		switch gs.Status.State {
-		case v1alpha1.GameServerStateReady:
-			counts[gs.Status.NodeName].Ready++
-		case v1alpha1.GameServerStateAllocated:
-			counts[gs.Status.NodeName].Allocated++
-		}
+		pnc.add(gs, stateCount(gs.Status.State))
 	}
 
-	pnc.counts = counts
 	return nil
 }
 
@@ -183,24 +178,106 @@ func (pnc *PerNodeCounter) Counts() map[string]NodeCount {
 	return result
 }
 
-func (pnc *PerNodeCounter) inc(gs *v1alpha1.GameServer, ready, allocated int64) {
-	pnc.countMutex.Lock()
-	defer pnc.countMutex.Unlock()
+// FleetCounts returns the NodeCount map of the GameServers of a Fleet in a thread safe way.
+// Nodes without any Ready, Allocated or Reserved GameServers of the Fleet are left out.
+func (pnc *PerNodeCounter) FleetCounts(namespace, fleetName string) map[string]NodeCount {
+	pnc.countMutex.RLock()
+	defer pnc.countMutex.RUnlock()
 
-	_, ok := pnc.counts[gs.Status.NodeName]
-	if !ok {
-		pnc.counts[gs.Status.NodeName] = &NodeCount{}
+	key := fleetKey(namespace, fleetName)
+	result := map[string]NodeCount{}
+	for node, fleets := range pnc.fleetCounts {
+		if c, ok := fleets[key]; ok {
+			result[node] = *c
+		}
+	}
+
+	return result
+}
+
+// fleetKey returns the key of the counts of a Fleet
+func fleetKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// stateCount returns the NodeCount of a single GameServer in the state
+func stateCount(state v1alpha1.GameServerState) NodeCount {
+	switch state {
+	case v1alpha1.GameServerStateReady:
+		return NodeCount{Ready: 1}
+	case v1alpha1.GameServerStateAllocated:
+		return NodeCount{Allocated: 1}
+	case v1alpha1.GameServerStateReserved:
+		return NodeCount{Reserved: 1}
 	}
+	return NodeCount{}
+}
+
+// negate returns the NodeCount with all its counts negated
+func (nc NodeCount) negate() NodeCount {
+	return NodeCount{Ready: -nc.Ready, Allocated: -nc.Allocated, Reserved: -nc.Reserved}
+}
 
-	pnc.counts[gs.Status.NodeName].Allocated += allocated
-	pnc.counts[gs.Status.NodeName].Ready += ready
+// add adds the delta to the counts, none of which go below zero
+func (nc *NodeCount) add(delta NodeCount) {
+	nc.Ready += delta.Ready
+	nc.Allocated += delta.Allocated
+	nc.Reserved += delta.Reserved
 
 	// just in case
-	if pnc.counts[gs.Status.NodeName].Allocated < 0 {
-		pnc.counts[gs.Status.NodeName].Allocated = 0
+	if nc.Ready < 0 {
+		nc.Ready = 0
+	}
+	if nc.Allocated < 0 {
+		nc.Allocated = 0
+	}
+	if nc.Reserved < 0 {
+		nc.Reserved = 0
+	}
+}
+
+func (pnc *PerNodeCounter) inc(gs *v1alpha1.GameServer, delta NodeCount) {
+	if delta == (NodeCount{}) {
+		return
+	}
+
+	pnc.countMutex.Lock()
+	defer pnc.countMutex.Unlock()
+
+	pnc.add(gs, delta)
+}
+
+// add adds the delta to the counts of the GameServer's node, in total and for its Fleet, if it has one.
+// The caller must hold the countMutex.
+func (pnc *PerNodeCounter) add(gs *v1alpha1.GameServer, delta NodeCount) {
+	node := gs.Status.NodeName
+	if _, ok := pnc.counts[node]; !ok {
+		pnc.counts[node] = &NodeCount{}
+	}
+	pnc.counts[node].add(delta)
+
+	fleetName, ok := gs.ObjectMeta.Labels[v1alpha1.FleetNameLabel]
+	if !ok {
+		return
+	}
+	key := fleetKey(gs.ObjectMeta.Namespace, fleetName)
+	fleets, ok := pnc.fleetCounts[node]
+	if !ok {
+		fleets = map[string]*NodeCount{}
+		pnc.fleetCounts[node] = fleets
 	}
+	count, ok := fleets[key]
+	if !ok {
+		count = &NodeCount{}
+		fleets[key] = count
+	}
+	count.add(delta)
 
-	if pnc.counts[gs.Status.NodeName].Ready < 0 {
-		pnc.counts[gs.Status.NodeName].Ready = 0
+	// so the counts of Fleets don't pile up, once they have no GameServers left on the node
+	if *count == (NodeCount{}) {
+		delete(fleets, key)
+		if len(fleets) == 0 {
+			delete(pnc.fleetCounts, node)
+		}
 	}
 }
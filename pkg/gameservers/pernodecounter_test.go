@@ -115,6 +115,81 @@ func TestPerNodeCounterGameServerEvents(t *testing.T) {
 	assert.Equal(t, int64(1), counts[name2].Allocated)
 }
 
+func TestPerNodeCounterReservedAndFleets(t *testing.T) {
+	t.Parallel()
+
+	pnc, m := newFakePerNodeCounter()
+
+	gsWatch := watch.NewFake()
+	nodeWatch := watch.NewFake()
+	fleetWatch := watch.NewFake()
+	m.AgonesClient.AddWatchReactor("gameservers", k8stesting.DefaultWatchReactor(gsWatch, nil))
+	m.AgonesClient.AddWatchReactor("fleets", k8stesting.DefaultWatchReactor(fleetWatch, nil))
+	m.KubeClient.AddWatchReactor("nodes", k8stesting.DefaultWatchReactor(nodeWatch, nil))
+
+	gsSynced := m.AgonesInformerFactory.Stable().V1alpha1().GameServers().Informer().HasSynced
+	fleetSynced := m.AgonesInformerFactory.Stable().V1alpha1().Fleets().Informer().HasSynced
+	nodeSynced := m.KubeInformerFactory.Core().V1().Nodes().Informer().HasSynced
+	stop, cancel := agtesting.StartInformers(m)
+	defer cancel()
+
+	gs1 := &v1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "gs1", Namespace: defaultNs, Labels: map[string]string{v1alpha1.FleetNameLabel: "fleet1"}},
+		Status:     v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReserved, NodeName: name1}}
+	gs2 := &v1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "gs2", Namespace: defaultNs, Labels: map[string]string{v1alpha1.FleetNameLabel: "fleet2"}},
+		Status:     v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReady, NodeName: name1}}
+	gs3 := &v1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "gs3", Namespace: defaultNs},
+		Status:     v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateAllocated, NodeName: name2}}
+
+	gsWatch.Add(gs1.DeepCopy())
+	gsWatch.Add(gs2.DeepCopy())
+	gsWatch.Add(gs3.DeepCopy())
+	cache.WaitForCacheSync(stop, gsSynced)
+
+	assert.Equal(t, map[string]NodeCount{name1: {Ready: 1, Reserved: 1}, name2: {Allocated: 1}}, pnc.Counts())
+	assert.Equal(t, map[string]NodeCount{name1: {Reserved: 1}}, pnc.FleetCounts(defaultNs, "fleet1"))
+	assert.Equal(t, map[string]NodeCount{name1: {Ready: 1}}, pnc.FleetCounts(defaultNs, "fleet2"))
+	assert.Empty(t, pnc.FleetCounts("other", "fleet1"))
+	assert.Empty(t, pnc.FleetCounts(defaultNs, "fleet3"))
+
+	gs1.Status.State = v1alpha1.GameServerStateAllocated
+	gsWatch.Modify(gs1.DeepCopy())
+	cache.WaitForCacheSync(stop, gsSynced)
+
+	assert.Equal(t, map[string]NodeCount{name1: {Ready: 1, Allocated: 1}, name2: {Allocated: 1}}, pnc.Counts())
+	assert.Equal(t, map[string]NodeCount{name1: {Allocated: 1}}, pnc.FleetCounts(defaultNs, "fleet1"))
+
+	// the counts of a Fleet without GameServers on the node are pruned
+	gsWatch.Delete(gs2.DeepCopy())
+	cache.WaitForCacheSync(stop, gsSynced)
+	assert.Empty(t, pnc.FleetCounts(defaultNs, "fleet2"))
+
+	// and so are the counts of a deleted Fleet
+	fleet := &v1alpha1.Fleet{ObjectMeta: metav1.ObjectMeta{Name: "fleet1", Namespace: defaultNs}}
+	fleetWatch.Add(fleet.DeepCopy())
+	cache.WaitForCacheSync(stop, fleetSynced)
+	fleetWatch.Delete(fleet.DeepCopy())
+	cache.WaitForCacheSync(stop, fleetSynced)
+	assert.Empty(t, pnc.FleetCounts(defaultNs, "fleet1"))
+	pnc.countMutex.RLock()
+	assert.Empty(t, pnc.fleetCounts)
+	pnc.countMutex.RUnlock()
+
+	gs2.ObjectMeta.Labels[v1alpha1.FleetNameLabel] = "fleet1"
+	gsWatch.Add(gs2.DeepCopy())
+	cache.WaitForCacheSync(stop, gsSynced)
+	assert.Equal(t, map[string]NodeCount{name1: {Ready: 1}}, pnc.FleetCounts(defaultNs, "fleet1"))
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name1}}
+	nodeWatch.Add(node.DeepCopy())
+	cache.WaitForCacheSync(stop, nodeSynced)
+	nodeWatch.Delete(node.DeepCopy())
+	cache.WaitForCacheSync(stop, nodeSynced)
+	assert.Empty(t, pnc.FleetCounts(defaultNs, "fleet1"))
+}
+
 func TestPerNodeCounterNodeEvents(t *testing.T) {
 	t.Parallel()
 
@@ -171,8 +246,13 @@ func TestPerNodeCounterRun(t *testing.T) {
 	gs4.ObjectMeta.Name = "gs4"
 	gs4.Status.State = v1alpha1.GameServerStateAllocated
 
+	gs5 := gs1.DeepCopy()
+	gs5.ObjectMeta.Name = "gs5"
+	gs5.ObjectMeta.Labels = map[string]string{v1alpha1.FleetNameLabel: "fleet1"}
+	gs5.Status.State = v1alpha1.GameServerStateReserved
+
 	m.AgonesClient.AddReactor("list", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
-		return true, &v1alpha1.GameServerList{Items: []v1alpha1.GameServer{*gs1, *gs2, *gs3, *gs4}}, nil
+		return true, &v1alpha1.GameServerList{Items: []v1alpha1.GameServer{*gs1, *gs2, *gs3, *gs4, *gs5}}, nil
 	})
 
 	stop, cancel := agtesting.StartInformers(m)
@@ -186,8 +266,10 @@ func TestPerNodeCounterRun(t *testing.T) {
 	assert.Len(t, counts, 2)
 	assert.Equal(t, int64(1), counts[name1].Ready)
 	assert.Equal(t, int64(2), counts[name1].Allocated)
+	assert.Equal(t, int64(1), counts[name1].Reserved)
 	assert.Equal(t, int64(0), counts[name2].Ready)
 	assert.Equal(t, int64(0), counts[name2].Allocated)
+	assert.Equal(t, map[string]NodeCount{name1: {Reserved: 1}}, pnc.FleetCounts(defaultNs, "fleet1"))
 }
 
 // newFakeController returns a controller, backed by the fake Clientset
@@ -15,76 +15,191 @@
 package gameservers
 
 import (
+	"reflect"
 	"sort"
+	"strconv"
 	"sync"
 
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
 	"agones.dev/agones/pkg/client/informers/externalversions"
 	listerv1alpha1 "agones.dev/agones/pkg/client/listers/stable/v1alpha1"
+	"agones.dev/agones/pkg/metrics"
 	"agones.dev/agones/pkg/util/runtime"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	corelisterv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
-// A set of port allocations for a node
+var (
+	// ErrNotEnoughPorts is returned when a GameServer requests more ports than there are in the port range
+	ErrNotEnoughPorts = errors.New("not enough ports in the port range")
+	// ErrPortRangeNotFound is returned when a GameServer requests ports from a port range that does not exist
+	ErrPortRangeNotFound = errors.New("port range not found")
+)
+
+const (
+	// failureExhausted is the reason recorded when none of the current nodes have enough free ports
+	// for a GameServer, and it has to wait for a new node
+	failureExhausted = "exhausted"
+	// failureNotEnoughPorts is the reason recorded for ErrNotEnoughPorts
+	failureNotEnoughPorts = "not_enough_ports"
+	// failurePortRangeNotFound is the reason recorded for ErrPortRangeNotFound
+	failurePortRangeNotFound = "port_range_not_found"
+)
+
+// PortRange is a range of ports, from MinPort to MaxPort inclusive
+type PortRange struct {
+	MinPort int32
+	MaxPort int32
+}
+
+// A set of port allocations for a node, across all port ranges
 type portAllocation map[int32]bool
 
+// portRange is a named PortRange, and the next port in it to be handed out with sticky ports
+type portRange struct {
+	PortRange
+	nextPort int32
+}
+
+// podPorts are the HostPorts of a Pod that is not a GameServer, and the port allocations that each of them is
+// taken on. If the Pod is not on a known node, node is empty, and each port is taken on the first port allocations
+// that it is open on, if any.
+type podPorts struct {
+	node        string
+	ports       []int32
+	allocations []portAllocation
+}
+
+// allocatedPort is a port of a set of port allocations, which are identified by the address of their map
+type allocatedPort struct {
+	allocation uintptr
+	port       int32
+}
+
+// allocatedPortOf returns the allocatedPort of port in n
+func allocatedPortOf(n portAllocation, port int32) allocatedPort {
+	return allocatedPort{allocation: reflect.ValueOf(n).Pointer(), port: port}
+}
+
+// contains returns true if port is within the range
+func (r *portRange) contains(port int32) bool {
+	return port >= r.MinPort && port <= r.MaxPort
+}
+
+// portCount returns how many ports there are in the range, which is the most
+// Dynamic and Passthrough ports a single GameServer can have from it
+func (r *portRange) portCount() int {
+	return int(r.MaxPort-r.MinPort) + 1
+}
+
 // PortAllocator manages the dynamic port
 // allocation strategy. Only use exposed methods to ensure
 // appropriate locking is taken.
-// The PortAllocator does not currently support mixing static portAllocations (or any pods with defined HostPort)
-// within the dynamic port range other than the ones it coordinates.
+// Ports are allocated from the default port range, unless a GameServerPort names another one of the
+// port ranges the PortAllocator is configured with. Port ranges must not overlap.
+// Pods that are not GameServers (such as DaemonSets) that define a HostPort within the port range are tracked,
+// and their ports are marked as taken, so they are not handed out to GameServers.
+// With sticky ports enabled, ports released by a Fleet's GameServers are kept aside, keyed by Fleet
+// and port ordinal, and preferred when allocating ports for that Fleet's new GameServers.
+// New ports are then handed out by wrapping around the port range, so released ports are the last to be reused
+// by anything else.
+// Nodes are added and removed from the port allocations as they are added or deleted, without rebuilding
+// the port allocations of the rest of the cluster. Cordoned nodes keep their port allocations, as the GameServers
+// and Pods on them keep running, but ports are not allocated from them until they are uncordoned.
 type PortAllocator struct {
-	logger             *logrus.Entry
-	mutex              sync.RWMutex
-	portAllocations    []portAllocation
-	gameServerRegistry map[types.UID]bool
-	minPort            int32
-	maxPort            int32
+	logger          *logrus.Entry
+	mutex           sync.RWMutex
+	portAllocations []portAllocation
+	// nodeNames is the name of the node of each of portAllocations, or empty for port allocations
+	// that were added because no node had enough open ports, and are waiting for a node to be added
+	nodeNames     []string
+	cordonedNodes map[string]bool
+	// gameServerRegistry is the port allocations that the ports of each GameServer are taken on,
+	// which are nil if it has no Dynamic or Passthrough ports
+	gameServerRegistry map[types.UID]portAllocation
+	// portHolders is the GameServer that holds each port that is taken by a GameServer, so a GameServer only
+	// ever releases its own ports
+	portHolders        map[allocatedPort]types.UID
+	podRegistry        map[types.UID]podPorts
+	portRanges         map[string]*portRange
+	stickyPorts        bool
+	releasedPorts      map[string][]int32
 	gameServerSynced   cache.InformerSynced
 	gameServerLister   listerv1alpha1.GameServerLister
 	gameServerInformer cache.SharedIndexInformer
 	nodeSynced         cache.InformerSynced
 	nodeLister         corelisterv1.NodeLister
 	nodeInformer       cache.SharedIndexInformer
+	podSynced          cache.InformerSynced
+	podLister          corelisterv1.PodLister
+	podInformer        cache.SharedIndexInformer
 }
 
 // NewPortAllocator returns a new dynamic port
-// allocator. minPort and maxPort are the top and bottom portAllocations that can be allocated in the range for
-// the game servers
-func NewPortAllocator(minPort, maxPort int32,
+// allocator. minPort and maxPort are the top and bottom portAllocations that can be allocated in the default range for
+// the game servers, and additionalPortRanges are the other named port ranges that GameServerPorts can be allocated from.
+// stickyPorts enables reuse of the ports previously held by a Fleet's GameServers.
+func NewPortAllocator(minPort, maxPort int32, additionalPortRanges map[string]PortRange, stickyPorts bool,
 	kubeInformerFactory informers.SharedInformerFactory,
 	agonesInformerFactory externalversions.SharedInformerFactory) *PortAllocator {
 
 	v1 := kubeInformerFactory.Core().V1()
 	nodes := v1.Nodes()
+	pods := v1.Pods()
 	gameServers := agonesInformerFactory.Stable().V1alpha1().GameServers()
 
+	portRanges := map[string]*portRange{
+		v1alpha1.DefaultPortRange: {PortRange: PortRange{MinPort: minPort, MaxPort: maxPort}, nextPort: minPort},
+	}
+	for name, r := range additionalPortRanges {
+		portRanges[name] = &portRange{PortRange: r, nextPort: r.MinPort}
+	}
+
 	pa := &PortAllocator{
 		mutex:              sync.RWMutex{},
-		minPort:            minPort,
-		maxPort:            maxPort,
-		gameServerRegistry: map[types.UID]bool{},
+		portRanges:         portRanges,
+		stickyPorts:        stickyPorts,
+		releasedPorts:      map[string][]int32{},
+		cordonedNodes:      map[string]bool{},
+		gameServerRegistry: map[types.UID]portAllocation{},
+		portHolders:        map[allocatedPort]types.UID{},
+		podRegistry:        map[types.UID]podPorts{},
 		gameServerSynced:   gameServers.Informer().HasSynced,
 		gameServerLister:   gameServers.Lister(),
 		gameServerInformer: gameServers.Informer(),
 		nodeLister:         nodes.Lister(),
 		nodeInformer:       nodes.Informer(),
 		nodeSynced:         nodes.Informer().HasSynced,
+		podLister:          pods.Lister(),
+		podInformer:        pods.Informer(),
+		podSynced:          pods.Informer().HasSynced,
 	}
 	pa.logger = runtime.NewLoggerWithType(pa)
 
 	pa.gameServerInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: pa.syncUpdateGameServer,
 		DeleteFunc: pa.syncDeleteGameServer,
 	})
+	pa.podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    pa.syncAddPod,
+		UpdateFunc: pa.syncUpdatePod,
+		DeleteFunc: pa.syncDeletePod,
+	})
+	pa.nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    pa.syncAddNode,
+		UpdateFunc: pa.syncUpdateNode,
+		DeleteFunc: pa.syncDeleteNode,
+	})
 
-	pa.logger.WithField("minPort", minPort).WithField("maxPort", maxPort).Info("Starting")
+	pa.logger.WithField("minPort", minPort).WithField("maxPort", maxPort).WithField("additionalPortRanges", additionalPortRanges).
+		WithField("stickyPorts", stickyPorts).Info("Starting")
 	return pa
 }
 
@@ -93,7 +208,7 @@ func NewPortAllocator(minPort, maxPort int32,
 func (pa *PortAllocator) Run(stop <-chan struct{}) error {
 	pa.logger.Info("Running")
 
-	if !cache.WaitForCacheSync(stop, pa.gameServerSynced, pa.nodeSynced) {
+	if !cache.WaitForCacheSync(stop, pa.gameServerSynced, pa.nodeSynced, pa.podSynced) {
 		return errors.New("failed to wait for caches to sync")
 	}
 
@@ -102,80 +217,181 @@ func (pa *PortAllocator) Run(stop <-chan struct{}) error {
 		return errors.Wrap(err, "error performing initial sync")
 	}
 
+	go wait.Until(pa.recordMetrics, metrics.MetricResyncPeriod, stop)
+
 	return nil
 }
 
-// Allocate assigns a port to the GameServer and returns it.
-// Return ErrPortNotFound if no port is allocatable
-func (pa *PortAllocator) Allocate(gs *v1alpha1.GameServer) *v1alpha1.GameServer {
+// recordMetrics records how many ports of each port range are allocated and free,
+// across all nodes and on each node
+func (pa *PortAllocator) recordMetrics() {
+	pa.mutex.RLock()
+	defer pa.mutex.RUnlock()
+
+	for name, r := range pa.portRanges {
+		var allocated int64
+		free := make([]int64, len(pa.portAllocations))
+		for i, n := range pa.portAllocations {
+			for p := r.MinPort; p <= r.MaxPort; p++ {
+				if n[p] {
+					allocated++
+				} else {
+					free[i]++
+				}
+			}
+		}
+		metrics.RecordPortAllocatorPorts(name, allocated, free)
+	}
+}
+
+// Allocate assigns ports to all the Dynamic and Passthrough ports of the GameServer and returns it.
+// All of the GameServer's ports are taken from the same node's port allocations, or none of them are,
+// as a GameServer with ports split across nodes could never be scheduled. If no node has enough open ports,
+// a new node is added, and allocation is tried again. Cordoned nodes are skipped.
+// Returns ErrPortRangeNotFound if the GameServer requests ports from a port range that does not exist, and
+// ErrNotEnoughPorts if it requests more ports than there are in a port range.
+func (pa *PortAllocator) Allocate(gs *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
 	pa.mutex.Lock()
 	defer pa.mutex.Unlock()
 
-	type pn struct {
-		pa   portAllocation
-		port int32
+	counts := countPortRanges(gs)
+	for name, count := range counts {
+		r, ok := pa.portRanges[name]
+		if !ok {
+			metrics.RecordPortAllocationFailure(name, failurePortRangeNotFound)
+			return gs, errors.Wrapf(ErrPortRangeNotFound, "GameServer %s requests ports from port range %s", gs.ObjectMeta.Name, name)
+		}
+		if count > r.portCount() {
+			metrics.RecordPortAllocationFailure(name, failureNotEnoughPorts)
+			return gs, errors.Wrapf(ErrNotEnoughPorts, "GameServer %s requests %d ports from port range %s, but it only has %d", gs.ObjectMeta.Name, count, name, r.portCount())
+		}
+	}
+
+	pa.gameServerRegistry[gs.ObjectMeta.UID] = nil
+	if len(counts) == 0 {
+		return gs, nil
 	}
 
 	// we only want this to be called inside the mutex lock
 	// so let's define the function here so it can never be called elsewhere.
-	// Also the return gives an escape from the double loop
-	findOpenPorts := func(amount int) []pn {
-		var ports []pn
-		for _, n := range pa.portAllocations {
-			for p, taken := range n {
-				if !taken {
-					ports = append(ports, pn{pa: n, port: p})
-					// only allocate as many ports as are asked for by the GameServer
-					if len(ports) == amount {
-						return ports
+	// findNodePorts returns a port from n for each of the GameServer's Dynamic and Passthrough ports, keyed by
+	// the index of the port in the GameServer's spec, along with which of those were previously released by the
+	// GameServer's Fleet. Nothing is marked as taken, and nil is returned if n does not have enough open ports,
+	// in which case exhausted is set to the port range that ran out.
+	var exhausted string
+	findNodePorts := func(n portAllocation) (map[int]int32, map[int]bool) {
+		result := map[int]int32{}
+		released := map[int]bool{}
+		chosen := map[int32]bool{}
+		// indexes of the ports that still need an open port, by port range
+		open := map[string][]int{}
+
+		for i, p := range gs.Spec.Ports {
+			if !isAllocatablePortPolicy(p.PortPolicy) {
+				continue
+			}
+			r := pa.portRanges[portRangeName(p.Range)]
+			if key, ok := stickyPortKey(gs, i); ok && pa.stickyPorts {
+				for _, port := range pa.releasedPorts[key] {
+					if taken, ok := n[port]; ok && !taken && !chosen[port] && r.contains(port) {
+						result[i] = port
+						released[i] = true
+						chosen[port] = true
+						break
 					}
 				}
 			}
+			if !released[i] {
+				open[portRangeName(p.Range)] = append(open[portRangeName(p.Range)], i)
+			}
 		}
-		return ports
-	}
 
-	// this allows us to do recursion, within the mutex lock
-	var allocate func(gs *v1alpha1.GameServer) *v1alpha1.GameServer
-	allocate = func(gs *v1alpha1.GameServer) *v1alpha1.GameServer {
-		amount := gs.CountPorts(func(policy v1alpha1.PortPolicy) bool {
-			return policy == v1alpha1.Dynamic || policy == v1alpha1.Passthrough
-		})
-		allocations := findOpenPorts(amount)
+		for name, indexes := range open {
+			r := pa.portRanges[name]
+			if pa.stickyPorts {
+				// wrap around the port range, starting from after the last port that was handed out
+				for j := int32(0); j <= r.MaxPort-r.MinPort && len(indexes) > 0; j++ {
+					p := r.MinPort + (r.nextPort-r.MinPort+j)%(r.MaxPort-r.MinPort+1)
+					if !n[p] && !chosen[p] {
+						result[indexes[0]] = p
+						chosen[p] = true
+						indexes = indexes[1:]
+					}
+				}
+			} else {
+				for p, taken := range n {
+					if len(indexes) == 0 {
+						break
+					}
+					if !taken && !chosen[p] && r.contains(p) {
+						result[indexes[0]] = p
+						chosen[p] = true
+						indexes = indexes[1:]
+					}
+				}
+			}
+
+			if len(indexes) > 0 {
+				exhausted = name
+				return nil, nil
+			}
+		}
 
-		if len(allocations) == amount {
-			pa.gameServerRegistry[gs.ObjectMeta.UID] = true
+		return result, released
+	}
 
-			for i, p := range gs.Spec.Ports {
-				if p.PortPolicy == v1alpha1.Dynamic || p.PortPolicy == v1alpha1.Passthrough {
-					// pop off allocation
-					var a pn
-					a, allocations = allocations[0], allocations[1:]
-					a.pa[a.port] = true
-					gs.Spec.Ports[i].HostPort = a.port
+	for {
+		for i, n := range pa.portAllocations {
+			if pa.cordonedNodes[pa.nodeNames[i]] {
+				continue
+			}
+			ports, released := findNodePorts(n)
+			if ports == nil {
+				continue
+			}
 
-					if p.PortPolicy == v1alpha1.Passthrough {
-						gs.Spec.Ports[i].ContainerPort = a.port
+			// in spec order, so sticky ports carry on wrapping around from the last port
+			for i := range gs.Spec.Ports {
+				port, ok := ports[i]
+				if !ok {
+					continue
+				}
+				pa.takeGameServerPort(n, port, gs.ObjectMeta.UID)
+				gs.Spec.Ports[i].HostPort = port
+				if gs.Spec.Ports[i].PortPolicy == v1alpha1.Passthrough {
+					gs.Spec.Ports[i].ContainerPort = port
+				}
+
+				if released[i] {
+					key, _ := stickyPortKey(gs, i)
+					pa.releasedPorts[key] = removePort(pa.releasedPorts[key], port)
+				} else {
+					r := pa.portRanges[portRangeName(gs.Spec.Ports[i].Range)]
+					r.nextPort = port + 1
+					if r.nextPort > r.MaxPort {
+						r.nextPort = r.MinPort
 					}
 				}
 			}
 
-			return gs
+			pa.gameServerRegistry[gs.ObjectMeta.UID] = n
+			return gs, nil
 		}
 
 		// if we get here, we ran out of ports. Add a node, and try again.
 		// this is important, because to autoscale scale up, we create GameServers that
 		// can't be scheduled on the current set of nodes, so we need to be sure
 		// there are always ports available to be allocated.
+		if exhausted != "" {
+			metrics.RecordPortAllocationFailure(exhausted, failureExhausted)
+		}
 		pa.portAllocations = append(pa.portAllocations, pa.newPortAllocation())
-
-		return allocate(gs)
+		pa.nodeNames = append(pa.nodeNames, "")
 	}
-
-	return allocate(gs)
 }
 
-// DeAllocate marks the given port as no longer allocated
+// DeAllocate marks the ports of the GameServer as no longer allocated on its node, or if it has not
+// been scheduled, on the port allocations they were taken on
 func (pa *PortAllocator) DeAllocate(gs *v1alpha1.GameServer) {
 	// skip if it wasn't previously allocated
 
@@ -196,16 +412,83 @@ func (pa *PortAllocator) DeAllocate(gs *v1alpha1.GameServer) {
 
 	pa.mutex.Lock()
 	defer pa.mutex.Unlock()
-	for _, p := range gs.Spec.Ports {
-		if p.HostPort < pa.minPort || p.HostPort > pa.maxPort {
+	pa.moveGameServerPorts(gs)
+	n := pa.gameServerRegistry[gs.ObjectMeta.UID]
+	for i, p := range gs.Spec.Ports {
+		r := pa.portRangeOf(p.HostPort)
+		if r == nil || !isAllocatablePortPolicy(p.PortPolicy) {
 			continue
 		}
-		pa.portAllocations = setPortAllocation(p.HostPort, pa.portAllocations, false)
+		pa.releaseGameServerPort(n, p.HostPort, gs.ObjectMeta.UID)
+
+		if pa.stickyPorts {
+			if key, ok := stickyPortKey(gs, i); ok && len(pa.releasedPorts[key]) < r.portCount() {
+				pa.releasedPorts[key] = append(pa.releasedPorts[key], p.HostPort)
+			}
+		}
 	}
 
 	delete(pa.gameServerRegistry, gs.ObjectMeta.UID)
 }
 
+// syncUpdateGameServer moves the ports of a GameServer to the port allocations of its node once it
+// has been scheduled, as it may not be the node they were taken on when it was allocated
+func (pa *PortAllocator) syncUpdateGameServer(oldObj, newObj interface{}) {
+	oldGs, ok := oldObj.(*v1alpha1.GameServer)
+	if !ok {
+		return
+	}
+	newGs, ok := newObj.(*v1alpha1.GameServer)
+	if !ok || oldGs.Status.NodeName == newGs.Status.NodeName {
+		return
+	}
+
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+	pa.moveGameServerPorts(newGs)
+}
+
+// moveGameServerPorts releases the ports of a registered GameServer from the port allocations they were
+// taken on, and takes them on the port allocations of its node instead, if it has been scheduled on a known node.
+// Should only be called with the mutex locked.
+func (pa *PortAllocator) moveGameServerPorts(gs *v1alpha1.GameServer) {
+	taken, ok := pa.gameServerRegistry[gs.ObjectMeta.UID]
+	n := pa.portAllocationOf(gs.Status.NodeName)
+	if !ok || n == nil {
+		return
+	}
+	for _, p := range gs.Spec.Ports {
+		if !isAllocatablePortPolicy(p.PortPolicy) || pa.portRangeOf(p.HostPort) == nil {
+			continue
+		}
+		pa.releaseGameServerPort(taken, p.HostPort, gs.ObjectMeta.UID)
+		pa.takeGameServerPort(n, p.HostPort, gs.ObjectMeta.UID)
+	}
+	pa.gameServerRegistry[gs.ObjectMeta.UID] = n
+}
+
+// takeGameServerPort marks port as taken on n by the GameServer with the uid.
+// Should only be called with the mutex locked.
+func (pa *PortAllocator) takeGameServerPort(n portAllocation, port int32, uid types.UID) {
+	n[port] = true
+	pa.portHolders[allocatedPortOf(n, port)] = uid
+}
+
+// releaseGameServerPort makes port available on n, if the GameServer with the uid holds it there, as a GameServer
+// that was allocated ports on one node, but scheduled on another, leaves them to a GameServer scheduled on the first.
+// Should only be called with the mutex locked.
+func (pa *PortAllocator) releaseGameServerPort(n portAllocation, port int32, uid types.UID) {
+	if n == nil {
+		return
+	}
+	key := allocatedPortOf(n, port)
+	if pa.portHolders[key] != uid {
+		return
+	}
+	n[port] = false
+	delete(pa.portHolders, key)
+}
+
 // syncDeleteGameServer when a GameServer Pod is deleted
 // make the HostPort available
 func (pa *PortAllocator) syncDeleteGameServer(object interface{}) {
@@ -215,12 +498,276 @@ func (pa *PortAllocator) syncDeleteGameServer(object interface{}) {
 	}
 }
 
+// syncAddPod marks the HostPorts within the port range of a Pod that is not a GameServer as taken,
+// on the Pod's node if it has been scheduled
+func (pa *PortAllocator) syncAddPod(object interface{}) {
+	pod, ok := object.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+	pa.syncPodPorts(pod)
+}
+
+// syncUpdatePod moves the HostPorts of a Pod that is not a GameServer to its node once it is scheduled,
+// and releases them once it has Succeeded or Failed
+func (pa *PortAllocator) syncUpdatePod(oldObj, newObj interface{}) {
+	oldPod, ok := oldObj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	newPod, ok := newObj.(*corev1.Pod)
+	if !ok || (oldPod.Spec.NodeName == newPod.Spec.NodeName && oldPod.Status.Phase == newPod.Status.Phase) {
+		return
+	}
+
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+	pa.syncPodPorts(newPod)
+}
+
+// syncDeletePod when a Pod that is not a GameServer is deleted,
+// make its HostPorts available
+func (pa *PortAllocator) syncDeletePod(object interface{}) {
+	pod, ok := object.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+	pa.releasePodPorts(pod)
+}
+
+// syncPodPorts marks the HostPorts of a Pod that is not a GameServer as taken on its node, and if they were
+// taken before the Pod was scheduled, or it no longer has any, releases the ports it had taken.
+// Should only be called with the mutex locked.
+func (pa *PortAllocator) syncPodPorts(pod *corev1.Pod) {
+	ports := pa.externalHostPorts(pod)
+	if registered, ok := pa.podRegistry[pod.ObjectMeta.UID]; ok {
+		if len(ports) > 0 && (registered.node != "" || pod.Spec.NodeName == "") {
+			return
+		}
+		pa.releasePodPorts(pod)
+	}
+	if len(ports) == 0 {
+		return
+	}
+
+	pa.logger.WithField("pod", pod.ObjectMeta.Name).WithField("node", pod.Spec.NodeName).WithField("ports", ports).
+		Info("Marking HostPorts of non GameServer Pod as taken")
+	pa.podRegistry[pod.ObjectMeta.UID] = pa.takePodPorts(pod.Spec.NodeName, ports)
+}
+
+// takePodPorts marks the ports of a Pod that is not a GameServer as taken on the named node, or if there are
+// no port allocations for it, each on the first port allocations that it is open on.
+// Should only be called with the mutex locked.
+func (pa *PortAllocator) takePodPorts(node string, ports []int32) podPorts {
+	registered := podPorts{ports: ports, allocations: make([]portAllocation, len(ports))}
+	n := pa.portAllocationOf(node)
+	if n != nil {
+		registered.node = node
+	}
+	for i, p := range ports {
+		allocation := n
+		if allocation == nil {
+			for _, a := range pa.portAllocations {
+				if !a[p] {
+					allocation = a
+					break
+				}
+			}
+		}
+		if allocation != nil {
+			allocation[p] = true
+			registered.allocations[i] = allocation
+		}
+	}
+	return registered
+}
+
+// releasePodPorts makes the HostPorts that a Pod that is not a GameServer had taken available.
+// Should only be called with the mutex locked.
+func (pa *PortAllocator) releasePodPorts(pod *corev1.Pod) {
+	registered, ok := pa.podRegistry[pod.ObjectMeta.UID]
+	if !ok {
+		return
+	}
+
+	pa.logger.WithField("pod", pod.ObjectMeta.Name).WithField("ports", registered.ports).Info("Releasing HostPorts of non GameServer Pod")
+	for i, p := range registered.ports {
+		// if the node has been removed, so have its port allocations, so this does nothing
+		if a := registered.allocations[i]; a != nil {
+			a[p] = false
+		}
+	}
+	delete(pa.podRegistry, pod.ObjectMeta.UID)
+}
+
+// portAllocationOf returns the port allocations of the named node, or nil if it doesn't have any.
+// Should only be called with the mutex locked.
+func (pa *PortAllocator) portAllocationOf(node string) portAllocation {
+	if node == "" {
+		return nil
+	}
+	for i, n := range pa.nodeNames {
+		if n == node {
+			return pa.portAllocations[i]
+		}
+	}
+	return nil
+}
+
+// syncAddNode adds port allocations for a Node
+func (pa *PortAllocator) syncAddNode(object interface{}) {
+	node, ok := object.(*corev1.Node)
+	if !ok {
+		return
+	}
+
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+	pa.addNode(node.ObjectMeta.Name, node.Spec.Unschedulable)
+}
+
+// syncUpdateNode marks a Node as cordoned or uncordoned, keeping its port allocations either way,
+// as the GameServers and Pods on a cordoned Node keep running
+func (pa *PortAllocator) syncUpdateNode(oldObj, newObj interface{}) {
+	oldNode, ok := oldObj.(*corev1.Node)
+	if !ok {
+		return
+	}
+	newNode, ok := newObj.(*corev1.Node)
+	if !ok || oldNode.Spec.Unschedulable == newNode.Spec.Unschedulable {
+		return
+	}
+
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+	pa.addNode(newNode.ObjectMeta.Name, newNode.Spec.Unschedulable)
+}
+
+// syncDeleteNode removes the port allocations of a deleted Node
+func (pa *PortAllocator) syncDeleteNode(object interface{}) {
+	node, ok := object.(*corev1.Node)
+	if !ok {
+		return
+	}
+
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+	pa.removeNode(node.ObjectMeta.Name)
+}
+
+// addNode adds port allocations for the named node, if it doesn't already have them, and records whether it is cordoned.
+// If port allocations were previously added because no node had enough open ports, a schedulable node takes
+// those over, since the GameServers that were given those ports are waiting for a node such as this one.
+// The ports of the GameServers and Pods already on the node are marked as taken, in case it was known before.
+// Should only be called with the mutex locked.
+func (pa *PortAllocator) addNode(name string, cordoned bool) {
+	if cordoned {
+		pa.cordonedNodes[name] = true
+	} else {
+		delete(pa.cordonedNodes, name)
+	}
+	for _, n := range pa.nodeNames {
+		if n == name {
+			return
+		}
+	}
+
+	pa.logger.WithField("node", name).Info("Adding port allocations for node")
+	added := false
+	for i, n := range pa.nodeNames {
+		if n == "" && !cordoned {
+			pa.nodeNames[i] = name
+			added = true
+			break
+		}
+	}
+	if !added {
+		pa.portAllocations = append(pa.portAllocations, pa.newPortAllocation())
+		pa.nodeNames = append(pa.nodeNames, name)
+	}
+	pa.seedNode(name)
+}
+
+// seedNode marks the ports of the registered GameServers and the Pods that are not GameServers on the named node
+// as taken on its port allocations.
+// Should only be called with the mutex locked.
+func (pa *PortAllocator) seedNode(name string) {
+	gameservers, err := pa.gameServerLister.List(labels.Everything())
+	if err != nil {
+		pa.logger.WithError(err).WithField("node", name).Warn("Could not list GameServers to seed the port allocations of node")
+	}
+	for _, gs := range gameservers {
+		if gs.Status.NodeName == name {
+			pa.moveGameServerPorts(gs)
+		}
+	}
+
+	pods, err := pa.podLister.List(labels.Everything())
+	if err != nil {
+		pa.logger.WithError(err).WithField("node", name).Warn("Could not list Pods to seed the port allocations of node")
+	}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == name {
+			pa.releasePodPorts(pod)
+			pa.syncPodPorts(pod)
+		}
+	}
+}
+
+// removeNode removes the port allocations of the named node, if it has any.
+// Should only be called with the mutex locked.
+func (pa *PortAllocator) removeNode(name string) {
+	for i, n := range pa.nodeNames {
+		if n == name {
+			pa.logger.WithField("node", name).Info("Removing port allocations for node")
+			removed := reflect.ValueOf(pa.portAllocations[i]).Pointer()
+			for key := range pa.portHolders {
+				if key.allocation == removed {
+					delete(pa.portHolders, key)
+				}
+			}
+			pa.portAllocations = append(pa.portAllocations[:i:i], pa.portAllocations[i+1:]...)
+			pa.nodeNames = append(pa.nodeNames[:i:i], pa.nodeNames[i+1:]...)
+			delete(pa.cordonedNodes, name)
+			return
+		}
+	}
+}
+
+// externalHostPorts returns the HostPorts within the port range of a Pod that is not a GameServer,
+// and is still running, or about to.
+func (pa *PortAllocator) externalHostPorts(pod *corev1.Pod) []int32 {
+	if v1alpha1.GameServerRolePodSelector.Matches(labels.Set(pod.ObjectMeta.Labels)) {
+		return nil
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return nil
+	}
+
+	var ports []int32
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if pa.portRangeOf(p.HostPort) != nil {
+				ports = append(ports, p.HostPort)
+			}
+		}
+	}
+	return ports
+}
+
 // syncAll syncs the pod, node and gameserver caches then
 // traverses all Nodes in the cluster and all looks at GameServers
-// and Terminating Pods values make sure those
+// and Pods that are not GameServers to make sure those
 // portAllocations are marked as taken.
 // Locks the mutex while doing this.
 // This is basically a stop the world Garbage Collection on port allocations, but it only happens on startup.
+// After that, node changes are applied incrementally by addNode and removeNode.
 func (pa *PortAllocator) syncAll() error {
 	pa.mutex.Lock()
 	defer pa.mutex.Unlock()
@@ -237,29 +784,55 @@ func (pa *PortAllocator) syncAll() error {
 		return errors.Wrapf(err, "error listing all GameServers")
 	}
 
-	gsRegistry := map[types.UID]bool{}
+	pods, err := pa.podLister.List(labels.Everything())
+	if err != nil {
+		return errors.Wrap(err, "error listing all Pods")
+	}
+
+	gsRegistry := map[types.UID]portAllocation{}
+	podRegistry := map[types.UID]podPorts{}
+	pa.portHolders = map[allocatedPort]types.UID{}
+	cordonedNodes := map[string]bool{}
+	for _, n := range nodes {
+		if n.Spec.Unschedulable {
+			cordonedNodes[n.ObjectMeta.Name] = true
+		}
+	}
 
-	// place to put GameServer port allocations that are not ready yet/after the ready state
-	allocations, nonReadyNodesPorts := pa.registerExistingGameServerPorts(gameservers, nodes, gsRegistry)
+	// GameServers that have ports, but are not ready yet/after the ready state
+	allocations, nodeNames, nonReadyGameServers := pa.registerExistingGameServerPorts(gameservers, pods, nodes, gsRegistry, podRegistry)
 
-	// close off the port on the first node you find
+	pa.portAllocations = allocations
+	pa.nodeNames = nodeNames
+	pa.cordonedNodes = cordonedNodes
+	pa.gameServerRegistry = gsRegistry
+	pa.podRegistry = podRegistry
+
+	// close off the ports on the first schedulable node you find that has all of them open
 	// we actually don't mind what node it is, since we only care
 	// that there is a port open *somewhere* as the default scheduler
 	// will re-route for us based on HostPort allocation
-	for _, p := range nonReadyNodesPorts {
-		allocations = setPortAllocation(p, allocations, true)
+	for _, gs := range nonReadyGameServers {
+		pa.takeGameServerPorts(gs)
 	}
 
-	pa.portAllocations = allocations
-	pa.gameServerRegistry = gsRegistry
+	// the same goes for Pods that are not GameServers, but their ports are tracked, so they can be released
+	for uid, registered := range podRegistry {
+		if registered.allocations == nil {
+			podRegistry[uid] = pa.takePodPorts("", registered.ports)
+		}
+	}
 
 	return nil
 }
 
-// registerExistingGameServerPorts registers the gameservers against gsRegistry and the ports against nodePorts.
-// and returns an ordered list of portAllocations per cluster nodes, and an array of
-// any GameServers allocated a port, but not yet assigned a Node will returned as an array of port values.
-func (pa *PortAllocator) registerExistingGameServerPorts(gameservers []*v1alpha1.GameServer, nodes []*corev1.Node, gsRegistry map[types.UID]bool) ([]portAllocation, []int32) {
+// registerExistingGameServerPorts registers the gameservers against gsRegistry, the Pods that are not GameServers
+// against podRegistry, and the ports of both against nodePorts.
+// and returns an ordered list of portAllocations per cluster nodes, the names of those nodes in the same order, and
+// any GameServers with a port, but not yet assigned a Node. Those GameServers, and Pods that are not yet assigned a Node
+// are registered without any port allocations.
+func (pa *PortAllocator) registerExistingGameServerPorts(gameservers []*v1alpha1.GameServer, pods []*corev1.Pod, nodes []*corev1.Node,
+	gsRegistry map[types.UID]portAllocation, podRegistry map[types.UID]podPorts) ([]portAllocation, []string, []*v1alpha1.GameServer) {
 	// setup blank port values
 	nodePortAllocation := pa.nodePortAllocation(nodes)
 	nodePortCount := make(map[string]int64, len(nodes))
@@ -267,25 +840,49 @@ func (pa *PortAllocator) registerExistingGameServerPorts(gameservers []*v1alpha1
 		nodePortCount[n.ObjectMeta.Name] = 0
 	}
 
-	var nonReadyNodesPorts []int32
+	var nonReadyGameServers []*v1alpha1.GameServer
 
 	for _, gs := range gameservers {
+		if len(countPortRanges(gs)) == 0 {
+			continue
+		}
+
+		// if the node doesn't exist, it's likely unscheduled
+		n, ok := nodePortAllocation[gs.Status.NodeName]
+		if gs.Status.NodeName == "" || !ok {
+			gsRegistry[gs.ObjectMeta.UID] = nil
+			nonReadyGameServers = append(nonReadyGameServers, gs)
+			continue
+		}
+		gsRegistry[gs.ObjectMeta.UID] = n
 		for _, p := range gs.Spec.Ports {
-			if p.PortPolicy == v1alpha1.Dynamic || p.PortPolicy == v1alpha1.Passthrough {
-				gsRegistry[gs.ObjectMeta.UID] = true
-
-				// if the node doesn't exist, it's likely unscheduled
-				_, ok := nodePortAllocation[gs.Status.NodeName]
-				if gs.Status.NodeName != "" && ok {
-					nodePortAllocation[gs.Status.NodeName][p.HostPort] = true
-					nodePortCount[gs.Status.NodeName]++
-				} else if p.HostPort != 0 {
-					nonReadyNodesPorts = append(nonReadyNodesPorts, p.HostPort)
-				}
+			if isAllocatablePortPolicy(p.PortPolicy) {
+				pa.takeGameServerPort(n, p.HostPort, gs.ObjectMeta.UID)
+				nodePortCount[gs.Status.NodeName]++
 			}
 		}
 	}
 
+	for _, pod := range pods {
+		ports := pa.externalHostPorts(pod)
+		if len(ports) == 0 {
+			continue
+		}
+
+		n, ok := nodePortAllocation[pod.Spec.NodeName]
+		if pod.Spec.NodeName == "" || !ok {
+			podRegistry[pod.ObjectMeta.UID] = podPorts{ports: ports}
+			continue
+		}
+		registered := podPorts{node: pod.Spec.NodeName, ports: ports}
+		for _, p := range ports {
+			n[p] = true
+			nodePortCount[pod.Spec.NodeName]++
+			registered.allocations = append(registered.allocations, n)
+		}
+		podRegistry[pod.ObjectMeta.UID] = registered
+	}
+
 	// make a list of the keys
 	keys := make([]string, 0, len(nodePortAllocation))
 	for k := range nodePortAllocation {
@@ -305,40 +902,143 @@ func (pa *PortAllocator) registerExistingGameServerPorts(gameservers []*v1alpha1
 
 	}
 
-	return allocations, nonReadyNodesPorts
+	return allocations, keys, nonReadyGameServers
+}
+
+// takeGameServerPorts marks the ports of a registered GameServer that has not been scheduled yet as taken on the
+// first port allocations of a schedulable node that has all of them open, adding port allocations if none do,
+// as Allocate would have.
+// Should only be called with the mutex locked.
+func (pa *PortAllocator) takeGameServerPorts(gs *v1alpha1.GameServer) {
+	var ports []int32
+	for _, p := range gs.Spec.Ports {
+		if isAllocatablePortPolicy(p.PortPolicy) && pa.portRangeOf(p.HostPort) != nil {
+			ports = append(ports, p.HostPort)
+		}
+	}
+	if len(ports) == 0 {
+		return
+	}
+
+	var taken portAllocation
+	for i, n := range pa.portAllocations {
+		if pa.cordonedNodes[pa.nodeNames[i]] {
+			continue
+		}
+		open := true
+		for _, p := range ports {
+			if n[p] {
+				open = false
+				break
+			}
+		}
+		if open {
+			taken = n
+			break
+		}
+	}
+	if taken == nil {
+		taken = pa.newPortAllocation()
+		pa.portAllocations = append(pa.portAllocations, taken)
+		pa.nodeNames = append(pa.nodeNames, "")
+	}
+
+	for _, p := range ports {
+		pa.takeGameServerPort(taken, p, gs.ObjectMeta.UID)
+	}
+	pa.gameServerRegistry[gs.ObjectMeta.UID] = taken
 }
 
 // nodePortAllocation returns a map of port allocations all set to being available
-// with a map key for each node, as well as the node registry record (since we're already looping)
+// with a map key for each node, as well as the node registry record (since we're already looping).
+// Unschedulable nodes have port allocations too, for the GameServers and Pods that are still running on them.
 func (pa *PortAllocator) nodePortAllocation(nodes []*corev1.Node) map[string]portAllocation {
 	nodePorts := map[string]portAllocation{}
 
 	for _, n := range nodes {
-		// ignore unschedulable nodes
-		if !n.Spec.Unschedulable {
-			nodePorts[n.Name] = pa.newPortAllocation()
-		}
+		nodePorts[n.Name] = pa.newPortAllocation()
 	}
 
 	return nodePorts
 }
 
 func (pa *PortAllocator) newPortAllocation() portAllocation {
-	p := make(portAllocation, (pa.maxPort-pa.minPort)+1)
-	for i := pa.minPort; i <= pa.maxPort; i++ {
-		p[i] = false
+	size := 0
+	for _, r := range pa.portRanges {
+		size += r.portCount()
+	}
+	p := make(portAllocation, size)
+	for _, r := range pa.portRanges {
+		for i := r.MinPort; i <= r.MaxPort; i++ {
+			p[i] = false
+		}
 	}
 
 	return p
 }
 
-// setPortAllocation takes a port from an all
-func setPortAllocation(port int32, allocations []portAllocation, taken bool) []portAllocation {
-	for _, np := range allocations {
-		if np[port] != taken {
-			np[port] = taken
-			break
+// portRangeOf returns the port range that port is in, or nil if it is not in any of them
+func (pa *PortAllocator) portRangeOf(port int32) *portRange {
+	for _, r := range pa.portRanges {
+		if r.contains(port) {
+			return r
 		}
 	}
-	return allocations
+	return nil
+}
+
+// portRangeSize returns the number of ports in the named port range, and false if there is no
+// port range with that name. An empty name is the default port range.
+func (pa *PortAllocator) portRangeSize(name string) (int, bool) {
+	r, ok := pa.portRanges[portRangeName(name)]
+	if !ok {
+		return 0, false
+	}
+	return r.portCount(), true
+}
+
+// stickyPortKey returns the key that released ports are stored against for reuse,
+// which is the GameServer's Fleet and the ordinal of the port. Returns false if the GameServer
+// is not part of a Fleet.
+func stickyPortKey(gs *v1alpha1.GameServer, ordinal int) (string, bool) {
+	fleet, ok := gs.ObjectMeta.Labels[v1alpha1.FleetNameLabel]
+	if !ok || fleet == "" {
+		return "", false
+	}
+	return gs.ObjectMeta.Namespace + "/" + fleet + "/" + strconv.Itoa(ordinal), true
+}
+
+// removePort returns ports without the first instance of port
+func removePort(ports []int32, port int32) []int32 {
+	for i, p := range ports {
+		if p == port {
+			return append(ports[:i:i], ports[i+1:]...)
+		}
+	}
+	return ports
+}
+
+// portRangeName returns the name of the port range, treating an empty name as the default port range,
+// for GameServers created before port ranges could be named
+func portRangeName(name string) string {
+	if name == "" {
+		return v1alpha1.DefaultPortRange
+	}
+	return name
+}
+
+// countPortRanges returns the number of Dynamic and Passthrough ports the GameServer has in each port range
+func countPortRanges(gs *v1alpha1.GameServer) map[string]int {
+	result := map[string]int{}
+	for _, p := range gs.Spec.Ports {
+		if isAllocatablePortPolicy(p.PortPolicy) {
+			result[portRangeName(p.Range)]++
+		}
+	}
+	return result
+}
+
+// isAllocatablePortPolicy returns true if ports with this policy are allocated by the PortAllocator
+func isAllocatablePortPolicy(policy v1alpha1.PortPolicy) bool {
+	return policy == v1alpha1.Dynamic || policy == v1alpha1.Passthrough
 }
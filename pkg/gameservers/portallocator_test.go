@@ -15,13 +15,13 @@
 package gameservers
 
 import (
-	"fmt"
 	"strconv"
 	"sync"
 	"testing"
 
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
 	agtesting "agones.dev/agones/pkg/testing"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
@@ -45,7 +45,7 @@ func TestPortAllocatorAllocate(t *testing.T) {
 
 	t.Run("test allocated port counts", func(t *testing.T) {
 		m := agtesting.NewMocks()
-		pa := NewPortAllocator(10, 50, m.KubeInformerFactory, m.AgonesInformerFactory)
+		pa := NewPortAllocator(10, 50, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
 		nodeWatch := watch.NewFake()
 		m.KubeClient.AddWatchReactor("nodes", k8stesting.DefaultWatchReactor(nodeWatch, nil))
 
@@ -62,11 +62,11 @@ func TestPortAllocatorAllocate(t *testing.T) {
 		assert.Nil(t, err)
 
 		// single port dynamic
-		pa.Allocate(fixture.DeepCopy())
+		_, err = pa.Allocate(fixture.DeepCopy())
 		assert.Nil(t, err)
 		assert.Equal(t, 1, countTotalAllocatedPorts(pa))
 
-		pa.Allocate(fixture.DeepCopy())
+		_, err = pa.Allocate(fixture.DeepCopy())
 		assert.Nil(t, err)
 		assert.Equal(t, 2, countTotalAllocatedPorts(pa))
 
@@ -74,7 +74,7 @@ func TestPortAllocatorAllocate(t *testing.T) {
 		copy := fixture.DeepCopy()
 		copy.Spec.Ports = append(copy.Spec.Ports, v1alpha1.GameServerPort{Name: "another", ContainerPort: 6666, PortPolicy: v1alpha1.Dynamic})
 		assert.Len(t, copy.Spec.Ports, 2)
-		pa.Allocate(copy.DeepCopy())
+		_, err = pa.Allocate(copy.DeepCopy())
 		assert.Nil(t, err)
 		assert.Equal(t, 4, countTotalAllocatedPorts(pa))
 
@@ -82,7 +82,7 @@ func TestPortAllocatorAllocate(t *testing.T) {
 		copy = copy.DeepCopy()
 		copy.Spec.Ports = append(copy.Spec.Ports, v1alpha1.GameServerPort{Name: "another", ContainerPort: 6666, PortPolicy: v1alpha1.Dynamic})
 		assert.Len(t, copy.Spec.Ports, 3)
-		pa.Allocate(copy)
+		_, err = pa.Allocate(copy)
 		assert.Nil(t, err)
 		assert.Equal(t, 7, countTotalAllocatedPorts(pa))
 
@@ -91,7 +91,7 @@ func TestPortAllocatorAllocate(t *testing.T) {
 		expected := int32(9999)
 		copy.Spec.Ports = append(copy.Spec.Ports, v1alpha1.GameServerPort{Name: "another", ContainerPort: 6666, HostPort: expected, PortPolicy: v1alpha1.Static})
 		assert.Len(t, copy.Spec.Ports, 4)
-		pa.Allocate(copy)
+		_, err = pa.Allocate(copy)
 		assert.Nil(t, err)
 		assert.Equal(t, 10, countTotalAllocatedPorts(pa))
 		assert.Equal(t, v1alpha1.Static, copy.Spec.Ports[3].PortPolicy)
@@ -101,7 +101,7 @@ func TestPortAllocatorAllocate(t *testing.T) {
 		copy = fixture.DeepCopy()
 		copy.Spec.Ports[0] = v1alpha1.GameServerPort{Name: "passthrough", PortPolicy: v1alpha1.Passthrough}
 		assert.Len(t, copy.Spec.Ports, 1)
-		pa.Allocate(copy)
+		_, err = pa.Allocate(copy)
 		assert.NotEmpty(t, copy.Spec.Ports[0].HostPort)
 		assert.Equal(t, copy.Spec.Ports[0].HostPort, copy.Spec.Ports[0].ContainerPort)
 		assert.Nil(t, err)
@@ -110,7 +110,7 @@ func TestPortAllocatorAllocate(t *testing.T) {
 
 	t.Run("ports are all allocated", func(t *testing.T) {
 		m := agtesting.NewMocks()
-		pa := NewPortAllocator(10, 20, m.KubeInformerFactory, m.AgonesInformerFactory)
+		pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
 		nodeWatch := watch.NewFake()
 		m.KubeClient.AddWatchReactor("nodes", k8stesting.DefaultWatchReactor(nodeWatch, nil))
 
@@ -130,14 +130,15 @@ func TestPortAllocatorAllocate(t *testing.T) {
 			// ports between 10 and 20
 			for i := 10; i <= 20; i++ {
 				var p int32
-				gs := pa.Allocate(fixture.DeepCopy())
+				gs, err := pa.Allocate(fixture.DeepCopy())
 				assert.True(t, 10 <= gs.Spec.Ports[0].HostPort && gs.Spec.Ports[0].HostPort <= 20, "%v is not between 10 and 20", p)
 				assert.Nil(t, err)
 			}
 		}
 
 		assert.Len(t, pa.portAllocations, 2)
-		gs := pa.Allocate(fixture.DeepCopy())
+		gs, err := pa.Allocate(fixture.DeepCopy())
+		assert.Nil(t, err)
 		assert.NotEmpty(t, gs.Spec.Ports[0].HostPort)
 		assert.Len(t, pa.portAllocations, 3)
 	})
@@ -145,7 +146,7 @@ func TestPortAllocatorAllocate(t *testing.T) {
 	t.Run("ports are all allocated with multiple ports per GameServers", func(t *testing.T) {
 		m := agtesting.NewMocks()
 		maxPort := int32(19) // make sure we have an even number
-		pa := NewPortAllocator(10, maxPort, m.KubeInformerFactory, m.AgonesInformerFactory)
+		pa := NewPortAllocator(10, maxPort, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
 		nodeWatch := watch.NewFake()
 		m.KubeClient.AddWatchReactor("nodes", k8stesting.DefaultWatchReactor(nodeWatch, nil))
 
@@ -173,7 +174,7 @@ func TestPortAllocatorAllocate(t *testing.T) {
 			for i := 10; i <= 14; i++ {
 				copy := morePortFixture.DeepCopy()
 				copy.ObjectMeta.UID = types.UID(strconv.Itoa(x) + ":" + strconv.Itoa(i))
-				gs := pa.Allocate(copy)
+				gs, err := pa.Allocate(copy)
 
 				// Dynamic
 				assert.NotEmpty(t, gs.Spec.Ports[0].HostPort)
@@ -195,8 +196,10 @@ func TestPortAllocatorAllocate(t *testing.T) {
 		}
 
 		logrus.WithField("allocated", countTotalAllocatedPorts(pa)).WithField("count", len(pa.portAllocations[0])+len(pa.portAllocations[1])).Info("How many allocated")
-		assert.Len(t, pa.portAllocations, 3)
-		gs := pa.Allocate(fixture.DeepCopy())
+		// all of a GameServer's ports come from a single node, so only 3 GameServers fit on each node
+		assert.Len(t, pa.portAllocations, 4)
+		gs, err := pa.Allocate(fixture.DeepCopy())
+		assert.Nil(t, err)
 		assert.NotEmpty(t, gs.Spec.Ports[0].HostPort)
 		assert.Len(t, pa.portAllocations, 4)
 	})
@@ -204,7 +207,7 @@ func TestPortAllocatorAllocate(t *testing.T) {
 	t.Run("ports are unique in a node", func(t *testing.T) {
 		fixture := dynamicGameServerFixture()
 		m := agtesting.NewMocks()
-		pa := NewPortAllocator(10, 20, m.KubeInformerFactory, m.AgonesInformerFactory)
+		pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
 
 		m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			nl := &corev1.NodeList{Items: []corev1.Node{n1}}
@@ -216,18 +219,159 @@ func TestPortAllocatorAllocate(t *testing.T) {
 		assert.Nil(t, err)
 		var ports []int32
 		for i := 10; i <= 20; i++ {
-			gs := pa.Allocate(fixture.DeepCopy())
+			gs, err := pa.Allocate(fixture.DeepCopy())
 			assert.Nil(t, err)
 			assert.NotContains(t, ports, gs.Spec.Ports[0].HostPort)
 			ports = append(ports, gs.Spec.Ports[0].HostPort)
 		}
 	})
+
+	t.Run("all of a GameServer's ports come from a single node", func(t *testing.T) {
+		m := agtesting.NewMocks()
+		pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
+		m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			nl := &corev1.NodeList{Items: []corev1.Node{n1, n2}}
+			return true, nl, nil
+		})
+		_, cancel := agtesting.StartInformers(m, pa.nodeSynced)
+		defer cancel()
+		err := pa.syncAll()
+		assert.Nil(t, err)
+
+		// leave 3 open ports on the first node, and 5 on the second
+		for i := int32(10); i <= 17; i++ {
+			pa.portAllocations[0][i] = true
+		}
+		for i := int32(10); i <= 15; i++ {
+			pa.portAllocations[1][i] = true
+		}
+
+		manyPorts := func(count int) *v1alpha1.GameServer {
+			gs := fixture.DeepCopy()
+			gs.Spec.Ports = nil
+			for i := 0; i < count; i++ {
+				gs.Spec.Ports = append(gs.Spec.Ports, v1alpha1.GameServerPort{Name: strconv.Itoa(i), ContainerPort: int32(7000 + i), PortPolicy: v1alpha1.Dynamic})
+			}
+			return gs
+		}
+
+		// too many for the first node, which is left untouched
+		gs, err := pa.Allocate(manyPorts(4))
+		assert.Nil(t, err)
+		for _, p := range gs.Spec.Ports {
+			assert.True(t, 16 <= p.HostPort && p.HostPort <= 20, "%v is not on the second node", p)
+		}
+		assert.Equal(t, 8, countAllocatedPortsOnNode(pa, 0))
+		assert.Equal(t, 10, countAllocatedPortsOnNode(pa, 1))
+		assert.Len(t, pa.portAllocations, 2)
+
+		// too many for either node, so a new one is added
+		gs, err = pa.Allocate(manyPorts(11))
+		assert.Nil(t, err)
+		assert.Len(t, pa.portAllocations, 3)
+		assert.Equal(t, 8, countAllocatedPortsOnNode(pa, 0))
+		assert.Equal(t, 10, countAllocatedPortsOnNode(pa, 1))
+		assert.Equal(t, 11, countAllocatedPortsOnNode(pa, 2))
+		ports := map[int32]bool{}
+		for _, p := range gs.Spec.Ports {
+			ports[p.HostPort] = true
+		}
+		assert.Len(t, ports, 11)
+
+		// fits exactly on the first node
+		gs, err = pa.Allocate(manyPorts(3))
+		assert.Nil(t, err)
+		for _, p := range gs.Spec.Ports {
+			assert.True(t, 18 <= p.HostPort && p.HostPort <= 20, "%v is not on the first node", p)
+		}
+		assert.Equal(t, 11, countAllocatedPortsOnNode(pa, 0))
+	})
+
+	t.Run("more ports than the port range", func(t *testing.T) {
+		m := agtesting.NewMocks()
+		pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
+		m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			nl := &corev1.NodeList{Items: []corev1.Node{n1}}
+			return true, nl, nil
+		})
+		_, cancel := agtesting.StartInformers(m, pa.nodeSynced)
+		defer cancel()
+		err := pa.syncAll()
+		assert.Nil(t, err)
+
+		gs := fixture.DeepCopy()
+		gs.Spec.Ports = nil
+		for i := 0; i < 12; i++ {
+			gs.Spec.Ports = append(gs.Spec.Ports, v1alpha1.GameServerPort{Name: strconv.Itoa(i), ContainerPort: int32(7000 + i), PortPolicy: v1alpha1.Passthrough})
+		}
+
+		_, err = pa.Allocate(gs)
+		assert.Equal(t, ErrNotEnoughPorts, errors.Cause(err))
+		assert.Equal(t, 0, countTotalAllocatedPorts(pa))
+		assert.Len(t, pa.portAllocations, 1)
+		assert.Empty(t, pa.gameServerRegistry)
+	})
+
+	t.Run("ports from named port ranges", func(t *testing.T) {
+		m := agtesting.NewMocks()
+		pa := NewPortAllocator(10, 20, map[string]PortRange{"query": {MinPort: 30, MaxPort: 32}}, false, m.KubeInformerFactory, m.AgonesInformerFactory)
+		m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			nl := &corev1.NodeList{Items: []corev1.Node{n1, n2}}
+			return true, nl, nil
+		})
+		_, cancel := agtesting.StartInformers(m, pa.nodeSynced)
+		defer cancel()
+		err := pa.syncAll()
+		assert.Nil(t, err)
+
+		for i := 0; i < 6; i++ {
+			gs := fixture.DeepCopy()
+			gs.ObjectMeta.UID = types.UID(strconv.Itoa(i))
+			gs.Spec.Ports = append(gs.Spec.Ports, v1alpha1.GameServerPort{Name: "query", Range: "query", ContainerPort: 7777, PortPolicy: v1alpha1.Dynamic})
+			gs, err = pa.Allocate(gs)
+			assert.Nil(t, err)
+
+			assert.True(t, gs.Spec.Ports[0].HostPort >= 10 && gs.Spec.Ports[0].HostPort <= 20, "port %d", gs.Spec.Ports[0].HostPort)
+			assert.True(t, gs.Spec.Ports[1].HostPort >= 30 && gs.Spec.Ports[1].HostPort <= 32, "port %d", gs.Spec.Ports[1].HostPort)
+		}
+		assert.Equal(t, 12, countTotalAllocatedPorts(pa))
+		assert.Equal(t, 6, countAllocatedPortsOnNode(pa, 0))
+		assert.Equal(t, 6, countAllocatedPortsOnNode(pa, 1))
+
+		// the query range is full on both nodes, so a new node is needed
+		gs := fixture.DeepCopy()
+		gs.Spec.Ports = append(gs.Spec.Ports, v1alpha1.GameServerPort{Name: "query", Range: "query", ContainerPort: 7777, PortPolicy: v1alpha1.Dynamic})
+		_, err = pa.Allocate(gs)
+		assert.Nil(t, err)
+		assert.Len(t, pa.portAllocations, 3)
+		assert.Equal(t, 2, countAllocatedPortsOnNode(pa, 2))
+	})
+
+	t.Run("port range that does not exist", func(t *testing.T) {
+		m := agtesting.NewMocks()
+		pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
+		m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			nl := &corev1.NodeList{Items: []corev1.Node{n1}}
+			return true, nl, nil
+		})
+		_, cancel := agtesting.StartInformers(m, pa.nodeSynced)
+		defer cancel()
+		err := pa.syncAll()
+		assert.Nil(t, err)
+
+		gs := fixture.DeepCopy()
+		gs.Spec.Ports[0].Range = "query"
+		_, err = pa.Allocate(gs)
+		assert.Equal(t, ErrPortRangeNotFound, errors.Cause(err))
+		assert.Equal(t, 0, countTotalAllocatedPorts(pa))
+		assert.Empty(t, pa.gameServerRegistry)
+	})
 }
 
 func TestPortAllocatorMultithreadAllocate(t *testing.T) {
 	fixture := dynamicGameServerFixture()
 	m := agtesting.NewMocks()
-	pa := NewPortAllocator(10, 20, m.KubeInformerFactory, m.AgonesInformerFactory)
+	pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
 
 	m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
 		nl := &corev1.NodeList{Items: []corev1.Node{n1, n2}}
@@ -246,7 +390,7 @@ func TestPortAllocatorMultithreadAllocate(t *testing.T) {
 		go func(i int) {
 			for x := 0; x < 10; x++ {
 				logrus.WithField("x", x).WithField("i", i).Info("allocating!")
-				gs := pa.Allocate(fixture.DeepCopy())
+				gs, err := pa.Allocate(fixture.DeepCopy())
 				for _, p := range gs.Spec.Ports {
 					assert.NotEmpty(t, p.HostPort)
 				}
@@ -264,7 +408,7 @@ func TestPortAllocatorDeAllocate(t *testing.T) {
 
 	fixture := dynamicGameServerFixture()
 	m := agtesting.NewMocks()
-	pa := NewPortAllocator(10, 20, m.KubeInformerFactory, m.AgonesInformerFactory)
+	pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
 	nodes := []corev1.Node{n1, n2, n3}
 	m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
 		nl := &corev1.NodeList{Items: nodes}
@@ -279,7 +423,7 @@ func TestPortAllocatorDeAllocate(t *testing.T) {
 	assert.NotEmpty(t, fixture.Spec.Ports)
 
 	for i := 0; i <= 100; i++ {
-		gs := pa.Allocate(fixture.DeepCopy())
+		gs, err := pa.Allocate(fixture.DeepCopy())
 		assert.Nil(t, err)
 		port := gs.Spec.Ports[0]
 		assert.True(t, 10 <= port.HostPort && port.HostPort <= 20)
@@ -300,11 +444,69 @@ func TestPortAllocatorDeAllocate(t *testing.T) {
 	}
 }
 
+func TestPortAllocatorStickyPorts(t *testing.T) {
+	t.Parallel()
+
+	m := agtesting.NewMocks()
+	pa := NewPortAllocator(10, 20, nil, true, m.KubeInformerFactory, m.AgonesInformerFactory)
+	m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		nl := &corev1.NodeList{Items: []corev1.Node{n1}}
+		return true, nl, nil
+	})
+	_, cancel := agtesting.StartInformers(m, pa.nodeSynced)
+	defer cancel()
+	err := pa.syncAll()
+	assert.Nil(t, err)
+
+	fleetFixture := func(uid string) *v1alpha1.GameServer {
+		gs := dynamicGameServerFixture()
+		gs.ObjectMeta.UID = types.UID(uid)
+		gs.ObjectMeta.Labels = map[string]string{v1alpha1.FleetNameLabel: "fleet"}
+		gs.Spec.Ports = append(gs.Spec.Ports, v1alpha1.GameServerPort{PortPolicy: v1alpha1.Passthrough})
+		return gs
+	}
+
+	// wrap around the port range
+	gs1, err := pa.Allocate(fleetFixture("1"))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(10), gs1.Spec.Ports[0].HostPort)
+	assert.Equal(t, int32(11), gs1.Spec.Ports[1].HostPort)
+	assert.Equal(t, int32(11), gs1.Spec.Ports[1].ContainerPort)
+	gs2, err := pa.Allocate(fleetFixture("2"))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(12), gs2.Spec.Ports[0].HostPort)
+	assert.Equal(t, int32(13), gs2.Spec.Ports[1].HostPort)
+
+	// released ports are reused by the same fleet, by port ordinal
+	pa.DeAllocate(gs1)
+	assert.Equal(t, 0, countAllocatedPorts(pa, 10))
+	other := dynamicGameServerFixture()
+	other.ObjectMeta.UID = "other"
+	other, err = pa.Allocate(other)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(14), other.Spec.Ports[0].HostPort)
+
+	gs3, err := pa.Allocate(fleetFixture("3"))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(10), gs3.Spec.Ports[0].HostPort)
+	assert.Equal(t, int32(11), gs3.Spec.Ports[1].HostPort)
+	assert.Equal(t, int32(11), gs3.Spec.Ports[1].ContainerPort)
+	assert.Equal(t, 1, countAllocatedPorts(pa, 10))
+	assert.Equal(t, 1, countAllocatedPorts(pa, 11))
+
+	// no released ports left, so back to wrapping around
+	gs4, err := pa.Allocate(fleetFixture("4"))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(15), gs4.Spec.Ports[0].HostPort)
+	assert.Equal(t, int32(16), gs4.Spec.Ports[1].HostPort)
+	assert.Equal(t, 7, countTotalAllocatedPorts(pa))
+}
+
 func TestPortAllocatorSyncPortAllocations(t *testing.T) {
 	t.Parallel()
 
 	m := agtesting.NewMocks()
-	pa := NewPortAllocator(10, 20, m.KubeInformerFactory, m.AgonesInformerFactory)
+	pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
 
 	m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
 		nl := &corev1.NodeList{Items: []corev1.Node{n1, n2, n3}}
@@ -367,6 +569,276 @@ func TestPortAllocatorSyncPortAllocations(t *testing.T) {
 	assert.Equal(t, 5, count)
 }
 
+func TestPortAllocatorExternalHostPorts(t *testing.T) {
+	t.Parallel()
+
+	m := agtesting.NewMocks()
+	pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
+
+	daemon1 := hostPortPod("daemon1", n1.ObjectMeta.Name, 10, 5000)
+	daemon2 := hostPortPod("daemon2", n2.ObjectMeta.Name, 10)
+	unscheduled := hostPortPod("unscheduled", "", 11)
+	gameServer := hostPortPod("gameserver", n1.ObjectMeta.Name, 12)
+	gameServer.ObjectMeta.Labels = map[string]string{v1alpha1.RoleLabel: v1alpha1.GameServerLabelRole}
+	completed := hostPortPod("completed", n1.ObjectMeta.Name, 13)
+	completed.Status.Phase = corev1.PodSucceeded
+
+	m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.NodeList{Items: []corev1.Node{n1, n2}}, nil
+	})
+	m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.PodList{Items: []corev1.Pod{daemon1, daemon2, unscheduled, gameServer, completed}}, nil
+	})
+
+	_, cancel := agtesting.StartInformers(m, pa.nodeSynced, pa.podSynced)
+	defer cancel()
+
+	err := pa.syncAll()
+	assert.Nil(t, err)
+
+	assert.Len(t, pa.portAllocations, 2)
+	assert.Len(t, pa.podRegistry, 3)
+	assert.Equal(t, 2, countAllocatedPorts(pa, 10))
+	assert.Equal(t, 1, countAllocatedPorts(pa, 11))
+	assert.Equal(t, 3, countTotalAllocatedPorts(pa))
+
+	// no ports taken by Pods are handed out
+	for i := 0; i < 8; i++ {
+		gs, err := pa.Allocate(dynamicGameServerFixture())
+		assert.Nil(t, err)
+		assert.NotEqual(t, int32(10), gs.Spec.Ports[0].HostPort)
+	}
+	assert.Len(t, pa.portAllocations, 2)
+}
+
+func TestPortAllocatorSyncPodPorts(t *testing.T) {
+	t.Parallel()
+
+	m := agtesting.NewMocks()
+	pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
+
+	m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.NodeList{Items: []corev1.Node{n1, n2}}, nil
+	})
+
+	_, cancel := agtesting.StartInformers(m, pa.nodeSynced, pa.podSynced)
+	defer cancel()
+
+	err := pa.syncAll()
+	assert.Nil(t, err)
+	n1Ports := pa.portAllocationOf(n1.ObjectMeta.Name)
+	n2Ports := pa.portAllocationOf(n2.ObjectMeta.Name)
+
+	// Pods take their ports on their node
+	daemon := hostPortPod("daemon", n2.ObjectMeta.Name, 14, 15)
+	pa.syncAddPod(&daemon)
+	assert.Len(t, pa.podRegistry, 1)
+	assert.True(t, n2Ports[14])
+	assert.True(t, n2Ports[15])
+	assert.Equal(t, 2, countTotalAllocatedPorts(pa))
+	pa.syncAddPod(&daemon)
+	assert.Equal(t, 2, countTotalAllocatedPorts(pa))
+
+	// Pods that are not scheduled yet take their ports on the first node with them open, until they are
+	unscheduled := hostPortPod("unscheduled", "", 14)
+	pa.syncAddPod(&unscheduled)
+	assert.True(t, n1Ports[14])
+	assert.Equal(t, 3, countTotalAllocatedPorts(pa))
+
+	scheduled := unscheduled.DeepCopy()
+	scheduled.Spec.NodeName = n1.ObjectMeta.Name
+	pa.syncUpdatePod(&unscheduled, scheduled)
+	assert.Equal(t, n1.ObjectMeta.Name, pa.podRegistry[scheduled.ObjectMeta.UID].node)
+	assert.True(t, n1Ports[14])
+	assert.Equal(t, 3, countTotalAllocatedPorts(pa))
+
+	// other changes to the Pod are ignored
+	labelled := scheduled.DeepCopy()
+	labelled.ObjectMeta.Labels = map[string]string{"app": "daemon"}
+	pa.syncUpdatePod(scheduled, labelled)
+	assert.Equal(t, 3, countTotalAllocatedPorts(pa))
+
+	// Pods that have completed release their ports
+	completed := labelled.DeepCopy()
+	completed.Status.Phase = corev1.PodSucceeded
+	pa.syncUpdatePod(labelled, completed)
+	assert.False(t, n1Ports[14])
+	assert.True(t, n2Ports[14])
+	assert.Len(t, pa.podRegistry, 1)
+	assert.Equal(t, 2, countTotalAllocatedPorts(pa))
+
+	// as do deleted Pods
+	pa.syncDeletePod(&daemon)
+	assert.Len(t, pa.podRegistry, 0)
+	assert.Equal(t, 0, countTotalAllocatedPorts(pa))
+
+	// GameServer Pods are left to the GameServer
+	gameServer := hostPortPod("gameserver", n1.ObjectMeta.Name, 12)
+	gameServer.ObjectMeta.Labels = map[string]string{v1alpha1.RoleLabel: v1alpha1.GameServerLabelRole}
+	pa.syncAddPod(&gameServer)
+	assert.Equal(t, 0, countTotalAllocatedPorts(pa))
+	pa.syncDeletePod(&gameServer)
+	assert.Equal(t, 0, countTotalAllocatedPorts(pa))
+}
+
+func TestPortAllocatorSyncNodes(t *testing.T) {
+	t.Parallel()
+
+	m := agtesting.NewMocks()
+	pa := NewPortAllocator(10, 11, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
+
+	m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.NodeList{Items: []corev1.Node{n1}}, nil
+	})
+
+	_, cancel := agtesting.StartInformers(m, pa.nodeSynced)
+	defer cancel()
+
+	err := pa.syncAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{n1.ObjectMeta.Name}, pa.nodeNames)
+
+	// run out of ports on node1, so port allocations are added for a node that doesn't exist yet
+	for i := 0; i < 3; i++ {
+		_, err = pa.Allocate(dynamicGameServerFixture())
+		assert.Nil(t, err)
+	}
+	assert.Len(t, pa.portAllocations, 2)
+	assert.Equal(t, []string{n1.ObjectMeta.Name, ""}, pa.nodeNames)
+
+	// the new node takes over the port allocations that were waiting for it
+	pa.syncAddNode(n2.DeepCopy())
+	assert.Len(t, pa.portAllocations, 2)
+	assert.Equal(t, []string{n1.ObjectMeta.Name, n2.ObjectMeta.Name}, pa.nodeNames)
+	assert.Equal(t, 3, countTotalAllocatedPorts(pa))
+
+	// already known nodes are ignored
+	pa.syncAddNode(n2.DeepCopy())
+	assert.Len(t, pa.portAllocations, 2)
+
+	pa.syncAddNode(n3.DeepCopy())
+	assert.Len(t, pa.portAllocations, 3)
+	assert.Equal(t, []string{n1.ObjectMeta.Name, n2.ObjectMeta.Name, n3.ObjectMeta.Name}, pa.nodeNames)
+
+	// cordoned nodes keep their port allocations, but are not allocated from
+	cordoned := n1.DeepCopy()
+	cordoned.Spec.Unschedulable = true
+	pa.syncUpdateNode(n1.DeepCopy(), cordoned)
+	assert.Equal(t, []string{n1.ObjectMeta.Name, n2.ObjectMeta.Name, n3.ObjectMeta.Name}, pa.nodeNames)
+	assert.Equal(t, map[string]bool{n1.ObjectMeta.Name: true}, pa.cordonedNodes)
+	assert.Equal(t, 3, countTotalAllocatedPorts(pa))
+	n1Ports := pa.portAllocationOf(n1.ObjectMeta.Name)
+	n1Ports[10] = false
+	_, err = pa.Allocate(dynamicGameServerFixture())
+	assert.Nil(t, err)
+	assert.False(t, n1Ports[10])
+	n1Ports[10] = true
+
+	pa.syncUpdateNode(cordoned, n1.DeepCopy())
+	assert.Equal(t, []string{n1.ObjectMeta.Name, n2.ObjectMeta.Name, n3.ObjectMeta.Name}, pa.nodeNames)
+	assert.Empty(t, pa.cordonedNodes)
+	assert.Equal(t, 4, countTotalAllocatedPorts(pa))
+
+	// unschedulable nodes are added, but don't take over the port allocations waiting for a node
+	unschedulable := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node4", UID: "node4"}, Spec: corev1.NodeSpec{Unschedulable: true}}
+	pa.syncAddNode(&unschedulable)
+	assert.Len(t, pa.portAllocations, 4)
+	assert.True(t, pa.cordonedNodes["node4"])
+
+	pa.syncDeleteNode(n3.DeepCopy())
+	assert.Equal(t, []string{n1.ObjectMeta.Name, n2.ObjectMeta.Name, "node4"}, pa.nodeNames)
+	assert.Len(t, pa.portAllocations, 3)
+
+	pa.syncDeleteNode(n3.DeepCopy())
+	assert.Len(t, pa.portAllocations, 3)
+
+	pa.syncDeleteNode(&unschedulable)
+	assert.Empty(t, pa.cordonedNodes)
+}
+
+func TestPortAllocatorSeedNode(t *testing.T) {
+	t.Parallel()
+
+	m := agtesting.NewMocks()
+	pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
+
+	gs := v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "gs1", UID: "1"},
+		Spec:   v1alpha1.GameServerSpec{Ports: []v1alpha1.GameServerPort{{PortPolicy: v1alpha1.Dynamic, HostPort: 10}}},
+		Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReady, NodeName: n2.ObjectMeta.Name}}
+	daemon := hostPortPod("daemon", n2.ObjectMeta.Name, 11)
+
+	m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.NodeList{Items: []corev1.Node{n1, n2}}, nil
+	})
+	m.KubeClient.AddReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.PodList{Items: []corev1.Pod{daemon}}, nil
+	})
+	m.AgonesClient.AddReactor("list", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &v1alpha1.GameServerList{Items: []v1alpha1.GameServer{gs}}, nil
+	})
+
+	_, cancel := agtesting.StartInformers(m, pa.gameServerSynced, pa.nodeSynced, pa.podSynced)
+	defer cancel()
+
+	err := pa.syncAll()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countTotalAllocatedPorts(pa))
+
+	// a node that is removed and added back still has the ports of the GameServers and Pods on it taken
+	pa.syncDeleteNode(n2.DeepCopy())
+	assert.Equal(t, 0, countTotalAllocatedPorts(pa))
+	pa.syncAddNode(n2.DeepCopy())
+	n2Ports := pa.portAllocationOf(n2.ObjectMeta.Name)
+	assert.True(t, n2Ports[10])
+	assert.True(t, n2Ports[11])
+	assert.Equal(t, 2, countTotalAllocatedPorts(pa))
+
+	// and they are released from it
+	pa.DeAllocate(&gs)
+	pa.syncDeletePod(&daemon)
+	assert.Equal(t, 0, countTotalAllocatedPorts(pa))
+}
+
+func TestPortAllocatorDeAllocateOnNode(t *testing.T) {
+	t.Parallel()
+
+	m := agtesting.NewMocks()
+	pa := NewPortAllocator(10, 10, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
+	m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.NodeList{Items: []corev1.Node{n1, n2}}, nil
+	})
+	_, cancel := agtesting.StartInformers(m, pa.nodeSynced)
+	defer cancel()
+	err := pa.syncAll()
+	assert.Nil(t, err)
+
+	gs1, err := pa.Allocate(dynamicGameServerFixture())
+	assert.Nil(t, err)
+	gs2 := dynamicGameServerFixture()
+	gs2.ObjectMeta.UID = "5678"
+	gs2, err = pa.Allocate(gs2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countAllocatedPorts(pa, 10))
+
+	// both GameServers are scheduled on the other node than their ports were taken on, so their ports move
+	first := pa.nodeNames[0]
+	second := pa.nodeNames[1]
+	scheduled1 := gs1.DeepCopy()
+	scheduled1.Status.NodeName = second
+	pa.syncUpdateGameServer(gs1, scheduled1)
+	scheduled2 := gs2.DeepCopy()
+	scheduled2.Status.NodeName = first
+	pa.syncUpdateGameServer(gs2, scheduled2)
+	assert.Equal(t, 2, countAllocatedPorts(pa, 10))
+
+	// the port is only released on the GameServer's node
+	pa.DeAllocate(scheduled1)
+	assert.False(t, pa.portAllocationOf(second)[10])
+	assert.True(t, pa.portAllocationOf(first)[10])
+	pa.DeAllocate(scheduled2)
+	assert.Equal(t, 0, countAllocatedPorts(pa, 10))
+}
+
 func TestPortAllocatorSyncDeleteGameServer(t *testing.T) {
 	t.Parallel()
 
@@ -395,7 +867,7 @@ func TestPortAllocatorSyncDeleteGameServer(t *testing.T) {
 		},
 		Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReady, Ports: []v1alpha1.GameServerStatusPort{{Port: 10}}, NodeName: n2.ObjectMeta.Name}}
 
-	pa := NewPortAllocator(10, 20, m.KubeInformerFactory, m.AgonesInformerFactory)
+	pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
 
 	m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
 		nl := &corev1.NodeList{Items: []corev1.Node{n1, n2, n3}}
@@ -443,7 +915,7 @@ func TestNodePortAllocation(t *testing.T) {
 	t.Parallel()
 
 	m := agtesting.NewMocks()
-	pa := NewPortAllocator(10, 20, m.KubeInformerFactory, m.AgonesInformerFactory)
+	pa := NewPortAllocator(10, 20, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
 	nodes := []corev1.Node{n1, n2, n3}
 	m.KubeClient.AddReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
 		nl := &corev1.NodeList{Items: nodes}
@@ -461,26 +933,10 @@ func TestNodePortAllocation(t *testing.T) {
 	}
 }
 
-func TestTakePortAllocation(t *testing.T) {
-	t.Parallel()
-
-	fixture := []portAllocation{{1: false, 2: false}, {1: false, 2: false}, {1: false, 3: false}}
-	result := setPortAllocation(2, fixture, true)
-	assert.True(t, result[0][2])
-
-	for i, row := range fixture {
-		for p, taken := range row {
-			if i != 0 && p != 2 {
-				assert.False(t, taken, fmt.Sprintf("row %d and port %d should be false", i, p))
-			}
-		}
-	}
-}
-
 func TestPortAllocatorRegisterExistingGameServerPorts(t *testing.T) {
 	t.Parallel()
 	m := agtesting.NewMocks()
-	pa := NewPortAllocator(10, 13, m.KubeInformerFactory, m.AgonesInformerFactory)
+	pa := NewPortAllocator(10, 13, nil, false, m.KubeInformerFactory, m.AgonesInformerFactory)
 
 	gs1 := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "gs1", UID: "1"},
 		Spec: v1alpha1.GameServerSpec{
@@ -506,9 +962,13 @@ func TestPortAllocatorRegisterExistingGameServerPorts(t *testing.T) {
 		},
 		Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStatePortAllocation, Ports: []v1alpha1.GameServerStatusPort{{Port: 13}}}}
 
-	allocations, nonReadyNodesPorts := pa.registerExistingGameServerPorts([]*v1alpha1.GameServer{gs1, gs2, gs3, gs4}, []*corev1.Node{&n1, &n2, &n3}, map[types.UID]bool{})
+	gsRegistry := map[types.UID]portAllocation{}
+	allocations, nodeNames, nonReadyGameServers := pa.registerExistingGameServerPorts([]*v1alpha1.GameServer{gs1, gs2, gs3, gs4}, nil, []*corev1.Node{&n1, &n2, &n3}, gsRegistry, map[types.UID]podPorts{})
 
-	assert.Equal(t, []int32{13}, nonReadyNodesPorts)
+	assert.Equal(t, []*v1alpha1.GameServer{gs4}, nonReadyGameServers)
+	assert.Equal(t, allocations[0], gsRegistry[gs1.ObjectMeta.UID])
+	assert.Equal(t, allocations[1], gsRegistry[gs2.ObjectMeta.UID])
+	assert.Equal(t, []string{n1.ObjectMeta.Name, n2.ObjectMeta.Name, n3.ObjectMeta.Name}, nodeNames)
 	assert.Equal(t, portAllocation{10: true, 11: false, 12: true, 13: false}, allocations[0])
 	assert.Equal(t, portAllocation{10: false, 11: true, 12: false, 13: false}, allocations[1])
 	assert.Equal(t, portAllocation{10: false, 11: false, 12: false, 13: false}, allocations[2])
@@ -534,6 +994,17 @@ func countAllocatedPorts(pa *PortAllocator, p int32) int {
 	return count
 }
 
+// hostPortPod returns a Pod on the named node, with a HostPort for each of ports
+func hostPortPod(name, node string, ports ...int32) corev1.Pod {
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name)}, Spec: corev1.PodSpec{NodeName: node}}
+	c := corev1.Container{Name: "container"}
+	for _, p := range ports {
+		c.Ports = append(c.Ports, corev1.ContainerPort{ContainerPort: p, HostPort: p})
+	}
+	pod.Spec.Containers = append(pod.Spec.Containers, c)
+	return pod
+}
+
 // countTotalAllocatedPorts counts the total number of allocated ports
 func countTotalAllocatedPorts(pa *PortAllocator) int {
 	count := 0
@@ -546,3 +1017,14 @@ func countTotalAllocatedPorts(pa *PortAllocator) int {
 	}
 	return count
 }
+
+// countAllocatedPortsOnNode counts the number of allocated ports on the node at index i
+func countAllocatedPortsOnNode(pa *PortAllocator, i int) int {
+	count := 0
+	for _, alloc := range pa.portAllocations[i] {
+		if alloc {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"path"
+	"time"
+
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// SidecarTokenPath is the path of the projected token in the containers of the Pod, in place of
+	// the token of the service account Secret
+	SidecarTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	// SidecarTokenFileEnv is the environment variable of the SDK sidecar with the path of
+	// the token it authenticates with, if it has a projected token
+	SidecarTokenFileEnv = "SDK_TOKEN_FILE"
+	// MinSidecarTokenExpiration is the shortest a projected token can be valid for
+	MinSidecarTokenExpiration = 10 * time.Minute
+	// SidecarTokenCAConfigMap is the ConfigMap in the namespace of the GameServer that the cluster's CA
+	// certificate is projected from, next to the token, as the Secret of the service account is not mounted
+	SidecarTokenCAConfigMap = "kube-root-ca.crt"
+
+	sidecarTokenVolume = "agones-sidecar-token"
+)
+
+// SidecarToken configures a projected service account token, with a short expiration, that the SDK sidecar
+// authenticates with the Kubernetes API server with, rather than the long lived token of the Pod's
+// service account Secret, which is then not mounted into the Pod at all
+type SidecarToken struct {
+	// Audience is the intended audience of the token, which the API server must accept.
+	// Defaults to the API server's own.
+	Audience string
+	// Expiration is how long the token is valid for. The kubelet refreshes it before then. 0 disables this.
+	Expiration time.Duration
+}
+
+// enabled returns if the SDK sidecar authenticates with a projected token
+func (t SidecarToken) enabled() bool {
+	return t.Expiration > 0
+}
+
+// applyToSidecar points the SDK sidecar container at the projected token, so that it reads it again as
+// the kubelet refreshes it
+func (t SidecarToken) applyToSidecar(sidecar *corev1.Container) {
+	if !t.enabled() {
+		return
+	}
+	sidecar.Env = append(sidecar.Env, corev1.EnvVar{Name: SidecarTokenFileEnv, Value: SidecarTokenPath})
+}
+
+// applyToPod stops the token of the service account Secret from being mounted into the Pod, and mounts the
+// projected token in its place in every container but the game server container, which is left to
+// applyToGameServerContainer. Containers that already mount something in the place of the token are left as is.
+func (t SidecarToken) applyToPod(gs *v1alpha1.GameServer, pod *corev1.Pod) {
+	if !t.enabled() {
+		return
+	}
+	automount := false
+	pod.Spec.AutomountServiceAccountToken = &automount
+
+	expiration := int64(t.Expiration / time.Second)
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name: sidecarTokenVolume,
+		VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+			Sources: []corev1.VolumeProjection{
+				{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+					Audience:          t.Audience,
+					ExpirationSeconds: &expiration,
+					Path:              path.Base(SidecarTokenPath),
+				}},
+				{ConfigMap: &corev1.ConfigMapProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: SidecarTokenCAConfigMap},
+					Items:                []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+				}},
+				{DownwardAPI: &corev1.DownwardAPIProjection{
+					Items: []corev1.DownwardAPIVolumeFile{{Path: "namespace",
+						FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "metadata.namespace"}}},
+				}},
+			},
+		}},
+	})
+
+	for i := range pod.Spec.InitContainers {
+		mountSidecarToken(&pod.Spec.InitContainers[i])
+	}
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name != gs.Spec.Container {
+			mountSidecarToken(&pod.Spec.Containers[i])
+		}
+	}
+}
+
+// applyToGameServerContainer mounts the projected token in the game server container, for when it
+// would otherwise have been given the token of the service account Secret
+func (t SidecarToken) applyToGameServerContainer(gs *v1alpha1.GameServer, pod *corev1.Pod) {
+	if !t.enabled() {
+		return
+	}
+	gs.ApplyToPodGameServerContainer(pod, func(c corev1.Container) corev1.Container {
+		mountSidecarToken(&c)
+		return c
+	})
+}
+
+// mountSidecarToken mounts the projected token volume in place of the token of the service account Secret,
+// unless the container already mounts something there
+func mountSidecarToken(c *corev1.Container) {
+	dir := path.Dir(SidecarTokenPath)
+	for _, vm := range c.VolumeMounts {
+		if vm.MountPath == dir {
+			return
+		}
+	}
+	c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: sidecarTokenVolume, MountPath: dir, ReadOnly: true})
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameservers
+
+import (
+	"testing"
+	"time"
+
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestSidecarToken(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disabled", func(t *testing.T) {
+		sidecar := corev1.Container{Name: "sidecar"}
+		gs := &v1alpha1.GameServer{Spec: newSingleContainerSpec()}
+		pod := &corev1.Pod{}
+		SidecarToken{}.applyToSidecar(&sidecar)
+		SidecarToken{}.applyToPod(gs, pod)
+		SidecarToken{}.applyToGameServerContainer(gs, pod)
+		assert.Empty(t, sidecar.VolumeMounts)
+		assert.Empty(t, sidecar.Env)
+		assert.Empty(t, pod.Spec.Volumes)
+		assert.Nil(t, pod.Spec.AutomountServiceAccountToken)
+	})
+
+	tokenMount := corev1.VolumeMount{Name: sidecarTokenVolume, MountPath: "/var/run/secrets/kubernetes.io/serviceaccount", ReadOnly: true}
+
+	fixtures := map[string]struct {
+		sdkToken        v1alpha1.SDKTokenMount
+		gameServerToken bool
+	}{
+		"enabled, game server token disabled": {sdkToken: v1alpha1.SDKTokenAuto, gameServerToken: false},
+		"enabled, game server token enabled":  {sdkToken: v1alpha1.SDKTokenEnabled, gameServerToken: true},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			c, m := newFakeController()
+			c.sidecarToken = SidecarToken{Audience: "agones", Expiration: 15 * time.Minute}
+			fixture := &v1alpha1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: newSingleContainerSpec(), Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateCreating}}
+			fixture.Spec.SDKToken = &v1alpha1.SDKToken{Mount: v.sdkToken}
+			fixture.ApplyDefaults()
+
+			created := false
+			m.KubeClient.AddReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				created = true
+				pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+
+				// the token of the service account Secret is replaced by the projected token
+				if assert.NotNil(t, pod.Spec.AutomountServiceAccountToken) {
+					assert.False(t, *pod.Spec.AutomountServiceAccountToken)
+				}
+				var volume *corev1.Volume
+				for i := range pod.Spec.Volumes {
+					if pod.Spec.Volumes[i].Name == sidecarTokenVolume {
+						volume = &pod.Spec.Volumes[i]
+					}
+				}
+				if assert.NotNil(t, volume) && assert.NotNil(t, volume.Projected) && assert.Len(t, volume.Projected.Sources, 3) {
+					token := volume.Projected.Sources[0].ServiceAccountToken
+					assert.Equal(t, "agones", token.Audience)
+					assert.Equal(t, int64(900), *token.ExpirationSeconds)
+					assert.Equal(t, "token", token.Path)
+					assert.Equal(t, SidecarTokenCAConfigMap, volume.Projected.Sources[1].ConfigMap.Name)
+					assert.Equal(t, "metadata.namespace", volume.Projected.Sources[2].DownwardAPI.Items[0].FieldRef.FieldPath)
+				}
+
+				sidecar := pod.Spec.Containers[1]
+				assert.Equal(t, "agones-gameserver-sidecar", sidecar.Name)
+				assert.Contains(t, sidecar.VolumeMounts, tokenMount)
+				assert.Contains(t, sidecar.Env, corev1.EnvVar{Name: SidecarTokenFileEnv, Value: SidecarTokenPath})
+
+				gameServer := pod.Spec.Containers[0]
+				if v.gameServerToken {
+					assert.Contains(t, gameServer.VolumeMounts, tokenMount)
+				} else {
+					assert.NotContains(t, gameServer.VolumeMounts, tokenMount)
+					assert.Len(t, gameServer.VolumeMounts, 1, "the game server should only mount the empty service account directory")
+				}
+
+				return true, pod, nil
+			})
+
+			_, err := c.createGameServerPod(fixture)
+			assert.Nil(t, err)
+			assert.True(t, created)
+		})
+	}
+}
@@ -16,7 +16,10 @@ package gameserversets
 
 import (
 	"encoding/json"
+	"reflect"
+	"sort"
 	"sync"
+	"time"
 
 	"agones.dev/agones/pkg/apis"
 	"agones.dev/agones/pkg/apis/stable"
@@ -32,8 +35,10 @@ import (
 	"agones.dev/agones/pkg/util/webhooks"
 	"agones.dev/agones/pkg/util/workerqueue"
 	"github.com/heptiolabs/healthcheck"
+	"github.com/mattbaird/jsonpatch"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 	admv1beta1 "k8s.io/api/admission/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	extclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
@@ -60,6 +65,8 @@ const (
 	maxDeletionParallelism         = 64
 	maxGameServerDeletionsPerBatch = 64
 
+	maxUpdateParallelism = 16
+
 	// maxPodPendingCount is the maximum number of pending pods per game server set
 	maxPodPendingCount = 5000
 )
@@ -69,6 +76,7 @@ type Controller struct {
 	baseLogger          *logrus.Entry
 	counter             *gameservers.PerNodeCounter
 	crdGetter           v1beta1.CustomResourceDefinitionInterface
+	crdWaitTimeout      time.Duration
 	gameServerGetter    getterv1alpha1.GameServersGetter
 	gameServerLister    listerv1alpha1.GameServerLister
 	gameServerSynced    cache.InformerSynced
@@ -79,13 +87,20 @@ type Controller struct {
 	stop                <-chan struct{}
 	recorder            record.EventRecorder
 	stateCache          *gameServerStateCache
+	unhealthyRetention  time.Duration
+	creationLimits      CreationLimits
+	// creationLimiter limits the rate GameServers are created across all GameServerSets, if set
+	creationLimiter *rate.Limiter
 }
 
 // NewController returns a new gameserverset crd controller
 func NewController(
 	wh *webhooks.WebHook,
 	health healthcheck.Handler,
+	crdWaitTimeout time.Duration,
 	counter *gameservers.PerNodeCounter,
+	unhealthyRetention time.Duration,
+	creationLimits CreationLimits,
 	kubeClient kubernetes.Interface,
 	extClient extclientset.Interface,
 	agonesClient versioned.Interface,
@@ -98,6 +113,7 @@ func NewController(
 
 	c := &Controller{
 		crdGetter:           extClient.ApiextensionsV1beta1().CustomResourceDefinitions(),
+		crdWaitTimeout:      crdWaitTimeout,
 		counter:             counter,
 		gameServerGetter:    agonesClient.StableV1alpha1(),
 		gameServerLister:    gameServers.Lister(),
@@ -106,6 +122,9 @@ func NewController(
 		gameServerSetLister: gameServerSets.Lister(),
 		gameServerSetSynced: gsSetInformer.HasSynced,
 		stateCache:          &gameServerStateCache{},
+		unhealthyRetention:  unhealthyRetention,
+		creationLimits:      creationLimits,
+		creationLimiter:     creationLimits.limiter(),
 	}
 
 	c.baseLogger = runtime.NewLoggerWithType(c)
@@ -117,6 +136,7 @@ func NewController(
 	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
 	c.recorder = eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "gameserverset-controller"})
 
+	wh.AddHandler("/mutate", v1alpha1.Kind("GameServerSet"), admv1beta1.Create, c.creationMutationHandler)
 	wh.AddHandler("/validate", v1alpha1.Kind("GameServerSet"), admv1beta1.Create, c.creationValidationHandler)
 	wh.AddHandler("/validate", v1alpha1.Kind("GameServerSet"), admv1beta1.Update, c.updateValidationHandler)
 
@@ -154,7 +174,7 @@ func NewController(
 func (c *Controller) Run(workers int, stop <-chan struct{}) error {
 	c.stop = stop
 
-	err := crd.WaitForEstablishedCRD(c.crdGetter, "gameserversets."+stable.GroupName, c.baseLogger)
+	err := crd.WaitForEstablishedCRD(c.crdGetter, "gameserversets."+stable.GroupName, c.crdWaitTimeout, c.baseLogger)
 	if err != nil {
 		return err
 	}
@@ -168,6 +188,46 @@ func (c *Controller) Run(workers int, stop <-chan struct{}) error {
 	return nil
 }
 
+// creationMutationHandler is the handler for the mutating webhook that sets the
+// the default values on the GameServerSet, including those created directly rather than by a Fleet
+// Should only be called on gameserverset create operations.
+// nolint:dupl
+func (c *Controller) creationMutationHandler(review admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error) {
+	c.baseLogger.WithField("review", review).Info("creationMutationHandler")
+
+	obj := review.Request.Object
+	gsSet := &v1alpha1.GameServerSet{}
+	err := json.Unmarshal(obj.Raw, gsSet)
+	if err != nil {
+		return review, errors.Wrapf(err, "error unmarshalling original GameServerSet json: %s", obj.Raw)
+	}
+
+	gsSet.ApplyDefaults()
+
+	newGsSet, err := json.Marshal(gsSet)
+	if err != nil {
+		return review, errors.Wrapf(err, "error marshalling default applied GameServerSet %s to json", gsSet.ObjectMeta.Name)
+	}
+
+	patch, err := jsonpatch.CreatePatch(obj.Raw, newGsSet)
+	if err != nil {
+		return review, errors.Wrapf(err, "error creating patch for GameServerSet %s", gsSet.ObjectMeta.Name)
+	}
+
+	jsn, err := json.Marshal(patch)
+	if err != nil {
+		return review, errors.Wrapf(err, "error creating json for patch for GameServerSet %s", gsSet.ObjectMeta.Name)
+	}
+
+	c.loggerForGameServerSet(gsSet).WithField("patch", string(jsn)).Infof("patch created!")
+
+	pt := admv1beta1.PatchTypeJSONPatch
+	review.Response.PatchType = &pt
+	review.Response.Patch = jsn
+
+	return review, nil
+}
+
 // updateValidationHandler that validates a GameServerSet when is updated
 // Should only be called on gameserverset update operations.
 func (c *Controller) updateValidationHandler(review admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error) {
@@ -187,6 +247,14 @@ func (c *Controller) updateValidationHandler(review admv1beta1.AdmissionReview)
 	}
 
 	causes, ok := oldGss.ValidateUpdate(newGss)
+	unknown, err := crd.UnknownFieldCauses(reflect.TypeOf(v1alpha1.GameServerSet{}), newObj.Raw)
+	if err != nil {
+		return review, errors.Wrapf(err, "error checking GameServerSet json for unknown fields: %s", newObj.Raw)
+	}
+	if len(unknown) > 0 {
+		ok = false
+		causes = append(causes, unknown...)
+	}
 	if !ok {
 		review.Response.Allowed = false
 		details := metav1.StatusDetails{
@@ -222,6 +290,14 @@ func (c *Controller) creationValidationHandler(review admv1beta1.AdmissionReview
 	}
 
 	causes, ok := newGss.Validate()
+	unknown, err := crd.UnknownFieldCauses(reflect.TypeOf(v1alpha1.GameServerSet{}), newObj.Raw)
+	if err != nil {
+		return review, errors.Wrapf(err, "error checking GameServerSet json for unknown fields: %s", newObj.Raw)
+	}
+	if len(unknown) > 0 {
+		ok = false
+		causes = append(causes, unknown...)
+	}
 	if !ok {
 		review.Response.Allowed = false
 		details := metav1.StatusDetails{
@@ -308,9 +384,28 @@ func (c *Controller) syncGameServerSet(key string) error {
 	}
 
 	list = c.stateCache.forGameServerSet(gsSet).reconcileWithUpdatedServerList(list)
+	list = c.retainUnhealthyGameServers(gsSet, list)
+	if gsSet.Spec.AllocationOverflow != nil {
+		c.applyAllocationOverflow(gsSet, list)
+	}
+
+	// the counts of the GameServers of the set's Fleet, so a Packed scale down empties the nodes it can
+	var fleetCounts map[string]gameservers.NodeCount
+	if fleetName, ok := gsSet.ObjectMeta.Labels[v1alpha1.FleetNameLabel]; ok {
+		fleetCounts = c.counter.FleetCounts(gsSet.ObjectMeta.Namespace, fleetName)
+	}
 
-	numServersToAdd, toDelete, isPartial := computeReconciliationAction(gsSet.Spec.Scheduling, list, c.counter.Counts(),
-		int(gsSet.Spec.Replicas), maxGameServerCreationsPerBatch, maxGameServerDeletionsPerBatch, maxPodPendingCount)
+	numServersToAdd, toDelete, isPartial := computeReconciliationAction(gsSet.Spec.Scheduling, gsSet.Spec.ScaleDownPreference, list, c.counter.Counts(),
+		fleetCounts, int(gsSet.Spec.Replicas), c.creationLimits.burst(), maxGameServerDeletionsPerBatch, c.creationLimits.maxPending())
+	// creationDelay is how long until more GameServers can be created within the creation rate limit
+	var creationDelay time.Duration
+	if numServersToAdd > 0 {
+		var allowed int
+		if allowed, creationDelay = c.reserveCreations(numServersToAdd); allowed < numServersToAdd {
+			numServersToAdd = allowed
+			isPartial = true
+		}
+	}
 	status := computeStatus(list)
 	fields := logrus.Fields{}
 
@@ -331,20 +426,28 @@ func (c *Controller) syncGameServerSet(key string) error {
 		WithField("numServersToAdd", numServersToAdd).
 		WithField("numServersToDelete", len(toDelete)).
 		WithField("isPartial", isPartial).
+		WithField("creationDelay", creationDelay).
 		WithField("status", status).
 		WithFields(fields).
 		Info("Reconciling GameServerSet")
-	if isPartial {
+	if creationDelay > 0 {
+		// the creation rate limit has been reached, so follow up once more GameServers can be created
+		defer c.workerqueue.EnqueueAfter(gsSet, creationDelay)
+	} else if isPartial {
 		// we've determined that there's work to do, but we've decided not to do all the work in one shot
 		// make sure we get a follow-up, by re-scheduling this GSS in the worker queue immediately before this
 		// function returns
 		defer c.workerqueue.EnqueueImmediately(gsSet)
 	}
 
+	// creation failures are kept until a GameServer is created, or none are left to create
+	failures := gsSet.Status.CreationFailures
 	if numServersToAdd > 0 {
-		if err := c.addMoreGameServers(gsSet, numServersToAdd); err != nil {
+		if failures, err = c.addMoreGameServers(gsSet, numServersToAdd); err != nil {
 			c.loggerForGameServerSet(gsSet).WithError(err).Warning("error adding game servers")
 		}
+	} else if creationDelay == 0 && !isPartial {
+		failures = nil
 	}
 
 	if len(toDelete) > 0 {
@@ -353,13 +456,104 @@ func (c *Controller) syncGameServerSet(key string) error {
 		}
 	}
 
-	return c.syncGameServerSetStatus(gsSet, list)
+	return c.syncGameServerSetStatus(gsSet, list, failures)
+}
+
+// retainUnhealthyGameServers returns the list without the Unhealthy GameServers that are still within their
+// retention period, so they are neither deleted nor counted, and can be debugged while they are replaced.
+// Retained GameServers are annotated with when they were first seen Unhealthy, and the GameServerSet is
+// synced again when the earliest of their retention periods ends.
+func (c *Controller) retainUnhealthyGameServers(gsSet *v1alpha1.GameServerSet, list []*v1alpha1.GameServer) []*v1alpha1.GameServer {
+	var result []*v1alpha1.GameServer
+	var next time.Duration
+	now := time.Now().UTC()
+	for _, gs := range list {
+		retention := c.unhealthyRetentionOf(gs)
+		if gs.Status.State != v1alpha1.GameServerStateUnhealthy || gs.IsBeingDeleted() || retention <= 0 {
+			result = append(result, gs)
+			continue
+		}
+
+		unhealthyTime := now
+		if v, ok := gs.ObjectMeta.Annotations[v1alpha1.UnhealthyTimeAnnotation]; ok {
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				unhealthyTime = t
+			}
+		} else {
+			gsCopy := gs.DeepCopy()
+			if gsCopy.ObjectMeta.Annotations == nil {
+				gsCopy.ObjectMeta.Annotations = map[string]string{}
+			}
+			gsCopy.ObjectMeta.Annotations[v1alpha1.UnhealthyTimeAnnotation] = now.Format(time.RFC3339)
+			if _, err := c.gameServerGetter.GameServers(gs.ObjectMeta.Namespace).Update(gsCopy); err != nil {
+				// retried on the next sync, which the failed update is likely to be caused by anyway
+				c.loggerForGameServerSet(gsSet).WithError(err).WithField("gs", gs.ObjectMeta.Name).
+					Warning("error annotating retained Unhealthy GameServer")
+			} else {
+				c.recorder.Eventf(gsSet, corev1.EventTypeNormal, "RetainingGameServer",
+					"Retaining Unhealthy GameServer %s for %s before deleting it", gs.ObjectMeta.Name, retention)
+			}
+		}
+
+		remaining := unhealthyTime.Add(retention).Sub(now)
+		if remaining <= 0 {
+			result = append(result, gs)
+			continue
+		}
+		if next == 0 || remaining < next {
+			next = remaining
+		}
+	}
+
+	if next > 0 {
+		c.workerqueue.EnqueueAfter(gsSet, next)
+	}
+	return result
+}
+
+// applyAllocationOverflow sets the labels and annotations of the allocation overflow of the GameServerSet
+// on those of its Allocated GameServers that don't have them yet. GameServers that fail to update, e.g.
+// because they changed at the same time, are updated on the next sync, which their change causes anyway.
+func (c *Controller) applyAllocationOverflow(gsSet *v1alpha1.GameServerSet, list []*v1alpha1.GameServer) {
+	var toUpdate []*v1alpha1.GameServer
+	for _, gs := range list {
+		if gs.Status.State == v1alpha1.GameServerStateAllocated && !gs.IsBeingDeleted() && !gsSet.Spec.AllocationOverflow.Applied(gs) {
+			toUpdate = append(toUpdate, gs)
+		}
+	}
+	if len(toUpdate) == 0 {
+		return
+	}
+
+	c.loggerForGameServerSet(gsSet).WithField("count", len(toUpdate)).Info("Applying allocation overflow to gameservers")
+	err := parallelize(gameServerListToChannel(toUpdate), maxUpdateParallelism, func(gs *v1alpha1.GameServer) error {
+		gsCopy := gs.DeepCopy()
+		gsSet.Spec.AllocationOverflow.Apply(gsCopy)
+		if _, err := c.gameServerGetter.GameServers(gs.ObjectMeta.Namespace).Update(gsCopy); err != nil {
+			return errors.Wrapf(err, "error applying allocation overflow to gameserver %s", gs.ObjectMeta.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		c.loggerForGameServerSet(gsSet).WithError(err).Warning("error applying allocation overflow")
+	}
+}
+
+// unhealthyRetentionOf returns how long the GameServer is retained for once it is Unhealthy,
+// which its UnhealthyRetentionAnnotation can override
+func (c *Controller) unhealthyRetentionOf(gs *v1alpha1.GameServer) time.Duration {
+	if v, ok := gs.ObjectMeta.Annotations[v1alpha1.UnhealthyRetentionAnnotation]; ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return c.unhealthyRetention
 }
 
 // computeReconciliationAction computes the action to take to reconcile a game server set set given
 // the list of game servers that were found and target replica count.
-func computeReconciliationAction(strategy apis.SchedulingStrategy, list []*v1alpha1.GameServer,
-	counts map[string]gameservers.NodeCount, targetReplicaCount int, maxCreations int, maxDeletions int,
+func computeReconciliationAction(strategy apis.SchedulingStrategy, preference v1alpha1.ScaleDownPreference, list []*v1alpha1.GameServer,
+	counts, fleetCounts map[string]gameservers.NodeCount, targetReplicaCount int, maxCreations int, maxDeletions int,
 	maxPending int) (int, []*v1alpha1.GameServer, bool) {
 	var upCount int     // up == Ready or will become ready
 	var deleteCount int // number of gameservers to delete
@@ -460,11 +654,7 @@ func computeReconciliationAction(strategy apis.SchedulingStrategy, list []*v1alp
 	}
 
 	if deleteCount > 0 {
-		if strategy == apis.Packed {
-			potentialDeletions = sortGameServersByLeastFullNodes(potentialDeletions, counts)
-		} else {
-			potentialDeletions = sortGameServersByNewFirst(potentialDeletions)
-		}
+		potentialDeletions = sortGameServersForScaleDown(strategy, preference, potentialDeletions, counts, fleetCounts)
 
 		toDelete = append(toDelete, potentialDeletions[0:deleteCount]...)
 	}
@@ -477,32 +667,77 @@ func computeReconciliationAction(strategy apis.SchedulingStrategy, list []*v1alp
 	return numServersToAdd, toDelete, partialReconciliation
 }
 
-// addMoreGameServers adds diff more GameServers to the set
-func (c *Controller) addMoreGameServers(gsSet *v1alpha1.GameServerSet, count int) error {
+// addMoreGameServers adds diff more GameServers to the set, and returns the distinct reasons
+// that any of them could not be created, added to the CreationFailures of the set, unless any were created
+func (c *Controller) addMoreGameServers(gsSet *v1alpha1.GameServerSet, count int) ([]v1alpha1.GameServerSetCreationFailure, error) {
 	c.loggerForGameServerSet(gsSet).WithField("count", count).Info("Adding more gameservers")
 
-	return parallelize(newGameServersChannel(count, gsSet), maxCreationParalellism, func(gs *v1alpha1.GameServer) error {
+	var mutex sync.Mutex
+	var failed []error
+	created := false
+	err := parallelize(newGameServersChannel(count, gsSet), maxCreationParalellism, func(gs *v1alpha1.GameServer) error {
 		gs, err := c.gameServerGetter.GameServers(gs.Namespace).Create(gs)
 		if err != nil {
+			mutex.Lock()
+			failed = append(failed, err)
+			mutex.Unlock()
+			c.recorder.Eventf(gsSet, corev1.EventTypeWarning, "FailedCreate", "Error creating gameserver: %v", err)
 			return errors.Wrapf(err, "error creating gameserver for gameserverset %s", gsSet.ObjectMeta.Name)
 		}
 
+		mutex.Lock()
+		created = true
+		mutex.Unlock()
 		c.stateCache.forGameServerSet(gsSet).created(gs)
 		c.recorder.Eventf(gsSet, corev1.EventTypeNormal, "SuccessfulCreate", "Created gameserver: %s", gs.ObjectMeta.Name)
 		return nil
 	})
+
+	if created {
+		return creationFailures(nil, failed), err
+	}
+	return creationFailures(gsSet.Status.CreationFailures, failed), err
+}
+
+// creationFailures aggregates the errors from creating GameServers by their reason and message,
+// added to the previous failures, with the most common first
+func creationFailures(previous []v1alpha1.GameServerSetCreationFailure, errs []error) []v1alpha1.GameServerSetCreationFailure {
+	result := append([]v1alpha1.GameServerSetCreationFailure(nil), previous...)
+	for _, err := range errs {
+		reason := k8serrors.ReasonForError(err)
+		message := err.Error()
+		found := false
+		for i := range result {
+			if result[i].Reason == reason && result[i].Message == message {
+				result[i].Count++
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, v1alpha1.GameServerSetCreationFailure{Reason: reason, Message: message, Count: 1})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Message < result[j].Message
+	})
+	return result
 }
 
 func (c *Controller) deleteGameServers(gsSet *v1alpha1.GameServerSet, toDelete []*v1alpha1.GameServer) error {
 	c.loggerForGameServerSet(gsSet).WithField("diff", len(toDelete)).Info("Deleting gameservers")
 
 	return parallelize(gameServerListToChannel(toDelete), maxDeletionParallelism, func(gs *v1alpha1.GameServer) error {
-		// We should not delete the gameservers directly buy set their state to shutdown and let the gameserver controller to delete
-		gsCopy := gs.DeepCopy()
-		gsCopy.Status.State = v1alpha1.GameServerStateShutdown
-		_, err := c.gameServerGetter.GameServers(gs.Namespace).Update(gsCopy)
+		gs, err := c.shutdownGameServer(gsSet, gs)
 		if err != nil {
-			return errors.Wrapf(err, "error updating gameserver %s from status %s to Shutdown status.", gs.ObjectMeta.Name, gs.Status.State)
+			return err
+		}
+		if gs == nil {
+			return nil
 		}
 
 		c.stateCache.forGameServerSet(gsSet).deleted(gs)
@@ -511,6 +746,43 @@ func (c *Controller) deleteGameServers(gsSet *v1alpha1.GameServerSet, toDelete [
 	})
 }
 
+// shutdownGameServer moves a GameServer that is being scaled down to the Shutdown state, returning the version
+// of the GameServer that was shut down, or nil if it can no longer be deleted.
+// The update is fenced by the resourceVersion of the GameServer, so if the GameServer has changed since it was
+// listed, e.g. because it allocated itself through the SDK at the same time, the latest version is retrieved
+// and only shut down if it is still deletable.
+func (c *Controller) shutdownGameServer(gsSet *v1alpha1.GameServerSet, gs *v1alpha1.GameServer) (*v1alpha1.GameServer, error) {
+	gameServers := c.gameServerGetter.GameServers(gs.ObjectMeta.Namespace)
+
+	// We should not delete the gameservers directly buy set their state to shutdown and let the gameserver controller to delete
+	gsCopy := gs.DeepCopy()
+	gsCopy.Status.State = v1alpha1.GameServerStateShutdown
+	_, err := gameServers.Update(gsCopy)
+	if k8serrors.IsConflict(err) {
+		latest, getErr := gameServers.Get(gs.ObjectMeta.Name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(getErr) {
+			return nil, nil
+		}
+		if getErr != nil {
+			return nil, errors.Wrapf(getErr, "error retrieving gameserver %s to check it can still be deleted", gs.ObjectMeta.Name)
+		}
+		if !latest.IsDeletable() || latest.IsBeingDeleted() {
+			c.loggerForGameServerSet(gsSet).WithField("gameserver", gs.ObjectMeta.Name).WithField("state", latest.Status.State).
+				Info("GameServer changed state while scaling down, not deleting it")
+			return nil, nil
+		}
+		gs = latest
+		gsCopy = latest.DeepCopy()
+		gsCopy.Status.State = v1alpha1.GameServerStateShutdown
+		_, err = gameServers.Update(gsCopy)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error updating gameserver %s from status %s to Shutdown status.", gs.ObjectMeta.Name, gs.Status.State)
+	}
+
+	return gs, nil
+}
+
 func newGameServersChannel(n int, gsSet *v1alpha1.GameServerSet) chan *v1alpha1.GameServer {
 	gameServers := make(chan *v1alpha1.GameServer)
 	go func() {
@@ -569,14 +841,18 @@ func parallelize(gameServers chan *v1alpha1.GameServer, parallelism int, work fu
 	return <-errch
 }
 
-// syncGameServerSetStatus synchronises the GameServerSet State with active GameServer counts
-func (c *Controller) syncGameServerSetStatus(gsSet *v1alpha1.GameServerSet, list []*v1alpha1.GameServer) error {
-	return c.updateStatusIfChanged(gsSet, computeStatus(list))
+// syncGameServerSetStatus synchronises the GameServerSet State with active GameServer counts,
+// and the reasons GameServers could not be created
+func (c *Controller) syncGameServerSetStatus(gsSet *v1alpha1.GameServerSet, list []*v1alpha1.GameServer,
+	failures []v1alpha1.GameServerSetCreationFailure) error {
+	status := computeStatus(list)
+	status.CreationFailures = failures
+	return c.updateStatusIfChanged(gsSet, status)
 }
 
 // updateStatusIfChanged updates GameServerSet status if it's different than provided.
 func (c *Controller) updateStatusIfChanged(gsSet *v1alpha1.GameServerSet, status v1alpha1.GameServerSetStatus) error {
-	if gsSet.Status != status {
+	if !reflect.DeepEqual(gsSet.Status, status) {
 		gsSetCopy := gsSet.DeepCopy()
 		gsSetCopy.Status = status
 		_, err := c.gameServerSetGetter.GameServerSets(gsSet.ObjectMeta.Namespace).UpdateStatus(gsSetCopy)
@@ -606,6 +882,31 @@ func computeStatus(list []*v1alpha1.GameServer) v1alpha1.GameServerSetStatus {
 		case v1alpha1.GameServerStateReserved:
 			status.ReservedReplicas++
 		}
+
+		if gs.Status.Players != nil {
+			if status.Players == nil {
+				status.Players = &v1alpha1.AggregatedPlayerStatus{}
+			}
+			status.Players.Add(gs.Status.Players)
+		}
+
+		allocated := gs.Status.State == v1alpha1.GameServerStateAllocated
+		for name, c := range gs.Status.Counters {
+			if status.Counters == nil {
+				status.Counters = map[string]v1alpha1.AggregatedCounterStatus{}
+			}
+			a := status.Counters[name]
+			a.Add(c, allocated)
+			status.Counters[name] = a
+		}
+		for name, l := range gs.Status.Lists {
+			if status.Lists == nil {
+				status.Lists = map[string]v1alpha1.AggregatedListStatus{}
+			}
+			a := status.Lists[name]
+			a.Add(l, allocated)
+			status.Lists[name] = a
+		}
 	}
 
 	return status
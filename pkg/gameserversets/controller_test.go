@@ -27,9 +27,12 @@ import (
 	agtesting "agones.dev/agones/pkg/testing"
 	"agones.dev/agones/pkg/util/webhooks"
 	"github.com/heptiolabs/healthcheck"
+	"github.com/mattbaird/jsonpatch"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	admv1beta1 "k8s.io/api/admission/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
@@ -209,7 +212,7 @@ func TestComputeReconciliationAction(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.desc, func(t *testing.T) {
-			toAdd, toDelete, isPartial := computeReconciliationAction(apis.Distributed, tc.list, map[string]gameservers.NodeCount{},
+			toAdd, toDelete, isPartial := computeReconciliationAction(apis.Distributed, "", tc.list, map[string]gameservers.NodeCount{}, nil,
 				tc.targetReplicaCount, maxTestCreationsPerBatch, maxTestDeletionsPerBatch, maxTestPendingPerBatch)
 
 			assert.Equal(t, tc.wantNumServersToAdd, toAdd, "# of GameServers to add")
@@ -227,7 +230,7 @@ func TestComputeReconciliationAction(t *testing.T) {
 		}
 
 		counts := map[string]gameservers.NodeCount{"node1": {Ready: 1}, "node3": {Ready: 2}}
-		toAdd, toDelete, isPartial := computeReconciliationAction(apis.Packed, list, counts, 2,
+		toAdd, toDelete, isPartial := computeReconciliationAction(apis.Packed, "", list, counts, nil, 2,
 			1000, 1000, 1000)
 
 		assert.Empty(t, toAdd)
@@ -252,7 +255,7 @@ func TestComputeReconciliationAction(t *testing.T) {
 				CreationTimestamp: metav1.Time{Time: now.Add(30 * time.Second)}}, Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReady}},
 		}
 
-		toAdd, toDelete, isPartial := computeReconciliationAction(apis.Distributed, list, map[string]gameservers.NodeCount{},
+		toAdd, toDelete, isPartial := computeReconciliationAction(apis.Distributed, "", list, map[string]gameservers.NodeCount{}, nil,
 			2, 1000, 1000, 1000)
 
 		assert.Empty(t, toAdd)
@@ -293,6 +296,37 @@ func TestComputeStatus(t *testing.T) {
 	for _, tc := range cases {
 		assert.Equal(t, tc.wantStatus, computeStatus(tc.list))
 	}
+
+	t.Run("players", func(t *testing.T) {
+		gs1 := gsWithState(v1alpha1.GameServerStateAllocated)
+		gs1.Status.Players = &v1alpha1.PlayerStatus{Count: 3, Capacity: 10}
+		gs2 := gsWithState(v1alpha1.GameServerStateReady)
+		gs2.Status.Players = &v1alpha1.PlayerStatus{Count: 0, Capacity: 10}
+		gs3 := gsWithState(v1alpha1.GameServerStateReady)
+
+		status := computeStatus([]*v1alpha1.GameServer{gs1, gs2, gs3})
+		assert.Equal(t, &v1alpha1.AggregatedPlayerStatus{Count: 3, Capacity: 20}, status.Players)
+	})
+
+	t.Run("counters and lists", func(t *testing.T) {
+		gs1 := gsWithState(v1alpha1.GameServerStateAllocated)
+		gs1.Status.Counters = map[string]v1alpha1.CounterStatus{"rooms": {Count: 3, Capacity: 10}}
+		gs1.Status.Lists = map[string]v1alpha1.ListStatus{"players": {Capacity: 8, Values: []string{"a", "b"}}}
+		gs2 := gsWithState(v1alpha1.GameServerStateReady)
+		gs2.Status.Counters = map[string]v1alpha1.CounterStatus{"rooms": {Count: 1, Capacity: 10}}
+		gs2.Status.Lists = map[string]v1alpha1.ListStatus{"players": {Capacity: 8}}
+		gs3 := gsWithState(v1alpha1.GameServerStateReady)
+		gs3.ObjectMeta.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		gs3.Status.Counters = map[string]v1alpha1.CounterStatus{"rooms": {Count: 5, Capacity: 10}}
+
+		status := computeStatus([]*v1alpha1.GameServer{gs1, gs2, gs3})
+		assert.Equal(t, map[string]v1alpha1.AggregatedCounterStatus{
+			"rooms": {AllocatedCount: 3, AllocatedCapacity: 10, Count: 4, Capacity: 20},
+		}, status.Counters)
+		assert.Equal(t, map[string]v1alpha1.AggregatedListStatus{
+			"players": {AllocatedCount: 2, AllocatedCapacity: 8, Count: 2, Capacity: 16},
+		}, status.Lists)
+	})
 }
 
 func TestControllerWatchGameServers(t *testing.T) {
@@ -492,6 +526,155 @@ func TestControllerSyncUnhealthyGameServers(t *testing.T) {
 	assert.Equal(t, 3, updatedCount, "Updates should have occurred")
 }
 
+func TestControllerRetainUnhealthyGameServers(t *testing.T) {
+	t.Parallel()
+
+	gsSet := defaultFixture()
+
+	newGameServer := func(name string, state v1alpha1.GameServerState, annotations map[string]string) *v1alpha1.GameServer {
+		gs := gsSet.GameServer()
+		gs.ObjectMeta.Name = name
+		gs.ObjectMeta.Annotations = annotations
+		gs.Status = v1alpha1.GameServerStatus{State: state}
+		return gs
+	}
+
+	t.Run("no retention", func(t *testing.T) {
+		c, m := newFakeController()
+		m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			assert.FailNow(t, "should not update")
+			return true, nil, nil
+		})
+
+		list := []*v1alpha1.GameServer{
+			newGameServer("ready", v1alpha1.GameServerStateReady, nil),
+			newGameServer("unhealthy", v1alpha1.GameServerStateUnhealthy, nil),
+		}
+		assert.Equal(t, list, c.retainUnhealthyGameServers(gsSet, list))
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		c, m := newFakeController()
+		c.unhealthyRetention = time.Hour
+
+		var annotated []string
+		m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gs := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.GameServer)
+			_, err := time.Parse(time.RFC3339, gs.ObjectMeta.Annotations[v1alpha1.UnhealthyTimeAnnotation])
+			assert.Nil(t, err)
+			annotated = append(annotated, gs.ObjectMeta.Name)
+			return true, gs, nil
+		})
+
+		ready := newGameServer("ready", v1alpha1.GameServerStateReady, nil)
+		newlyUnhealthy := newGameServer("new", v1alpha1.GameServerStateUnhealthy, nil)
+		retained := newGameServer("retained", v1alpha1.GameServerStateUnhealthy,
+			map[string]string{v1alpha1.UnhealthyTimeAnnotation: time.Now().Add(-30 * time.Minute).UTC().Format(time.RFC3339)})
+		expired := newGameServer("expired", v1alpha1.GameServerStateUnhealthy,
+			map[string]string{v1alpha1.UnhealthyTimeAnnotation: time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)})
+		overridden := newGameServer("overridden", v1alpha1.GameServerStateUnhealthy,
+			map[string]string{v1alpha1.UnhealthyTimeAnnotation: time.Now().Add(-30 * time.Minute).UTC().Format(time.RFC3339),
+				v1alpha1.UnhealthyRetentionAnnotation: "10m"})
+		disabled := newGameServer("disabled", v1alpha1.GameServerStateUnhealthy,
+			map[string]string{v1alpha1.UnhealthyRetentionAnnotation: "0s"})
+
+		result := c.retainUnhealthyGameServers(gsSet, []*v1alpha1.GameServer{ready, newlyUnhealthy, retained, expired, overridden, disabled})
+		assert.Equal(t, []*v1alpha1.GameServer{ready, expired, overridden, disabled}, result)
+		assert.Equal(t, []string{"new"}, annotated)
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "Retaining Unhealthy GameServer new for 1h0m0s")
+	})
+}
+
+func TestControllerApplyAllocationOverflow(t *testing.T) {
+	t.Parallel()
+
+	gsSet := defaultFixture()
+	gsSet.Spec.AllocationOverflow = &v1alpha1.AllocationOverflow{
+		Labels:      map[string]string{"version": "outdated"},
+		Annotations: map[string]string{"message": "please migrate"},
+	}
+
+	newGameServer := func(name string, state v1alpha1.GameServerState) *v1alpha1.GameServer {
+		gs := gsSet.GameServer()
+		gs.ObjectMeta.Name = name
+		gs.Status = v1alpha1.GameServerStatus{State: state}
+		return gs
+	}
+
+	c, m := newFakeController()
+	var updated []string
+	m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gs := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.GameServer)
+		assert.Equal(t, "outdated", gs.ObjectMeta.Labels["version"])
+		assert.Equal(t, "please migrate", gs.ObjectMeta.Annotations["message"])
+		assert.Equal(t, gsSet.ObjectMeta.Name, gs.ObjectMeta.Labels[v1alpha1.GameServerSetGameServerLabel])
+		updated = append(updated, gs.ObjectMeta.Name)
+		return true, gs, nil
+	})
+
+	allocated := newGameServer("allocated", v1alpha1.GameServerStateAllocated)
+	applied := newGameServer("applied", v1alpha1.GameServerStateAllocated)
+	gsSet.Spec.AllocationOverflow.Apply(applied)
+	deleted := newGameServer("deleted", v1alpha1.GameServerStateAllocated)
+	now := metav1.Now()
+	deleted.ObjectMeta.DeletionTimestamp = &now
+	ready := newGameServer("ready", v1alpha1.GameServerStateReady)
+
+	c.applyAllocationOverflow(gsSet, []*v1alpha1.GameServer{allocated, applied, deleted, ready})
+	assert.Equal(t, []string{"allocated"}, updated)
+	assert.Empty(t, allocated.ObjectMeta.Annotations)
+}
+
+func TestControllerDeleteGameServersConflict(t *testing.T) {
+	t.Parallel()
+
+	run := func(t *testing.T, latestState v1alpha1.GameServerState) (int, *agtesting.Mocks) {
+		gsSet := defaultFixture()
+		gs := gsSet.GameServer()
+		gs.ObjectMeta.Name = "test-1"
+		gs.ObjectMeta.ResourceVersion = "1"
+		gs.Status = v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReady}
+
+		c, m := newFakeController()
+		updatedCount := 0
+		m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			ua := action.(k8stesting.UpdateAction)
+			updated := ua.GetObject().(*v1alpha1.GameServer)
+			assert.Equal(t, v1alpha1.GameServerStateShutdown, updated.Status.State)
+			updatedCount++
+			if updated.ObjectMeta.ResourceVersion == "1" {
+				return true, nil, k8serrors.NewConflict(v1alpha1.Resource("gameservers"), updated.ObjectMeta.Name, errors.New("conflict"))
+			}
+			return true, updated, nil
+		})
+		m.AgonesClient.AddReactor("get", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			latest := gs.DeepCopy()
+			latest.ObjectMeta.ResourceVersion = "2"
+			latest.Status.State = latestState
+			return true, latest, nil
+		})
+
+		_, cancel := agtesting.StartInformers(m)
+		defer cancel()
+
+		err := c.deleteGameServers(gsSet, []*v1alpha1.GameServer{gs})
+		assert.Nil(t, err)
+		return updatedCount, &m
+	}
+
+	t.Run("allocated through the SDK before the scale down", func(t *testing.T) {
+		updatedCount, m := run(t, v1alpha1.GameServerStateAllocated)
+		assert.Equal(t, 1, updatedCount)
+		assert.Empty(t, m.FakeRecorder.Events)
+	})
+
+	t.Run("still deletable", func(t *testing.T) {
+		updatedCount, m := run(t, v1alpha1.GameServerStateReady)
+		assert.Equal(t, 2, updatedCount)
+		agtesting.AssertEventContains(t, m.FakeRecorder.Events, "SuccessfulDelete")
+	})
+}
+
 func TestSyncMoreGameServers(t *testing.T) {
 	gsSet := defaultFixture()
 
@@ -512,12 +695,71 @@ func TestSyncMoreGameServers(t *testing.T) {
 	_, cancel := agtesting.StartInformers(m)
 	defer cancel()
 
-	err := c.addMoreGameServers(gsSet, expected)
+	failures, err := c.addMoreGameServers(gsSet, expected)
 	assert.Nil(t, err)
+	assert.Empty(t, failures)
 	assert.Equal(t, expected, count)
 	agtesting.AssertEventContains(t, m.FakeRecorder.Events, "SuccessfulCreate")
 }
 
+func TestSyncMoreGameServersFailures(t *testing.T) {
+	gsSet := defaultFixture()
+
+	c, m := newFakeController()
+	count := 0
+
+	m.AgonesClient.AddReactor("create", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		count++
+		return true, nil, k8serrors.NewForbidden(v1alpha1.Resource("gameservers"), "", errors.New("exceeded quota: gameservers"))
+	})
+
+	_, cancel := agtesting.StartInformers(m)
+	defer cancel()
+
+	failures, err := c.addMoreGameServers(gsSet, 10)
+	assert.Error(t, err)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, metav1.StatusReasonForbidden, failures[0].Reason)
+		assert.Contains(t, failures[0].Message, "exceeded quota")
+		assert.Equal(t, int32(count), failures[0].Count)
+	}
+	agtesting.AssertEventContains(t, m.FakeRecorder.Events, "FailedCreate")
+
+	// failures accumulate until a GameServer is created
+	gsSet.Status.CreationFailures = failures
+	failures, err = c.addMoreGameServers(gsSet, 10)
+	assert.Error(t, err)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, int32(count), failures[0].Count)
+		assert.True(t, failures[0].Count > gsSet.Status.CreationFailures[0].Count)
+	}
+}
+
+func TestCreationFailures(t *testing.T) {
+	t.Parallel()
+
+	quota := k8serrors.NewForbidden(v1alpha1.Resource("gameservers"), "", errors.New("exceeded quota: gameservers"))
+	invalid := k8serrors.NewBadRequest("invalid image")
+	other := errors.New("connection refused")
+
+	failures := creationFailures(nil, []error{invalid, quota, other, quota, quota, invalid})
+	assert.Equal(t, []v1alpha1.GameServerSetCreationFailure{
+		{Reason: metav1.StatusReasonForbidden, Message: quota.Error(), Count: 3},
+		{Reason: metav1.StatusReasonBadRequest, Message: invalid.Error(), Count: 2},
+		{Reason: metav1.StatusReasonUnknown, Message: other.Error(), Count: 1},
+	}, failures)
+
+	// failures are added to the previous ones
+	previous := []v1alpha1.GameServerSetCreationFailure{{Reason: metav1.StatusReasonUnknown, Message: other.Error(), Count: 4}}
+	assert.Equal(t, []v1alpha1.GameServerSetCreationFailure{
+		{Reason: metav1.StatusReasonUnknown, Message: other.Error(), Count: 5},
+		{Reason: metav1.StatusReasonForbidden, Message: quota.Error(), Count: 1},
+	}, creationFailures(previous, []error{quota, other}))
+	assert.Equal(t, int32(4), previous[0].Count)
+
+	assert.Empty(t, creationFailures(nil, nil))
+}
+
 func TestControllerSyncGameServerSetStatus(t *testing.T) {
 	t.Parallel()
 
@@ -539,7 +781,7 @@ func TestControllerSyncGameServerSetStatus(t *testing.T) {
 		})
 
 		list := []*v1alpha1.GameServer{{Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReady}}}
-		err := c.syncGameServerSetStatus(gsSet, list)
+		err := c.syncGameServerSetStatus(gsSet, list, nil)
 		assert.Nil(t, err)
 		assert.True(t, updated)
 	})
@@ -571,12 +813,52 @@ func TestControllerSyncGameServerSetStatus(t *testing.T) {
 			{Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateAllocated}},
 			{Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateAllocated}},
 		}
-		err := c.syncGameServerSetStatus(gsSet, list)
+		err := c.syncGameServerSetStatus(gsSet, list, nil)
 		assert.Nil(t, err)
 		assert.True(t, updated)
 	})
 }
 
+func TestControllerCreationMutationHandler(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newFakeController()
+	gvk := metav1.GroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind("GameServerSet"))
+	fixture := v1alpha1.GameServerSet{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: v1alpha1.GameServerSetSpec{Replicas: 5},
+	}
+
+	raw, err := json.Marshal(fixture)
+	assert.Nil(t, err)
+	review := admv1beta1.AdmissionReview{
+		Request: &admv1beta1.AdmissionRequest{
+			Kind:      gvk,
+			Operation: admv1beta1.Create,
+			Object: runtime.RawExtension{
+				Raw: raw,
+			},
+		},
+		Response: &admv1beta1.AdmissionResponse{Allowed: true},
+	}
+
+	result, err := c.creationMutationHandler(review)
+	assert.Nil(t, err)
+	assert.True(t, result.Response.Allowed)
+	assert.Equal(t, admv1beta1.PatchTypeJSONPatch, *result.Response.PatchType)
+
+	patch := &jsonpatch.ByPath{}
+	err = json.Unmarshal(result.Response.Patch, patch)
+	assert.Nil(t, err)
+
+	found := false
+	for _, p := range *patch {
+		if assert.ObjectsAreEqualValues(p, jsonpatch.JsonPatchOperation{Operation: "add", Path: "/spec/scheduling", Value: "Packed"}) {
+			found = true
+		}
+	}
+	assert.True(t, found, "Could not find scheduling operation in patch %v", *patch)
+}
+
 func TestControllerUpdateValidationHandler(t *testing.T) {
 	t.Parallel()
 
@@ -678,7 +960,7 @@ func newFakeController() (*Controller, agtesting.Mocks) {
 	m := agtesting.NewMocks()
 	wh := webhooks.NewWebHook(http.NewServeMux())
 	counter := gameservers.NewPerNodeCounter(m.KubeInformerFactory, m.AgonesInformerFactory)
-	c := NewController(wh, healthcheck.NewHandler(), counter, m.KubeClient, m.ExtClient, m.AgonesClient, m.AgonesInformerFactory)
+	c := NewController(wh, healthcheck.NewHandler(), time.Minute, counter, 0, CreationLimits{}, m.KubeClient, m.ExtClient, m.AgonesClient, m.AgonesInformerFactory)
 	c.recorder = m.FakeRecorder
 	return c, m
 }
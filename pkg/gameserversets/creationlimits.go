@@ -0,0 +1,82 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// CreationLimits limit how quickly GameServerSets create GameServers, so scaling
+// a GameServerSet up by thousands doesn't overwhelm the Kubernetes API server,
+// the scheduler and the image registry all at once
+type CreationLimits struct {
+	// Burst is the most GameServers a GameServerSet creates in a single batch.
+	// 0 is the default of 64.
+	Burst int
+	// Rate is how many GameServers are created per second across all GameServerSets,
+	// once a Burst of them has been created. 0 is no limit.
+	Rate float64
+	// MaxPending is the most GameServers of a GameServerSet that can be waiting for their
+	// Pods to be scheduled and start, before it creates any more. 0 is the default of 5000.
+	MaxPending int
+}
+
+// burst returns the most GameServers a GameServerSet creates in a batch
+func (l CreationLimits) burst() int {
+	if l.Burst <= 0 {
+		return maxGameServerCreationsPerBatch
+	}
+	return l.Burst
+}
+
+// maxPending returns the most GameServers of a GameServerSet that can be pending
+func (l CreationLimits) maxPending() int {
+	if l.MaxPending <= 0 {
+		return maxPodPendingCount
+	}
+	return l.MaxPending
+}
+
+// limiter returns the rate limiter of GameServer creations, or nil if there is no limit
+func (l CreationLimits) limiter() *rate.Limiter {
+	if l.Rate <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(l.Rate), l.burst())
+}
+
+// reserveCreations returns how many of count GameServers can be created now within the creation
+// rate limit, and if that is fewer than count, how long until another one can be created
+func (c *Controller) reserveCreations(count int) (int, time.Duration) {
+	if c.creationLimiter == nil {
+		return count, 0
+	}
+
+	now := time.Now()
+	allowed := 0
+	for allowed < count && c.creationLimiter.AllowN(now, 1) {
+		allowed++
+	}
+	if allowed == count {
+		return count, 0
+	}
+
+	r := c.creationLimiter.ReserveN(now, 1)
+	delay := r.DelayFrom(now)
+	r.CancelAt(now)
+	return allowed, delay
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gameserversets
+
+import (
+	"testing"
+	"time"
+
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	agtesting "agones.dev/agones/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCreationLimits(t *testing.T) {
+	t.Parallel()
+
+	l := CreationLimits{}
+	assert.Equal(t, maxGameServerCreationsPerBatch, l.burst())
+	assert.Equal(t, maxPodPendingCount, l.maxPending())
+	assert.Nil(t, l.limiter())
+
+	l = CreationLimits{Burst: 10, Rate: 5, MaxPending: 100}
+	assert.Equal(t, 10, l.burst())
+	assert.Equal(t, 100, l.maxPending())
+	if limiter := l.limiter(); assert.NotNil(t, limiter) {
+		assert.Equal(t, 10, limiter.Burst())
+		assert.Equal(t, float64(5), float64(limiter.Limit()))
+	}
+}
+
+func TestControllerReserveCreations(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newFakeController()
+	allowed, delay := c.reserveCreations(1000)
+	assert.Equal(t, 1000, allowed)
+	assert.Equal(t, time.Duration(0), delay)
+
+	c.creationLimiter = CreationLimits{Burst: 10, Rate: 1}.limiter()
+	allowed, delay = c.reserveCreations(4)
+	assert.Equal(t, 4, allowed)
+	assert.Equal(t, time.Duration(0), delay)
+
+	allowed, delay = c.reserveCreations(20)
+	assert.Equal(t, 6, allowed)
+	assert.True(t, delay > 0 && delay <= time.Second, "delay %s should be up to a second", delay)
+}
+
+func TestSyncGameServerSetCreationRate(t *testing.T) {
+	t.Parallel()
+
+	gsSet := defaultFixture()
+	gsSet.Spec.Replicas = 100
+	count := 0
+
+	c, m := newFakeController()
+	c.creationLimits = CreationLimits{Burst: 20, Rate: 0.1}
+	c.creationLimiter = c.creationLimits.limiter()
+	m.AgonesClient.AddReactor("list", "gameserversets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &v1alpha1.GameServerSetList{Items: []v1alpha1.GameServerSet{*gsSet}}, nil
+	})
+	m.AgonesClient.AddReactor("create", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		count++
+		return true, action.(k8stesting.CreateAction).GetObject(), nil
+	})
+
+	_, cancel := agtesting.StartInformers(m, c.gameServerSetSynced, c.gameServerSynced)
+	defer cancel()
+
+	key := gsSet.ObjectMeta.Namespace + "/" + gsSet.ObjectMeta.Name
+	assert.Nil(t, c.syncGameServerSet(key))
+	assert.Equal(t, 20, count, "only a burst of gameservers should be created")
+
+	// the rate limit has been reached, so none are created until it allows more
+	assert.Nil(t, c.syncGameServerSet(key))
+	assert.Equal(t, 20, count)
+}
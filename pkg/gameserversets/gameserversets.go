@@ -16,7 +16,9 @@ package gameserversets
 
 import (
 	"sort"
+	"strconv"
 
+	"agones.dev/agones/pkg/apis"
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
 	listerv1alpha1 "agones.dev/agones/pkg/client/listers/stable/v1alpha1"
 	"agones.dev/agones/pkg/gameservers"
@@ -25,28 +27,102 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 )
 
-// sortGameServersByLeastFullNodes sorts the list of gameservers by which gameservers reside on the least full nodes
-func sortGameServersByLeastFullNodes(list []*v1alpha1.GameServer, count map[string]gameservers.NodeCount) []*v1alpha1.GameServer {
-	sort.Slice(list, func(i, j int) bool {
+// sortGameServersForScaleDown sorts the list of gameservers in the order they are deleted in when scaling down,
+// as per the scale down preference, or the scheduling strategy if there is none
+func sortGameServersForScaleDown(strategy apis.SchedulingStrategy, preference v1alpha1.ScaleDownPreference,
+	list []*v1alpha1.GameServer, counts, fleetCounts map[string]gameservers.NodeCount) []*v1alpha1.GameServer {
+	switch preference {
+	case v1alpha1.ScaleDownOldestFirst:
+		return sortGameServersByAge(list, false)
+	case v1alpha1.ScaleDownNewestFirst:
+		return sortGameServersByAge(list, true)
+	}
+
+	if strategy == apis.Packed {
+		list = sortGameServersByLeastFullNodes(list, counts, fleetCounts)
+	} else {
+		list = sortGameServersByNewFirst(list)
+	}
+	if preference == v1alpha1.ScaleDownCost {
+		list = sortGameServersByCost(list)
+	}
+	return list
+}
+
+// sortGameServersByAge sorts the list of gameservers by their creation, newest or oldest first
+func sortGameServersByAge(list []*v1alpha1.GameServer, newestFirst bool) []*v1alpha1.GameServer {
+	sort.SliceStable(list, func(i, j int) bool {
+		a := list[i].ObjectMeta.CreationTimestamp
+		b := list[j].ObjectMeta.CreationTimestamp
+		if newestFirst {
+			return b.Before(&a)
+		}
+		return a.Before(&b)
+	})
+
+	return list
+}
+
+// sortGameServersByCost sorts the list of gameservers by their scale down cost, lowest first,
+// keeping the order of those with the same cost
+func sortGameServersByCost(list []*v1alpha1.GameServer) []*v1alpha1.GameServer {
+	sort.SliceStable(list, func(i, j int) bool {
+		return scaleDownCost(list[i]) < scaleDownCost(list[j])
+	})
+
+	return list
+}
+
+// scaleDownCost returns the cost of deleting the gameserver from its GameServerScaleDownCostAnnotation,
+// or 0 if it doesn't have a valid one
+func scaleDownCost(gs *v1alpha1.GameServer) int64 {
+	cost, err := strconv.ParseInt(gs.ObjectMeta.Annotations[v1alpha1.GameServerScaleDownCostAnnotation], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return cost
+}
+
+// sortGameServersByLeastFullNodes sorts the list of gameservers by which gameservers reside on the least full nodes,
+// so that scaling down a Packed GameServerSet empties out nodes, which the cluster autoscaler can then remove.
+// Gameservers that aren't scheduled yet, or whose node has been deleted, come first. Of equally full nodes, those
+// with the fewest gameservers of other fleets, as per the counts of the fleet of the gameservers in fleetCount,
+// come first, as deleting the gameservers gets them the closest to empty. Gameservers on equally full nodes are
+// grouped by node, so that one node is emptied out before the next, with the newest first.
+func sortGameServersByLeastFullNodes(list []*v1alpha1.GameServer, count, fleetCount map[string]gameservers.NodeCount) []*v1alpha1.GameServer {
+	sort.SliceStable(list, func(i, j int) bool {
 		a := list[i]
 		b := list[j]
-		// not scheduled yet/node deleted, put them first
-		ac, ok := count[a.Status.NodeName]
-		if !ok {
-			return true
+		ac, aok := count[a.Status.NodeName]
+		bc, bok := count[b.Status.NodeName]
+		if aok != bok {
+			return !aok
 		}
-
-		bc, ok := count[b.Status.NodeName]
-		if !ok {
+		if !aok {
 			return false
 		}
 
-		return (ac.Allocated + ac.Ready) < (bc.Allocated + bc.Ready)
+		at, bt := nodeTotal(ac), nodeTotal(bc)
+		if at != bt {
+			return at < bt
+		}
+		if ao, bo := at-nodeTotal(fleetCount[a.Status.NodeName]), bt-nodeTotal(fleetCount[b.Status.NodeName]); ao != bo {
+			return ao < bo
+		}
+		if a.Status.NodeName != b.Status.NodeName {
+			return a.Status.NodeName < b.Status.NodeName
+		}
+		return b.ObjectMeta.CreationTimestamp.Before(&a.ObjectMeta.CreationTimestamp)
 	})
 
 	return list
 }
 
+// nodeTotal returns the number of Ready, Reserved and Allocated gameservers of a NodeCount
+func nodeTotal(nc gameservers.NodeCount) int64 {
+	return nc.Allocated + nc.Reserved + nc.Ready
+}
+
 // sortGameServersByNewFirst sorts by newest gameservers first, and returns them
 func sortGameServersByNewFirst(list []*v1alpha1.GameServer) []*v1alpha1.GameServer {
 	sort.Slice(list, func(i, j int) bool {
@@ -19,6 +19,7 @@ import (
 	"testing"
 	"time"
 
+	"agones.dev/agones/pkg/apis"
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
 	"agones.dev/agones/pkg/gameservers"
 	agtesting "agones.dev/agones/pkg/testing"
@@ -34,20 +35,123 @@ func TestSortGameServersByLeastFullNodes(t *testing.T) {
 	nc := map[string]gameservers.NodeCount{
 		"n1": {Ready: 1, Allocated: 0},
 		"n2": {Ready: 0, Allocated: 2},
+		"n3": {Ready: 0, Allocated: 2, Reserved: 1},
 	}
 
 	list := []*v1alpha1.GameServer{
+		{ObjectMeta: metav1.ObjectMeta{Name: "g4"}, Status: v1alpha1.GameServerStatus{NodeName: "n3"}},
 		{ObjectMeta: metav1.ObjectMeta{Name: "g1"}, Status: v1alpha1.GameServerStatus{NodeName: "n2"}},
 		{ObjectMeta: metav1.ObjectMeta{Name: "g2"}, Status: v1alpha1.GameServerStatus{NodeName: ""}},
 		{ObjectMeta: metav1.ObjectMeta{Name: "g3"}, Status: v1alpha1.GameServerStatus{NodeName: "n1"}},
 	}
 
-	result := sortGameServersByLeastFullNodes(list, nc)
+	result := sortGameServersByLeastFullNodes(list, nc, nil)
 
 	assert.Len(t, result, len(list))
 	assert.Equal(t, "g2", result[0].ObjectMeta.Name)
 	assert.Equal(t, "g3", result[1].ObjectMeta.Name)
 	assert.Equal(t, "g1", result[2].ObjectMeta.Name)
+	assert.Equal(t, "g4", result[3].ObjectMeta.Name)
+
+	t.Run("equally full nodes", func(t *testing.T) {
+		now := metav1.Now()
+		nc := map[string]gameservers.NodeCount{
+			"n1": {Ready: 2},
+			"n2": {Ready: 1, Allocated: 1},
+		}
+
+		list := []*v1alpha1.GameServer{
+			{ObjectMeta: metav1.ObjectMeta{Name: "g1", CreationTimestamp: now}, Status: v1alpha1.GameServerStatus{NodeName: "n2"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "g2", CreationTimestamp: now}, Status: v1alpha1.GameServerStatus{NodeName: "n1"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "g3"}, Status: v1alpha1.GameServerStatus{NodeName: "n3"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "g4", CreationTimestamp: metav1.Time{Time: now.Add(time.Minute)}},
+				Status: v1alpha1.GameServerStatus{NodeName: "n1"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "g5"}, Status: v1alpha1.GameServerStatus{NodeName: ""}},
+		}
+
+		result := sortGameServersByLeastFullNodes(list, nc, nil)
+
+		var names []string
+		for _, gs := range result {
+			names = append(names, gs.ObjectMeta.Name)
+		}
+		assert.Equal(t, []string{"g3", "g5", "g4", "g2", "g1"}, names)
+	})
+
+	t.Run("equally full nodes, with other fleets", func(t *testing.T) {
+		nc := map[string]gameservers.NodeCount{
+			"n1": {Ready: 2},
+			"n2": {Ready: 2},
+		}
+		// n1 also has a gameserver of another fleet, so it can't be emptied out by this one
+		fc := map[string]gameservers.NodeCount{
+			"n1": {Ready: 1},
+			"n2": {Ready: 2},
+		}
+
+		list := []*v1alpha1.GameServer{
+			{ObjectMeta: metav1.ObjectMeta{Name: "g1"}, Status: v1alpha1.GameServerStatus{NodeName: "n1"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "g2"}, Status: v1alpha1.GameServerStatus{NodeName: "n2"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "g3"}, Status: v1alpha1.GameServerStatus{NodeName: "n2"}},
+		}
+
+		result := sortGameServersByLeastFullNodes(list, nc, fc)
+
+		var names []string
+		for _, gs := range result {
+			names = append(names, gs.ObjectMeta.Name)
+		}
+		assert.Equal(t, []string{"g2", "g3", "g1"}, names)
+	})
+}
+
+func TestSortGameServersForScaleDown(t *testing.T) {
+	t.Parallel()
+
+	now := metav1.Now()
+	nc := map[string]gameservers.NodeCount{
+		"n1": {Ready: 1},
+		"n2": {Ready: 3},
+	}
+	newList := func() []*v1alpha1.GameServer {
+		return []*v1alpha1.GameServer{
+			{ObjectMeta: metav1.ObjectMeta{Name: "g1", CreationTimestamp: now,
+				Annotations: map[string]string{v1alpha1.GameServerScaleDownCostAnnotation: "10"}},
+				Status: v1alpha1.GameServerStatus{NodeName: "n2"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "g2", CreationTimestamp: metav1.Time{Time: now.Add(10 * time.Second)},
+				Annotations: map[string]string{v1alpha1.GameServerScaleDownCostAnnotation: "-5"}},
+				Status: v1alpha1.GameServerStatus{NodeName: "n2"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "g3", CreationTimestamp: metav1.Time{Time: now.Add(20 * time.Second)}},
+				Status: v1alpha1.GameServerStatus{NodeName: "n2"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "g4", CreationTimestamp: metav1.Time{Time: now.Add(5 * time.Second)},
+				Annotations: map[string]string{v1alpha1.GameServerScaleDownCostAnnotation: "invalid"}},
+				Status: v1alpha1.GameServerStatus{NodeName: "n1"}},
+		}
+	}
+
+	fixtures := map[string]struct {
+		strategy   apis.SchedulingStrategy
+		preference v1alpha1.ScaleDownPreference
+		expected   []string
+	}{
+		"packed":            {strategy: apis.Packed, expected: []string{"g4", "g3", "g2", "g1"}},
+		"distributed":       {strategy: apis.Distributed, expected: []string{"g1", "g4", "g2", "g3"}},
+		"oldest first":      {strategy: apis.Packed, preference: v1alpha1.ScaleDownOldestFirst, expected: []string{"g1", "g4", "g2", "g3"}},
+		"newest first":      {strategy: apis.Packed, preference: v1alpha1.ScaleDownNewestFirst, expected: []string{"g3", "g2", "g4", "g1"}},
+		"cost, packed":      {strategy: apis.Packed, preference: v1alpha1.ScaleDownCost, expected: []string{"g2", "g4", "g3", "g1"}},
+		"cost, distributed": {strategy: apis.Distributed, preference: v1alpha1.ScaleDownCost, expected: []string{"g2", "g4", "g3", "g1"}},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			result := sortGameServersForScaleDown(v.strategy, v.preference, newList(), nc, nil)
+			var names []string
+			for _, gs := range result {
+				names = append(names, gs.ObjectMeta.Name)
+			}
+			assert.Equal(t, v.expected, names)
+		})
+	}
 }
 
 func TestSortGameServersByNewFirst(t *testing.T) {
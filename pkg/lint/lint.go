@@ -0,0 +1,278 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint serves an aggregated API that checks GameServers, GameServerSets and Fleets
+// without creating them, so game teams can lint their specs ahead of time, such as in CI
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"agones.dev/agones/pkg/apis/stable"
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"agones.dev/agones/pkg/util/apiserver"
+	"agones.dev/agones/pkg/util/https"
+	"agones.dev/agones/pkg/util/runtime"
+	"agones.dev/agones/pkg/util/webhooks"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	admv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// GroupName is the API group that the lint API is served under
+	GroupName = "lint.agones.dev"
+	// ReviewKind is the kind of the Review returned by the lint API
+	ReviewKind = "LintReview"
+)
+
+// SchemeGroupVersion is the group version of the lint API
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1"}
+
+// resources are the api resources of the lint API, one for each kind of object it lints
+var resources = []metav1.APIResource{
+	{Name: "gameservers", SingularName: "gameserver", Kind: "GameServer"},
+	{Name: "gameserversets", SingularName: "gameserverset", Kind: "GameServerSet"},
+	{Name: "fleets", SingularName: "fleet", Kind: "Fleet"},
+}
+
+// Review is the result of linting an object
+type Review struct {
+	metav1.TypeMeta `json:",inline"`
+	// Allowed is whether the object would be accepted if it was created
+	Allowed bool `json:"allowed"`
+	// Message is why the object would not be accepted, if it wouldn't be
+	Message string `json:"message,omitempty"`
+	// Causes are each of the problems with the object that would stop it being accepted
+	Causes []metav1.StatusCause `json:"causes,omitempty"`
+	// Warnings are problems with the object that would not stop it being accepted,
+	// but are likely to cause problems once it is running
+	Warnings []string `json:"warnings,omitempty"`
+	// Object is the object with all of its defaults applied, as it would be created
+	Object json.RawMessage `json:"object"`
+}
+
+// Linter lints GameServers, GameServerSets and Fleets by running them through the
+// same mutating and validating webhook handlers as when they are created
+type Linter struct {
+	baseLogger *logrus.Entry
+	wh         *webhooks.WebHook
+}
+
+// NewLinter returns a Linter, and registers its api resources
+func NewLinter(wh *webhooks.WebHook, api *apiserver.APIServer) *Linter {
+	l := &Linter{wh: wh}
+	l.baseLogger = runtime.NewLoggerWithType(l)
+
+	for _, resource := range resources {
+		resource.Namespaced = true
+		resource.Verbs = []string{"create"}
+		api.AddAPIResource(SchemeGroupVersion.String(), resource, l.lintHandler(resource.Name, resource.Kind),
+			apiserver.AllowMethods(http.MethodPost))
+	}
+
+	return l
+}
+
+// lintHandler returns the handler that lints objects of the given kind that are posted to it
+func (l *Linter) lintHandler(resource, kind string) apiserver.CRDHandler {
+	return func(w http.ResponseWriter, r *http.Request, namespace string) error {
+		if r.Body != nil {
+			defer r.Body.Close() // nolint: errcheck
+		}
+
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return errors.Wrap(err, "could not read body")
+		}
+
+		review, err := l.lint(resource, kind, namespace, b)
+		if err != nil {
+			https.LogRequest(l.baseLogger, r).WithError(err).Info("could not lint object")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+
+		w.Header().Set(apiserver.ContentTypeHeader, k8sruntime.ContentTypeJSON)
+		return errors.Wrap(json.NewEncoder(w).Encode(review), "error encoding lint review")
+	}
+}
+
+// lint applies the defaults to the object of the given kind in body, which can be YAML or JSON,
+// and validates it, as if it was being created in the namespace
+func (l *Linter) lint(resource, kind, namespace string, body []byte) (*Review, error) {
+	raw, err := yaml.ToJSON(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode object")
+	}
+	obj := map[string]interface{}{}
+	if err = json.Unmarshal(raw, &obj); err != nil {
+		return nil, errors.Wrap(err, "could not decode object")
+	}
+
+	gvk, err := objectKind(obj, kind)
+	if err != nil {
+		return nil, err
+	}
+	name, err := setNamespace(obj, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if raw, err = json.Marshal(obj); err != nil {
+		return nil, errors.Wrap(err, "could not encode object")
+	}
+
+	request := admv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+		Resource:  metav1.GroupVersionResource{Group: gvk.Group, Version: gvk.Version, Resource: resource},
+		Name:      name,
+		Namespace: namespace,
+		Operation: admv1beta1.Create,
+		Object:    k8sruntime.RawExtension{Raw: raw},
+	}
+
+	review, err := l.wh.Review("/mutate", admv1beta1.AdmissionReview{Request: request.DeepCopy()})
+	if err != nil {
+		return nil, err
+	}
+	if review.Response.Allowed && len(review.Response.Patch) > 0 {
+		if raw, err = applyPatch(raw, review.Response.Patch); err != nil {
+			return nil, err
+		}
+		request.Object = k8sruntime.RawExtension{Raw: raw}
+	}
+	if review.Response.Allowed {
+		if review, err = l.wh.Review("/validate", admv1beta1.AdmissionReview{Request: request.DeepCopy()}); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Review{
+		TypeMeta: metav1.TypeMeta{Kind: ReviewKind, APIVersion: SchemeGroupVersion.String()},
+		Allowed:  review.Response.Allowed,
+		Object:   raw,
+	}
+	if status := review.Response.Result; status != nil {
+		result.Message = status.Message
+		if status.Details != nil {
+			result.Causes = status.Details.Causes
+		}
+	}
+	if result.Warnings, err = warnings(kind, raw); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// objectKind returns the group, version and kind of the object, which must be the given kind
+// in the stable.agones.dev group. Both are set on the object if they are missing.
+func objectKind(obj map[string]interface{}, kind string) (schema.GroupVersionKind, error) {
+	gvk := v1alpha1.SchemeGroupVersion.WithKind(kind)
+	if apiVersion, ok := obj["apiVersion"].(string); ok && apiVersion != "" {
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			return gvk, errors.Wrapf(err, "invalid apiVersion %s", apiVersion)
+		}
+		if gv.Group != stable.GroupName {
+			return gvk, errors.Errorf("apiVersion %s is not in the %s group", apiVersion, stable.GroupName)
+		}
+		gvk.Version = gv.Version
+	}
+	if k, ok := obj["kind"].(string); ok && k != "" && k != kind {
+		return gvk, errors.Errorf("a %s can not be linted as a %s", k, kind)
+	}
+
+	obj["apiVersion"] = gvk.GroupVersion().String()
+	obj["kind"] = kind
+	return gvk, nil
+}
+
+// setNamespace sets the namespace of the object, which must not be different
+// to the namespace it is being linted in, and returns its name
+func setNamespace(obj map[string]interface{}, namespace string) (string, error) {
+	meta, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		meta = map[string]interface{}{}
+		obj["metadata"] = meta
+	}
+	if ns, ok := meta["namespace"].(string); ok && ns != "" && ns != namespace {
+		return "", errors.Errorf("the namespace of the object (%s) does not match the namespace it is being linted in (%s)", ns, namespace)
+	}
+	meta["namespace"] = namespace
+
+	name, _ := meta["name"].(string)
+	return name, nil
+}
+
+// warnings returns the problems with the GameServer template of the object that would not stop it
+// being accepted, but are likely to cause problems once its GameServers are running
+func warnings(kind string, raw []byte) ([]string, error) {
+	var spec *v1alpha1.GameServerSpec
+	replicated := true
+	switch kind {
+	case "GameServer":
+		gs := &v1alpha1.GameServer{}
+		if err := json.Unmarshal(raw, gs); err != nil {
+			return nil, errors.Wrap(err, "error unmarshalling GameServer json")
+		}
+		spec = &gs.Spec
+		replicated = false
+	case "GameServerSet":
+		gsSet := &v1alpha1.GameServerSet{}
+		if err := json.Unmarshal(raw, gsSet); err != nil {
+			return nil, errors.Wrap(err, "error unmarshalling GameServerSet json")
+		}
+		spec = &gsSet.Spec.Template.Spec
+	case "Fleet":
+		fleet := &v1alpha1.Fleet{}
+		if err := json.Unmarshal(raw, fleet); err != nil {
+			return nil, errors.Wrap(err, "error unmarshalling Fleet json")
+		}
+		spec = &fleet.Spec.Template.Spec
+	default:
+		return nil, errors.Errorf("can not lint a %s", kind)
+	}
+
+	var result []string
+	for _, c := range spec.Template.Spec.Containers {
+		if len(c.Resources.Requests) == 0 {
+			result = append(result, fmt.Sprintf("container %s has no resource requests, so it may be scheduled onto a node that does not have enough cpu or memory for it", c.Name))
+		}
+		if len(c.Resources.Limits) == 0 {
+			result = append(result, fmt.Sprintf("container %s has no resource limits, so it can use all of the cpu and memory of its node", c.Name))
+		}
+		for _, p := range c.Ports {
+			if p.HostPort > 0 {
+				result = append(result, fmt.Sprintf("container %s sets hostPort %d on its Pod template, which is not managed by the port policies of the GameServer, and may conflict with the ports that are allocated to other GameServers", c.Name, p.HostPort))
+			}
+		}
+	}
+	if replicated {
+		for _, p := range spec.Ports {
+			if p.PortPolicy == v1alpha1.Static {
+				result = append(result, fmt.Sprintf("port %s has a Static port policy, so only one GameServer of this template can run on each node", p.Name))
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"agones.dev/agones/pkg/util/apiserver"
+	"agones.dev/agones/pkg/util/webhooks"
+	"github.com/mattbaird/jsonpatch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const gameServerYAML = `
+apiVersion: "stable.agones.dev/v1alpha1"
+kind: GameServer
+metadata:
+  name: simple-udp
+spec:
+  ports:
+  - name: default
+    containerPort: 7654
+  template:
+    spec:
+      containers:
+      - name: simple-udp
+        image: gcr.io/agones-images/udp-server:0.17
+        ports:
+        - containerPort: 7654
+          hostPort: 7654
+`
+
+// newFakeLinter returns a Linter with a mutating webhook handler that defaults the port policy
+// of GameServers, and a validating one that rejects GameServers with a hostPort
+func newFakeLinter(t *testing.T) (*Linter, *http.ServeMux) {
+	mux := http.NewServeMux()
+	wh := webhooks.NewWebHook(mux)
+	l := NewLinter(wh, apiserver.NewAPIServer(mux))
+
+	wh.AddHandler("/mutate", v1alpha1.Kind("GameServer"), admv1beta1.Create, func(review admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error) {
+		patch, err := json.Marshal([]jsonpatch.JsonPatchOperation{
+			jsonpatch.NewPatch("add", "/spec/ports/0/portPolicy", "Dynamic"),
+			jsonpatch.NewPatch("add", "/spec/scheduling", "Packed"),
+		})
+		require.NoError(t, err)
+		review.Response.Patch = patch
+		return review, nil
+	})
+	wh.AddHandler("/validate", v1alpha1.Kind("GameServer"), admv1beta1.Create, func(review admv1beta1.AdmissionReview) (admv1beta1.AdmissionReview, error) {
+		gs := &v1alpha1.GameServer{}
+		require.NoError(t, json.Unmarshal(review.Request.Object.Raw, gs))
+		assert.Equal(t, v1alpha1.Dynamic, gs.Spec.Ports[0].PortPolicy)
+		if gs.Spec.Ports[0].HostPort > 0 {
+			review.Response.Allowed = false
+			review.Response.Result = &metav1.Status{Message: "GameServer configuration is invalid",
+				Details: &metav1.StatusDetails{Causes: []metav1.StatusCause{{Field: "default", Message: v1alpha1.ErrHostPortDynamic}}}}
+		}
+		return review, nil
+	})
+
+	return l, mux
+}
+
+func TestLinterLint(t *testing.T) {
+	t.Parallel()
+
+	l, _ := newFakeLinter(t)
+
+	review, err := l.lint("gameservers", "GameServer", "default", []byte(gameServerYAML))
+	require.NoError(t, err)
+	assert.Equal(t, ReviewKind, review.Kind)
+	assert.Equal(t, "lint.agones.dev/v1", review.APIVersion)
+	assert.True(t, review.Allowed)
+	assert.Empty(t, review.Causes)
+	assert.Equal(t, []string{
+		"container simple-udp has no resource requests, so it may be scheduled onto a node that does not have enough cpu or memory for it",
+		"container simple-udp has no resource limits, so it can use all of the cpu and memory of its node",
+		"container simple-udp sets hostPort 7654 on its Pod template, which is not managed by the port policies of the GameServer, and may conflict with the ports that are allocated to other GameServers",
+	}, review.Warnings)
+
+	gs := &v1alpha1.GameServer{}
+	require.NoError(t, json.Unmarshal(review.Object, gs))
+	assert.Equal(t, "default", gs.ObjectMeta.Namespace)
+	assert.Equal(t, v1alpha1.Dynamic, gs.Spec.Ports[0].PortPolicy)
+	assert.Equal(t, "Packed", string(gs.Spec.Scheduling))
+
+	// invalid, with the kind and apiVersion left out
+	review, err = l.lint("gameservers", "GameServer", "default",
+		[]byte(`{"metadata": {"name": "gs"}, "spec": {"ports": [{"name": "default", "hostPort": 7000, "containerPort": 7654}]}}`))
+	require.NoError(t, err)
+	assert.False(t, review.Allowed)
+	assert.Equal(t, "GameServer configuration is invalid", review.Message)
+	assert.Equal(t, []metav1.StatusCause{{Field: "default", Message: v1alpha1.ErrHostPortDynamic}}, review.Causes)
+
+	_, err = l.lint("gameservers", "GameServer", "default", []byte(`{"kind": "Fleet"}`))
+	assert.EqualError(t, err, "a Fleet can not be linted as a GameServer")
+	_, err = l.lint("gameservers", "GameServer", "default", []byte(`{"apiVersion": "v1"}`))
+	assert.EqualError(t, err, "apiVersion v1 is not in the stable.agones.dev group")
+	_, err = l.lint("gameservers", "GameServer", "default", []byte(`{"metadata": {"namespace": "other"}}`))
+	assert.EqualError(t, err, "the namespace of the object (other) does not match the namespace it is being linted in (default)")
+}
+
+func TestLinterLintHandler(t *testing.T) {
+	t.Parallel()
+
+	_, mux := newFakeLinter(t)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	url := ts.URL + "/apis/lint.agones.dev/v1/namespaces/default/gameservers"
+	resp, err := http.Post(url, "application/yaml", bytes.NewBufferString(gameServerYAML))
+	require.NoError(t, err)
+	defer resp.Body.Close() // nolint: errcheck
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	review := &Review{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(review))
+	assert.True(t, review.Allowed)
+	assert.Len(t, review.Warnings, 3)
+
+	resp, err = http.Post(url, "application/json", bytes.NewBufferString(`{"kind": "Fleet"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close() // nolint: errcheck
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close() // nolint: errcheck
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestWarnings(t *testing.T) {
+	t.Parallel()
+
+	fleet := &v1alpha1.Fleet{Spec: v1alpha1.FleetSpec{Template: v1alpha1.GameServerTemplateSpec{Spec: v1alpha1.GameServerSpec{
+		Ports: []v1alpha1.GameServerPort{{Name: "static", PortPolicy: v1alpha1.Static, HostPort: 7000}, {Name: "dynamic", PortPolicy: v1alpha1.Dynamic}},
+	}}}}
+	raw, err := json.Marshal(fleet)
+	require.NoError(t, err)
+
+	result, err := warnings("Fleet", raw)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"port static has a Static port policy, so only one GameServer of this template can run on each node"}, result)
+
+	// a single GameServer with a static port is fine
+	gs := &v1alpha1.GameServer{Spec: fleet.Spec.Template.Spec}
+	raw, err = json.Marshal(gs)
+	require.NoError(t, err)
+	result, err = warnings("GameServer", raw)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestApplyPatch(t *testing.T) {
+	t.Parallel()
+
+	doc := []byte(`{"a": {"b": 1, "c~/d": 2}, "list": [1, 2, 3]}`)
+	patch := []byte(`[
+		{"op": "replace", "path": "/a/b", "value": 5},
+		{"op": "remove", "path": "/a/c~0~1d"},
+		{"op": "add", "path": "/list/1", "value": 9},
+		{"op": "remove", "path": "/list/3"},
+		{"op": "add", "path": "/list/-", "value": 4},
+		{"op": "add", "path": "/e", "value": {"f": true}}
+	]`)
+
+	result, err := applyPatch(doc, patch)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a": {"b": 5}, "list": [1, 9, 2, 4], "e": {"f": true}}`, string(result))
+
+	_, err = applyPatch(doc, []byte(`[{"op": "add", "path": "/missing/b", "value": 1}]`))
+	assert.EqualError(t, err, "json patch path /missing/b does not exist")
+	_, err = applyPatch(doc, []byte(`[{"op": "replace", "path": "/list/3", "value": 1}]`))
+	assert.EqualError(t, err, "json patch path /list/3 does not exist")
+	_, err = applyPatch(doc, []byte(`[{"op": "move", "path": "/a/b"}]`))
+	assert.EqualError(t, err, "unsupported json patch operation move")
+}
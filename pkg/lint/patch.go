@@ -0,0 +1,131 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/mattbaird/jsonpatch"
+	"github.com/pkg/errors"
+)
+
+// applyPatch applies the JSON patch that a mutating webhook handler returned to the
+// JSON document it reviewed, as the Kubernetes API server would before validating it
+func applyPatch(doc, patch []byte) ([]byte, error) {
+	var ops []jsonpatch.JsonPatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling json patch")
+	}
+
+	var root interface{}
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling json document")
+	}
+
+	for _, op := range ops {
+		var err error
+		root, err = applyOperation(root, splitPointer(op.Path), op)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(root)
+}
+
+// splitPointer splits a JSON pointer, such as /spec/ports/0, into its unescaped tokens
+func splitPointer(pointer string) []string {
+	if pointer == "" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens
+}
+
+// applyOperation applies the operation at the path below node, and returns the updated node
+func applyOperation(node interface{}, path []string, op jsonpatch.JsonPatchOperation) (interface{}, error) {
+	if len(path) == 0 {
+		switch op.Operation {
+		case "add", "replace":
+			return op.Value, nil
+		case "remove":
+			return nil, nil
+		}
+		return nil, errors.Errorf("unsupported json patch operation %s", op.Operation)
+	}
+
+	key := path[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			switch op.Operation {
+			case "add", "replace":
+				n[key] = op.Value
+			case "remove":
+				delete(n, key)
+			default:
+				return nil, errors.Errorf("unsupported json patch operation %s", op.Operation)
+			}
+			return n, nil
+		}
+		child, ok := n[key]
+		if !ok {
+			return nil, errors.Errorf("json patch path %s does not exist", op.Path)
+		}
+		child, err := applyOperation(child, path[1:], op)
+		if err != nil {
+			return nil, err
+		}
+		n[key] = child
+		return n, nil
+
+	case []interface{}:
+		if key == "-" && len(path) == 1 && op.Operation == "add" {
+			return append(n, op.Value), nil
+		}
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i > len(n) || (i == len(n) && (len(path) > 1 || op.Operation != "add")) {
+			return nil, errors.Errorf("json patch path %s does not exist", op.Path)
+		}
+		if len(path) == 1 {
+			switch op.Operation {
+			case "add":
+				n = append(n, nil)
+				copy(n[i+1:], n[i:])
+				n[i] = op.Value
+			case "replace":
+				n[i] = op.Value
+			case "remove":
+				n = append(n[:i], n[i+1:]...)
+			default:
+				return nil, errors.Errorf("unsupported json patch operation %s", op.Operation)
+			}
+			return n, nil
+		}
+		child, err := applyOperation(n[i], path[1:], op)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = child
+		return n, nil
+	}
+
+	return nil, errors.Errorf("json patch path %s does not exist", op.Path)
+}
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
+	multiclusterv1alpha1 "agones.dev/agones/pkg/apis/multicluster/v1alpha1"
 	stablev1alpha1 "agones.dev/agones/pkg/apis/stable/v1alpha1"
 	"agones.dev/agones/pkg/client/clientset/versioned"
 	"agones.dev/agones/pkg/client/informers/externalversions"
@@ -59,6 +60,7 @@ type Controller struct {
 	fleetSynced      cache.InformerSynced
 	fasSynced        cache.InformerSynced
 	nodeSynced       cache.InformerSynced
+	policySynced     cache.InformerSynced
 	lock             sync.Mutex
 	gsCount          GameServerCount
 	faCount          map[string]int64
@@ -80,6 +82,7 @@ func NewController(
 	fasInformer := fas.Informer()
 	node := kubeInformerFactory.Core().V1().Nodes()
 	nodeInformer := node.Informer()
+	policyInformer := agonesInformerFactory.Multicluster().V1alpha1().GameServerAllocationPolicies().Informer()
 
 	c := &Controller{
 		gameServerLister: gameServer.Lister(),
@@ -88,6 +91,7 @@ func NewController(
 		fleetSynced:      fInformer.HasSynced,
 		fasSynced:        fasInformer.HasSynced,
 		nodeSynced:       nodeInformer.HasSynced,
+		policySynced:     policyInformer.HasSynced,
 		gsCount:          GameServerCount{},
 		faCount:          map[string]int64{},
 	}
@@ -110,6 +114,14 @@ func NewController(
 		DeleteFunc: c.recordFleetAutoScalerDeletion,
 	})
 
+	policyInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(added interface{}) {
+			c.recordAllocationPolicyChanges(nil, added)
+		},
+		UpdateFunc: c.recordAllocationPolicyChanges,
+		DeleteFunc: c.recordAllocationPolicyDeletion,
+	})
+
 	gsInformer.AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
 		UpdateFunc: c.recordGameServerStatusChanges,
 	}, 0)
@@ -157,18 +169,22 @@ func (c *Controller) recordFleetAutoScalerChanges(old, new interface{}) {
 		fasAbleToScaleStats.M(int64(ableToScale)),
 		fasLimitedStats.M(int64(limited)))
 
-	// recording buffer policy
-	if fas.Spec.Policy.Buffer != nil {
+	// recording buffer policy, which an allocation failure policy has too
+	buffer := fas.Spec.Policy.Buffer
+	if a := fas.Spec.Policy.AllocationFailure; a != nil {
+		buffer = &a.Buffer
+	}
+	if buffer != nil {
 		// recording limits
 		recordWithTags(ctx, []tag.Mutator{tag.Upsert(keyType, "max")},
-			fasBufferLimitsCountStats.M(int64(fas.Spec.Policy.Buffer.MaxReplicas)))
+			fasBufferLimitsCountStats.M(int64(buffer.MaxReplicas)))
 		recordWithTags(ctx, []tag.Mutator{tag.Upsert(keyType, "min")},
-			fasBufferLimitsCountStats.M(int64(fas.Spec.Policy.Buffer.MinReplicas)))
+			fasBufferLimitsCountStats.M(int64(buffer.MinReplicas)))
 
 		// recording size
-		if fas.Spec.Policy.Buffer.BufferSize.Type == intstr.String {
+		if buffer.BufferSize.Type == intstr.String {
 			// as percentage
-			sizeString := fas.Spec.Policy.Buffer.BufferSize.StrVal
+			sizeString := buffer.BufferSize.StrVal
 			if sizeString != "" {
 				if size, err := strconv.Atoi(sizeString[:len(sizeString)-1]); err == nil {
 					recordWithTags(ctx, []tag.Mutator{tag.Upsert(keyType, "percentage")},
@@ -178,7 +194,7 @@ func (c *Controller) recordFleetAutoScalerChanges(old, new interface{}) {
 		} else {
 			// as count
 			recordWithTags(ctx, []tag.Mutator{tag.Upsert(keyType, "count")},
-				fasBufferSizeStats.M(int64(fas.Spec.Policy.Buffer.BufferSize.IntVal)))
+				fasBufferSizeStats.M(int64(buffer.BufferSize.IntVal)))
 		}
 	}
 }
@@ -213,6 +229,10 @@ func (c *Controller) recordFleetChanges(obj interface{}) {
 
 	c.recordFleetReplicas(f.Name, f.Status.Replicas, f.Status.AllocatedReplicas,
 		f.Status.ReadyReplicas, f.Spec.Replicas)
+
+	estimates := f.ResourceEstimates()
+	c.recordFleetRequests(f.Name, estimates.Cpu().MilliValue()*int64(f.Status.Replicas),
+		estimates.Memory().Value()*int64(f.Status.Replicas))
 }
 
 func (c *Controller) recordFleetDeletion(obj interface{}) {
@@ -222,6 +242,7 @@ func (c *Controller) recordFleetDeletion(obj interface{}) {
 	}
 
 	c.recordFleetReplicas(f.Name, 0, 0, 0, 0)
+	c.recordFleetRequests(f.Name, 0, 0)
 }
 
 func (c *Controller) recordFleetReplicas(fleetName string, total, allocated, ready, desired int32) {
@@ -238,6 +259,95 @@ func (c *Controller) recordFleetReplicas(fleetName string, total, allocated, rea
 		fleetsReplicasCountStats.M(int64(desired)))
 }
 
+// recordFleetRequests records the estimated resource requests of all the
+// replicas of a fleet, with cpu in millicores and memory in bytes
+func (c *Controller) recordFleetRequests(fleetName string, cpu, memory int64) {
+	ctx, _ := tag.New(context.Background(), tag.Upsert(keyName, fleetName))
+
+	stats.Record(ctx, fleetsRequestedCPUStats.M(cpu), fleetsRequestedMemStats.M(memory))
+}
+
+func (c *Controller) recordAllocationPolicyChanges(old, new interface{}) {
+	policy, ok := new.(*multiclusterv1alpha1.GameServerAllocationPolicy)
+	if !ok {
+		return
+	}
+
+	// if the cluster changes, we need to reset the metrics for the old cluster.
+	if old != nil {
+		if oldPolicy, ok := old.(*multiclusterv1alpha1.GameServerAllocationPolicy); ok &&
+			oldPolicy.Spec.ConnectionInfo.ClusterName != policy.Spec.ConnectionInfo.ClusterName {
+			c.recordAllocationPolicyDeletion(old)
+		}
+	}
+
+	if policy.DeletionTimestamp != nil {
+		c.recordAllocationPolicyDeletion(policy)
+		return
+	}
+
+	c.recordAllocationPolicy(policy, int64(policy.Spec.Weight), int64(policy.Spec.Priority))
+}
+
+func (c *Controller) recordAllocationPolicyDeletion(obj interface{}) {
+	policy, ok := obj.(*multiclusterv1alpha1.GameServerAllocationPolicy)
+	if !ok {
+		return
+	}
+
+	c.recordAllocationPolicy(policy, 0, 0)
+}
+
+func (c *Controller) recordAllocationPolicy(policy *multiclusterv1alpha1.GameServerAllocationPolicy, weight, priority int64) {
+	ctx, _ := tag.New(context.Background(), tag.Upsert(keyName, policy.Name),
+		tag.Upsert(keyNamespace, policy.Namespace), tag.Upsert(keyCluster, policy.Spec.ConnectionInfo.ClusterName))
+
+	stats.Record(ctx, policyWeightStats.M(weight), policyPriorityStats.M(priority))
+}
+
+// RecordMultiClusterAllocation records an allocation attempt against a cluster by the multi-cluster allocator,
+// and whether it was successful, so the traffic split across clusters can be compared to the allocation policies.
+func RecordMultiClusterAllocation(clusterName string, success bool) {
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	recordWithTags(context.Background(), []tag.Mutator{tag.Upsert(keyCluster, clusterName),
+		tag.Upsert(keyResult, result)}, multiClusterAllocStats.M(1))
+}
+
+// RecordPortAllocatorPorts records the number of allocated and free host ports of a port range of the port allocator,
+// across all of its nodes, as well as the number of free ports of the port range on each node.
+func RecordPortAllocatorPorts(portRange string, allocated int64, freePerNode []int64) {
+	var free int64
+	for _, f := range freePerNode {
+		free += f
+	}
+	ctx, _ := tag.New(context.Background(), tag.Upsert(keyPortRange, portRange))
+	recordWithTags(ctx, []tag.Mutator{tag.Upsert(keyType, "allocated")}, portAllocatorPortsStats.M(allocated))
+	recordWithTags(ctx, []tag.Mutator{tag.Upsert(keyType, "free")}, portAllocatorPortsStats.M(free))
+	for _, f := range freePerNode {
+		stats.Record(ctx, portAllocatorNodeStats.M(f))
+	}
+}
+
+// RecordPortAllocationFailure records that ports could not be allocated from a port range, and why,
+// such as the current nodes having run out of free ports.
+func RecordPortAllocationFailure(portRange, reason string) {
+	recordWithTags(context.Background(), []tag.Mutator{tag.Upsert(keyPortRange, portRange),
+		tag.Upsert(keyReason, reason)}, portAllocatorFailureStats.M(1))
+}
+
+// RecordAllocationCacheCount records the number of GameServers of a fleet in one of the allocator's caches,
+// such as "ready" or "allocated", which should match the number of GameServers in that state, unless the cache is stale.
+func RecordAllocationCacheCount(cacheType, fleetName string, count int64) {
+	if fleetName == "" {
+		fleetName = "none"
+	}
+	recordWithTags(context.Background(), []tag.Mutator{tag.Upsert(keyType, cacheType),
+		tag.Upsert(keyFleetName, fleetName)}, allocationCacheStats.M(count))
+}
+
 // recordGameServerStatusChanged records gameserver status changes, however since it's based
 // on cache events some events might collapsed and not appear, for example transition state
 // like creating, port allocation, could be skipped.
@@ -269,7 +379,7 @@ func (c *Controller) recordGameServerStatusChanges(old, new interface{}) {
 // Collect metrics via cache changes and parse the cache periodically to record resource counts.
 func (c *Controller) Run(workers int, stop <-chan struct{}) error {
 	c.logger.Info("Wait for cache sync")
-	if !cache.WaitForCacheSync(stop, c.gameServerSynced, c.fleetSynced, c.fasSynced) {
+	if !cache.WaitForCacheSync(stop, c.gameServerSynced, c.fleetSynced, c.fasSynced, c.policySynced) {
 		return errors.New("failed to wait for caches to sync")
 	}
 	wait.Until(c.collect, MetricResyncPeriod, stop)
@@ -303,6 +413,8 @@ func (c *Controller) collectGameServerCounts() {
 // collectNodeCounts count gameservers per node using informer cache.
 func (c *Controller) collectNodeCounts() {
 	gsPerNodes := map[string]int32{}
+	// the counts of each fleet, per state, per node
+	gsPerNodeStates := map[string]map[stablev1alpha1.GameServerState]map[string]int64{}
 
 	gameservers, err := c.gameServerLister.List(labels.Everything())
 	if err != nil {
@@ -313,6 +425,21 @@ func (c *Controller) collectNodeCounts() {
 		if gs.Status.NodeName != "" {
 			gsPerNodes[gs.Status.NodeName]++
 		}
+
+		fleetName := gs.ObjectMeta.Labels[stablev1alpha1.FleetNameLabel]
+		if fleetName == "" {
+			fleetName = "none"
+		}
+		if _, ok := gsPerNodeStates[fleetName]; !ok {
+			gsPerNodeStates[fleetName] = map[stablev1alpha1.GameServerState]map[string]int64{
+				stablev1alpha1.GameServerStateReady:     {},
+				stablev1alpha1.GameServerStateAllocated: {},
+				stablev1alpha1.GameServerStateReserved:  {},
+			}
+		}
+		if perNode, ok := gsPerNodeStates[fleetName][gs.Status.State]; ok && gs.Status.NodeName != "" {
+			perNode[gs.Status.NodeName]++
+		}
 	}
 
 	nodes, err := c.nodeLister.List(labels.Everything())
@@ -330,6 +457,17 @@ func (c *Controller) collectNodeCounts() {
 	for _, node := range nodes {
 		stats.Record(context.Background(), gsPerNodesCountStats.M(int64(gsPerNodes[node.Name])))
 	}
+
+	// nodes without gameservers of a fleet in a state are recorded as zero,
+	// so that the distribution covers every node, like gameservers_node_count
+	for fleetName, states := range gsPerNodeStates {
+		for state, perNode := range states {
+			tags := []tag.Mutator{tag.Upsert(keyType, string(state)), tag.Upsert(keyFleetName, fleetName)}
+			for _, node := range nodes {
+				recordWithTags(context.Background(), tags, gsPerNodesStateCountStats.M(perNode[node.Name]))
+			}
+		}
+	}
 }
 
 func removeSystemNodes(nodes []*corev1.Node) []*corev1.Node {
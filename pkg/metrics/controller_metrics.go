@@ -22,6 +22,8 @@ import (
 
 var (
 	fleetsReplicasCountStats  = stats.Int64("fleets/replicas_count", "The count of replicas per fleet", "1")
+	fleetsRequestedCPUStats   = stats.Int64("fleets/requested_cpu", "The estimated CPU requests of the replicas per fleet, in millicores", "1")
+	fleetsRequestedMemStats   = stats.Int64("fleets/requested_memory", "The estimated memory requests of the replicas per fleet", stats.UnitBytes)
 	fasBufferLimitsCountStats = stats.Int64("fas/buffer_limits", "The buffer limits of autoscalers", "1")
 	fasBufferSizeStats        = stats.Int64("fas/buffer_size", "The buffer size value of autoscalers", "1")
 	fasCurrentReplicasStats   = stats.Int64("fas/current_replicas_count", "The current replicas cout as seen by autoscalers", "1")
@@ -32,6 +34,14 @@ var (
 	gameServerTotalStats      = stats.Int64("gameservers/total", "The total of gameservers", "1")
 	nodesCountStats           = stats.Int64("nodes/count", "The count of nodes in the cluster", "1")
 	gsPerNodesCountStats      = stats.Int64("gameservers_node/count", "The count of gameservers per node in the cluster", "1")
+	gsPerNodesStateCountStats = stats.Int64("gameservers_node/state_count", "The count of gameservers per node in the cluster, per fleet and state", "1")
+	policyWeightStats         = stats.Int64("allocation_policies/weight", "The weight of multi-cluster allocation policies", "1")
+	policyPriorityStats       = stats.Int64("allocation_policies/priority", "The priority of multi-cluster allocation policies", "1")
+	multiClusterAllocStats    = stats.Int64("multicluster_allocations/total", "The total of multi-cluster allocations per cluster", "1")
+	portAllocatorPortsStats   = stats.Int64("port_allocator/ports_count", "The count of allocated and free ports per port range", "1")
+	portAllocatorNodeStats    = stats.Int64("port_allocator_node/free_ports", "The count of free ports per node per port range", "1")
+	portAllocatorFailureStats = stats.Int64("port_allocator/failures_total", "The total of port allocation failures per port range", "1")
+	allocationCacheStats      = stats.Int64("allocation_cache/gameservers_count", "The count of gameservers in the allocation caches", "1")
 
 	stateViews = []*view.View{
 		&view.View{
@@ -41,6 +51,20 @@ var (
 			Aggregation: view.LastValue(),
 			TagKeys:     []tag.Key{keyName, keyType},
 		},
+		&view.View{
+			Name:        "fleets_requested_cpu_millicores",
+			Measure:     fleetsRequestedCPUStats,
+			Description: "The estimated CPU requests of the replicas per fleet, in millicores",
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{keyName},
+		},
+		&view.View{
+			Name:        "fleets_requested_memory_bytes",
+			Measure:     fleetsRequestedMemStats,
+			Description: "The estimated memory requests of the replicas per fleet, in bytes",
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{keyName},
+		},
 		&view.View{
 			Name:        "fleet_autoscalers_buffer_limits",
 			Measure:     fasBufferLimitsCountStats,
@@ -110,6 +134,62 @@ var (
 			Description: "The count of gameservers per node in the cluster",
 			Aggregation: view.Distribution(0.00001, 1.00001, 2.00001, 3.00001, 4.00001, 5.00001, 6.00001, 7.00001, 8.00001, 9.00001, 10.00001, 11.00001, 12.00001, 13.00001, 14.00001, 15.00001, 16.00001, 32.00001, 40.00001, 50.00001, 60.00001, 70.00001, 80.00001, 90.00001, 100.00001, 110.00001, 120.00001),
 		},
+		&view.View{
+			Name:        "gameservers_node_state_count",
+			Measure:     gsPerNodesStateCountStats,
+			Description: "The count of gameservers per node in the cluster, per fleet and state",
+			Aggregation: view.Distribution(0.00001, 1.00001, 2.00001, 3.00001, 4.00001, 5.00001, 6.00001, 7.00001, 8.00001, 9.00001, 10.00001, 11.00001, 12.00001, 13.00001, 14.00001, 15.00001, 16.00001, 32.00001, 40.00001, 50.00001, 60.00001, 70.00001, 80.00001, 90.00001, 100.00001, 110.00001, 120.00001),
+			TagKeys:     []tag.Key{keyType, keyFleetName},
+		},
+		&view.View{
+			Name:        "allocation_policies_weight",
+			Measure:     policyWeightStats,
+			Description: "The weight of multi-cluster allocation policies",
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{keyName, keyNamespace, keyCluster},
+		},
+		&view.View{
+			Name:        "allocation_policies_priority",
+			Measure:     policyPriorityStats,
+			Description: "The priority of multi-cluster allocation policies",
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{keyName, keyNamespace, keyCluster},
+		},
+		&view.View{
+			Name:        "multicluster_allocations_total",
+			Measure:     multiClusterAllocStats,
+			Description: "The total of multi-cluster allocations per cluster, and whether they succeeded",
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{keyCluster, keyResult},
+		},
+		&view.View{
+			Name:        "port_allocator_ports_count",
+			Measure:     portAllocatorPortsStats,
+			Description: "The number of allocated and free host ports per port range, across all nodes",
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{keyPortRange, keyType},
+		},
+		&view.View{
+			Name:        "port_allocator_node_free_ports",
+			Measure:     portAllocatorNodeStats,
+			Description: "The number of free host ports per node per port range",
+			Aggregation: view.Distribution(0.00001, 1.00001, 2.00001, 5.00001, 10.00001, 20.00001, 50.00001, 100.00001, 200.00001, 500.00001, 1000.00001, 2000.00001, 5000.00001),
+			TagKeys:     []tag.Key{keyPortRange},
+		},
+		&view.View{
+			Name:        "port_allocator_failures_total",
+			Measure:     portAllocatorFailureStats,
+			Description: "The total of port allocations that could not be satisfied by the current nodes, or at all, per port range",
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{keyPortRange, keyReason},
+		},
+		&view.View{
+			Name:        "allocation_cache_gameservers_count",
+			Measure:     allocationCacheStats,
+			Description: "The number of gameservers per fleet in the Ready and Allocated caches of the allocator",
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{keyType, keyFleetName},
+		},
 	}
 )
 
@@ -20,10 +20,14 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"go.opencensus.io/metric/metricdata"
 	"go.opencensus.io/metric/metricexport"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
 	"github.com/pkg/errors"
@@ -207,6 +211,43 @@ func TestControllerFleetReplicasCount(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestControllerFleetRequestedResources(t *testing.T) {
+
+	resetMetrics()
+	exporter := &metricExporter{}
+	reader := metricexport.NewReader()
+	c := newFakeController()
+	defer c.close()
+	c.run(t)
+
+	f := fleet("fleet-test", 4, 0, 4, 4)
+	f.ObjectMeta.Annotations = map[string]string{
+		v1alpha1.FleetCPURequestAnnotation:    "250m",
+		v1alpha1.FleetMemoryRequestAnnotation: "64Mi",
+	}
+	fd := fleet("fleet-deleted", 10, 0, 10, 10)
+	fd.Spec.Template.Spec.Template.Spec.Containers = []corev1.Container{{
+		Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}},
+	}}
+	c.fleetWatch.Add(f)
+	c.fleetWatch.Add(fd)
+	c.fleetWatch.Delete(fd)
+
+	c.sync()
+
+	reader.ReadAndExport(exporter)
+	err := verifyMetricData(exporter, "fleets_requested_cpu_millicores", []expectedMetricData{
+		{labels: []string{"fleet-deleted"}, val: int64(0)},
+		{labels: []string{"fleet-test"}, val: int64(1000)},
+	})
+	assert.Nil(t, err)
+	err = verifyMetricData(exporter, "fleets_requested_memory_bytes", []expectedMetricData{
+		{labels: []string{"fleet-deleted"}, val: int64(0)},
+		{labels: []string{"fleet-test"}, val: int64(4 * 64 * 1024 * 1024)},
+	})
+	assert.Nil(t, err)
+}
+
 func TestControllerFleetAutoScalerState(t *testing.T) {
 	resetMetrics()
 	exporter := &metricExporter{}
@@ -281,6 +322,140 @@ func TestControllerFleetAutoScalerState(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestControllerAllocationPolicyState(t *testing.T) {
+	resetMetrics()
+	exporter := &metricExporter{}
+	reader := metricexport.NewReader()
+	c := newFakeController()
+	defer c.close()
+	c.run(t)
+
+	policy := allocationPolicy("policy", "cluster-a", 1, 100)
+	c.policyWatch.Add(policy)
+	policy = policy.DeepCopy()
+	policy.Spec.Weight = 50
+	c.policyWatch.Modify(policy)
+	// testing cluster change
+	policy = policy.DeepCopy()
+	policy.Spec.ConnectionInfo.ClusterName = "cluster-b"
+	c.policyWatch.Modify(policy)
+	// testing deletion
+	deleted := allocationPolicy("deleted", "cluster-c", 2, 10)
+	c.policyWatch.Add(deleted)
+	c.policyWatch.Delete(deleted)
+	// a policy with the same name in another namespace is recorded separately
+	other := allocationPolicy("policy", "cluster-b", 3, 30)
+	other.Namespace = "other"
+	c.policyWatch.Add(other)
+
+	c.sync()
+
+	reader.ReadAndExport(exporter)
+	err := verifyMetricData(exporter, "allocation_policies_weight", []expectedMetricData{
+		{labels: []string{"cluster-a", "policy", "default"}, val: int64(0)},
+		{labels: []string{"cluster-b", "policy", "default"}, val: int64(50)},
+		{labels: []string{"cluster-b", "policy", "other"}, val: int64(30)},
+		{labels: []string{"cluster-c", "deleted", "default"}, val: int64(0)},
+	})
+	assert.Nil(t, err)
+	err = verifyMetricData(exporter, "allocation_policies_priority", []expectedMetricData{
+		{labels: []string{"cluster-a", "policy", "default"}, val: int64(0)},
+		{labels: []string{"cluster-b", "policy", "default"}, val: int64(1)},
+		{labels: []string{"cluster-b", "policy", "other"}, val: int64(3)},
+		{labels: []string{"cluster-c", "deleted", "default"}, val: int64(0)},
+	})
+	assert.Nil(t, err)
+}
+
+func TestRecordMultiClusterAllocation(t *testing.T) {
+	resetMetrics()
+	exporter := &metricExporter{}
+	reader := metricexport.NewReader()
+
+	RecordMultiClusterAllocation("cluster-a", true)
+	RecordMultiClusterAllocation("cluster-a", true)
+	RecordMultiClusterAllocation("cluster-a", false)
+	RecordMultiClusterAllocation("cluster-b", true)
+
+	// recording is asynchronous, so wait for it to be processed
+	err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		reader.ReadAndExport(exporter)
+		return verifyMetricData(exporter, "multicluster_allocations_total", []expectedMetricData{
+			{labels: []string{"cluster-a", "success"}, val: int64(2)},
+			{labels: []string{"cluster-a", "failure"}, val: int64(1)},
+			{labels: []string{"cluster-b", "success"}, val: int64(1)},
+		}) == nil, nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestRecordPortAllocatorPorts(t *testing.T) {
+	resetMetrics()
+	exporter := &metricExporter{}
+	reader := metricexport.NewReader()
+
+	RecordPortAllocatorPorts("default", 3, []int64{0, 7})
+
+	err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		reader.ReadAndExport(exporter)
+		return verifyMetricData(exporter, "port_allocator_ports_count", []expectedMetricData{
+			{labels: []string{"default", "allocated"}, val: int64(3)},
+			{labels: []string{"default", "free"}, val: int64(7)},
+		}) == nil, nil
+	})
+	assert.Nil(t, err)
+
+	err = verifyMetricData(exporter, "port_allocator_node_free_ports", []expectedMetricData{
+		{labels: []string{"default"}, val: &metricdata.Distribution{
+			Count:                 2,
+			Sum:                   7,
+			SumOfSquaredDeviation: 24.5,
+			BucketOptions:         &metricdata.BucketOptions{Bounds: []float64{0.00001, 1.00001, 2.00001, 5.00001, 10.00001, 20.00001, 50.00001, 100.00001, 200.00001, 500.00001, 1000.00001, 2000.00001, 5000.00001}},
+			Buckets:               []metricdata.Bucket{{Count: 1}, {Count: 0}, {Count: 0}, {Count: 0}, {Count: 1}, {Count: 0}, {Count: 0}, {Count: 0}, {Count: 0}, {Count: 0}, {Count: 0}, {Count: 0}, {Count: 0}, {Count: 0}}}},
+	})
+	assert.Nil(t, err)
+}
+
+func TestRecordAllocationCacheCount(t *testing.T) {
+	resetMetrics()
+	exporter := &metricExporter{}
+	reader := metricexport.NewReader()
+
+	RecordAllocationCacheCount("ready", "fleet-a", 3)
+	RecordAllocationCacheCount("allocated", "fleet-a", 2)
+	RecordAllocationCacheCount("ready", "", 1)
+	RecordAllocationCacheCount("ready", "fleet-a", 4)
+
+	err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		reader.ReadAndExport(exporter)
+		return verifyMetricData(exporter, "allocation_cache_gameservers_count", []expectedMetricData{
+			{labels: []string{"fleet-a", "allocated"}, val: int64(2)},
+			{labels: []string{"fleet-a", "ready"}, val: int64(4)},
+			{labels: []string{"none", "ready"}, val: int64(1)},
+		}) == nil, nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestRecordPortAllocationFailure(t *testing.T) {
+	resetMetrics()
+	exporter := &metricExporter{}
+	reader := metricexport.NewReader()
+
+	RecordPortAllocationFailure("default", "exhausted")
+	RecordPortAllocationFailure("default", "exhausted")
+	RecordPortAllocationFailure("query", "not_enough_ports")
+
+	err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		reader.ReadAndExport(exporter)
+		return verifyMetricData(exporter, "port_allocator_failures_total", []expectedMetricData{
+			{labels: []string{"default", "exhausted"}, val: int64(2)},
+			{labels: []string{"query", "not_enough_ports"}, val: int64(1)},
+		}) == nil, nil
+	})
+	assert.Nil(t, err)
+}
+
 func TestControllerGameServersNodeState(t *testing.T) {
 	resetMetrics()
 	c := newFakeController()
@@ -313,3 +488,48 @@ func TestControllerGameServersNodeState(t *testing.T) {
 	})
 	assert.Nil(t, err)
 }
+
+func TestControllerGameServersNodeStateCounts(t *testing.T) {
+	resetMetrics()
+	c := newFakeController()
+	defer c.close()
+	c.nodeWatch.Add(nodeWithName("node1"))
+	c.nodeWatch.Add(nodeWithName("node2"))
+	c.gsWatch.Add(gameServerWithNode("node1"))
+	c.gsWatch.Add(gameServerWithNode("node1"))
+	c.gsWatch.Add(gameServerWithNode("node2"))
+	gs := gameServerWithNode("node2")
+	gs.Status.State = v1alpha1.GameServerStateAllocated
+	c.gsWatch.Add(gs)
+	gs = gameServerWithNode("node1")
+	gs.Status.State = v1alpha1.GameServerStateReserved
+	c.gsWatch.Add(gs)
+
+	c.run(t)
+	c.sync()
+
+	exporter := &metricExporter{}
+	reader := metricexport.NewReader()
+	reader.ReadAndExport(exporter)
+
+	var metric *metricdata.Metric
+	for _, m := range exporter.metrics {
+		if m.Descriptor.Name == "gameservers_node_state_count" {
+			metric = m
+		}
+	}
+	if !assert.NotNil(t, metric) {
+		return
+	}
+
+	sums := map[string]float64{}
+	for _, ts := range metric.TimeSeries {
+		assert.Len(t, ts.LabelValues, 2)
+		assert.Equal(t, "fleet", ts.LabelValues[0].Value)
+		d := ts.Points[0].Value.(*metricdata.Distribution)
+		// one value per node
+		assert.Equal(t, int64(2), d.Count)
+		sums[ts.LabelValues[1].Value] = d.Sum
+	}
+	assert.Equal(t, map[string]float64{"Ready": 3, "Allocated": 1, "Reserved": 1}, sums)
+}
@@ -22,6 +22,7 @@ import (
 	"contrib.go.opencensus.io/exporter/stackdriver"
 	prom "github.com/prometheus/client_golang/prometheus"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
 )
 
 // RegisterPrometheusExporter register a prometheus exporter to OpenCensus with a given prometheus metric registry.
@@ -47,7 +48,7 @@ func RegisterPrometheusExporter(registry *prom.Registry) (http.Handler, error) {
 }
 
 // RegisterStackdriverExporter register a Stackdriver exporter to OpenCensus.
-// It will add Agones metrics into Stackdriver on Google Cloud.
+// It will add Agones metrics, and the traces of api requests, into Stackdriver on Google Cloud.
 func RegisterStackdriverExporter(projectID string) (sd *stackdriver.Exporter, err error) {
 	// Default project will be used
 	sd, err = stackdriver.NewExporter(stackdriver.Options{
@@ -61,6 +62,8 @@ func RegisterStackdriverExporter(projectID string) (sd *stackdriver.Exporter, er
 
 	// Register it as a metrics exporter
 	view.RegisterExporter(sd)
+	// and as a trace exporter
+	trace.RegisterExporter(sd)
 	return
 }
 
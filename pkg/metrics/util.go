@@ -26,12 +26,17 @@ var (
 	logger = runtime.NewLoggerWithSource("metrics")
 
 	keyName       = mustTagKey("name")
+	keyNamespace  = mustTagKey("namespace")
 	keyFleetName  = mustTagKey("fleet_name")
 	keyType       = mustTagKey("type")
 	keyStatusCode = mustTagKey("status_code")
 	keyVerb       = mustTagKey("verb")
 	keyEndpoint   = mustTagKey("endpoint")
 	keyEmpty      = mustTagKey("empty")
+	keyCluster    = mustTagKey("cluster_name")
+	keyResult     = mustTagKey("result")
+	keyPortRange  = mustTagKey("port_range")
+	keyReason     = mustTagKey("reason")
 )
 
 func recordWithTags(ctx context.Context, mutators []tag.Mutator, ms ...stats.Measurement) {
@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
+	multiclusterv1alpha1 "agones.dev/agones/pkg/apis/multicluster/v1alpha1"
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
 	agtesting "agones.dev/agones/pkg/testing"
 	"github.com/stretchr/testify/assert"
@@ -40,23 +41,26 @@ func newFakeController() *fakeController {
 	fasWatch := watch.NewFake()
 	fleetWatch := watch.NewFake()
 	nodeWatch := watch.NewFake()
+	policyWatch := watch.NewFake()
 
 	m.AgonesClient.AddWatchReactor("gameservers", k8stesting.DefaultWatchReactor(gsWatch, nil))
 	m.AgonesClient.AddWatchReactor("fleetautoscalers", k8stesting.DefaultWatchReactor(fasWatch, nil))
 	m.AgonesClient.AddWatchReactor("fleets", k8stesting.DefaultWatchReactor(fleetWatch, nil))
 	m.KubeClient.AddWatchReactor("nodes", k8stesting.DefaultWatchReactor(nodeWatch, nil))
+	m.AgonesClient.AddWatchReactor("gameserverallocationpolicies", k8stesting.DefaultWatchReactor(policyWatch, nil))
 
-	stop, cancel := agtesting.StartInformers(m, c.gameServerSynced, c.fleetSynced, c.fasSynced, c.nodeSynced)
+	stop, cancel := agtesting.StartInformers(m, c.gameServerSynced, c.fleetSynced, c.fasSynced, c.nodeSynced, c.policySynced)
 
 	return &fakeController{
-		Controller: c,
-		Mocks:      m,
-		gsWatch:    gsWatch,
-		fasWatch:   fasWatch,
-		fleetWatch: fleetWatch,
-		nodeWatch:  nodeWatch,
-		cancel:     cancel,
-		stop:       stop,
+		Controller:  c,
+		Mocks:       m,
+		gsWatch:     gsWatch,
+		fasWatch:    fasWatch,
+		fleetWatch:  fleetWatch,
+		nodeWatch:   nodeWatch,
+		policyWatch: policyWatch,
+		cancel:      cancel,
+		stop:        stop,
 	}
 }
 
@@ -75,18 +79,19 @@ func (c *fakeController) run(t *testing.T) {
 }
 
 func (c *fakeController) sync() {
-	cache.WaitForCacheSync(c.stop, c.gameServerSynced, c.fleetSynced, c.fasSynced, c.nodeSynced)
+	cache.WaitForCacheSync(c.stop, c.gameServerSynced, c.fleetSynced, c.fasSynced, c.nodeSynced, c.policySynced)
 }
 
 type fakeController struct {
 	*Controller
 	agtesting.Mocks
-	gsWatch    *watch.FakeWatcher
-	fasWatch   *watch.FakeWatcher
-	fleetWatch *watch.FakeWatcher
-	nodeWatch  *watch.FakeWatcher
-	stop       <-chan struct{}
-	cancel     context.CancelFunc
+	gsWatch     *watch.FakeWatcher
+	fasWatch    *watch.FakeWatcher
+	fleetWatch  *watch.FakeWatcher
+	nodeWatch   *watch.FakeWatcher
+	policyWatch *watch.FakeWatcher
+	stop        <-chan struct{}
+	cancel      context.CancelFunc
 }
 
 func nodeWithName(name string) *v1.Node {
@@ -179,3 +184,20 @@ func fleetAutoScaler(fleetName string, fasName string) *autoscalingv1.FleetAutos
 		},
 	}
 }
+
+func allocationPolicy(name, clusterName string, priority, weight int) *multiclusterv1alpha1.GameServerAllocationPolicy {
+	return &multiclusterv1alpha1.GameServerAllocationPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			UID:       uuid.NewUUID(),
+		},
+		Spec: multiclusterv1alpha1.GameServerAllocationPolicySpec{
+			Priority: priority,
+			Weight:   weight,
+			ConnectionInfo: multiclusterv1alpha1.ClusterConnectionInfo{
+				ClusterName: clusterName,
+			},
+		},
+	}
+}
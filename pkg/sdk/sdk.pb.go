@@ -50,7 +50,7 @@ func (m *Empty) Reset()         { *m = Empty{} }
 func (m *Empty) String() string { return proto.CompactTextString(m) }
 func (*Empty) ProtoMessage()    {}
 func (*Empty) Descriptor() ([]byte, []int) {
-	return fileDescriptor_sdk_c87317298fe478a7, []int{0}
+	return fileDescriptor_sdk_5eb25965361d9688, []int{0}
 }
 func (m *Empty) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Empty.Unmarshal(m, b)
@@ -83,7 +83,7 @@ func (m *KeyValue) Reset()         { *m = KeyValue{} }
 func (m *KeyValue) String() string { return proto.CompactTextString(m) }
 func (*KeyValue) ProtoMessage()    {}
 func (*KeyValue) Descriptor() ([]byte, []int) {
-	return fileDescriptor_sdk_c87317298fe478a7, []int{1}
+	return fileDescriptor_sdk_5eb25965361d9688, []int{1}
 }
 func (m *KeyValue) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_KeyValue.Unmarshal(m, b)
@@ -129,7 +129,7 @@ func (m *Duration) Reset()         { *m = Duration{} }
 func (m *Duration) String() string { return proto.CompactTextString(m) }
 func (*Duration) ProtoMessage()    {}
 func (*Duration) Descriptor() ([]byte, []int) {
-	return fileDescriptor_sdk_c87317298fe478a7, []int{2}
+	return fileDescriptor_sdk_5eb25965361d9688, []int{2}
 }
 func (m *Duration) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Duration.Unmarshal(m, b)
@@ -156,6 +156,233 @@ func (m *Duration) GetSeconds() int64 {
 	return 0
 }
 
+// A named counter, and how high it can go
+type Counter struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Count                int64    `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Capacity             int64    `protobuf:"varint,3,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Counter) Reset()         { *m = Counter{} }
+func (m *Counter) String() string { return proto.CompactTextString(m) }
+func (*Counter) ProtoMessage()    {}
+func (*Counter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_sdk_5eb25965361d9688, []int{3}
+}
+func (m *Counter) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Counter.Unmarshal(m, b)
+}
+func (m *Counter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Counter.Marshal(b, m, deterministic)
+}
+func (dst *Counter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Counter.Merge(dst, src)
+}
+func (m *Counter) XXX_Size() int {
+	return xxx_messageInfo_Counter.Size(m)
+}
+func (m *Counter) XXX_DiscardUnknown() {
+	xxx_messageInfo_Counter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Counter proto.InternalMessageInfo
+
+func (m *Counter) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Counter) GetCount() int64 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *Counter) GetCapacity() int64 {
+	if m != nil {
+		return m.Capacity
+	}
+	return 0
+}
+
+// A named list of values, and how many values it can hold
+type List struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Capacity             int64    `protobuf:"varint,2,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	Values               []string `protobuf:"bytes,3,rep,name=values,proto3" json:"values,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *List) Reset()         { *m = List{} }
+func (m *List) String() string { return proto.CompactTextString(m) }
+func (*List) ProtoMessage()    {}
+func (*List) Descriptor() ([]byte, []int) {
+	return fileDescriptor_sdk_5eb25965361d9688, []int{4}
+}
+func (m *List) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_List.Unmarshal(m, b)
+}
+func (m *List) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_List.Marshal(b, m, deterministic)
+}
+func (dst *List) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_List.Merge(dst, src)
+}
+func (m *List) XXX_Size() int {
+	return xxx_messageInfo_List.Size(m)
+}
+func (m *List) XXX_DiscardUnknown() {
+	xxx_messageInfo_List.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_List proto.InternalMessageInfo
+
+func (m *List) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *List) GetCapacity() int64 {
+	if m != nil {
+		return m.Capacity
+	}
+	return 0
+}
+
+func (m *List) GetValues() []string {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+// The unique identifier of a player
+type PlayerID struct {
+	PlayerID             string   `protobuf:"bytes,1,opt,name=playerID,proto3" json:"playerID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PlayerID) Reset()         { *m = PlayerID{} }
+func (m *PlayerID) String() string { return proto.CompactTextString(m) }
+func (*PlayerID) ProtoMessage()    {}
+func (*PlayerID) Descriptor() ([]byte, []int) {
+	return fileDescriptor_sdk_5eb25965361d9688, []int{5}
+}
+func (m *PlayerID) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PlayerID.Unmarshal(m, b)
+}
+func (m *PlayerID) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PlayerID.Marshal(b, m, deterministic)
+}
+func (dst *PlayerID) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PlayerID.Merge(dst, src)
+}
+func (m *PlayerID) XXX_Size() int {
+	return xxx_messageInfo_PlayerID.Size(m)
+}
+func (m *PlayerID) XXX_DiscardUnknown() {
+	xxx_messageInfo_PlayerID.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PlayerID proto.InternalMessageInfo
+
+func (m *PlayerID) GetPlayerID() string {
+	if m != nil {
+		return m.PlayerID
+	}
+	return ""
+}
+
+// A count of something, e.g. players
+type Count struct {
+	Count                int64    `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Count) Reset()         { *m = Count{} }
+func (m *Count) String() string { return proto.CompactTextString(m) }
+func (*Count) ProtoMessage()    {}
+func (*Count) Descriptor() ([]byte, []int) {
+	return fileDescriptor_sdk_5eb25965361d9688, []int{6}
+}
+func (m *Count) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Count.Unmarshal(m, b)
+}
+func (m *Count) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Count.Marshal(b, m, deterministic)
+}
+func (dst *Count) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Count.Merge(dst, src)
+}
+func (m *Count) XXX_Size() int {
+	return xxx_messageInfo_Count.Size(m)
+}
+func (m *Count) XXX_DiscardUnknown() {
+	xxx_messageInfo_Count.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Count proto.InternalMessageInfo
+
+func (m *Count) GetCount() int64 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+// A boolean result
+type Bool struct {
+	Bool                 bool     `protobuf:"varint,1,opt,name=bool,proto3" json:"bool,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Bool) Reset()         { *m = Bool{} }
+func (m *Bool) String() string { return proto.CompactTextString(m) }
+func (*Bool) ProtoMessage()    {}
+func (*Bool) Descriptor() ([]byte, []int) {
+	return fileDescriptor_sdk_5eb25965361d9688, []int{7}
+}
+func (m *Bool) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Bool.Unmarshal(m, b)
+}
+func (m *Bool) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Bool.Marshal(b, m, deterministic)
+}
+func (dst *Bool) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Bool.Merge(dst, src)
+}
+func (m *Bool) XXX_Size() int {
+	return xxx_messageInfo_Bool.Size(m)
+}
+func (m *Bool) XXX_DiscardUnknown() {
+	xxx_messageInfo_Bool.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Bool proto.InternalMessageInfo
+
+func (m *Bool) GetBool() bool {
+	if m != nil {
+		return m.Bool
+	}
+	return false
+}
+
 // A GameServer Custom Resource Definition object
 // We will only export those resources that make the most
 // sense. Can always expand to more as needed.
@@ -172,7 +399,7 @@ func (m *GameServer) Reset()         { *m = GameServer{} }
 func (m *GameServer) String() string { return proto.CompactTextString(m) }
 func (*GameServer) ProtoMessage()    {}
 func (*GameServer) Descriptor() ([]byte, []int) {
-	return fileDescriptor_sdk_c87317298fe478a7, []int{3}
+	return fileDescriptor_sdk_5eb25965361d9688, []int{8}
 }
 func (m *GameServer) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_GameServer.Unmarshal(m, b)
@@ -235,7 +462,7 @@ func (m *GameServer_ObjectMeta) Reset()         { *m = GameServer_ObjectMeta{} }
 func (m *GameServer_ObjectMeta) String() string { return proto.CompactTextString(m) }
 func (*GameServer_ObjectMeta) ProtoMessage()    {}
 func (*GameServer_ObjectMeta) Descriptor() ([]byte, []int) {
-	return fileDescriptor_sdk_c87317298fe478a7, []int{3, 0}
+	return fileDescriptor_sdk_5eb25965361d9688, []int{8, 0}
 }
 func (m *GameServer_ObjectMeta) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_GameServer_ObjectMeta.Unmarshal(m, b)
@@ -329,7 +556,7 @@ func (m *GameServer_Spec) Reset()         { *m = GameServer_Spec{} }
 func (m *GameServer_Spec) String() string { return proto.CompactTextString(m) }
 func (*GameServer_Spec) ProtoMessage()    {}
 func (*GameServer_Spec) Descriptor() ([]byte, []int) {
-	return fileDescriptor_sdk_c87317298fe478a7, []int{3, 1}
+	return fileDescriptor_sdk_5eb25965361d9688, []int{8, 1}
 }
 func (m *GameServer_Spec) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_GameServer_Spec.Unmarshal(m, b)
@@ -370,7 +597,7 @@ func (m *GameServer_Spec_Health) Reset()         { *m = GameServer_Spec_Health{}
 func (m *GameServer_Spec_Health) String() string { return proto.CompactTextString(m) }
 func (*GameServer_Spec_Health) ProtoMessage()    {}
 func (*GameServer_Spec_Health) Descriptor() ([]byte, []int) {
-	return fileDescriptor_sdk_c87317298fe478a7, []int{3, 1, 0}
+	return fileDescriptor_sdk_5eb25965361d9688, []int{8, 1, 0}
 }
 func (m *GameServer_Spec_Health) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_GameServer_Spec_Health.Unmarshal(m, b)
@@ -419,19 +646,22 @@ func (m *GameServer_Spec_Health) GetInitialDelaySeconds() int32 {
 }
 
 type GameServer_Status struct {
-	State                string                    `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
-	Address              string                    `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
-	Ports                []*GameServer_Status_Port `protobuf:"bytes,3,rep,name=ports,proto3" json:"ports,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}                  `json:"-"`
-	XXX_unrecognized     []byte                    `json:"-"`
-	XXX_sizecache        int32                     `json:"-"`
+	State                string                                      `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	Address              string                                      `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Ports                []*GameServer_Status_Port                   `protobuf:"bytes,3,rep,name=ports,proto3" json:"ports,omitempty"`
+	Counters             map[string]*GameServer_Status_CounterStatus `protobuf:"bytes,4,rep,name=counters,proto3" json:"counters,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Lists                map[string]*GameServer_Status_ListStatus    `protobuf:"bytes,5,rep,name=lists,proto3" json:"lists,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Players              *GameServer_Status_PlayerStatus             `protobuf:"bytes,6,opt,name=players,proto3" json:"players,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                                    `json:"-"`
+	XXX_unrecognized     []byte                                      `json:"-"`
+	XXX_sizecache        int32                                       `json:"-"`
 }
 
 func (m *GameServer_Status) Reset()         { *m = GameServer_Status{} }
 func (m *GameServer_Status) String() string { return proto.CompactTextString(m) }
 func (*GameServer_Status) ProtoMessage()    {}
 func (*GameServer_Status) Descriptor() ([]byte, []int) {
-	return fileDescriptor_sdk_c87317298fe478a7, []int{3, 2}
+	return fileDescriptor_sdk_5eb25965361d9688, []int{8, 2}
 }
 func (m *GameServer_Status) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_GameServer_Status.Unmarshal(m, b)
@@ -472,6 +702,27 @@ func (m *GameServer_Status) GetPorts() []*GameServer_Status_Port {
 	return nil
 }
 
+func (m *GameServer_Status) GetCounters() map[string]*GameServer_Status_CounterStatus {
+	if m != nil {
+		return m.Counters
+	}
+	return nil
+}
+
+func (m *GameServer_Status) GetLists() map[string]*GameServer_Status_ListStatus {
+	if m != nil {
+		return m.Lists
+	}
+	return nil
+}
+
+func (m *GameServer_Status) GetPlayers() *GameServer_Status_PlayerStatus {
+	if m != nil {
+		return m.Players
+	}
+	return nil
+}
+
 type GameServer_Status_Port struct {
 	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	Port                 int32    `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
@@ -484,7 +735,7 @@ func (m *GameServer_Status_Port) Reset()         { *m = GameServer_Status_Port{}
 func (m *GameServer_Status_Port) String() string { return proto.CompactTextString(m) }
 func (*GameServer_Status_Port) ProtoMessage()    {}
 func (*GameServer_Status_Port) Descriptor() ([]byte, []int) {
-	return fileDescriptor_sdk_c87317298fe478a7, []int{3, 2, 0}
+	return fileDescriptor_sdk_5eb25965361d9688, []int{8, 2, 0}
 }
 func (m *GameServer_Status_Port) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_GameServer_Status_Port.Unmarshal(m, b)
@@ -518,10 +769,161 @@ func (m *GameServer_Status_Port) GetPort() int32 {
 	return 0
 }
 
+type GameServer_Status_CounterStatus struct {
+	Count                int64    `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Capacity             int64    `protobuf:"varint,2,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GameServer_Status_CounterStatus) Reset()         { *m = GameServer_Status_CounterStatus{} }
+func (m *GameServer_Status_CounterStatus) String() string { return proto.CompactTextString(m) }
+func (*GameServer_Status_CounterStatus) ProtoMessage()    {}
+func (*GameServer_Status_CounterStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_sdk_5eb25965361d9688, []int{8, 2, 1}
+}
+func (m *GameServer_Status_CounterStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GameServer_Status_CounterStatus.Unmarshal(m, b)
+}
+func (m *GameServer_Status_CounterStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GameServer_Status_CounterStatus.Marshal(b, m, deterministic)
+}
+func (dst *GameServer_Status_CounterStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GameServer_Status_CounterStatus.Merge(dst, src)
+}
+func (m *GameServer_Status_CounterStatus) XXX_Size() int {
+	return xxx_messageInfo_GameServer_Status_CounterStatus.Size(m)
+}
+func (m *GameServer_Status_CounterStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_GameServer_Status_CounterStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GameServer_Status_CounterStatus proto.InternalMessageInfo
+
+func (m *GameServer_Status_CounterStatus) GetCount() int64 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *GameServer_Status_CounterStatus) GetCapacity() int64 {
+	if m != nil {
+		return m.Capacity
+	}
+	return 0
+}
+
+type GameServer_Status_ListStatus struct {
+	Capacity             int64    `protobuf:"varint,1,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	Values               []string `protobuf:"bytes,2,rep,name=values,proto3" json:"values,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GameServer_Status_ListStatus) Reset()         { *m = GameServer_Status_ListStatus{} }
+func (m *GameServer_Status_ListStatus) String() string { return proto.CompactTextString(m) }
+func (*GameServer_Status_ListStatus) ProtoMessage()    {}
+func (*GameServer_Status_ListStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_sdk_5eb25965361d9688, []int{8, 2, 2}
+}
+func (m *GameServer_Status_ListStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GameServer_Status_ListStatus.Unmarshal(m, b)
+}
+func (m *GameServer_Status_ListStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GameServer_Status_ListStatus.Marshal(b, m, deterministic)
+}
+func (dst *GameServer_Status_ListStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GameServer_Status_ListStatus.Merge(dst, src)
+}
+func (m *GameServer_Status_ListStatus) XXX_Size() int {
+	return xxx_messageInfo_GameServer_Status_ListStatus.Size(m)
+}
+func (m *GameServer_Status_ListStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_GameServer_Status_ListStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GameServer_Status_ListStatus proto.InternalMessageInfo
+
+func (m *GameServer_Status_ListStatus) GetCapacity() int64 {
+	if m != nil {
+		return m.Capacity
+	}
+	return 0
+}
+
+func (m *GameServer_Status_ListStatus) GetValues() []string {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+type GameServer_Status_PlayerStatus struct {
+	Count                int64    `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Capacity             int64    `protobuf:"varint,2,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	Ids                  []string `protobuf:"bytes,3,rep,name=ids,proto3" json:"ids,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GameServer_Status_PlayerStatus) Reset()         { *m = GameServer_Status_PlayerStatus{} }
+func (m *GameServer_Status_PlayerStatus) String() string { return proto.CompactTextString(m) }
+func (*GameServer_Status_PlayerStatus) ProtoMessage()    {}
+func (*GameServer_Status_PlayerStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_sdk_5eb25965361d9688, []int{8, 2, 3}
+}
+func (m *GameServer_Status_PlayerStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GameServer_Status_PlayerStatus.Unmarshal(m, b)
+}
+func (m *GameServer_Status_PlayerStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GameServer_Status_PlayerStatus.Marshal(b, m, deterministic)
+}
+func (dst *GameServer_Status_PlayerStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GameServer_Status_PlayerStatus.Merge(dst, src)
+}
+func (m *GameServer_Status_PlayerStatus) XXX_Size() int {
+	return xxx_messageInfo_GameServer_Status_PlayerStatus.Size(m)
+}
+func (m *GameServer_Status_PlayerStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_GameServer_Status_PlayerStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GameServer_Status_PlayerStatus proto.InternalMessageInfo
+
+func (m *GameServer_Status_PlayerStatus) GetCount() int64 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *GameServer_Status_PlayerStatus) GetCapacity() int64 {
+	if m != nil {
+		return m.Capacity
+	}
+	return 0
+}
+
+func (m *GameServer_Status_PlayerStatus) GetIds() []string {
+	if m != nil {
+		return m.Ids
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Empty)(nil), "stable.agones.dev.sdk.Empty")
 	proto.RegisterType((*KeyValue)(nil), "stable.agones.dev.sdk.KeyValue")
 	proto.RegisterType((*Duration)(nil), "stable.agones.dev.sdk.Duration")
+	proto.RegisterType((*Counter)(nil), "stable.agones.dev.sdk.Counter")
+	proto.RegisterType((*List)(nil), "stable.agones.dev.sdk.List")
+	proto.RegisterType((*PlayerID)(nil), "stable.agones.dev.sdk.PlayerID")
+	proto.RegisterType((*Count)(nil), "stable.agones.dev.sdk.Count")
+	proto.RegisterType((*Bool)(nil), "stable.agones.dev.sdk.Bool")
 	proto.RegisterType((*GameServer)(nil), "stable.agones.dev.sdk.GameServer")
 	proto.RegisterType((*GameServer_ObjectMeta)(nil), "stable.agones.dev.sdk.GameServer.ObjectMeta")
 	proto.RegisterMapType((map[string]string)(nil), "stable.agones.dev.sdk.GameServer.ObjectMeta.AnnotationsEntry")
@@ -529,7 +931,12 @@ func init() {
 	proto.RegisterType((*GameServer_Spec)(nil), "stable.agones.dev.sdk.GameServer.Spec")
 	proto.RegisterType((*GameServer_Spec_Health)(nil), "stable.agones.dev.sdk.GameServer.Spec.Health")
 	proto.RegisterType((*GameServer_Status)(nil), "stable.agones.dev.sdk.GameServer.Status")
+	proto.RegisterMapType((map[string]*GameServer_Status_CounterStatus)(nil), "stable.agones.dev.sdk.GameServer.Status.CountersEntry")
+	proto.RegisterMapType((map[string]*GameServer_Status_ListStatus)(nil), "stable.agones.dev.sdk.GameServer.Status.ListsEntry")
 	proto.RegisterType((*GameServer_Status_Port)(nil), "stable.agones.dev.sdk.GameServer.Status.Port")
+	proto.RegisterType((*GameServer_Status_CounterStatus)(nil), "stable.agones.dev.sdk.GameServer.Status.CounterStatus")
+	proto.RegisterType((*GameServer_Status_ListStatus)(nil), "stable.agones.dev.sdk.GameServer.Status.ListStatus")
+	proto.RegisterType((*GameServer_Status_PlayerStatus)(nil), "stable.agones.dev.sdk.GameServer.Status.PlayerStatus")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -562,6 +969,20 @@ type SDKClient interface {
 	SetAnnotation(ctx context.Context, in *KeyValue, opts ...grpc.CallOption) (*Empty, error)
 	// Marks the GameServer as the Reserved state for Duration
 	Reserve(ctx context.Context, in *Duration, opts ...grpc.CallOption) (*Empty, error)
+	// Sets the count and capacity of a Counter in the backing GameServer status
+	SetCounter(ctx context.Context, in *Counter, opts ...grpc.CallOption) (*Empty, error)
+	// Sets the values and capacity of a List in the backing GameServer status
+	SetList(ctx context.Context, in *List, opts ...grpc.CallOption) (*Empty, error)
+	// Adds the player to the GameServer's connected players.
+	// Returns false if the player was already connected, and an error if the GameServer is at player capacity
+	PlayerConnect(ctx context.Context, in *PlayerID, opts ...grpc.CallOption) (*Bool, error)
+	// Removes the player from the GameServer's connected players.
+	// Returns false if the player was not connected
+	PlayerDisconnect(ctx context.Context, in *PlayerID, opts ...grpc.CallOption) (*Bool, error)
+	// Sets the maximum number of players that can connect to the GameServer
+	SetPlayerCapacity(ctx context.Context, in *Count, opts ...grpc.CallOption) (*Empty, error)
+	// Retrieves the number of players currently connected to the GameServer
+	GetPlayerCount(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Count, error)
 }
 
 type sDKClient struct {
@@ -701,6 +1122,60 @@ func (c *sDKClient) Reserve(ctx context.Context, in *Duration, opts ...grpc.Call
 	return out, nil
 }
 
+func (c *sDKClient) SetCounter(ctx context.Context, in *Counter, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/stable.agones.dev.sdk.SDK/SetCounter", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKClient) SetList(ctx context.Context, in *List, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/stable.agones.dev.sdk.SDK/SetList", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKClient) PlayerConnect(ctx context.Context, in *PlayerID, opts ...grpc.CallOption) (*Bool, error) {
+	out := new(Bool)
+	err := c.cc.Invoke(ctx, "/stable.agones.dev.sdk.SDK/PlayerConnect", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKClient) PlayerDisconnect(ctx context.Context, in *PlayerID, opts ...grpc.CallOption) (*Bool, error) {
+	out := new(Bool)
+	err := c.cc.Invoke(ctx, "/stable.agones.dev.sdk.SDK/PlayerDisconnect", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKClient) SetPlayerCapacity(ctx context.Context, in *Count, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/stable.agones.dev.sdk.SDK/SetPlayerCapacity", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sDKClient) GetPlayerCount(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Count, error) {
+	out := new(Count)
+	err := c.cc.Invoke(ctx, "/stable.agones.dev.sdk.SDK/GetPlayerCount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SDKServer is the server API for SDK service.
 type SDKServer interface {
 	// Call when the GameServer is ready
@@ -721,6 +1196,20 @@ type SDKServer interface {
 	SetAnnotation(context.Context, *KeyValue) (*Empty, error)
 	// Marks the GameServer as the Reserved state for Duration
 	Reserve(context.Context, *Duration) (*Empty, error)
+	// Sets the count and capacity of a Counter in the backing GameServer status
+	SetCounter(context.Context, *Counter) (*Empty, error)
+	// Sets the values and capacity of a List in the backing GameServer status
+	SetList(context.Context, *List) (*Empty, error)
+	// Adds the player to the GameServer's connected players.
+	// Returns false if the player was already connected, and an error if the GameServer is at player capacity
+	PlayerConnect(context.Context, *PlayerID) (*Bool, error)
+	// Removes the player from the GameServer's connected players.
+	// Returns false if the player was not connected
+	PlayerDisconnect(context.Context, *PlayerID) (*Bool, error)
+	// Sets the maximum number of players that can connect to the GameServer
+	SetPlayerCapacity(context.Context, *Count) (*Empty, error)
+	// Retrieves the number of players currently connected to the GameServer
+	GetPlayerCount(context.Context, *Empty) (*Count, error)
 }
 
 func RegisterSDKServer(s *grpc.Server, srv SDKServer) {
@@ -900,6 +1389,114 @@ func _SDK_Reserve_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SDK_SetCounter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Counter)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServer).SetCounter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stable.agones.dev.sdk.SDK/SetCounter",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServer).SetCounter(ctx, req.(*Counter))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDK_SetList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(List)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServer).SetList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stable.agones.dev.sdk.SDK/SetList",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServer).SetList(ctx, req.(*List))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDK_PlayerConnect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PlayerID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServer).PlayerConnect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stable.agones.dev.sdk.SDK/PlayerConnect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServer).PlayerConnect(ctx, req.(*PlayerID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDK_PlayerDisconnect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PlayerID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServer).PlayerDisconnect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stable.agones.dev.sdk.SDK/PlayerDisconnect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServer).PlayerDisconnect(ctx, req.(*PlayerID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDK_SetPlayerCapacity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Count)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServer).SetPlayerCapacity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stable.agones.dev.sdk.SDK/SetPlayerCapacity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServer).SetPlayerCapacity(ctx, req.(*Count))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SDK_GetPlayerCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SDKServer).GetPlayerCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stable.agones.dev.sdk.SDK/GetPlayerCount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SDKServer).GetPlayerCount(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _SDK_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "stable.agones.dev.sdk.SDK",
 	HandlerType: (*SDKServer)(nil),
@@ -932,6 +1529,30 @@ var _SDK_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Reserve",
 			Handler:    _SDK_Reserve_Handler,
 		},
+		{
+			MethodName: "SetCounter",
+			Handler:    _SDK_SetCounter_Handler,
+		},
+		{
+			MethodName: "SetList",
+			Handler:    _SDK_SetList_Handler,
+		},
+		{
+			MethodName: "PlayerConnect",
+			Handler:    _SDK_PlayerConnect_Handler,
+		},
+		{
+			MethodName: "PlayerDisconnect",
+			Handler:    _SDK_PlayerDisconnect_Handler,
+		},
+		{
+			MethodName: "SetPlayerCapacity",
+			Handler:    _SDK_SetPlayerCapacity_Handler,
+		},
+		{
+			MethodName: "GetPlayerCount",
+			Handler:    _SDK_GetPlayerCount_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -948,62 +1569,85 @@ var _SDK_serviceDesc = grpc.ServiceDesc{
 	Metadata: "sdk.proto",
 }
 
-func init() { proto.RegisterFile("sdk.proto", fileDescriptor_sdk_c87317298fe478a7) }
-
-var fileDescriptor_sdk_c87317298fe478a7 = []byte{
-	// 850 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0x5f, 0x6f, 0x1b, 0x45,
-	0x10, 0xd7, 0xc5, 0xff, 0xc7, 0xa4, 0x49, 0x36, 0xa9, 0x74, 0x3d, 0x45, 0xb4, 0x9c, 0x00, 0x85,
-	0x40, 0xef, 0x90, 0x79, 0x29, 0x96, 0x40, 0x14, 0x12, 0x15, 0xa9, 0x54, 0x54, 0xe7, 0x2a, 0x48,
-	0x15, 0xc8, 0xda, 0xdc, 0x0e, 0xf6, 0x91, 0xf3, 0xed, 0x69, 0x77, 0x9d, 0xca, 0xaf, 0x88, 0x6f,
-	0xc0, 0x87, 0xe0, 0x05, 0x5e, 0xf8, 0x2a, 0x7c, 0x05, 0x3e, 0x06, 0x0f, 0x68, 0x67, 0xef, 0x62,
-	0xab, 0x6a, 0x1a, 0x47, 0xc9, 0x93, 0x77, 0x67, 0xe6, 0xf7, 0xfb, 0xed, 0xcd, 0xce, 0xcc, 0x1a,
-	0x7a, 0x5a, 0x9c, 0x45, 0xa5, 0x92, 0x46, 0xb2, 0xbb, 0xda, 0xf0, 0xd3, 0x1c, 0x23, 0x3e, 0x91,
-	0x05, 0xea, 0x48, 0xe0, 0x79, 0xa4, 0xc5, 0x59, 0xb0, 0x3f, 0x91, 0x72, 0x92, 0x63, 0xcc, 0xcb,
-	0x2c, 0xe6, 0x45, 0x21, 0x0d, 0x37, 0x99, 0x2c, 0xb4, 0x03, 0x85, 0x1d, 0x68, 0x1d, 0xcf, 0x4a,
-	0xb3, 0x08, 0x07, 0xd0, 0x7d, 0x8a, 0x8b, 0x13, 0x9e, 0xcf, 0x91, 0x6d, 0x43, 0xe3, 0x0c, 0x17,
-	0xbe, 0xf7, 0xc0, 0x3b, 0xe8, 0x25, 0x76, 0xc9, 0xf6, 0xa0, 0x75, 0x6e, 0x5d, 0xfe, 0x06, 0xd9,
-	0xdc, 0x26, 0x7c, 0x1f, 0xba, 0x47, 0x73, 0x45, 0x7c, 0xcc, 0x87, 0x8e, 0xc6, 0x54, 0x16, 0x42,
-	0x13, 0xae, 0x91, 0xd4, 0xdb, 0xf0, 0xef, 0x1e, 0xc0, 0x13, 0x3e, 0xc3, 0x11, 0xaa, 0x73, 0x54,
-	0xec, 0x19, 0xf4, 0xe5, 0xe9, 0x2f, 0x98, 0x9a, 0xf1, 0x0c, 0x0d, 0xa7, 0xe0, 0xfe, 0xe0, 0x93,
-	0xe8, 0x8d, 0x87, 0x8f, 0x96, 0xb8, 0xe8, 0x7b, 0x02, 0x3d, 0x43, 0xc3, 0x13, 0x90, 0x17, 0x6b,
-	0x36, 0x84, 0xa6, 0x2e, 0x31, 0xa5, 0x83, 0xf5, 0x07, 0x1f, 0x5e, 0xcd, 0x33, 0x2a, 0x31, 0x4d,
-	0x08, 0xc3, 0xbe, 0x82, 0xb6, 0x36, 0xdc, 0xcc, 0xb5, 0xdf, 0x20, 0xf4, 0xc1, 0x1a, 0x68, 0x8a,
-	0x4f, 0x2a, 0x5c, 0xf0, 0x47, 0x13, 0x60, 0x79, 0x30, 0xc6, 0xa0, 0x59, 0xf0, 0x19, 0x56, 0x99,
-	0xa3, 0x35, 0xdb, 0x87, 0x9e, 0xfd, 0xd5, 0x25, 0x4f, 0xeb, 0xf4, 0x2d, 0x0d, 0x36, 0xd5, 0xf3,
-	0x4c, 0x90, 0x7e, 0x2f, 0xb1, 0x4b, 0xf6, 0x11, 0x6c, 0x2b, 0xd4, 0x72, 0xae, 0x52, 0x1c, 0x9f,
-	0xa3, 0xd2, 0x99, 0x2c, 0xfc, 0x26, 0xb9, 0xb7, 0x6a, 0xfb, 0x89, 0x33, 0xb3, 0x77, 0x01, 0x26,
-	0x58, 0xa0, 0xbb, 0x01, 0xbf, 0x45, 0x69, 0x5f, 0xb1, 0xb0, 0x87, 0xc0, 0x52, 0x85, 0xb4, 0x1e,
-	0x9b, 0x6c, 0x86, 0xda, 0xf0, 0x59, 0xe9, 0xb7, 0x29, 0x6e, 0xa7, 0xf6, 0xbc, 0xa8, 0x1d, 0x36,
-	0x5c, 0x60, 0x8e, 0xaf, 0x85, 0x77, 0x5c, 0x78, 0xed, 0x59, 0x86, 0x8f, 0xa1, 0xbf, 0x52, 0x4f,
-	0x7e, 0xf7, 0x41, 0xe3, 0xa0, 0x3f, 0xf8, 0xe2, 0x3a, 0x17, 0x19, 0x3d, 0x5e, 0xe2, 0x8f, 0x0b,
-	0xa3, 0x16, 0xc9, 0x2a, 0x23, 0x7b, 0x0e, 0xed, 0x9c, 0x9f, 0x62, 0xae, 0xfd, 0x1e, 0x71, 0x3f,
-	0xba, 0x16, 0xf7, 0x77, 0x04, 0x75, 0xb4, 0x15, 0x4f, 0xf0, 0x25, 0x6c, 0xbf, 0x2e, 0xb9, 0x6e,
-	0xb1, 0x0f, 0x37, 0x1e, 0x79, 0xc1, 0xe7, 0xd0, 0x5f, 0xa1, 0xbd, 0x16, 0xf4, 0x3f, 0x0f, 0x9a,
-	0xb6, 0xf4, 0xd8, 0x31, 0xb4, 0xa7, 0xc8, 0x73, 0x33, 0xad, 0x4a, 0xff, 0xe1, 0x7a, 0x25, 0x1b,
-	0x7d, 0x4b, 0xa0, 0xa4, 0x02, 0x07, 0x7f, 0x7a, 0xd0, 0x76, 0x26, 0x16, 0x40, 0x57, 0x64, 0xda,
-	0x72, 0x08, 0xe2, 0xec, 0x26, 0x17, 0x7b, 0xf6, 0x01, 0xdc, 0x29, 0x51, 0x65, 0x52, 0x8c, 0xeb,
-	0xee, 0xb4, 0x27, 0x6b, 0x25, 0x9b, 0xce, 0x3a, 0x72, 0x46, 0xf6, 0x31, 0xec, 0xfc, 0xcc, 0xb3,
-	0x7c, 0xae, 0x70, 0x6c, 0xa6, 0x0a, 0xf5, 0x54, 0xe6, 0xae, 0x28, 0x5b, 0xc9, 0x76, 0xe5, 0x78,
-	0x51, 0xdb, 0xd9, 0x00, 0xee, 0x66, 0x45, 0x66, 0x32, 0x9e, 0x8f, 0x05, 0xe6, 0x7c, 0x71, 0x41,
-	0xdd, 0x24, 0xc0, 0x6e, 0xe5, 0x3c, 0xb2, 0xbe, 0x4a, 0x20, 0xf8, 0xcb, 0x83, 0xb6, 0xeb, 0x1d,
-	0x9b, 0x23, 0xdb, 0x3d, 0x75, 0x97, 0xb8, 0x8d, 0x9d, 0x1f, 0x5c, 0x08, 0x85, 0x5a, 0x57, 0xb9,
-	0xab, 0xb7, 0xec, 0x1b, 0x68, 0x95, 0x52, 0x19, 0xdb, 0xa4, 0x8d, 0x35, 0xf3, 0x45, 0x42, 0xd1,
-	0x73, 0xa9, 0x4c, 0xe2, 0xb0, 0x41, 0x04, 0x4d, 0xbb, 0x7d, 0x63, 0x87, 0x32, 0x68, 0xda, 0xa0,
-	0x2a, 0x33, 0xb4, 0x1e, 0xfc, 0xd6, 0x81, 0xc6, 0xe8, 0xe8, 0x29, 0x3b, 0x81, 0x56, 0x82, 0x5c,
-	0x2c, 0xd8, 0xfe, 0x25, 0xb2, 0x34, 0x3d, 0x83, 0xb7, 0x7a, 0xc3, 0x9d, 0x5f, 0xff, 0xf9, 0xf7,
-	0xf7, 0x8d, 0x7e, 0xd8, 0x8e, 0x95, 0xe5, 0x1a, 0x7a, 0x87, 0xec, 0x47, 0xe8, 0x3e, 0xce, 0x73,
-	0x99, 0xda, 0x4f, 0xbf, 0x09, 0xf5, 0x1e, 0x51, 0xdf, 0x09, 0x7b, 0x31, 0xaf, 0xe8, 0x2a, 0xf6,
-	0xd1, 0x74, 0x6e, 0x84, 0x7c, 0x55, 0xdc, 0x12, 0xbb, 0xae, 0xe8, 0x2c, 0xfb, 0xcb, 0x8b, 0xca,
-	0xbb, 0x09, 0x37, 0x23, 0xee, 0x77, 0xc2, 0x4e, 0xec, 0x2a, 0x7a, 0xe8, 0x1d, 0x1e, 0x78, 0x0c,
-	0x61, 0xf3, 0x09, 0x9a, 0x95, 0xe7, 0xe2, 0xed, 0x12, 0xef, 0x5d, 0x59, 0x0c, 0xe1, 0x2e, 0xe9,
-	0x6c, 0xb2, 0x7e, 0x3c, 0xb1, 0x53, 0xd7, 0xb1, 0x4a, 0xd8, 0xfa, 0x81, 0x9b, 0x74, 0x7a, 0x9b,
-	0x42, 0xf7, 0x48, 0x68, 0x97, 0xed, 0xc4, 0xaf, 0x2c, 0xf5, 0x8a, 0xdc, 0xa7, 0xf6, 0xbb, 0xba,
-	0x23, 0x34, 0x34, 0x3c, 0xd8, 0xfd, 0x4b, 0xb8, 0xea, 0xf7, 0xf7, 0x8a, 0xc4, 0x05, 0xa4, 0xb3,
-	0x17, 0x6c, 0xc5, 0xf6, 0x01, 0x15, 0xdc, 0xf0, 0x98, 0xc6, 0x9b, 0xbd, 0x1a, 0x09, 0x9b, 0x23,
-	0x34, 0xcb, 0x19, 0x77, 0x53, 0xad, 0xfb, 0xa4, 0x75, 0x2f, 0xd8, 0x5b, 0x6a, 0x2d, 0x27, 0xb4,
-	0x15, 0xfc, 0x09, 0x3a, 0x89, 0xfb, 0xca, 0x4b, 0xa5, 0xea, 0xbf, 0x08, 0x57, 0x48, 0x55, 0xf7,
-	0x14, 0x76, 0x63, 0xe5, 0x08, 0x87, 0xde, 0xe1, 0xd7, 0xad, 0x97, 0x0d, 0x2d, 0xce, 0x4e, 0xdb,
-	0xf4, 0x67, 0xe5, 0xb3, 0xff, 0x03, 0x00, 0x00, 0xff, 0xff, 0x1a, 0x40, 0x3f, 0x1d, 0xee, 0x08,
-	0x00, 0x00,
+func init() { proto.RegisterFile("sdk.proto", fileDescriptor_sdk_5eb25965361d9688) }
+
+var fileDescriptor_sdk_5eb25965361d9688 = []byte{
+	// 1229 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x57, 0xdd, 0x6e, 0xdb, 0xb6,
+	0x17, 0x87, 0x63, 0xcb, 0x96, 0x8f, 0xeb, 0xc4, 0x61, 0x93, 0xff, 0xdf, 0x55, 0xd3, 0x8f, 0x09,
+	0x5b, 0x91, 0x75, 0xab, 0x35, 0xb8, 0xd8, 0xd0, 0x05, 0xd8, 0xd0, 0x8f, 0x14, 0x5d, 0xd0, 0x74,
+	0x09, 0xe4, 0x22, 0x03, 0x8a, 0x0e, 0x06, 0x23, 0x71, 0xb1, 0x16, 0x59, 0x34, 0x44, 0x3a, 0x85,
+	0x6f, 0xf7, 0x0a, 0x7b, 0x88, 0xdd, 0xec, 0x6d, 0x76, 0xb7, 0x9b, 0xdd, 0xec, 0x72, 0x8f, 0xb0,
+	0x8b, 0x81, 0x87, 0x94, 0xa5, 0xb6, 0x71, 0xec, 0x34, 0xb9, 0x12, 0xc9, 0xc3, 0xf3, 0xfb, 0xf1,
+	0x1c, 0x9e, 0x0f, 0x0a, 0xea, 0x22, 0x3c, 0xee, 0x8c, 0x52, 0x2e, 0x39, 0x59, 0x17, 0x92, 0x1e,
+	0xc6, 0xac, 0x43, 0x8f, 0x78, 0xc2, 0x44, 0x27, 0x64, 0x27, 0x1d, 0x11, 0x1e, 0x3b, 0x1b, 0x47,
+	0x9c, 0x1f, 0xc5, 0xcc, 0xa3, 0xa3, 0xc8, 0xa3, 0x49, 0xc2, 0x25, 0x95, 0x11, 0x4f, 0x84, 0x56,
+	0x72, 0x6b, 0x60, 0x3d, 0x1d, 0x8e, 0xe4, 0xc4, 0xed, 0x82, 0xfd, 0x9c, 0x4d, 0x0e, 0x68, 0x3c,
+	0x66, 0xa4, 0x05, 0xe5, 0x63, 0x36, 0x69, 0x97, 0x6e, 0x97, 0x36, 0xeb, 0xbe, 0x1a, 0x92, 0x35,
+	0xb0, 0x4e, 0x94, 0xa8, 0xbd, 0x84, 0x6b, 0x7a, 0xe2, 0x7e, 0x0c, 0xf6, 0xf6, 0x38, 0x45, 0x3c,
+	0xd2, 0x86, 0x9a, 0x60, 0x01, 0x4f, 0x42, 0x81, 0x7a, 0x65, 0x3f, 0x9b, 0xba, 0x7b, 0x50, 0x7b,
+	0xc2, 0xc7, 0x89, 0x64, 0x29, 0x21, 0x50, 0x49, 0xe8, 0x90, 0x19, 0x64, 0x1c, 0x2b, 0xe8, 0x40,
+	0x89, 0x11, 0xba, 0xec, 0xeb, 0x09, 0x71, 0xc0, 0x0e, 0xe8, 0x88, 0x06, 0x91, 0x9c, 0xb4, 0xcb,
+	0x28, 0x98, 0xce, 0xdd, 0xef, 0xa1, 0xb2, 0x1b, 0x09, 0x79, 0x2a, 0x5a, 0x51, 0x6f, 0xe9, 0x6d,
+	0x3d, 0xf2, 0x3f, 0xa8, 0xe2, 0xb9, 0x45, 0xbb, 0x7c, 0xbb, 0xbc, 0x59, 0xf7, 0xcd, 0xcc, 0xbd,
+	0x03, 0xf6, 0x7e, 0x4c, 0x27, 0x2c, 0xdd, 0xd9, 0x56, 0xfa, 0x23, 0x33, 0x36, 0xb8, 0xd3, 0xb9,
+	0x7b, 0x03, 0x2c, 0x34, 0x24, 0x3f, 0x72, 0xa9, 0x70, 0x64, 0xd7, 0x81, 0xca, 0x63, 0xce, 0x63,
+	0x75, 0xac, 0x43, 0xce, 0x63, 0x14, 0xda, 0x3e, 0x8e, 0xdd, 0xbf, 0x96, 0x01, 0x9e, 0xd1, 0x21,
+	0xeb, 0xb1, 0xf4, 0x84, 0xa5, 0xe4, 0x05, 0x34, 0xf8, 0xe1, 0xcf, 0x2c, 0x90, 0xfd, 0x21, 0x93,
+	0x14, 0x77, 0x36, 0xba, 0x9f, 0x77, 0x4e, 0xbd, 0xc0, 0x4e, 0xae, 0xd7, 0xd9, 0x43, 0xa5, 0x17,
+	0x4c, 0x52, 0x1f, 0xf8, 0x74, 0x4c, 0xb6, 0xa0, 0x22, 0x46, 0x2c, 0x40, 0x83, 0x1b, 0xdd, 0x3b,
+	0xf3, 0x71, 0x7a, 0x23, 0x16, 0xf8, 0xa8, 0x43, 0x1e, 0x42, 0x55, 0x48, 0x2a, 0xc7, 0x02, 0xdd,
+	0xdc, 0xe8, 0x6e, 0x2e, 0xa0, 0x8d, 0xfb, 0x7d, 0xa3, 0xe7, 0xfc, 0x56, 0x01, 0xc8, 0x0f, 0x76,
+	0xea, 0xad, 0x6c, 0x40, 0x5d, 0x7d, 0xc5, 0x88, 0x06, 0x59, 0x08, 0xe5, 0x0b, 0x2a, 0xdc, 0xc6,
+	0x51, 0x88, 0xfc, 0x75, 0x5f, 0x0d, 0xc9, 0xa7, 0xd0, 0x4a, 0x99, 0xe0, 0xe3, 0x34, 0x60, 0xfd,
+	0x13, 0x96, 0x8a, 0x88, 0x27, 0xed, 0x0a, 0x8a, 0x57, 0xb2, 0xf5, 0x03, 0xbd, 0x4c, 0x6e, 0x02,
+	0x1c, 0xb1, 0x84, 0xe9, 0x28, 0x6c, 0x5b, 0x78, 0x21, 0x85, 0x15, 0x72, 0x0f, 0x48, 0x90, 0x32,
+	0x1c, 0xf7, 0x65, 0x34, 0x64, 0x42, 0xd2, 0xe1, 0xa8, 0x5d, 0xc5, 0x7d, 0xab, 0x99, 0xe4, 0x65,
+	0x26, 0x50, 0xdb, 0x43, 0x16, 0xb3, 0x77, 0xb6, 0xd7, 0xf4, 0xf6, 0x4c, 0x92, 0x6f, 0xef, 0x43,
+	0xa3, 0x90, 0x53, 0x6d, 0xfb, 0x76, 0x79, 0xb3, 0xd1, 0xfd, 0xe6, 0x3c, 0x17, 0xd9, 0x79, 0x94,
+	0xeb, 0x3f, 0x4d, 0x64, 0x3a, 0xf1, 0x8b, 0x88, 0x64, 0x1f, 0xaa, 0x31, 0x3d, 0x64, 0xb1, 0x68,
+	0xd7, 0x11, 0xfb, 0xc1, 0xb9, 0xb0, 0x77, 0x51, 0x55, 0xc3, 0x1a, 0x1c, 0xe7, 0x5b, 0x68, 0xbd,
+	0x4b, 0xb9, 0x68, 0xc2, 0x6f, 0x2d, 0x3d, 0x28, 0x39, 0x5f, 0x43, 0xa3, 0x00, 0x7b, 0x2e, 0xd5,
+	0x7f, 0x4b, 0x50, 0x51, 0xa1, 0x47, 0x9e, 0x42, 0x75, 0xc0, 0x68, 0x2c, 0x07, 0x26, 0xf4, 0xef,
+	0x2d, 0x16, 0xb2, 0x9d, 0xef, 0x50, 0xc9, 0x37, 0xca, 0xce, 0xef, 0x25, 0xa8, 0xea, 0x25, 0x95,
+	0xb7, 0x61, 0x24, 0x14, 0x46, 0x68, 0x12, 0x6f, 0x3a, 0x27, 0x9f, 0xc0, 0xf2, 0x88, 0xa5, 0x11,
+	0x0f, 0xfb, 0x59, 0x85, 0x52, 0x27, 0xb3, 0xfc, 0xa6, 0x5e, 0xed, 0xe9, 0x45, 0xf2, 0x19, 0xac,
+	0xfe, 0x44, 0xa3, 0x78, 0x9c, 0xb2, 0xbe, 0x1c, 0xa4, 0x4c, 0x0c, 0x78, 0xac, 0x83, 0xd2, 0xf2,
+	0x5b, 0x46, 0xf0, 0x32, 0x5b, 0x27, 0x5d, 0x58, 0x8f, 0x92, 0x48, 0x46, 0x34, 0xee, 0x87, 0x2c,
+	0xa6, 0x93, 0x29, 0x74, 0x05, 0x15, 0xae, 0x1a, 0xe1, 0xb6, 0x92, 0x19, 0x02, 0xe7, 0xcf, 0x2a,
+	0x54, 0x75, 0xee, 0x28, 0x1f, 0xa9, 0xec, 0xc9, 0xb2, 0x44, 0x4f, 0x54, 0x0d, 0xa5, 0x61, 0x98,
+	0x32, 0x21, 0x8c, 0xef, 0xb2, 0x29, 0x79, 0x02, 0xd6, 0x88, 0xa7, 0x52, 0x57, 0xae, 0xc5, 0xfc,
+	0x85, 0x44, 0x9d, 0x7d, 0x9e, 0x4a, 0x5f, 0xeb, 0x12, 0x1f, 0xec, 0x40, 0x17, 0x62, 0x75, 0x4c,
+	0x85, 0xf3, 0xd5, 0xc2, 0x38, 0xa6, 0x82, 0x9b, 0x58, 0x9a, 0xe2, 0x90, 0x1d, 0xb0, 0xe2, 0x48,
+	0x48, 0xd1, 0xb6, 0x10, 0xf0, 0xfe, 0xc2, 0x80, 0xaa, 0x82, 0x1b, 0x34, 0x8d, 0x40, 0xf6, 0xa0,
+	0xa6, 0x4b, 0xad, 0xc0, 0xf4, 0x6c, 0x74, 0xbf, 0x5c, 0xdc, 0x4a, 0xd4, 0xd3, 0x13, 0x3f, 0x43,
+	0x71, 0x3a, 0x50, 0x51, 0xe6, 0x9f, 0x5a, 0x91, 0x08, 0x54, 0x94, 0x53, 0x4c, 0x24, 0xe0, 0xd8,
+	0x79, 0x04, 0x4d, 0x63, 0x66, 0x7e, 0x4b, 0xef, 0xd7, 0xf9, 0xb3, 0x5a, 0x8c, 0xf3, 0x10, 0x40,
+	0x19, 0x66, 0xf4, 0x8b, 0x3b, 0x4b, 0x33, 0x9b, 0xd1, 0x52, 0xb1, 0x19, 0x39, 0x3e, 0x5c, 0x29,
+	0x5a, 0x73, 0xfe, 0x33, 0xa8, 0x8c, 0x8c, 0xc2, 0xac, 0xc7, 0xa9, 0xa1, 0x23, 0xa6, 0x86, 0xcd,
+	0x4c, 0xda, 0xdd, 0x62, 0xd2, 0x7e, 0x40, 0x60, 0x18, 0xdf, 0x17, 0x92, 0x7d, 0xa8, 0x5d, 0x31,
+	0x93, 0x71, 0xe7, 0x6d, 0xc6, 0xf3, 0x45, 0xce, 0x7b, 0x74, 0xdd, 0x7f, 0x1a, 0x50, 0xee, 0x6d,
+	0x3f, 0x27, 0x07, 0x60, 0xf9, 0x8c, 0x86, 0x13, 0xb2, 0x31, 0x03, 0x10, 0x9f, 0x3b, 0xce, 0x99,
+	0x52, 0x77, 0xf5, 0x97, 0x3f, 0xfe, 0xfe, 0x75, 0xa9, 0xb1, 0x55, 0xba, 0xeb, 0x56, 0xbd, 0x14,
+	0xe1, 0x5e, 0x83, 0xfd, 0x28, 0x8e, 0x79, 0xa0, 0xf2, 0xf4, 0x22, 0xd0, 0x6b, 0x08, 0xbd, 0xac,
+	0xa0, 0xeb, 0x1e, 0xcd, 0x10, 0x5f, 0x83, 0xdd, 0x1b, 0x8c, 0x65, 0xc8, 0xdf, 0x24, 0x97, 0x87,
+	0x2e, 0x32, 0xc4, 0x57, 0xd3, 0x32, 0x79, 0x11, 0x6c, 0x82, 0xd8, 0x57, 0x14, 0x76, 0xcd, 0xd3,
+	0x15, 0x78, 0xb3, 0x44, 0x18, 0x34, 0x9f, 0x31, 0x59, 0x78, 0xdb, 0x9c, 0x4d, 0xf1, 0xd1, 0xdc,
+	0x6b, 0x76, 0xaf, 0x22, 0x4f, 0x93, 0x34, 0xbc, 0x23, 0xf5, 0x44, 0xd0, 0xa8, 0x1c, 0x56, 0x7e,
+	0xa0, 0x32, 0x18, 0x5c, 0x26, 0xd1, 0x35, 0x24, 0xba, 0x4a, 0x56, 0xbd, 0x37, 0x0a, 0xba, 0x40,
+	0xf7, 0x85, 0xb2, 0xcb, 0xee, 0x31, 0x89, 0x9d, 0x8e, 0xdc, 0x9a, 0x81, 0x95, 0x3d, 0x98, 0xe7,
+	0x38, 0xce, 0x41, 0x9e, 0xb5, 0xad, 0xd2, 0x5d, 0x67, 0xc5, 0x53, 0x0f, 0xbe, 0x90, 0x4a, 0xea,
+	0x61, 0x3b, 0x26, 0x1c, 0x9a, 0x3d, 0x26, 0xf3, 0x86, 0x7c, 0x51, 0xae, 0x5b, 0xc8, 0x75, 0x4d,
+	0x71, 0xad, 0xe5, 0x5c, 0xf9, 0x8b, 0x82, 0xfc, 0x08, 0x35, 0x5f, 0x5b, 0x39, 0x93, 0x2a, 0x7b,
+	0xd3, 0xcf, 0xa1, 0x32, 0xf7, 0xa4, 0xe2, 0xc1, 0xf6, 0x52, 0x83, 0xd9, 0x07, 0xe8, 0x31, 0x99,
+	0xbd, 0xf7, 0x6f, 0xce, 0x00, 0x30, 0xf2, 0x85, 0x09, 0x1c, 0xdb, 0x33, 0x1d, 0x87, 0x1c, 0x40,
+	0x4d, 0xdd, 0x8b, 0x7a, 0xff, 0x5f, 0x9f, 0xa1, 0xad, 0x84, 0x73, 0xa0, 0x5b, 0x08, 0x0d, 0x0a,
+	0xda, 0xf2, 0x54, 0xfb, 0x21, 0x11, 0x34, 0x75, 0xdd, 0x7d, 0xc2, 0x93, 0x84, 0x05, 0x72, 0xa6,
+	0x77, 0xb2, 0x5f, 0x05, 0x67, 0x16, 0xbd, 0xfa, 0x09, 0x28, 0xdc, 0xb9, 0xbb, 0xe2, 0xe9, 0x86,
+	0xe4, 0x05, 0x06, 0x99, 0x43, 0x4b, 0x83, 0x6c, 0x47, 0x22, 0xb8, 0x14, 0xb6, 0x1b, 0xc8, 0xf6,
+	0x7f, 0xc5, 0x46, 0x32, 0xb6, 0x30, 0x07, 0x8f, 0x61, 0xb5, 0xc7, 0xa4, 0x31, 0x2f, 0x6b, 0x13,
+	0x1b, 0x67, 0xdd, 0xcd, 0x1c, 0xf7, 0x5d, 0x47, 0xbe, 0x75, 0xe5, 0xbe, 0xd6, 0xd4, 0xba, 0x0c,
+	0x38, 0x80, 0xe5, 0x67, 0x53, 0x36, 0xec, 0x56, 0x1f, 0x56, 0x75, 0x50, 0xd7, 0x5d, 0x47, 0xaa,
+	0x15, 0xd2, 0xcc, 0xbd, 0x38, 0x4e, 0xe4, 0x63, 0xeb, 0x55, 0x59, 0x84, 0xc7, 0x87, 0x55, 0xfc,
+	0x8b, 0xbd, 0xff, 0xdf, 0x00, 0x52, 0xd5, 0xb4, 0x2c, 0x07, 0x0f, 0x00, 0x00,
 }
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net/http"
 	"os"
 	"strconv"
 	"sync"
@@ -35,6 +36,10 @@ import (
 var (
 	_ sdk.SDKServer = &LocalSDKServer{}
 
+	// defaultHealthPeriod is how long after a health ping the LocalSDKServer reports
+	// it is healthy, if the GameServer doesn't set a health period
+	defaultHealthPeriod = 5 * time.Second
+
 	defaultGs = &sdk.GameServer{
 		ObjectMeta: &sdk.GameServer_ObjectMeta{
 			Name:              "local",
@@ -65,6 +70,9 @@ type LocalSDKServer struct {
 	requestSequence  []string
 	expectedSequence []string
 	testMode         bool
+
+	healthMutex       sync.RWMutex
+	healthLastUpdated time.Time
 }
 
 // NewLocalSDKServer returns the default LocalSDKServer
@@ -205,9 +213,46 @@ func (l *LocalSDKServer) Health(stream sdk.SDK_HealthServer) error {
 		}
 		l.recordRequest("health")
 		logrus.Info("Health Ping Received!")
+		l.healthMutex.Lock()
+		l.healthLastUpdated = time.Now()
+		l.healthMutex.Unlock()
 	}
 }
 
+// HealthHandler is the http handler for /gshealthz, which responds with an error if no health ping
+// has been received within the health period of the GameServer.
+// This lets Agones check the health of a development GameServer that runs alongside this LocalSDKServer.
+func (l *LocalSDKServer) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	if !l.healthy() {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if _, err := w.Write([]byte("ok")); err != nil {
+		logrus.WithError(err).Error("could not send ok response on gshealthz")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// healthy returns if a health ping has been received within the health period of the GameServer
+func (l *LocalSDKServer) healthy() bool {
+	period := defaultHealthPeriod
+	l.gsMutex.RLock()
+	if l.gs.Spec != nil && l.gs.Spec.Health != nil {
+		if l.gs.Spec.Health.Disabled {
+			l.gsMutex.RUnlock()
+			return true
+		}
+		if l.gs.Spec.Health.PeriodSeconds > 0 {
+			period = time.Duration(l.gs.Spec.Health.PeriodSeconds) * time.Second
+		}
+	}
+	l.gsMutex.RUnlock()
+
+	l.healthMutex.RLock()
+	defer l.healthMutex.RUnlock()
+	return !l.healthLastUpdated.IsZero() && time.Since(l.healthLastUpdated) <= period
+}
+
 // SetLabel applies a Label to the backing GameServer metadata
 func (l *LocalSDKServer) SetLabel(_ context.Context, kv *sdk.KeyValue) (*sdk.Empty, error) {
 	logrus.WithField("values", kv).Info("Setting label")
@@ -246,6 +291,123 @@ func (l *LocalSDKServer) SetAnnotation(_ context.Context, kv *sdk.KeyValue) (*sd
 	return &sdk.Empty{}, nil
 }
 
+// SetCounter sets a Counter on the backing GameServer status
+func (l *LocalSDKServer) SetCounter(_ context.Context, c *sdk.Counter) (*sdk.Empty, error) {
+	logrus.WithField("values", c).Info("Setting counter")
+	l.gsMutex.Lock()
+	defer l.gsMutex.Unlock()
+
+	if l.gs.Status == nil {
+		l.gs.Status = &sdk.GameServer_Status{}
+	}
+	if l.gs.Status.Counters == nil {
+		l.gs.Status.Counters = map[string]*sdk.GameServer_Status_CounterStatus{}
+	}
+
+	l.gs.Status.Counters[c.Name] = &sdk.GameServer_Status_CounterStatus{Count: c.Count, Capacity: c.Capacity}
+	l.update <- struct{}{}
+	l.recordRequest("setcounter")
+	return &sdk.Empty{}, nil
+}
+
+// SetList sets a List on the backing GameServer status
+func (l *LocalSDKServer) SetList(_ context.Context, list *sdk.List) (*sdk.Empty, error) {
+	logrus.WithField("values", list).Info("Setting list")
+	l.gsMutex.Lock()
+	defer l.gsMutex.Unlock()
+
+	if l.gs.Status == nil {
+		l.gs.Status = &sdk.GameServer_Status{}
+	}
+	if l.gs.Status.Lists == nil {
+		l.gs.Status.Lists = map[string]*sdk.GameServer_Status_ListStatus{}
+	}
+
+	l.gs.Status.Lists[list.Name] = &sdk.GameServer_Status_ListStatus{Capacity: list.Capacity, Values: list.Values}
+	l.update <- struct{}{}
+	l.recordRequest("setlist")
+	return &sdk.Empty{}, nil
+}
+
+// players returns the player status of the backing GameServer, creating it if needed.
+// Must be called with the gsMutex held.
+func (l *LocalSDKServer) players() *sdk.GameServer_Status_PlayerStatus {
+	if l.gs.Status == nil {
+		l.gs.Status = &sdk.GameServer_Status{}
+	}
+	if l.gs.Status.Players == nil {
+		l.gs.Status.Players = &sdk.GameServer_Status_PlayerStatus{}
+	}
+	return l.gs.Status.Players
+}
+
+// PlayerConnect adds the player to the connected players on the backing GameServer status
+func (l *LocalSDKServer) PlayerConnect(_ context.Context, id *sdk.PlayerID) (*sdk.Bool, error) {
+	logrus.WithField("playerID", id.PlayerID).Info("Player connected")
+	l.recordRequest("playerconnect")
+	l.gsMutex.Lock()
+	defer l.gsMutex.Unlock()
+
+	p := l.players()
+	for _, existing := range p.Ids {
+		if existing == id.PlayerID {
+			return &sdk.Bool{Bool: false}, nil
+		}
+	}
+	if p.Count >= p.Capacity {
+		return &sdk.Bool{Bool: false}, errors.Errorf("could not connect player %s: players are already at capacity", id.PlayerID)
+	}
+
+	p.Ids = append(p.Ids, id.PlayerID)
+	p.Count = int64(len(p.Ids))
+	l.update <- struct{}{}
+	return &sdk.Bool{Bool: true}, nil
+}
+
+// PlayerDisconnect removes the player from the connected players on the backing GameServer status
+func (l *LocalSDKServer) PlayerDisconnect(_ context.Context, id *sdk.PlayerID) (*sdk.Bool, error) {
+	logrus.WithField("playerID", id.PlayerID).Info("Player disconnected")
+	l.recordRequest("playerdisconnect")
+	l.gsMutex.Lock()
+	defer l.gsMutex.Unlock()
+
+	p := l.players()
+	for i, existing := range p.Ids {
+		if existing == id.PlayerID {
+			p.Ids = append(p.Ids[:i], p.Ids[i+1:]...)
+			p.Count = int64(len(p.Ids))
+			l.update <- struct{}{}
+			return &sdk.Bool{Bool: true}, nil
+		}
+	}
+	return &sdk.Bool{Bool: false}, nil
+}
+
+// SetPlayerCapacity sets the player capacity on the backing GameServer status
+func (l *LocalSDKServer) SetPlayerCapacity(_ context.Context, c *sdk.Count) (*sdk.Empty, error) {
+	logrus.WithField("capacity", c.Count).Info("Setting player capacity")
+	l.recordRequest("setplayercapacity")
+	l.gsMutex.Lock()
+	defer l.gsMutex.Unlock()
+
+	l.players().Capacity = c.Count
+	l.update <- struct{}{}
+	return &sdk.Empty{}, nil
+}
+
+// GetPlayerCount returns the number of connected players on the backing GameServer status
+func (l *LocalSDKServer) GetPlayerCount(context.Context, *sdk.Empty) (*sdk.Count, error) {
+	logrus.Info("Getting player count")
+	l.recordRequest("getplayercount")
+	l.gsMutex.RLock()
+	defer l.gsMutex.RUnlock()
+
+	if l.gs.Status == nil || l.gs.Status.Players == nil {
+		return &sdk.Count{}, nil
+	}
+	return &sdk.Count{Count: l.gs.Status.Players.Count}, nil
+}
+
 // GetGameServer returns a dummy game server.
 func (l *LocalSDKServer) GetGameServer(context.Context, *sdk.Empty) (*sdk.GameServer, error) {
 	logrus.Info("getting GameServer details")
@@ -283,6 +445,7 @@ func (l *LocalSDKServer) WatchGameServer(_ *sdk.Empty, stream sdk.SDK_WatchGameS
 // Reserve moves this GameServer to the Reserved state for the Duration specified
 func (l *LocalSDKServer) Reserve(_ context.Context, d *sdk.Duration) (*sdk.Empty, error) {
 	logrus.WithField("duration", d).Info("Reserve request has been received!")
+	l.recordRequest("reserve")
 	return &sdk.Empty{}, nil
 }
 
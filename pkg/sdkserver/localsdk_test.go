@@ -17,6 +17,8 @@ package sdkserver
 import (
 	"encoding/json"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"sync"
 	"testing"
@@ -96,6 +98,38 @@ func TestLocalSDKWithGameServer(t *testing.T) {
 	assert.Equal(t, fixture.ObjectMeta.Name, gs.ObjectMeta.Name)
 }
 
+func TestLocalSDKServerHealthHandler(t *testing.T) {
+	l, err := NewLocalSDKServer("")
+	assert.Nil(t, err)
+
+	gshealthz := func() int {
+		w := httptest.NewRecorder()
+		l.HealthHandler(w, httptest.NewRequest(http.MethodGet, "/gshealthz", nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, gshealthz(), "no health ping received yet")
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	stream := newEmptyMockStream()
+	go func() {
+		err := l.Health(stream)
+		assert.Nil(t, err)
+		wg.Done()
+	}()
+	stream.msgs <- &sdk.Empty{}
+	close(stream.msgs)
+	wg.Wait()
+
+	assert.Equal(t, http.StatusOK, gshealthz())
+
+	l.healthMutex.Lock()
+	l.healthLastUpdated = time.Now().Add(-time.Minute)
+	l.healthMutex.Unlock()
+	assert.Equal(t, http.StatusInternalServerError, gshealthz(), "last health ping is too old")
+}
+
 // nolint:dupl
 func TestLocalSDKServerSetLabel(t *testing.T) {
 	t.Parallel()
@@ -284,3 +318,67 @@ func gsToTmpFile(gs *v1alpha1.GameServer) (string, error) {
 	err = json.NewEncoder(file).Encode(gs)
 	return file.Name(), err
 }
+
+func TestLocalSDKServerSetCounterAndList(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	e := &sdk.Empty{}
+	l, err := NewLocalSDKServer("")
+	assert.Nil(t, err)
+	l.gs = &sdk.GameServer{}
+
+	_, err = l.SetCounter(ctx, &sdk.Counter{Name: "rooms", Count: 1, Capacity: 10})
+	assert.Nil(t, err)
+	_, err = l.SetList(ctx, &sdk.List{Name: "players", Capacity: 4, Values: []string{"a"}})
+	assert.Nil(t, err)
+
+	gs, err := l.GetGameServer(ctx, e)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), gs.Status.Counters["rooms"].Count)
+	assert.Equal(t, int64(10), gs.Status.Counters["rooms"].Capacity)
+	assert.Equal(t, int64(4), gs.Status.Lists["players"].Capacity)
+	assert.Equal(t, []string{"a"}, gs.Status.Lists["players"].Values)
+
+	l.Close()
+}
+
+func TestLocalSDKServerPlayerTracking(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	e := &sdk.Empty{}
+	l, err := NewLocalSDKServer("")
+	assert.Nil(t, err)
+	l.gs = &sdk.GameServer{}
+
+	_, err = l.SetPlayerCapacity(ctx, &sdk.Count{Count: 1})
+	assert.Nil(t, err)
+
+	ok, err := l.PlayerConnect(ctx, &sdk.PlayerID{PlayerID: "1"})
+	assert.Nil(t, err)
+	assert.True(t, ok.Bool)
+	_, err = l.PlayerConnect(ctx, &sdk.PlayerID{PlayerID: "2"})
+	assert.NotNil(t, err)
+
+	count, err := l.GetPlayerCount(ctx, e)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count.Count)
+
+	gs, err := l.GetGameServer(ctx, e)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1"}, gs.Status.Players.Ids)
+
+	ok, err = l.PlayerDisconnect(ctx, &sdk.PlayerID{PlayerID: "1"})
+	assert.Nil(t, err)
+	assert.True(t, ok.Bool)
+	ok, err = l.PlayerDisconnect(ctx, &sdk.PlayerID{PlayerID: "1"})
+	assert.Nil(t, err)
+	assert.False(t, ok.Bool)
+
+	count, err = l.GetPlayerCount(ctx, e)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count.Count)
+
+	l.Close()
+}
@@ -66,5 +66,23 @@ func convert(gs *v1alpha1.GameServer) *sdk.GameServer {
 		result.Status.Ports = append(result.Status.Ports, grpcPort)
 	}
 
+	if len(status.Counters) > 0 {
+		result.Status.Counters = make(map[string]*sdk.GameServer_Status_CounterStatus, len(status.Counters))
+		for k, c := range status.Counters {
+			result.Status.Counters[k] = &sdk.GameServer_Status_CounterStatus{Count: c.Count, Capacity: c.Capacity}
+		}
+	}
+
+	if len(status.Lists) > 0 {
+		result.Status.Lists = make(map[string]*sdk.GameServer_Status_ListStatus, len(status.Lists))
+		for k, l := range status.Lists {
+			result.Status.Lists[k] = &sdk.GameServer_Status_ListStatus{Capacity: l.Capacity, Values: l.Values}
+		}
+	}
+
+	if p := status.Players; p != nil {
+		result.Status.Players = &sdk.GameServer_Status_PlayerStatus{Count: p.Count, Capacity: p.Capacity, Ids: p.IDs}
+	}
+
 	return result
 }
@@ -51,6 +51,9 @@ func TestConvert(t *testing.T) {
 				{Name: "default", Port: 12345},
 				{Name: "beacon", Port: 123123},
 			},
+			Counters: map[string]v1alpha1.CounterStatus{"rooms": {Count: 1, Capacity: 10}},
+			Lists:    map[string]v1alpha1.ListStatus{"players": {Capacity: 4, Values: []string{"a", "b"}}},
+			Players:  &v1alpha1.PlayerStatus{Count: 1, Capacity: 8, IDs: []string{"player1"}},
 		},
 	}
 
@@ -73,6 +76,21 @@ func TestConvert(t *testing.T) {
 			assert.Equal(t, fp.Name, p.Name)
 			assert.Equal(t, fp.Port, p.Port)
 		}
+		assert.Len(t, sdkGs.Status.Counters, len(fixture.Status.Counters))
+		for name, fc := range fixture.Status.Counters {
+			assert.Equal(t, fc.Count, sdkGs.Status.Counters[name].Count)
+			assert.Equal(t, fc.Capacity, sdkGs.Status.Counters[name].Capacity)
+		}
+		assert.Len(t, sdkGs.Status.Lists, len(fixture.Status.Lists))
+		for name, fl := range fixture.Status.Lists {
+			assert.Equal(t, fl.Capacity, sdkGs.Status.Lists[name].Capacity)
+			assert.Equal(t, fl.Values, sdkGs.Status.Lists[name].Values)
+		}
+		if assert.NotNil(t, sdkGs.Status.Players) {
+			assert.Equal(t, fixture.Status.Players.Count, sdkGs.Status.Players.Count)
+			assert.Equal(t, fixture.Status.Players.Capacity, sdkGs.Status.Players.Capacity)
+			assert.Equal(t, fixture.Status.Players.IDs, sdkGs.Status.Players.Ids)
+		}
 	}
 
 	sdkGs := convert(fixture)
@@ -54,6 +54,10 @@ const (
 	updateState      Operation = "updateState"
 	updateLabel      Operation = "updateLabel"
 	updateAnnotation Operation = "updateAnnotation"
+	updateCounter    Operation = "updateCounter"
+	updateList       Operation = "updateList"
+	updatePlayers    Operation = "updatePlayers"
+	updateSDKStatus  Operation = "updateSDKStatus"
 )
 
 var (
@@ -82,6 +86,7 @@ type SDKServer struct {
 	server             *http.Server
 	clock              clock.Clock
 	health             stablev1alpha1.Health
+	restartPolicy      stablev1alpha1.RestartPolicy
 	healthTimeout      time.Duration
 	healthMutex        sync.RWMutex
 	healthLastUpdated  time.Time
@@ -93,14 +98,22 @@ type SDKServer struct {
 	recorder           record.EventRecorder
 	gsLabels           map[string]string
 	gsAnnotations      map[string]string
+	gsCounters         map[string]stablev1alpha1.CounterStatus
+	gsLists            map[string]stablev1alpha1.ListStatus
+	gsPlayerCapacity   int64
+	gsConnectedPlayers []string
 	gsState            stablev1alpha1.GameServerState
+	gsReserveDuration  time.Duration
+	gsSDKStatus        stablev1alpha1.SDKStatus
+	gsPingReported     time.Time
+	pingReportPeriod   time.Duration
 	gsUpdateMutex      sync.RWMutex
 	gsWaitForSync      sync.WaitGroup
 }
 
 // NewSDKServer creates a SDKServer that sets up an
 // InClusterConfig for Kubernetes
-func NewSDKServer(gameServerName, namespace string, kubeClient kubernetes.Interface,
+func NewSDKServer(gameServerName, namespace string, pingReportPeriod time.Duration, kubeClient kubernetes.Interface,
 	agonesClient versioned.Interface) (*SDKServer, error) {
 	mux := http.NewServeMux()
 
@@ -127,7 +140,10 @@ func NewSDKServer(gameServerName, namespace string, kubeClient kubernetes.Interf
 		streamMutex:        sync.RWMutex{},
 		gsLabels:           map[string]string{},
 		gsAnnotations:      map[string]string{},
+		gsCounters:         map[string]stablev1alpha1.CounterStatus{},
+		gsLists:            map[string]stablev1alpha1.ListStatus{},
 		gsUpdateMutex:      sync.RWMutex{},
+		pingReportPeriod:   pingReportPeriod,
 		gsWaitForSync:      sync.WaitGroup{},
 	}
 
@@ -191,6 +207,12 @@ func (s *SDKServer) Run(stop <-chan struct{}) error {
 	if !cache.WaitForCacheSync(stop, s.gameServerSynced) {
 		return errors.New("failed to wait for caches to sync")
 	}
+	// load the players before player requests stop waiting for the sync, so they
+	// carry on from the players already recorded, e.g. if the sidecar was restarted
+	if gs, err := s.gameServerLister.GameServers(s.namespace).Get(s.gameServerName); err == nil && gs.Status.Players != nil {
+		s.gsPlayerCapacity = gs.Status.Players.Capacity
+		s.gsConnectedPlayers = append([]string(nil), gs.Status.Players.IDs...)
+	}
 	// we have the gameserver details now
 	s.gsWaitForSync.Done()
 
@@ -203,6 +225,7 @@ func (s *SDKServer) Run(stop <-chan struct{}) error {
 	s.health = gs.Spec.Health
 	s.logger.WithField("health", s.health).Info("setting health configuration")
 	s.healthTimeout = time.Duration(gs.Spec.Health.PeriodSeconds) * time.Second
+	s.restartPolicy = gs.Spec.RestartPolicy
 	s.initHealthLastUpdated(time.Duration(gs.Spec.Health.InitialDelaySeconds) * time.Second)
 
 	// start health checking running
@@ -242,6 +265,14 @@ func (s *SDKServer) syncGameServer(key string) error {
 		return s.updateLabels()
 	case updateAnnotation:
 		return s.updateAnnotations()
+	case updateCounter:
+		return s.updateCounters()
+	case updateList:
+		return s.updateLists()
+	case updatePlayers:
+		return s.updatePlayers()
+	case updateSDKStatus:
+		return s.updateSDKStatus()
 	}
 
 	return errors.Errorf("could not sync game server key: %s", key)
@@ -275,6 +306,13 @@ func (s *SDKServer) updateState() error {
 
 	s.gsUpdateMutex.RLock()
 	gs.Status.State = s.gsState
+	s.setSDKStatus(gs)
+	// the gameservers controller moves the GameServer back to Ready once ReservedUntil has passed
+	gs.Status.ReservedUntil = nil
+	if s.gsState == stablev1alpha1.GameServerStateReserved && s.gsReserveDuration > 0 {
+		until := metav1.NewTime(s.clock.Now().Add(s.gsReserveDuration))
+		gs.Status.ReservedUntil = &until
+	}
 	s.gsUpdateMutex.RUnlock()
 
 	_, err = gameServers.Update(gs)
@@ -350,6 +388,149 @@ func (s *SDKServer) updateAnnotations() error {
 	return err
 }
 
+// updateCounters updates the Counters in this GameServer's status to the ones persisted in SDKServer,
+// i.e. SDKServer.gsCounters
+func (s *SDKServer) updateCounters() error {
+	s.logger.WithField("counters", s.gsCounters).Info("updating counters")
+	gs, err := s.gameServer()
+	if err != nil {
+		return err
+	}
+
+	gsCopy := gs.DeepCopy()
+
+	s.gsUpdateMutex.RLock()
+	if len(s.gsCounters) > 0 && gsCopy.Status.Counters == nil {
+		gsCopy.Status.Counters = map[string]stablev1alpha1.CounterStatus{}
+	}
+	for k, v := range s.gsCounters {
+		gsCopy.Status.Counters[k] = v
+	}
+	s.gsUpdateMutex.RUnlock()
+
+	_, err = s.gameServerGetter.GameServers(s.namespace).Update(gsCopy)
+	return err
+}
+
+// updateLists updates the Lists in this GameServer's status to the ones persisted in SDKServer,
+// i.e. SDKServer.gsLists
+func (s *SDKServer) updateLists() error {
+	s.logger.WithField("lists", s.gsLists).Info("updating lists")
+	gs, err := s.gameServer()
+	if err != nil {
+		return err
+	}
+
+	gsCopy := gs.DeepCopy()
+
+	s.gsUpdateMutex.RLock()
+	if len(s.gsLists) > 0 && gsCopy.Status.Lists == nil {
+		gsCopy.Status.Lists = map[string]stablev1alpha1.ListStatus{}
+	}
+	for k, v := range s.gsLists {
+		gsCopy.Status.Lists[k] = *v.DeepCopy()
+	}
+	s.gsUpdateMutex.RUnlock()
+
+	_, err = s.gameServerGetter.GameServers(s.namespace).Update(gsCopy)
+	return err
+}
+
+// updatePlayers updates the Players in this GameServer's status to the ones persisted in SDKServer,
+// i.e. SDKServer.gsPlayerCapacity and SDKServer.gsConnectedPlayers
+func (s *SDKServer) updatePlayers() error {
+	gs, err := s.gameServer()
+	if err != nil {
+		return err
+	}
+
+	gsCopy := gs.DeepCopy()
+
+	s.gsUpdateMutex.RLock()
+	gsCopy.Status.Players = &stablev1alpha1.PlayerStatus{
+		Count:    int64(len(s.gsConnectedPlayers)),
+		Capacity: s.gsPlayerCapacity,
+		IDs:      append([]string(nil), s.gsConnectedPlayers...),
+	}
+	s.gsUpdateMutex.RUnlock()
+
+	s.logger.WithField("players", gsCopy.Status.Players).Info("updating players")
+	_, err = s.gameServerGetter.GameServers(s.namespace).Update(gsCopy)
+	return err
+}
+
+// updateSDKStatus sets the SDK status of this GameServer to the connection state
+// persisted in SDKServer, i.e. SDKServer.gsSDKStatus
+func (s *SDKServer) updateSDKStatus() error {
+	gs, err := s.gameServer()
+	if err != nil {
+		return err
+	}
+
+	gsCopy := gs.DeepCopy()
+
+	s.gsUpdateMutex.RLock()
+	s.setSDKStatus(gsCopy)
+	s.gsUpdateMutex.RUnlock()
+	if gsCopy.Status.SDK == nil {
+		return nil
+	}
+
+	s.logger.WithField("sdk", gsCopy.Status.SDK).Info("updating SDK status")
+	_, err = s.gameServerGetter.GameServers(s.namespace).Update(gsCopy)
+	return err
+}
+
+// setSDKStatus sets the SDK status of the GameServer, once the game server process
+// has called the SDK. gsUpdateMutex must be held.
+func (s *SDKServer) setSDKStatus(gs *stablev1alpha1.GameServer) {
+	if s.gsSDKStatus.FirstCall == "" {
+		return
+	}
+	gs.Status.SDK = s.gsSDKStatus.DeepCopy()
+}
+
+// connected records that the game server process has called the SDK, and returns
+// whether this is the first call it has made. gsUpdateMutex must be held.
+func (s *SDKServer) connected(call string, now time.Time) bool {
+	if s.gsSDKStatus.FirstCall != "" {
+		return false
+	}
+	s.gsSDKStatus.FirstCall = call
+	s.gsSDKStatus.ConnectedTime = metav1.NewTime(now)
+	return true
+}
+
+// enqueueConnected enqueues an update of the SDK status, if this
+// is the first SDK call from the game server process
+func (s *SDKServer) enqueueConnected(call string) {
+	s.gsUpdateMutex.Lock()
+	first := s.connected(call, s.clock.Now())
+	s.gsUpdateMutex.Unlock()
+	if first {
+		s.workerqueue.Enqueue(cache.ExplicitKey(string(updateSDKStatus)))
+	}
+}
+
+// enqueuePing records a health ping from the game server process, and enqueues an update of the
+// SDK status if it is the first SDK call, or, if pings are reported, the last one was reported
+// at least pingReportPeriod ago
+func (s *SDKServer) enqueuePing() {
+	now := s.clock.Now()
+	s.gsUpdateMutex.Lock()
+	first := s.connected("Health", now)
+	report := s.pingReportPeriod > 0 && (first || now.Sub(s.gsPingReported) >= s.pingReportPeriod)
+	if report {
+		s.gsPingReported = now
+		ping := metav1.NewTime(now)
+		s.gsSDKStatus.LastPingTime = &ping
+	}
+	s.gsUpdateMutex.Unlock()
+	if first || report {
+		s.workerqueue.Enqueue(cache.ExplicitKey(string(updateSDKStatus)))
+	}
+}
+
 // enqueueState enqueue a State change request into the
 // workerqueue
 func (s *SDKServer) enqueueState(state stablev1alpha1.GameServerState) {
@@ -363,6 +544,10 @@ func (s *SDKServer) enqueueState(state stablev1alpha1.GameServerState) {
 // the workqueue so it can be updated
 func (s *SDKServer) Ready(ctx context.Context, e *sdk.Empty) (*sdk.Empty, error) {
 	s.logger.Info("Received Ready request, adding to queue")
+	// the state update sets the SDK status as well
+	s.gsUpdateMutex.Lock()
+	s.connected("Ready", s.clock.Now())
+	s.gsUpdateMutex.Unlock()
 	s.enqueueState(stablev1alpha1.GameServerStateRequestReady)
 	return e, nil
 }
@@ -394,6 +579,7 @@ func (s *SDKServer) Allocate(context.Context, *sdk.Empty) (*sdk.Empty, error) {
 
 		gsCopy := gs.DeepCopy()
 		gsCopy.Status.State = stablev1alpha1.GameServerStateAllocated
+		gsCopy.Status.ReservedUntil = nil
 		_, err = s.gameServerGetter.GameServers(s.namespace).Update(gsCopy)
 
 		// if a contention, and we are under the timeout period.
@@ -432,6 +618,7 @@ func (s *SDKServer) Health(stream sdk.SDK_HealthServer) error {
 			return errors.Wrap(err, "Error with Health check")
 		}
 		s.logger.Info("Health Ping Received")
+		s.enqueuePing()
 		s.touchHealthLastUpdated()
 	}
 }
@@ -462,9 +649,115 @@ func (s *SDKServer) SetAnnotation(_ context.Context, kv *sdk.KeyValue) (*sdk.Emp
 	return &sdk.Empty{}, nil
 }
 
+// SetCounter stores the count and capacity of the Counter, to be set in the `GameServer` status.
+// Returns an error if the count is negative or more than the capacity.
+func (s *SDKServer) SetCounter(_ context.Context, c *sdk.Counter) (*sdk.Empty, error) {
+	s.logger.WithField("values", c).Info("Adding SetCounter to queue")
+
+	counter := stablev1alpha1.CounterStatus{Count: c.Count, Capacity: c.Capacity}
+	if err := counter.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "could not set counter %s", c.Name)
+	}
+
+	s.gsUpdateMutex.Lock()
+	s.gsCounters[c.Name] = counter
+	s.gsUpdateMutex.Unlock()
+
+	s.workerqueue.Enqueue(cache.ExplicitKey(string(updateCounter)))
+	return &sdk.Empty{}, nil
+}
+
+// SetList stores the values and capacity of the List, to be set in the `GameServer` status.
+// Returns an error if there are more values than the capacity, or duplicate values.
+func (s *SDKServer) SetList(_ context.Context, l *sdk.List) (*sdk.Empty, error) {
+	s.logger.WithField("values", l).Info("Adding SetList to queue")
+
+	list := stablev1alpha1.ListStatus{Capacity: l.Capacity, Values: append([]string(nil), l.Values...)}
+	if err := list.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "could not set list %s", l.Name)
+	}
+
+	s.gsUpdateMutex.Lock()
+	s.gsLists[l.Name] = list
+	s.gsUpdateMutex.Unlock()
+
+	s.workerqueue.Enqueue(cache.ExplicitKey(string(updateList)))
+	return &sdk.Empty{}, nil
+}
+
+// PlayerConnect adds the player to the connected players, to be set in the `GameServer` status.
+// Returns false if the player is already connected, and an error if the GameServer is at player capacity.
+func (s *SDKServer) PlayerConnect(_ context.Context, id *sdk.PlayerID) (*sdk.Bool, error) {
+	s.logger.WithField("playerID", id.PlayerID).Info("Adding PlayerConnect to queue")
+	s.gsWaitForSync.Wait()
+
+	s.gsUpdateMutex.Lock()
+	defer s.gsUpdateMutex.Unlock()
+
+	for _, p := range s.gsConnectedPlayers {
+		if p == id.PlayerID {
+			return &sdk.Bool{Bool: false}, nil
+		}
+	}
+	if int64(len(s.gsConnectedPlayers)) >= s.gsPlayerCapacity {
+		return &sdk.Bool{Bool: false}, errors.Errorf("could not connect player %s: players are already at capacity", id.PlayerID)
+	}
+	s.gsConnectedPlayers = append(s.gsConnectedPlayers, id.PlayerID)
+
+	s.workerqueue.Enqueue(cache.ExplicitKey(string(updatePlayers)))
+	return &sdk.Bool{Bool: true}, nil
+}
+
+// PlayerDisconnect removes the player from the connected players, to be set in the `GameServer` status.
+// Returns false if the player is not connected.
+func (s *SDKServer) PlayerDisconnect(_ context.Context, id *sdk.PlayerID) (*sdk.Bool, error) {
+	s.logger.WithField("playerID", id.PlayerID).Info("Adding PlayerDisconnect to queue")
+	s.gsWaitForSync.Wait()
+
+	s.gsUpdateMutex.Lock()
+	defer s.gsUpdateMutex.Unlock()
+
+	for i, p := range s.gsConnectedPlayers {
+		if p == id.PlayerID {
+			s.gsConnectedPlayers = append(s.gsConnectedPlayers[:i], s.gsConnectedPlayers[i+1:]...)
+			s.workerqueue.Enqueue(cache.ExplicitKey(string(updatePlayers)))
+			return &sdk.Bool{Bool: true}, nil
+		}
+	}
+
+	return &sdk.Bool{Bool: false}, nil
+}
+
+// SetPlayerCapacity stores the player capacity, to be set in the `GameServer` status.
+// Returns an error if the capacity is negative.
+func (s *SDKServer) SetPlayerCapacity(_ context.Context, c *sdk.Count) (*sdk.Empty, error) {
+	s.logger.WithField("capacity", c.Count).Info("Adding SetPlayerCapacity to queue")
+
+	if c.Count < 0 {
+		return nil, errors.New(stablev1alpha1.ErrPlayerCapacityInvalid)
+	}
+	s.gsWaitForSync.Wait()
+
+	s.gsUpdateMutex.Lock()
+	s.gsPlayerCapacity = c.Count
+	s.gsUpdateMutex.Unlock()
+
+	s.workerqueue.Enqueue(cache.ExplicitKey(string(updatePlayers)))
+	return &sdk.Empty{}, nil
+}
+
+// GetPlayerCount returns the number of players currently connected
+func (s *SDKServer) GetPlayerCount(context.Context, *sdk.Empty) (*sdk.Count, error) {
+	s.gsWaitForSync.Wait()
+	s.gsUpdateMutex.RLock()
+	defer s.gsUpdateMutex.RUnlock()
+	return &sdk.Count{Count: int64(len(s.gsConnectedPlayers))}, nil
+}
+
 // GetGameServer returns the current GameServer configuration and state from the backing GameServer CRD
 func (s *SDKServer) GetGameServer(context.Context, *sdk.Empty) (*sdk.GameServer, error) {
 	s.logger.Info("Received GetGameServer request")
+	s.enqueueConnected("GetGameServer")
 	gs, err := s.gameServer()
 	if err != nil {
 		return nil, err
@@ -477,18 +770,47 @@ func (s *SDKServer) GetGameServer(context.Context, *sdk.Empty) (*sdk.GameServer,
 // backing GameServer configuration / status
 func (s *SDKServer) WatchGameServer(_ *sdk.Empty, stream sdk.SDK_WatchGameServerServer) error {
 	s.logger.Info("Received WatchGameServer request, adding stream to connectedStreams")
+	s.enqueueConnected("WatchGameServer")
 	s.streamMutex.Lock()
 	s.connectedStreams = append(s.connectedStreams, stream)
 	s.streamMutex.Unlock()
-	// don't exit until we shutdown, because that will close the stream
-	<-s.stop
+	// don't exit until we shutdown, because that will close the stream,
+	// or the game server process disconnects, so it stops being sent updates
+	select {
+	case <-s.stop:
+	case <-stream.Context().Done():
+		s.logger.Info("WatchGameServer stream disconnected, removing from connectedStreams")
+		s.removeConnectedStream(stream)
+	}
 	return nil
 }
 
-// Reserve moves this GameServer to the Reserved state for the Duration specified
-// TODO: implement this functionality
+// removeConnectedStream stops sending GameServer updates to the stream
+func (s *SDKServer) removeConnectedStream(stream sdk.SDK_WatchGameServerServer) {
+	s.streamMutex.Lock()
+	defer s.streamMutex.Unlock()
+	for i, st := range s.connectedStreams {
+		if st == stream {
+			s.connectedStreams = append(s.connectedStreams[:i], s.connectedStreams[i+1:]...)
+			return
+		}
+	}
+}
+
+// Reserve moves this GameServer to the Reserved state for the Duration specified.
+// A Duration of 0 reserves the GameServer until the state is next changed through the SDK.
 func (s *SDKServer) Reserve(_ context.Context, d *sdk.Duration) (*sdk.Empty, error) {
-	return &sdk.Empty{}, errors.New("not implemented")
+	s.logger.WithField("duration", d).Info("Received Reserve request, adding to queue")
+	if d.Seconds < 0 {
+		return nil, errors.Errorf("could not reserve for %d seconds: duration must not be negative", d.Seconds)
+	}
+
+	s.gsUpdateMutex.Lock()
+	s.gsReserveDuration = time.Duration(d.Seconds) * time.Second
+	s.gsUpdateMutex.Unlock()
+
+	s.enqueueState(stablev1alpha1.GameServerStateReserved)
+	return &sdk.Empty{}, nil
 }
 
 // sendGameServerUpdate sends a watch game server event
@@ -512,11 +834,15 @@ func (s *SDKServer) sendGameServerUpdate(gs *stablev1alpha1.GameServer) {
 
 // runHealth actively checks the health, and if not
 // healthy will push the Unhealthy state into the queue so
-// it can be updated
+// it can be updated. With the InPlace RestartPolicy, the kubelet restarts
+// the game server container through its liveness probe instead.
 func (s *SDKServer) runHealth() {
 	s.checkHealth()
 	if !s.healthy() {
 		s.logger.WithField("gameServerName", s.gameServerName).Info("has failed health check")
+		if s.restartPolicy == stablev1alpha1.RestartInPlace {
+			return
+		}
 		s.enqueueState(stablev1alpha1.GameServerStateUnhealthy)
 	}
 }
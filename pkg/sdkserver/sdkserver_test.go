@@ -45,6 +45,8 @@ func TestSidecarRun(t *testing.T) {
 		state       v1alpha1.GameServerState
 		labels      map[string]string
 		annotations map[string]string
+		counters    map[string]v1alpha1.CounterStatus
+		lists       map[string]v1alpha1.ListStatus
 		recordings  []string
 	}
 
@@ -115,6 +117,28 @@ func TestSidecarRun(t *testing.T) {
 				state: v1alpha1.GameServerStateAllocated,
 			},
 		},
+		"counter": {
+			f: func(sc *SDKServer, ctx context.Context) {
+				_, err := sc.SetCounter(ctx, &sdk.Counter{Name: "rooms", Count: 2, Capacity: 10})
+				assert.NoError(t, err)
+				_, err = sc.SetCounter(ctx, &sdk.Counter{Name: "invalid", Count: 11, Capacity: 10})
+				assert.Error(t, err)
+			},
+			expected: expected{
+				counters: map[string]v1alpha1.CounterStatus{"rooms": {Count: 2, Capacity: 10}},
+			},
+		},
+		"list": {
+			f: func(sc *SDKServer, ctx context.Context) {
+				_, err := sc.SetList(ctx, &sdk.List{Name: "players", Capacity: 4, Values: []string{"a", "b"}})
+				assert.NoError(t, err)
+				_, err = sc.SetList(ctx, &sdk.List{Name: "invalid", Capacity: 1, Values: []string{"a", "b"}})
+				assert.Error(t, err)
+			},
+			expected: expected{
+				lists: map[string]v1alpha1.ListStatus{"players": {Capacity: 4, Values: []string{"a", "b"}}},
+			},
+		},
 	}
 
 	for k, v := range fixtures {
@@ -152,11 +176,19 @@ func TestSidecarRun(t *testing.T) {
 				for ann, value := range v.expected.annotations {
 					assert.Equal(t, value, gs.ObjectMeta.Annotations[ann])
 				}
+				for name, value := range v.expected.counters {
+					assert.Equal(t, value, gs.Status.Counters[name])
+				}
+				for name, value := range v.expected.lists {
+					assert.Equal(t, value, gs.Status.Lists[name])
+				}
+				assert.NotContains(t, gs.Status.Counters, "invalid")
+				assert.NotContains(t, gs.Status.Lists, "invalid")
 
 				return true, gs, nil
 			})
 
-			sc, err := NewSDKServer("test", "default", m.KubeClient, m.AgonesClient)
+			sc, err := NewSDKServer("test", "default", 0, m.KubeClient, m.AgonesClient)
 			stop := make(chan struct{})
 			defer close(stop)
 			sc.informerFactory.Start(stop)
@@ -357,6 +389,84 @@ func TestSidecarUpdateState(t *testing.T) {
 	}
 }
 
+func TestSDKServerUpdateSDKStatus(t *testing.T) {
+	t.Parallel()
+
+	m := agtesting.NewMocks()
+	sc, err := defaultSidecar(m)
+	assert.Nil(t, err)
+	now := time.Now().UTC()
+	fc := clock.NewFakeClock(now)
+	sc.clock = fc
+	sc.pingReportPeriod = time.Minute
+	var updated *v1alpha1.SDKStatus
+
+	m.AgonesClient.AddReactor("list", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gs := v1alpha1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: sc.gameServerName, Namespace: sc.namespace},
+			Status:     v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateScheduled},
+		}
+		return true, &v1alpha1.GameServerList{Items: []v1alpha1.GameServer{gs}}, nil
+	})
+	m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		ua := action.(k8stesting.UpdateAction)
+		gs := ua.GetObject().(*v1alpha1.GameServer)
+		updated = gs.Status.SDK
+		return true, gs, nil
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	sc.informerFactory.Start(stop)
+	assert.True(t, cache.WaitForCacheSync(stop, sc.gameServerSynced))
+	sc.gsWaitForSync.Done()
+
+	// nothing to update until the game server has called the SDK
+	assert.Nil(t, sc.updateSDKStatus())
+	assert.Nil(t, updated)
+
+	sc.enqueuePing()
+	assert.Nil(t, sc.updateSDKStatus())
+	if assert.NotNil(t, updated) {
+		assert.Equal(t, "Health", updated.FirstCall)
+		assert.Equal(t, now, updated.ConnectedTime.Time)
+		assert.Equal(t, now, updated.LastPingTime.Time)
+	}
+
+	// pings are only reported every pingReportPeriod
+	fc.Step(sc.pingReportPeriod / 2)
+	sc.enqueuePing()
+	sc.enqueueConnected("Ready")
+	assert.Equal(t, now, sc.gsSDKStatus.LastPingTime.Time)
+	assert.Equal(t, "Health", sc.gsSDKStatus.FirstCall)
+
+	fc.Step(sc.pingReportPeriod / 2)
+	sc.enqueuePing()
+	assert.Equal(t, now.Add(sc.pingReportPeriod), sc.gsSDKStatus.LastPingTime.Time)
+	assert.Equal(t, now, sc.gsSDKStatus.ConnectedTime.Time)
+}
+
+func TestSDKServerUpdateSDKStatusNoPingReports(t *testing.T) {
+	t.Parallel()
+
+	m := agtesting.NewMocks()
+	sc, err := defaultSidecar(m)
+	assert.Nil(t, err)
+	now := time.Now().UTC()
+	fc := clock.NewFakeClock(now)
+	sc.clock = fc
+
+	// the first call is still reported, but none of the pings
+	sc.enqueuePing()
+	assert.Equal(t, "Health", sc.gsSDKStatus.FirstCall)
+	assert.Equal(t, now, sc.gsSDKStatus.ConnectedTime.Time)
+	assert.Nil(t, sc.gsSDKStatus.LastPingTime)
+
+	fc.Step(time.Hour)
+	sc.enqueuePing()
+	assert.Nil(t, sc.gsSDKStatus.LastPingTime)
+}
+
 func TestSidecarHealthLastUpdated(t *testing.T) {
 	t.Parallel()
 	now := time.Now().UTC()
@@ -507,9 +617,42 @@ func TestSidecarHealthy(t *testing.T) {
 	wg.Wait()
 }
 
+func TestSidecarRunHealth(t *testing.T) {
+	t.Parallel()
+
+	fixtures := map[string]struct {
+		restartPolicy v1alpha1.RestartPolicy
+		expected      v1alpha1.GameServerState
+	}{
+		"never":    {restartPolicy: v1alpha1.RestartNever, expected: v1alpha1.GameServerStateUnhealthy},
+		"in place": {restartPolicy: v1alpha1.RestartInPlace, expected: v1alpha1.GameServerStateReady},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			m := agtesting.NewMocks()
+			sc, err := defaultSidecar(m)
+			assert.Nil(t, err)
+
+			sc.health = v1alpha1.Health{FailureThreshold: 1}
+			sc.healthTimeout = 5 * time.Second
+			sc.restartPolicy = v.restartPolicy
+			sc.gsState = v1alpha1.GameServerStateReady
+			fc := clock.NewFakeClock(time.Now().UTC())
+			sc.clock = fc
+			sc.initHealthLastUpdated(0)
+
+			fc.Step(10 * time.Second)
+			sc.runHealth()
+			assert.False(t, sc.healthy())
+			assert.Equal(t, v.expected, sc.gsState)
+		})
+	}
+}
+
 func TestSidecarHTTPHealthCheck(t *testing.T) {
 	m := agtesting.NewMocks()
-	sc, err := NewSDKServer("test", "default", m.KubeClient, m.AgonesClient)
+	sc, err := NewSDKServer("test", "default", 0, m.KubeClient, m.AgonesClient)
 	assert.Nil(t, err)
 	now := time.Now().Add(time.Hour).UTC()
 	fc := clock.NewFakeClock(now)
@@ -607,6 +750,17 @@ func TestSDKServerWatchGameServer(t *testing.T) {
 	assert.Nil(t, waitConnectedStreamCount(sc, 2))
 	assert.Len(t, sc.connectedStreams, 2)
 	assert.Equal(t, stream, sc.connectedStreams[1])
+
+	// disconnected streams are no longer sent updates
+	ctx, cancel := context.WithCancel(context.Background())
+	disconnected := newGameServerMockStream()
+	disconnected.ctx = ctx
+	asyncWatchGameServer(t, sc, disconnected)
+	assert.Nil(t, waitConnectedStreamCount(sc, 3))
+	cancel()
+	assert.Nil(t, waitConnectedStreamCount(sc, 2))
+	assert.NotContains(t, sc.connectedStreams, disconnected)
+	assert.Equal(t, stream, sc.connectedStreams[1])
 }
 
 func TestSDKServerSendGameServerUpdate(t *testing.T) {
@@ -851,7 +1005,7 @@ func TestSDKServerAllocate(t *testing.T) {
 }
 
 func defaultSidecar(m agtesting.Mocks) (*SDKServer, error) {
-	server, err := NewSDKServer("test", "default", m.KubeClient, m.AgonesClient)
+	server, err := NewSDKServer("test", "default", 0, m.KubeClient, m.AgonesClient)
 	if err != nil {
 		return server, err
 	}
@@ -882,3 +1036,144 @@ func asyncWatchGameServer(t *testing.T, sc *SDKServer, stream sdk.SDK_WatchGameS
 		assert.Nil(t, err)
 	}()
 }
+
+func TestSDKServerReserve(t *testing.T) {
+	t.Parallel()
+
+	m := agtesting.NewMocks()
+	fixture := v1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Status:     v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReady},
+	}
+	m.AgonesClient.AddReactor("list", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &v1alpha1.GameServerList{Items: []v1alpha1.GameServer{fixture}}, nil
+	})
+	updated := make(chan *v1alpha1.GameServer, 10)
+	m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gs := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.GameServer)
+		updated <- gs
+		return true, gs, nil
+	})
+
+	sc, err := defaultSidecar(m)
+	assert.NoError(t, err)
+	now := time.Now()
+	sc.clock = clock.NewFakeClock(now)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	sc.informerFactory.Start(stop)
+	assert.True(t, cache.WaitForCacheSync(stop, sc.gameServerSynced))
+	sc.gsWaitForSync.Done()
+	go sc.workerqueue.Run(1, stop)
+
+	wait := func() *v1alpha1.GameServer {
+		select {
+		case gs := <-updated:
+			return gs
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "GameServer was not updated")
+		}
+		return nil
+	}
+
+	_, err = sc.Reserve(context.Background(), &sdk.Duration{Seconds: -1})
+	assert.Error(t, err)
+
+	_, err = sc.Reserve(context.Background(), &sdk.Duration{Seconds: 10})
+	assert.NoError(t, err)
+	gs := wait()
+	assert.Equal(t, v1alpha1.GameServerStateReserved, gs.Status.State)
+	if assert.NotNil(t, gs.Status.ReservedUntil) {
+		assert.Equal(t, now.Add(10*time.Second).Unix(), gs.Status.ReservedUntil.Unix())
+	}
+
+	_, err = sc.Reserve(context.Background(), &sdk.Duration{Seconds: 0})
+	assert.NoError(t, err)
+	gs = wait()
+	assert.Equal(t, v1alpha1.GameServerStateReserved, gs.Status.State)
+	assert.Nil(t, gs.Status.ReservedUntil)
+}
+
+func TestSDKServerPlayerTracking(t *testing.T) {
+	t.Parallel()
+
+	m := agtesting.NewMocks()
+	fixture := v1alpha1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: v1alpha1.GameServerSpec{
+			Health:  v1alpha1.Health{Disabled: true},
+			Players: &v1alpha1.PlayerSpec{InitialCapacity: 2},
+		},
+		Status: v1alpha1.GameServerStatus{State: v1alpha1.GameServerStateReady},
+	}
+	fixture.ApplyDefaults()
+
+	m.AgonesClient.AddReactor("list", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &v1alpha1.GameServerList{Items: []v1alpha1.GameServer{fixture}}, nil
+	})
+	updated := make(chan *v1alpha1.PlayerStatus, 10)
+	m.AgonesClient.AddReactor("update", "gameservers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gs := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.GameServer)
+		updated <- gs.Status.Players
+		return true, gs, nil
+	})
+
+	sc, err := defaultSidecar(m)
+	assert.NoError(t, err)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		err := sc.Run(stop)
+		assert.NoError(t, err)
+	}()
+
+	ctx := context.Background()
+	count, err := sc.GetPlayerCount(ctx, &sdk.Empty{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count.Count)
+
+	ok, err := sc.PlayerConnect(ctx, &sdk.PlayerID{PlayerID: "1"})
+	assert.NoError(t, err)
+	assert.True(t, ok.Bool)
+	ok, err = sc.PlayerConnect(ctx, &sdk.PlayerID{PlayerID: "1"})
+	assert.NoError(t, err)
+	assert.False(t, ok.Bool)
+	ok, err = sc.PlayerConnect(ctx, &sdk.PlayerID{PlayerID: "2"})
+	assert.NoError(t, err)
+	assert.True(t, ok.Bool)
+
+	// the initial capacity is 2
+	_, err = sc.PlayerConnect(ctx, &sdk.PlayerID{PlayerID: "3"})
+	assert.Error(t, err)
+
+	ok, err = sc.PlayerDisconnect(ctx, &sdk.PlayerID{PlayerID: "1"})
+	assert.NoError(t, err)
+	assert.True(t, ok.Bool)
+	ok, err = sc.PlayerDisconnect(ctx, &sdk.PlayerID{PlayerID: "1"})
+	assert.NoError(t, err)
+	assert.False(t, ok.Bool)
+
+	_, err = sc.SetPlayerCapacity(ctx, &sdk.Count{Count: -1})
+	assert.Error(t, err)
+	_, err = sc.SetPlayerCapacity(ctx, &sdk.Count{Count: 10})
+	assert.NoError(t, err)
+
+	count, err = sc.GetPlayerCount(ctx, &sdk.Empty{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count.Count)
+
+	expected := &v1alpha1.PlayerStatus{Count: 1, Capacity: 10, IDs: []string{"2"}}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case p := <-updated:
+			if assert.ObjectsAreEqual(expected, p) {
+				return
+			}
+		case <-timeout:
+			assert.Fail(t, "players were not updated")
+			return
+		}
+	}
+}
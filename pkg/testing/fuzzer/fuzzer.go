@@ -0,0 +1,206 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fuzzer provides helpers for fuzz testing the Agones API types,
+// such as their serialisation, defaulting and the patches created by the
+// admission webhooks.
+// It is kept separate from the testing package, so that it can be used by
+// the API packages without an import cycle.
+package fuzzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gofuzz"
+	"github.com/mattbaird/jsonpatch"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// SeedEnv is the environment variable that can be set to replay a
+// previous fuzzing run with the same seed
+const SeedEnv = "AGONES_FUZZ_SEED"
+
+// Seed returns the seed to fuzz with. This is taken from SeedEnv
+// if it is set, otherwise it is based on the current time.
+func Seed() int64 {
+	if s, err := strconv.ParseInt(os.Getenv(SeedEnv), 10, 64); err == nil {
+		return s
+	}
+	return time.Now().UnixNano()
+}
+
+// maxJSONInt is the largest integer that can be held exactly as a json number, as
+// jsonpatch.CreatePatch decodes the values in the patches it creates into float64s
+const maxJSONInt = 1 << 53
+
+// New returns a Fuzzer with the given seed, which fills Kubernetes types
+// that can't be filled with random values, such as resource.Quantity,
+// with values that are valid and survive a JSON round trip.
+func New(seed int64) *fuzz.Fuzzer {
+	return fuzz.New().
+		RandSource(rand.NewSource(seed)).
+		NilChance(.2).
+		NumElements(1, 3).
+		Funcs(
+			func(i *int64, c fuzz.Continue) {
+				*i = c.Int63n(2*maxJSONInt) - maxJSONInt
+			},
+			func(i *int, c fuzz.Continue) {
+				*i = int(c.Int63n(2*maxJSONInt) - maxJSONInt)
+			},
+			func(i *uint64, c fuzz.Continue) {
+				*i = uint64(c.Int63n(maxJSONInt))
+			},
+			func(q *resource.Quantity, c fuzz.Continue) {
+				*q = *resource.NewQuantity(c.Int63n(1000), resource.DecimalSI)
+			},
+			func(i *intstr.IntOrString, c fuzz.Continue) {
+				if c.RandBool() {
+					*i = intstr.FromInt(c.Intn(100))
+					return
+				}
+				*i = intstr.FromString(strconv.Itoa(c.Intn(100)) + "%")
+			},
+			func(t *metav1.Time, c fuzz.Continue) {
+				// JSON only keeps second precision
+				*t = metav1.Unix(c.Int63n(1<<32), 0)
+			},
+		)
+}
+
+// ApplyJSONPatch applies the json patch created by a mutating webhook to
+// the original json document, and returns the patched document.
+// Only the add, replace and remove operations that jsonpatch.CreatePatch
+// generates are supported.
+func ApplyJSONPatch(original []byte, patch []jsonpatch.JsonPatchOperation) ([]byte, error) {
+	// keep numbers as they are, so large integers don't lose precision as float64s
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(original))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling original document")
+	}
+
+	for _, op := range patch {
+		var err error
+		doc, err = applyOperation(doc, pathTokens(op.Path), op)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error applying %s to %s", op.Operation, op.Path)
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+// pathTokens splits a json pointer into its unescaped reference tokens
+func pathTokens(path string) []string {
+	if path == "" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens
+}
+
+// applyOperation applies op at the location of tokens within node, and
+// returns the updated node
+func applyOperation(node interface{}, tokens []string, op jsonpatch.JsonPatchOperation) (interface{}, error) {
+	if len(tokens) == 0 {
+		if op.Operation == "remove" {
+			return nil, nil
+		}
+		return op.Value, nil
+	}
+
+	key, rest := tokens[0], tokens[1:]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			switch op.Operation {
+			case "add", "replace":
+				n[key] = op.Value
+			case "remove":
+				if _, ok := n[key]; !ok {
+					return nil, errors.Errorf("key %s does not exist", key)
+				}
+				delete(n, key)
+			default:
+				return nil, errors.Errorf("unsupported operation %s", op.Operation)
+			}
+			return n, nil
+		}
+		child, ok := n[key]
+		if !ok {
+			return nil, errors.Errorf("key %s does not exist", key)
+		}
+		v, err := applyOperation(child, rest, op)
+		if err != nil {
+			return nil, err
+		}
+		n[key] = v
+		return n, nil
+
+	case []interface{}:
+		i := len(n)
+		if key != "-" {
+			var err error
+			if i, err = strconv.Atoi(key); err != nil || i < 0 || i > len(n) {
+				return nil, errors.Errorf("invalid index %s", key)
+			}
+		}
+		if len(rest) == 0 {
+			switch op.Operation {
+			case "add":
+				n = append(n, nil)
+				copy(n[i+1:], n[i:])
+				n[i] = op.Value
+			case "replace":
+				if i == len(n) {
+					return nil, errors.Errorf("index %s out of range", key)
+				}
+				n[i] = op.Value
+			case "remove":
+				if i == len(n) {
+					return nil, errors.Errorf("index %s out of range", key)
+				}
+				n = append(n[:i], n[i+1:]...)
+			default:
+				return nil, errors.Errorf("unsupported operation %s", op.Operation)
+			}
+			return n, nil
+		}
+		if i == len(n) {
+			return nil, errors.Errorf("index %s out of range", key)
+		}
+		v, err := applyOperation(n[i], rest, op)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = v
+		return n, nil
+	}
+
+	return nil, errors.Errorf("can't traverse %s of a %T", key, node)
+}
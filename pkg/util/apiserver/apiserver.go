@@ -73,6 +73,7 @@ type APIServer struct {
 	resourceList map[string]*metav1.APIResourceList
 	swagger      *spec.Swagger
 	delegates    map[string]CRDHandler
+	middlewares  []Middleware
 }
 
 // NewAPIServer returns a new API Server from the given Mux.
@@ -103,11 +104,18 @@ func NewAPIServer(mux *http.ServeMux) *APIServer {
 	return s
 }
 
+// Use adds middlewares that all http requests for every APIResource are routed through, in order,
+// before the middlewares that each APIResource was added with
+func (as *APIServer) Use(middlewares ...Middleware) {
+	as.middlewares = append(as.middlewares, middlewares...)
+}
+
 // AddAPIResource stores the APIResource under the given groupVersion string, and returns it
 // in the appropriate place for the K8s discovery service
 // e.g. http://localhost:8001/apis/scheduling.k8s.io/v1beta1
-// as well as registering a CRDHandler that all http requests for the given APIResource are routed to
-func (as *APIServer) AddAPIResource(groupVersion string, resource metav1.APIResource, handler CRDHandler) {
+// as well as registering a CRDHandler that all http requests for the given APIResource are routed to,
+// through the given middlewares
+func (as *APIServer) AddAPIResource(groupVersion string, resource metav1.APIResource, handler CRDHandler, middlewares ...Middleware) {
 	list, ok := as.resourceList[groupVersion]
 	if !ok {
 		// discovery handler
@@ -129,7 +137,7 @@ func (as *APIServer) AddAPIResource(groupVersion string, resource metav1.APIReso
 
 	// add specific crd resource handler
 	key := fmt.Sprintf("%s/%s", groupVersion, resource.Name)
-	as.delegates[key] = handler
+	as.delegates[key] = Chain(handler, middlewares...)
 
 	as.logger.WithField("groupversion", groupVersion).WithField("apiresource", resource).Info("Adding APIResource")
 }
@@ -149,7 +157,7 @@ func (as *APIServer) resourceHandler(gv string) https.ErrorHandlerFunc {
 			return nil
 		}
 
-		if err = delegate(w, r, namespace); err != nil {
+		if err = Chain(delegate, as.middlewares...)(w, r, namespace); err != nil {
 			return err
 		}
 
@@ -0,0 +1,286 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiserver
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/trace"
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// RemoteUserHeader is the header that the Kubernetes API server sets to the name of the
+	// authenticated user, when it proxies a request to an API extension
+	RemoteUserHeader = "X-Remote-User"
+	// RemoteGroupHeader is the header that the Kubernetes API server sets to each of the groups of the
+	// authenticated user, when it proxies a request to an API extension
+	RemoteGroupHeader = "X-Remote-Group"
+
+	// authenticationNamespace and authenticationConfigMap are where the Kubernetes API server publishes
+	// how API extensions can authenticate the requests that it proxies to them
+	authenticationNamespace = "kube-system"
+	authenticationConfigMap = "extension-apiserver-authentication"
+)
+
+// Middleware wraps a CRDHandler with a concern that the requests of many APIResources share,
+// such as identifying the user, logging, rate limiting or tracing, so that it isn't wired into each CRDHandler
+type Middleware func(next CRDHandler) CRDHandler
+
+// Chain returns the handler wrapped in the middlewares, so that the first of them sees each request first
+func Chain(handler CRDHandler, middlewares ...Middleware) CRDHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// AllowMethods returns a Middleware that responds to requests with any other method
+// with a 405 Method Not Allowed
+func AllowMethods(methods ...string) Middleware {
+	return func(next CRDHandler) CRDHandler {
+		return func(w http.ResponseWriter, r *http.Request, namespace string) error {
+			for _, m := range methods {
+				if r.Method == m {
+					return next(w, r, namespace)
+				}
+			}
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+			return nil
+		}
+	}
+}
+
+// User is the user that the Kubernetes API server authenticated a request as
+type User struct {
+	Name   string
+	Groups []string
+	// Verified is true if the request presented the front-proxy client certificate of the
+	// Kubernetes API server, otherwise anyone could have set the user headers
+	Verified bool
+}
+
+// userKey is the context key of the User of a request
+type userKey struct{}
+
+// RequestHeaderAuth verifies that a request was proxied by the Kubernetes API server, from the
+// front-proxy client certificate that it presents, so that the user headers it sets can be trusted
+type RequestHeaderAuth struct {
+	// ClientCAs are the CAs that sign the front-proxy client certificate
+	ClientCAs *x509.CertPool
+	// AllowedNames are the common names that the front-proxy client certificate can have.
+	// Any name is allowed if it is empty
+	AllowedNames []string
+}
+
+// LoadRequestHeaderAuth returns the RequestHeaderAuth that the Kubernetes API server publishes for
+// API extensions, in the extension-apiserver-authentication ConfigMap in kube-system
+func LoadRequestHeaderAuth(getter typedcorev1.ConfigMapsGetter) (RequestHeaderAuth, error) {
+	cm, err := getter.ConfigMaps(authenticationNamespace).Get(authenticationConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return RequestHeaderAuth{}, errors.Wrapf(err, "error retrieving configmap %s/%s", authenticationNamespace, authenticationConfigMap)
+	}
+	auth := RequestHeaderAuth{ClientCAs: x509.NewCertPool()}
+	if !auth.ClientCAs.AppendCertsFromPEM([]byte(cm.Data["requestheader-client-ca-file"])) {
+		return RequestHeaderAuth{}, errors.Errorf("configmap %s/%s has no requestheader-client-ca-file certificates", authenticationNamespace, authenticationConfigMap)
+	}
+	if names := cm.Data["requestheader-allowed-names"]; names != "" {
+		if err := json.Unmarshal([]byte(names), &auth.AllowedNames); err != nil {
+			return RequestHeaderAuth{}, errors.Wrapf(err, "error parsing requestheader-allowed-names of configmap %s/%s", authenticationNamespace, authenticationConfigMap)
+		}
+	}
+	return auth, nil
+}
+
+// verify returns true if the request presented a client certificate that is signed by the ClientCAs,
+// and has one of the AllowedNames
+func (a RequestHeaderAuth) verify(r *http.Request) bool {
+	if a.ClientCAs == nil || r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return false
+	}
+	cert := r.TLS.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, c := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	opts := x509.VerifyOptions{Roots: a.ClientCAs, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}
+	if _, err := cert.Verify(opts); err != nil {
+		return false
+	}
+	if len(a.AllowedNames) == 0 {
+		return true
+	}
+	for _, name := range a.AllowedNames {
+		if cert.Subject.CommonName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoteUser returns a Middleware that adds the User that the Kubernetes API server authenticated
+// the request as to the context of the request, where UserFrom returns it.
+// The User is only Verified if auth verifies that the Kubernetes API server proxied the request
+func RemoteUser(auth RequestHeaderAuth) Middleware {
+	return func(next CRDHandler) CRDHandler {
+		return func(w http.ResponseWriter, r *http.Request, namespace string) error {
+			name := r.Header.Get(RemoteUserHeader)
+			if name == "" {
+				return next(w, r, namespace)
+			}
+			user := User{Name: name, Groups: r.Header[RemoteGroupHeader], Verified: auth.verify(r)}
+			return next(w, r.WithContext(WithUser(r.Context(), user)), namespace)
+		}
+	}
+}
+
+// WithUser returns a copy of ctx that carries user, which UserFrom returns
+func WithUser(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom returns the User that the RemoteUser Middleware added to the context of a request,
+// if the request had one
+func UserFrom(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(userKey{}).(User)
+	return user, ok
+}
+
+// RequestInfo describes a request that a CRDHandler has handled
+type RequestInfo struct {
+	Request   *http.Request
+	Namespace string
+	// Code is the status code of the response, which is 500 if the CRDHandler returned an error
+	Code    int
+	Latency time.Duration
+	Err     error
+}
+
+// Observe returns a Middleware that calls the hook with each request once it has been handled,
+// e.g. to log it, or to record metrics of it
+func Observe(hook func(RequestInfo)) Middleware {
+	return func(next CRDHandler) CRDHandler {
+		return func(w http.ResponseWriter, r *http.Request, namespace string) error {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+			err := next(rec, r, namespace)
+			code := rec.code
+			if err != nil {
+				code = http.StatusInternalServerError
+			}
+			hook(RequestInfo{Request: r, Namespace: namespace, Code: code, Latency: time.Since(start), Err: err})
+			return err
+		}
+	}
+}
+
+// LogRequests returns a Middleware that logs each request at debug level,
+// along with the status code and latency of its response
+func LogRequests(logger *logrus.Entry) Middleware {
+	return Observe(func(info RequestInfo) {
+		if !logger.Logger.IsLevelEnabled(logrus.DebugLevel) {
+			return
+		}
+		log := logger.WithField("method", info.Request.Method).
+			WithField("path", info.Request.URL.Path).
+			WithField("namespace", info.Namespace).
+			WithField("code", info.Code).
+			WithField("latency", info.Latency.String())
+		if user, ok := UserFrom(info.Request.Context()); ok {
+			log = log.WithField("user", user.Name).WithField("verified", user.Verified)
+		}
+		if info.Err != nil {
+			log = log.WithError(info.Err)
+		}
+		log.Debug("api request")
+	})
+}
+
+// RateLimit returns a Middleware that responds to requests over the rate of the limiter with a
+// 429 Too Many Requests, rather than queueing them, so that clients back off and retry
+func RateLimit(limiter *rate.Limiter) Middleware {
+	return func(next CRDHandler) CRDHandler {
+		return func(w http.ResponseWriter, r *http.Request, namespace string) error {
+			if !limiter.Allow() {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return nil
+			}
+			return next(w, r, namespace)
+		}
+	}
+}
+
+// Trace returns a Middleware that records a span with the name for each request, as a child of the
+// span that the B3 headers of the request carry, if any. The span is added to the context of the request,
+// so that the CRDHandler can record spans within it, and is exported by the trace exporters registered
+// with OpenCensus.
+func Trace(name string) Middleware {
+	format := &b3.HTTPFormat{}
+	return func(next CRDHandler) CRDHandler {
+		return func(w http.ResponseWriter, r *http.Request, namespace string) error {
+			ctx := r.Context()
+			var span *trace.Span
+			if parent, ok := format.SpanContextFromRequest(r); ok {
+				ctx, span = trace.StartSpanWithRemoteParent(ctx, name, parent, trace.WithSpanKind(trace.SpanKindServer))
+			} else {
+				ctx, span = trace.StartSpan(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+			}
+			defer span.End()
+			span.AddAttributes(
+				trace.StringAttribute(ochttp.MethodAttribute, r.Method),
+				trace.StringAttribute(ochttp.PathAttribute, r.URL.Path),
+				trace.StringAttribute("namespace", namespace))
+
+			rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+			err := next(rec, r.WithContext(ctx), namespace)
+			code := rec.code
+			if err != nil {
+				code = http.StatusInternalServerError
+			}
+			span.AddAttributes(trace.Int64Attribute(ochttp.StatusCodeAttribute, int64(code)))
+			status := ochttp.TraceStatus(code, http.StatusText(code))
+			if err != nil {
+				status.Message = err.Error()
+			}
+			span.SetStatus(status)
+			return err
+		}
+	}
+}
+
+// statusRecorder records the status code that is written to a ResponseWriter
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+// WriteHeader records the status code, and writes it to the underlying ResponseWriter
+func (s *statusRecorder) WriteHeader(code int) {
+	s.code = code
+	s.ResponseWriter.WriteHeader(code)
+}
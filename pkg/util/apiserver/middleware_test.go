@@ -0,0 +1,354 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiserver
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	middleware := func(name string) Middleware {
+		return func(next CRDHandler) CRDHandler {
+			return func(w http.ResponseWriter, r *http.Request, namespace string) error {
+				calls = append(calls, name)
+				return next(w, r, namespace)
+			}
+		}
+	}
+	handler := Chain(func(_ http.ResponseWriter, _ *http.Request, _ string) error {
+		calls = append(calls, "handler")
+		return nil
+	}, middleware("first"), middleware("second"))
+
+	require.NoError(t, handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), "default"))
+	assert.Equal(t, []string{"first", "second", "handler"}, calls)
+}
+
+func TestAPIServerMiddlewares(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	api := NewAPIServer(mux)
+	var user User
+	var found bool
+	api.Use(RemoteUser(RequestHeaderAuth{}))
+	api.AddAPIResource(gv.String(), resource, func(_ http.ResponseWriter, r *http.Request, _ string) error {
+		user, found = UserFrom(r.Context())
+		return nil
+	}, AllowMethods(http.MethodPost))
+
+	path := "/apis/allocation.agones.dev/v1/namespaces/default/gameserverallocations"
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, http.MethodPost, rec.Header().Get("Allow"))
+	assert.False(t, found)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, found, "there is no user without the header")
+
+	r := httptest.NewRequest(http.MethodPost, path, nil)
+	r.Header.Set(RemoteUserHeader, "matchmaker")
+	r.Header.Add(RemoteGroupHeader, "system:serviceaccounts")
+	r.Header.Add(RemoteGroupHeader, "system:authenticated")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, found)
+	assert.Equal(t, User{Name: "matchmaker", Groups: []string{"system:serviceaccounts", "system:authenticated"}}, user)
+}
+
+func TestRemoteUser(t *testing.T) {
+	t.Parallel()
+
+	ca, client := newClientCert(t, "front-proxy-client")
+	_, otherClient := newClientCert(t, "front-proxy-client")
+	auth := RequestHeaderAuth{ClientCAs: x509.NewCertPool()}
+	auth.ClientCAs.AddCert(ca)
+
+	var user User
+	var found bool
+	handler := func(auth RequestHeaderAuth) CRDHandler {
+		return RemoteUser(auth)(func(_ http.ResponseWriter, r *http.Request, _ string) error {
+			user, found = UserFrom(r.Context())
+			return nil
+		})
+	}
+	request := func(certs ...*x509.Certificate) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/apis/allocation.agones.dev/v1/namespaces/default/gameserverallocations", nil)
+		r.TLS = &tls.ConnectionState{PeerCertificates: certs}
+		return r
+	}
+
+	fixtures := map[string]struct {
+		auth     RequestHeaderAuth
+		r        *http.Request
+		verified bool
+	}{
+		"front-proxy client certificate": {auth: auth, r: request(client), verified: true},
+		"allowed name": {
+			auth:     RequestHeaderAuth{ClientCAs: auth.ClientCAs, AllowedNames: []string{"aggregator", "front-proxy-client"}},
+			r:        request(client),
+			verified: true,
+		},
+		"name not allowed": {
+			auth: RequestHeaderAuth{ClientCAs: auth.ClientCAs, AllowedNames: []string{"aggregator"}},
+			r:    request(client),
+		},
+		"no client certificate": {auth: auth, r: request()},
+		"no tls":                {auth: auth, r: httptest.NewRequest(http.MethodPost, "/", nil)},
+		"other ca":              {auth: auth, r: request(otherClient)},
+		"no client ca":          {auth: RequestHeaderAuth{}, r: request(client)},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			found = false
+			v.r.Header.Set(RemoteUserHeader, "matchmaker")
+			require.NoError(t, handler(v.auth)(httptest.NewRecorder(), v.r, "default"))
+			assert.True(t, found)
+			assert.Equal(t, "matchmaker", user.Name)
+			assert.Equal(t, v.verified, user.Verified)
+		})
+	}
+}
+
+func TestLoadRequestHeaderAuth(t *testing.T) {
+	t.Parallel()
+
+	ca, client := newClientCert(t, "front-proxy-client")
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: authenticationConfigMap, Namespace: authenticationNamespace},
+		Data: map[string]string{
+			"requestheader-client-ca-file": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})),
+			"requestheader-allowed-names":  `["front-proxy-client"]`,
+		},
+	}
+
+	auth, err := LoadRequestHeaderAuth(fake.NewSimpleClientset(cm).CoreV1())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"front-proxy-client"}, auth.AllowedNames)
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client}}
+	assert.True(t, auth.verify(r))
+
+	_, err = LoadRequestHeaderAuth(fake.NewSimpleClientset().CoreV1())
+	assert.Error(t, err)
+
+	cm.Data["requestheader-allowed-names"] = "front-proxy-client"
+	_, err = LoadRequestHeaderAuth(fake.NewSimpleClientset(cm).CoreV1())
+	assert.Error(t, err)
+
+	cm.Data = map[string]string{}
+	_, err = LoadRequestHeaderAuth(fake.NewSimpleClientset(cm).CoreV1())
+	assert.Error(t, err)
+}
+
+func TestObserve(t *testing.T) {
+	t.Parallel()
+
+	var infos []RequestInfo
+	observe := Observe(func(info RequestInfo) {
+		infos = append(infos, info)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	err := observe(func(w http.ResponseWriter, _ *http.Request, _ string) error {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return nil
+	})(httptest.NewRecorder(), r, "default")
+	require.NoError(t, err)
+
+	err = observe(func(_ http.ResponseWriter, _ *http.Request, _ string) error {
+		return errors.New("failed")
+	})(httptest.NewRecorder(), r, "default")
+	assert.EqualError(t, err, "failed")
+
+	err = observe(func(_ http.ResponseWriter, _ *http.Request, _ string) error {
+		return nil
+	})(httptest.NewRecorder(), r, "default")
+	require.NoError(t, err)
+
+	if assert.Len(t, infos, 3) {
+		assert.Equal(t, http.StatusUnprocessableEntity, infos[0].Code)
+		assert.Equal(t, "default", infos[0].Namespace)
+		assert.Equal(t, r, infos[0].Request)
+		assert.Equal(t, http.StatusInternalServerError, infos[1].Code)
+		assert.EqualError(t, infos[1].Err, "failed")
+		assert.Equal(t, http.StatusOK, infos[2].Code)
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	t.Parallel()
+
+	buf := bytes.NewBuffer(nil)
+	logger := logrus.New()
+	logger.Out = buf
+	logger.Level = logrus.InfoLevel
+	handler := Chain(func(_ http.ResponseWriter, _ *http.Request, _ string) error {
+		return nil
+	}, RemoteUser(RequestHeaderAuth{}), LogRequests(logrus.NewEntry(logger)))
+
+	r := httptest.NewRequest(http.MethodPost, "/apis/allocation.agones.dev/v1/namespaces/default/gameserverallocations", nil)
+	r.Header.Set(RemoteUserHeader, "matchmaker")
+	require.NoError(t, handler(httptest.NewRecorder(), r, "default"))
+	assert.Equal(t, 0, buf.Len(), "requests are only logged at debug level")
+
+	logger.Level = logrus.DebugLevel
+	require.NoError(t, handler(httptest.NewRecorder(), r, "default"))
+	assert.Contains(t, buf.String(), "api request")
+	assert.Contains(t, buf.String(), "user=matchmaker")
+	assert.Contains(t, buf.String(), "verified=false")
+	assert.Contains(t, buf.String(), "code=200")
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	handler := Chain(func(_ http.ResponseWriter, _ *http.Request, _ string) error {
+		calls++
+		return nil
+	}, RateLimit(rate.NewLimiter(rate.Every(time.Hour), 2)))
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		require.NoError(t, handler(w, r, "default"))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	require.NoError(t, handler(w, r, "default"))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, 2, calls)
+}
+
+// spanRecorder is a trace exporter that records the spans with a name
+type spanRecorder struct {
+	name  string
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+// ExportSpan records the span, if it has the name of the spanRecorder
+func (s *spanRecorder) ExportSpan(sd *trace.SpanData) {
+	if sd.Name != s.name {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spans = append(s.spans, sd)
+}
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+
+	exporter := &spanRecorder{name: "TestTrace"}
+	trace.RegisterExporter(exporter)
+	defer trace.UnregisterExporter(exporter)
+
+	var span *trace.Span
+	handler := Chain(func(w http.ResponseWriter, r *http.Request, _ string) error {
+		span = trace.FromContext(r.Context())
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}, Trace("TestTrace"))
+
+	r := httptest.NewRequest(http.MethodPost, "/apis/allocation.agones.dev/v1/namespaces/default/gameserverallocations", nil)
+	r.Header.Set("X-B3-TraceId", "463ac35c9f6413ad48485a3953bb6124")
+	r.Header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+	r.Header.Set("X-B3-Sampled", "1")
+	require.NoError(t, handler(httptest.NewRecorder(), r, "default"))
+	require.NotNil(t, span, "the span is in the context of the request")
+
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+	if assert.Len(t, exporter.spans, 1) {
+		sd := exporter.spans[0]
+		assert.Equal(t, "463ac35c9f6413ad48485a3953bb6124", sd.TraceID.String())
+		assert.Equal(t, "a2fb4a1d1a96d312", sd.ParentSpanID.String())
+		assert.Equal(t, trace.SpanKindServer, sd.SpanKind)
+		assert.Equal(t, "default", sd.Attributes["namespace"])
+		assert.Equal(t, int64(http.StatusNotFound), sd.Attributes[ochttp.StatusCodeAttribute])
+		assert.Equal(t, int32(trace.StatusCodeNotFound), sd.Status.Code)
+	}
+}
+
+// newClientCert returns a new CA, and a client certificate with the common name that it signed
+func newClientCert(t *testing.T, commonName string) (*x509.Certificate, *x509.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "front-proxy-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err = x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	client, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return ca, client
+}
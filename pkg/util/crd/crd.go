@@ -17,34 +17,189 @@
 package crd
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	apiv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	extv1beta1 "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
-// WaitForEstablishedCRD blocks until CRD comes to an Established state.
-// Has a deadline of 60 seconds for this to occur.
-func WaitForEstablishedCRD(crdGetter extv1beta1.CustomResourceDefinitionInterface, name string, logger *logrus.Entry) error {
-	return wait.PollImmediate(time.Second, 60*time.Second, func() (done bool, err error) {
+// DefaultEstablishedTimeout is how long the controller waits for
+// the CRDs to become established, unless configured otherwise
+const DefaultEstablishedTimeout = 60 * time.Second
+
+// SchemaVersionAnnotation is the annotation on the Agones CRDs with the version of their schema
+const SchemaVersionAnnotation = "agones.dev/schema-version"
+
+// SchemaVersion is the version of the CRD schemas that this version of Agones is built for.
+// It must be incremented, along with the SchemaVersionAnnotation of the CRDs in the install
+// manifests, whenever a CRD schema changes in a way that a controller built for the previous
+// version could not handle, such as new fields that it would drop.
+// Version 2 added the v1 resource versions, and the validation of the fields added since version 1.
+const SchemaVersion = 2
+
+// MinCompatibleSchemaVersion is the oldest schema version of the CRDs that a controller built for the
+// SchemaVersion can run with in compatibility mode, where the fields of the newer schema aren't validated
+// by the API server, or the resource versions of the newer schema aren't served. It must be raised whenever
+// a CRD schema changes in a way that this controller can't run without.
+// Schema version 0 CRDs predate the validation of many of the fields that the controller relies on.
+const MinCompatibleSchemaVersion = 1
+
+// SchemaVersionSkewError is returned when the schema version of one or more CRDs
+// is not the SchemaVersion. CRDs without a SchemaVersionAnnotation have version 0.
+type SchemaVersionSkewError struct {
+	// Version is the schema version that was expected
+	Version int
+	// MinCompatible is the oldest schema version that is compatible with Version
+	MinCompatible int
+	// Older are the names of the CRDs with an older schema version
+	Older []string
+	// Incompatible are the names of the Older CRDs with a schema version older than MinCompatible
+	Incompatible []string
+	// Newer are the names of the CRDs with a newer schema version
+	Newer []string
+}
+
+func (e *SchemaVersionSkewError) Error() string {
+	var parts []string
+	if len(e.Older) > 0 {
+		parts = append(parts, fmt.Sprintf("older than %d: %s", e.Version, strings.Join(e.Older, ", ")))
+	}
+	if len(e.Incompatible) > 0 {
+		parts = append(parts, fmt.Sprintf("incompatible, as older than %d: %s", e.MinCompatible, strings.Join(e.Incompatible, ", ")))
+	}
+	if len(e.Newer) > 0 {
+		parts = append(parts, fmt.Sprintf("newer than %d: %s", e.Version, strings.Join(e.Newer, ", ")))
+	}
+	return "custom resource definition schema versions are " + strings.Join(parts, "; ")
+}
+
+// Compatible returns true if all the CRDs have a schema version between MinCompatible and Version, so a
+// controller built for Version can run with them. A CRD with a newer schema version is never compatible,
+// as the controller would drop the fields that it doesn't know about when it updates resources.
+func (e *SchemaVersionSkewError) Compatible() bool {
+	return len(e.Incompatible) == 0 && len(e.Newer) == 0
+}
+
+// CheckSchemaVersions checks that the named CRDs have the given schema version in their SchemaVersionAnnotation.
+// CRDs that do not exist are skipped. If any of them has a different version, a *SchemaVersionSkewError is returned,
+// with those older than minCompatible as Incompatible.
+func CheckSchemaVersions(crdGetter extv1beta1.CustomResourceDefinitionInterface, names []string, version, minCompatible int) error {
+	skew := &SchemaVersionSkewError{Version: version, MinCompatible: minCompatible}
+	for _, name := range names {
 		crd, err := crdGetter.Get(name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
 		if err != nil {
-			return false, err
+			return errors.Wrapf(err, "error retrieving custom resource definition %s", name)
 		}
 
-		for _, cond := range crd.Status.Conditions {
-			switch cond.Type {
-			case apiv1beta1.Established:
-				if cond.Status == apiv1beta1.ConditionTrue {
-					logger.WithField("crd", crd.ObjectMeta.Name).Info("custom resource definition established")
-					return true, err
-				}
+		v := 0
+		if value, ok := crd.ObjectMeta.Annotations[SchemaVersionAnnotation]; ok {
+			if v, err = strconv.Atoi(value); err != nil {
+				return errors.Wrapf(err, "invalid %s annotation on custom resource definition %s", SchemaVersionAnnotation, name)
+			}
+		}
+		switch {
+		case v < version:
+			skew.Older = append(skew.Older, name)
+			if v < minCompatible {
+				skew.Incompatible = append(skew.Incompatible, name)
 			}
+		case v > version:
+			skew.Newer = append(skew.Newer, name)
 		}
+	}
 
-		return false, nil
+	if len(skew.Older) == 0 && len(skew.Newer) == 0 {
+		return nil
+	}
+	sort.Strings(skew.Older)
+	sort.Strings(skew.Incompatible)
+	sort.Strings(skew.Newer)
+	return skew
+}
+
+// NotEstablishedError is returned when one or more CRDs did not come
+// to an Established state before the timeout
+type NotEstablishedError struct {
+	// Names of the CRDs that are not established
+	Names   []string
+	Timeout time.Duration
+}
+
+func (e *NotEstablishedError) Error() string {
+	return fmt.Sprintf("custom resource definitions not established after %s: %s", e.Timeout, strings.Join(e.Names, ", "))
+}
+
+// WaitForEstablishedCRD blocks until CRD comes to an Established state.
+// Has a deadline of timeout for this to occur.
+func WaitForEstablishedCRD(crdGetter extv1beta1.CustomResourceDefinitionInterface, name string, timeout time.Duration, logger *logrus.Entry) error {
+	return WaitForEstablishedCRDs(crdGetter, []string{name}, timeout, logger)
+}
+
+// WaitForEstablishedCRDs blocks until all the named CRDs come to an Established state.
+// A CRD that does not exist yet is waited on, in case it is still being installed.
+// If the timeout is reached, a *NotEstablishedError is returned, listing the CRDs that are not established.
+func WaitForEstablishedCRDs(crdGetter extv1beta1.CustomResourceDefinitionInterface, names []string, timeout time.Duration, logger *logrus.Entry) error {
+	established := map[string]bool{}
+
+	err := wait.PollImmediate(time.Second, timeout, func() (done bool, err error) {
+		for _, name := range names {
+			if established[name] {
+				continue
+			}
+
+			ok, err := isEstablished(crdGetter, name)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				logger.WithField("crd", name).Info("custom resource definition established")
+				established[name] = true
+			}
+		}
+
+		return len(established) == len(names), nil
 	})
+
+	if err == wait.ErrWaitTimeout {
+		nee := &NotEstablishedError{Timeout: timeout}
+		for _, name := range names {
+			if !established[name] {
+				nee.Names = append(nee.Names, name)
+			}
+		}
+		return nee
+	}
+
+	return err
+}
+
+// isEstablished returns true if the CRD exists, and is in an Established state
+func isEstablished(crdGetter extv1beta1.CustomResourceDefinitionInterface, name string) (bool, error) {
+	crd, err := crdGetter.Get(name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, cond := range crd.Status.Conditions {
+		if cond.Type == apiv1beta1.Established && cond.Status == apiv1beta1.ConditionTrue {
+			return true, nil
+		}
+	}
+
+	return false, nil
 }
@@ -19,10 +19,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	extfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	k8stesting "k8s.io/client-go/testing"
 )
@@ -44,7 +47,7 @@ func TestWaitForEstablishedCRD(t *testing.T) {
 			return true, crd, nil
 		})
 
-		err := WaitForEstablishedCRD(extClient.ApiextensionsV1beta1().CustomResourceDefinitions(), "test", logrus.WithField("test", "already-established"))
+		err := WaitForEstablishedCRD(extClient.ApiextensionsV1beta1().CustomResourceDefinitions(), "test", DefaultEstablishedTimeout, logrus.WithField("test", "already-established"))
 		assert.Nil(t, err)
 	})
 
@@ -69,7 +72,136 @@ func TestWaitForEstablishedCRD(t *testing.T) {
 			established = true
 		}()
 
-		err := WaitForEstablishedCRD(extClient.ApiextensionsV1beta1().CustomResourceDefinitions(), "test", logrus.WithField("test", "already-established"))
+		err := WaitForEstablishedCRD(extClient.ApiextensionsV1beta1().CustomResourceDefinitions(), "test", DefaultEstablishedTimeout, logrus.WithField("test", "already-established"))
 		assert.Nil(t, err)
 	})
 }
+
+func TestWaitForEstablishedCRDs(t *testing.T) {
+	t.Parallel()
+
+	established := &v1beta1.CustomResourceDefinition{
+		Status: v1beta1.CustomResourceDefinitionStatus{
+			Conditions: []v1beta1.CustomResourceDefinitionCondition{{
+				Type:   v1beta1.Established,
+				Status: v1beta1.ConditionTrue,
+			}},
+		},
+	}
+
+	t.Run("all CRDs established", func(t *testing.T) {
+		extClient := &extfake.Clientset{}
+		extClient.AddReactor("get", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, established, nil
+		})
+
+		err := WaitForEstablishedCRDs(extClient.ApiextensionsV1beta1().CustomResourceDefinitions(), []string{"a", "b"},
+			time.Second, logrus.WithField("test", "all-established"))
+		assert.Nil(t, err)
+	})
+
+	t.Run("missing CRDs time out", func(t *testing.T) {
+		extClient := &extfake.Clientset{}
+		extClient.AddReactor("get", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			name := action.(k8stesting.GetAction).GetName()
+			switch name {
+			case "a":
+				return true, established, nil
+			case "b":
+				return true, &v1beta1.CustomResourceDefinition{}, nil
+			}
+			return true, nil, k8serrors.NewNotFound(v1beta1.Resource("customresourcedefinitions"), name)
+		})
+
+		err := WaitForEstablishedCRDs(extClient.ApiextensionsV1beta1().CustomResourceDefinitions(), []string{"a", "b", "c"},
+			2*time.Second, logrus.WithField("test", "missing"))
+		if assert.IsType(t, &NotEstablishedError{}, err) {
+			nee := err.(*NotEstablishedError)
+			assert.Equal(t, []string{"b", "c"}, nee.Names)
+			assert.Equal(t, 2*time.Second, nee.Timeout)
+			assert.Contains(t, err.Error(), "b, c")
+		}
+	})
+
+	t.Run("get error", func(t *testing.T) {
+		extClient := &extfake.Clientset{}
+		extClient.AddReactor("get", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("bad things")
+		})
+
+		err := WaitForEstablishedCRDs(extClient.ApiextensionsV1beta1().CustomResourceDefinitions(), []string{"a"},
+			time.Second, logrus.WithField("test", "error"))
+		assert.EqualError(t, err, "bad things")
+	})
+}
+
+func TestCheckSchemaVersions(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(crds ...*v1beta1.CustomResourceDefinition) *extfake.Clientset {
+		objects := make([]runtime.Object, len(crds))
+		for i, crd := range crds {
+			objects[i] = crd
+		}
+		return extfake.NewSimpleClientset(objects...)
+	}
+	newCRD := func(name string, annotations map[string]string) *v1beta1.CustomResourceDefinition {
+		return &v1beta1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+	}
+	names := []string{"a", "b", "c"}
+
+	t.Run("same version", func(t *testing.T) {
+		client := newClient(
+			newCRD("a", map[string]string{SchemaVersionAnnotation: "2"}),
+			newCRD("b", map[string]string{SchemaVersionAnnotation: "2"}))
+		err := CheckSchemaVersions(client.ApiextensionsV1beta1().CustomResourceDefinitions(), names, 2, 1)
+		assert.Nil(t, err)
+	})
+
+	t.Run("older and newer versions", func(t *testing.T) {
+		client := newClient(
+			newCRD("a", map[string]string{SchemaVersionAnnotation: "3"}),
+			newCRD("b", map[string]string{SchemaVersionAnnotation: "1"}),
+			newCRD("c", nil))
+		err := CheckSchemaVersions(client.ApiextensionsV1beta1().CustomResourceDefinitions(), names, 2, 1)
+		skew, ok := err.(*SchemaVersionSkewError)
+		if assert.True(t, ok, "%v", err) {
+			assert.Equal(t, 2, skew.Version)
+			assert.Equal(t, []string{"b", "c"}, skew.Older)
+			assert.Equal(t, []string{"c"}, skew.Incompatible)
+			assert.Equal(t, []string{"a"}, skew.Newer)
+			assert.False(t, skew.Compatible())
+			assert.Equal(t, "custom resource definition schema versions are older than 2: b, c; incompatible, as older than 1: c; newer than 2: a", skew.Error())
+		}
+	})
+
+	t.Run("older compatible versions", func(t *testing.T) {
+		client := newClient(
+			newCRD("a", map[string]string{SchemaVersionAnnotation: "2"}),
+			newCRD("b", map[string]string{SchemaVersionAnnotation: "1"}))
+		err := CheckSchemaVersions(client.ApiextensionsV1beta1().CustomResourceDefinitions(), names, 2, 1)
+		skew, ok := err.(*SchemaVersionSkewError)
+		if assert.True(t, ok, "%v", err) {
+			assert.Equal(t, []string{"b"}, skew.Older)
+			assert.Empty(t, skew.Incompatible)
+			assert.True(t, skew.Compatible())
+		}
+	})
+
+	t.Run("invalid version", func(t *testing.T) {
+		client := newClient(newCRD("a", map[string]string{SchemaVersionAnnotation: "nope"}))
+		err := CheckSchemaVersions(client.ApiextensionsV1beta1().CustomResourceDefinitions(), names, 2, 1)
+		assert.Error(t, err)
+		_, ok := err.(*SchemaVersionSkewError)
+		assert.False(t, ok)
+	})
+
+	t.Run("error retrieving a CRD", func(t *testing.T) {
+		client := &extfake.Clientset{}
+		client.AddReactor("get", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("boom")
+		})
+		err := CheckSchemaVersions(client.ApiextensionsV1beta1().CustomResourceDefinitions(), names, 2, 1)
+		assert.EqualError(t, err, "error retrieving custom resource definition a: boom")
+	})
+}
@@ -0,0 +1,340 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crd
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var (
+	// opaqueTypes are the Kubernetes types that are validated by Kubernetes itself, such as when
+	// the Pod of a GameServer is created, so their fields are not described by a generated schema.
+	opaqueTypes = map[reflect.Type]bool{
+		reflect.TypeOf(metav1.ObjectMeta{}):      true,
+		reflect.TypeOf(metav1.ListMeta{}):        true,
+		reflect.TypeOf(corev1.PodTemplateSpec{}): true,
+	}
+
+	// scalarTypes are the types that are serialised to json as scalars, rather than as objects
+	scalarTypes = map[reflect.Type]apiv1beta1.JSONSchemaProps{
+		reflect.TypeOf(metav1.Time{}):     {Type: "string", Format: "date-time"},
+		reflect.TypeOf(metav1.Duration{}): {Type: "string"},
+		// a Quantity, or an IntOrString, can be either an integer or a string
+		reflect.TypeOf(resource.Quantity{}):  {},
+		reflect.TypeOf(intstr.IntOrString{}): {},
+	}
+
+	// schemas caches the schemas generated by UnknownFieldCauses, by type
+	schemas sync.Map
+)
+
+// Schema generates the OpenAPI v3 schema of the json serialisation of the Go type t, which
+// describes the type of every field, and the fields of every object, apart from those of the
+// opaqueTypes. Fields that can be either integers or strings, such as an IntOrString, have no type.
+func Schema(t reflect.Type) apiv1beta1.JSONSchemaProps {
+	return schema(t, map[reflect.Type]bool{})
+}
+
+// schema generates the schema of t, where seen are the struct types that t is nested within,
+// to stop recursive types from recursing forever
+func schema(t reflect.Type, seen map[reflect.Type]bool) apiv1beta1.JSONSchemaProps {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if s, ok := scalarTypes[t]; ok {
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return apiv1beta1.JSONSchemaProps{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return apiv1beta1.JSONSchemaProps{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return apiv1beta1.JSONSchemaProps{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return apiv1beta1.JSONSchemaProps{Type: "number"}
+	case reflect.String:
+		return apiv1beta1.JSONSchemaProps{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return apiv1beta1.JSONSchemaProps{Type: "string", Format: "byte"}
+		}
+		items := schema(t.Elem(), seen)
+		return apiv1beta1.JSONSchemaProps{Type: "array", Items: &apiv1beta1.JSONSchemaPropsOrArray{Schema: &items}}
+	case reflect.Map:
+		values := schema(t.Elem(), seen)
+		return apiv1beta1.JSONSchemaProps{Type: "object", AdditionalProperties: &apiv1beta1.JSONSchemaPropsOrBool{Allows: true, Schema: &values}}
+	case reflect.Struct:
+		if opaqueTypes[t] || seen[t] {
+			return apiv1beta1.JSONSchemaProps{Type: "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		s := apiv1beta1.JSONSchemaProps{Type: "object", Properties: map[string]apiv1beta1.JSONSchemaProps{}}
+		addFields(&s, t, seen)
+		return s
+	}
+
+	// interfaces can hold any value
+	return apiv1beta1.JSONSchemaProps{}
+}
+
+// addFields adds the schemas of the json fields of struct type t to the properties of s,
+// including those of any embedded structs that are inlined
+func addFields(s *apiv1beta1.JSONSchemaProps, t reflect.Type, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" && f.Anonymous {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(s, ft, seen)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = schema(f.Type, seen)
+	}
+}
+
+// MergeSchema adds the constraints of a hand written schema, such as titles, enums, minimums and required
+// fields, to a generated schema. Properties of the constraints can only be added to an object that has no
+// properties in the generated schema, such as one of the opaqueTypes, otherwise an error is returned for
+// each property that is not in the generated schema, to catch hand written schemas that have drifted from
+// the Go types that they describe.
+func MergeSchema(generated *apiv1beta1.JSONSchemaProps, constraints apiv1beta1.JSONSchemaProps) error {
+	return mergeSchema(generated, constraints, "")
+}
+
+func mergeSchema(dst *apiv1beta1.JSONSchemaProps, src apiv1beta1.JSONSchemaProps, path string) error {
+	if src.Type != "" {
+		if dst.Type != "" && dst.Type != src.Type {
+			return errors.Errorf("%s has type %s, but its field has type %s", fieldPath(path), src.Type, dst.Type)
+		}
+		dst.Type = src.Type
+	}
+	if src.Format != "" {
+		dst.Format = src.Format
+	}
+	if src.Title != "" {
+		dst.Title = src.Title
+	}
+	if src.Description != "" {
+		dst.Description = src.Description
+	}
+	if src.Maximum != nil {
+		dst.Maximum, dst.ExclusiveMaximum = src.Maximum, src.ExclusiveMaximum
+	}
+	if src.Minimum != nil {
+		dst.Minimum, dst.ExclusiveMinimum = src.Minimum, src.ExclusiveMinimum
+	}
+	if src.MaxLength != nil {
+		dst.MaxLength = src.MaxLength
+	}
+	if src.MinLength != nil {
+		dst.MinLength = src.MinLength
+	}
+	if src.Pattern != "" {
+		dst.Pattern = src.Pattern
+	}
+	if src.MaxItems != nil {
+		dst.MaxItems = src.MaxItems
+	}
+	if src.MinItems != nil {
+		dst.MinItems = src.MinItems
+	}
+	if src.MultipleOf != nil {
+		dst.MultipleOf = src.MultipleOf
+	}
+	if src.Enum != nil {
+		dst.Enum = src.Enum
+	}
+	if src.MaxProperties != nil {
+		dst.MaxProperties = src.MaxProperties
+	}
+	if src.MinProperties != nil {
+		dst.MinProperties = src.MinProperties
+	}
+	if src.Required != nil {
+		dst.Required = src.Required
+	}
+	if src.AllOf != nil {
+		dst.AllOf = src.AllOf
+	}
+	if src.OneOf != nil {
+		dst.OneOf = src.OneOf
+	}
+	if src.AnyOf != nil {
+		dst.AnyOf = src.AnyOf
+	}
+	if src.Not != nil {
+		dst.Not = src.Not
+	}
+
+	if src.Items != nil && src.Items.Schema != nil {
+		if dst.Items == nil || dst.Items.Schema == nil {
+			return errors.Errorf("%s has items, but is not an array", fieldPath(path))
+		}
+		if err := mergeSchema(dst.Items.Schema, *src.Items.Schema, path+"[]"); err != nil {
+			return err
+		}
+	}
+	if src.AdditionalProperties != nil && src.AdditionalProperties.Schema != nil {
+		if dst.AdditionalProperties == nil || dst.AdditionalProperties.Schema == nil {
+			return errors.Errorf("%s has additional properties, but is not a map", fieldPath(path))
+		}
+		if err := mergeSchema(dst.AdditionalProperties.Schema, *src.AdditionalProperties.Schema, path+"[]"); err != nil {
+			return err
+		}
+	}
+
+	if len(src.Properties) == 0 {
+		return nil
+	}
+	if dst.Properties == nil {
+		if dst.AdditionalProperties != nil {
+			return errors.Errorf("%s has properties, but is a map", fieldPath(path))
+		}
+		if dst.Type != "" && dst.Type != "object" {
+			return errors.Errorf("%s has properties, but its field has type %s", fieldPath(path), dst.Type)
+		}
+		// one of the opaqueTypes, so keep the hand written properties as they are
+		dst.Properties = src.Properties
+		return nil
+	}
+	for _, name := range sortedKeys(src.Properties) {
+		p, ok := dst.Properties[name]
+		if !ok {
+			return errors.Errorf("%s is not a field", fieldPath(path+"."+name))
+		}
+		if err := mergeSchema(&p, src.Properties[name], path+"."+name); err != nil {
+			return err
+		}
+		dst.Properties[name] = p
+	}
+	return nil
+}
+
+// UnknownFields returns the paths, such as spec.template.spec.portz, of the fields of the json document
+// raw that are not properties of their object in the schema s, in alphabetical order.
+// Objects without properties in the schema, such as those of the opaqueTypes, can have any fields.
+func UnknownFields(s apiv1beta1.JSONSchemaProps, raw []byte) ([]string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling json document")
+	}
+	var unknown []string
+	unknownFields(s, doc, "", &unknown)
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+// UnknownFieldCauses returns a StatusCause for each field of the json document raw, such as
+// the object of an admission review, that is not a field of the Go type t that it is unmarshalled
+// into, e.g. because it is misspelt, as otherwise it would be silently ignored.
+func UnknownFieldCauses(t reflect.Type, raw []byte) ([]metav1.StatusCause, error) {
+	s, ok := schemas.Load(t)
+	if !ok {
+		s, _ = schemas.LoadOrStore(t, Schema(t))
+	}
+	unknown, err := UnknownFields(s.(apiv1beta1.JSONSchemaProps), raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var causes []metav1.StatusCause
+	for _, f := range unknown {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   f,
+			Message: "unknown field",
+		})
+	}
+	return causes, nil
+}
+
+func unknownFields(s apiv1beta1.JSONSchemaProps, doc interface{}, path string, unknown *[]string) {
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+			for k, v := range d {
+				unknownFields(*s.AdditionalProperties.Schema, v, path+"."+k, unknown)
+			}
+			return
+		}
+		if s.Properties == nil {
+			return
+		}
+		for k, v := range d {
+			p, ok := s.Properties[k]
+			if !ok {
+				*unknown = append(*unknown, fieldPath(path+"."+k))
+				continue
+			}
+			unknownFields(p, v, path+"."+k, unknown)
+		}
+	case []interface{}:
+		if s.Items == nil || s.Items.Schema == nil {
+			return
+		}
+		for _, v := range d {
+			unknownFields(*s.Items.Schema, v, path+"[]", unknown)
+		}
+	}
+}
+
+// fieldPath returns path without its leading separator
+func fieldPath(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return strings.TrimPrefix(path, ".")
+}
+
+func sortedKeys(m map[string]apiv1beta1.JSONSchemaProps) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
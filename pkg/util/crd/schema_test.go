@@ -0,0 +1,160 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+type testInline struct {
+	Inlined string `json:"inlined"`
+}
+
+type testSpec struct {
+	Name     string                 `json:"name"`
+	Replicas int32                  `json:"replicas,omitempty"`
+	Enabled  *bool                  `json:"enabled,omitempty"`
+	Ports    []testPort             `json:"ports,omitempty"`
+	Labels   map[string]string      `json:"labels,omitempty"`
+	Size     intstr.IntOrString     `json:"size"`
+	Since    metav1.Time            `json:"since"`
+	Template corev1.PodTemplateSpec `json:"template"`
+	Data     []byte                 `json:"data"`
+	Ignored  string                 `json:"-"`
+	ignored  string
+}
+
+type testPort struct {
+	Port int `json:"port"`
+}
+
+type testObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	testInline        `json:",inline"`
+
+	Spec testSpec `json:"spec"`
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	s := Schema(reflect.TypeOf(testObject{}))
+	assert.Equal(t, "object", s.Type)
+	assert.ElementsMatch(t, []string{"apiVersion", "kind", "metadata", "inlined", "spec"}, keys(s.Properties))
+	assert.Equal(t, v1beta1.JSONSchemaProps{Type: "object"}, s.Properties["metadata"])
+
+	spec := s.Properties["spec"]
+	assert.ElementsMatch(t, []string{"name", "replicas", "enabled", "ports", "labels", "size", "since", "template", "data"}, keys(spec.Properties))
+	assert.Equal(t, "string", spec.Properties["name"].Type)
+	assert.Equal(t, v1beta1.JSONSchemaProps{Type: "integer", Format: "int32"}, spec.Properties["replicas"])
+	assert.Equal(t, "boolean", spec.Properties["enabled"].Type)
+	assert.Equal(t, "array", spec.Properties["ports"].Type)
+	assert.Equal(t, v1beta1.JSONSchemaProps{Type: "integer", Format: "int64"}, spec.Properties["ports"].Items.Schema.Properties["port"])
+	assert.Equal(t, "object", spec.Properties["labels"].Type)
+	assert.Equal(t, "string", spec.Properties["labels"].AdditionalProperties.Schema.Type)
+	assert.Equal(t, v1beta1.JSONSchemaProps{}, spec.Properties["size"])
+	assert.Equal(t, v1beta1.JSONSchemaProps{Type: "string", Format: "date-time"}, spec.Properties["since"])
+	assert.Equal(t, v1beta1.JSONSchemaProps{Type: "object"}, spec.Properties["template"])
+	assert.Equal(t, v1beta1.JSONSchemaProps{Type: "string", Format: "byte"}, spec.Properties["data"])
+}
+
+func TestMergeSchema(t *testing.T) {
+	t.Parallel()
+
+	min := float64(1)
+	constraints := v1beta1.JSONSchemaProps{
+		Properties: map[string]v1beta1.JSONSchemaProps{
+			"spec": {
+				Required: []string{"name"},
+				Properties: map[string]v1beta1.JSONSchemaProps{
+					"replicas": {Title: "The replicas", Minimum: &min},
+					"ports": {Items: &v1beta1.JSONSchemaPropsOrArray{Schema: &v1beta1.JSONSchemaProps{
+						Properties: map[string]v1beta1.JSONSchemaProps{"port": {Minimum: &min}},
+					}}},
+					"template": {Properties: map[string]v1beta1.JSONSchemaProps{"spec": {Type: "object"}}},
+				},
+			},
+		},
+	}
+
+	s := Schema(reflect.TypeOf(testObject{}))
+	assert.Nil(t, MergeSchema(&s, constraints))
+	spec := s.Properties["spec"]
+	assert.Equal(t, []string{"name"}, spec.Required)
+	assert.Equal(t, v1beta1.JSONSchemaProps{Type: "integer", Format: "int32", Title: "The replicas", Minimum: &min}, spec.Properties["replicas"])
+	assert.Equal(t, &min, spec.Properties["ports"].Items.Schema.Properties["port"].Minimum)
+	assert.Equal(t, "object", spec.Properties["template"].Properties["spec"].Type)
+	assert.Equal(t, "string", spec.Properties["name"].Type)
+
+	s = Schema(reflect.TypeOf(testObject{}))
+	err := MergeSchema(&s, v1beta1.JSONSchemaProps{Properties: map[string]v1beta1.JSONSchemaProps{
+		"spec": {Properties: map[string]v1beta1.JSONSchemaProps{"replica": {Type: "integer"}}},
+	}})
+	assert.EqualError(t, err, "spec.replica is not a field")
+
+	s = Schema(reflect.TypeOf(testObject{}))
+	err = MergeSchema(&s, v1beta1.JSONSchemaProps{Properties: map[string]v1beta1.JSONSchemaProps{
+		"spec": {Properties: map[string]v1beta1.JSONSchemaProps{"replicas": {Type: "string"}}},
+	}})
+	assert.EqualError(t, err, "spec.replicas has type string, but its field has type integer")
+
+	s = Schema(reflect.TypeOf(testObject{}))
+	err = MergeSchema(&s, v1beta1.JSONSchemaProps{Properties: map[string]v1beta1.JSONSchemaProps{
+		"spec": {Properties: map[string]v1beta1.JSONSchemaProps{"name": {Properties: map[string]v1beta1.JSONSchemaProps{"first": {}}}}},
+	}})
+	assert.EqualError(t, err, "spec.name has properties, but its field has type string")
+}
+
+func TestUnknownFields(t *testing.T) {
+	t.Parallel()
+
+	s := Schema(reflect.TypeOf(testObject{}))
+	raw := []byte(`{"apiVersion": "v1", "kind": "Test", "metadata": {"name": "test", "anything": true}, "inlined": "yes",
+		"spec": {"name": "test", "replica": 1, "ports": [{"port": 7777}, {"prot": 7778}], "labels": {"a": "b"},
+		"template": {"spec": {"containers": []}}, "size": "10%"}, "sepc": {}}`)
+
+	unknown, err := UnknownFields(s, raw)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sepc", "spec.ports[].prot", "spec.replica"}, unknown)
+
+	_, err = UnknownFields(s, []byte("{"))
+	assert.NotNil(t, err)
+
+	causes, err := UnknownFieldCauses(reflect.TypeOf(testObject{}), raw)
+	assert.Nil(t, err)
+	if assert.Len(t, causes, 3) {
+		assert.Equal(t, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid, Field: "sepc", Message: "unknown field"}, causes[0])
+	}
+
+	causes, err = UnknownFieldCauses(reflect.TypeOf(testObject{}), []byte(`{"spec": {"name": "test"}}`))
+	assert.Nil(t, err)
+	assert.Empty(t, causes)
+}
+
+func keys(m map[string]v1beta1.JSONSchemaProps) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
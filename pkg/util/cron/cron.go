@@ -0,0 +1,169 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cron parses standard five field cron expressions
+// (minute, hour, day of month, month and day of week),
+// and matches times against them
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	// anyDay is true if either the day of month or day of week field is "*", in which case a time only has to
+	// match both of them. Otherwise it only has to match one of them, as is standard for cron.
+	anyDay bool
+}
+
+// field is the range of values of a cron field
+type field struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteField     = field{name: "minute", min: 0, max: 59}
+	hourField       = field{name: "hour", min: 0, max: 23}
+	dayOfMonthField = field{name: "day of month", min: 1, max: 31}
+	monthField      = field{name: "month", min: 1, max: 12}
+	// 0 and 7 are both Sunday
+	dayOfWeekField = field{name: "day of week", min: 0, max: 7}
+)
+
+// Parse parses a five field cron expression, e.g. "0 2 * * 1-5" for 2am every weekday.
+// Each field is "*", a value, a range such as "1-5", any of these with a step such as "*/15",
+// or a comma separated list of them.
+func Parse(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("cron expression %q must have 5 fields, not %d", expr, len(fields))
+	}
+
+	s := &Schedule{}
+	var err error
+	if s.minutes, err = minuteField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if s.hours, err = hourField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if s.daysOfMonth, err = dayOfMonthField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if s.months, err = monthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if s.daysOfWeek, err = dayOfWeekField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	// fold Sunday as 7 into Sunday as 0, to match time.Weekday
+	if s.daysOfWeek&(1<<7) != 0 {
+		s.daysOfWeek |= 1
+	}
+	s.anyDay = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*")
+
+	return s, nil
+}
+
+// parse parses a single cron field into a bit set of its values
+func (f field) parse(value string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(value, ",") {
+		min, max, step := f.min, f.max, 1
+
+		rng := part
+		if i := strings.Index(part, "/"); i >= 0 {
+			rng = part[:i]
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return 0, errors.Errorf("invalid step in %s field %q", f.name, value)
+			}
+			step = s
+		}
+
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if min, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, errors.Errorf("invalid value in %s field %q", f.name, value)
+			}
+			max = min
+			if len(bounds) == 2 {
+				if max, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, errors.Errorf("invalid value in %s field %q", f.name, value)
+				}
+			} else if step > 1 {
+				// a single value with a step, e.g. "5/15", runs through to the end of the range
+				max = f.max
+			}
+			if min < f.min || max > f.max || min > max {
+				return 0, errors.Errorf("%s field %q must be within %d-%d", f.name, value, f.min, f.max)
+			}
+		}
+
+		for i := min; i <= max; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+// Matches returns if the minute of the given time matches the schedule
+func (s *Schedule) Matches(t time.Time) bool {
+	if s.minutes&(1<<uint(t.Minute())) == 0 || s.hours&(1<<uint(t.Hour())) == 0 || s.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dom := s.daysOfMonth&(1<<uint(t.Day())) != 0
+	dow := s.daysOfWeek&(1<<uint(t.Weekday())) != 0
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first minute after the given time that matches the schedule,
+// searching up to the given limit. Returns false if there is none within it.
+func (s *Schedule) Next(after time.Time, limit time.Duration) (time.Time, bool) {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	for end := after.Add(limit); !t.After(end); t = t.Add(time.Minute) {
+		if s.Matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Prev returns the last minute at or before the given time that matches the schedule,
+// searching back up to the given limit. Returns false if there is none within it.
+func (s *Schedule) Prev(before time.Time, limit time.Duration) (time.Time, bool) {
+	t := before.Truncate(time.Minute)
+	for end := before.Add(-limit); !t.Before(end); t = t.Add(-time.Minute) {
+		if s.Matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{"* * * * *", "0 2 * * 1-5", "*/15 0-6,22-23 1,15 */2 0", "5/10 * * * 7", " 30  4 * * * "} {
+		_, err := Parse(expr)
+		assert.Nil(t, err, expr)
+	}
+
+	for expr, msg := range map[string]string{
+		"* * * *":       `cron expression "* * * *" must have 5 fields, not 4`,
+		"60 * * * *":    `minute field "60" must be within 0-59`,
+		"* 5-1 * * *":   `hour field "5-1" must be within 0-23`,
+		"* * 0 * *":     `day of month field "0" must be within 1-31`,
+		"* * * x *":     `invalid value in month field "x"`,
+		"* * * * */0":   `invalid step in day of week field "*/0"`,
+		"* * * * 1-x":   `invalid value in day of week field "1-x"`,
+		"* * * * 1,,2":  `invalid value in day of week field "1,,2"`,
+		"* * * 1-13 *":  `month field "1-13" must be within 1-12`,
+		"* * * * 8":     `day of week field "8" must be within 0-7`,
+		"*/x * * * *":   `invalid step in minute field "*/x"`,
+		"1-2-3 * * * *": `invalid value in minute field "1-2-3"`,
+	} {
+		_, err := Parse(expr)
+		assert.EqualError(t, err, msg, expr)
+	}
+}
+
+func TestScheduleMatches(t *testing.T) {
+	t.Parallel()
+
+	// a Wednesday
+	wed := time.Date(2019, time.July, 17, 2, 30, 0, 0, time.UTC)
+
+	fixtures := map[string]struct {
+		expr     string
+		time     time.Time
+		expected bool
+	}{
+		"every minute":                   {expr: "* * * * *", time: wed, expected: true},
+		"minute and hour":                {expr: "30 2 * * *", time: wed, expected: true},
+		"wrong minute":                   {expr: "31 2 * * *", time: wed, expected: false},
+		"wrong hour":                     {expr: "30 3 * * *", time: wed, expected: false},
+		"weekdays":                       {expr: "30 2 * * 1-5", time: wed, expected: true},
+		"weekends":                       {expr: "30 2 * * 0,6", time: wed, expected: false},
+		"sunday as 7":                    {expr: "* * * * 7", time: wed.AddDate(0, 0, 4), expected: true},
+		"step":                           {expr: "*/15 * * * *", time: wed, expected: true},
+		"step with start":                {expr: "5/15 * * * *", time: wed.Add(5 * time.Minute), expected: true},
+		"step miss":                      {expr: "*/20 * * * *", time: wed, expected: false},
+		"month":                          {expr: "* * * 7 *", time: wed, expected: true},
+		"wrong month":                    {expr: "* * * 8 *", time: wed, expected: false},
+		"day of month or week, month":    {expr: "30 2 17 * 1", time: wed, expected: true},
+		"day of month or week, week":     {expr: "30 2 1 * 3", time: wed, expected: true},
+		"day of month or week, neither":  {expr: "30 2 1 * 1", time: wed, expected: false},
+		"day of month and any day, miss": {expr: "30 2 1 * *", time: wed, expected: false},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			s, err := Parse(v.expr)
+			assert.Nil(t, err)
+			assert.Equal(t, v.expected, s.Matches(v.time))
+		})
+	}
+}
+
+func TestScheduleNextPrev(t *testing.T) {
+	t.Parallel()
+
+	s, err := Parse("0 2 * * *")
+	assert.Nil(t, err)
+
+	now := time.Date(2019, time.July, 17, 2, 30, 15, 0, time.UTC)
+
+	next, ok := s.Next(now, 48*time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2019, time.July, 18, 2, 0, 0, 0, time.UTC), next)
+
+	_, ok = s.Next(now, time.Hour)
+	assert.False(t, ok)
+
+	prev, ok := s.Prev(now, time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2019, time.July, 17, 2, 0, 0, 0, time.UTC), prev)
+
+	prev, ok = s.Prev(time.Date(2019, time.July, 17, 2, 0, 0, 0, time.UTC), 0)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2019, time.July, 17, 2, 0, 0, 0, time.UTC), prev)
+
+	_, ok = s.Prev(now, 20*time.Minute)
+	assert.False(t, ok)
+}
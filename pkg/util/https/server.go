@@ -15,6 +15,7 @@
 package https
 
 import (
+	cryptotls "crypto/tls"
 	"net/http"
 
 	"agones.dev/agones/pkg/util/runtime"
@@ -45,6 +46,9 @@ func NewServer(certFile, keyFile string) *Server {
 	tls := &http.Server{
 		Addr:    ":8081",
 		Handler: mux,
+		// the Kubernetes API server presents its front-proxy client certificate when it proxies
+		// requests to API extensions, which verify it before they trust the user headers it sets
+		TLSConfig: &cryptotls.Config{ClientAuth: cryptotls.RequestClientCert},
 	}
 
 	wh := &Server{
@@ -0,0 +1,321 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package namespaces provides informers that only watch a restricted set of namespaces,
+// rather than the whole cluster
+package namespaces
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+var _ cache.SharedIndexInformer = &informer{}
+var _ cache.Indexer = &indexer{}
+
+// informer is a SharedIndexInformer that runs an informer per namespace,
+// and serves their objects through a single indexer
+type informer struct {
+	namespaces []string
+	informers  map[string]cache.SharedIndexInformer
+	indexer    *indexer
+}
+
+// NewInformer returns a SharedIndexInformer of the objects in any of the namespaces,
+// which runs an informer per namespace, created by newInformer.
+// It can be registered with the InformerFor func of a SharedInformerFactory, so that
+// the informers and listers that the factory returns for its type only see these namespaces.
+func NewInformer(namespaces []string, newInformer func(namespace string) cache.SharedIndexInformer) cache.SharedIndexInformer {
+	i := &informer{
+		namespaces: namespaces,
+		informers:  make(map[string]cache.SharedIndexInformer, len(namespaces)),
+		indexer:    &indexer{namespaces: namespaces, indexers: make(map[string]cache.Indexer, len(namespaces))},
+	}
+	for _, ns := range namespaces {
+		i.informers[ns] = newInformer(ns)
+		i.indexer.indexers[ns] = i.informers[ns].GetIndexer()
+	}
+	return i
+}
+
+// AddEventHandler adds the handler to the informer of each namespace
+func (i *informer) AddEventHandler(handler cache.ResourceEventHandler) {
+	for _, ns := range i.namespaces {
+		i.informers[ns].AddEventHandler(handler)
+	}
+}
+
+// AddEventHandlerWithResyncPeriod adds the handler to the informer of each namespace
+func (i *informer) AddEventHandlerWithResyncPeriod(handler cache.ResourceEventHandler, resyncPeriod time.Duration) {
+	for _, ns := range i.namespaces {
+		i.informers[ns].AddEventHandlerWithResyncPeriod(handler, resyncPeriod)
+	}
+}
+
+// GetStore returns the indexer of the objects of all the namespaces
+func (i *informer) GetStore() cache.Store {
+	return i.indexer
+}
+
+// GetController returns the informer itself, as it runs the informers of all the namespaces
+func (i *informer) GetController() cache.Controller {
+	return i
+}
+
+// Run runs the informer of each namespace, until stopCh is closed
+func (i *informer) Run(stopCh <-chan struct{}) {
+	for _, ns := range i.namespaces {
+		go i.informers[ns].Run(stopCh)
+	}
+	<-stopCh
+}
+
+// HasSynced returns true once the informers of all the namespaces have synced
+func (i *informer) HasSynced() bool {
+	for _, ns := range i.namespaces {
+		if !i.informers[ns].HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// LastSyncResourceVersion is always empty, as the resource versions
+// that the informers of different namespaces have synced to can't be combined
+func (i *informer) LastSyncResourceVersion() string {
+	return ""
+}
+
+// AddIndexers adds the indexers to the informer of each namespace
+func (i *informer) AddIndexers(indexers cache.Indexers) error {
+	for _, ns := range i.namespaces {
+		if err := i.informers[ns].AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetIndexer returns the indexer of the objects of all the namespaces
+func (i *informer) GetIndexer() cache.Indexer {
+	return i.indexer
+}
+
+// indexer is an Indexer over the indexers of each namespace.
+// Objects are stored in the indexer of their namespace, and lookups
+// by index are made against all of them.
+type indexer struct {
+	namespaces []string
+	indexers   map[string]cache.Indexer
+}
+
+// forObject returns the indexer of the namespace of obj
+func (i *indexer) forObject(obj interface{}) (cache.Indexer, error) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		ns, _, err := cache.SplitMetaNamespaceKey(d.Key)
+		if err != nil {
+			return nil, err
+		}
+		return i.forNamespace(ns)
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	return i.forNamespace(m.GetNamespace())
+}
+
+// forNamespace returns the indexer of namespace ns
+func (i *indexer) forNamespace(ns string) (cache.Indexer, error) {
+	if idx, ok := i.indexers[ns]; ok {
+		return idx, nil
+	}
+	return nil, errors.Errorf("namespace %s is not watched", ns)
+}
+
+// Add adds obj to the indexer of its namespace
+func (i *indexer) Add(obj interface{}) error {
+	idx, err := i.forObject(obj)
+	if err != nil {
+		return err
+	}
+	return idx.Add(obj)
+}
+
+// Update updates obj in the indexer of its namespace
+func (i *indexer) Update(obj interface{}) error {
+	idx, err := i.forObject(obj)
+	if err != nil {
+		return err
+	}
+	return idx.Update(obj)
+}
+
+// Delete deletes obj from the indexer of its namespace
+func (i *indexer) Delete(obj interface{}) error {
+	idx, err := i.forObject(obj)
+	if err != nil {
+		return err
+	}
+	return idx.Delete(obj)
+}
+
+// List lists the objects of all the namespaces
+func (i *indexer) List() []interface{} {
+	var result []interface{}
+	for _, ns := range i.namespaces {
+		result = append(result, i.indexers[ns].List()...)
+	}
+	return result
+}
+
+// ListKeys lists the keys of the objects of all the namespaces
+func (i *indexer) ListKeys() []string {
+	var result []string
+	for _, ns := range i.namespaces {
+		result = append(result, i.indexers[ns].ListKeys()...)
+	}
+	return result
+}
+
+// Get returns obj from the indexer of its namespace.
+// Objects in namespaces that are not watched never exist.
+func (i *indexer) Get(obj interface{}) (interface{}, bool, error) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return i.GetByKey(key)
+}
+
+// GetByKey returns the object of key from the indexer of its namespace.
+// Objects in namespaces that are not watched never exist.
+func (i *indexer) GetByKey(key string) (interface{}, bool, error) {
+	ns, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	idx, ok := i.indexers[ns]
+	if !ok {
+		return nil, false, nil
+	}
+	return idx.GetByKey(key)
+}
+
+// Replace replaces the objects of each namespace with those of list in that namespace
+func (i *indexer) Replace(list []interface{}, resourceVersion string) error {
+	byNamespace := make(map[string][]interface{}, len(i.namespaces))
+	for _, obj := range list {
+		m, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		if _, err := i.forNamespace(m.GetNamespace()); err != nil {
+			return err
+		}
+		byNamespace[m.GetNamespace()] = append(byNamespace[m.GetNamespace()], obj)
+	}
+	for _, ns := range i.namespaces {
+		if err := i.indexers[ns].Replace(byNamespace[ns], resourceVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resync resyncs the indexer of each namespace
+func (i *indexer) Resync() error {
+	for _, ns := range i.namespaces {
+		if err := i.indexers[ns].Resync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Index returns the objects of all the namespaces that match obj on the named index
+func (i *indexer) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	var result []interface{}
+	for _, ns := range i.namespaces {
+		items, err := i.indexers[ns].Index(indexName, obj)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
+// IndexKeys returns the keys of the objects of all the namespaces
+// whose indexed value on the named index is indexKey
+func (i *indexer) IndexKeys(indexName, indexKey string) ([]string, error) {
+	var result []string
+	for _, ns := range i.namespaces {
+		keys, err := i.indexers[ns].IndexKeys(indexName, indexKey)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, keys...)
+	}
+	return result, nil
+}
+
+// ListIndexFuncValues returns the indexed values of the named index in all the namespaces
+func (i *indexer) ListIndexFuncValues(indexName string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, ns := range i.namespaces {
+		for _, v := range i.indexers[ns].ListIndexFuncValues(indexName) {
+			if !seen[v] {
+				seen[v] = true
+				result = append(result, v)
+			}
+		}
+	}
+	return result
+}
+
+// ByIndex returns the objects of all the namespaces whose indexed value on the named index is indexKey
+func (i *indexer) ByIndex(indexName, indexKey string) ([]interface{}, error) {
+	var result []interface{}
+	for _, ns := range i.namespaces {
+		items, err := i.indexers[ns].ByIndex(indexName, indexKey)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
+// GetIndexers returns the indexers, which are the same for every namespace
+func (i *indexer) GetIndexers() cache.Indexers {
+	if len(i.namespaces) == 0 {
+		return cache.Indexers{}
+	}
+	return i.indexers[i.namespaces[0]].GetIndexers()
+}
+
+// AddIndexers adds the indexers to the indexer of each namespace
+func (i *indexer) AddIndexers(newIndexers cache.Indexers) error {
+	for _, ns := range i.namespaces {
+		if err := i.indexers[ns].AddIndexers(newIndexers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
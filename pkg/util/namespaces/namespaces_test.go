@@ -0,0 +1,95 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespaces
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestInformer(t *testing.T) {
+	t.Parallel()
+
+	pod := func(ns, name string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	}
+	client := fake.NewSimpleClientset(pod("a", "pod1"), pod("a", "pod2"), pod("b", "pod3"), pod("c", "pod4"))
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	factory.InformerFor(&corev1.Pod{}, func(c kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return NewInformer([]string{"a", "b"}, func(ns string) cache.SharedIndexInformer {
+			return coreinformers.NewPodInformer(c, ns, resync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		})
+	})
+	pods := factory.Core().V1()
+	added := make(chan string, 10)
+	pods.Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			added <- obj.(*corev1.Pod).ObjectMeta.Name
+		},
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	factory.Start(stop)
+	assert.True(t, cache.WaitForCacheSync(stop, pods.Pods().Informer().HasSynced))
+
+	var names []string
+	for i := 0; i < 3; i++ {
+		select {
+		case name := <-added:
+			names = append(names, name)
+		case <-time.After(10 * time.Second):
+			assert.FailNow(t, "pod should be added")
+		}
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"pod1", "pod2", "pod3"}, names)
+
+	lister := pods.Pods().Lister()
+	list, err := lister.List(labels.Everything())
+	assert.NoError(t, err)
+	assert.Len(t, list, 3)
+
+	list, err = lister.Pods("a").List(labels.Everything())
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	list, err = lister.Pods("c").List(labels.Everything())
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	p, err := lister.Pods("b").Get("pod3")
+	assert.NoError(t, err)
+	assert.Equal(t, "pod3", p.ObjectMeta.Name)
+
+	_, err = lister.Pods("c").Get("pod4")
+	assert.True(t, k8serrors.IsNotFound(err))
+
+	err = pods.Pods().Informer().GetStore().Add(pod("c", "pod5"))
+	assert.EqualError(t, err, "namespace c is not watched")
+}
@@ -0,0 +1,135 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"agones.dev/agones/pkg/util/runtime"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ConversionReview describes a request from the Kubernetes API server to convert
+// custom resources between versions, and its response.
+// It matches the apiextensions.k8s.io/v1beta1 ConversionReview, which is newer than
+// the vendored apiextensions-apiserver.
+type ConversionReview struct {
+	metav1.TypeMeta `json:",inline"`
+	Request         *ConversionRequest  `json:"request,omitempty"`
+	Response        *ConversionResponse `json:"response,omitempty"`
+}
+
+// ConversionRequest is the objects to convert, and the version to convert them to
+type ConversionRequest struct {
+	UID               types.UID                 `json:"uid"`
+	DesiredAPIVersion string                    `json:"desiredAPIVersion"`
+	Objects           []k8sruntime.RawExtension `json:"objects"`
+}
+
+// ConversionResponse is the converted objects, in the same order as they were requested,
+// or the reason they could not be converted
+type ConversionResponse struct {
+	UID              types.UID                 `json:"uid"`
+	ConvertedObjects []k8sruntime.RawExtension `json:"convertedObjects"`
+	Result           metav1.Status             `json:"result"`
+}
+
+// ConversionHandler converts an object, serialised as JSON, to apiVersion
+type ConversionHandler func(raw []byte, apiVersion string) (k8sruntime.Object, error)
+
+// AddConversionHandler adds a handler that converts objects of a given group and kind,
+// for the ConversionReviews sent to path. The path can't also have admission handlers.
+func (wh *WebHook) AddConversionHandler(path string, gk schema.GroupKind, h ConversionHandler) {
+	if len(wh.converters[path]) == 0 {
+		wh.converters[path] = map[schema.GroupKind]ConversionHandler{}
+		wh.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			err := wh.handleConversion(path, w, r)
+			if err != nil {
+				runtime.HandleError(wh.logger.WithField("url", r.URL), err)
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		})
+	}
+	wh.logger.WithField("path", path).WithField("groupKind", gk).Info("Added conversion webhook handler")
+	wh.converters[path][gk] = h
+}
+
+// handleConversion handles http requests for conversion webhooks.
+// Conversion failures are returned in the ConversionResponse, rather than as errors.
+func (wh *WebHook) handleConversion(path string, w http.ResponseWriter, r *http.Request) error { // nolint: interfacer
+	wh.logger.WithField("path", path).Info("running conversion webhook")
+
+	var review ConversionReview
+	err := json.NewDecoder(r.Body).Decode(&review)
+	if err != nil {
+		return errors.Wrapf(err, "error decoding decoding json for path %v", path)
+	}
+	if review.Request == nil {
+		return errors.Errorf("conversion review for path %v has no request", path)
+	}
+
+	review.Response = &ConversionResponse{UID: review.Request.UID}
+	converted, err := wh.convert(path, review.Request)
+	if err != nil {
+		wh.logger.WithError(err).WithField("path", path).Warn("could not convert objects")
+		review.Response.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+	} else {
+		review.Response.ConvertedObjects = converted
+		review.Response.Result = metav1.Status{Status: metav1.StatusSuccess}
+	}
+	review.Request = nil
+
+	err = json.NewEncoder(w).Encode(review)
+	if err != nil {
+		return errors.Wrapf(err, "error decoding encoding json for path %v", path)
+	}
+
+	return nil
+}
+
+// convert converts each object in the request with the conversion handler for its group and kind
+func (wh *WebHook) convert(path string, request *ConversionRequest) ([]k8sruntime.RawExtension, error) {
+	result := make([]k8sruntime.RawExtension, 0, len(request.Objects))
+	for _, obj := range request.Objects {
+		var tm metav1.TypeMeta
+		if err := json.Unmarshal(obj.Raw, &tm); err != nil {
+			return nil, errors.Wrap(err, "error decoding object type")
+		}
+		gv, err := schema.ParseGroupVersion(tm.APIVersion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing apiVersion %s", tm.APIVersion)
+		}
+		h, ok := wh.converters[path][gv.WithKind(tm.Kind).GroupKind()]
+		if !ok {
+			return nil, errors.Errorf("no conversion handler for %s, Kind=%s", tm.APIVersion, tm.Kind)
+		}
+
+		converted, err := h(obj.Raw, request.DesiredAPIVersion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error converting %s to %s", tm.Kind, request.DesiredAPIVersion)
+		}
+		raw, err := json.Marshal(converted)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error encoding converted %s", tm.Kind)
+		}
+		result = append(result, k8sruntime.RawExtension{Raw: raw})
+	}
+	return result, nil
+}
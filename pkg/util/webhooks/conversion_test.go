@@ -0,0 +1,114 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWebHookAddConversionHandler(t *testing.T) {
+	t.Parallel()
+
+	type expected struct {
+		status  string
+		objects []string
+	}
+	fixtures := map[string]struct {
+		objects  []string
+		expected expected
+	}{
+		"converted": {
+			objects: []string{`{"apiVersion": "group/v1alpha1", "kind": "kind", "metadata": {"name": "one"}}`,
+				`{"apiVersion": "group/v1", "kind": "kind", "metadata": {"name": "two"}}`},
+			expected: expected{status: metav1.StatusSuccess, objects: []string{
+				`{"apiVersion":"group/v1","kind":"kind","metadata":{"name":"one"}}`,
+				`{"apiVersion":"group/v1","kind":"kind","metadata":{"name":"two"}}`}},
+		},
+		"no handler for kind": {
+			objects:  []string{`{"apiVersion": "group/v1alpha1", "kind": "nope"}`},
+			expected: expected{status: metav1.StatusFailure},
+		},
+		"handler fails": {
+			objects:  []string{`{"apiVersion": "group/v1alpha1", "kind": "kind", "metadata": {"name": "fail"}}`},
+			expected: expected{status: metav1.StatusFailure},
+		},
+	}
+
+	for k, v := range fixtures {
+		t.Run(k, func(t *testing.T) {
+			mux := http.NewServeMux()
+			ts := httptest.NewUnstartedServer(mux)
+			wh := NewWebHook(mux)
+
+			wh.AddConversionHandler("/convert", schema.GroupKind{Group: "group", Kind: "kind"}, func(raw []byte, apiVersion string) (k8sruntime.Object, error) {
+				obj := &unstructured.Unstructured{}
+				if err := json.Unmarshal(raw, obj); err != nil {
+					return nil, err
+				}
+				if obj.GetName() == "fail" {
+					return nil, errors.New("failed")
+				}
+				obj.SetAPIVersion(apiVersion)
+				return obj, nil
+			})
+
+			ts.StartTLS()
+			defer ts.Close()
+
+			fixture := ConversionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1beta1", Kind: "ConversionReview"},
+				Request:  &ConversionRequest{UID: "1234", DesiredAPIVersion: "group/v1"},
+			}
+			for _, o := range v.objects {
+				fixture.Request.Objects = append(fixture.Request.Objects, k8sruntime.RawExtension{Raw: []byte(o)})
+			}
+
+			buf := &bytes.Buffer{}
+			err := json.NewEncoder(buf).Encode(fixture)
+			assert.Nil(t, err)
+
+			resp, err := ts.Client().Post(ts.URL+"/convert", "application/json", buf)
+			assert.Nil(t, err)
+			defer resp.Body.Close() // nolint: errcheck
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			review := ConversionReview{}
+			err = json.NewDecoder(resp.Body).Decode(&review)
+			assert.Nil(t, err)
+			assert.Equal(t, fixture.TypeMeta, review.TypeMeta)
+			assert.Nil(t, review.Request)
+			if assert.NotNil(t, review.Response) {
+				assert.Equal(t, fixture.Request.UID, review.Response.UID)
+				assert.Equal(t, v.expected.status, review.Response.Result.Status)
+				var objects []string
+				for _, o := range review.Response.ConvertedObjects {
+					objects = append(objects, string(o.Raw))
+				}
+				assert.Equal(t, v.expected.objects, objects)
+			}
+		})
+	}
+}
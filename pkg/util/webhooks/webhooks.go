@@ -23,6 +23,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -31,6 +33,12 @@ type WebHook struct {
 	logger   *logrus.Entry
 	mux      *http.ServeMux
 	handlers map[string][]operationHandler
+	// converters are the conversion handlers for each path, by group and kind
+	converters map[string]map[schema.GroupKind]ConversionHandler
+	// namespaces are the only namespaces whose objects the handlers run for, or all of them if empty
+	namespaces map[string]bool
+	// labelSelectors restrict the handlers of a group and kind to the objects whose labels match
+	labelSelectors map[schema.GroupKind]labels.Selector
 }
 
 // operationHandler stores the data for a handler to match against
@@ -47,8 +55,10 @@ type Handler func(review v1beta1.AdmissionReview) (v1beta1.AdmissionReview, erro
 // NewWebHook returns a Kubernetes webhook manager
 func NewWebHook(mux *http.ServeMux) *WebHook {
 	wh := &WebHook{
-		mux:      mux,
-		handlers: map[string][]operationHandler{},
+		mux:            mux,
+		handlers:       map[string][]operationHandler{},
+		converters:     map[string]map[schema.GroupKind]ConversionHandler{},
+		labelSelectors: map[schema.GroupKind]labels.Selector{},
 	}
 
 	wh.logger = runtime.NewLoggerWithType(wh)
@@ -70,6 +80,24 @@ func (wh *WebHook) AddHandler(path string, gk schema.GroupKind, op v1beta1.Opera
 	wh.handlers[path] = append(wh.handlers[path], operationHandler{groupKind: gk, operation: op, handler: h})
 }
 
+// SetNamespaces restricts the handlers to the objects in the given namespaces, so that the objects
+// in other namespaces, which the controllers don't watch, are allowed without being mutated or validated.
+// No namespaces runs the handlers for every namespace.
+func (wh *WebHook) SetNamespaces(namespaces []string) {
+	wh.namespaces = map[string]bool{}
+	for _, ns := range namespaces {
+		wh.namespaces[ns] = true
+	}
+	wh.logger.WithField("namespaces", namespaces).Info("Restricted webhook handlers to namespaces")
+}
+
+// SetLabelSelector restricts the handlers of the given group and kind to the objects whose labels match
+// the selector, so that the objects the controllers don't cache are allowed without being mutated or validated.
+func (wh *WebHook) SetLabelSelector(gk schema.GroupKind, selector labels.Selector) {
+	wh.labelSelectors[gk] = selector
+	wh.logger.WithField("groupKind", gk).WithField("selector", selector).Info("Restricted webhook handlers to label selector")
+}
+
 // handle Handles http requests for webhooks
 func (wh *WebHook) handle(path string, w http.ResponseWriter, r *http.Request) error { // nolint: interfacer
 	wh.logger.WithField("path", path).Info("running webhook")
@@ -80,10 +108,36 @@ func (wh *WebHook) handle(path string, w http.ResponseWriter, r *http.Request) e
 		return errors.Wrapf(err, "error decoding decoding json for path %v", path)
 	}
 
+	review, err = wh.Review(path, review)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(w).Encode(review)
+	if err != nil {
+		return errors.Wrapf(err, "error decoding encoding json for path %v", path)
+	}
+
+	return nil
+}
+
+// Review runs the handlers for the given path that match the operation, group and kind of
+// the review, as if it had been sent to that path, and returns the resulting AdmissionReview.
+// This lets other parts of the controller mutate and validate objects without creating them.
+func (wh *WebHook) Review(path string, review v1beta1.AdmissionReview) (v1beta1.AdmissionReview, error) {
 	// set it to true, in case there are no handlers
 	if review.Response == nil {
 		review.Response = &v1beta1.AdmissionResponse{Allowed: true}
 	}
+	if len(wh.namespaces) > 0 && !wh.namespaces[review.Request.Namespace] {
+		return review, nil
+	}
+	selected, err := wh.selected(review.Request)
+	if err != nil {
+		return review, errors.Wrapf(err, "error matching labels for path %v", path)
+	}
+	if !selected {
+		return review, nil
+	}
 	for _, oh := range wh.handlers[path] {
 		if oh.operation == review.Request.Operation &&
 			oh.groupKind.Kind == review.Request.Kind.Kind &&
@@ -91,14 +145,25 @@ func (wh *WebHook) handle(path string, w http.ResponseWriter, r *http.Request) e
 
 			review, err = oh.handler(review)
 			if err != nil {
-				return errors.Wrapf(err, "error with webhook handler for path %v", path)
+				return review, errors.Wrapf(err, "error with webhook handler for path %v", path)
 			}
 		}
 	}
-	err = json.NewEncoder(w).Encode(review)
-	if err != nil {
-		return errors.Wrapf(err, "error decoding encoding json for path %v", path)
-	}
+	return review, nil
+}
 
-	return nil
+// selected returns if the object of the request matches the label selector of its group and kind,
+// or true if there isn't one
+func (wh *WebHook) selected(request *v1beta1.AdmissionRequest) (bool, error) {
+	selector, ok := wh.labelSelectors[schema.GroupKind{Group: request.Kind.Group, Kind: request.Kind.Kind}]
+	if !ok || len(request.Object.Raw) == 0 {
+		return true, nil
+	}
+	obj := &struct {
+		metav1.ObjectMeta `json:"metadata,omitempty"`
+	}{}
+	if err := json.Unmarshal(request.Object.Raw, obj); err != nil {
+		return false, errors.Wrap(err, "error unmarshalling object metadata")
+	}
+	return selector.Matches(labels.Set(obj.ObjectMeta.Labels)), nil
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"k8s.io/api/admission/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -122,3 +123,52 @@ func TestWebHookAddHandler(t *testing.T) {
 	}
 
 }
+
+func TestWebHookReview(t *testing.T) {
+	t.Parallel()
+
+	wh := NewWebHook(http.NewServeMux())
+	wh.AddHandler("/validate", schema.GroupKind{Group: "group", Kind: "kind"}, v1beta1.Create, func(review v1beta1.AdmissionReview) (v1beta1.AdmissionReview, error) {
+		review.Response.Allowed = false
+		return review, nil
+	})
+
+	review := v1beta1.AdmissionReview{Request: &v1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Kind: "kind", Group: "group", Version: "version"},
+		Operation: v1beta1.Create}}
+
+	result, err := wh.Review("/validate", review)
+	assert.Nil(t, err)
+	assert.False(t, result.Response.Allowed)
+
+	// no handlers for the path, so it is allowed
+	result, err = wh.Review("/mutate", review)
+	assert.Nil(t, err)
+	assert.True(t, result.Response.Allowed)
+
+	// outside of the namespaces, the handlers are not run
+	wh.SetNamespaces([]string{"watched"})
+	review.Request.Namespace = "other"
+	result, err = wh.Review("/validate", review)
+	assert.Nil(t, err)
+	assert.True(t, result.Response.Allowed)
+
+	review.Request.Namespace = "watched"
+	result, err = wh.Review("/validate", review)
+	assert.Nil(t, err)
+	assert.False(t, result.Response.Allowed)
+
+	// objects that don't match the label selector of their kind are not run through the handlers
+	selector, err := labels.Parse("role=gameserver")
+	assert.Nil(t, err)
+	wh.SetLabelSelector(schema.GroupKind{Group: "group", Kind: "kind"}, selector)
+	review.Request.Object.Raw = []byte(`{"metadata":{"labels":{"role":"other"}}}`)
+	result, err = wh.Review("/validate", review)
+	assert.Nil(t, err)
+	assert.True(t, result.Response.Allowed)
+
+	review.Request.Object.Raw = []byte(`{"metadata":{"labels":{"role":"gameserver"}}}`)
+	result, err = wh.Review("/validate", review)
+	assert.Nil(t, err)
+	assert.False(t, result.Response.Allowed)
+}
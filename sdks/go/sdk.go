@@ -72,6 +72,15 @@ func (s *SDK) Allocate() error {
 	return errors.Wrap(err, "could not mark self as Allocated")
 }
 
+// Reserve marks the Game Server as Reserved for a given duration, at which point
+// it will return the GameServer to a Ready state.
+// A duration of 0 reserves the GameServer until its state is next changed.
+// Do note, the smallest unit available in the time.Duration argument is a second.
+func (s *SDK) Reserve(d time.Duration) error {
+	_, err := s.client.Reserve(s.ctx, &sdk.Duration{Seconds: int64(d.Seconds())})
+	return errors.Wrap(err, "could not send Reserve message")
+}
+
 // Shutdown marks the Game Server as ready to
 // shutdown
 func (s *SDK) Shutdown() error {
@@ -101,6 +110,58 @@ func (s *SDK) SetAnnotation(key, value string) error {
 	return errors.Wrap(err, "could not set annotation")
 }
 
+// SetCounter sets the count and capacity of a counter in the `GameServer` status,
+// which can be used to filter allocations
+func (s *SDK) SetCounter(name string, count, capacity int64) error {
+	c := &sdk.Counter{Name: name, Count: count, Capacity: capacity}
+	_, err := s.client.SetCounter(s.ctx, c)
+	return errors.Wrap(err, "could not set counter")
+}
+
+// SetList sets the values and capacity of a list in the `GameServer` status,
+// which can be used to filter allocations
+func (s *SDK) SetList(name string, capacity int64, values []string) error {
+	l := &sdk.List{Name: name, Capacity: capacity, Values: values}
+	_, err := s.client.SetList(s.ctx, l)
+	return errors.Wrap(err, "could not set list")
+}
+
+// PlayerConnect adds the player to the connected players in the `GameServer` status.
+// Returns false if the player was already connected, and an error if the
+// GameServer is already at player capacity
+func (s *SDK) PlayerConnect(id string) (bool, error) {
+	ok, err := s.client.PlayerConnect(s.ctx, &sdk.PlayerID{PlayerID: id})
+	if err != nil {
+		return false, errors.Wrap(err, "could not connect player")
+	}
+	return ok.Bool, nil
+}
+
+// PlayerDisconnect removes the player from the connected players in the `GameServer` status.
+// Returns false if the player was not connected
+func (s *SDK) PlayerDisconnect(id string) (bool, error) {
+	ok, err := s.client.PlayerDisconnect(s.ctx, &sdk.PlayerID{PlayerID: id})
+	if err != nil {
+		return false, errors.Wrap(err, "could not disconnect player")
+	}
+	return ok.Bool, nil
+}
+
+// SetPlayerCapacity sets the maximum number of players that can connect to the GameServer
+func (s *SDK) SetPlayerCapacity(capacity int64) error {
+	_, err := s.client.SetPlayerCapacity(s.ctx, &sdk.Count{Count: capacity})
+	return errors.Wrap(err, "could not set player capacity")
+}
+
+// GetPlayerCount returns the number of players currently connected to the GameServer
+func (s *SDK) GetPlayerCount() (int64, error) {
+	c, err := s.client.GetPlayerCount(s.ctx, &sdk.Empty{})
+	if err != nil {
+		return 0, errors.Wrap(err, "could not get player count")
+	}
+	return c.Count, nil
+}
+
 // GameServer retrieve the GameServer details
 func (s *SDK) GameServer() (*sdk.GameServer, error) {
 	gs, err := s.client.GetGameServer(s.ctx, &sdk.Empty{})
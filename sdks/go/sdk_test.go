@@ -53,6 +53,10 @@ func TestSDK(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, sm.allocated)
 
+	err = s.Reserve(12 * time.Second)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 12, sm.reserved.Seconds)
+
 	err = s.Shutdown()
 	assert.Nil(t, err)
 	assert.True(t, sm.ready)
@@ -123,19 +127,80 @@ func TestSDKSetAnnotation(t *testing.T) {
 	assert.Equal(t, expected, sm.annotations["foo"])
 }
 
+func TestSDKSetCounterAndList(t *testing.T) {
+	t.Parallel()
+	sm := &sdkMock{
+		counters: map[string]*sdk.Counter{},
+		lists:    map[string]*sdk.List{},
+	}
+	s := SDK{
+		ctx:    context.Background(),
+		client: sm,
+	}
+
+	err := s.SetCounter("rooms", 1, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), sm.counters["rooms"].Count)
+	assert.Equal(t, int64(10), sm.counters["rooms"].Capacity)
+
+	err = s.SetList("players", 5, []string{"a", "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), sm.lists["players"].Capacity)
+	assert.Equal(t, []string{"a", "b"}, sm.lists["players"].Values)
+}
+
+func TestSDKPlayerTracking(t *testing.T) {
+	t.Parallel()
+	sm := &sdkMock{}
+	s := SDK{
+		ctx:    context.Background(),
+		client: sm,
+	}
+
+	err := s.SetPlayerCapacity(1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), sm.playerCapacity)
+
+	ok, err := s.PlayerConnect("1")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = s.PlayerConnect("1")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	count, err := s.GetPlayerCount()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+
+	ok, err = s.PlayerDisconnect("1")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = s.PlayerDisconnect("1")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	count, err = s.GetPlayerCount()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
 var _ sdk.SDKClient = &sdkMock{}
 var _ sdk.SDK_HealthClient = &healthMock{}
 var _ sdk.SDK_WatchGameServerClient = &watchMock{}
 
 type sdkMock struct {
-	ready       bool
-	shutdown    bool
-	allocated   bool
-	reserved    *sdk.Duration
-	hm          *healthMock
-	wm          *watchMock
-	labels      map[string]string
-	annotations map[string]string
+	ready          bool
+	shutdown       bool
+	allocated      bool
+	reserved       *sdk.Duration
+	hm             *healthMock
+	wm             *watchMock
+	labels         map[string]string
+	annotations    map[string]string
+	counters       map[string]*sdk.Counter
+	lists          map[string]*sdk.List
+	players        []string
+	playerCapacity int64
 }
 
 func (m *sdkMock) SetLabel(ctx context.Context, in *sdk.KeyValue, opts ...grpc.CallOption) (*sdk.Empty, error) {
@@ -148,6 +213,45 @@ func (m *sdkMock) SetAnnotation(ctx context.Context, in *sdk.KeyValue, opts ...g
 	return &sdk.Empty{}, nil
 }
 
+func (m *sdkMock) SetCounter(ctx context.Context, in *sdk.Counter, opts ...grpc.CallOption) (*sdk.Empty, error) {
+	m.counters[in.Name] = in
+	return &sdk.Empty{}, nil
+}
+
+func (m *sdkMock) SetList(ctx context.Context, in *sdk.List, opts ...grpc.CallOption) (*sdk.Empty, error) {
+	m.lists[in.Name] = in
+	return &sdk.Empty{}, nil
+}
+
+func (m *sdkMock) PlayerConnect(ctx context.Context, in *sdk.PlayerID, opts ...grpc.CallOption) (*sdk.Bool, error) {
+	for _, p := range m.players {
+		if p == in.PlayerID {
+			return &sdk.Bool{Bool: false}, nil
+		}
+	}
+	m.players = append(m.players, in.PlayerID)
+	return &sdk.Bool{Bool: true}, nil
+}
+
+func (m *sdkMock) PlayerDisconnect(ctx context.Context, in *sdk.PlayerID, opts ...grpc.CallOption) (*sdk.Bool, error) {
+	for i, p := range m.players {
+		if p == in.PlayerID {
+			m.players = append(m.players[:i], m.players[i+1:]...)
+			return &sdk.Bool{Bool: true}, nil
+		}
+	}
+	return &sdk.Bool{Bool: false}, nil
+}
+
+func (m *sdkMock) SetPlayerCapacity(ctx context.Context, in *sdk.Count, opts ...grpc.CallOption) (*sdk.Empty, error) {
+	m.playerCapacity = in.Count
+	return &sdk.Empty{}, nil
+}
+
+func (m *sdkMock) GetPlayerCount(ctx context.Context, in *sdk.Empty, opts ...grpc.CallOption) (*sdk.Count, error) {
+	return &sdk.Count{Count: int64(len(m.players))}, nil
+}
+
 func (m *sdkMock) WatchGameServer(ctx context.Context, in *sdk.Empty, opts ...grpc.CallOption) (sdk.SDK_WatchGameServerClient, error) {
 	return m.wm, nil
 }
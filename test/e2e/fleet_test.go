@@ -17,7 +17,7 @@ package e2e
 import (
 	"fmt"
 	"math"
-	"sync"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -579,124 +579,30 @@ func assertSuccessOrUpdateConflict(t *testing.T, err error) {
 	}
 }
 
-// TestGameServerAllocationDuringGameServerDeletion is built to specifically
+// TestFleetScenarios runs each of the scenarios in the scenarios directory, which are built to specifically
 // test for race conditions of allocations when doing scale up/down,
 // rolling updates, etc. Failures may not happen ALL the time -- as that is the
 // nature of race conditions.
-func TestGameServerAllocationDuringGameServerDeletion(t *testing.T) {
+func TestFleetScenarios(t *testing.T) {
 	t.Parallel()
 
-	testAllocationRaceCondition := func(t *testing.T, fleet func() *v1alpha1.Fleet, deltaSleep time.Duration, delta func(t *testing.T, flt *v1alpha1.Fleet)) {
-		alpha1 := framework.AgonesClient.StableV1alpha1()
-
-		flt := fleet()
-		flt.ApplyDefaults()
-		size := int32(10)
-		flt.Spec.Replicas = size
-		flt, err := alpha1.Fleets(defaultNs).Create(flt)
-		if assert.Nil(t, err) {
-			defer alpha1.Fleets(defaultNs).Delete(flt.ObjectMeta.Name, nil) // nolint:errcheck
-		}
-
-		assert.Equal(t, size, flt.Spec.Replicas)
-
-		framework.WaitForFleetCondition(t, flt, e2e.FleetReadyCount(flt.Spec.Replicas))
-
-		var allocs []string
-
-		wg := sync.WaitGroup{}
-		wg.Add(2)
-		go func() {
-			for {
-				// this gives room for fleet scaling to go down - makes it more likely for the race condition to fire
-				time.Sleep(100 * time.Millisecond)
-				gsa := &allocationv1.GameServerAllocation{ObjectMeta: metav1.ObjectMeta{GenerateName: "allocation-"},
-					Spec: allocationv1.GameServerAllocationSpec{
-						Required: metav1.LabelSelector{MatchLabels: map[string]string{v1alpha1.FleetNameLabel: flt.ObjectMeta.Name}},
-					}}
-				gsa, err = framework.AgonesClient.AllocationV1().GameServerAllocations(defaultNs).Create(gsa)
-				if err != nil || gsa.Status.State == allocationv1.GameServerAllocationUnAllocated {
-					logrus.WithError(err).Info("Allocation ended")
-					break
-				}
-				logrus.WithField("gs", gsa.Status.GameServerName).Info("Allocated")
-				allocs = append(allocs, gsa.Status.GameServerName)
-			}
-			wg.Done()
-		}()
-		go func() {
-			// this tends to force the scaling to happen as we are fleet allocating
-			time.Sleep(deltaSleep)
-			// call the function that makes the change to the fleet
-			logrus.Info("Applying delta function")
-			delta(t, flt)
-			wg.Done()
-		}()
-
-		wg.Wait()
-		assert.NotEmpty(t, allocs)
-
-		for _, name := range allocs {
-			gsCheck, err := alpha1.GameServers(defaultNs).Get(name, metav1.GetOptions{})
-			assert.Nil(t, err)
-			assert.True(t, gsCheck.ObjectMeta.DeletionTimestamp.IsZero())
-		}
-	}
-
-	t.Run("scale down", func(t *testing.T) {
-		t.Parallel()
-
-		testAllocationRaceCondition(t, defaultFleet, time.Second,
-			func(t *testing.T, flt *v1alpha1.Fleet) {
-				const targetScale = int32(0)
-				flt = scaleFleetPatch(t, flt, targetScale)
-				assert.Equal(t, targetScale, flt.Spec.Replicas)
-			})
-	})
-
-	t.Run("recreate update", func(t *testing.T) {
-		t.Parallel()
-
-		fleet := func() *v1alpha1.Fleet {
-			flt := defaultFleet()
-			flt.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
-			flt.Spec.Template.ObjectMeta.Annotations = map[string]string{key: red}
+	files, err := filepath.Glob(filepath.Join("scenarios", "*.yaml"))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, files)
 
-			return flt
+	for _, file := range files {
+		s, err := e2e.LoadScenario(file)
+		if !assert.Nil(t, err) {
+			continue
 		}
 
-		testAllocationRaceCondition(t, fleet, time.Second,
-			func(t *testing.T, flt *v1alpha1.Fleet) {
-				flt, err := framework.AgonesClient.StableV1alpha1().Fleets(defaultNs).Get(flt.ObjectMeta.Name, metav1.GetOptions{})
-				assert.Nil(t, err)
-				fltCopy := flt.DeepCopy()
-				fltCopy.Spec.Template.ObjectMeta.Annotations[key] = green
-				_, err = framework.AgonesClient.StableV1alpha1().Fleets(defaultNs).Update(fltCopy)
-				assertSuccessOrUpdateConflict(t, err)
-			})
-	})
-
-	t.Run("rolling update", func(t *testing.T) {
-		t.Parallel()
-
-		fleet := func() *v1alpha1.Fleet {
-			flt := defaultFleet()
-			flt.Spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
-			flt.Spec.Template.ObjectMeta.Annotations = map[string]string{key: red}
-
-			return flt
-		}
+		t.Run(s.Name, func(t *testing.T) {
+			t.Parallel()
 
-		testAllocationRaceCondition(t, fleet, time.Duration(0),
-			func(t *testing.T, flt *v1alpha1.Fleet) {
-				flt, err := framework.AgonesClient.StableV1alpha1().Fleets(defaultNs).Get(flt.ObjectMeta.Name, metav1.GetOptions{})
-				assert.Nil(t, err)
-				fltCopy := flt.DeepCopy()
-				fltCopy.Spec.Template.ObjectMeta.Annotations[key] = green
-				_, err = framework.AgonesClient.StableV1alpha1().Fleets(defaultNs).Update(fltCopy)
-				assertSuccessOrUpdateConflict(t, err)
-			})
-	})
+			result := framework.RunScenario(t, s, defaultFleet())
+			assert.NotEmpty(t, result.Allocated)
+		})
+	}
 }
 
 // TestCreateFleetAndUpdateScaleSubresource is built to
@@ -725,92 +631,32 @@ func TestCreateFleetAndUpdateScaleSubresource(t *testing.T) {
 
 // TestScaleUpAndDownInParallelStressTest creates N fleets, half of which start with replicas=0
 // and the other half with 0 and scales them up/down 3 times in parallel expecting it to reach
-// the desired number of ready replicas each time.
+// the desired number of ready replicas each time, without deleting any allocated GameServers.
 // This test is also used as a stress test with 'make stress-test-e2e', in which case it creates
 // many more fleets of bigger sizes and runs many more repetitions.
 func TestScaleUpAndDownInParallelStressTest(t *testing.T) {
 	t.Parallel()
 
-	alpha1 := framework.AgonesClient.StableV1alpha1()
-	fleetCount := 2
-	fleetSize := int32(10)
-	repeatCount := 3
-	deadline := time.Now().Add(1 * time.Minute)
-
-	logrus.WithField("fleetCount", fleetCount).
-		WithField("fleetSize", fleetSize).
-		WithField("repeatCount", repeatCount).
-		WithField("deadline", deadline).
-		Info("starting scale up/down test")
-
-	if framework.StressTestLevel > 0 {
-		fleetSize = 10 * int32(framework.StressTestLevel)
-		repeatCount = 10
-		fleetCount = 10
-		deadline = time.Now().Add(45 * time.Minute)
-	}
-
-	var fleets []*v1alpha1.Fleet
-
-	scaleUpStats := framework.NewStatsCollector(fmt.Sprintf("fleet_%v_scale_up", fleetSize))
-	scaleDownStats := framework.NewStatsCollector(fmt.Sprintf("fleet_%v_scale_down", fleetSize))
-
-	defer scaleUpStats.Report()
-	defer scaleDownStats.Report()
-
-	for fleetNumber := 0; fleetNumber < fleetCount; fleetNumber++ {
-		flt := defaultFleet()
-		flt.ObjectMeta.GenerateName = fmt.Sprintf("scale-fleet-%v-", fleetNumber)
-		if fleetNumber%2 == 0 {
-			// even-numbered fleets starts at fleetSize and are scaled down to zero and back.
-			flt.Spec.Replicas = fleetSize
-		} else {
-			// odd-numbered fleets starts at zero and are scaled up to fleetSize and back.
-			flt.Spec.Replicas = 0
-		}
-
-		flt, err := alpha1.Fleets(defaultNs).Create(flt)
-		if assert.Nil(t, err) {
-			defer alpha1.Fleets(defaultNs).Delete(flt.ObjectMeta.Name, nil) // nolint:errcheck
-		}
-		fleets = append(fleets, flt)
-	}
-
-	// wait for initial fleet conditions.
-	for fleetNumber, flt := range fleets {
-		if fleetNumber%2 == 0 {
-			framework.WaitForFleetCondition(t, flt, e2e.FleetReadyCount(fleetSize))
-		} else {
-			framework.WaitForFleetCondition(t, flt, e2e.FleetReadyCount(0))
-		}
+	s := e2e.StressTest{
+		Name: "scale-fleet",
+		Load: e2e.LoadProfile{
+			Fleets:              2,
+			FleetSize:           10,
+			Repetitions:         3,
+			Duration:            1 * time.Minute,
+			AllocationsPerCycle: 1,
+		},
+		Invariants: e2e.StressInvariants{NoAllocatedDeletions: true},
 	}
 
-	var wg sync.WaitGroup
-
-	for fleetNumber, flt := range fleets {
-		wg.Add(1)
-		go func(fleetNumber int, flt *v1alpha1.Fleet) {
-			defer wg.Done()
-			defer func() {
-				if err := recover(); err != nil {
-					t.Errorf("recovered panic: %v", err)
-				}
-			}()
-
-			if fleetNumber%2 == 0 {
-				scaleDownStats.ReportDuration(scaleAndWait(t, flt, 0), nil)
-			}
-			for i := 0; i < repeatCount; i++ {
-				if time.Now().After(deadline) {
-					break
-				}
-				scaleUpStats.ReportDuration(scaleAndWait(t, flt, fleetSize), nil)
-				scaleDownStats.ReportDuration(scaleAndWait(t, flt, 0), nil)
-			}
-		}(fleetNumber, flt)
+	if framework.StressTestLevel > 0 {
+		s.Load.FleetSize = 10 * int32(framework.StressTestLevel)
+		s.Load.Repetitions = 10
+		s.Load.Fleets = 10
+		s.Load.Duration = 45 * time.Minute
 	}
 
-	wg.Wait()
+	framework.RunStressTest(t, s, defaultFleet())
 }
 
 // Creates a fleet and one GameServer with Packed scheduling.
@@ -979,13 +825,6 @@ func schedulingFleetPatch(t *testing.T,
 	return fltRes
 }
 
-func scaleAndWait(t *testing.T, flt *v1alpha1.Fleet, fleetSize int32) time.Duration {
-	t0 := time.Now()
-	scaleFleetSubresource(t, flt, fleetSize)
-	framework.WaitForFleetCondition(t, flt, e2e.FleetReadyCount(fleetSize))
-	return time.Since(t0)
-}
-
 // scaleFleetPatch creates a patch to apply to a Fleet.
 // Easier for testing, as it removes object generational issues.
 func scaleFleetPatch(t *testing.T, f *v1alpha1.Fleet, scale int32) *v1alpha1.Fleet {
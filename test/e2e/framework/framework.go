@@ -32,8 +32,10 @@ import (
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/util/wait"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 
 	// required to use gcloud login see: https://github.com/kubernetes/client-go/issues/242
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -54,6 +56,9 @@ type Framework struct {
 	PullSecret      string
 	StressTestLevel int
 	PerfOutputDir   string
+	// RemoteAllocatorHost is the address, reachable from the controller, that remote
+	// allocators started by the tests are served on
+	RemoteAllocatorHost string
 }
 
 // New setups a testing framework using a kubeconfig path and the game server image to use for testing.
@@ -108,20 +113,16 @@ func (f *Framework) WaitForGameServerState(gs *stable.GameServer, state stable.G
 	timeout time.Duration) (*stable.GameServer, error) {
 	var readyGs *stable.GameServer
 
-	err := wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
-		var err error
-		readyGs, err = f.AgonesClient.StableV1alpha1().GameServers(gs.Namespace).Get(gs.Name, metav1.GetOptions{})
-
-		if err != nil {
-			logrus.WithError(err).Warn("error retrieving gameserver")
+	gameServers := f.AgonesClient.StableV1alpha1().GameServers(gs.Namespace)
+	lw := nameListWatch(gs.Name,
+		func(options metav1.ListOptions) (k8sruntime.Object, error) { return gameServers.List(options) },
+		gameServers.Watch)
+	err := listWatchUntil(lw, timeout, func(objects []k8sruntime.Object) (bool, error) {
+		if len(objects) == 0 {
 			return false, nil
 		}
-
-		if readyGs.Status.State == state {
-			return true, nil
-		}
-
-		return false, nil
+		readyGs = objects[0].(*stable.GameServer)
+		return readyGs.Status.State == state, nil
 	})
 
 	return readyGs, errors.Wrapf(err, "waiting for GameServer to be %v %v/%v",
@@ -133,13 +134,15 @@ func (f *Framework) WaitForGameServerState(gs *stable.GameServer, state stable.G
 func (f *Framework) WaitForFleetCondition(t *testing.T, flt *stable.Fleet, condition func(fleet *stable.Fleet) bool) {
 	t.Helper()
 	logrus.WithField("fleet", flt.Name).Info("waiting for fleet condition")
-	err := wait.PollImmediate(2*time.Second, 5*time.Minute, func() (bool, error) {
-		fleet, err := f.AgonesClient.StableV1alpha1().Fleets(flt.ObjectMeta.Namespace).Get(flt.ObjectMeta.Name, metav1.GetOptions{})
-		if err != nil {
-			return true, err
+	fleets := f.AgonesClient.StableV1alpha1().Fleets(flt.ObjectMeta.Namespace)
+	lw := nameListWatch(flt.ObjectMeta.Name,
+		func(options metav1.ListOptions) (k8sruntime.Object, error) { return fleets.List(options) },
+		fleets.Watch)
+	err := listWatchUntil(lw, 5*time.Minute, func(objects []k8sruntime.Object) (bool, error) {
+		if len(objects) == 0 {
+			return false, errors.Errorf("fleet %v not found", flt.ObjectMeta.Name)
 		}
-
-		return condition(fleet), nil
+		return condition(objects[0].(*stable.Fleet)), nil
 	})
 	if err != nil {
 		logrus.WithField("fleet", flt.Name).WithError(err).Info("error waiting for fleet condition")
@@ -152,13 +155,15 @@ func (f *Framework) WaitForFleetCondition(t *testing.T, flt *stable.Fleet, condi
 func (f *Framework) WaitForFleetAutoScalerCondition(t *testing.T, fas *autoscaling.FleetAutoscaler, condition func(fas *autoscaling.FleetAutoscaler) bool) {
 	t.Helper()
 	logrus.WithField("fleetautoscaler", fas.Name).Info("waiting for fleetautoscaler condition")
-	err := wait.PollImmediate(2*time.Second, 2*time.Minute, func() (bool, error) {
-		fleetautoscaler, err := f.AgonesClient.AutoscalingV1().FleetAutoscalers(fas.ObjectMeta.Namespace).Get(fas.ObjectMeta.Name, metav1.GetOptions{})
-		if err != nil {
-			return true, err
+	fleetAutoscalers := f.AgonesClient.AutoscalingV1().FleetAutoscalers(fas.ObjectMeta.Namespace)
+	lw := nameListWatch(fas.ObjectMeta.Name,
+		func(options metav1.ListOptions) (k8sruntime.Object, error) { return fleetAutoscalers.List(options) },
+		fleetAutoscalers.Watch)
+	err := listWatchUntil(lw, 2*time.Minute, func(objects []k8sruntime.Object) (bool, error) {
+		if len(objects) == 0 {
+			return false, errors.Errorf("fleetautoscaler %v not found", fas.ObjectMeta.Name)
 		}
-
-		return condition(fleetautoscaler), nil
+		return condition(objects[0].(*autoscaling.FleetAutoscaler)), nil
 	})
 	if err != nil {
 		logrus.WithField("fleetautoscaler", fas.Name).WithError(err).Info("error waiting for fleetautoscaler condition")
@@ -216,14 +221,26 @@ func (f *Framework) WaitForFleetGameServersCondition(flt *stable.Fleet,
 // specified by a callback and the size of GameServers to match fleet's Spec.Replicas.
 func (f *Framework) WaitForFleetGameServerListCondition(flt *stable.Fleet,
 	cond func(servers []stable.GameServer) bool) error {
-	return wait.Poll(2*time.Second, 5*time.Minute, func() (done bool, err error) {
-		gsList, err := f.ListGameServersFromFleet(flt)
-		if err != nil {
-			return false, err
-		}
-		if int32(len(gsList)) != flt.Spec.Replicas {
+	gameServers := f.AgonesClient.StableV1alpha1().GameServers(flt.ObjectMeta.Namespace)
+	selector := labels.Set{stable.FleetNameLabel: flt.ObjectMeta.Name}.String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (k8sruntime.Object, error) {
+			options.LabelSelector = selector
+			return gameServers.List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = selector
+			return gameServers.Watch(options)
+		},
+	}
+	return listWatchUntil(lw, 5*time.Minute, func(objects []k8sruntime.Object) (bool, error) {
+		if int32(len(objects)) != flt.Spec.Replicas {
 			return false, nil
 		}
+		gsList := make([]stable.GameServer, 0, len(objects))
+		for _, obj := range objects {
+			gsList = append(gsList, *obj.(*stable.GameServer))
+		}
 		return cond(gsList), nil
 	})
 }
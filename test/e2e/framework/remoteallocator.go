@@ -0,0 +1,252 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
+	multiclusterv1alpha1 "agones.dev/agones/pkg/apis/multicluster/v1alpha1"
+	allocationtesting "agones.dev/agones/pkg/gameserverallocations/testing"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RemoteAllocator is an allocation service, run in the e2e test process, that stands in for the
+// allocation service of another cluster in multi-cluster allocation tests. It serves HTTPS with
+// generated certificates, and requires the client certificate in the Secret it creates, which its
+// GameServerAllocationPolicies point the controller at. It allocates from a synthetic Fleet.
+type RemoteAllocator struct {
+	// ClusterName is the name of the remote cluster in the allocation policies
+	ClusterName string
+	// Endpoint is the allocation endpoint the controller sends requests to
+	Endpoint string
+	// SecretName is the name of the Secret with the client certificate and the CA certificate
+	SecretName string
+	// Backend is the synthetic Fleet that GameServers are allocated from
+	Backend *allocationtesting.FakeBackend
+
+	namespace string
+	requests  int64
+	failing   int32
+	server    *http.Server
+	f         *Framework
+}
+
+// StartRemoteAllocator starts a RemoteAllocator for the cluster, with a synthetic Fleet of size
+// GameServers, and creates the Secret for the controller to connect to it in the namespace.
+// The controller must be able to reach the host of the test process at f.RemoteAllocatorHost.
+func (f *Framework) StartRemoteAllocator(ns, clusterName string, size int) (*RemoteAllocator, error) {
+	if f.RemoteAllocatorHost == "" {
+		return nil, errors.New("the remote allocator host must be set to start a remote allocator")
+	}
+
+	ca, caKey, err := newCertificate(clusterName+"-ca", nil, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the CA certificate")
+	}
+	serverCert, serverKey, err := newCertificate(clusterName, []string{f.RemoteAllocatorHost}, ca, caKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the server certificate")
+	}
+	clientCert, clientKey, err := newCertificate(clusterName+"-client", nil, ca, caKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the client certificate")
+	}
+
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not listen for allocation requests")
+	}
+
+	r := &RemoteAllocator{
+		ClusterName: clusterName,
+		Endpoint: fmt.Sprintf("https://%s/v1/gameserverallocation",
+			net.JoinHostPort(f.RemoteAllocatorHost, fmt.Sprint(listener.Addr().(*net.TCPAddr).Port))),
+		SecretName: clusterName + "-allocator-client",
+		Backend:    allocationtesting.NewFakeBackend(clusterName+"-fleet", ns, size, 0),
+		namespace:  ns,
+		f:          f,
+	}
+
+	clientKeyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		listener.Close() // nolint: errcheck
+		return nil, errors.Wrap(err, "could not marshal the client key")
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: r.SecretName},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCert.Raw}),
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: clientKeyDER}),
+			// the controller expects the CA certificate in DER form
+			"ca.crt": ca.Raw,
+		},
+	}
+	if _, err = f.KubeClient.CoreV1().Secrets(ns).Create(secret); err != nil {
+		listener.Close() // nolint: errcheck
+		return nil, errors.Wrapf(err, "could not create secret %s", r.SecretName)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/gameserverallocation", r.allocate)
+	r.server = &http.Server{
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    clientCAs,
+		},
+	}
+	go func() {
+		if err := r.server.ServeTLS(listener, "", ""); err != http.ErrServerClosed {
+			logrus.WithError(err).WithField("cluster", clusterName).Error("remote allocator stopped serving")
+		}
+	}()
+
+	logrus.WithField("cluster", clusterName).WithField("endpoint", r.Endpoint).Info("remote allocator started")
+	return r, nil
+}
+
+// Policy returns a GameServerAllocationPolicy, with the priority, weight and labels,
+// that sends allocations to the RemoteAllocator after trying the extraEndpoints first
+func (r *RemoteAllocator) Policy(priority, weight int, labels map[string]string, extraEndpoints ...string) *multiclusterv1alpha1.GameServerAllocationPolicy {
+	return &multiclusterv1alpha1.GameServerAllocationPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "allocationpolicy-",
+			Namespace:    r.namespace,
+			Labels:       labels,
+		},
+		Spec: multiclusterv1alpha1.GameServerAllocationPolicySpec{
+			Priority: priority,
+			Weight:   weight,
+			ConnectionInfo: multiclusterv1alpha1.ClusterConnectionInfo{
+				ClusterName:         r.ClusterName,
+				AllocationEndpoints: append(extraEndpoints, r.Endpoint),
+				SecretName:          r.SecretName,
+			},
+		},
+	}
+}
+
+// Requests returns the number of allocation requests the RemoteAllocator has received
+func (r *RemoteAllocator) Requests() int {
+	return int(atomic.LoadInt64(&r.requests))
+}
+
+// SetFailing sets whether the RemoteAllocator fails every allocation request with an internal server error,
+// as the allocation service of an unhealthy cluster would
+func (r *RemoteAllocator) SetFailing(failing bool) {
+	var v int32
+	if failing {
+		v = 1
+	}
+	atomic.StoreInt32(&r.failing, v)
+}
+
+// Close stops the RemoteAllocator, and deletes its Secret
+func (r *RemoteAllocator) Close() error {
+	if err := r.server.Close(); err != nil {
+		return err
+	}
+	return r.f.KubeClient.CoreV1().Secrets(r.namespace).Delete(r.SecretName, nil)
+}
+
+// allocate serves an allocation request the same way as the allocator service
+func (r *RemoteAllocator) allocate(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	atomic.AddInt64(&r.requests, 1)
+	if atomic.LoadInt32(&r.failing) == 1 {
+		http.Error(w, "remote allocator is failing", http.StatusInternalServerError)
+		return
+	}
+
+	gsa := &allocationv1.GameServerAllocation{}
+	if err := json.NewDecoder(req.Body).Decode(gsa); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	result, err := r.Backend.Allocate(gsa)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		logrus.WithError(err).WithField("cluster", r.ClusterName).Error("could not write allocation response")
+	}
+}
+
+// newCertificate creates a certificate, and its key, for the hosts, signed by the parent
+// certificate and key, or a self signed CA certificate if there is no parent
+func newCertificate(commonName string, hosts []string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
@@ -0,0 +1,281 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
+	stable "agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// ScenarioActionScale scales the Fleet to the step's replicas
+	ScenarioActionScale ScenarioAction = "Scale"
+	// ScenarioActionUpdate changes the Fleet's GameServer template, which triggers
+	// the Fleet's update strategy (Recreate or RollingUpdate)
+	ScenarioActionUpdate ScenarioAction = "Update"
+	// ScenarioActionCordon marks a node that hosts one of the Fleet's GameServers as unschedulable.
+	// Cordoned nodes are uncordoned when the scenario completes.
+	ScenarioActionCordon ScenarioAction = "Cordon"
+	// ScenarioActionWaitForReady waits for the Fleet to have the step's replicas Ready
+	ScenarioActionWaitForReady ScenarioAction = "WaitForReady"
+	// ScenarioActionWait does nothing but wait for the step's delay, while allocations continue
+	ScenarioActionWait ScenarioAction = "Wait"
+
+	scenarioUpdateAnnotation = "stable.agones.dev/e2e-scenario-update"
+)
+
+// ScenarioAction is an action that a ScenarioStep performs against the Fleet
+type ScenarioAction string
+
+// Scenario composes a set of changes to a Fleet that are run while
+// continuously allocating from it, and the invariants to check once they are complete.
+type Scenario struct {
+	// Name of the scenario, used for logging
+	Name string `json:"name"`
+	// Stress scenarios are only run when stress testing is enabled, as they may disrupt other tests
+	Stress bool `json:"stress,omitempty"`
+	// Replicas the Fleet starts with
+	Replicas int32 `json:"replicas"`
+	// Strategy is the update strategy of the Fleet. Defaults to RollingUpdate
+	Strategy appsv1.DeploymentStrategyType `json:"strategy,omitempty"`
+	// AllocationClients is the number of clients allocating concurrently. Defaults to 1
+	AllocationClients int `json:"allocationClients,omitempty"`
+	// AllocationInterval is the wait between allocations for each client
+	AllocationInterval metav1.Duration `json:"allocationInterval,omitempty"`
+	// MaxAllocationErrorPercent is the percentage of allocation requests that may fail with an error.
+	// UnAllocated results are not errors, as the Fleet may have been scaled to zero.
+	MaxAllocationErrorPercent float64 `json:"maxAllocationErrorPercent,omitempty"`
+	// Steps are run in order while allocations are occurring
+	Steps []ScenarioStep `json:"steps"`
+}
+
+// ScenarioStep is a single change to the Fleet within a Scenario
+type ScenarioStep struct {
+	// Action to perform
+	Action ScenarioAction `json:"action"`
+	// Delay before the action is performed
+	Delay metav1.Duration `json:"delay,omitempty"`
+	// Replicas for the Scale and WaitForReady actions
+	Replicas int32 `json:"replicas,omitempty"`
+}
+
+// ScenarioResult is the outcome of a Scenario run
+type ScenarioResult struct {
+	// Allocated are the names of all the GameServers that were allocated
+	Allocated []string
+	// Requests is the total number of allocation requests
+	Requests int
+	// Errors is the number of allocation requests that returned an error
+	Errors int
+}
+
+// LoadScenario reads a Scenario from a YAML or JSON file
+func LoadScenario(path string) (*Scenario, error) {
+	file, err := os.Open(path) // nolint: gosec
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open scenario file %s", path)
+	}
+	defer file.Close() // nolint: errcheck
+
+	s := &Scenario{}
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(s); err != nil {
+		return nil, errors.Wrapf(err, "could not decode scenario file %s", path)
+	}
+	s.applyDefaults()
+	return s, nil
+}
+
+func (s *Scenario) applyDefaults() {
+	if s.Strategy == "" {
+		s.Strategy = appsv1.RollingUpdateDeploymentStrategyType
+	}
+	if s.AllocationClients == 0 {
+		s.AllocationClients = 1
+	}
+}
+
+// RunScenario creates the Fleet with the Scenario's replicas and strategy, and once it is Ready, runs
+// the Scenario's steps while allocating from it. Once the steps are complete, it asserts that no
+// allocated GameServer was deleted, and that the allocation error rate is within the Scenario's limit.
+func (f *Framework) RunScenario(t *testing.T, s *Scenario, flt *stable.Fleet) ScenarioResult {
+	t.Helper()
+	if s.Stress && f.StressTestLevel == 0 {
+		t.Skipf("scenario %s is only run as a stress test", s.Name)
+	}
+	log := logrus.WithField("scenario", s.Name)
+	fleets := f.AgonesClient.StableV1alpha1().Fleets(flt.ObjectMeta.Namespace)
+
+	flt = flt.DeepCopy()
+	flt.Spec.Replicas = s.Replicas
+	flt.Spec.Strategy.Type = s.Strategy
+	flt.ApplyDefaults()
+	flt, err := fleets.Create(flt)
+	if !assert.Nil(t, err) {
+		assert.FailNow(t, "could not create scenario fleet")
+	}
+	defer fleets.Delete(flt.ObjectMeta.Name, nil) // nolint:errcheck
+
+	f.WaitForFleetCondition(t, flt, FleetReadyCount(flt.Spec.Replicas))
+
+	var cordoned []string
+	defer func() {
+		for _, node := range cordoned {
+			if err := f.setNodeUnschedulable(node, false); err != nil {
+				log.WithError(err).WithField("node", node).Error("could not uncordon node")
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	result := ScenarioResult{}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for i := 0; i < s.AllocationClients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-time.After(s.AllocationInterval.Duration):
+				}
+
+				gsa := &allocationv1.GameServerAllocation{ObjectMeta: metav1.ObjectMeta{GenerateName: "allocation-"},
+					Spec: allocationv1.GameServerAllocationSpec{
+						Required: metav1.LabelSelector{MatchLabels: map[string]string{stable.FleetNameLabel: flt.ObjectMeta.Name}},
+					}}
+				gsa, err := f.AgonesClient.AllocationV1().GameServerAllocations(flt.ObjectMeta.Namespace).Create(gsa)
+
+				mu.Lock()
+				result.Requests++
+				if err != nil {
+					log.WithError(err).Info("Allocation error")
+					result.Errors++
+				} else if gsa.Status.State == allocationv1.GameServerAllocationAllocated {
+					result.Allocated = append(result.Allocated, gsa.Status.GameServerName)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i, step := range s.Steps {
+		time.Sleep(step.Delay.Duration)
+		log.WithField("step", i).WithField("action", step.Action).Info("Running scenario step")
+
+		switch step.Action {
+		case ScenarioActionScale:
+			patch := fmt.Sprintf(`[{ "op": "replace", "path": "/spec/replicas", "value": %d }]`, step.Replicas)
+			_, err = fleets.Patch(flt.ObjectMeta.Name, types.JSONPatchType, []byte(patch))
+			assert.Nil(t, err, "could not scale fleet")
+		case ScenarioActionUpdate:
+			err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+				current, err := fleets.Get(flt.ObjectMeta.Name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				fltCopy := current.DeepCopy()
+				if fltCopy.Spec.Template.ObjectMeta.Annotations == nil {
+					fltCopy.Spec.Template.ObjectMeta.Annotations = map[string]string{}
+				}
+				fltCopy.Spec.Template.ObjectMeta.Annotations[scenarioUpdateAnnotation] = fmt.Sprintf("%d", i)
+				_, err = fleets.Update(fltCopy)
+				return err
+			})
+			assert.Nil(t, err, "could not update fleet")
+		case ScenarioActionCordon:
+			node, err := f.cordonFleetNode(flt, cordoned)
+			if assert.Nil(t, err, "could not cordon node") && node != "" {
+				cordoned = append(cordoned, node)
+			}
+		case ScenarioActionWaitForReady:
+			f.WaitForFleetCondition(t, flt, FleetReadyCount(step.Replicas))
+		case ScenarioActionWait:
+		default:
+			assert.FailNow(t, fmt.Sprintf("unknown scenario action: %s", step.Action))
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+
+	log.WithField("requests", result.Requests).WithField("errors", result.Errors).
+		WithField("allocated", len(result.Allocated)).Info("Scenario complete")
+
+	// no allocated GameServer should have been deleted
+	f.assertGameServersNotDeleted(t, flt.ObjectMeta.Namespace, result.Allocated)
+
+	if result.Requests > 0 {
+		errorPercent := float64(result.Errors) / float64(result.Requests) * 100
+		assert.True(t, errorPercent <= s.MaxAllocationErrorPercent,
+			"allocation error rate %.2f%% is more than %.2f%%", errorPercent, s.MaxAllocationErrorPercent)
+	}
+
+	return result
+}
+
+// cordonFleetNode cordons a node that hosts a GameServer from the Fleet, and has not yet been cordoned.
+// Returns an empty string if there is no such node.
+func (f *Framework) cordonFleetNode(flt *stable.Fleet, cordoned []string) (string, error) {
+	list, err := f.ListGameServersFromFleet(flt)
+	if err != nil {
+		return "", err
+	}
+
+	skip := map[string]bool{}
+	for _, n := range cordoned {
+		skip[n] = true
+	}
+
+	for _, gs := range list {
+		node := gs.Status.NodeName
+		if node == "" || skip[node] {
+			continue
+		}
+		return node, f.setNodeUnschedulable(node, true)
+	}
+
+	return "", nil
+}
+
+// setNodeUnschedulable cordons or uncordons a node
+func (f *Framework) setNodeUnschedulable(name string, unschedulable bool) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		node, err := f.KubeClient.CoreV1().Nodes().Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		nodeCopy := node.DeepCopy()
+		nodeCopy.Spec.Unschedulable = unschedulable
+		_, err = f.KubeClient.CoreV1().Nodes().Update(nodeCopy)
+		return err
+	})
+}
@@ -0,0 +1,254 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
+	stable "agones.dev/agones/pkg/apis/stable/v1alpha1"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	v1betaext "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+)
+
+// defaultMaxScaleDuration is how long a Fleet may take to scale when a StressTest doesn't set a limit
+const defaultMaxScaleDuration = 5 * time.Minute
+
+// LoadProfile is the load that a StressTest puts on the cluster
+type LoadProfile struct {
+	// Fleets is the number of Fleets that are scaled in parallel. Half of them start with
+	// FleetSize replicas and are scaled down first, and the other half start with none.
+	Fleets int
+	// FleetSize is the number of replicas each Fleet is scaled up to
+	FleetSize int32
+	// Repetitions is how many times each Fleet is scaled up and back down
+	Repetitions int
+	// Duration is how long repetitions keep starting for. 0 is no limit.
+	Duration time.Duration
+	// AllocationsPerCycle is how many GameServers are allocated from each Fleet before it is scaled down.
+	// Allocated GameServers are never deleted by scaling down, so they add up over the repetitions.
+	AllocationsPerCycle int
+}
+
+// StressInvariants are the checks that are made against the Fleets of a StressTest
+type StressInvariants struct {
+	// NoAllocatedDeletions checks that no allocated GameServer was deleted when its Fleet was scaled down
+	NoAllocatedDeletions bool
+	// MaxScaleDuration is the longest that a Fleet may take to reach its replicas once it is scaled.
+	// Defaults to 5 minutes.
+	MaxScaleDuration time.Duration
+}
+
+// StressTest scales a set of Fleets up and down in parallel, under the load of its LoadProfile,
+// and checks that its StressInvariants hold. It can be run against any cluster with Agones
+// installed, e.g. as an acceptance test.
+type StressTest struct {
+	// Name of the stress test, used for logging and to name the Fleets
+	Name string
+	// Load to put on the cluster
+	Load LoadProfile
+	// Invariants to check
+	Invariants StressInvariants
+}
+
+// StressResult is the outcome of a StressTest run
+type StressResult struct {
+	// ScaleUps are how long each scale up took
+	ScaleUps []time.Duration
+	// ScaleDowns are how long each scale down took
+	ScaleDowns []time.Duration
+	// Allocated are the names of all the GameServers that were allocated
+	Allocated []string
+}
+
+// RunStressTest creates the StressTest's Fleets from flt, and scales each of them between zero and the
+// LoadProfile's FleetSize in parallel, allocating from them before each scale down. Scaling durations
+// are reported to stats collectors, and failing the StressInvariants fails the test.
+func (f *Framework) RunStressTest(t *testing.T, s StressTest, flt *stable.Fleet) StressResult {
+	t.Helper()
+	maxScaleDuration := s.Invariants.MaxScaleDuration
+	if maxScaleDuration == 0 {
+		maxScaleDuration = defaultMaxScaleDuration
+	}
+	var deadline time.Time
+	if s.Load.Duration > 0 {
+		deadline = time.Now().Add(s.Load.Duration)
+	}
+
+	log := logrus.WithField("stress", s.Name).
+		WithField("fleetCount", s.Load.Fleets).
+		WithField("fleetSize", s.Load.FleetSize).
+		WithField("repeatCount", s.Load.Repetitions).
+		WithField("deadline", deadline)
+	log.Info("starting scale up/down stress test")
+
+	scaleUpStats := f.NewStatsCollector(fmt.Sprintf("fleet_%v_scale_up", s.Load.FleetSize))
+	scaleDownStats := f.NewStatsCollector(fmt.Sprintf("fleet_%v_scale_down", s.Load.FleetSize))
+	defer scaleUpStats.Report()
+	defer scaleDownStats.Report()
+
+	fleetClient := f.AgonesClient.StableV1alpha1().Fleets(flt.ObjectMeta.Namespace)
+	var fleets []*stable.Fleet
+	for i := 0; i < s.Load.Fleets; i++ {
+		fltCopy := flt.DeepCopy()
+		fltCopy.ObjectMeta.GenerateName = fmt.Sprintf("%s-%v-", s.Name, i)
+		if i%2 == 0 {
+			// even-numbered fleets start at FleetSize and are scaled down to zero and back.
+			fltCopy.Spec.Replicas = s.Load.FleetSize
+		} else {
+			// odd-numbered fleets start at zero and are scaled up to FleetSize and back.
+			fltCopy.Spec.Replicas = 0
+		}
+
+		created, err := fleetClient.Create(fltCopy)
+		if !assert.Nil(t, err) {
+			assert.FailNow(t, "could not create stress test fleet")
+		}
+		defer fleetClient.Delete(created.ObjectMeta.Name, nil) // nolint:errcheck
+		fleets = append(fleets, created)
+	}
+
+	// wait for initial fleet conditions.
+	for _, created := range fleets {
+		f.WaitForFleetCondition(t, created, FleetReadyCount(created.Spec.Replicas))
+	}
+
+	var mu sync.Mutex
+	result := StressResult{}
+	var wg sync.WaitGroup
+
+	scale := func(flt *stable.Fleet, replicas int32) bool {
+		d, err := f.scaleFleetAndWait(flt, replicas, maxScaleDuration)
+		mu.Lock()
+		if replicas == 0 {
+			result.ScaleDowns = append(result.ScaleDowns, d)
+			scaleDownStats.ReportDuration(d, err)
+		} else {
+			result.ScaleUps = append(result.ScaleUps, d)
+			scaleUpStats.ReportDuration(d, err)
+		}
+		mu.Unlock()
+		if err != nil {
+			t.Errorf("fleet %s did not scale to %d replicas within %s: %v", flt.ObjectMeta.Name, replicas, maxScaleDuration, err)
+			return false
+		}
+		return true
+	}
+
+	scaleDown := func(flt *stable.Fleet) bool {
+		for i := 0; i < s.Load.AllocationsPerCycle; i++ {
+			gsa, err := f.AgonesClient.AllocationV1().GameServerAllocations(flt.ObjectMeta.Namespace).Create(GetAllocation(flt))
+			if err != nil {
+				log.WithError(err).WithField("fleet", flt.ObjectMeta.Name).Info("Allocation error")
+				continue
+			}
+			if gsa.Status.State == allocationv1.GameServerAllocationAllocated {
+				mu.Lock()
+				result.Allocated = append(result.Allocated, gsa.Status.GameServerName)
+				mu.Unlock()
+			}
+		}
+		return scale(flt, 0)
+	}
+
+	for i, created := range fleets {
+		wg.Add(1)
+		go func(fleetNumber int, flt *stable.Fleet) {
+			defer wg.Done()
+
+			if fleetNumber%2 == 0 && !scaleDown(flt) {
+				return
+			}
+			for i := 0; i < s.Load.Repetitions; i++ {
+				if !deadline.IsZero() && time.Now().After(deadline) {
+					break
+				}
+				if !scale(flt, s.Load.FleetSize) || !scaleDown(flt) {
+					return
+				}
+			}
+		}(i, created)
+	}
+
+	wg.Wait()
+
+	log.WithField("scaleUps", len(result.ScaleUps)).WithField("scaleDowns", len(result.ScaleDowns)).
+		WithField("allocated", len(result.Allocated)).Info("Stress test complete")
+
+	if s.Invariants.NoAllocatedDeletions {
+		f.assertGameServersNotDeleted(t, flt.ObjectMeta.Namespace, result.Allocated)
+	}
+
+	return result
+}
+
+// scaleFleetAndWait scales the Fleet with its scale subresource, and waits until it has the
+// replicas Ready that are not already Allocated. Returns how long that took.
+func (f *Framework) scaleFleetAndWait(flt *stable.Fleet, replicas int32, timeout time.Duration) (time.Duration, error) {
+	logrus.WithField("fleet", flt.ObjectMeta.Name).WithField("scale", replicas).Info("Scaling fleet")
+	t0 := time.Now()
+
+	fleets := f.AgonesClient.StableV1alpha1().Fleets(flt.ObjectMeta.Namespace)
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		sc, err := fleets.GetScale(flt.ObjectMeta.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		_, err = fleets.UpdateScale(flt.ObjectMeta.Name, &v1betaext.Scale{
+			ObjectMeta: metav1.ObjectMeta{Name: flt.ObjectMeta.Name, Namespace: flt.ObjectMeta.Namespace,
+				ResourceVersion: sc.ObjectMeta.ResourceVersion},
+			Spec: v1betaext.ScaleSpec{Replicas: replicas},
+		})
+		return err
+	})
+	if err != nil {
+		return time.Since(t0), errors.Wrap(err, "could not update the scale subresource")
+	}
+
+	lw := nameListWatch(flt.ObjectMeta.Name,
+		func(options metav1.ListOptions) (k8sruntime.Object, error) { return fleets.List(options) },
+		fleets.Watch)
+	err = listWatchUntil(lw, timeout, func(objects []k8sruntime.Object) (bool, error) {
+		if len(objects) == 0 {
+			return false, errors.Errorf("fleet %v not found", flt.ObjectMeta.Name)
+		}
+		current := objects[0].(*stable.Fleet)
+		ready := replicas - current.Status.AllocatedReplicas
+		if ready < 0 {
+			ready = 0
+		}
+		return current.Status.ReadyReplicas == ready, nil
+	})
+	return time.Since(t0), err
+}
+
+// assertGameServersNotDeleted asserts that each of the named GameServers exists, and is not being deleted
+func (f *Framework) assertGameServersNotDeleted(t *testing.T, ns string, names []string) {
+	t.Helper()
+	for _, name := range names {
+		gs, err := f.AgonesClient.StableV1alpha1().GameServers(ns).Get(name, metav1.GetOptions{})
+		if assert.Nil(t, err, "allocated GameServer %s should exist", name) {
+			assert.True(t, gs.ObjectMeta.DeletionTimestamp.IsZero(), "allocated GameServer %s should not be deleted", name)
+		}
+	}
+}
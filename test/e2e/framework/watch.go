@@ -0,0 +1,124 @@
+// Copyright 2019 Google LLC All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// listWatchUntil lists the objects of lw, and then watches them for changes, until condition returns
+// true for the latest versions of all of them, sorted by namespace and name, or until the timeout
+// expires, in which case it returns wait.ErrWaitTimeout.
+// If the watch is closed, or has expired, the objects are listed again.
+func listWatchUntil(lw cache.ListerWatcher, timeout time.Duration, condition func(objects []k8sruntime.Object) (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		list, err := lw.List(metav1.ListOptions{})
+		if err != nil {
+			return errors.Wrap(err, "error listing objects to watch")
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return errors.Wrap(err, "error extracting listed objects")
+		}
+
+		objects := map[string]k8sruntime.Object{}
+		for _, obj := range items {
+			key, err := cache.MetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return err
+			}
+			objects[key] = obj
+		}
+		check := func() (bool, error) {
+			keys := make([]string, 0, len(objects))
+			for key := range objects {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			sorted := make([]k8sruntime.Object, 0, len(keys))
+			for _, key := range keys {
+				sorted = append(sorted, objects[key])
+			}
+			return condition(sorted)
+		}
+
+		done, err := check()
+		if done || err != nil {
+			return err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return wait.ErrWaitTimeout
+		}
+		resourceVersion, err := meta.NewAccessor().ResourceVersion(list)
+		if err != nil {
+			return err
+		}
+		w, err := lw.Watch(metav1.ListOptions{ResourceVersion: resourceVersion})
+		if err != nil {
+			return errors.Wrap(err, "error watching objects")
+		}
+
+		_, err = watch.Until(remaining, w, func(event watch.Event) (bool, error) {
+			if event.Type == watch.Error {
+				return false, k8serrors.FromObject(event.Object)
+			}
+			key, err := cache.MetaNamespaceKeyFunc(event.Object)
+			if err != nil {
+				return false, err
+			}
+			if event.Type == watch.Deleted {
+				delete(objects, key)
+			} else {
+				objects[key] = event.Object
+			}
+			return check()
+		})
+		if err == watch.ErrWatchClosed || k8serrors.IsGone(err) || k8serrors.IsResourceExpired(err) {
+			continue
+		}
+		return err
+	}
+}
+
+// nameListWatch returns a ListWatch of the single object called name, from the list and
+// watch functions of its resource
+func nameListWatch(name string, listFunc func(metav1.ListOptions) (k8sruntime.Object, error),
+	watchFunc func(metav1.ListOptions) (watch.Interface, error)) *cache.ListWatch {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (k8sruntime.Object, error) {
+			options.FieldSelector = selector
+			return listFunc(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return watchFunc(options)
+		},
+	}
+}
@@ -15,14 +15,17 @@
 package e2e
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
 	"agones.dev/agones/pkg/apis/stable"
+	stablev1 "agones.dev/agones/pkg/apis/stable/v1"
 	"agones.dev/agones/pkg/apis/stable/v1alpha1"
 	e2eframework "agones.dev/agones/test/e2e/framework"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -57,6 +60,38 @@ func TestCreateConnect(t *testing.T) {
 	assert.Equal(t, "ACK: Hello World !\n", reply)
 }
 
+func TestCreateV1GameServer(t *testing.T) {
+	t.Parallel()
+	create := func(gs *stablev1.GameServer) ([]byte, error) {
+		body, err := json.Marshal(gs)
+		require.NoError(t, err)
+		return framework.AgonesClient.StableV1alpha1().RESTClient().Post().
+			AbsPath("/apis", stablev1.SchemeGroupVersion.Group, stablev1.SchemeGroupVersion.Version, "namespaces", defaultNs, "gameservers").
+			Body(body).DoRaw()
+	}
+
+	// v1 GameServers are defaulted and validated by the webhooks, the same as v1alpha1 ones
+	invalid := stablev1.ConvertGameServerFromV1alpha1(defaultGameServer())
+	invalid.Spec.Container = "missing"
+	_, err := create(invalid)
+	require.Error(t, err)
+	assert.True(t, k8serrors.IsInvalid(err), "unexpected error: %v", err)
+
+	raw, err := create(stablev1.ConvertGameServerFromV1alpha1(defaultGameServer()))
+	require.NoError(t, err)
+	created := &stablev1.GameServer{}
+	require.NoError(t, json.Unmarshal(raw, created))
+	defer framework.AgonesClient.StableV1alpha1().GameServers(defaultNs).Delete(created.ObjectMeta.Name, nil) // nolint: errcheck
+
+	assert.Equal(t, stablev1.SchemeGroupVersion.String(), created.TypeMeta.APIVersion)
+	assert.Contains(t, created.ObjectMeta.Finalizers, stable.GroupName)
+	assert.Equal(t, v1alpha1.GameServerStatePortAllocation, created.Status.State)
+
+	readyGs, err := framework.WaitForGameServerState(stablev1.ConvertGameServerToV1alpha1(created), v1alpha1.GameServerStateReady, time.Minute)
+	require.NoError(t, err)
+	assert.NotEmpty(t, readyGs.Status.Ports[0].Port)
+}
+
 // nolint:dupl
 func TestSDKSetLabel(t *testing.T) {
 	t.Parallel()
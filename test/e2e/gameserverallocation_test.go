@@ -170,6 +170,83 @@ func TestMultiClusterAllocationOnLocalCluster(t *testing.T) {
 	}
 }
 
+func TestMultiClusterAllocationFromRemoteCluster(t *testing.T) {
+	if framework.RemoteAllocatorHost == "" {
+		t.Skip("remote allocator host is not set")
+	}
+	t.Parallel()
+
+	fleets := framework.AgonesClient.StableV1alpha1().Fleets(defaultNs)
+	flt, err := fleets.Create(defaultFleet())
+	if assert.Nil(t, err) {
+		defer fleets.Delete(flt.ObjectMeta.Name, nil) // nolint:errcheck
+	}
+	framework.WaitForFleetCondition(t, flt, e2e.FleetReadyCount(flt.Spec.Replicas))
+
+	remote, err := framework.StartRemoteAllocator(defaultNs, "remotecluster", replicasCount)
+	if !assert.Nil(t, err) {
+		assert.FailNow(t, "could not start remote allocator")
+	}
+	defer remote.Close() // nolint:errcheck
+
+	policies := framework.AgonesClient.MulticlusterV1alpha1().GameServerAllocationPolicies(defaultNs)
+	policyLabels := map[string]string{"cluster": "remote-allocator"}
+
+	// the remote cluster has the highest priority, and its first allocation endpoint can't be reached
+	unreachable := "https://" + framework.RemoteAllocatorHost + ":1/v1/gameserverallocation"
+	remotePolicy, err := policies.Create(remote.Policy(1, 100, policyLabels, unreachable))
+	if assert.Nil(t, err) {
+		defer policies.Delete(remotePolicy.ObjectMeta.Name, nil) // nolint:errcheck
+	}
+	localPolicy, err := policies.Create(&multiclusterv1alpha1.GameServerAllocationPolicy{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "allocationpolicy-", Labels: policyLabels},
+		Spec: multiclusterv1alpha1.GameServerAllocationPolicySpec{
+			Priority: 2,
+			Weight:   100,
+			ConnectionInfo: multiclusterv1alpha1.ClusterConnectionInfo{
+				AllocationEndpoints: []string{"localhost"},
+				ClusterName:         "localcluster",
+				SecretName:          remote.SecretName,
+			},
+		},
+	})
+	if assert.Nil(t, err) {
+		defer policies.Delete(localPolicy.ObjectMeta.Name, nil) // nolint:errcheck
+	}
+
+	gsa := &allocationv1.GameServerAllocation{
+		ObjectMeta: metav1.ObjectMeta{ClusterName: "localcluster", GenerateName: "allocation-"},
+		Spec: allocationv1.GameServerAllocationSpec{
+			Required: metav1.LabelSelector{MatchLabels: map[string]string{stablev1alpha1.FleetNameLabel: flt.ObjectMeta.Name}},
+			MultiClusterSetting: allocationv1.MultiClusterSetting{
+				Enabled:        true,
+				PolicySelector: metav1.LabelSelector{MatchLabels: policyLabels},
+			},
+		},
+	}
+
+	// allocated by the remote cluster, from its second allocation endpoint
+	result, err := framework.AgonesClient.AllocationV1().GameServerAllocations(defaultNs).Create(gsa.DeepCopy())
+	if assert.Nil(t, err) {
+		assert.Equal(t, allocationv1.GameServerAllocationAllocated, result.Status.State)
+		assert.Equal(t, 1, remote.Requests())
+		_, allocated := remote.Backend.Counts()
+		assert.Equal(t, 1, allocated)
+	}
+
+	// allocated by the local cluster, once the remote cluster fails
+	remote.SetFailing(true)
+	result, err = framework.AgonesClient.AllocationV1().GameServerAllocations(defaultNs).Create(gsa.DeepCopy())
+	if assert.Nil(t, err) {
+		assert.Equal(t, allocationv1.GameServerAllocationAllocated, result.Status.State)
+		assert.Equal(t, 2, remote.Requests())
+		gs, err := framework.AgonesClient.StableV1alpha1().GameServers(defaultNs).Get(result.Status.GameServerName, metav1.GetOptions{})
+		if assert.Nil(t, err) {
+			assert.Equal(t, flt.ObjectMeta.Name, gs.ObjectMeta.Labels[stablev1alpha1.FleetNameLabel])
+		}
+	}
+}
+
 // Can't allocate more GameServers if a fleet is fully used.
 func TestCreateFullFleetAndCantGameServerAllocate(t *testing.T) {
 	t.Parallel()
@@ -290,8 +367,8 @@ func TestGameServerAllocationPreferredSelection(t *testing.T) {
 	gsa := &allocationv1.GameServerAllocation{ObjectMeta: metav1.ObjectMeta{GenerateName: "allocation-"},
 		Spec: allocationv1.GameServerAllocationSpec{
 			Required: metav1.LabelSelector{MatchLabels: label},
-			Preferred: []metav1.LabelSelector{
-				{MatchLabels: map[string]string{stablev1alpha1.FleetNameLabel: preferred.ObjectMeta.Name}},
+			Preferred: []allocationv1.PreferredSelector{
+				{LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{stablev1alpha1.FleetNameLabel: preferred.ObjectMeta.Name}}},
 			},
 		}}
 
@@ -387,8 +464,8 @@ func TestGameServerAllocationDuringMultipleAllocationClients(t *testing.T) {
 	gsa := &allocationv1.GameServerAllocation{ObjectMeta: metav1.ObjectMeta{GenerateName: "allocation-"},
 		Spec: allocationv1.GameServerAllocationSpec{
 			Required: metav1.LabelSelector{MatchLabels: label},
-			Preferred: []metav1.LabelSelector{
-				{MatchLabels: map[string]string{stablev1alpha1.FleetNameLabel: "preferred"}},
+			Preferred: []allocationv1.PreferredSelector{
+				{LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{stablev1alpha1.FleetNameLabel: "preferred"}}},
 			},
 		}}
 
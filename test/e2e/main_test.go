@@ -40,6 +40,8 @@ func TestMain(m *testing.M) {
 		"optional secret to be used for pulling the gameserver and/or Agones SDK sidecar images")
 	stressTestLevel := flag.Int("stress", 0, "enable stress test at given level 0-100")
 	perfOutputDir := flag.String("perf-output", "", "write performance statistics to the specified directrory")
+	remoteAllocatorHost := flag.String("remote-allocator-host", "",
+		"optional address of this machine, reachable from the controller, to serve remote allocators on in multi-cluster allocation tests")
 
 	flag.Parse()
 
@@ -63,6 +65,7 @@ func TestMain(m *testing.M) {
 	framework.PullSecret = *pullSecret
 	framework.StressTestLevel = *stressTestLevel
 	framework.PerfOutputDir = *perfOutputDir
+	framework.RemoteAllocatorHost = *remoteAllocatorHost
 
 	// run cleanup before tests, to ensure no resources from previous runs exist.
 	err = framework.CleanUp(defaultNs)